@@ -0,0 +1,173 @@
+package fauxgl
+
+import "math"
+
+// AdaptiveSupersampling antialiases a frame by shading extra samples only
+// where they matter: after rendering the frame once, pixels whose color or
+// depth differs sharply from a neighbor, as along edges and specular
+// highlights, are refined with jittered samples while the flat areas
+// dominating most frames keep their single sample. Each extra sample
+// rasterizes the frame again but runs the fragment shader only in the
+// refined pixels.
+type AdaptiveSupersampling struct {
+	// Samples is the number of jittered samples shaded in each refined
+	// pixel in addition to the one at its center
+	Samples int
+	// ContrastThreshold refines pixels whose luminance or alpha differs
+	// from a neighbor's by more than it
+	ContrastThreshold float64
+	// DepthThreshold refines pixels where the window depth bends by more
+	// than it between neighbors, at silhouettes and creases; planar
+	// surfaces have linear window depth, so slopes don't count
+	DepthThreshold float64
+	// RefinedPixels counts the pixels refined by the last Render
+	RefinedPixels int
+}
+
+// NewAdaptiveSupersampling returns adaptive supersampling with 8 extra
+// samples per refined pixel
+func NewAdaptiveSupersampling() *AdaptiveSupersampling {
+	return &AdaptiveSupersampling{
+		Samples:           8,
+		ContrastThreshold: 0.04,
+		DepthThreshold:    1e-4,
+	}
+}
+
+// Render renders a frame into dc with draw, which must draw the complete
+// frame including clearing the buffers, e.g. by calling
+// SceneRenderer.RenderScene after clearing. draw is called once, then once
+// per extra sample if any pixel needs refining. The color buffer, and the
+// HDR buffer when enabled, receive the average of the samples of each
+// pixel; the depth buffer keeps the depth of the center samples.
+func (a *AdaptiveSupersampling) Render(dc *Context, draw func()) {
+	a.RefinedPixels = 0
+	draw()
+	mask := a.detect(dc)
+	for _, refine := range mask {
+		if refine {
+			a.RefinedPixels++
+		}
+	}
+	if a.RefinedPixels == 0 || a.Samples < 1 {
+		return
+	}
+
+	// Keep the center samples, whose buffers the extra passes clear
+	color := append([]uint8(nil), dc.ColorBuffer.Pix...)
+	depth := append([]float64(nil), dc.DepthBuffer...)
+	var hdr []float32
+	if dc.HDRBuffer != nil {
+		hdr = append([]float32(nil), dc.HDRBuffer.Pix...)
+	}
+
+	// Premultiplied sums of the samples of the refined pixels
+	sums := make([]Color, len(mask))
+	var hdrSums []Color
+	if hdr != nil {
+		hdrSums = make([]Color, len(mask))
+	}
+	accumulate := func() {
+		parallelRows(dc.Height, func(y0, y1 int) {
+			for y := y0; y < y1; y++ {
+				for x := 0; x < dc.Width; x++ {
+					i := y*dc.Width + x
+					if !mask[i] {
+						continue
+					}
+					c := MakeColor(dc.ColorBuffer.NRGBAAt(x, y))
+					sums[i] = sums[i].Add(c)
+					if hdrSums != nil {
+						hdrSums[i] = hdrSums[i].Add(dc.HDRBuffer.ColorAt(x, y).Premultiply())
+					}
+				}
+			}
+		})
+	}
+	accumulate()
+
+	screen := dc.screenMatrix
+	dc.mask = mask
+	for s := 1; s <= a.Samples; s++ {
+		dc.screenMatrix = Translate(jitterOffset(s)).Mul(screen)
+		draw()
+		accumulate()
+	}
+	dc.screenMatrix = screen
+	dc.mask = nil
+
+	copy(dc.ColorBuffer.Pix, color)
+	copy(dc.DepthBuffer, depth)
+	if hdr != nil {
+		copy(dc.HDRBuffer.Pix, hdr)
+	}
+	n := float64(a.Samples + 1)
+	for i, refine := range mask {
+		if !refine {
+			continue
+		}
+		x, y := i%dc.Width, i/dc.Width
+		dc.ColorBuffer.SetNRGBA(x, y, sums[i].DivScalar(n).Unpremultiply().NRGBA())
+		if hdr != nil {
+			dc.HDRBuffer.SetColor(x, y, hdrSums[i].DivScalar(n).Unpremultiply())
+		}
+	}
+}
+
+// detect flags the pixels of a rendered frame to refine
+func (a *AdaptiveSupersampling) detect(dc *Context) []bool {
+	w, h := dc.Width, dc.Height
+	luminance := make([]float64, w*h)
+	alpha := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := MakeColor(dc.ColorBuffer.NRGBAAt(x, y))
+			luminance[y*w+x] = 0.2126*c.R + 0.7152*c.G + 0.0722*c.B
+			alpha[y*w+x] = c.A
+		}
+	}
+	clear := dc.clearDepth()
+	mask := make([]bool, w*h)
+	parallelRows(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				// Color contrast with any of the four neighbors
+				contrast := func(j int) bool {
+					return math.Abs(luminance[i]-luminance[j]) > a.ContrastThreshold ||
+						math.Abs(alpha[i]-alpha[j]) > a.ContrastThreshold
+				}
+				if (x > 0 && contrast(i-1)) || (x < w-1 && contrast(i+1)) ||
+					(y > 0 && contrast(i-w)) || (y < h-1 && contrast(i+w)) {
+					mask[i] = true
+					continue
+				}
+				// Depth discontinuities along rows and columns
+				d := dc.DepthBuffer[i]
+				if x > 0 && x < w-1 && depthBends(dc.DepthBuffer[i-1], d, dc.DepthBuffer[i+1], clear, a.DepthThreshold) {
+					mask[i] = true
+				} else if y > 0 && y < h-1 && depthBends(dc.DepthBuffer[i-w], d, dc.DepthBuffer[i+w], clear, a.DepthThreshold) {
+					mask[i] = true
+				}
+			}
+		}
+	})
+	return mask
+}
+
+// jitterOffset returns the s-th point of the R2 low discrepancy sequence
+// as a subpixel offset in [-0.5, 0.5), starting at the pixel center for 0
+func jitterOffset(s int) Vector {
+	dx := math.Mod(0.5+float64(s)*0.7548776662466927, 1) - 0.5
+	dy := math.Mod(0.5+float64(s)*0.5698402909980532, 1) - 0.5
+	return Vector{dx, dy, 0}
+}
+
+// depthBends reports whether three consecutive window depths are not
+// collinear within threshold, or mix covered and uncovered pixels
+func depthBends(a, b, c, clear, threshold float64) bool {
+	if a == clear || b == clear || c == clear {
+		return !(a == clear && b == clear && c == clear)
+	}
+	return math.Abs(a+c-2*b) > threshold
+}
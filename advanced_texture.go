@@ -3,7 +3,7 @@ package fauxgl
 import (
 	"fmt"
 	"image"
-	"image/color"
+	"io/fs"
 	"math"
 	"os"
 )
@@ -15,6 +15,22 @@ type Texture interface {
 	BilinearSample(u, v float64) Color
 }
 
+// GradientTexture is implemented by textures that can filter using the
+// screen space derivatives of the texture coordinates
+type GradientTexture interface {
+	Texture
+	SampleGrad(u, v float64, dx, dy Vector) Color
+}
+
+// SampleTextureGrad samples a texture with derivatives when it supports them
+// and falls back to bilinear sampling otherwise
+func SampleTextureGrad(texture Texture, u, v float64, dx, dy Vector) Color {
+	if t, ok := texture.(GradientTexture); ok && (dx != Vector{} || dy != Vector{}) {
+		return t.SampleGrad(u, v, dx, dy)
+	}
+	return texture.BilinearSample(u, v)
+}
+
 // TextureType represents different types of textures
 type TextureType int
 
@@ -51,6 +67,8 @@ const (
 	WrapClamp
 	// WrapMirror - mirror repeat
 	WrapMirror
+	// WrapBorder - coordinates outside the texture sample BorderColor
+	WrapBorder
 )
 
 // TextureFilter defines texture filtering method
@@ -78,12 +96,32 @@ type AdvancedTexture struct {
 	MipLevels []image.Image // For mipmap support
 	Transform Matrix        // Texture coordinate transformation
 
+	// BorderColor is sampled outside the texture with WrapBorder
+	BorderColor Color
+	// HalfTexelClamp keeps WrapClamp and WrapBorder lookups half a texel
+	// inside the region of the texture that Transform maps the unit square
+	// to, at the mip level sampled, so filtering never blends in texels of
+	// neighbouring atlas regions or beyond the edge of a decal
+	HalfTexelClamp bool
+	// StochasticTiling hides the repetition of tileable textures by
+	// blending randomly offset copies over a triangle grid, see
+	// sampleStochastic
+	StochasticTiling bool
+
 	// **新增**: UV修改器支持
 	UVModifier *UVModifier // 动态UV修改器
+
+	// UDIM tiles keyed by tile number (1001, 1002, ...)
+	UDIMTiles map[int]*AdvancedTexture
+
+	// mipmaps holds the mip chain built on the first minified lookup of a
+	// texture without MipLevels, see mipLevels
+	mipmaps *lazyMipmaps
 }
 
 // NewAdvancedTexture creates a new advanced texture from an image
 func NewAdvancedTexture(img image.Image, textureType TextureType) *AdvancedTexture {
+	img = samplableImage(img)
 	bounds := img.Bounds()
 	texture := &AdvancedTexture{
 		Image:     img,
@@ -92,14 +130,12 @@ func NewAdvancedTexture(img image.Image, textureType TextureType) *AdvancedTextu
 		Type:      textureType,
 		WrapS:     WrapRepeat,
 		WrapT:     WrapRepeat,
-		MinFilter: FilterLinear,
+		MinFilter: FilterMipmap,
 		MagFilter: FilterLinear,
 		Transform: Identity(),
+		mipmaps:   &lazyMipmaps{},
 	}
 
-	// Generate mipmaps for better quality
-	texture.GenerateMipmaps()
-
 	return texture
 }
 
@@ -112,6 +148,24 @@ func LoadAdvancedTexture(path string, textureType TextureType) (*AdvancedTexture
 	return NewAdvancedTexture(img, textureType), nil
 }
 
+// LoadAdvancedTextureFS loads an advanced texture from a file system
+func LoadAdvancedTextureFS(fsys fs.FS, name string, textureType TextureType) (*AdvancedTexture, error) {
+	img, err := LoadImageFS(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return NewAdvancedTexture(img, textureType), nil
+}
+
+// LoadAdvancedTextureFromBytes decodes an advanced texture from encoded image data
+func LoadAdvancedTextureFromBytes(data []byte, textureType TextureType) (*AdvancedTexture, error) {
+	img, err := DecodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewAdvancedTexture(img, textureType), nil
+}
+
 // LoadTexture loads a texture from a file path (legacy compatibility)
 func LoadTexture(path string) (Texture, error) {
 	img, err := LoadImage(path)
@@ -131,33 +185,96 @@ func (t *AdvancedTexture) BilinearSample(u, v float64) Color {
 	return t.SampleWithFilter(u, v, FilterLinear)
 }
 
-// SampleWithFilter samples the texture with specified filtering
+// SampleWithFilter samples the texture with specified filtering. Like every
+// sampling method it returns a straight alpha color; texels are filtered
+// premultiplied so transparent texels do not darken their neighbours.
 func (t *AdvancedTexture) SampleWithFilter(u, v float64, filter TextureFilter) Color {
+	return t.sample(u, v, Vector{}, Vector{}, filter, false).Unpremultiply()
+}
+
+// SampleGrad samples the texture using the screen space derivatives of the
+// texture coordinates (see Vertex.TextureDx) to choose between MagFilter and
+// MinFilter. With FilterMipmap minified lookups blend the two nearest mip
+// levels (trilinear filtering).
+func (t *AdvancedTexture) SampleGrad(u, v float64, dx, dy Vector) Color {
+	return t.sample(u, v, dx, dy, t.MagFilter, true).Unpremultiply()
+}
+
+// sample applies the UV modifier, UDIM tiling, transform and wrapping and
+// samples the texture. When grad is set the filter is chosen from the
+// derivatives dx and dy, which are carried through every UV transformation.
+// The result is premultiplied.
+func (t *AdvancedTexture) sample(u, v float64, dx, dy Vector, filter TextureFilter, grad bool) Color {
 	// **新增**: 应用UV修改器变换
 	if t.UVModifier != nil {
-		u, v = t.UVModifier.TransformUV(u, v)
+		if grad {
+			ux, vx := t.UVModifier.TransformUV(u+dx.X, v+dx.Y)
+			uy, vy := t.UVModifier.TransformUV(u+dy.X, v+dy.Y)
+			u, v = t.UVModifier.TransformUV(u, v)
+			dx = Vector{ux - u, vx - v, 0}
+			dy = Vector{uy - u, vy - v, 0}
+		} else {
+			u, v = t.UVModifier.TransformUV(u, v)
+		}
+	}
+
+	// UDIM textures select the tile from the integer offset of the
+	// transformed UV coordinate
+	if len(t.UDIMTiles) > 0 {
+		return t.sampleUDIM(u, v, dx, dy, filter, grad)
 	}
 
+	if t.StochasticTiling {
+		return t.sampleStochastic(u, v, dx, dy, filter, grad)
+	}
+	return t.sampleTransformed(u, v, dx, dy, filter, grad)
+}
+
+// sampleTransformed applies the transform and wrapping and samples the
+// texture, see sample
+func (t *AdvancedTexture) sampleTransformed(u, v float64, dx, dy Vector, filter TextureFilter, grad bool) Color {
 	// Apply texture coordinate transformation
 	uv := Vector{u, v, 0}
 	transformedUV := t.Transform.MulPosition(uv)
 	u, v = transformedUV.X, transformedUV.Y
 
 	// Handle texture wrapping
+	var region *texelRegion
+	if t.HalfTexelClamp {
+		region = t.clampRegion()
+	}
+	if t.outsideBorder(u, v, region) {
+		return t.BorderColor.Premultiply()
+	}
+	u, v = region.clamp(u, v)
 	u = t.wrapCoordinate(u, t.WrapS)
 	v = t.wrapCoordinate(v, t.WrapT)
 
 	// Flip V coordinate (OpenGL convention)
 	v = 1.0 - v
 
+	if grad {
+		m := t.Transform
+		dx = Vector{m.X00*dx.X + m.X01*dx.Y, m.X10*dx.X + m.X11*dx.Y, 0}
+		dy = Vector{m.X00*dy.X + m.X01*dy.Y, m.X10*dy.X + m.X11*dy.Y, 0}
+		lod := t.lod(dx, dy)
+		if lod <= 0 {
+			filter = t.MagFilter
+		} else if t.MinFilter == FilterMipmap {
+			return t.sampleTrilinear(u, v, lod, region)
+		} else {
+			filter = t.MinFilter
+		}
+	}
+
+	u, v = region.inset(u, v, t.Width, t.Height)
 	switch filter {
 	case FilterNearest:
 		return t.sampleNearest(u, v)
 	case FilterLinear:
 		return t.sampleBilinear(u, v)
 	case FilterMipmap:
-		// For now, fall back to bilinear
-		// TODO: Implement proper mipmap sampling with derivatives
+		// Without derivatives the base level is sampled
 		return t.sampleBilinear(u, v)
 	default:
 		return t.sampleBilinear(u, v)
@@ -169,7 +286,7 @@ func (t *AdvancedTexture) wrapCoordinate(coord float64, wrap TextureWrap) float6
 	switch wrap {
 	case WrapRepeat:
 		return coord - math.Floor(coord)
-	case WrapClamp:
+	case WrapClamp, WrapBorder:
 		return math.Max(0, math.Min(1, coord))
 	case WrapMirror:
 		coord = coord - math.Floor(coord)
@@ -182,19 +299,111 @@ func (t *AdvancedTexture) wrapCoordinate(coord float64, wrap TextureWrap) float6
 	}
 }
 
+// outsideBorder reports whether transformed texture coordinates lie outside
+// the texture, or its clamp region, along an axis with WrapBorder
+func (t *AdvancedTexture) outsideBorder(u, v float64, region *texelRegion) bool {
+	u0, v0, u1, v1 := 0.0, 0.0, 1.0, 1.0
+	if region != nil {
+		u0, v0, u1, v1 = region.u0, region.v0, region.u1, region.v1
+	}
+	return t.WrapS == WrapBorder && (u < u0 || u > u1) ||
+		t.WrapT == WrapBorder && (v < v0 || v > v1)
+}
+
+// texelRegion is the region of a texture that lookups are clamped to along
+// the axes s and t, in texture coordinates before the V flip
+type texelRegion struct {
+	u0, v0, u1, v1 float64
+	s, t           bool
+}
+
+// clampRegion returns the region that Transform maps the unit square to,
+// for the axes with WrapClamp or WrapBorder, or nil
+func (t *AdvancedTexture) clampRegion() *texelRegion {
+	s := t.WrapS == WrapClamp || t.WrapS == WrapBorder
+	tt := t.WrapT == WrapClamp || t.WrapT == WrapBorder
+	if !s && !tt {
+		return nil
+	}
+	a := t.Transform.MulPosition(Vector{0, 0, 0})
+	b := t.Transform.MulPosition(Vector{1, 1, 0})
+	return &texelRegion{
+		u0: Clamp(math.Min(a.X, b.X), 0, 1),
+		v0: Clamp(math.Min(a.Y, b.Y), 0, 1),
+		u1: Clamp(math.Max(a.X, b.X), 0, 1),
+		v1: Clamp(math.Max(a.Y, b.Y), 0, 1),
+		s:  s,
+		t:  tt,
+	}
+}
+
+// clamp clamps texture coordinates to the region
+func (r *texelRegion) clamp(u, v float64) (float64, float64) {
+	if r == nil {
+		return u, v
+	}
+	if r.s {
+		u = Clamp(u, r.u0, r.u1)
+	}
+	if r.t {
+		v = Clamp(v, r.v0, r.v1)
+	}
+	return u, v
+}
+
+// inset clamps V flipped sample coordinates of an image of the given size
+// to the centers of the outermost texels of the region, so that bilinear
+// lookups stay inside it
+func (r *texelRegion) inset(u, v float64, width, height int) (float64, float64) {
+	if r == nil {
+		return u, v
+	}
+	if r.s && width > 1 {
+		u = insetCoordinate(u, r.u0, r.u1, width)
+	}
+	if r.t && height > 1 {
+		v = insetCoordinate(v, 1-r.v1, 1-r.v0, height)
+	}
+	return u, v
+}
+
+// insetCoordinate clamps a sample coordinate, which maps 0 and 1 to the
+// centers of the first and last texel, to the texels between the edges lo
+// and hi
+func insetCoordinate(coord, lo, hi float64, size int) float64 {
+	first := math.Round(lo * float64(size))
+	last := math.Max(first, math.Round(hi*float64(size))-1)
+	n := float64(size - 1)
+	return Clamp(coord*n, first, math.Min(last, n)) / n
+}
+
 // sampleNearest performs nearest neighbor sampling
 func (t *AdvancedTexture) sampleNearest(u, v float64) Color {
 	x := int(u*float64(t.Width-1) + 0.5)
 	y := int(v*float64(t.Height-1) + 0.5)
 	x = ClampInt(x, 0, t.Width-1)
 	y = ClampInt(y, 0, t.Height-1)
-	return MakeColor(t.Image.At(x, y))
+	return texelReader(t.Image)(x, y)
 }
 
 // sampleBilinear performs bilinear sampling
 func (t *AdvancedTexture) sampleBilinear(u, v float64) Color {
-	x := u * float64(t.Width-1)
-	y := v * float64(t.Height-1)
+	return sampleImageBilinear(t.Image, t.Width, t.Height, u, v)
+}
+
+// sampleImageBilinear bilinearly samples an image at normalized coordinates
+func sampleImageBilinear(img image.Image, width, height int, u, v float64) Color {
+	switch im := img.(type) {
+	case *TexelImage:
+		return im.bilinear(u, v)
+	case *image.NRGBA:
+		if im.Rect.Min == (image.Point{}) {
+			return sampleNRGBABilinear(im, width, height, u, v)
+		}
+	}
+	texel := texelReader(img)
+	x := u * float64(width-1)
+	y := v * float64(height-1)
 
 	x0 := int(x)
 	y0 := int(y)
@@ -202,20 +411,20 @@ func (t *AdvancedTexture) sampleBilinear(u, v float64) Color {
 	y1 := y0 + 1
 
 	// Clamp coordinates
-	x0 = ClampInt(x0, 0, t.Width-1)
-	y0 = ClampInt(y0, 0, t.Height-1)
-	x1 = ClampInt(x1, 0, t.Width-1)
-	y1 = ClampInt(y1, 0, t.Height-1)
+	x0 = ClampInt(x0, 0, width-1)
+	y0 = ClampInt(y0, 0, height-1)
+	x1 = ClampInt(x1, 0, width-1)
+	y1 = ClampInt(y1, 0, height-1)
 
 	// Fractional parts
 	fx := x - float64(int(x))
 	fy := y - float64(int(y))
 
 	// Sample four corners
-	c00 := MakeColor(t.Image.At(x0, y0))
-	c01 := MakeColor(t.Image.At(x0, y1))
-	c10 := MakeColor(t.Image.At(x1, y0))
-	c11 := MakeColor(t.Image.At(x1, y1))
+	c00 := texel(x0, y0)
+	c01 := texel(x0, y1)
+	c10 := texel(x1, y0)
+	c11 := texel(x1, y1)
 
 	// Bilinear interpolation
 	top := c00.Lerp(c10, fx)
@@ -223,33 +432,60 @@ func (t *AdvancedTexture) sampleBilinear(u, v float64) Color {
 	return top.Lerp(bottom, fy)
 }
 
-// GenerateMipmaps generates mipmap levels for the texture
-func (t *AdvancedTexture) GenerateMipmaps() {
-	// Clear existing mipmaps
-	t.MipLevels = nil
-	t.MipLevels = append(t.MipLevels, t.Image)
-
-	currentImg := t.Image
-	currentWidth := t.Width
-	currentHeight := t.Height
-
-	// Generate mipmaps until 1x1
-	for currentWidth > 1 || currentHeight > 1 {
-		newWidth := int(math.Max(1, float64(currentWidth)/2))
-		newHeight := int(math.Max(1, float64(currentHeight)/2))
-
-		// For simplicity, we'll skip actual mipmap generation here
-		// In a real implementation, you'd want to use proper downsampling
-		// For now, just store the original image at each level
-		t.MipLevels = append(t.MipLevels, currentImg)
-
-		currentWidth = newWidth
-		currentHeight = newHeight
+// texelReader returns a function reading the texel at (x, y), relative to
+// the image origin, as a premultiplied color like MakeColor. The pixel
+// bytes of NRGBA, RGBA, gray and compact images are read directly, without
+// the interface call and color conversion of At, and HDR texels unclamped.
+func texelReader(img image.Image) func(x, y int) Color {
+	switch im := img.(type) {
+	case *TexelImage:
+		return im.color
+	case *image.NRGBA:
+		return func(x, y int) Color {
+			i := y*im.Stride + x*4
+			p := im.Pix[i : i+4 : i+4]
+			a := float64(p[3]) / 255
+			return Color{float64(p[0]) / 255 * a, float64(p[1]) / 255 * a, float64(p[2]) / 255 * a, a}
+		}
+	case *image.RGBA:
+		return func(x, y int) Color {
+			i := y*im.Stride + x*4
+			p := im.Pix[i : i+4 : i+4]
+			return Color{float64(p[0]) / 255, float64(p[1]) / 255, float64(p[2]) / 255, float64(p[3]) / 255}
+		}
+	case *image.Gray:
+		return func(x, y int) Color {
+			g := float64(im.Pix[y*im.Stride+x]) / 255
+			return Color{g, g, g, 1}
+		}
+	case *HDRImage:
+		return func(x, y int) Color {
+			return im.ColorAt(im.Rect.Min.X+x, im.Rect.Min.Y+y).Premultiply()
+		}
+	}
+	origin := img.Bounds().Min
+	return func(x, y int) Color {
+		return MakeColor(img.At(origin.X+x, origin.Y+y))
+	}
+}
 
-		if newWidth == 1 && newHeight == 1 {
-			break
+// samplableImage converts an image once, at load, to a type texelReader
+// reads directly: paletted, YCbCr (JPEG), CMYK and other 8-bit images
+// become NRGBA. Images with 16-bit channels are kept to preserve their
+// precision, and every image is moved to origin (0, 0).
+func samplableImage(img image.Image) image.Image {
+	zero := img.Bounds().Min == (image.Point{})
+	switch img.(type) {
+	case *TexelImage, *HDRImage:
+		return img
+	case *image.NRGBA, *image.RGBA, *image.Gray:
+		if zero {
+			return img
 		}
+	case *image.NRGBA64, *image.RGBA64, *image.Gray16, *image.Alpha16:
+		return img
 	}
+	return toNRGBA(img)
 }
 
 // SampleNormal samples a normal map and returns the normal in tangent space
@@ -353,7 +589,7 @@ func (atlas *TextureAtlas) AddRegion(name string, x, y, width, height int) {
 func (atlas *TextureAtlas) SampleRegion(regionName string, u, v float64) Color {
 	region, exists := atlas.Regions[regionName]
 	if !exists {
-		return Color{1, 0, 1, 1} // Magenta for missing texture
+		return missingColor
 	}
 
 	// Map UV to region coordinates
@@ -366,7 +602,7 @@ func (atlas *TextureAtlas) SampleRegion(regionName string, u, v float64) Color {
 	x = ClampInt(x, 0, atlas.Width-1)
 	y = ClampInt(y, 0, atlas.Height-1)
 
-	return MakeColor(atlas.Image.At(x, y))
+	return MakeColor(atlas.Image.At(x, y)).Unpremultiply()
 }
 
 // CubeMapTexture represents a cube map texture for environment mapping
@@ -445,50 +681,29 @@ func LoadKTX2Texture(data []byte) (*AdvancedTexture, error) {
 		return nil, fmt.Errorf("failed to create KTX2 reader: %w", err)
 	}
 
-	header := reader.Header()
-	levels, err := reader.Levels()
+	levels, err := reader.DecodeLevels()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get KTX2 levels: %w", err)
-	}
-
-	if len(levels) == 0 {
-		return nil, fmt.Errorf("no texture levels found in KTX2 file")
+		return nil, err
 	}
 
-	// For now, we'll create a basic texture from the first level
-	// TODO: Implement proper KTX2 decoding with supercompression support
-	// firstLevel := levels[0] // 暂时不使用第一级数据
-
-	// Create a placeholder implementation
-	// In a real implementation, you'd need to:
-	// 1. Check the format and supercompression scheme
-	// 2. Decompress the data if needed (Basis Universal, Zstd, etc.)
-	// 3. Convert to a standard image format
-
-	// For demonstration, create a simple colored texture
-	img := createPlaceholderKTX2Image(int(header.PixelWidth), int(header.PixelHeight))
-
 	texture := &AdvancedTexture{
-		Image:     img,
-		Width:     int(header.PixelWidth),
-		Height:    int(header.PixelHeight),
+		Image:     levels[0],
+		Width:     levels[0].Bounds().Dx(),
+		Height:    levels[0].Bounds().Dy(),
 		Type:      KTX2Texture,
 		WrapS:     WrapRepeat,
 		WrapT:     WrapRepeat,
-		MinFilter: FilterLinear,
+		MinFilter: FilterMipmap,
 		MagFilter: FilterLinear,
 		Transform: Identity(),
 	}
 
-	// Store KTX2 specific data
-	texture.MipLevels = make([]image.Image, len(levels))
-	for i, level := range levels {
-		// In a real implementation, decode each level
-		texture.MipLevels[i] = createPlaceholderKTX2Image(
-			int(header.PixelWidth)>>i,
-			int(header.PixelHeight)>>i,
-		)
-		_ = level // 避免未使用变量警告
+	// Use the stored mip levels when present, otherwise build them on the
+	// first minified lookup
+	if len(levels) > 1 {
+		texture.MipLevels = levels
+	} else {
+		texture.mipmaps = &lazyMipmaps{}
 	}
 
 	return texture, nil
@@ -504,30 +719,6 @@ func LoadKTX2TextureFromFile(path string) (*AdvancedTexture, error) {
 	return LoadKTX2Texture(data)
 }
 
-// createPlaceholderKTX2Image creates a placeholder image for KTX2 textures
-// TODO: Replace with proper KTX2 decoding
-func createPlaceholderKTX2Image(width, height int) image.Image {
-	if width <= 0 {
-		width = 1
-	}
-	if height <= 0 {
-		height = 1
-	}
-
-	// Create a simple gradient image as placeholder
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			r := uint8(float64(x) / float64(width) * 255)
-			g := uint8(float64(y) / float64(height) * 255)
-			b := uint8(128) // 固定蓝色分量
-			img.Set(x, y, color.RGBA{r, g, b, 255})
-		}
-	}
-
-	return img
-}
-
 // IsKTX2File checks if the given data represents a KTX2 file
 func IsKTX2File(data []byte) bool {
 	if len(data) < len(KTX2_MAGIC) {
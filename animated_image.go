@@ -0,0 +1,380 @@
+package fauxgl
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// frameDelay returns the display time of frame i at fps in units of a
+// second divided by scale, rounded so the delays add up without drifting
+func frameDelay(i int, fps, scale float64) int {
+	return int(math.Round(float64(i+1)*scale/fps) - math.Round(float64(i)*scale/fps))
+}
+
+// GIFSink encodes frames as an animated GIF, each with its own 256 color
+// palette chosen by median cut and dithered. GIF timing has centisecond
+// steps, so frame rates that don't divide 100 alternate delays. Alpha is
+// dropped. The frames are kept in memory, at one byte per pixel, until
+// Close writes the file.
+type GIFSink struct {
+	// LoopCount is the number of times the animation repeats, 0 forever
+	// and -1 playing it once
+	LoopCount int
+	// Dither diffuses the quantization error, avoiding banding in
+	// gradients at the cost of noise
+	Dither bool
+
+	w      io.Writer
+	closer io.Closer
+	fps    float64
+	anim   gif.GIF
+}
+
+// NewGIFSink creates an animated GIF sink writing to w on Close
+func NewGIFSink(w io.Writer, fps float64) (*GIFSink, error) {
+	if fps <= 0 {
+		return nil, errors.New("invalid frame rate")
+	}
+	return &GIFSink{Dither: true, w: w, fps: fps}, nil
+}
+
+// CreateGIFFile creates an animated GIF file at path
+func CreateGIFFile(path string, fps float64) (*GIFSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewGIFSink(file, fps)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.closer = file
+	return s, nil
+}
+
+func (s *GIFSink) WriteFrame(img image.Image) error {
+	bounds := img.Bounds()
+	if len(s.anim.Image) > 0 && bounds.Size() != s.anim.Image[0].Bounds().Size() {
+		first := s.anim.Image[0].Bounds()
+		return fmt.Errorf("frame is %dx%d, not %dx%d", bounds.Dx(), bounds.Dy(), first.Dx(), first.Dy())
+	}
+	paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), medianCutPalette(img, 256))
+	if s.Dither {
+		draw.FloydSteinberg.Draw(paletted, paletted.Rect, img, bounds.Min)
+	} else {
+		draw.Draw(paletted, paletted.Rect, img, bounds.Min, draw.Src)
+	}
+	s.anim.Image = append(s.anim.Image, paletted)
+	s.anim.Delay = append(s.anim.Delay, frameDelay(len(s.anim.Delay), s.fps, 100))
+	return nil
+}
+
+// Close encodes the frames, and closes the file of a sink created by
+// CreateGIFFile
+func (s *GIFSink) Close() error {
+	s.anim.LoopCount = s.LoopCount
+	err := gif.EncodeAll(s.w, &s.anim)
+	s.anim = gif.GIF{}
+	if s.closer != nil {
+		if closeErr := s.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// medianCutPalette returns a palette of at most n colors for an image by
+// median cut over its colors at 5 bits per channel
+func medianCutPalette(img image.Image, n int) color.Palette {
+	type bin struct {
+		rgb   [3]int // 5 bit channels
+		count int
+	}
+	var histogram [1 << 15]int
+	bounds := img.Bounds()
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			row := nrgba.Pix[nrgba.PixOffset(bounds.Min.X, y):nrgba.PixOffset(bounds.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				histogram[int(row[i])>>3<<10|int(row[i+1])>>3<<5|int(row[i+2])>>3]++
+			}
+		}
+	} else {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				histogram[r>>11<<10|g>>11<<5|b>>11]++
+			}
+		}
+	}
+	var bins []bin
+	for i, count := range histogram {
+		if count > 0 {
+			bins = append(bins, bin{[3]int{i >> 10, i >> 5 & 31, i & 31}, count})
+		}
+	}
+
+	// Split the box with the most pixels spread over a channel at the
+	// median of that channel until there are n boxes
+	type box struct {
+		bins  []bin
+		count int
+		axis  int
+		span  int
+	}
+	measure := func(bins []bin) box {
+		b := box{bins: bins}
+		var lo, hi [3]int
+		lo = [3]int{31, 31, 31}
+		for _, entry := range bins {
+			b.count += entry.count
+			for c := 0; c < 3; c++ {
+				if entry.rgb[c] < lo[c] {
+					lo[c] = entry.rgb[c]
+				}
+				if entry.rgb[c] > hi[c] {
+					hi[c] = entry.rgb[c]
+				}
+			}
+		}
+		for c := 0; c < 3; c++ {
+			if hi[c]-lo[c] > b.span {
+				b.axis, b.span = c, hi[c]-lo[c]
+			}
+		}
+		return b
+	}
+	boxes := []box{measure(bins)}
+	for len(boxes) < n {
+		best := -1
+		for i, b := range boxes {
+			if b.span > 0 && (best < 0 || b.count*b.span > boxes[best].count*boxes[best].span) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		b := boxes[best]
+		sort.Slice(b.bins, func(i, j int) bool { return b.bins[i].rgb[b.axis] < b.bins[j].rgb[b.axis] })
+		half, split := 0, 1
+		for i, entry := range b.bins[:len(b.bins)-1] {
+			half += entry.count
+			split = i + 1
+			if 2*half >= b.count {
+				break
+			}
+		}
+		boxes[best] = measure(b.bins[:split])
+		boxes = append(boxes, measure(b.bins[split:]))
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, b := range boxes {
+		if b.count == 0 {
+			continue
+		}
+		var sum [3]int
+		for _, entry := range b.bins {
+			for c := 0; c < 3; c++ {
+				sum[c] += entry.rgb[c] * entry.count
+			}
+		}
+		channel := func(c int) uint8 {
+			return uint8((sum[c]*255 + b.count*31/2) / (b.count * 31))
+		}
+		palette = append(palette, color.RGBA{channel(0), channel(1), channel(2), 255})
+	}
+	if len(palette) == 0 {
+		palette = append(palette, color.RGBA{0, 0, 0, 255})
+	}
+	return palette
+}
+
+// APNGSink encodes frames as an animated PNG: lossless, with alpha, and
+// shown as its first frame by viewers without APNG support. Frames must
+// all have the size of the first. The file is completed by Close, which
+// seeks back to fill in the frame count.
+type APNGSink struct {
+	// LoopCount is the number of times the animation plays, 0 forever
+	LoopCount int
+
+	w             io.WriteSeeker
+	closer        io.Closer
+	fps           float64
+	width, height int
+	frames        int
+	sequence      uint32 // Sequence number of the next fcTL or fdAT chunk
+	actl          int64  // Offset of the acTL chunk
+	offset        int64
+	err           error
+}
+
+// NewAPNGSink creates an animated PNG sink writing to w
+func NewAPNGSink(w io.WriteSeeker, fps float64) (*APNGSink, error) {
+	if fps <= 0 {
+		return nil, errors.New("invalid frame rate")
+	}
+	return &APNGSink{w: w, fps: fps}, nil
+}
+
+// CreateAPNGFile creates an animated PNG file at path
+func CreateAPNGFile(path string, fps float64) (*APNGSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewAPNGSink(file, fps)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.closer = file
+	return s, nil
+}
+
+// chunk writes a PNG chunk
+func (s *APNGSink) chunk(kind string, data []byte) {
+	if s.err != nil {
+		return
+	}
+	b := make([]byte, 0, len(data)+12)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	b = append(b, kind...)
+	b = append(b, data...)
+	b = binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b[4:]))
+	n, err := s.w.Write(b)
+	s.offset += int64(n)
+	s.err = err
+}
+
+// actlData returns the data of the acTL chunk
+func (s *APNGSink) actlData() []byte {
+	data := binary.BigEndian.AppendUint32(nil, uint32(s.frames))
+	return binary.BigEndian.AppendUint32(data, uint32(s.LoopCount))
+}
+
+func (s *APNGSink) WriteFrame(img image.Image) error {
+	bounds := img.Bounds()
+	if s.frames == 0 {
+		s.width, s.height = bounds.Dx(), bounds.Dy()
+		n, err := s.w.Write([]byte("\x89PNG\r\n\x1a\n"))
+		s.offset += int64(n)
+		if err != nil {
+			return err
+		}
+		ihdr := binary.BigEndian.AppendUint32(nil, uint32(s.width))
+		ihdr = binary.BigEndian.AppendUint32(ihdr, uint32(s.height))
+		ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8 bit RGBA, deflate, adaptive filtering, no interlace
+		s.chunk("IHDR", ihdr)
+		s.actl = s.offset
+		s.chunk("acTL", s.actlData())
+	} else if bounds.Dx() != s.width || bounds.Dy() != s.height {
+		return fmt.Errorf("frame is %dx%d, not %dx%d", bounds.Dx(), bounds.Dy(), s.width, s.height)
+	}
+
+	data, err := pngImageData(img)
+	if err != nil {
+		return fmt.Errorf("failed to encode frame: %w", err)
+	}
+	fctl := binary.BigEndian.AppendUint32(nil, s.sequence)
+	fctl = binary.BigEndian.AppendUint32(fctl, uint32(s.width))
+	fctl = binary.BigEndian.AppendUint32(fctl, uint32(s.height))
+	fctl = binary.BigEndian.AppendUint32(fctl, 0)
+	fctl = binary.BigEndian.AppendUint32(fctl, 0)
+	fctl = binary.BigEndian.AppendUint16(fctl, uint16(frameDelay(s.frames, s.fps, 1000)))
+	fctl = binary.BigEndian.AppendUint16(fctl, 1000)
+	fctl = append(fctl, 0, 0) // No disposal, frames replace the canvas
+	s.chunk("fcTL", fctl)
+	s.sequence++
+	if s.frames == 0 {
+		s.chunk("IDAT", data)
+	} else {
+		s.chunk("fdAT", append(binary.BigEndian.AppendUint32(nil, s.sequence), data...))
+		s.sequence++
+	}
+	s.frames++
+	return s.err
+}
+
+// pngImageData returns the compressed RGBA scanlines of an image, each
+// with the Paeth filter
+func pngImageData(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	rgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, bounds.Min, draw.Src)
+	var b bytes.Buffer
+	z := zlib.NewWriter(&b)
+	stride := 4 * rgba.Rect.Dx()
+	row := make([]byte, 1+stride)
+	row[0] = 4 // Paeth
+	prev := make([]byte, stride)
+	for y := 0; y < rgba.Rect.Dy(); y++ {
+		cur := rgba.Pix[y*rgba.Stride : y*rgba.Stride+stride]
+		for i := range cur {
+			var a, c int
+			if i >= 4 {
+				a, c = int(cur[i-4]), int(prev[i-4])
+			}
+			up := int(prev[i])
+			p := a + up - c
+			pa, pb, pc := AbsInt(p-a), AbsInt(p-up), AbsInt(p-c)
+			predictor := c
+			if pa <= pb && pa <= pc {
+				predictor = a
+			} else if pb <= pc {
+				predictor = up
+			}
+			row[1+i] = cur[i] - uint8(predictor)
+		}
+		if _, err := z.Write(row); err != nil {
+			return nil, err
+		}
+		prev = cur
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Close writes the end of the file and the frame count, and closes the
+// file of a sink created by CreateAPNGFile
+func (s *APNGSink) Close() error {
+	if s.frames == 0 && s.err == nil {
+		s.err = errors.New("animated PNG has no frames")
+	}
+	s.chunk("IEND", nil)
+	if s.err == nil {
+		// Rewrite the acTL chunk, whose data and checksum hold the count
+		end := s.offset
+		if _, s.err = s.w.Seek(s.actl, io.SeekStart); s.err == nil {
+			s.offset = s.actl
+			s.chunk("acTL", s.actlData())
+			s.offset = end
+		}
+		if s.err == nil {
+			_, s.err = s.w.Seek(0, io.SeekEnd)
+		}
+	}
+	err := s.err
+	if s.closer != nil {
+		if closeErr := s.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
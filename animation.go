@@ -18,6 +18,11 @@ type AnimationChannel struct {
 	Property      AnimationProperty
 	Keyframes     []Keyframe
 	Interpolation InterpolationType
+
+	// UVTarget receives texture transform properties
+	UVTarget *UVTransform
+	// Pointer is the KHR_animation_pointer path the channel was loaded from
+	Pointer string
 }
 
 // AnimationProperty represents what property is being animated
@@ -34,6 +39,12 @@ const (
 	Weights
 	// Joints animates skinned mesh joints
 	Joints
+	// TextureOffset animates the offset of a texture transform (Vector, X=U, Y=V)
+	TextureOffset
+	// TextureRotation animates the rotation of a texture transform (float64, radians)
+	TextureRotation
+	// TextureScale animates the scale of a texture transform (Vector, X=U, Y=V)
+	TextureScale
 )
 
 // InterpolationType represents how values are interpolated between keyframes
@@ -52,6 +63,11 @@ const (
 type Keyframe struct {
 	Time  float64
 	Value interface{} // Can be Vector, Quaternion, float64, etc.
+
+	// Tangents of CubicSpline channels, of the same type as Value and scaled
+	// per second as in glTF; nil falls back to linear interpolation
+	InTangent  interface{}
+	OutTangent interface{}
 }
 
 // Quaternion represents a rotation quaternion
@@ -65,6 +81,7 @@ type Skin struct {
 	Joints              []*SceneNode // Joint nodes
 	InverseBindMatrices []Matrix     // Inverse bind pose matrices
 	Skeleton            *SceneNode   // Root skeleton node (optional)
+	JointMatrices       []Matrix     // World space joint matrices of the current pose
 }
 
 // Joint represents a joint/bone in skeletal animation
@@ -74,18 +91,24 @@ type Joint struct {
 	JointMatrix       Matrix // Computed joint matrix
 }
 
-// MorphTarget represents a morph target for shape interpolation
+// MorphTarget represents a morph target for shape interpolation. Like glTF
+// targets it stores displacements added to the base vertices, one per vertex
+// of the source primitive.
 type MorphTarget struct {
 	Name      string
-	Positions []Vector // Target positions
-	Normals   []Vector // Target normals (optional)
-	Tangents  []Vector // Target tangents (optional)
+	Positions []Vector // Position displacements
+	Normals   []Vector // Normal displacements (optional)
+	Tangents  []Vector // Tangent displacements (optional)
 }
 
 // MorphTargets represents a collection of morph targets
 type MorphTargets struct {
 	Targets []MorphTarget
 	Weights []float64
+	// Indices maps the triangle corners of the mesh, three per triangle, to
+	// the primitive vertices the targets are indexed by. Nil means the
+	// targets hold one entry per corner.
+	Indices []int
 }
 
 // NewAnimation creates a new animation
@@ -116,7 +139,7 @@ func (anim *Animation) Evaluate(time float64) {
 
 // Evaluate evaluates the animation channel at a specific time
 func (channel *AnimationChannel) Evaluate(time float64) {
-	if len(channel.Keyframes) == 0 || channel.Target == nil {
+	if len(channel.Keyframes) == 0 || (channel.Target == nil && channel.UVTarget == nil) {
 		return
 	}
 
@@ -143,7 +166,11 @@ func (channel *AnimationChannel) Evaluate(time float64) {
 		// Calculate interpolation factor
 		t := (time - before.Time) / (after.Time - before.Time)
 
-		value = channel.interpolate(before.Value, after.Value, t)
+		if channel.Interpolation == CubicSpline && before.OutTangent != nil && after.InTangent != nil {
+			value = channel.cubicSplineInterpolate(before, after, t)
+		} else {
+			value = channel.interpolate(before.Value, after.Value, t)
+		}
 	}
 
 	// Apply the animated value to the target
@@ -206,7 +233,7 @@ func (channel *AnimationChannel) interpolate(before, after interface{}, t float6
 // linearInterpolate performs linear interpolation between two values
 func (channel *AnimationChannel) linearInterpolate(before, after interface{}, t float64) interface{} {
 	switch channel.Property {
-	case Translation, ScaleProperty:
+	case Translation, ScaleProperty, TextureOffset, TextureScale:
 		if v1, ok := before.(Vector); ok {
 			if v2, ok := after.(Vector); ok {
 				return v1.Lerp(v2, t)
@@ -220,6 +247,13 @@ func (channel *AnimationChannel) linearInterpolate(before, after interface{}, t
 			}
 		}
 
+	case TextureRotation:
+		if r1, ok := before.(float64); ok {
+			if r2, ok := after.(float64); ok {
+				return r1*(1-t) + r2*t
+			}
+		}
+
 	case Weights:
 		if w1, ok := before.([]float64); ok {
 			if w2, ok := after.([]float64); ok {
@@ -239,8 +273,70 @@ func (channel *AnimationChannel) linearInterpolate(before, after interface{}, t
 	return before
 }
 
+// cubicSplineInterpolate evaluates the cubic Hermite spline between two
+// keyframes as defined by glTF CubicSpline samplers
+func (channel *AnimationChannel) cubicSplineInterpolate(before, after Keyframe, t float64) interface{} {
+	dt := after.Time - before.Time
+	t2 := t * t
+	t3 := t2 * t
+	h00 := 2*t3 - 3*t2 + 1
+	h10 := (t3 - 2*t2 + t) * dt
+	h01 := -2*t3 + 3*t2
+	h11 := (t3 - t2) * dt
+
+	switch v0 := before.Value.(type) {
+	case Vector:
+		v1, ok1 := after.Value.(Vector)
+		b0, ok2 := before.OutTangent.(Vector)
+		a1, ok3 := after.InTangent.(Vector)
+		if ok1 && ok2 && ok3 {
+			return v0.MulScalar(h00).Add(b0.MulScalar(h10)).Add(v1.MulScalar(h01)).Add(a1.MulScalar(h11))
+		}
+
+	case Quaternion:
+		v1, ok1 := after.Value.(Quaternion)
+		b0, ok2 := before.OutTangent.(Quaternion)
+		a1, ok3 := after.InTangent.(Quaternion)
+		if ok1 && ok2 && ok3 {
+			return Quaternion{
+				h00*v0.X + h10*b0.X + h01*v1.X + h11*a1.X,
+				h00*v0.Y + h10*b0.Y + h01*v1.Y + h11*a1.Y,
+				h00*v0.Z + h10*b0.Z + h01*v1.Z + h11*a1.Z,
+				h00*v0.W + h10*b0.W + h01*v1.W + h11*a1.W,
+			}.Normalize()
+		}
+
+	case float64:
+		v1, ok1 := after.Value.(float64)
+		b0, ok2 := before.OutTangent.(float64)
+		a1, ok3 := after.InTangent.(float64)
+		if ok1 && ok2 && ok3 {
+			return h00*v0 + h10*b0 + h01*v1 + h11*a1
+		}
+
+	case []float64:
+		v1, ok1 := after.Value.([]float64)
+		b0, ok2 := before.OutTangent.([]float64)
+		a1, ok3 := after.InTangent.([]float64)
+		if ok1 && ok2 && ok3 && len(v1) == len(v0) && len(b0) == len(v0) && len(a1) == len(v0) {
+			result := make([]float64, len(v0))
+			for i := range result {
+				result[i] = h00*v0[i] + h10*b0[i] + h01*v1[i] + h11*a1[i]
+			}
+			return result
+		}
+	}
+
+	return channel.linearInterpolate(before.Value, after.Value, t)
+}
+
 // applyValue applies the animated value to the target node
 func (channel *AnimationChannel) applyValue(value interface{}) {
+	if channel.UVTarget != nil {
+		channel.applyUVValue(value)
+		return
+	}
+
 	if channel.Target == nil {
 		return
 	}
@@ -251,8 +347,8 @@ func (channel *AnimationChannel) applyValue(value interface{}) {
 			// Update translation component of local transform
 			transform := channel.Target.LocalTransform
 			transform = Identity().Translate(v).Mul(
-				Identity().Scale(channel.getScale(transform)).Mul(
-					channel.getRotationMatrix(transform)))
+				channel.getRotationMatrix(transform).Mul(
+					Identity().Scale(channel.getScale(transform))))
 			channel.Target.SetTransform(transform)
 		}
 
@@ -272,22 +368,54 @@ func (channel *AnimationChannel) applyValue(value interface{}) {
 				channel.getRotationMatrix(channel.Target.LocalTransform).Mul(Identity().Scale(v)))
 			channel.Target.SetTransform(transform)
 		}
+
+	case Weights:
+		if w, ok := value.([]float64); ok {
+			// glTF weights target the node's mesh, whose primitives are
+			// loaded as child nodes
+			setMorphWeights(channel.Target, w)
+			for _, child := range channel.Target.Children {
+				setMorphWeights(child, w)
+			}
+		}
+	}
+}
+
+// setMorphWeights copies animated weights into the morph targets of a node
+func setMorphWeights(node *SceneNode, weights []float64) {
+	if node.MorphTargets == nil {
+		return
 	}
+	node.MorphTargets.Weights = append(node.MorphTargets.Weights[:0], weights...)
 }
 
-// Helper functions to extract transform components (simplified)
+// Helper functions to extract the components of a translation, rotation,
+// scale (TRS) transform
 func (channel *AnimationChannel) getTranslation(matrix Matrix) Vector {
 	return Vector{matrix.X03, matrix.X13, matrix.X23}
 }
 
 func (channel *AnimationChannel) getScale(matrix Matrix) Vector {
-	// Simplified scale extraction
-	return Vector{1, 1, 1}
+	// Column lengths of the upper 3x3
+	return Vector{
+		Vector{matrix.X00, matrix.X10, matrix.X20}.Length(),
+		Vector{matrix.X01, matrix.X11, matrix.X21}.Length(),
+		Vector{matrix.X02, matrix.X12, matrix.X22}.Length(),
+	}
 }
 
 func (channel *AnimationChannel) getRotationMatrix(matrix Matrix) Matrix {
-	// Simplified rotation extraction - return identity for now
-	return Identity()
+	// Upper 3x3 with the scale divided out of each column
+	s := channel.getScale(matrix)
+	if s.X == 0 || s.Y == 0 || s.Z == 0 {
+		return Identity()
+	}
+	return Matrix{
+		matrix.X00 / s.X, matrix.X01 / s.Y, matrix.X02 / s.Z, 0,
+		matrix.X10 / s.X, matrix.X11 / s.Y, matrix.X12 / s.Z, 0,
+		matrix.X20 / s.X, matrix.X21 / s.Y, matrix.X22 / s.Z, 0,
+		0, 0, 0, 1,
+	}
 }
 
 // Quaternion methods
@@ -402,6 +530,7 @@ type AnimationPlayer struct {
 	isPlaying   bool
 	currentAnim string
 	loop        bool
+	uvModifiers []*UVModifier
 }
 
 // NewAnimationPlayer creates a new animation player
@@ -420,6 +549,12 @@ func (player *AnimationPlayer) AddAnimation(name string, animation *Animation) {
 	player.animations[name] = animation
 }
 
+// AddUVModifier lets the player drive the scroll and rotation speeds of a
+// UV modifier, advancing it to the playback time on every update
+func (player *AnimationPlayer) AddUVModifier(modifier *UVModifier) {
+	player.uvModifiers = append(player.uvModifiers, modifier)
+}
+
 // Play starts playing an animation
 func (player *AnimationPlayer) Play(name string) bool {
 	if _, exists := player.animations[name]; exists {
@@ -435,6 +570,9 @@ func (player *AnimationPlayer) Play(name string) bool {
 func (player *AnimationPlayer) Stop() {
 	player.isPlaying = false
 	player.currentTime = 0
+	for _, modifier := range player.uvModifiers {
+		modifier.SetAnimationTime(0)
+	}
 }
 
 // Pause pauses the current animation
@@ -475,6 +613,9 @@ func (player *AnimationPlayer) Update(deltaTime float64) {
 
 	// Evaluate animation
 	animation.Evaluate(player.currentTime)
+	for _, modifier := range player.uvModifiers {
+		modifier.SetAnimationTime(player.currentTime)
+	}
 }
 
 // SetPlaySpeed sets the playback speed
@@ -498,11 +639,14 @@ func (skin *Skin) AddJoint(joint *SceneNode, inverseBindMatrix Matrix) {
 
 // UpdateJointMatrices updates all joint matrices for the current pose
 func (skin *Skin) UpdateJointMatrices() {
+	if len(skin.JointMatrices) != len(skin.Joints) {
+		skin.JointMatrices = make([]Matrix, len(skin.Joints))
+	}
 	for i, joint := range skin.Joints {
+		// Joint matrix = globalTransform * inverseBindMatrix
+		skin.JointMatrices[i] = joint.WorldTransform
 		if i < len(skin.InverseBindMatrices) {
-			// Joint matrix = globalTransform * inverseBindMatrix
-			jointMatrix := joint.WorldTransform.Mul(skin.InverseBindMatrices[i])
-			_ = jointMatrix // Store in a joint matrices array when needed
+			skin.JointMatrices[i] = joint.WorldTransform.Mul(skin.InverseBindMatrices[i])
 		}
 	}
 }
@@ -516,23 +660,3 @@ func NewMorphTarget(name string, vertexCount int) *MorphTarget {
 		Tangents:  make([]Vector, vertexCount),
 	}
 }
-
-// ApplyMorphTargets applies morph target deformation to mesh
-func ApplyMorphTargets(baseMesh *Mesh, targets *MorphTargets) *Mesh {
-	if len(targets.Targets) == 0 || len(targets.Weights) == 0 {
-		return baseMesh
-	}
-
-	// Create a copy of the base mesh
-	resultMesh := baseMesh.Copy()
-
-	// Apply weighted morph target deformation
-	// For simplified implementation, we'll just apply the first target's weight
-	if len(targets.Weights) > 0 && len(targets.Targets) > 0 {
-		// In a full implementation, you'd iterate through vertices and apply
-		// morph target displacements based on vertex indices
-		_ = resultMesh.Triangles // Keep reference to triangles for future implementation
-	}
-
-	return resultMesh
-}
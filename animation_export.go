@@ -0,0 +1,130 @@
+package fauxgl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// AnimationExporter renders the animations of a scene frame by frame from
+// its active camera and hands the frames to an EncoderSink, e.g. an
+// MJPEGSink for a playable video instead of a directory of images, a
+// GIFSink or APNGSink for an animated image, or an ffmpeg CommandSink for
+// MP4
+type AnimationExporter struct {
+	Scene         *Scene
+	Width, Height int
+	FrameRate     float64
+	// Start is the animation time of the first frame in seconds
+	Start float64
+	// Duration is the exported time in seconds from Start; 0 exports the
+	// longest of the animations once, up to its end
+	Duration float64
+	// Camera, when set, returns the camera of every frame from its
+	// animation time, e.g. CameraAnimator.CameraAt; otherwise the active
+	// camera of the scene is used. Its aspect ratio is set to the frame's.
+	Camera func(t float64) *Camera
+	// Animations are evaluated at the time of every frame, as are the
+	// scroll and rotation speeds of the UV modifiers of the scene's
+	// textures; nil plays all the animations of the scene
+	Animations []*Animation
+	Quality    RenderQuality
+	Background Color
+	// Backdrop, when set, is drawn behind every frame instead of Background
+	Backdrop *GradientBackground
+	// Prepare, when set, configures the renderer before the first frame,
+	// e.g. to enable shadows
+	Prepare func(renderer *SceneRenderer)
+}
+
+// NewAnimationExporter creates an exporter of a scene at 30 frames per
+// second and preview quality
+func NewAnimationExporter(scene *Scene, width, height int) *AnimationExporter {
+	return &AnimationExporter{
+		Scene:      scene,
+		Width:      width,
+		Height:     height,
+		FrameRate:  30,
+		Quality:    QualityPreview,
+		Background: Black,
+	}
+}
+
+// animations returns the animations to evaluate
+func (e *AnimationExporter) animations() []*Animation {
+	if e.Animations != nil {
+		return e.Animations
+	}
+	animations := make([]*Animation, 0, len(e.Scene.Animations))
+	for _, animation := range e.Scene.Animations {
+		animations = append(animations, animation)
+	}
+	return animations
+}
+
+// Frames returns the number of frames Export renders. The frame at the end
+// time is left out, as looping animations show it again at the start.
+func (e *AnimationExporter) Frames() int {
+	duration := e.Duration
+	if duration <= 0 {
+		for _, animation := range e.animations() {
+			duration = math.Max(duration, animation.Duration-e.Start)
+		}
+	}
+	frames := int(math.Ceil(duration*e.FrameRate - 1e-9))
+	if frames < 1 {
+		frames = 1
+	}
+	return frames
+}
+
+// Export renders every frame into sink. The sink is left open, so several
+// exports can be appended; close it to finish the output.
+func (e *AnimationExporter) Export(sink EncoderSink) error {
+	if e.Scene.ActiveCamera == nil && e.Camera == nil {
+		return errors.New("scene has no active camera")
+	}
+	if e.FrameRate <= 0 {
+		return errors.New("invalid frame rate")
+	}
+	settings := e.Quality.Settings()
+	settings.ApplyToScene(e.Scene)
+	context := settings.NewContext(e.Width, e.Height)
+	renderer := NewSceneRenderer(context)
+	settings.ApplyToRenderer(renderer)
+	if e.Prepare != nil {
+		e.Prepare(renderer)
+	}
+
+	animations := e.animations()
+	frames := e.Frames()
+	active := e.Scene.ActiveCamera
+	defer func() { e.Scene.ActiveCamera = active }()
+	for i := 0; i < frames; i++ {
+		t := e.Start + float64(i)/e.FrameRate
+		for _, animation := range animations {
+			animation.Evaluate(t)
+		}
+		e.Scene.SetUVAnimationTime(t)
+		if e.Camera != nil {
+			camera := e.Camera(t)
+			if camera == nil {
+				return fmt.Errorf("no camera for frame %d", i)
+			}
+			camera.AspectRatio = float64(e.Width) / float64(e.Height)
+			e.Scene.ActiveCamera = camera
+		}
+		renderer.Time = t
+		if e.Backdrop != nil {
+			e.Backdrop.Draw(context)
+		} else {
+			context.ClearColorBufferWith(e.Background)
+		}
+		context.ClearDepthBuffer()
+		renderer.RenderScene(e.Scene)
+		if err := sink.WriteFrame(settings.Resolve(context)); err != nil {
+			return fmt.Errorf("failed to write frame %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+)
+
+// BackgroundType selects what a Background draws
+type BackgroundType int
+
+const (
+	// BackgroundColor fills the frame with a solid color
+	BackgroundColor BackgroundType = iota
+	// BackgroundEquirectangular draws a panorama mapping longitude across
+	// and latitude down its image, seen in the view directions of the camera
+	BackgroundEquirectangular
+	// BackgroundCubeMap draws a cube map seen in the view directions of the
+	// camera
+	BackgroundCubeMap
+	// BackgroundGradient draws a GradientBackground fixed to the frame
+	BackgroundGradient
+)
+
+// Background fills the frame behind the geometry of a scene, see
+// Scene.Background. Panoramas and cube maps follow the orientation of the
+// camera, so the backdrop turns with it like distant surroundings. It is
+// drawn per pixel in floating point, straight into the HDR buffer when the
+// context has one, so bright panoramas keep their range for tone mapping.
+type Background struct {
+	Type  BackgroundType
+	Color Color
+	// Panorama is the equirectangular image, its center looking along -Z
+	// with +Y up
+	Panorama *HDRImage
+	CubeMap  *CubeMapTexture
+	Gradient *GradientBackground
+	// Rotation turns panoramas and cube maps about the Y axis, in radians,
+	// counterclockwise seen from above
+	Rotation float64
+	// Intensity multiplies the color of panoramas and cube maps
+	Intensity float64
+}
+
+// NewColorBackground creates a solid background
+func NewColorBackground(color Color) *Background {
+	return &Background{Type: BackgroundColor, Color: color, Intensity: 1}
+}
+
+// NewEquirectangularBackground creates a background of an equirectangular
+// panorama. 8-bit images are converted to HDR images; an *HDRImage is used
+// as is.
+func NewEquirectangularBackground(panorama image.Image) *Background {
+	hdr, ok := panorama.(*HDRImage)
+	if !ok {
+		hdr = NewHDRImageFrom(toNRGBA(panorama))
+	}
+	return &Background{Type: BackgroundEquirectangular, Panorama: hdr, Intensity: 1}
+}
+
+// NewCubeMapBackground creates a background of a cube map
+func NewCubeMapBackground(cubeMap *CubeMapTexture) *Background {
+	return &Background{Type: BackgroundCubeMap, CubeMap: cubeMap, Intensity: 1}
+}
+
+// NewGradientSceneBackground creates a background of a gradient fixed to
+// the frame
+func NewGradientSceneBackground(gradient *GradientBackground) *Background {
+	return &Background{Type: BackgroundGradient, Gradient: gradient, Intensity: 1}
+}
+
+// ColorInDirection returns the color of a panorama or cube map background
+// seen along a world direction, or the solid color of the others
+func (bg *Background) ColorInDirection(direction Vector) Color {
+	if bg.Rotation != 0 {
+		direction = Rotate(Vector{0, 1, 0}, -bg.Rotation).MulDirection(direction)
+	}
+	direction = direction.Normalize()
+	switch bg.Type {
+	case BackgroundEquirectangular:
+		if bg.Panorama == nil {
+			return bg.Color
+		}
+		u := 0.5 + math.Atan2(direction.X, -direction.Z)/(2*math.Pi)
+		v := math.Acos(Clamp(direction.Y, -1, 1)) / math.Pi
+		return bg.Panorama.sampleEquirectangular(u, v).MulScalar(bg.Intensity).Opaque()
+	case BackgroundCubeMap:
+		if bg.CubeMap == nil {
+			return bg.Color
+		}
+		return bg.CubeMap.SampleCubeMap(direction).MulScalar(bg.Intensity).Opaque()
+	}
+	return bg.Color
+}
+
+// Draw fills the color buffer of a context, and its HDR buffer if enabled,
+// with the background seen from a camera, like ClearColorBuffer
+func (bg *Background) Draw(dc *Context, camera *Camera) {
+	switch bg.Type {
+	case BackgroundColor:
+		dc.ClearColorBufferWith(bg.Color)
+		return
+	case BackgroundGradient:
+		if bg.Gradient != nil {
+			bg.Gradient.Draw(dc)
+		} else {
+			dc.ClearColorBufferWith(bg.Color)
+		}
+		return
+	}
+
+	// The view rays through the pixels, unprojected through the screen
+	// matrix of the context so tiles and jittered passes see their part of
+	// the frame. Screen depths 0 and 0.5 are the near plane and a point
+	// beyond it, 1 and 0.75 with reversed depth.
+	inverse := dc.screenMatrix.Mul(camera.GetCameraMatrix()).Inverse()
+	near, mid := 0.0, 0.5
+	if camera.DepthMode == DepthReversed {
+		near, mid = 1, 0.75
+	}
+	unproject := func(p Vector) Vector {
+		clip := inverse.MulPositionW(p)
+		return clip.DivScalar(clip.W).Vector()
+	}
+	parallelRows(dc.Height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			sy := float64(y) + 0.5
+			i := dc.ColorBuffer.PixOffset(0, y)
+			for x := 0; x < dc.Width; x++ {
+				sx := float64(x) + 0.5
+				origin := unproject(Vector{sx, sy, near})
+				c := bg.ColorInDirection(unproject(Vector{sx, sy, mid}).Sub(origin))
+				if dc.HDRBuffer != nil {
+					dc.HDRBuffer.SetColor(x, y, c)
+				}
+				n := c.NRGBA()
+				dc.ColorBuffer.Pix[i+0] = n.R
+				dc.ColorBuffer.Pix[i+1] = n.G
+				dc.ColorBuffer.Pix[i+2] = n.B
+				dc.ColorBuffer.Pix[i+3] = n.A
+				i += 4
+			}
+		}
+	})
+}
+
+// sampleEquirectangular bilinearly samples the image at texture
+// coordinates from the top left, wrapping across and clamping down
+func (im *HDRImage) sampleEquirectangular(u, v float64) Color {
+	w, h := im.Rect.Dx(), im.Rect.Dy()
+	if w == 0 || h == 0 {
+		return Transparent
+	}
+	x := u*float64(w) - 0.5
+	y := v*float64(h) - 0.5
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	at := func(x, y int) Color {
+		x = ((x % w) + w) % w
+		y = ClampInt(y, 0, h-1)
+		return im.ColorAt(x+im.Rect.Min.X, y+im.Rect.Min.Y)
+	}
+	top := at(x0, y0).Lerp(at(x0+1, y0), fx)
+	bottom := at(x0, y0+1).Lerp(at(x0+1, y0+1), fx)
+	return top.Lerp(bottom, fy)
+}
@@ -0,0 +1,209 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// BakeOptions configures texture space baking
+type BakeOptions struct {
+	Width, Height  int     // Output texture size
+	Samples        int     // Rays per texel for thickness
+	MaxDistance    float64 // Thickness that maps to white (0 uses the mesh bounding box diagonal)
+	CurvatureScale float64 // Multiplier applied to curvature before encoding
+	Padding        int     // Texels the UV islands are dilated by, see DilateImage
+}
+
+// NewBakeOptions returns the default baking options
+func NewBakeOptions() *BakeOptions {
+	return &BakeOptions{
+		Width:          512,
+		Height:         512,
+		Samples:        16,
+		CurvatureScale: 1,
+		Padding:        4,
+	}
+}
+
+// BakeCurvatureMap bakes the mean curvature of the mesh into its UV layout.
+// Flat areas encode as 0.5 gray, convex edges brighter and concave creases
+// darker, which makes the map usable as an edge-wear or cavity mask.
+func BakeCurvatureMap(mesh *Mesh, options *BakeOptions) *image.NRGBA {
+	if options == nil {
+		options = NewBakeOptions()
+	}
+	curvature := vertexCurvature(mesh)
+
+	// Normalize so the strongest feature of the mesh reaches full range
+	maxCurvature := 0.0
+	for _, k := range curvature {
+		maxCurvature = math.Max(maxCurvature, math.Abs(k))
+	}
+	if maxCurvature == 0 {
+		maxCurvature = 1
+	}
+	scale := options.CurvatureScale / maxCurvature
+
+	return bakeTexels(mesh, options.Width, options.Height, options.Padding, func(t *Triangle, b VectorW, rnd *rand.Rand) Color {
+		k := curvature[t.V1.Position]*b.X + curvature[t.V2.Position]*b.Y + curvature[t.V3.Position]*b.Z
+		g := Clamp(0.5+0.5*k*scale, 0, 1)
+		return Color{g, g, g, 1}
+	})
+}
+
+// BakeThicknessMap bakes the local thickness of the mesh into its UV layout
+// by casting rays into the surface against the interior. Values follow the
+// KHR_materials_volume convention: 0 is thin and 1 is MaxDistance or thicker.
+func BakeThicknessMap(mesh *Mesh, options *BakeOptions) *image.NRGBA {
+	if options == nil {
+		options = NewBakeOptions()
+	}
+	maxDistance := options.MaxDistance
+	if maxDistance <= 0 {
+		maxDistance = mesh.BoundingBox().Size().Length()
+	}
+	samples := options.Samples
+	if samples < 1 {
+		samples = 1
+	}
+
+	// Build the BVH of the mesh before the texels are baked in parallel
+	mesh.RayIntersect(Vector{}, Vector{})
+	return bakeTexels(mesh, options.Width, options.Height, options.Padding, func(t *Triangle, b VectorW, rnd *rand.Rand) Color {
+		position := InterpolateVectors(t.V1.Position, t.V2.Position, t.V3.Position, b)
+		normal := InterpolateVectors(t.V1.Normal, t.V2.Normal, t.V3.Normal, b).Normalize()
+		if normal.Length() == 0 {
+			normal = t.Normal()
+		}
+		inward := normal.Negate()
+		origin := position.Add(inward.MulScalar(maxDistance * 1e-4))
+
+		total := 0.0
+		for i := 0; i < samples; i++ {
+			direction := cosineSampleHemisphere(inward, rnd.Float64(), rnd.Float64())
+			distance := maxDistance
+			if hit, ok := mesh.RayIntersect(origin, direction); ok && hit.Distance > 1e-9 && hit.Distance < distance {
+				distance = hit.Distance
+			}
+			total += distance
+		}
+
+		g := Clamp(total/float64(samples)/maxDistance, 0, 1)
+		return Color{g, g, g, 1}
+	})
+}
+
+// vertexCurvature estimates mean curvature per vertex position from the
+// change of the normal along each connected edge
+func vertexCurvature(mesh *Mesh) map[Vector]float64 {
+	normals := make(map[Vector]Vector)
+	for _, t := range mesh.Triangles {
+		n := t.Normal().MulScalar(t.Area())
+		normals[t.V1.Position] = normals[t.V1.Position].Add(n)
+		normals[t.V2.Position] = normals[t.V2.Position].Add(n)
+		normals[t.V3.Position] = normals[t.V3.Position].Add(n)
+	}
+	for p, n := range normals {
+		normals[p] = n.Normalize()
+	}
+
+	sums := make(map[Vector]float64)
+	counts := make(map[Vector]int)
+	edge := func(a, b Vector) {
+		d := b.Sub(a)
+		lengthSq := d.LengthSquared()
+		if lengthSq == 0 {
+			return
+		}
+		k := normals[b].Sub(normals[a]).Dot(d) / lengthSq
+		sums[a] += k
+		sums[b] += k
+		counts[a]++
+		counts[b]++
+	}
+	for _, t := range mesh.Triangles {
+		edge(t.V1.Position, t.V2.Position)
+		edge(t.V2.Position, t.V3.Position)
+		edge(t.V3.Position, t.V1.Position)
+	}
+
+	curvature := make(map[Vector]float64, len(sums))
+	for p, sum := range sums {
+		curvature[p] = sum / float64(counts[p])
+	}
+	return curvature
+}
+
+// bakeTexels rasterizes the mesh in UV space and evaluates f for every
+// covered texel center. Texels f returns fully transparent are left as
+// they are, to other triangles. Rows are processed in parallel; each worker
+// gets its own random source so results are deterministic. Islands are
+// dilated by padding texels.
+func bakeTexels(mesh *Mesh, width, height, padding int, f func(t *Triangle, b VectorW, rnd *rand.Rand) Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	wn := runtime.NumCPU()
+	var wg sync.WaitGroup
+	for wi := 0; wi < wn; wi++ {
+		wg.Add(1)
+		go func(wi int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(wi) + 1))
+			for _, t := range mesh.Triangles {
+				// Texture coordinates in pixel space (V up, matching the sampler)
+				p1 := Vector{t.V1.Texture.X * float64(width), (1 - t.V1.Texture.Y) * float64(height), 0}
+				p2 := Vector{t.V2.Texture.X * float64(width), (1 - t.V2.Texture.Y) * float64(height), 0}
+				p3 := Vector{t.V3.Texture.X * float64(width), (1 - t.V3.Texture.Y) * float64(height), 0}
+				area := (p2.X-p1.X)*(p3.Y-p1.Y) - (p2.Y-p1.Y)*(p3.X-p1.X)
+				if area == 0 {
+					continue
+				}
+
+				min := p1.Min(p2.Min(p3))
+				max := p1.Max(p2.Max(p3))
+				x0 := ClampInt(int(math.Floor(min.X)), 0, width-1)
+				x1 := ClampInt(int(math.Ceil(max.X)), 0, width-1)
+				y0 := ClampInt(int(math.Floor(min.Y)), 0, height-1)
+				y1 := ClampInt(int(math.Ceil(max.Y)), 0, height-1)
+
+				for y := y0; y <= y1; y++ {
+					if y%wn != wi {
+						continue
+					}
+					for x := x0; x <= x1; x++ {
+						p := Vector{float64(x) + 0.5, float64(y) + 0.5, 0}
+						w1 := ((p2.X-p.X)*(p3.Y-p.Y) - (p2.Y-p.Y)*(p3.X-p.X)) / area
+						w2 := ((p3.X-p.X)*(p1.Y-p.Y) - (p3.Y-p.Y)*(p1.X-p.X)) / area
+						w3 := 1 - w1 - w2
+						if w1 < 0 || w2 < 0 || w3 < 0 {
+							continue
+						}
+						if c := f(t, VectorW{w1, w2, w3, 1}, rnd); c.A > 0 {
+							img.SetNRGBA(x, y, c.NRGBA())
+						}
+					}
+				}
+			}
+		}(wi)
+	}
+	wg.Wait()
+	if padding > 0 {
+		return DilateImage(img, padding)
+	}
+	return img
+}
+
+// cosineSampleHemisphere returns a cosine weighted direction around normal
+func cosineSampleHemisphere(normal Vector, u1, u2 float64) Vector {
+	r := math.Sqrt(u1)
+	phi := 2 * math.Pi * u2
+	x := r * math.Cos(phi)
+	y := r * math.Sin(phi)
+	z := math.Sqrt(math.Max(0, 1-u1))
+
+	tangent := normal.Perpendicular()
+	bitangent := normal.Cross(tangent)
+	return tangent.MulScalar(x).Add(bitangent.MulScalar(y)).Add(normal.MulScalar(z)).Normalize()
+}
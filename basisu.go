@@ -0,0 +1,668 @@
+package fauxgl
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Basis Universal ETC1S (BasisLZ) transcoding. ETC1S images are ETC1 blocks
+// whose two subblocks share one color and intensity table. The colors,
+// intensities and selectors live in global codebooks stored in the KTX2
+// supercompression global data, and every slice is a Huffman coded stream
+// of codebook indices with spatial prediction and a selector history.
+
+const (
+	basisHuffmanMaxCodeSize     = 16
+	basisHuffmanMaxSymsLog2     = 14
+	basisHuffmanCodelengthCodes = 21
+
+	basisSmallZeroRunCode = 17
+	basisBigZeroRunCode   = 18
+	basisSmallRepeatCode  = 19
+	basisBigRepeatCode    = 20
+
+	// Color prediction thresholds of the endpoint codebook
+	basisColor5Pal0PrevHi = 9
+	basisColor5Pal1PrevHi = 21
+
+	basisEndpointPredRepeatLastSymbol = 256
+	basisEndpointPredMinRepeatCount   = 3
+	basisEndpointPredCountVLCBits     = 4
+
+	basisSelectorHistoryRLECountThresh = 3
+	basisSelectorHistoryRLECountTotal  = 64
+	basisSelectorHistoryRLEVLCBits     = 7
+
+	basisImageIsPFrame = 2
+)
+
+// basisCodelengthOrder is the order code length code sizes are transmitted in
+var basisCodelengthOrder = [basisHuffmanCodelengthCodes]int{
+	basisSmallZeroRunCode, basisBigZeroRunCode, basisSmallRepeatCode, basisBigRepeatCode,
+	0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15, 16,
+}
+
+// etc1IntensityTables are the ETC1 intensity modifiers ordered from the
+// darkest to the brightest selector
+var etc1IntensityTables = [8][4]int{
+	{-8, -2, 2, 8}, {-17, -5, 5, 17}, {-29, -9, 9, 29}, {-42, -13, 13, 42},
+	{-60, -18, 18, 60}, {-80, -24, 24, 80}, {-106, -33, 33, 106}, {-183, -47, 47, 183},
+}
+
+var errBasisCorrupt = errors.New("corrupt Basis Universal data")
+
+// basisBitReader reads the LSB first bit streams of Basis Universal. Reading
+// past the end yields zero bits, like the reference transcoder.
+type basisBitReader struct {
+	data []byte
+	pos  int
+	buf  uint64
+	n    uint
+}
+
+func (r *basisBitReader) bits(n uint) uint32 {
+	if n == 0 {
+		return 0
+	}
+	for r.n < n {
+		var c byte
+		if r.pos < len(r.data) {
+			c = r.data[r.pos]
+		}
+		r.pos++
+		r.buf |= uint64(c) << r.n
+		r.n += 8
+	}
+	v := uint32(r.buf & (1<<n - 1))
+	r.buf >>= n
+	r.n -= n
+	return v
+}
+
+// overrun reports whether more bits were read than the stream holds
+func (r *basisBitReader) overrun() bool {
+	return r.pos > len(r.data)+8
+}
+
+// vlc reads a variable length integer made of chunks of chunkBits bits, each
+// followed by a continuation bit
+func (r *basisBitReader) vlc(chunkBits uint) (uint32, error) {
+	var v uint32
+	var shift uint
+	for {
+		s := r.bits(chunkBits + 1)
+		v |= (s & (1<<chunkBits - 1)) << shift
+		shift += chunkBits
+		if s&(1<<chunkBits) == 0 {
+			return v, nil
+		}
+		if shift >= 32 {
+			return 0, errBasisCorrupt
+		}
+	}
+}
+
+// basisHuffman is a canonical Huffman decoding table. Codes are stored bit
+// reversed, so reading one bit at a time from the LSB first stream yields
+// the canonical code MSB first.
+type basisHuffman struct {
+	counts  [basisHuffmanMaxCodeSize + 1]int
+	symbols []int
+}
+
+func newBasisHuffman(sizes []uint8) (*basisHuffman, error) {
+	h := &basisHuffman{}
+	for _, size := range sizes {
+		if size > basisHuffmanMaxCodeSize {
+			return nil, errBasisCorrupt
+		}
+		h.counts[size]++
+	}
+	h.counts[0] = 0
+
+	// Reject over-subscribed codes
+	left := 1
+	for length := 1; length <= basisHuffmanMaxCodeSize; length++ {
+		left = left<<1 - h.counts[length]
+		if left < 0 {
+			return nil, errBasisCorrupt
+		}
+	}
+
+	var offsets [basisHuffmanMaxCodeSize + 2]int
+	for length := 1; length <= basisHuffmanMaxCodeSize; length++ {
+		offsets[length+1] = offsets[length] + h.counts[length]
+	}
+	h.symbols = make([]int, offsets[basisHuffmanMaxCodeSize+1])
+	for symbol, size := range sizes {
+		if size != 0 {
+			h.symbols[offsets[size]] = symbol
+			offsets[size]++
+		}
+	}
+	if len(h.symbols) == 0 {
+		return nil, errBasisCorrupt
+	}
+	return h, nil
+}
+
+func (h *basisHuffman) decode(r *basisBitReader) (int, error) {
+	code, first, index := 0, 0, 0
+	for length := 1; length <= basisHuffmanMaxCodeSize; length++ {
+		code |= int(r.bits(1))
+		count := h.counts[length]
+		if code-first < count {
+			return h.symbols[index+code-first], nil
+		}
+		index += count
+		first = (first + count) << 1
+		code <<= 1
+	}
+	return 0, errBasisCorrupt
+}
+
+// readBasisHuffman reads a Huffman table whose code sizes are themselves
+// Huffman coded with run length codes, similar to Deflate
+func readBasisHuffman(r *basisBitReader) (*basisHuffman, error) {
+	total := int(r.bits(basisHuffmanMaxSymsLog2))
+	if total == 0 {
+		// An unused table; decoding from it fails
+		return &basisHuffman{}, nil
+	}
+
+	count := int(r.bits(5))
+	if count < 1 || count > basisHuffmanCodelengthCodes {
+		return nil, errBasisCorrupt
+	}
+	var codelengthSizes [basisHuffmanCodelengthCodes]uint8
+	for i := 0; i < count; i++ {
+		codelengthSizes[basisCodelengthOrder[i]] = uint8(r.bits(3))
+	}
+	codelengths, err := newBasisHuffman(codelengthSizes[:])
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make([]uint8, total)
+	for i := 0; i < total; {
+		c, err := codelengths.decode(r)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case c <= basisHuffmanMaxCodeSize:
+			sizes[i] = uint8(c)
+			i++
+		case c == basisSmallZeroRunCode || c == basisBigZeroRunCode:
+			var run int
+			if c == basisBigZeroRunCode {
+				run = int(r.bits(7)) + 11
+			} else {
+				run = int(r.bits(3)) + 3
+			}
+			if i+run > total {
+				return nil, errBasisCorrupt
+			}
+			i += run
+		default:
+			var run int
+			if c == basisBigRepeatCode {
+				run = int(r.bits(7)) + 7
+			} else {
+				run = int(r.bits(2)) + 3
+			}
+			if i == 0 || sizes[i-1] == 0 || i+run > total {
+				return nil, errBasisCorrupt
+			}
+			for ; run > 0; run-- {
+				sizes[i] = sizes[i-1]
+				i++
+			}
+		}
+	}
+	return newBasisHuffman(sizes)
+}
+
+// etc1sEndpoint is a codebook color with 5 bits per channel and an ETC1
+// intensity table index
+type etc1sEndpoint struct {
+	color [3]uint8
+	inten uint8
+}
+
+// etc1sSelector holds the 2 bit selectors of a block, one byte per row with
+// the leftmost pixel in the low bits
+type etc1sSelector [4]uint8
+
+// basisImageDesc locates the slices of one image in its mip level data
+type basisImageDesc struct {
+	flags       uint32
+	rgbOffset   uint32
+	rgbLength   uint32
+	alphaOffset uint32
+	alphaLength uint32
+}
+
+// etc1sTranscoder decodes ETC1S slices using the global codebooks of a file
+type etc1sTranscoder struct {
+	endpoints []etc1sEndpoint
+	selectors []etc1sSelector
+	images    []basisImageDesc
+
+	endpointPredModel       *basisHuffman
+	deltaEndpointModel      *basisHuffman
+	selectorModel           *basisHuffman
+	selectorHistoryRLEModel *basisHuffman
+	selectorHistorySize     int
+}
+
+// newETC1STranscoder parses BasisLZ supercompression global data describing
+// imageCount images
+func newETC1STranscoder(sgd []byte, imageCount int) (*etc1sTranscoder, error) {
+	const headerLength = 20
+	const imageDescLength = 20
+	if len(sgd) < headerLength+imageCount*imageDescLength {
+		return nil, UnexpectedEnd
+	}
+
+	endpointCount := int(binary.LittleEndian.Uint16(sgd[0:2]))
+	selectorCount := int(binary.LittleEndian.Uint16(sgd[2:4]))
+	endpointsLength := uint64(binary.LittleEndian.Uint32(sgd[4:8]))
+	selectorsLength := uint64(binary.LittleEndian.Uint32(sgd[8:12]))
+	tablesLength := uint64(binary.LittleEndian.Uint32(sgd[12:16]))
+
+	t := &etc1sTranscoder{images: make([]basisImageDesc, imageCount)}
+	for i := range t.images {
+		d := sgd[headerLength+i*imageDescLength:]
+		t.images[i] = basisImageDesc{
+			flags:       binary.LittleEndian.Uint32(d[0:4]),
+			rgbOffset:   binary.LittleEndian.Uint32(d[4:8]),
+			rgbLength:   binary.LittleEndian.Uint32(d[8:12]),
+			alphaOffset: binary.LittleEndian.Uint32(d[12:16]),
+			alphaLength: binary.LittleEndian.Uint32(d[16:20]),
+		}
+	}
+
+	offset := uint64(headerLength + imageCount*imageDescLength)
+	if offset+endpointsLength+selectorsLength+tablesLength > uint64(len(sgd)) {
+		return nil, UnexpectedEnd
+	}
+	endpointsData := sgd[offset : offset+endpointsLength]
+	offset += endpointsLength
+	selectorsData := sgd[offset : offset+selectorsLength]
+	offset += selectorsLength
+	tablesData := sgd[offset : offset+tablesLength]
+
+	if endpointCount == 0 || selectorCount == 0 {
+		return nil, errBasisCorrupt
+	}
+	if err := t.decodeEndpoints(endpointsData, endpointCount); err != nil {
+		return nil, fmt.Errorf("failed to decode endpoint codebook: %w", err)
+	}
+	if err := t.decodeSelectors(selectorsData, selectorCount); err != nil {
+		return nil, fmt.Errorf("failed to decode selector codebook: %w", err)
+	}
+	if err := t.decodeTables(tablesData); err != nil {
+		return nil, fmt.Errorf("failed to decode slice tables: %w", err)
+	}
+	return t, nil
+}
+
+// decodeEndpoints decodes the delta coded endpoint codebook
+func (t *etc1sTranscoder) decodeEndpoints(data []byte, count int) error {
+	r := &basisBitReader{data: data}
+	var colorModels [3]*basisHuffman
+	for i := range colorModels {
+		model, err := readBasisHuffman(r)
+		if err != nil {
+			return err
+		}
+		colorModels[i] = model
+	}
+	intenModel, err := readBasisHuffman(r)
+	if err != nil {
+		return err
+	}
+	grayscale := r.bits(1) != 0
+
+	t.endpoints = make([]etc1sEndpoint, count)
+	previous := [3]int{16, 16, 16}
+	previousInten := 0
+	for i := range t.endpoints {
+		delta, err := intenModel.decode(r)
+		if err != nil {
+			return err
+		}
+		previousInten = (previousInten + delta) & 7
+		t.endpoints[i].inten = uint8(previousInten)
+
+		channels := 3
+		if grayscale {
+			channels = 1
+		}
+		for c := 0; c < channels; c++ {
+			model := colorModels[2]
+			if previous[c] <= basisColor5Pal0PrevHi {
+				model = colorModels[0]
+			} else if previous[c] <= basisColor5Pal1PrevHi {
+				model = colorModels[1]
+			}
+			delta, err := model.decode(r)
+			if err != nil {
+				return err
+			}
+			previous[c] = (previous[c] + delta) & 31
+			t.endpoints[i].color[c] = uint8(previous[c])
+		}
+		if grayscale {
+			t.endpoints[i].color[1] = t.endpoints[i].color[0]
+			t.endpoints[i].color[2] = t.endpoints[i].color[0]
+		}
+	}
+	if r.overrun() {
+		return UnexpectedEnd
+	}
+	return nil
+}
+
+// decodeSelectors decodes the raw or delta coded selector codebook
+func (t *etc1sTranscoder) decodeSelectors(data []byte, count int) error {
+	r := &basisBitReader{data: data}
+	if r.bits(1) != 0 {
+		return errors.New("global selector codebooks are not supported")
+	}
+	if r.bits(1) != 0 {
+		return errors.New("hybrid selector codebooks are not supported")
+	}
+
+	t.selectors = make([]etc1sSelector, count)
+	if r.bits(1) != 0 {
+		// Raw selectors
+		for i := range t.selectors {
+			for row := 0; row < 4; row++ {
+				t.selectors[i][row] = uint8(r.bits(8))
+			}
+		}
+	} else {
+		// Each row is XOR coded against the row of the previous selector
+		model, err := readBasisHuffman(r)
+		if err != nil {
+			return err
+		}
+		for row := 0; row < 4; row++ {
+			t.selectors[0][row] = uint8(r.bits(8))
+		}
+		for i := 1; i < count; i++ {
+			for row := 0; row < 4; row++ {
+				delta, err := model.decode(r)
+				if err != nil {
+					return err
+				}
+				t.selectors[i][row] = uint8(delta) ^ t.selectors[i-1][row]
+			}
+		}
+	}
+	if r.overrun() {
+		return UnexpectedEnd
+	}
+	return nil
+}
+
+// decodeTables reads the Huffman tables shared by all slices
+func (t *etc1sTranscoder) decodeTables(data []byte) error {
+	r := &basisBitReader{data: data}
+	var err error
+	if t.endpointPredModel, err = readBasisHuffman(r); err != nil {
+		return err
+	}
+	if t.deltaEndpointModel, err = readBasisHuffman(r); err != nil {
+		return err
+	}
+	if t.selectorModel, err = readBasisHuffman(r); err != nil {
+		return err
+	}
+	if t.selectorHistoryRLEModel, err = readBasisHuffman(r); err != nil {
+		return err
+	}
+	t.selectorHistorySize = int(r.bits(13))
+	return nil
+}
+
+// decodeImage decodes the color slice and the optional alpha slice of an
+// image. Alpha is taken from the green channel of the alpha slice.
+func (t *etc1sTranscoder) decodeImage(width, height int, desc basisImageDesc, level []byte) (*image.NRGBA, error) {
+	if desc.flags&basisImageIsPFrame != 0 {
+		return nil, errors.New("ETC1S video frames are not supported")
+	}
+	slice := func(offset, length uint32) ([]byte, error) {
+		end := uint64(offset) + uint64(length)
+		if end > uint64(len(level)) {
+			return nil, UnexpectedEnd
+		}
+		return level[offset:end], nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	rgb, err := slice(desc.rgbOffset, desc.rgbLength)
+	if err != nil {
+		return nil, err
+	}
+	err = t.decodeSlice(width, height, rgb, func(x, y int, c [3]uint8) {
+		i := img.PixOffset(x, y)
+		img.Pix[i+0], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c[0], c[1], c[2], 255
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if desc.alphaLength > 0 {
+		alpha, err := slice(desc.alphaOffset, desc.alphaLength)
+		if err != nil {
+			return nil, err
+		}
+		err = t.decodeSlice(width, height, alpha, func(x, y int, c [3]uint8) {
+			img.Pix[img.PixOffset(x, y)+3] = c[1]
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// approxMoveToFront is the selector history buffer: new entries replace a
+// rover in the back half, used entries are swapped halfway to the front
+type approxMoveToFront struct {
+	values []int
+	rover  int
+}
+
+func (m *approxMoveToFront) add(value int) {
+	m.values[m.rover] = value
+	m.rover++
+	if m.rover == len(m.values) {
+		m.rover = len(m.values) / 2
+	}
+}
+
+func (m *approxMoveToFront) use(index int) {
+	if index > 0 {
+		m.values[index/2], m.values[index] = m.values[index], m.values[index/2]
+	}
+}
+
+// decodeSlice decodes the blocks of a slice and calls set for every pixel
+// inside the image
+func (t *etc1sTranscoder) decodeSlice(width, height int, data []byte, set func(x, y int, c [3]uint8)) error {
+	blocksX := (width + 3) / 4
+	blocksY := (height + 3) / 4
+	r := &basisBitReader{data: data}
+
+	history := &approxMoveToFront{values: make([]int, t.selectorHistorySize), rover: t.selectorHistorySize / 2}
+	historyFirstSymbol := len(t.selectors)
+	historyRLESymbol := historyFirstSymbol + t.selectorHistorySize
+	selectorRLECount := 0
+
+	// Per column endpoint indices and prediction bits of the previous and
+	// current block rows
+	type blockPred struct {
+		endpoint int
+		predBits int
+	}
+	var preds [2][]blockPred
+	preds[0] = make([]blockPred, blocksX)
+	preds[1] = make([]blockPred, blocksX)
+
+	predBits := 0
+	previousPredSymbol := 0
+	predRepeatCount := 0
+	previousEndpoint := 0
+
+	for by := 0; by < blocksY; by++ {
+		row := by & 1
+		for bx := 0; bx < blocksX; bx++ {
+			// A prediction symbol covers a 2x2 group of blocks
+			if bx&1 == 0 {
+				if by&1 == 0 {
+					if predRepeatCount > 0 {
+						predRepeatCount--
+						predBits = previousPredSymbol
+					} else {
+						symbol, err := t.endpointPredModel.decode(r)
+						if err != nil {
+							return err
+						}
+						if symbol == basisEndpointPredRepeatLastSymbol {
+							count, err := r.vlc(basisEndpointPredCountVLCBits)
+							if err != nil {
+								return err
+							}
+							predRepeatCount = int(count) + basisEndpointPredMinRepeatCount - 1
+							predBits = previousPredSymbol
+						} else {
+							predBits = symbol
+							previousPredSymbol = symbol
+						}
+					}
+					preds[row^1][bx].predBits = predBits >> 4
+				} else {
+					predBits = preds[row][bx].predBits
+				}
+			}
+
+			// Endpoint index
+			var endpoint int
+			pred := predBits & 3
+			predBits >>= 2
+			switch pred {
+			case 0: // Left
+				if bx == 0 {
+					return errBasisCorrupt
+				}
+				endpoint = previousEndpoint
+			case 1: // Upper
+				if by == 0 {
+					return errBasisCorrupt
+				}
+				endpoint = preds[row^1][bx].endpoint
+			case 2: // Upper left
+				if bx == 0 || by == 0 {
+					return errBasisCorrupt
+				}
+				endpoint = preds[row^1][bx-1].endpoint
+			default:
+				delta, err := t.deltaEndpointModel.decode(r)
+				if err != nil {
+					return err
+				}
+				endpoint = delta + previousEndpoint
+				if endpoint >= len(t.endpoints) {
+					endpoint -= len(t.endpoints)
+				}
+				if endpoint >= len(t.endpoints) {
+					return errBasisCorrupt
+				}
+			}
+			preds[row][bx].endpoint = endpoint
+			previousEndpoint = endpoint
+
+			// Selector index, possibly from the history buffer
+			var symbol int
+			if selectorRLECount > 0 {
+				selectorRLECount--
+				symbol = historyFirstSymbol
+			} else {
+				var err error
+				symbol, err = t.selectorModel.decode(r)
+				if err != nil {
+					return err
+				}
+				if symbol == historyRLESymbol {
+					run, err := t.selectorHistoryRLEModel.decode(r)
+					if err != nil {
+						return err
+					}
+					if run == basisSelectorHistoryRLECountTotal-1 {
+						count, err := r.vlc(basisSelectorHistoryRLEVLCBits)
+						if err != nil {
+							return err
+						}
+						selectorRLECount = int(count) + basisSelectorHistoryRLECountThresh
+					} else {
+						selectorRLECount = run + basisSelectorHistoryRLECountThresh
+					}
+					if selectorRLECount > blocksX*blocksY {
+						return errBasisCorrupt
+					}
+					symbol = historyFirstSymbol
+					selectorRLECount--
+				}
+			}
+			var selector int
+			if symbol >= historyFirstSymbol {
+				index := symbol - historyFirstSymbol
+				if index >= len(history.values) {
+					return errBasisCorrupt
+				}
+				selector = history.values[index]
+				history.use(index)
+			} else {
+				selector = symbol
+				if len(history.values) > 0 {
+					history.add(selector)
+				}
+			}
+			if selector >= len(t.selectors) {
+				return errBasisCorrupt
+			}
+
+			t.decodeBlock(bx*4, by*4, width, height, t.endpoints[endpoint], t.selectors[selector], set)
+		}
+		if r.overrun() {
+			return UnexpectedEnd
+		}
+	}
+	return nil
+}
+
+// decodeBlock expands one ETC1S block
+func (t *etc1sTranscoder) decodeBlock(x0, y0, width, height int, e etc1sEndpoint, s etc1sSelector, set func(x, y int, c [3]uint8)) {
+	var base [3]int
+	for c := range base {
+		base[c] = int(e.color[c])<<3 | int(e.color[c])>>2
+	}
+	var palette [4][3]uint8
+	for i, modifier := range etc1IntensityTables[e.inten] {
+		for c := range base {
+			palette[i][c] = uint8(ClampInt(base[c]+modifier, 0, 255))
+		}
+	}
+	for y := 0; y < 4 && y0+y < height; y++ {
+		bits := s[y]
+		for x := 0; x < 4 && x0+x < width; x++ {
+			set(x0+x, y0+y, palette[bits>>(2*x)&3])
+		}
+	}
+}
@@ -0,0 +1,733 @@
+package fauxgl
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"image"
+	"sort"
+)
+
+// Basis Universal ETC1S (BasisLZ) encoding, the counterpart of the
+// transcoder in basisu.go. Every block is fitted with one ETC1S color and
+// intensity table; identical endpoints and selectors are merged into the
+// global codebooks, which are coarsened when they outgrow the Huffman
+// symbol limit. Slices use spatial endpoint prediction and a selector
+// history, and every stream is canonical Huffman coded.
+
+const (
+	// Codebook limits that keep every Huffman table below 2^14 symbols
+	etc1sMaxEndpoints = 1 << 13
+	etc1sMaxSelectors = 1 << 13
+
+	etc1sSelectorHistorySize = 64
+)
+
+// basisBitWriter writes the LSB first bit streams read by basisBitReader
+type basisBitWriter struct {
+	data []byte
+	buf  uint64
+	n    uint
+}
+
+func (w *basisBitWriter) bits(v uint32, n uint) {
+	w.buf |= uint64(v&(1<<n-1)) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.data = append(w.data, byte(w.buf))
+		w.buf >>= 8
+		w.n -= 8
+	}
+}
+
+// bytes flushes the final partial byte and returns the stream
+func (w *basisBitWriter) bytes() []byte {
+	if w.n > 0 {
+		w.data = append(w.data, byte(w.buf))
+		w.buf, w.n = 0, 0
+	}
+	return w.data
+}
+
+// basisHuffmanEncoder holds canonical codes matching newBasisHuffman
+type basisHuffmanEncoder struct {
+	sizes []uint8
+	codes []uint32
+}
+
+// newBasisHuffmanEncoder builds a length limited canonical code for the
+// symbol frequencies. At least two symbols always get codes so that every
+// table is a valid prefix code.
+func newBasisHuffmanEncoder(freq []int, maxSize int) *basisHuffmanEncoder {
+	freq = append([]int(nil), freq...)
+	used := 0
+	for _, f := range freq {
+		if f > 0 {
+			used++
+		}
+	}
+	for i := 0; used < 2 && i < len(freq); i++ {
+		if freq[i] == 0 {
+			freq[i] = 1
+			used++
+		}
+	}
+
+	sizes := huffmanCodeSizes(freq)
+	for maxCodeSize(sizes) > maxSize {
+		// Flatten the distribution until the code fits
+		for i, f := range freq {
+			if f > 0 {
+				freq[i] = (f + 1) / 2
+			}
+		}
+		sizes = huffmanCodeSizes(freq)
+	}
+
+	// Canonical codes, assigned in symbol order per length
+	var counts [basisHuffmanMaxCodeSize + 1]int
+	for _, s := range sizes {
+		counts[s]++
+	}
+	counts[0] = 0
+	var next [basisHuffmanMaxCodeSize + 2]uint32
+	code := uint32(0)
+	for length := 1; length <= basisHuffmanMaxCodeSize; length++ {
+		code = (code + uint32(counts[length-1])) << 1
+		next[length] = code
+	}
+	codes := make([]uint32, len(sizes))
+	for symbol, s := range sizes {
+		if s > 0 {
+			codes[symbol] = next[s]
+			next[s]++
+		}
+	}
+	return &basisHuffmanEncoder{sizes: sizes, codes: codes}
+}
+
+// encode writes a symbol. Codes are written MSB first, one bit at a time,
+// as the decoder reads them.
+func (h *basisHuffmanEncoder) encode(w *basisBitWriter, symbol int) {
+	size := uint(h.sizes[symbol])
+	code := h.codes[symbol]
+	for i := int(size) - 1; i >= 0; i-- {
+		w.bits(code>>uint(i)&1, 1)
+	}
+}
+
+// huffmanCodeSizes returns the Huffman code length of every symbol
+func huffmanCodeSizes(freq []int) []uint8 {
+	h := &huffmanHeap{}
+	for symbol, f := range freq {
+		if f > 0 {
+			h.nodes = append(h.nodes, huffmanNode{weight: f, symbol: symbol, left: -1, right: -1})
+			h.live = append(h.live, len(h.nodes)-1)
+		}
+	}
+	sizes := make([]uint8, len(freq))
+	if len(h.live) == 1 {
+		sizes[h.nodes[0].symbol] = 1
+		return sizes
+	}
+
+	heap.Init(h)
+	for h.Len() > 1 {
+		a := heap.Pop(h).(int)
+		b := heap.Pop(h).(int)
+		h.nodes = append(h.nodes, huffmanNode{weight: h.nodes[a].weight + h.nodes[b].weight, symbol: -1, left: a, right: b})
+		heap.Push(h, len(h.nodes)-1)
+	}
+
+	var walk func(index int, depth uint8)
+	walk = func(index int, depth uint8) {
+		n := h.nodes[index]
+		if n.symbol >= 0 {
+			sizes[n.symbol] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(h.live[0], 0)
+	return sizes
+}
+
+// huffmanNode is a leaf or merged node of a Huffman tree
+type huffmanNode struct {
+	weight      int
+	symbol      int // -1 for merged nodes
+	left, right int
+}
+
+// huffmanHeap is a min heap of live node indices ordered by weight
+type huffmanHeap struct {
+	nodes []huffmanNode
+	live  []int
+}
+
+func (h *huffmanHeap) Len() int { return len(h.live) }
+func (h *huffmanHeap) Less(i, j int) bool {
+	return h.nodes[h.live[i]].weight < h.nodes[h.live[j]].weight
+}
+func (h *huffmanHeap) Swap(i, j int)      { h.live[i], h.live[j] = h.live[j], h.live[i] }
+func (h *huffmanHeap) Push(x interface{}) { h.live = append(h.live, x.(int)) }
+func (h *huffmanHeap) Pop() interface{} {
+	x := h.live[len(h.live)-1]
+	h.live = h.live[:len(h.live)-1]
+	return x
+}
+
+func maxCodeSize(sizes []uint8) int {
+	m := 0
+	for _, s := range sizes {
+		if int(s) > m {
+			m = int(s)
+		}
+	}
+	return m
+}
+
+// writeTable writes the code sizes in the format read by readBasisHuffman:
+// the sizes are run length coded with the code length alphabet, which is
+// itself Huffman coded with 3 bit sizes
+func (h *basisHuffmanEncoder) writeTable(w *basisBitWriter) {
+	type lengthCode struct {
+		symbol int
+		extra  uint32
+		bits   uint
+	}
+	var lengthCodes []lengthCode
+	sizes := h.sizes
+	for i := 0; i < len(sizes); {
+		s := sizes[i]
+		run := 1
+		for i+run < len(sizes) && sizes[i+run] == s {
+			run++
+		}
+		switch {
+		case s == 0 && run >= 11:
+			n := minInt(run, 138)
+			lengthCodes = append(lengthCodes, lengthCode{basisBigZeroRunCode, uint32(n - 11), 7})
+			i += n
+		case s == 0 && run >= 3:
+			lengthCodes = append(lengthCodes, lengthCode{basisSmallZeroRunCode, uint32(run - 3), 3})
+			i += run
+		case s != 0 && run >= 4:
+			// Emit the size once, then repeat it
+			lengthCodes = append(lengthCodes, lengthCode{int(s), 0, 0})
+			i++
+			run--
+			for run >= 3 {
+				if run >= 7 {
+					n := minInt(run, 134)
+					lengthCodes = append(lengthCodes, lengthCode{basisBigRepeatCode, uint32(n - 7), 7})
+					i += n
+					run -= n
+				} else {
+					lengthCodes = append(lengthCodes, lengthCode{basisSmallRepeatCode, uint32(run - 3), 2})
+					i += run
+					run = 0
+				}
+			}
+		default:
+			lengthCodes = append(lengthCodes, lengthCode{int(s), 0, 0})
+			i++
+		}
+	}
+
+	freq := make([]int, basisHuffmanCodelengthCodes)
+	for _, c := range lengthCodes {
+		freq[c.symbol]++
+	}
+	codelengths := newBasisHuffmanEncoder(freq, 7)
+
+	count := basisHuffmanCodelengthCodes
+	for count > 1 && codelengths.sizes[basisCodelengthOrder[count-1]] == 0 {
+		count--
+	}
+	w.bits(uint32(len(sizes)), basisHuffmanMaxSymsLog2)
+	w.bits(uint32(count), 5)
+	for i := 0; i < count; i++ {
+		w.bits(uint32(codelengths.sizes[basisCodelengthOrder[i]]), 3)
+	}
+	for _, c := range lengthCodes {
+		codelengths.encode(w, c.symbol)
+		w.bits(c.extra, c.bits)
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// etc1sBlock is a fitted 4x4 block: its pixels and the chosen codebook
+// entries
+type etc1sBlock struct {
+	pixels   [16][3]int
+	endpoint int
+	selector int
+}
+
+// etc1sSlice is the blocks of one slice of an image
+type etc1sSlice struct {
+	width, height int
+	blocks        []etc1sBlock
+}
+
+// newETC1SSlice splits an image into blocks. With alpha set, the slice
+// encodes the alpha channel replicated to all three channels, as the
+// transcoder reads it from green.
+func newETC1SSlice(img *image.NRGBA, alpha bool) *etc1sSlice {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	blocksX, blocksY := (width+3)/4, (height+3)/4
+	s := &etc1sSlice{width: width, height: height, blocks: make([]etc1sBlock, blocksX*blocksY)}
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			block := &s.blocks[by*blocksX+bx]
+			for y := 0; y < 4; y++ {
+				for x := 0; x < 4; x++ {
+					// Replicate edges into partial blocks
+					px := minInt(bx*4+x, width-1)
+					py := minInt(by*4+y, height-1)
+					p := img.Pix[img.PixOffset(b.Min.X+px, b.Min.Y+py):]
+					if alpha {
+						block.pixels[y*4+x] = [3]int{int(p[3]), int(p[3]), int(p[3])}
+					} else {
+						block.pixels[y*4+x] = [3]int{int(p[0]), int(p[1]), int(p[2])}
+					}
+				}
+			}
+		}
+	}
+	return s
+}
+
+// etc1sPalette expands an endpoint into its four selector colors
+func etc1sPalette(e etc1sEndpoint) [4][3]int {
+	var palette [4][3]int
+	for i, modifier := range etc1IntensityTables[e.inten] {
+		for c := 0; c < 3; c++ {
+			base := int(e.color[c])<<3 | int(e.color[c])>>2
+			palette[i][c] = ClampInt(base+modifier, 0, 255)
+		}
+	}
+	return palette
+}
+
+// etc1sSelectors picks the closest palette entry per pixel and returns the
+// selectors and the squared error
+func etc1sSelectors(pixels *[16][3]int, e etc1sEndpoint) (etc1sSelector, int) {
+	palette := etc1sPalette(e)
+	var s etc1sSelector
+	total := 0
+	for i, p := range pixels {
+		best, bestErr := 0, 1<<30
+		for k, c := range palette {
+			dr, dg, db := p[0]-c[0], p[1]-c[1], p[2]-c[2]
+			if err := dr*dr + dg*dg + db*db; err < bestErr {
+				best, bestErr = k, err
+			}
+		}
+		s[i/4] |= uint8(best) << (2 * uint(i%4))
+		total += bestErr
+	}
+	return s, total
+}
+
+// fitETC1SEndpoint finds the endpoint with the lowest error for a block,
+// trying the quantized block average shifted along the gray axis with
+// every intensity table
+func fitETC1SEndpoint(pixels *[16][3]int) etc1sEndpoint {
+	var sum [3]int
+	for _, p := range pixels {
+		for c := 0; c < 3; c++ {
+			sum[c] += p[c]
+		}
+	}
+	best := etc1sEndpoint{}
+	bestErr := 1 << 30
+	for inten := 0; inten < 8; inten++ {
+		for shift := -1; shift <= 1; shift++ {
+			var e etc1sEndpoint
+			e.inten = uint8(inten)
+			for c := 0; c < 3; c++ {
+				q := (sum[c]*31+16*255/2)/(16*255) + shift
+				e.color[c] = uint8(ClampInt(q, 0, 31))
+			}
+			if _, err := etc1sSelectors(pixels, e); err < bestErr {
+				best, bestErr = e, err
+			}
+		}
+	}
+	return best
+}
+
+// etc1sEncoder accumulates the slices of a file and its codebooks
+type etc1sEncoder struct {
+	slices    []*etc1sSlice
+	endpoints []etc1sEndpoint
+	selectors []etc1sSelector
+}
+
+// buildCodebooks fits every block and merges the endpoint and selector
+// codebooks of all slices
+func (enc *etc1sEncoder) buildCodebooks() {
+	// Endpoints: merge identical ones, coarsening the colors while there
+	// are too many
+	fitted := make([][]etc1sEndpoint, len(enc.slices))
+	for i, s := range enc.slices {
+		fitted[i] = make([]etc1sEndpoint, len(s.blocks))
+		for j := range s.blocks {
+			fitted[i][j] = fitETC1SEndpoint(&s.blocks[j].pixels)
+		}
+	}
+	for dropBits := uint(0); ; dropBits++ {
+		index := make(map[etc1sEndpoint]int)
+		enc.endpoints = enc.endpoints[:0]
+		for i, s := range enc.slices {
+			for j := range s.blocks {
+				e := fitted[i][j]
+				if dropBits > 0 {
+					for c := 0; c < 3; c++ {
+						// Round to the center of the coarser step
+						q := int(e.color[c])>>dropBits<<dropBits | (1<<dropBits)>>1
+						e.color[c] = uint8(ClampInt(q, 0, 31))
+					}
+				}
+				k, ok := index[e]
+				if !ok {
+					k = len(enc.endpoints)
+					index[e] = k
+					enc.endpoints = append(enc.endpoints, e)
+				}
+				s.blocks[j].endpoint = k
+			}
+		}
+		if len(enc.endpoints) <= etc1sMaxEndpoints {
+			break
+		}
+	}
+
+	// Selectors for the final endpoints
+	counts := make(map[etc1sSelector]int)
+	blockSelectors := make([][]etc1sSelector, len(enc.slices))
+	for i, s := range enc.slices {
+		blockSelectors[i] = make([]etc1sSelector, len(s.blocks))
+		for j := range s.blocks {
+			sel, _ := etc1sSelectors(&s.blocks[j].pixels, enc.endpoints[s.blocks[j].endpoint])
+			blockSelectors[i][j] = sel
+			counts[sel]++
+		}
+	}
+
+	// Keep the most frequent selectors when there are too many and map the
+	// rest to the kept selector with the lowest error
+	enc.selectors = enc.selectors[:0]
+	for sel := range counts {
+		enc.selectors = append(enc.selectors, sel)
+	}
+	sort.Slice(enc.selectors, func(i, j int) bool {
+		a, b := enc.selectors[i], enc.selectors[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		return binary.LittleEndian.Uint32(a[:]) < binary.LittleEndian.Uint32(b[:])
+	})
+	if len(enc.selectors) > etc1sMaxSelectors {
+		enc.selectors = enc.selectors[:etc1sMaxSelectors]
+	}
+	index := make(map[etc1sSelector]int, len(enc.selectors))
+	buckets := make(map[int][]int)
+	for k, sel := range enc.selectors {
+		index[sel] = k
+		key := selectorBucket(sel)
+		buckets[key] = append(buckets[key], k)
+	}
+	for i, s := range enc.slices {
+		for j := range s.blocks {
+			sel := blockSelectors[i][j]
+			k, ok := index[sel]
+			if !ok {
+				candidates := buckets[selectorBucket(sel)]
+				if len(candidates) == 0 {
+					candidates = make([]int, minInt(64, len(enc.selectors)))
+					for c := range candidates {
+						candidates[c] = c
+					}
+				}
+				k = enc.closestSelector(&s.blocks[j], candidates)
+			}
+			s.blocks[j].selector = k
+		}
+	}
+}
+
+// selectorBucket groups selectors by the rounded mean selector of their
+// four quadrants, so that near matches are searched among similar ones
+func selectorBucket(s etc1sSelector) int {
+	key := 0
+	for q := 0; q < 4; q++ {
+		sum := 0
+		for y := 0; y < 2; y++ {
+			row := s[(q/2)*2+y]
+			for x := 0; x < 2; x++ {
+				sum += int(row >> (2 * uint((q%2)*2+x)) & 3)
+			}
+		}
+		key = key*5 + (sum+1)/3
+	}
+	return key
+}
+
+// closestSelector returns the candidate selector with the lowest error for
+// a block with its endpoint
+func (enc *etc1sEncoder) closestSelector(block *etc1sBlock, candidates []int) int {
+	palette := etc1sPalette(enc.endpoints[block.endpoint])
+	best, bestErr := candidates[0], 1<<30
+	for _, k := range candidates {
+		sel := enc.selectors[k]
+		total := 0
+		for i, p := range block.pixels {
+			c := palette[sel[i/4]>>(2*uint(i%4))&3]
+			dr, dg, db := p[0]-c[0], p[1]-c[1], p[2]-c[2]
+			total += dr*dr + dg*dg + db*db
+		}
+		if total < bestErr {
+			best, bestErr = k, total
+		}
+	}
+	return best
+}
+
+// etc1sSliceSymbol is one Huffman coded symbol of a slice
+type etc1sSliceSymbol struct {
+	model int // 0 prediction, 1 endpoint delta, 2 selector
+	value int
+}
+
+// sliceSymbols converts the blocks of a slice into the symbols decodeSlice
+// reads: a prediction symbol per 2x2 block group, an endpoint delta for
+// blocks that aren't predicted and a selector or history index per block
+func (enc *etc1sEncoder) sliceSymbols(s *etc1sSlice) []etc1sSliceSymbol {
+	blocksX := (s.width + 3) / 4
+	blocksY := (s.height + 3) / 4
+	endpointAt := func(bx, by int) int {
+		return s.blocks[by*blocksX+bx].endpoint
+	}
+
+	// Prediction of a block: 0 left, 1 upper, 2 upper left, 3 delta
+	predict := func(bx, by int) int {
+		if bx >= blocksX || by >= blocksY {
+			return 0
+		}
+		e := endpointAt(bx, by)
+		switch {
+		case bx > 0 && endpointAt(bx-1, by) == e:
+			return 0
+		case by > 0 && endpointAt(bx, by-1) == e:
+			return 1
+		case bx > 0 && by > 0 && endpointAt(bx-1, by-1) == e:
+			return 2
+		}
+		return 3
+	}
+
+	history := &approxMoveToFront{values: make([]int, etc1sSelectorHistorySize), rover: etc1sSelectorHistorySize / 2}
+	var symbols []etc1sSliceSymbol
+	previousEndpoint := 0
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			if bx&1 == 0 && by&1 == 0 {
+				group := predict(bx, by) | predict(bx+1, by)<<2 | predict(bx, by+1)<<4 | predict(bx+1, by+1)<<6
+				symbols = append(symbols, etc1sSliceSymbol{0, group})
+			}
+
+			block := s.blocks[by*blocksX+bx]
+			if predict(bx, by) == 3 {
+				delta := block.endpoint - previousEndpoint
+				if delta < 0 {
+					delta += len(enc.endpoints)
+				}
+				symbols = append(symbols, etc1sSliceSymbol{1, delta})
+			}
+			previousEndpoint = block.endpoint
+
+			symbol := block.selector
+			for k, v := range history.values {
+				if v == block.selector {
+					symbol = len(enc.selectors) + k
+					history.use(k)
+					break
+				}
+			}
+			if symbol == block.selector {
+				history.add(block.selector)
+			}
+			symbols = append(symbols, etc1sSliceSymbol{2, symbol})
+		}
+	}
+	return symbols
+}
+
+// encode returns the BasisLZ global data and the data of every slice.
+// Slices come in pairs of color and alpha when hasAlpha is set.
+func (enc *etc1sEncoder) encode(imageCount int, hasAlpha bool) ([]byte, [][]byte) {
+	enc.buildCodebooks()
+
+	// Endpoint codebook, delta coded against the previous entry
+	var colorFreq [3][]int
+	for i := range colorFreq {
+		colorFreq[i] = make([]int, 32)
+	}
+	intenFreq := make([]int, 8)
+	colorModel := func(previous int) int {
+		if previous <= basisColor5Pal0PrevHi {
+			return 0
+		} else if previous <= basisColor5Pal1PrevHi {
+			return 1
+		}
+		return 2
+	}
+	forEndpointDeltas := func(visit func(inten int, model [3]int, delta [3]int)) {
+		previous := [3]int{16, 16, 16}
+		previousInten := 0
+		for _, e := range enc.endpoints {
+			var model, delta [3]int
+			for c := 0; c < 3; c++ {
+				model[c] = colorModel(previous[c])
+				delta[c] = (int(e.color[c]) - previous[c]) & 31
+				previous[c] = int(e.color[c])
+			}
+			visit((int(e.inten)-previousInten)&7, model, delta)
+			previousInten = int(e.inten)
+		}
+	}
+	forEndpointDeltas(func(inten int, model, delta [3]int) {
+		intenFreq[inten]++
+		for c := 0; c < 3; c++ {
+			colorFreq[model[c]][delta[c]]++
+		}
+	})
+	var colorCoders [3]*basisHuffmanEncoder
+	for i := range colorCoders {
+		colorCoders[i] = newBasisHuffmanEncoder(colorFreq[i], basisHuffmanMaxCodeSize)
+	}
+	intenCoder := newBasisHuffmanEncoder(intenFreq, basisHuffmanMaxCodeSize)
+	endpointsWriter := &basisBitWriter{}
+	for _, coder := range colorCoders {
+		coder.writeTable(endpointsWriter)
+	}
+	intenCoder.writeTable(endpointsWriter)
+	endpointsWriter.bits(0, 1) // Not grayscale
+	forEndpointDeltas(func(inten int, model, delta [3]int) {
+		intenCoder.encode(endpointsWriter, inten)
+		for c := 0; c < 3; c++ {
+			colorCoders[model[c]].encode(endpointsWriter, delta[c])
+		}
+	})
+	endpointsData := endpointsWriter.bytes()
+
+	// Selector codebook, stored raw
+	selectorsWriter := &basisBitWriter{}
+	selectorsWriter.bits(0, 1) // No global codebook
+	selectorsWriter.bits(0, 1) // Not hybrid
+	selectorsWriter.bits(1, 1) // Raw
+	for _, sel := range enc.selectors {
+		for row := 0; row < 4; row++ {
+			selectorsWriter.bits(uint32(sel[row]), 8)
+		}
+	}
+	selectorsData := selectorsWriter.bytes()
+
+	// Slice tables from the symbol statistics of all slices
+	sliceSymbols := make([][]etc1sSliceSymbol, len(enc.slices))
+	predFreq := make([]int, basisEndpointPredRepeatLastSymbol+1)
+	deltaFreq := make([]int, len(enc.endpoints))
+	selectorFreq := make([]int, len(enc.selectors)+etc1sSelectorHistorySize+1)
+	for i, s := range enc.slices {
+		sliceSymbols[i] = enc.sliceSymbols(s)
+		for _, sym := range sliceSymbols[i] {
+			switch sym.model {
+			case 0:
+				predFreq[sym.value]++
+			case 1:
+				deltaFreq[sym.value]++
+			default:
+				selectorFreq[sym.value]++
+			}
+		}
+	}
+	coders := [3]*basisHuffmanEncoder{
+		newBasisHuffmanEncoder(predFreq, basisHuffmanMaxCodeSize),
+		newBasisHuffmanEncoder(deltaFreq, basisHuffmanMaxCodeSize),
+		newBasisHuffmanEncoder(selectorFreq, basisHuffmanMaxCodeSize),
+	}
+	tablesWriter := &basisBitWriter{}
+	for _, coder := range coders {
+		coder.writeTable(tablesWriter)
+	}
+	tablesWriter.bits(0, basisHuffmanMaxSymsLog2) // Unused selector history RLE table
+	tablesWriter.bits(etc1sSelectorHistorySize, 13)
+	tablesData := tablesWriter.bytes()
+
+	slices := make([][]byte, len(enc.slices))
+	for i, symbols := range sliceSymbols {
+		w := &basisBitWriter{}
+		for _, sym := range symbols {
+			coders[sym.model].encode(w, sym.value)
+		}
+		slices[i] = w.bytes()
+	}
+
+	// Global data: header, image descriptors and the codebooks
+	const headerLength = 20
+	const imageDescLength = 20
+	sgd := make([]byte, headerLength+imageCount*imageDescLength)
+	binary.LittleEndian.PutUint16(sgd[0:], uint16(len(enc.endpoints)))
+	binary.LittleEndian.PutUint16(sgd[2:], uint16(len(enc.selectors)))
+	binary.LittleEndian.PutUint32(sgd[4:], uint32(len(endpointsData)))
+	binary.LittleEndian.PutUint32(sgd[8:], uint32(len(selectorsData)))
+	binary.LittleEndian.PutUint32(sgd[12:], uint32(len(tablesData)))
+	for i := 0; i < imageCount; i++ {
+		d := sgd[headerLength+i*imageDescLength:]
+		if hasAlpha {
+			rgbLength := len(slices[2*i])
+			binary.LittleEndian.PutUint32(d[8:], uint32(rgbLength))
+			binary.LittleEndian.PutUint32(d[12:], uint32(rgbLength))
+			binary.LittleEndian.PutUint32(d[16:], uint32(len(slices[2*i+1])))
+		} else {
+			binary.LittleEndian.PutUint32(d[8:], uint32(len(slices[i])))
+		}
+	}
+	sgd = append(sgd, endpointsData...)
+	sgd = append(sgd, selectorsData...)
+	sgd = append(sgd, tablesData...)
+	return sgd, slices
+}
+
+// encodeETC1S encodes mip levels, level 0 first, as BasisLZ global data and
+// the payload of every level
+func encodeETC1S(levels []*image.NRGBA, hasAlpha bool) ([]byte, [][]byte) {
+	enc := &etc1sEncoder{}
+	for _, level := range levels {
+		enc.slices = append(enc.slices, newETC1SSlice(level, false))
+		if hasAlpha {
+			enc.slices = append(enc.slices, newETC1SSlice(level, true))
+		}
+	}
+	sgd, slices := enc.encode(len(levels), hasAlpha)
+
+	payloads := make([][]byte, len(levels))
+	for i := range levels {
+		if hasAlpha {
+			payloads[i] = append(append([]byte(nil), slices[2*i]...), slices[2*i+1]...)
+		} else {
+			payloads[i] = slices[i]
+		}
+	}
+	return sgd, payloads
+}
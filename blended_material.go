@@ -0,0 +1,172 @@
+package fauxgl
+
+import "math"
+
+// BlendVertexMask selects the vertex color channel that masks a
+// BlendedMaterial
+type BlendVertexMask int
+
+const (
+	// BlendVertexNone ignores the vertex colors
+	BlendVertexNone BlendVertexMask = iota
+	// BlendVertexRed masks by the red channel of the vertex colors
+	BlendVertexRed
+	// BlendVertexGreen masks by the green channel of the vertex colors
+	BlendVertexGreen
+	// BlendVertexBlue masks by the blue channel of the vertex colors
+	BlendVertexBlue
+	// BlendVertexAlpha masks by the alpha channel of the vertex colors
+	BlendVertexAlpha
+)
+
+// BlendedMaterial mixes a layer material over a base material per fragment
+// by a mask, e.g. dirt or worn metal over paint, so layered looks need no
+// pre-composited textures. The mask is the product of MaskFactor, the red
+// channel of Mask and the VertexMask channel of the vertex colors, 0
+// showing the base and 1 the layer. Every sampled property is mixed,
+// normals included.
+type BlendedMaterial struct {
+	Base  *PBRMaterial
+	Layer *PBRMaterial
+
+	Mask       Texture // Sampled at the texture coordinates of the fragment, red channel
+	MaskFactor float64
+	VertexMask BlendVertexMask // Meshes without vertex colors mask the layer out
+
+	// Threshold and Softness reshape the mask into a linear ramp from
+	// Threshold-Softness to Threshold+Softness, so a soft mask can cut a
+	// sharp edge. The defaults, 0.5 and 0.5, keep the mask as it is.
+	Threshold float64
+	Softness  float64
+}
+
+// NewBlendedMaterial creates a material blending a layer over a base by the
+// red channel of a mask texture, which may be nil for a vertex color mask.
+// Alpha mode, cutoff and sidedness come from the base.
+func NewBlendedMaterial(base, layer *PBRMaterial, mask Texture) *PBRMaterial {
+	material := NewPBRMaterial()
+	material.AlphaMode = base.AlphaMode
+	material.AlphaCutoff = base.AlphaCutoff
+	material.DoubleSided = base.DoubleSided
+	material.Blend = &BlendedMaterial{
+		Base:       base,
+		Layer:      layer,
+		Mask:       mask,
+		MaskFactor: 1,
+		Threshold:  0.5,
+		Softness:   0.5,
+	}
+	return material
+}
+
+// weight returns the share of the layer at texture coordinates and a
+// vertex color
+func (blend *BlendedMaterial) weight(u, v float64, dx, dy Vector, vertex Color) float64 {
+	mask := blend.MaskFactor
+	if blend.Mask != nil {
+		mask *= SampleTextureGrad(blend.Mask, u, v, dx, dy).R
+	}
+	switch blend.VertexMask {
+	case BlendVertexRed:
+		mask *= vertex.R
+	case BlendVertexGreen:
+		mask *= vertex.G
+	case BlendVertexBlue:
+		mask *= vertex.B
+	case BlendVertexAlpha:
+		mask *= vertex.A
+	}
+	if blend.Softness <= 0 {
+		if mask >= blend.Threshold {
+			return 1
+		}
+		return 0
+	}
+	return Clamp((mask-blend.Threshold)/(2*blend.Softness)+0.5, 0, 1)
+}
+
+// sample samples the base and layer, skipping whichever the mask hides,
+// and mixes them
+func (blend *BlendedMaterial) sample(u, v float64, dx, dy Vector, vertex Color) *SampledMaterial {
+	if blend.Layer == nil {
+		return blend.Base.sample(u, v, dx, dy, vertex)
+	}
+	t := blend.weight(u, v, dx, dy, vertex)
+	if t <= 0 {
+		return blend.Base.sample(u, v, dx, dy, vertex)
+	}
+	if t >= 1 {
+		return blend.Layer.sample(u, v, dx, dy, vertex)
+	}
+	return mixSampledMaterials(blend.Base.sample(u, v, dx, dy, vertex), blend.Layer.sample(u, v, dx, dy, vertex), t)
+}
+
+// normalMapped reports whether the base or layer perturbs the normal
+func (blend *BlendedMaterial) normalMapped() bool {
+	return blend.Base.normalMapped() || blend.Layer.normalMapped()
+}
+
+// mixSampledMaterials linearly interpolates every property of two sampled
+// materials, renormalizing the normals
+func mixSampledMaterials(a, b *SampledMaterial, t float64) *SampledMaterial {
+	mix := func(x, y float64) float64 {
+		return x + (y-x)*t
+	}
+	mixNormal := func(x, y Vector) Vector {
+		n := x.Lerp(y, t)
+		if n.Length() < 1e-9 {
+			return x
+		}
+		return n.Normalize()
+	}
+	return &SampledMaterial{
+		BaseColor: a.BaseColor.Lerp(b.BaseColor, t),
+		Metallic:  mix(a.Metallic, b.Metallic),
+		Roughness: mix(a.Roughness, b.Roughness),
+		Normal:    mixNormal(a.Normal, b.Normal),
+		Occlusion: mix(a.Occlusion, b.Occlusion),
+		Emissive:  a.Emissive.Lerp(b.Emissive, t),
+
+		EmissiveStrength:    mix(a.EmissiveStrength, b.EmissiveStrength),
+		IOR:                 mix(a.IOR, b.IOR),
+		SpecularColor:       a.SpecularColor.Lerp(b.SpecularColor, t),
+		Transmission:        mix(a.Transmission, b.Transmission),
+		Thickness:           mix(a.Thickness, b.Thickness),
+		AttenuationColor:    a.AttenuationColor.Lerp(b.AttenuationColor, t),
+		AttenuationDistance: mixDistance(a.AttenuationDistance, b.AttenuationDistance, t),
+
+		AnisotropyStrength:   mix(a.AnisotropyStrength, b.AnisotropyStrength),
+		AnisotropyRotation:   mix(a.AnisotropyRotation, b.AnisotropyRotation),
+		SheenColor:           a.SheenColor.Lerp(b.SheenColor, t),
+		SheenRoughness:       mix(a.SheenRoughness, b.SheenRoughness),
+		Iridescence:          mix(a.Iridescence, b.Iridescence),
+		IridescenceIor:       mix(a.IridescenceIor, b.IridescenceIor),
+		IridescenceThickness: mix(a.IridescenceThickness, b.IridescenceThickness),
+		Dispersion:           mix(a.Dispersion, b.Dispersion),
+		Clearcoat:            mix(a.Clearcoat, b.Clearcoat),
+		ClearcoatRoughness:   mix(a.ClearcoatRoughness, b.ClearcoatRoughness),
+		ClearcoatNormal:      mixNormal(a.ClearcoatNormal, b.ClearcoatNormal),
+		Tangent:              a.Tangent.Lerp(b.Tangent, t),
+
+		Subsurface:          mix(a.Subsurface, b.Subsurface),
+		SubsurfaceColor:     a.SubsurfaceColor.Lerp(b.SubsurfaceColor, t),
+		SubsurfaceRadius:    mix(a.SubsurfaceRadius, b.SubsurfaceRadius),
+		SubsurfaceThickness: mix(a.SubsurfaceThickness, b.SubsurfaceThickness),
+	}
+}
+
+// mixDistance interpolates attenuation distances by their inverses, the
+// densities, so the infinite distance of clear materials mixes as none
+func mixDistance(a, b, t float64) float64 {
+	density := func(d float64) float64 {
+		if d <= 0 {
+			return 0
+		}
+		return 1 / d
+	}
+	d := density(a) + (density(b)-density(a))*t
+	if d <= 0 {
+		return math.Inf(1)
+	}
+	return 1 / d
+}
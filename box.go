@@ -1,5 +1,7 @@
 package fauxgl
 
+import "math"
+
 var EmptyBox = Box{}
 
 type Box struct {
@@ -57,3 +59,35 @@ func (a Box) Intersection(b Box) Box {
 func (a Box) Transform(m Matrix) Box {
 	return m.MulBox(a)
 }
+
+// Area returns the surface area of the box
+func (a Box) Area() float64 {
+	s := a.Size()
+	return 2 * (s.X*s.Y + s.Y*s.Z + s.Z*s.X)
+}
+
+// IntersectRay returns the distances along a ray, in units of direction,
+// at which it enters and leaves the box, and whether it hits the box in
+// front of the origin
+func (a Box) IntersectRay(origin, direction Vector) (float64, float64, bool) {
+	t0, t1 := math.Inf(-1), math.Inf(1)
+	o := [3]float64{origin.X, origin.Y, origin.Z}
+	d := [3]float64{direction.X, direction.Y, direction.Z}
+	lo := [3]float64{a.Min.X, a.Min.Y, a.Min.Z}
+	hi := [3]float64{a.Max.X, a.Max.Y, a.Max.Z}
+	for i := range o {
+		if d[i] == 0 {
+			if o[i] < lo[i] || o[i] > hi[i] {
+				return 0, 0, false
+			}
+			continue
+		}
+		n, f := (lo[i]-o[i])/d[i], (hi[i]-o[i])/d[i]
+		if n > f {
+			n, f = f, n
+		}
+		t0 = math.Max(t0, n)
+		t1 = math.Min(t1, f)
+	}
+	return t0, t1, t0 <= t1 && t1 >= 0
+}
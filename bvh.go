@@ -0,0 +1,267 @@
+package fauxgl
+
+import "sort"
+
+// bvhLeafSize is the most nodes a BVH leaf holds
+const bvhLeafSize = 4
+
+// bvhRebuildCost is the growth of the summed box areas, relative to the
+// last build, at which refitting gives way to a rebuild
+const bvhRebuildCost = 1.5
+
+// SceneBVH is a bounding volume hierarchy over the renderable nodes of a
+// scene, answering which nodes lie in a view frustum, a box or along a ray
+// without testing every node. Update keeps it in sync with the scene:
+// moved or deformed nodes only refit the boxes above them, while adding,
+// removing or hiding nodes, or boxes loosened too much by refitting,
+// rebuild it. Queries return nodes in scene order.
+type SceneBVH struct {
+	// Builds counts the full builds of the hierarchy
+	Builds int
+
+	scene  *Scene
+	order  []*SceneNode // Renderable nodes in scene order
+	bounds []Box        // World bounds of each node in order
+	leaf   []int        // BVH node holding each node in order
+	items  []int        // Indices into order, grouped by leaf
+	nodes  []bvhNode
+	cost   float64 // Summed box areas after the last build
+}
+
+type bvhNode struct {
+	bounds      Box
+	parent      int
+	left, right int  // Children of inner nodes
+	first       int  // First item of a leaf
+	count       int  // Items of a leaf, 0 for inner nodes
+	refit       bool // A box below changed
+}
+
+// NewSceneBVH builds a BVH over the renderable nodes of a scene
+func NewSceneBVH(scene *Scene) *SceneBVH {
+	bvh := &SceneBVH{scene: scene}
+	bvh.Update()
+	return bvh
+}
+
+// BVH returns the BVH of the scene, built on first use and updated for the
+// changes since the last call
+func (scene *Scene) BVH() *SceneBVH {
+	if scene.bvh == nil {
+		scene.bvh = NewSceneBVH(scene)
+	} else {
+		scene.bvh.Update()
+	}
+	return scene.bvh
+}
+
+// Update brings the BVH up to date with the scene, updating its transforms
+// first
+func (bvh *SceneBVH) Update() {
+	bvh.scene.UpdateTransforms()
+	nodes := bvh.scene.RootNode.GetRenderableNodes()
+	if !bvh.sameNodes(nodes) {
+		bvh.build(nodes)
+		return
+	}
+
+	// Refit the boxes above the nodes whose bounds changed
+	changed := false
+	for i, node := range bvh.order {
+		bounds := node.worldBounds()
+		if bounds == bvh.bounds[i] {
+			continue
+		}
+		bvh.bounds[i] = bounds
+		changed = true
+		for n := bvh.leaf[i]; n >= 0 && !bvh.nodes[n].refit; n = bvh.nodes[n].parent {
+			bvh.nodes[n].refit = true
+		}
+	}
+	if !changed {
+		return
+	}
+	// Children follow their parents, so a reverse pass refits bottom up
+	cost := 0.0
+	for n := len(bvh.nodes) - 1; n >= 0; n-- {
+		node := &bvh.nodes[n]
+		if node.refit {
+			node.refit = false
+			node.bounds = bvh.nodeBounds(node)
+		}
+		cost += node.bounds.Area()
+	}
+	if cost > bvh.cost*bvhRebuildCost {
+		bvh.build(nodes)
+	}
+}
+
+// sameNodes reports whether the BVH was built over nodes
+func (bvh *SceneBVH) sameNodes(nodes []*SceneNode) bool {
+	if bvh.nodes == nil || len(nodes) != len(bvh.order) {
+		return false
+	}
+	for i, node := range nodes {
+		if node != bvh.order[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeBounds returns the union of the boxes below a BVH node
+func (bvh *SceneBVH) nodeBounds(node *bvhNode) Box {
+	if node.count == 0 {
+		return bvh.nodes[node.left].bounds.Extend(bvh.nodes[node.right].bounds)
+	}
+	bounds := EmptyBox
+	for _, i := range bvh.items[node.first : node.first+node.count] {
+		bounds = bounds.Extend(bvh.bounds[i])
+	}
+	return bounds
+}
+
+// build rebuilds the hierarchy over nodes, splitting at the median of the
+// node centers along the longest axis
+func (bvh *SceneBVH) build(nodes []*SceneNode) {
+	bvh.Builds++
+	bvh.order = nodes
+	bvh.bounds = make([]Box, len(nodes))
+	bvh.leaf = make([]int, len(nodes))
+	bvh.items = make([]int, len(nodes))
+	bvh.nodes = bvh.nodes[:0]
+	for i, node := range nodes {
+		bvh.bounds[i] = node.worldBounds()
+		bvh.items[i] = i
+	}
+	if len(nodes) == 0 {
+		bvh.nodes = append(bvh.nodes, bvhNode{bounds: EmptyBox, parent: -1})
+		bvh.cost = 0
+		return
+	}
+	bvh.split(0, len(nodes), -1)
+	bvh.cost = 0
+	for _, node := range bvh.nodes {
+		bvh.cost += node.bounds.Area()
+	}
+}
+
+// split builds the BVH node over items[first:last] and returns its index
+func (bvh *SceneBVH) split(first, last, parent int) int {
+	index := len(bvh.nodes)
+	bvh.nodes = append(bvh.nodes, bvhNode{parent: parent})
+	items := bvh.items[first:last]
+	bounds, centers := EmptyBox, EmptyBox
+	for _, i := range items {
+		bounds = bounds.Extend(bvh.bounds[i])
+		center := bvh.bounds[i].Center()
+		centers = centers.Extend(Box{center, center})
+	}
+	if len(items) <= bvhLeafSize {
+		for _, i := range items {
+			bvh.leaf[i] = index
+		}
+		bvh.nodes[index] = bvhNode{bounds: bounds, parent: parent, first: first, count: len(items)}
+		return index
+	}
+
+	size := centers.Size()
+	axis := func(b Box) float64 { return b.Center().X }
+	if size.Y > size.X && size.Y >= size.Z {
+		axis = func(b Box) float64 { return b.Center().Y }
+	} else if size.Z > size.X && size.Z > size.Y {
+		axis = func(b Box) float64 { return b.Center().Z }
+	}
+	sort.Slice(items, func(a, b int) bool {
+		return axis(bvh.bounds[items[a]]) < axis(bvh.bounds[items[b]])
+	})
+	mid := first + len(items)/2
+	left := bvh.split(first, mid, index)
+	right := bvh.split(mid, last, index)
+	bvh.nodes[index] = bvhNode{bounds: bounds, parent: parent, left: left, right: right}
+	return index
+}
+
+// query returns the indices in order of the nodes whose boxes pass test,
+// sorted, pruning subtrees whose boxes fail it
+func (bvh *SceneBVH) query(test func(Box) bool) []int {
+	if len(bvh.order) == 0 {
+		return nil
+	}
+	var indices []int
+	stack := []int{0}
+	for len(stack) > 0 {
+		node := &bvh.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !test(node.bounds) {
+			continue
+		}
+		if node.count == 0 {
+			stack = append(stack, node.left, node.right)
+			continue
+		}
+		for _, i := range bvh.items[node.first : node.first+node.count] {
+			if test(bvh.bounds[i]) {
+				indices = append(indices, i)
+			}
+		}
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// nodesAt returns the nodes at indices in order
+func (bvh *SceneBVH) nodesAt(indices []int) []*SceneNode {
+	if len(indices) == 0 {
+		return nil
+	}
+	nodes := make([]*SceneNode, len(indices))
+	for i, index := range indices {
+		nodes[i] = bvh.order[index]
+	}
+	return nodes
+}
+
+// Nodes returns the renderable nodes the BVH holds, in scene order
+func (bvh *SceneBVH) Nodes() []*SceneNode {
+	return bvh.order
+}
+
+// QueryFrustum returns the nodes whose bounds intersect a view frustum
+func (bvh *SceneBVH) QueryFrustum(frustum *ViewFrustum) []*SceneNode {
+	return bvh.nodesAt(bvh.query(frustum.IntersectsBox))
+}
+
+// QueryBox returns the nodes whose bounds intersect a box
+func (bvh *SceneBVH) QueryBox(box Box) []*SceneNode {
+	return bvh.nodesAt(bvh.query(box.Intersects))
+}
+
+// QueryRay returns the nodes whose bounds a ray hits in front of its
+// origin, nearest first by the distance at which the ray enters them
+func (bvh *SceneBVH) QueryRay(origin, direction Vector) []*SceneNode {
+	hit := func(b Box) bool {
+		_, _, ok := b.IntersectRay(origin, direction)
+		return ok
+	}
+	indices := bvh.query(hit)
+	near := make(map[int]float64, len(indices))
+	for _, i := range indices {
+		near[i], _, _ = bvh.bounds[i].IntersectRay(origin, direction)
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return near[indices[i]] < near[indices[j]]
+	})
+	return bvh.nodesAt(indices)
+}
+
+// shadowCasters returns the shadow casting nodes whose bounds pass a test
+func (bvh *SceneBVH) shadowCasters(test func(Box) bool) []*SceneNode {
+	var casters []*SceneNode
+	for _, node := range bvh.nodesAt(bvh.query(test)) {
+		if node.CastShadows {
+			casters = append(casters, node)
+		}
+	}
+	return casters
+}
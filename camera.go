@@ -1,6 +1,10 @@
 package fauxgl
 
-import "math"
+import (
+	"math"
+	"sort"
+	"time"
+)
 
 // Camera represents a camera in the scene
 type Camera struct {
@@ -8,12 +12,15 @@ type Camera struct {
 	Position       Vector
 	Target         Vector
 	Up             Vector
-	FOV            float64 // Field of view in radians
+	FOV            float64 // Vertical field of view in radians, e.g. Radians(45)
 	AspectRatio    float64
 	NearPlane      float64
 	FarPlane       float64
 	ProjectionType ProjectionType
 	OrthoSize      float64 // For orthographic projection
+	// DepthMode selects a standard or reversed-Z projection. With
+	// DepthReversed FarPlane may be math.Inf(1).
+	DepthMode DepthMode
 }
 
 // ProjectionType represents the type of camera projection
@@ -65,10 +72,17 @@ func (camera *Camera) GetViewMatrix() Matrix {
 func (camera *Camera) GetProjectionMatrix() Matrix {
 	switch camera.ProjectionType {
 	case PerspectiveProjection:
-		return Perspective(camera.FOV, camera.AspectRatio, camera.NearPlane, camera.FarPlane)
+		// Perspective takes the field of view in degrees
+		if camera.DepthMode == DepthReversed {
+			return PerspectiveReversed(Degrees(camera.FOV), camera.AspectRatio, camera.NearPlane, camera.FarPlane)
+		}
+		return Perspective(Degrees(camera.FOV), camera.AspectRatio, camera.NearPlane, camera.FarPlane)
 	case OrthographicProjection:
 		width := camera.OrthoSize * camera.AspectRatio
 		height := camera.OrthoSize
+		if camera.DepthMode == DepthReversed {
+			return OrthographicReversed(-width/2, width/2, -height/2, height/2, camera.NearPlane, camera.FarPlane)
+		}
 		return Orthographic(-width/2, width/2, -height/2, height/2, camera.NearPlane, camera.FarPlane)
 	default:
 		return Identity()
@@ -80,6 +94,55 @@ func (camera *Camera) GetCameraMatrix() Matrix {
 	return camera.GetProjectionMatrix().Mul(camera.GetViewMatrix())
 }
 
+// minNearFarRatio bounds how close the near plane of a perspective camera may
+// get relative to the far plane, limiting depth precision loss when the
+// camera is inside the bounds
+const minNearFarRatio = 1e-4
+
+// FitDepthRange sets the near and far planes tightly around bounds as seen
+// from the camera and returns them. margin is the fraction of the depth
+// extent added in front of and behind the bounds. The planes are left
+// unchanged when bounds is empty or entirely behind the camera.
+func (camera *Camera) FitDepthRange(bounds Box, margin float64) (near, far float64) {
+	forward := camera.Target.Sub(camera.Position).Normalize()
+	if bounds == EmptyBox || forward == (Vector{}) {
+		return camera.NearPlane, camera.FarPlane
+	}
+
+	// View depth of the box corners
+	lo := math.Inf(1)
+	hi := math.Inf(-1)
+	for i := 0; i < 8; i++ {
+		corner := bounds.Min
+		if i&1 != 0 {
+			corner.X = bounds.Max.X
+		}
+		if i&2 != 0 {
+			corner.Y = bounds.Max.Y
+		}
+		if i&4 != 0 {
+			corner.Z = bounds.Max.Z
+		}
+		d := corner.Sub(camera.Position).Dot(forward)
+		lo = math.Min(lo, d)
+		hi = math.Max(hi, d)
+	}
+
+	pad := math.Max((hi-lo)*margin, 1e-6)
+	near = lo - pad
+	far = hi + pad
+	if camera.ProjectionType == PerspectiveProjection {
+		if far <= 0 {
+			return camera.NearPlane, camera.FarPlane
+		}
+		near = math.Max(near, far*minNearFarRatio)
+	}
+
+	camera.NearPlane = near
+	camera.FarPlane = far
+	return near, far
+}
+
 // LookAt sets the camera to look at a specific target
 func (camera *Camera) LookAt(position, target, up Vector) {
 	camera.Position = position
@@ -235,33 +298,154 @@ func (fpc *FirstPersonCamera) Move(direction Vector) {
 // SceneRenderer handles rendering of scenes
 type SceneRenderer struct {
 	context *Context
+	// LightCulling bins scene lights into screen tiles of LightTileSize
+	// pixels (forward+) so fragments skip lights that can't reach them.
+	// Worthwhile for scenes with many point or spot lights with a Range.
+	LightCulling  bool
+	LightTileSize int
+	lightGrid     *LightGrid
+	// CollectStats records the draw cost of every node into Stats during
+	// RenderScene, see RenderStats.DrawOverlay
+	CollectStats  bool
+	Stats         *RenderStats
+	materialNames map[*PBRMaterial]string
+	// EnableShadows renders a shadow map for every directional and spot
+	// light, and a cube of them for every point light, from the nodes that
+	// CastShadows before drawing, and shadows the lights with percentage
+	// closer filtering on the nodes that ReceiveShadows. ShadowMapSize
+	// defaults to 1024 texels, ShadowBias (in world units) to one texel, see
+	// LightShadow, and ShadowPCFSize is the filter radius in texels.
+	EnableShadows bool
+	ShadowMapSize int
+	ShadowBias    float64
+	ShadowPCFSize int
+	shadowContext *Context
+	shadowMaps    []*ShadowMap
+	shadowCubes   []*OmniShadowMap
+	// Alpha blended and transmissive nodes are drawn after the opaque ones,
+	// sorted back to front and blended. They don't write depth, so
+	// overlapping transparent surfaces stay visible, unless
+	// DepthWriteTransparent is set.
+	DepthWriteTransparent bool
+	// OrderIndependentTransparency draws the transparent nodes with
+	// weighted blended transparency instead, see Context.BeginTransparency,
+	// for overlapping or intersecting surfaces that sorting by node can't
+	// order
+	OrderIndependentTransparency bool
+	// ContactShadows, when set, renders a depth prepass of the opaque
+	// nodes and traces short rays against it from every shaded fragment
+	// towards the lights, see ContactShadows
+	ContactShadows *ContactShadows
+	// AmbientOcclusion, when set, renders a depth and normal prepass of the
+	// opaque nodes and darkens their ambient light by its screen space
+	// ambient occlusion, see SSAOEffect
+	AmbientOcclusion *SSAOEffect
+	// Time in seconds the vertex modifiers of the nodes are evaluated at,
+	// see SceneNode.Modifier
+	Time float64
+	// Placeholders draws the nodes skipped for broken meshes or materials
+	// in magenta, see RenderReport
+	Placeholders bool
+	// DebugNaN draws the fragments shaded NaN or infinite in cyan rather
+	// than dropping them, and warns of every node that produced them in the
+	// report
+	DebugNaN bool
+	// DebugFlags draws the nodes in debug views, see DebugFlags
+	DebugFlags DebugFlags
+	// Limits, when set, are checked by RenderSceneContext before drawing,
+	// see ResourceLimits
+	Limits *ResourceLimits
+	// DispersionBands traces the view rays refracted into transmissive
+	// meshes with a DispersionFactor through them in 3 to 9 wavelength
+	// bands, following internal reflections, which shows the fire of
+	// faceted gems. 0 turns it off; more bands separate the colors more
+	// smoothly, and each costs a few ray casts per fragment.
+	DispersionBands int
+
+	// Toon, when set, draws the nodes cel shaded in its style with
+	// ToonShader instead of physically based shading
+	Toon *ToonStyle
+
+	// Gizmos draws debug gizmos over every node in addition to their own,
+	// see SceneNode.Gizmos. GizmoSize is the length of their axes in world
+	// units; 0 sizes them by the nodes.
+	Gizmos    GizmoFlags
+	GizmoSize float64
+
+	cameraPosition Vector // World position of the active camera
+	viewMatrix     Matrix // View matrix of the active camera, for NormalMatrix
+	motion         *motionHistory
+
+	report *RenderReport
+	// meshChecks caches the mesh checks of the current render; the checks
+	// of the previous render are carried over as their meshes are drawn
+	// again, so meshes that leave the scene are dropped
+	meshChecks, previousChecks map[*Mesh]meshCheck
+	limitErr                   error // Limit exceeded by the nodes of the last render
 }
 
 // NewSceneRenderer creates a new scene renderer
 func NewSceneRenderer(context *Context) *SceneRenderer {
 	return &SceneRenderer{
-		context: context,
+		context:       context,
+		ShadowPCFSize: 1,
 	}
 }
 
 // RenderScene renders a complete scene
 func (renderer *SceneRenderer) RenderScene(scene *Scene) {
+	renderer.RenderSceneWithReport(scene)
+}
+
+// RenderSceneWithReport renders a complete scene like RenderScene and
+// reports the nodes it drew and skipped
+func (renderer *SceneRenderer) RenderSceneWithReport(scene *Scene) *RenderReport {
+	renderer.report = &RenderReport{}
 	if scene.ActiveCamera == nil {
-		return
+		renderer.report.warn(nil, "scene has no active camera")
+		return renderer.report
 	}
 
+	// The depth buffer must match the depth mapping of the projection
+	renderer.context.SetDepthMode(scene.ActiveCamera.DepthMode)
+
+	// Deform morphed and skinned meshes into the current pose
+	scene.UpdateTransforms()
+	scene.ApplyMorphTargetsToMeshes()
+	scene.UpdateSkinnedMeshes()
+
 	// Get camera matrices
 	viewMatrix := scene.ActiveCamera.GetViewMatrix()
 	projectionMatrix := scene.ActiveCamera.GetProjectionMatrix()
 	cameraMatrix := projectionMatrix.Mul(viewMatrix)
+	renderer.cameraPosition, renderer.viewMatrix = scene.ActiveCamera.Position, viewMatrix
+	renderer.beginMotion(cameraMatrix)
+	lights := renderer.shadowLights(scene)
+	renderer.updateLightGrid(lights, cameraMatrix)
+	renderer.resetStats(scene)
+
+	// Get all renderable nodes, split by material, without the broken ones
+	renderables := renderer.checkNodes(scene, scene.RootNode.GetRenderableNodes(), cameraMatrix)
+	renderables = expandMaterialNodes(renderables)
+	if renderer.ContactShadows != nil {
+		renderer.ContactShadows.prepare(renderer.context, scene.ActiveCamera, renderables, viewMatrix, cameraMatrix, renderer.Time)
+	}
+	if renderer.AmbientOcclusion != nil {
+		renderer.AmbientOcclusion.prepare(renderer.context, scene.ActiveCamera, renderables, viewMatrix, cameraMatrix, renderer.Time)
+	}
 
-	// Get all renderable nodes
-	renderables := scene.RootNode.GetRenderableNodes()
-
-	// Render each node
-	for _, node := range renderables {
-		renderer.RenderNode(node, cameraMatrix, scene.Lights)
+	if scene.Background != nil {
+		scene.Background.Draw(renderer.context, scene.ActiveCamera)
 	}
+
+	// Render each node, transparent ones last
+	renderer.renderPasses(renderables, viewMatrix, func(node *SceneNode) {
+		renderer.RenderNode(node, cameraMatrix, lights)
+	})
+	renderer.drawWireframe(renderables, cameraMatrix)
+	renderer.drawGizmos(scene, cameraMatrix)
+	renderer.drawLabels(scene, cameraMatrix)
+	return renderer.report
 }
 
 // RenderNode renders a single scene node
@@ -269,17 +453,152 @@ func (renderer *SceneRenderer) RenderNode(node *SceneNode, cameraMatrix Matrix,
 	if node.Mesh == nil || node.Material == nil {
 		return
 	}
+	if nodes := node.materialNodes(); len(nodes) > 1 || nodes[0] != node {
+		for _, n := range nodes {
+			renderer.RenderNode(n, cameraMatrix, lights)
+		}
+		return
+	}
 
-	// Calculate final transform matrix
-	modelMatrix := node.WorldTransform
-	finalMatrix := cameraMatrix.Mul(modelMatrix)
+	// Draw the mesh once per instance
+	for instance, modelMatrix := range node.InstanceTransforms() {
+		finalMatrix := cameraMatrix.Mul(modelMatrix)
+
+		// Create PBR shader
+		pbrShader := NewPBRShader(finalMatrix, node.Material, lights, renderer.cameraPosition)
+		pbrShader.LightGrid = renderer.lightGrid
+		pbrShader.Model = modelMatrix
+		pbrShader.ReceiveShadows = node.ReceiveShadows
+		pbrShader.ContactShadows = renderer.ContactShadows
+		pbrShader.AmbientOcclusion = renderer.AmbientOcclusion
+		pbrShader.Grade = node.Grade
+		pbrShader.dispersion = renderer.dispersionTracer(node, modelMatrix)
+		var shader Shader = pbrShader
+		if renderer.Toon != nil {
+			shader = renderer.toonShader(node, finalMatrix, modelMatrix, lights)
+		}
+		if renderer.DebugFlags != 0 {
+			shader = renderer.debugShader(node, shader, finalMatrix, modelMatrix, lights)
+		}
 
-	// Create PBR shader
-	pbrShader := NewPBRShader(finalMatrix, node.Material, lights, Vector{0, 0, 5})
+		// Set shader and render
+		renderer.drawNode(node, node.modify(shader, renderer.Time), instance, modelMatrix, cameraMatrix)
+	}
+}
+
+// renderPasses draws the opaque nodes, then the transparent nodes from back
+// to front by the view depth of the center of their bounds, blended and
+// without depth writes unless DepthWriteTransparent is set, or accumulated
+// for OrderIndependentTransparency
+func (renderer *SceneRenderer) renderPasses(nodes []*SceneNode, viewMatrix Matrix, draw func(node *SceneNode)) {
+	type sortedNode struct {
+		node  *SceneNode
+		depth float64
+	}
+	var transparent []sortedNode
+	for _, node := range nodes {
+		if !node.transparent() {
+			renderer.drawChecked(node, draw)
+			continue
+		}
+		center := node.worldBounds().Center()
+		// The camera looks down -Z, the farthest nodes have the lowest Z
+		transparent = append(transparent, sortedNode{node, viewMatrix.MulPosition(center).Z})
+	}
+	if len(transparent) == 0 {
+		return
+	}
+	sort.SliceStable(transparent, func(i, j int) bool {
+		return transparent[i].depth < transparent[j].depth
+	})
+
+	dc := renderer.context
+	alphaBlend, writeDepth := dc.AlphaBlend, dc.WriteDepth
+	dc.AlphaBlend = true
+	dc.WriteDepth = writeDepth && renderer.DepthWriteTransparent
+	if renderer.OrderIndependentTransparency {
+		dc.WriteDepth = false
+		dc.BeginTransparency()
+	}
+	for _, t := range transparent {
+		renderer.drawChecked(t.node, draw)
+	}
+	dc.ResolveTransparency()
+	dc.AlphaBlend, dc.WriteDepth = alphaBlend, writeDepth
+}
 
-	// Set shader and render
-	renderer.context.Shader = pbrShader
-	renderer.context.DrawMesh(node.Mesh)
+// transparent reports whether a node is drawn in the transparent pass: its
+// material is alpha blended or transmissive
+func (node *SceneNode) transparent() bool {
+	if node.Mesh == nil || node.Material == nil {
+		return false
+	}
+	return node.Material.AlphaMode == AlphaBlend || node.Material.TransmissionFactor > 0
+}
+
+// drawNode draws the mesh of a node, or one of its instances placed by
+// model, with shader, recording its cost when collecting statistics. The
+// LOD of the node for its screen coverage is drawn, see LODMesh. Node
+// shaders move vertices to world space, see worldVertex.
+func (renderer *SceneRenderer) drawNode(node *SceneNode, shader Shader, instance int, model, cameraMatrix Matrix) {
+	mesh := node.LODMesh(model, cameraMatrix)
+	renderer.context.Shader = shader
+	if renderer.context.NormalBuffer != nil {
+		// View space normals; normals transform by the inverse transpose
+		renderer.context.NormalMatrix = renderer.viewMatrix.Inverse().Transpose()
+	}
+	if renderer.context.VelocityBuffer != nil && renderer.motion != nil {
+		// Back to model space, then through the previous frame
+		renderer.context.PreviousMatrix = renderer.motion.previousMatrix(node, instance, model).Mul(model.Inverse())
+	}
+	if renderer.DebugNaN {
+		debug := &nanShader{Shader: shader}
+		renderer.context.Shader = debug
+		defer renderer.reportNaN(node, debug)
+	}
+	if !renderer.CollectStats {
+		renderer.context.DrawMesh(mesh)
+		return
+	}
+
+	start := time.Now()
+	info := renderer.context.DrawMesh(mesh)
+	dc := renderer.context
+	renderer.Stats.Add(NodeStats{
+		Node:      node.Name,
+		Material:  renderer.materialNames[node.Material],
+		Shader:    shaderName(shader),
+		Triangles: len(mesh.Triangles),
+		Fragments: info.TotalPixels,
+		Written:   info.UpdatedPixels,
+		Duration:  time.Since(start),
+		Bounds:    screenBounds(model.MulBox(node.Mesh.BoundingBox()), cameraMatrix, dc.Width, dc.Height),
+	})
+}
+
+// resetStats starts collecting statistics for a new frame
+func (renderer *SceneRenderer) resetStats(scene *Scene) {
+	if !renderer.CollectStats {
+		return
+	}
+	if renderer.Stats == nil {
+		renderer.Stats = &RenderStats{}
+	}
+	renderer.Stats.Reset()
+	renderer.materialNames = make(map[*PBRMaterial]string, len(scene.Materials))
+	for name, material := range scene.Materials {
+		renderer.materialNames[material] = name
+	}
+}
+
+// updateLightGrid rebuilds the light grid for the current frame
+func (renderer *SceneRenderer) updateLightGrid(lights []Light, cameraMatrix Matrix) {
+	if !renderer.LightCulling {
+		renderer.lightGrid = nil
+		return
+	}
+	dc := renderer.context
+	renderer.lightGrid = NewLightGrid(lights, cameraMatrix, dc.Width, dc.Height, renderer.LightTileSize)
 }
 
 // ViewFrustum represents a camera viewing frustum for culling
@@ -297,42 +616,27 @@ type Plane struct {
 func NewViewFrustumFromMatrix(matrix Matrix) *ViewFrustum {
 	frustum := &ViewFrustum{}
 
-	// Extract frustum planes from projection-view matrix
-	// Left plane
-	frustum.Planes[0] = Plane{
-		Normal:   Vector{matrix.X03 + matrix.X00, matrix.X13 + matrix.X10, matrix.X23 + matrix.X20},
-		Distance: matrix.X33 + matrix.X30,
-	}
-
-	// Right plane
-	frustum.Planes[1] = Plane{
-		Normal:   Vector{matrix.X03 - matrix.X00, matrix.X13 - matrix.X10, matrix.X23 - matrix.X20},
-		Distance: matrix.X33 - matrix.X30,
-	}
-
-	// Bottom plane
-	frustum.Planes[2] = Plane{
-		Normal:   Vector{matrix.X03 + matrix.X01, matrix.X13 + matrix.X11, matrix.X23 + matrix.X21},
-		Distance: matrix.X33 + matrix.X31,
-	}
-
-	// Top plane
-	frustum.Planes[3] = Plane{
-		Normal:   Vector{matrix.X03 - matrix.X01, matrix.X13 - matrix.X11, matrix.X23 - matrix.X21},
-		Distance: matrix.X33 - matrix.X31,
-	}
-
-	// Near plane
-	frustum.Planes[4] = Plane{
-		Normal:   Vector{matrix.X02, matrix.X12, matrix.X22},
-		Distance: matrix.X32,
+	// Extract frustum planes from the rows of the projection-view matrix,
+	// which maps a point p to clip space with -w <= x, y, z <= w inside.
+	// Depth modes mapping z to [0, w] are culled by the wider range.
+	m := [4][4]float64{
+		{matrix.X00, matrix.X01, matrix.X02, matrix.X03},
+		{matrix.X10, matrix.X11, matrix.X12, matrix.X13},
+		{matrix.X20, matrix.X21, matrix.X22, matrix.X23},
+		{matrix.X30, matrix.X31, matrix.X32, matrix.X33},
 	}
-
-	// Far plane
-	frustum.Planes[5] = Plane{
-		Normal:   Vector{matrix.X03 - matrix.X02, matrix.X13 - matrix.X12, matrix.X23 - matrix.X22},
-		Distance: matrix.X33 - matrix.X32,
+	row := func(i int, sign float64) Plane {
+		return Plane{
+			Normal:   Vector{m[3][0] + sign*m[i][0], m[3][1] + sign*m[i][1], m[3][2] + sign*m[i][2]},
+			Distance: m[3][3] + sign*m[i][3],
+		}
 	}
+	frustum.Planes[0] = row(0, 1)  // Left
+	frustum.Planes[1] = row(0, -1) // Right
+	frustum.Planes[2] = row(1, 1)  // Bottom
+	frustum.Planes[3] = row(1, -1) // Top
+	frustum.Planes[4] = row(2, 1)  // Near
+	frustum.Planes[5] = row(2, -1) // Far
 
 	// Normalize plane normals and distances
 	for i := range frustum.Planes {
@@ -401,25 +705,63 @@ func NewCullingSceneRenderer(context *Context) *CullingSceneRenderer {
 
 // RenderScene renders a complete scene with frustum culling
 func (csr *CullingSceneRenderer) RenderScene(scene *Scene) {
+	csr.RenderSceneWithReport(scene)
+}
+
+// RenderSceneWithReport renders a complete scene with frustum culling like
+// RenderScene and reports the nodes it drew and skipped
+func (csr *CullingSceneRenderer) RenderSceneWithReport(scene *Scene) *RenderReport {
+	csr.report = &RenderReport{}
 	if scene.ActiveCamera == nil {
-		return
+		csr.report.warn(nil, "scene has no active camera")
+		return csr.report
 	}
 
+	// The depth buffer must match the depth mapping of the projection
+	csr.context.SetDepthMode(scene.ActiveCamera.DepthMode)
+
+	// Deform morphed and skinned meshes into the current pose
+	scene.UpdateTransforms()
+	scene.ApplyMorphTargetsToMeshes()
+	scene.UpdateSkinnedMeshes()
+
 	// Get camera matrices
 	viewMatrix := scene.ActiveCamera.GetViewMatrix()
 	projectionMatrix := scene.ActiveCamera.GetProjectionMatrix()
 	cameraMatrix := projectionMatrix.Mul(viewMatrix)
+	csr.cameraPosition, csr.viewMatrix = scene.ActiveCamera.Position, viewMatrix
+	csr.beginMotion(cameraMatrix)
+
+	lights := csr.shadowLights(scene)
+	csr.updateLightGrid(lights, cameraMatrix)
+	csr.resetStats(scene)
 
 	// Create frustum for culling
 	frustum := NewViewFrustumFromMatrix(cameraMatrix)
 
-	// Get all renderable nodes
-	renderables := scene.RootNode.GetRenderableNodes()
+	// Get the renderable nodes in the frustum from the scene BVH, split by
+	// material
+	renderables := csr.checkNodes(scene, scene.BVH().QueryFrustum(frustum), cameraMatrix)
+	renderables = expandMaterialNodes(renderables)
+	if csr.ContactShadows != nil {
+		csr.ContactShadows.prepare(csr.context, scene.ActiveCamera, renderables, viewMatrix, cameraMatrix, csr.Time)
+	}
+	if csr.AmbientOcclusion != nil {
+		csr.AmbientOcclusion.prepare(csr.context, scene.ActiveCamera, renderables, viewMatrix, cameraMatrix, csr.Time)
+	}
 
-	// Render each node with culling
-	for _, node := range renderables {
-		csr.RenderNodeWithCulling(node, cameraMatrix, scene.Lights, frustum)
+	if scene.Background != nil {
+		scene.Background.Draw(csr.context, scene.ActiveCamera)
 	}
+
+	// Render each node with culling, transparent ones last
+	csr.renderPasses(renderables, viewMatrix, func(node *SceneNode) {
+		csr.RenderNodeWithCulling(node, cameraMatrix, lights, frustum)
+	})
+	csr.drawWireframe(renderables, cameraMatrix)
+	csr.drawGizmos(scene, cameraMatrix)
+	csr.drawLabels(scene, cameraMatrix)
+	return csr.report
 }
 
 // RenderNodeWithCulling renders a single scene node with frustum culling
@@ -427,24 +769,41 @@ func (csr *CullingSceneRenderer) RenderNodeWithCulling(node *SceneNode, cameraMa
 	if node.Mesh == nil || node.Material == nil {
 		return
 	}
-
-	// Transform mesh bounds to world space
-	meshBounds := node.Mesh.BoundingBox()
-	worldBounds := node.WorldTransform.MulBox(meshBounds)
-
-	// Check if the node is within the view frustum
-	if !frustum.IntersectsBox(worldBounds) {
-		return // Skip rendering this node
+	if nodes := node.materialNodes(); len(nodes) > 1 || nodes[0] != node {
+		for _, n := range nodes {
+			csr.RenderNodeWithCulling(n, cameraMatrix, lights, frustum)
+		}
+		return
 	}
 
-	// Calculate final transform matrix
-	modelMatrix := node.WorldTransform
-	finalMatrix := cameraMatrix.Mul(modelMatrix)
+	meshBounds := node.Mesh.BoundingBox()
+	for instance, modelMatrix := range node.InstanceTransforms() {
+		// Check if the instance is within the view frustum
+		if !frustum.IntersectsBox(modelMatrix.MulBox(meshBounds)) {
+			continue // Skip rendering this instance
+		}
 
-	// Create PBR shader
-	pbrShader := NewPBRShader(finalMatrix, node.Material, lights, Vector{0, 0, 5})
+		// Calculate final transform matrix
+		finalMatrix := cameraMatrix.Mul(modelMatrix)
+
+		// Create PBR shader
+		pbrShader := NewPBRShader(finalMatrix, node.Material, lights, csr.cameraPosition)
+		pbrShader.LightGrid = csr.lightGrid
+		pbrShader.Model = modelMatrix
+		pbrShader.ReceiveShadows = node.ReceiveShadows
+		pbrShader.ContactShadows = csr.ContactShadows
+		pbrShader.AmbientOcclusion = csr.AmbientOcclusion
+		pbrShader.Grade = node.Grade
+		pbrShader.dispersion = csr.dispersionTracer(node, modelMatrix)
+		var shader Shader = pbrShader
+		if csr.Toon != nil {
+			shader = csr.toonShader(node, finalMatrix, modelMatrix, lights)
+		}
+		if csr.DebugFlags != 0 {
+			shader = csr.debugShader(node, shader, finalMatrix, modelMatrix, lights)
+		}
 
-	// Set shader and render
-	csr.context.Shader = pbrShader
-	csr.context.DrawMesh(node.Mesh)
+		// Set shader and render
+		csr.drawNode(node, node.modify(shader, csr.Time), instance, modelMatrix, cameraMatrix)
+	}
 }
@@ -0,0 +1,228 @@
+package fauxgl
+
+import (
+	"math"
+	"sort"
+)
+
+// Easing maps the linear progress of a camera animation, from 0 to 1, to
+// its eased progress
+type Easing func(u float64) float64
+
+// EaseLinear moves at constant speed
+func EaseLinear(u float64) float64 {
+	return u
+}
+
+// EaseIn starts at rest and accelerates
+func EaseIn(u float64) float64 {
+	return u * u
+}
+
+// EaseOut decelerates to rest
+func EaseOut(u float64) float64 {
+	return 1 - (1-u)*(1-u)
+}
+
+// EaseInOut starts and ends at rest, the usual timing of product shots
+func EaseInOut(u float64) float64 {
+	return u * u * (3 - 2*u)
+}
+
+// CameraAnimator moves a camera along a turntable orbit or a fly-through
+// path over time, producing the camera of every frame of a sequence. The
+// camera looks at Target, at the path ahead, or tracks a node. Use
+// CameraAt as AnimationExporter.Camera to render the sequence.
+type CameraAnimator struct {
+	// Lens is the camera whose projection settings, up vector and name
+	// every produced camera copies
+	Lens *Camera
+	// Start is the time in seconds the motion starts at, and Duration the
+	// time it takes
+	Start    float64
+	Duration float64
+	// Easing times the motion; nil moves at constant speed
+	Easing Easing
+	// Loop repeats the motion after Duration instead of holding the end
+	Loop bool
+
+	// Target is the point the camera looks at
+	Target Vector
+	// LookAhead looks along the path instead of at Target
+	LookAhead bool
+	// Track, when set, looks at the center of the node's bounds, or its
+	// origin without a mesh, instead of at Target, following it while it
+	// is animated
+	Track *SceneNode
+
+	path func(u float64) Vector // Position at progress u in [0, 1]
+}
+
+// NewTurntableAnimator orbits the camera around the vertical axis through
+// its target, starting from its position and keeping its distance and
+// height, turns times over duration seconds; negative turns orbit
+// clockwise seen from above
+func NewTurntableAnimator(camera *Camera, turns, duration float64) *CameraAnimator {
+	center := camera.Target
+	offset := camera.Position.Sub(center)
+	radius := math.Hypot(offset.X, offset.Z)
+	angle := math.Atan2(offset.Z, offset.X)
+	return &CameraAnimator{
+		Lens:     camera,
+		Duration: duration,
+		Target:   center,
+		path: func(u float64) Vector {
+			a := angle + 2*math.Pi*turns*u
+			return Vector{center.X + radius*math.Cos(a), center.Y + offset.Y, center.Z + radius*math.Sin(a)}
+		},
+	}
+}
+
+// NewFlyThroughAnimator flies the camera through control points over
+// duration seconds along a Catmull-Rom spline, at constant speed before
+// easing, looking ahead along the path. Closed paths return to the first
+// point and loop smoothly.
+func NewFlyThroughAnimator(camera *Camera, points []Vector, closed bool, duration float64) *CameraAnimator {
+	animator := &CameraAnimator{
+		Lens:      camera,
+		Duration:  duration,
+		Loop:      closed,
+		Target:    camera.Target,
+		LookAhead: true,
+	}
+	switch len(points) {
+	case 0:
+		position := camera.Position
+		animator.path = func(u float64) Vector { return position }
+	case 1:
+		animator.path = func(u float64) Vector { return points[0] }
+	default:
+		animator.path = newCatmullRomPath(points, closed)
+	}
+	return animator
+}
+
+// newCatmullRomPath returns the position along a Catmull-Rom spline through
+// points, parameterized by arc length
+func newCatmullRomPath(points []Vector, closed bool) func(u float64) Vector {
+	n := len(points)
+	segments := n - 1
+	if closed {
+		segments = n
+	}
+	point := func(i int) Vector {
+		if closed {
+			return points[((i%n)+n)%n]
+		}
+		return points[ClampInt(i, 0, n-1)]
+	}
+	segment := func(s float64) Vector {
+		i := int(s)
+		if i >= segments {
+			i = segments - 1
+		}
+		t := s - float64(i)
+		p0, p1, p2, p3 := point(i-1), point(i), point(i+1), point(i+2)
+		t2, t3 := t*t, t*t*t
+		return p1.MulScalar(2).
+			Add(p2.Sub(p0).MulScalar(t)).
+			Add(p0.MulScalar(2).Sub(p1.MulScalar(5)).Add(p2.MulScalar(4)).Sub(p3).MulScalar(t2)).
+			Add(p1.MulScalar(3).Sub(p0).Sub(p2.MulScalar(3)).Add(p3).MulScalar(t3)).
+			MulScalar(0.5)
+	}
+
+	// Arc length table, so the camera moves at constant speed however
+	// unevenly the points are spaced
+	const samples = 32
+	lengths := make([]float64, segments*samples+1)
+	previous := segment(0)
+	for i := 1; i < len(lengths); i++ {
+		p := segment(float64(i) / samples)
+		lengths[i] = lengths[i-1] + p.Distance(previous)
+		previous = p
+	}
+	total := lengths[len(lengths)-1]
+	return func(u float64) Vector {
+		if total == 0 {
+			return points[0]
+		}
+		d := Clamp(u, 0, 1) * total
+		i := sort.SearchFloat64s(lengths, d)
+		if i == 0 {
+			return segment(0)
+		}
+		if i >= len(lengths) {
+			i = len(lengths) - 1
+		}
+		f := 0.0
+		if span := lengths[i] - lengths[i-1]; span > 0 {
+			f = (d - lengths[i-1]) / span
+		}
+		return segment((float64(i-1) + f) / samples)
+	}
+}
+
+// progress returns the eased progress of the motion at time t
+func (animator *CameraAnimator) progress(t float64) float64 {
+	u := 1.0
+	if animator.Duration > 0 {
+		u = (t - animator.Start) / animator.Duration
+	}
+	if animator.Loop {
+		u -= math.Floor(u)
+	} else {
+		u = Clamp(u, 0, 1)
+	}
+	if animator.Easing != nil {
+		u = animator.Easing(u)
+	}
+	return u
+}
+
+// CameraAt returns the camera at time t in seconds
+func (animator *CameraAnimator) CameraAt(t float64) *Camera {
+	camera := *animator.Lens
+	u := animator.progress(t)
+	camera.Position = animator.path(u)
+	switch {
+	case animator.Track != nil:
+		camera.Target = animator.Track.GetWorldPosition()
+		if animator.Track.Mesh != nil {
+			if bounds := animator.Track.worldBounds(); bounds != EmptyBox {
+				camera.Target = bounds.Center()
+			}
+		}
+	case animator.LookAhead:
+		// Look towards a point a little further along the path, or away
+		// from one a little back at its end
+		const du = 1e-3
+		if ahead := animator.path(u + du); u+du <= 1 && ahead != camera.Position {
+			camera.Target = ahead
+		} else if behind := animator.path(u - du); behind != camera.Position {
+			camera.Target = camera.Position.MulScalar(2).Sub(behind)
+		} else {
+			camera.Target = animator.Target
+		}
+	default:
+		camera.Target = animator.Target
+	}
+	return &camera
+}
+
+// Frames returns the cameras of the frames of the motion at a frame rate,
+// from Start to the end of Duration, leaving out the end frame of looping
+// motions, which repeats the start
+func (animator *CameraAnimator) Frames(frameRate float64) []*Camera {
+	count := int(math.Ceil(animator.Duration*frameRate - 1e-9))
+	if !animator.Loop {
+		count++
+	}
+	if count < 1 {
+		count = 1
+	}
+	cameras := make([]*Camera, count)
+	for i := range cameras {
+		cameras[i] = animator.CameraAt(animator.Start + float64(i)/frameRate)
+	}
+	return cameras
+}
@@ -1,103 +1,162 @@
 package fauxgl
 
+import "math"
+
 // Basic frustum clipping functions
 // Simplified version of the original clipping functionality
 
-var clipPlanes = []clipPlane{
-	{VectorW{1, 0, 0, 1}, VectorW{-1, 0, 0, 1}},
-	{VectorW{-1, 0, 0, 1}, VectorW{1, 0, 0, 1}},
-	{VectorW{0, 1, 0, 1}, VectorW{0, -1, 0, 1}},
-	{VectorW{0, -1, 0, 1}, VectorW{0, 1, 0, 1}},
-	{VectorW{0, 0, 1, 1}, VectorW{0, 0, -1, 1}},
-	{VectorW{0, 0, -1, 1}, VectorW{0, 0, 1, 1}},
+// clipVertex is a polygon vertex during triangle clipping. Weights holds its
+// barycentric coordinates relative to the source triangle, tracked exactly
+// through every clip so attributes never need to be recovered from positions.
+type clipVertex struct {
+	Position VectorW
+	Weights  Vector
+}
+
+// clipPolygon clips a convex polygon against the half-space plane·v >= 0
+func clipPolygon(input []clipVertex, plane VectorW) []clipVertex {
+	if len(input) == 0 {
+		return nil
+	}
+	var output []clipVertex
+	s := input[len(input)-1]
+	ds := plane.Dot(s.Position)
+	for _, e := range input {
+		de := plane.Dot(e.Position)
+		if (de >= 0) != (ds >= 0) {
+			// the edge crosses the plane; interpolate from the inside vertex
+			// so the result is identical for both triangles sharing the edge
+			a, b, da, db := s, e, ds, de
+			if da < 0 {
+				a, b, da, db = e, s, de, ds
+			}
+			t := da / (da - db)
+			output = append(output, clipVertex{
+				Position: a.Position.Add(b.Position.Sub(a.Position).MulScalar(t)),
+				Weights:  a.Weights.Add(b.Weights.Sub(a.Weights).MulScalar(t)),
+			})
+		}
+		if de >= 0 {
+			output = append(output, e)
+		}
+		s, ds = e, de
+	}
+	return output
 }
 
-type clipPlane struct {
-	P, N VectorW
+// guardBandPlanes returns the clip planes of a frustum whose x and y extent
+// is scaled by guardBand, as half-spaces plane·v >= 0. Near and far are
+// never widened. Standard projections have clip z in [-w, w], reversed-Z
+// projections in [0, w].
+func guardBandPlanes(guardBand float64, mode DepthMode) []VectorW {
+	g := math.Max(guardBand, 1)
+	minZ := VectorW{0, 0, 1, 1} // standard near: z >= -w
+	if mode == DepthReversed {
+		minZ = VectorW{0, 0, 1, 0} // reversed far: z >= 0
+	}
+	return []VectorW{
+		minZ,
+		{0, 0, -1, 1}, // z <= w
+		{1, 0, 0, g},  // left: x >= -g*w
+		{-1, 0, 0, g}, // right: x <= g*w
+		{0, 1, 0, g},  // bottom: y >= -g*w
+		{0, -1, 0, g}, // top: y <= g*w
+	}
 }
 
-func (p clipPlane) pointInFront(v VectorW) bool {
-	return v.Sub(p.P).Dot(p.N) > 0
+// insideGuardBand reports whether a clip space position needs no clipping
+func insideGuardBand(v VectorW, guardBand float64, mode DepthMode) bool {
+	g := math.Max(guardBand, 1) * v.W
+	minZ := -v.W
+	if mode == DepthReversed {
+		minZ = 0
+	}
+	return v.W > 0 && v.Z >= minZ && v.Z <= v.W && v.X >= -g && v.X <= g && v.Y >= -g && v.Y <= g
 }
 
-func (p clipPlane) intersectSegment(v0, v1 VectorW) VectorW {
-	u := v1.Sub(v0)
-	w := v0.Sub(p.P)
-	d := p.N.Dot(u)
-	n := -p.N.Dot(w)
-	return v0.Add(u.MulScalar(n / d))
+// ClipTriangle clips a triangle against the viewing frustum
+func ClipTriangle(t *Triangle) []*Triangle {
+	return clipTriangle(t, 1, DepthStandard)
 }
 
-func sutherlandHodgman(points []VectorW, planes []clipPlane) []VectorW {
-	output := points
-	for _, plane := range planes {
-		input := output
-		output = nil
-		if len(input) == 0 {
+// clipTriangle clips a triangle against the near and far planes and against
+// the x and y planes widened by guardBand. Parts outside the screen but
+// inside the guard band are left to the rasterizer, which is limited to the
+// screen, so fewer and better shaped triangles are produced.
+func clipTriangle(t *Triangle, guardBand float64, mode DepthMode) []*Triangle {
+	polygon := []clipVertex{
+		{t.V1.Output, Vector{1, 0, 0}},
+		{t.V2.Output, Vector{0, 1, 0}},
+		{t.V3.Output, Vector{0, 0, 1}},
+	}
+	for _, plane := range guardBandPlanes(guardBand, mode) {
+		polygon = clipPolygon(polygon, plane)
+	}
+
+	vertexes := make([]Vertex, len(polygon))
+	for i, p := range polygon {
+		if p.Position.W <= 0 {
+			// only possible with a degenerate projection
 			return nil
 		}
-		s := input[len(input)-1]
-		for _, e := range input {
-			if plane.pointInFront(e) {
-				if !plane.pointInFront(s) {
-					x := plane.intersectSegment(s, e)
-					output = append(output, x)
-				}
-				output = append(output, e)
-			} else if plane.pointInFront(s) {
-				x := plane.intersectSegment(s, e)
-				output = append(output, x)
-			}
-			s = e
-		}
+		b := VectorW{p.Weights.X, p.Weights.Y, p.Weights.Z, 1}
+		v := InterpolateVertexes(t.V1, t.V2, t.V3, b)
+		v.Output = p.Position
+		vertexes[i] = v
 	}
-	return output
-}
 
-// ClipTriangle clips a triangle against the viewing frustum
-func ClipTriangle(t *Triangle) []*Triangle {
-	w1 := t.V1.Output
-	w2 := t.V2.Output
-	w3 := t.V3.Output
-	p1 := w1.Vector()
-	p2 := w2.Vector()
-	p3 := w3.Vector()
-	points := []VectorW{w1, w2, w3}
-	newPoints := sutherlandHodgman(points, clipPlanes)
 	var result []*Triangle
-	for i := 2; i < len(newPoints); i++ {
-		b1 := Barycentric(p1, p2, p3, newPoints[0].Vector())
-		b2 := Barycentric(p1, p2, p3, newPoints[i-1].Vector())
-		b3 := Barycentric(p1, p2, p3, newPoints[i].Vector())
-		v1 := InterpolateVertexes(t.V1, t.V2, t.V3, b1)
-		v2 := InterpolateVertexes(t.V1, t.V2, t.V3, b2)
-		v3 := InterpolateVertexes(t.V1, t.V2, t.V3, b3)
-		result = append(result, NewTriangle(v1, v2, v3))
+	for i := 2; i < len(vertexes); i++ {
+		result = append(result, NewTriangle(vertexes[0], vertexes[i-1], vertexes[i]))
 	}
 	return result
 }
 
-// ClipLine clips a line against the viewing frustum
+// ClipLine clips a line against the viewing frustum. Vertex attributes are
+// interpolated linearly in clip space, which keeps them perspective correct
+// once the rasterizer divides by w.
 func ClipLine(l *Line) *Line {
-	// TODO: interpolate vertex attributes when clipped
+	return clipLine(l, DepthStandard)
+}
+
+// clipLine clips a line against the viewing frustum of a depth mode
+func clipLine(l *Line, mode DepthMode) *Line {
 	w1 := l.V1.Output
 	w2 := l.V2.Output
-	for _, plane := range clipPlanes {
-		f1 := plane.pointInFront(w1)
-		f2 := plane.pointInFront(w2)
+	t1, t2 := 0.0, 1.0
+	for _, plane := range guardBandPlanes(1, mode) {
+		d1 := plane.Dot(w1)
+		d2 := plane.Dot(w2)
+		f1 := d1 >= 0
+		f2 := d2 >= 0
 		if f1 && f2 {
 			continue
-		} else if f1 {
-			w2 = plane.intersectSegment(w1, w2)
-		} else if f2 {
-			w1 = plane.intersectSegment(w2, w1)
-		} else {
+		} else if !f1 && !f2 {
 			return nil
 		}
+		s := d1 / (d1 - d2)
+		w := w1.Add(w2.Sub(w1).MulScalar(s))
+		t := t1 + (t2-t1)*s
+		if f1 {
+			w2, t2 = w, t
+		} else {
+			w1, t1 = w, t
+		}
 	}
-	v1 := l.V1
-	v2 := l.V2
+	v1 := lerpVertex(l.V1, l.V2, t1)
+	v2 := lerpVertex(l.V1, l.V2, t2)
 	v1.Output = w1
 	v2.Output = w2
 	return NewLine(v1, v2)
 }
+
+// lerpVertex linearly interpolates all attributes of two vertexes
+func lerpVertex(v1, v2 Vertex, t float64) Vertex {
+	if t == 0 {
+		return v1
+	}
+	if t == 1 {
+		return v2
+	}
+	return InterpolateVertexes(v1, v2, v2, VectorW{1 - t, t, 0, 1})
+}
@@ -18,7 +18,9 @@ var (
 	White = Color{1, 1, 1, 1}
 )
 
-// Color color struct
+// Color color struct. Colors have straight (non-premultiplied) alpha,
+// except where noted: MakeColor and the internal texture filtering work
+// with premultiplied colors, which Unpremultiply converts back.
 type Color struct {
 	R, G, B, A float64
 }
@@ -28,7 +30,8 @@ func Gray(x float64) Color {
 	return Color{x, x, x, 1}
 }
 
-// MakeColor converts color from color module to fauxgl
+// MakeColor converts color from color module to fauxgl. The result is
+// premultiplied, like color.Color.RGBA; see Unpremultiply.
 func MakeColor(c color.Color) Color {
 	r, g, b, a := c.RGBA()
 	const d = 0xffff
@@ -71,6 +74,13 @@ func (a Color) NRGBA() color.NRGBA {
 	return color.NRGBA{uint8(r * d), uint8(g * d), uint8(b * d), uint8(alpha * d)}
 }
 
+// IsFinite reports whether no channel of the color is NaN or infinite
+func (a Color) IsFinite() bool {
+	// x-x is 0 for finite x and NaN otherwise
+	s := a.R + a.G + a.B + a.A
+	return s-s == 0
+}
+
 // Opaque makes a color opaque
 func (a Color) Opaque() Color {
 	return Color{a.R, a.G, a.B, 1}
@@ -81,11 +91,49 @@ func (a Color) Alpha(alpha float64) Color {
 	return Color{a.R, a.G, a.B, alpha}
 }
 
-// Lerp lerps two colors
+// Lerp lerps two colors. Lerping straight colors of different alpha
+// weights the color of nearly transparent ones too much, so filter
+// premultiplied colors.
 func (a Color) Lerp(b Color, t float64) Color {
 	return a.Add(b.Sub(a).MulScalar(t))
 }
 
+// Premultiply multiplies the red, green and blue of a straight color by
+// its alpha
+func (a Color) Premultiply() Color {
+	return Color{a.R * a.A, a.G * a.A, a.B * a.A, a.A}
+}
+
+// Unpremultiply divides the red, green and blue of a premultiplied color
+// by its alpha. Fully transparent colors become transparent black.
+func (a Color) Unpremultiply() Color {
+	if a.A <= 0 {
+		return Transparent
+	}
+	if a.A == 1 {
+		return a
+	}
+	return Color{a.R / a.A, a.G / a.A, a.B / a.A, a.A}
+}
+
+// Over composites a straight color over another (Porter-Duff source over)
+// and returns the straight result. Alpha is clamped to [0, 1], color
+// channels are not, so it also composites HDR values.
+func (a Color) Over(b Color) Color {
+	sa := Clamp(a.A, 0, 1)
+	da := Clamp(b.A, 0, 1) * (1 - sa)
+	alpha := sa + da
+	if alpha <= 0 {
+		return Transparent
+	}
+	return Color{
+		(a.R*sa + b.R*da) / alpha,
+		(a.G*sa + b.G*da) / alpha,
+		(a.B*sa + b.B*da) / alpha,
+		alpha,
+	}
+}
+
 // Add adds two colors
 func (a Color) Add(b Color) Color {
 	return Color{a.R + b.R, a.G + b.G, a.B + b.B, a.A + b.A}
@@ -140,3 +188,52 @@ func (a Color) Min(b Color) Color {
 func (a Color) Max(b Color) Color {
 	return Color{math.Max(a.R, b.R), math.Max(a.G, b.G), math.Max(a.B, b.B), math.Max(a.A, b.A)}
 }
+
+// ColorSpace is the encoding of color values
+type ColorSpace int
+
+const (
+	// ColorSpaceSRGB - gamma encoded values as displayed, the convention of
+	// the legacy shaders and of 8-bit images
+	ColorSpaceSRGB ColorSpace = iota
+	// ColorSpaceLinear - values proportional to light, the convention of
+	// PBRShader, encoded for display by a final gamma pass
+	ColorSpaceLinear
+)
+
+// Linear decodes sRGB encoded color values to linear ones, keeping alpha
+func (a Color) Linear() Color {
+	return Color{srgbToLinear(a.R), srgbToLinear(a.G), srgbToLinear(a.B), a.A}
+}
+
+// SRGB encodes linear color values with the sRGB transfer function, keeping
+// alpha
+func (a Color) SRGB() Color {
+	return Color{linearToSRGB(a.R), linearToSRGB(a.G), linearToSRGB(a.B), a.A}
+}
+
+// Convert converts the color from one color space to another
+func (a Color) Convert(from, to ColorSpace) Color {
+	switch {
+	case from == to:
+		return a
+	case to == ColorSpaceLinear:
+		return a.Linear()
+	default:
+		return a.SRGB()
+	}
+}
+
+func srgbToLinear(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(x float64) float64 {
+	if x <= 0.0031308 {
+		return x * 12.92
+	}
+	return 1.055*math.Pow(x, 1/2.4) - 0.055
+}
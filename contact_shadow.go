@@ -0,0 +1,82 @@
+package fauxgl
+
+import "math"
+
+// ContactShadows darkens direct light where short rays towards the lights
+// are blocked in a depth prepass of the scene, grounding small objects on
+// the surfaces they touch at a scale shadow maps don't resolve. Only what
+// the camera sees occludes: the rays march in screen space.
+type ContactShadows struct {
+	Length    float64 // World length of the rays
+	Thickness float64 // Depth assumed behind visible surfaces, in world units
+	Bias      float64 // Depth difference ignored against self shadowing
+	Steps     int     // Depth samples along each ray
+	depth     *DepthMap
+	view      Matrix
+	matrix    Matrix
+	screen    Matrix // Maps the prepass to its pixels, see RenderTile
+}
+
+// NewContactShadows creates contact shadows with rays of a tenth of a
+// world unit
+func NewContactShadows() *ContactShadows {
+	return &ContactShadows{
+		Length:    0.1,
+		Thickness: 0.05,
+		Bias:      0.002,
+		Steps:     12,
+	}
+}
+
+// prepare renders the depth of the opaque nodes through the camera and
+// keeps it for the rays of the frame, restoring the depth buffer
+func (cs *ContactShadows) prepare(dc *Context, camera *Camera, nodes []*SceneNode, view, matrix Matrix, time float64) {
+	saved := append([]float64(nil), dc.DepthBuffer...)
+	shader, writeColor := dc.Shader, dc.WriteColor
+	dc.WriteColor = false
+	dc.ClearDepthBuffer()
+	for _, node := range nodes {
+		if node.Mesh == nil || node.Material == nil || node.transparent() || node.Material.AlphaMode == AlphaMask {
+			continue
+		}
+		for _, transform := range node.InstanceTransforms() {
+			dc.Shader = node.modify(NewShadowMapShader(matrix.Mul(transform)), time)
+			dc.DrawMesh(node.Mesh)
+		}
+	}
+	cs.depth = dc.CameraDepth(camera)
+	cs.view, cs.matrix, cs.screen = view, matrix, dc.screenMatrix
+	copy(dc.DepthBuffer, saved)
+	dc.Shader, dc.WriteColor = shader, writeColor
+}
+
+// Visibility returns the fraction of light reaching a world position from
+// the direction toLight, 1 when no depth sample along the ray lies in front
+// of it within Thickness. Nearer hits shadow more.
+func (cs *ContactShadows) Visibility(position, toLight Vector) float64 {
+	if cs.depth == nil || cs.Steps <= 0 || cs.Length <= 0 {
+		return 1
+	}
+	toLight = toLight.Normalize()
+	step := cs.Length / float64(cs.Steps)
+	for i := 1; i <= cs.Steps; i++ {
+		t := step * float64(i)
+		p := position.Add(toLight.MulScalar(t))
+		clip := cs.matrix.MulPositionW(p)
+		if clip.W <= 0 {
+			return 1
+		}
+		s := cs.screen.MulPosition(clip.DivScalar(clip.W).Vector())
+		x, y := int(math.Floor(s.X)), int(math.Floor(s.Y))
+		if x < 0 || y < 0 || x >= cs.depth.Width || y >= cs.depth.Height {
+			return 1
+		}
+		// The camera looks down -Z
+		rayDepth := -cs.view.MulPosition(p).Z
+		d := rayDepth - cs.depth.At(x, y)
+		if d > cs.Bias && d < cs.Thickness {
+			return t / cs.Length
+		}
+	}
+	return 1
+}
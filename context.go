@@ -47,23 +47,67 @@ func (info RasterizeInfo) Add(other RasterizeInfo) RasterizeInfo {
 }
 
 type Context struct {
-	Width        int
-	Height       int
-	ColorBuffer  *image.NRGBA
-	DepthBuffer  []float64
-	ClearColor   Color
-	Shader       Shader
-	ReadDepth    bool
-	WriteDepth   bool
-	WriteColor   bool
-	AlphaBlend   bool
-	Wireframe    bool
-	FrontFace    Face
-	Cull         Cull
-	LineWidth    float64
-	DepthBias    float64
-	screenMatrix Matrix
-	locks        []sync.Mutex
+	Width       int
+	Height      int
+	ColorBuffer *image.NRGBA
+	DepthBuffer []float64
+	ClearColor  Color
+	Shader      Shader
+	ReadDepth   bool
+	WriteDepth  bool
+	WriteColor  bool
+	AlphaBlend  bool
+	Wireframe   bool
+	FrontFace   Face
+	Cull        Cull
+	LineWidth   float64
+	DepthBias   float64 // Constant offset added to fragment depth before the depth test
+	// DepthSlopeBias scales the maximum depth slope of each triangle and is
+	// added to DepthBias, like glPolygonOffset's factor. Negative values pull
+	// geometry toward the camera, e.g. for decals and outline shells.
+	DepthSlopeBias float64
+	FillRule       FillRule // Which pixels a triangle covers
+	// Centroid interpolates attributes of partially covered pixels at the
+	// nearest point inside the triangle instead of extrapolating them to the
+	// pixel center. Only affects FillConservative.
+	Centroid bool
+	// GuardBand scales the x and y clip planes. Triangles extending past the
+	// screen but within the guard band are not clipped; the rasterizer only
+	// visits on-screen pixels. 1 clips exactly to the viewing frustum.
+	GuardBand float64
+	// DepthMode selects standard or reversed-Z depth; change it with
+	// SetDepthMode so the depth buffer is cleared accordingly
+	DepthMode DepthMode
+	// DepthRangeNear and DepthRangeFar map normalized depth into the depth
+	// buffer like glDepthRange, e.g. to draw overlays in front of a scene
+	DepthRangeNear float64
+	DepthRangeFar  float64
+	// HDRBuffer, when enabled with EnableHDR, receives the unclamped linear
+	// fragment colors alongside ColorBuffer for HDR post-processing
+	HDRBuffer *HDRImage
+	// NormalBuffer, when enabled with EnableNormalBuffer, receives the
+	// normals of the shaded fragments that write depth, transformed by
+	// NormalMatrix, for screen space effects such as SSAOEffect. Pixels
+	// where nothing was drawn hold the zero vector.
+	NormalBuffer []Vector
+	NormalMatrix Matrix
+	// VelocityBuffer, when enabled with EnableVelocityBuffer, receives the
+	// motion in pixels since the previous frame of the fragments that write
+	// depth, found by projecting their positions with PreviousMatrix, the
+	// clip space matrix of the previous frame. Its zero value, the
+	// default, records no motion, as do pixels where nothing was drawn.
+	VelocityBuffer []Vector
+	PreviousMatrix Matrix
+	// ResolveFilter reduces supersampled frames to the output resolution,
+	// see SetSupersampling
+	ResolveFilter ResolveFilter
+	supersampling int // Samples per axis of every output pixel
+	screenMatrix  Matrix
+	locks         []sync.Mutex
+	oit           *oitBuffer      // see BeginTransparency
+	mask          []bool          // Pixels to shade, all when nil, see AdaptiveSupersampling
+	shadeMask     []bool          // Pixels to shade, the others only get depth, see PreviewRenderer
+	done          <-chan struct{} // Closed when the render is cancelled, see SceneRenderer.RenderSceneContext
 }
 
 func NewContext(width, height int) *Context {
@@ -73,7 +117,7 @@ func NewContext(width, height int) *Context {
 	dc.ColorBuffer = image.NewNRGBA(image.Rect(0, 0, width, height))
 	dc.DepthBuffer = make([]float64, width*height)
 	dc.ClearColor = Transparent
-	dc.Shader = NewSolidColorShader(Identity(), Color{1, 0, 1, 1})
+	dc.Shader = NewSolidColorShader(Identity(), missingColor)
 	dc.ReadDepth = true
 	dc.WriteDepth = true
 	dc.WriteColor = true
@@ -83,21 +127,38 @@ func NewContext(width, height int) *Context {
 	dc.Cull = CullBack
 	dc.LineWidth = 2
 	dc.DepthBias = 0
+	dc.DepthSlopeBias = 0
+	dc.FillRule = FillTopLeft
+	dc.Centroid = false
+	dc.GuardBand = 16
+	dc.DepthMode = DepthStandard
+	dc.DepthRangeNear = 0
+	dc.DepthRangeFar = 1
+	dc.NormalMatrix = Identity()
 	dc.screenMatrix = Screen(width, height)
 	dc.locks = make([]sync.Mutex, 256)
 	dc.ClearDepthBuffer()
 	return dc
 }
 
+// Image returns the frame at the output resolution: the color buffer, or
+// its resolved image with supersampling, see SetSupersampling
 func (dc *Context) Image() image.Image {
+	if dc.Supersampling() > 1 {
+		return dc.Resolve()
+	}
 	return dc.ColorBuffer
 }
 
+// DepthImage returns the raw depth buffer normalized between the nearest and
+// farthest drawn pixel, near in black. Empty pixels are white. See LinearDepth
+// for metric depth.
 func (dc *Context) DepthImage() image.Image {
+	clear := dc.clearDepth()
 	lo := math.MaxFloat64
 	hi := -math.MaxFloat64
 	for _, d := range dc.DepthBuffer {
-		if d == math.MaxFloat64 {
+		if d == clear {
 			continue
 		}
 		if d < lo {
@@ -113,9 +174,14 @@ func (dc *Context) DepthImage() image.Image {
 	for y := 0; y < dc.Height; y++ {
 		for x := 0; x < dc.Width; x++ {
 			d := dc.DepthBuffer[i]
-			t := (d - lo) / (hi - lo)
-			if d == math.MaxFloat64 {
+			t := 0.0
+			if d == clear {
 				t = 1
+			} else if hi > lo {
+				t = (d - lo) / (hi - lo)
+				if dc.DepthMode == DepthReversed {
+					t = 1 - t
+				}
 			}
 			c := color.Gray16{uint16(t * 0xffff)}
 			im.SetGray16(x, y, c)
@@ -125,7 +191,29 @@ func (dc *Context) DepthImage() image.Image {
 	return im
 }
 
+// EnableHDR adds a floating point color buffer to the context, cleared to
+// the clear color. Set HDRBuffer to nil to disable it.
+func (dc *Context) EnableHDR() {
+	dc.HDRBuffer = NewHDRImage(image.Rect(0, 0, dc.Width, dc.Height))
+	dc.HDRBuffer.Clear(dc.ClearColor)
+}
+
+// EnableNormalBuffer adds a normal buffer to the context, cleared with the
+// depth buffer. Set NormalBuffer to nil to disable it.
+func (dc *Context) EnableNormalBuffer() {
+	dc.NormalBuffer = make([]Vector, dc.Width*dc.Height)
+}
+
+// EnableVelocityBuffer adds a velocity buffer to the context, cleared with
+// the depth buffer. Set VelocityBuffer to nil to disable it.
+func (dc *Context) EnableVelocityBuffer() {
+	dc.VelocityBuffer = make([]Vector, dc.Width*dc.Height)
+}
+
 func (dc *Context) ClearColorBufferWith(color Color) {
+	if dc.HDRBuffer != nil {
+		dc.HDRBuffer.Clear(color)
+	}
 	c := color.NRGBA()
 	for y := 0; y < dc.Height; y++ {
 		i := dc.ColorBuffer.PixOffset(0, y)
@@ -147,10 +235,17 @@ func (dc *Context) ClearDepthBufferWith(value float64) {
 	for i := range dc.DepthBuffer {
 		dc.DepthBuffer[i] = value
 	}
+	for i := range dc.NormalBuffer {
+		dc.NormalBuffer[i] = Vector{}
+	}
+	for i := range dc.VelocityBuffer {
+		dc.VelocityBuffer[i] = Vector{}
+	}
 }
 
+// ClearDepthBuffer resets the depth buffer to the empty value of the depth mode
 func (dc *Context) ClearDepthBuffer() {
-	dc.ClearDepthBufferWith(math.MaxFloat64)
+	dc.ClearDepthBufferWith(dc.clearDepth())
 }
 
 func edge(a, b, c Vector) float64 {
@@ -160,13 +255,17 @@ func edge(a, b, c Vector) float64 {
 func (dc *Context) rasterize(v0, v1, v2 Vertex, s0, s1, s2 Vector) RasterizeInfo {
 	var info RasterizeInfo
 
-	// integer bounding box
+	// integer bounding box, limited to the screen
 	min := s0.Min(s1.Min(s2)).Floor()
 	max := s0.Max(s1.Max(s2)).Ceil()
-	x0 := int(min.X)
-	x1 := int(max.X)
-	y0 := int(min.Y)
-	y1 := int(max.Y)
+	if max.X < 0 || max.Y < 0 || min.X >= float64(dc.Width) || min.Y >= float64(dc.Height) {
+		// off screen, as in the tiles of a frame
+		return info
+	}
+	x0 := ClampInt(int(min.X), 0, dc.Width-1)
+	x1 := ClampInt(int(max.X), 0, dc.Width-1)
+	y0 := ClampInt(int(min.Y), 0, dc.Height-1)
+	y1 := ClampInt(int(max.Y), 0, dc.Height-1)
 
 	// forward differencing variables
 	p := Vector{float64(x0) + 0.5, float64(y0) + 0.5, 0}
@@ -189,6 +288,41 @@ func (dc *Context) rasterize(v0, v1, v2 Vertex, s0, s1, s2 Vector) RasterizeInfo
 	ra20 := 1 / a20
 	ra01 := 1 / a01
 
+	// fill rule: e is how far outside an edge (in barycentric units) a pixel
+	// center may lie, t whether a center exactly on the boundary is covered
+	var e0, e1, e2 float64
+	t0, t1, t2 := true, true, true
+	conservative := dc.FillRule == FillConservative
+	switch dc.FillRule {
+	case FillTopLeft:
+		t0 = isTopLeftEdge(a12*ra, b12*ra)
+		t1 = isTopLeftEdge(a20*ra, b20*ra)
+		t2 = isTopLeftEdge(a01*ra, b01*ra)
+	case FillConservative:
+		// the maximum of a linear function over the pixel square
+		e0 = 0.5 * (math.Abs(a12*ra) + math.Abs(b12*ra))
+		e1 = 0.5 * (math.Abs(a20*ra) + math.Abs(b20*ra))
+		e2 = 0.5 * (math.Abs(a01*ra) + math.Abs(b01*ra))
+		t0, t1, t2 = false, false, false
+	}
+
+	// texture coordinate derivatives: the gradients of the perspective
+	// weighted texture coordinates and of their normalization
+	ux := v0.Texture.MulScalar(a12 * ra * r0).Add(v1.Texture.MulScalar(a20 * ra * r1)).Add(v2.Texture.MulScalar(a01 * ra * r2))
+	uy := v0.Texture.MulScalar(b12 * ra * r0).Add(v1.Texture.MulScalar(b20 * ra * r1)).Add(v2.Texture.MulScalar(b01 * ra * r2))
+	wx := (a12*r0 + a20*r1 + a01*r2) * ra
+	wy := (b12*r0 + b20*r1 + b01*r2) * ra
+
+	// depth bias; negative bias always moves toward the camera
+	bias := dc.DepthBias
+	if dc.DepthSlopeBias != 0 {
+		bias += dc.DepthSlopeBias * depthSlope(s0, s1, s2)
+	}
+	reversed := dc.DepthMode == DepthReversed
+	if reversed {
+		bias = -bias
+	}
+
 	// iterate over all pixels in bounding box
 	for y := y0; y <= y1; y++ {
 		var d float64
@@ -205,7 +339,7 @@ func (dc *Context) rasterize(v0, v1, v2 Vertex, s0, s1, s2 Vector) RasterizeInfo
 			d = d2
 		}
 		d = float64(int(d))
-		if d < 0 {
+		if d < 0 || conservative {
 			// occurs in pathological cases
 			d = 0
 		}
@@ -221,13 +355,22 @@ func (dc *Context) rasterize(v0, v1, v2 Vertex, s0, s1, s2 Vector) RasterizeInfo
 			w1 += a20
 			w2 += a01
 			// check if inside triangle
-			if b0 < 0 || b1 < 0 || b2 < 0 {
+			if b0 < -e0 || b1 < -e1 || b2 < -e2 ||
+				(b0 == -e0 && !t0) || (b1 == -e1 && !t1) || (b2 == -e2 && !t2) {
 				if wasInside {
 					break
 				}
 				continue
 			}
 			wasInside = true
+			// depth is never extrapolated beyond the triangle
+			z0, z1, z2 := b0, b1, b2
+			if b0 < 0 || b1 < 0 || b2 < 0 {
+				z0, z1, z2 = clampBarycentric(b0, b1, b2)
+				if dc.Centroid {
+					b0, b1, b2 = z0, z1, z2
+				}
+			}
 			// check depth buffer for early abort
 			i := y*dc.Width + x
 			if i < 0 || i >= len(dc.DepthBuffer) {
@@ -235,45 +378,76 @@ func (dc *Context) rasterize(v0, v1, v2 Vertex, s0, s1, s2 Vector) RasterizeInfo
 				// TODO: could also be from fat lines going off screen
 				continue
 			}
+			if dc.mask != nil && !dc.mask[i] {
+				continue
+			}
 			info.TotalPixels++
-			z := b0*s0.Z + b1*s1.Z + b2*s2.Z
-			bz := z + dc.DepthBias
-			if dc.ReadDepth && bz > dc.DepthBuffer[i] { // safe w/out lock?
+			z := z0*s0.Z + z1*s1.Z + z2*s2.Z
+			bz := z + bias
+			if dc.ReadDepth && !depthPasses(bz, dc.DepthBuffer[i], reversed) { // safe w/out lock?
+				continue
+			}
+			if dc.shadeMask != nil && !dc.shadeMask[i] {
+				if dc.WriteDepth {
+					lock := &dc.locks[(x+y)&255]
+					lock.Lock()
+					if !dc.ReadDepth || depthPasses(bz, dc.DepthBuffer[i], reversed) {
+						dc.DepthBuffer[i] = z
+					}
+					lock.Unlock()
+				}
 				continue
 			}
 			// perspective-correct interpolation of vertex data
 			b := VectorW{b0 * r0, b1 * r1, b2 * r2, 0}
 			b.W = 1 / (b.X + b.Y + b.Z)
 			v := InterpolateVertexes(v0, v1, v2, b)
+			v.TextureDx = ux.Sub(v.Texture.MulScalar(wx)).MulScalar(b.W)
+			v.TextureDy = uy.Sub(v.Texture.MulScalar(wy)).MulScalar(b.W)
 			// invoke fragment shader
 			color := dc.Shader.Fragment(v)
-			if color == Discard {
+			if color == Discard || !color.IsFinite() {
+				// NaN would survive blending and every post effect, so the
+				// fragment is dropped along with its depth, normal and
+				// velocity; SceneRenderer.DebugNaN highlights them instead
 				continue
 			}
 			// update buffers atomically
 			lock := &dc.locks[(x+y)&255]
 			lock.Lock()
 			// check depth buffer again
-			if bz <= dc.DepthBuffer[i] || !dc.ReadDepth {
+			if !dc.ReadDepth || depthPasses(bz, dc.DepthBuffer[i], reversed) {
 				info.UpdatedPixels++
 				if dc.WriteDepth {
 					// update depth buffer
 					dc.DepthBuffer[i] = z
+					if i < len(dc.NormalBuffer) {
+						dc.NormalBuffer[i] = dc.NormalMatrix.MulDirection(v.Normal)
+					}
+					if i < len(dc.VelocityBuffer) {
+						dc.VelocityBuffer[i] = dc.velocity(x, y, v.Position)
+					}
 				}
-				if dc.WriteColor {
+				if dc.WriteColor && dc.oit != nil {
+					// accumulate for order independent transparency
+					dc.oit.add(i, color, z, reversed)
+				} else if dc.WriteColor {
+					if dc.HDRBuffer != nil {
+						if dc.AlphaBlend && color.A < 1 {
+							dc.HDRBuffer.blend(x, y, color)
+						} else {
+							dc.HDRBuffer.SetColor(x, y, color)
+						}
+					}
 					// update color buffer
 					if dc.AlphaBlend && color.A < 1 {
-						sr, sg, sb, sa := color.NRGBA().RGBA()
-						a := (0xffff - sa) * 0x101
+						// The color buffer has straight alpha: composite
+						// over it without darkening transparent pixels
 						j := dc.ColorBuffer.PixOffset(x, y)
-						dr := &dc.ColorBuffer.Pix[j+0]
-						dg := &dc.ColorBuffer.Pix[j+1]
-						db := &dc.ColorBuffer.Pix[j+2]
-						da := &dc.ColorBuffer.Pix[j+3]
-						*dr = uint8((uint32(*dr)*a/0xffff + sr) >> 8)
-						*dg = uint8((uint32(*dg)*a/0xffff + sg) >> 8)
-						*db = uint8((uint32(*db)*a/0xffff + sb) >> 8)
-						*da = uint8((uint32(*da)*a/0xffff + sa) >> 8)
+						p := dc.ColorBuffer.Pix[j : j+4 : j+4]
+						dst := Color{float64(p[0]) / 255, float64(p[1]) / 255, float64(p[2]) / 255, float64(p[3]) / 255}
+						c := color.Over(dst).NRGBA()
+						p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A
 					} else {
 						dc.ColorBuffer.SetNRGBA(x, y, color.NRGBA())
 					}
@@ -289,6 +463,37 @@ func (dc *Context) rasterize(v0, v1, v2 Vertex, s0, s1, s2 Vector) RasterizeInfo
 	return info
 }
 
+// isTopLeftEdge reports whether an edge with the given inward barycentric
+// gradient is a top or left edge in screen space (y down). Pixel centers lying
+// exactly on a shared edge are covered only by the triangle for which the edge
+// is top or left, so they are drawn exactly once.
+func isTopLeftEdge(gx, gy float64) bool {
+	return gx > 0 || (gx == 0 && gy > 0)
+}
+
+// clampBarycentric moves barycentric coordinates onto the triangle
+func clampBarycentric(b0, b1, b2 float64) (float64, float64, float64) {
+	b0 = math.Max(b0, 0)
+	b1 = math.Max(b1, 0)
+	b2 = math.Max(b2, 0)
+	s := b0 + b1 + b2
+	if s == 0 {
+		return 1.0 / 3, 1.0 / 3, 1.0 / 3
+	}
+	return b0 / s, b1 / s, b2 / s
+}
+
+// depthSlope returns the maximum screen space depth gradient of a triangle
+func depthSlope(s0, s1, s2 Vector) float64 {
+	d := (s1.X-s0.X)*(s2.Y-s0.Y) - (s2.X-s0.X)*(s1.Y-s0.Y)
+	if d == 0 {
+		return 0
+	}
+	dzdx := ((s1.Z-s0.Z)*(s2.Y-s0.Y) - (s2.Z-s0.Z)*(s1.Y-s0.Y)) / d
+	dzdy := ((s2.Z-s0.Z)*(s1.X-s0.X) - (s1.Z-s0.Z)*(s2.X-s0.X)) / d
+	return math.Max(math.Abs(dzdx), math.Abs(dzdy))
+}
+
 func (dc *Context) line(v0, v1 Vertex, s0, s1 Vector) RasterizeInfo {
 	n := s1.Sub(s0).Perpendicular().MulScalar(dc.LineWidth / 2)
 	s0 = s0.Add(s0.Sub(s1).Normalize().MulScalar(dc.LineWidth / 2))
@@ -317,6 +522,8 @@ func (dc *Context) drawClippedLine(v0, v1 Vertex) RasterizeInfo {
 	// screen coordinates
 	s0 := dc.screenMatrix.MulPosition(ndc0)
 	s1 := dc.screenMatrix.MulPosition(ndc1)
+	s0.Z = dc.windowDepth(ndc0.Z)
+	s1.Z = dc.windowDepth(ndc1.Z)
 
 	// rasterize
 	return dc.line(v0, v1, s0, s1)
@@ -331,8 +538,8 @@ func (dc *Context) drawClippedTriangle(v0, v1, v2 Vertex) RasterizeInfo {
 	// back face culling
 	a := (ndc1.X-ndc0.X)*(ndc2.Y-ndc0.Y) - (ndc2.X-ndc0.X)*(ndc1.Y-ndc0.Y)
 	if a < 0 {
-		v0, v1, v2 = v2, v1, v0
-		ndc0, ndc1, ndc2 = ndc2, ndc1, ndc0
+		v0, v2 = v2, v0
+		ndc0, ndc2 = ndc2, ndc0
 	}
 	if dc.Cull == CullFront {
 		a = -a
@@ -348,6 +555,9 @@ func (dc *Context) drawClippedTriangle(v0, v1, v2 Vertex) RasterizeInfo {
 	s0 := dc.screenMatrix.MulPosition(ndc0)
 	s1 := dc.screenMatrix.MulPosition(ndc1)
 	s2 := dc.screenMatrix.MulPosition(ndc2)
+	s0.Z = dc.windowDepth(ndc0.Z)
+	s1.Z = dc.windowDepth(ndc1.Z)
+	s2.Z = dc.windowDepth(ndc2.Z)
 
 	// rasterize
 	if dc.Wireframe {
@@ -362,9 +572,9 @@ func (dc *Context) DrawLine(t *Line) RasterizeInfo {
 	v1 := dc.Shader.Vertex(t.V1)
 	v2 := dc.Shader.Vertex(t.V2)
 
-	if v1.Outside() || v2.Outside() {
+	if !insideGuardBand(v1.Output, 1, dc.DepthMode) || !insideGuardBand(v2.Output, 1, dc.DepthMode) {
 		// clip to viewing volume
-		line := ClipLine(NewLine(v1, v2))
+		line := clipLine(NewLine(v1, v2), dc.DepthMode)
 		if line != nil {
 			return dc.drawClippedLine(line.V1, line.V2)
 		} else {
@@ -381,10 +591,17 @@ func (dc *Context) DrawTriangle(t *Triangle) RasterizeInfo {
 	v1 := dc.Shader.Vertex(t.V1)
 	v2 := dc.Shader.Vertex(t.V2)
 	v3 := dc.Shader.Vertex(t.V3)
+	return dc.drawShadedTriangle(v1, v2, v3)
+}
 
-	if v1.Outside() || v2.Outside() || v3.Outside() {
-		// clip to viewing volume
-		triangles := ClipTriangle(NewTriangle(v1, v2, v3))
+// drawShadedTriangle clips and rasterizes a triangle of vertices output by
+// the vertex shader
+func (dc *Context) drawShadedTriangle(v1, v2, v3 Vertex) RasterizeInfo {
+	if !insideGuardBand(v1.Output, dc.GuardBand, dc.DepthMode) ||
+		!insideGuardBand(v2.Output, dc.GuardBand, dc.DepthMode) ||
+		!insideGuardBand(v3.Output, dc.GuardBand, dc.DepthMode) {
+		// clip to near and far planes and the guard band
+		triangles := clipTriangle(NewTriangle(v1, v2, v3), dc.GuardBand, dc.DepthMode)
 		var result RasterizeInfo
 		for _, t := range triangles {
 			info := dc.drawClippedTriangle(t.V1, t.V2, t.V3)
@@ -426,6 +643,9 @@ func (dc *Context) DrawTriangles(triangles []*Triangle) RasterizeInfo {
 		go func(wi int) {
 			var result RasterizeInfo
 			for i, t := range triangles {
+				if i%(wn*64) == wi && dc.cancelled() {
+					break
+				}
 				if i%wn == wi {
 					info := dc.DrawTriangle(t)
 					result = result.Add(info)
@@ -446,3 +666,40 @@ func (dc *Context) DrawMesh(mesh *Mesh) RasterizeInfo {
 	info2 := dc.DrawLines(mesh.Lines)
 	return info1.Add(info2)
 }
+
+// DrawIndexedMesh draws an indexed mesh, running the vertex shader once per
+// vertex rather than once per triangle corner
+func (dc *Context) DrawIndexedMesh(mesh *IndexedMesh) RasterizeInfo {
+	wn := runtime.NumCPU()
+	shaded := make([]Vertex, len(mesh.Vertices))
+	var wg sync.WaitGroup
+	for wi := 0; wi < wn; wi++ {
+		wg.Add(1)
+		go func(wi int) {
+			defer wg.Done()
+			for i := wi; i < len(shaded); i += wn {
+				shaded[i] = dc.Shader.Vertex(mesh.Vertices[i])
+			}
+		}(wi)
+	}
+	wg.Wait()
+
+	ch := make(chan RasterizeInfo, wn)
+	triangles := len(mesh.Indices) / 3
+	for wi := 0; wi < wn; wi++ {
+		go func(wi int) {
+			var result RasterizeInfo
+			for i := wi; i < triangles; i += wn {
+				index := mesh.Indices[3*i : 3*i+3]
+				info := dc.drawShadedTriangle(shaded[index[0]], shaded[index[1]], shaded[index[2]])
+				result = result.Add(info)
+			}
+			ch <- result
+		}(wi)
+	}
+	var result RasterizeInfo
+	for wi := 0; wi < wn; wi++ {
+		result = result.Add(<-ch)
+	}
+	return result.Add(dc.DrawLines(mesh.Lines))
+}
@@ -0,0 +1,241 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+// DebugFlags selects the debug views of SceneRenderer.DebugFlags, for
+// diagnosing broken assets: torn or inverted meshes, missing or stretched
+// texture coordinates and blocky shadows
+type DebugFlags int
+
+const (
+	// DebugWireframe draws the edges of the triangles of every node over
+	// the shaded frame, in debugWireColor
+	DebugWireframe DebugFlags = 1 << iota
+	// DebugNormals shades the nodes with their world space vertex normals,
+	// mapped from -1..1 to 0..1 colors; inverted normals show as the
+	// opposite color of their neighbors
+	DebugNormals
+	// DebugUVChecker replaces the base color of the materials by a checker
+	// whose cells grow redder along U and greener along V, showing seams,
+	// stretching and flipped texture coordinates
+	DebugUVChecker
+	// DebugBounds draws the world space bounds of every node, like
+	// GizmoBounds
+	DebugBounds
+	// DebugShadowHeatMap tints the surfaces covered by shadow maps by the
+	// size of a shadow texel on screen: blue under half a pixel, green at
+	// one, yellow at two and red at four pixels or more, where shadows turn
+	// blocky. It shows the maps of EnableShadows.
+	DebugShadowHeatMap
+)
+
+// debugWireColor is the color of the edges of DebugWireframe
+var debugWireColor = Color{0.1, 0.9, 1, 1}
+
+// debugWireOffset pulls the edges of DebugWireframe towards the camera, as
+// a fraction of their distance, so the surfaces they lie on don't hide them
+const debugWireOffset = 0.002
+
+var (
+	uvCheckerOnce    sync.Once
+	uvCheckerTexture *AdvancedTexture
+)
+
+// newUVCheckerTexture returns the texture of DebugUVChecker, 8 by 8 cells
+// with U = 0, V = 0 at the bottom left
+func newUVCheckerTexture() *AdvancedTexture {
+	uvCheckerOnce.Do(func() {
+		const size, checks = 256, 8
+		im := image.NewNRGBA(image.Rect(0, 0, size, size))
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				u, v := x*checks/size, (size-1-y)*checks/size
+				c := Color{0.15 + 0.85*float64(u)/(checks-1), 0.15 + 0.85*float64(v)/(checks-1), 0.35, 1}
+				if (u+v)%2 != 0 {
+					c = c.MulScalar(0.45).Opaque()
+				}
+				im.SetNRGBA(x, y, c.NRGBA())
+			}
+		}
+		uvCheckerTexture = NewAdvancedTexture(im, BaseColorTexture)
+		uvCheckerTexture.MagFilter = FilterNearest
+	})
+	return uvCheckerTexture
+}
+
+// debugShader returns the shader of a node instance for the debug views of
+// the renderer, given its regular shader
+func (renderer *SceneRenderer) debugShader(node *SceneNode, shader Shader, matrix, model Matrix, lights []Light) Shader {
+	flags := renderer.DebugFlags
+	if flags&DebugNormals != 0 {
+		return &debugNormalShader{matrix, model, node.Material}
+	}
+	if flags&DebugUVChecker != 0 {
+		material := *node.Material
+		material.BaseColorTexture = newUVCheckerTexture()
+		material.BaseColorFactor = White.Alpha(node.Material.BaseColorFactor.A)
+		material.Swizzles.BaseColor = Swizzle{}
+		material.Blend, material.Zones = nil, nil
+		switch s := shader.(type) {
+		case *PBRShader:
+			checker := *s
+			checker.Material = &material
+			shader = &checker
+		case *ToonShader:
+			checker := *s
+			checker.Material = &material
+			shader = &checker
+		}
+	}
+	if flags&DebugShadowHeatMap != 0 {
+		// The world size of a pixel at a view depth of one
+		projection := matrix.Mul(model.Inverse()).Mul(renderer.viewMatrix.Inverse())
+		pixel := 2 / (math.Abs(projection.X11) * float64(renderer.context.Height))
+		shader = &debugShadowShader{shader, lights, pixel}
+	}
+	return shader
+}
+
+// debugNormalShader shades the world space normals of a mesh
+type debugNormalShader struct {
+	Matrix   Matrix
+	Model    Matrix
+	Material *PBRMaterial
+}
+
+func (shader *debugNormalShader) Vertex(v Vertex) Vertex {
+	v.Output = shader.Matrix.MulPositionW(v.Position)
+	return worldVertex(v, shader.Model)
+}
+
+func (shader *debugNormalShader) Fragment(v Vertex) Color {
+	if m := shader.Material; m != nil && m.AlphaMode == AlphaMask {
+		if m.sample(v.Texture.X, v.Texture.Y, v.TextureDx, v.TextureDy, v.Color).BaseColor.A < m.AlphaCutoff {
+			return Discard
+		}
+	}
+	n := v.Normal.Normalize()
+	return Color{n.X*0.5 + 0.5, n.Y*0.5 + 0.5, n.Z*0.5 + 0.5, 1}
+}
+
+// debugShadowShader tints the fragments of its shader, shaded in world
+// space, by the screen size of the coarsest shadow texel covering them
+type debugShadowShader struct {
+	Shader
+	Lights []Light
+	Pixel  float64 // World size of a pixel at a view depth of one
+}
+
+func (shader *debugShadowShader) Fragment(v Vertex) Color {
+	color := shader.Shader.Fragment(v)
+	if color == Discard {
+		return color
+	}
+	position := v.Position
+	// Orthographic projections keep W at one, with Pixel their pixel size
+	pixel := shader.Pixel * math.Abs(v.Output.W)
+	ratio := 0.0
+	for _, light := range shader.Lights {
+		if texel, ok := light.Shadow.texelAt(position); ok {
+			ratio = math.Max(ratio, texel/pixel)
+		}
+	}
+	if ratio == 0 {
+		return color
+	}
+	a := color.A
+	color = color.Lerp(shadowHeatColor(ratio), 0.6)
+	color.A = a
+	return color
+}
+
+// texelAt returns the world size of a shadow texel at a world position, and
+// whether the shadow covers it
+func (s *LightShadow) texelAt(position Vector) (float64, bool) {
+	if s == nil {
+		return 0, false
+	}
+	texel := s.Texel
+	if s.positional {
+		texel *= s.Position.Distance(position)
+	}
+	switch {
+	case s.Cube != nil:
+		return texel, position.Distance(s.Cube.LightPosition) < s.Cube.Far
+	case s.Map != nil:
+		p := s.Map.LightView.MulPositionW(position)
+		if p.W <= 0 {
+			return 0, false
+		}
+		x, y := p.X/p.W, p.Y/p.W
+		return texel, x >= -1 && x <= 1 && y >= -1 && y <= 1
+	}
+	return 0, false
+}
+
+// shadowHeatStops color the texel sizes of DebugShadowHeatMap, from half a
+// pixel at 0 to four pixels at 3
+var shadowHeatStops = []GradientStop{
+	{0, Color{0.1, 0.3, 1, 1}},
+	{1, Color{0.1, 0.9, 0.2, 1}},
+	{2, Color{1, 0.9, 0.1, 1}},
+	{3, Color{1, 0.1, 0.1, 1}},
+}
+
+// shadowHeatColor maps a ratio of shadow texel to pixel size to the colors
+// of DebugShadowHeatMap, on a logarithmic scale
+func shadowHeatColor(ratio float64) Color {
+	return gradientColor(shadowHeatStops, math.Log2(ratio)+1)
+}
+
+// debugWireShader draws edges in a solid color slightly towards the camera
+type debugWireShader struct {
+	Matrix Matrix // Camera matrix
+	Model  Matrix
+	Camera Vector // World position of the camera
+	Color  Color
+}
+
+func (shader *debugWireShader) Vertex(v Vertex) Vertex {
+	position := shader.Model.MulPosition(v.Position)
+	position = shader.Camera.Add(position.Sub(shader.Camera).MulScalar(1 - debugWireOffset))
+	v.Output = shader.Matrix.MulPositionW(position)
+	return v
+}
+
+func (shader *debugWireShader) Fragment(v Vertex) Color {
+	return shader.Color
+}
+
+// drawWireframe draws the edges of the triangles of nodes over the frame
+// for DebugWireframe, hidden by the surfaces in front of them
+func (renderer *SceneRenderer) drawWireframe(nodes []*SceneNode, cameraMatrix Matrix) {
+	if renderer.DebugFlags&DebugWireframe == 0 {
+		return
+	}
+	dc := renderer.context
+	shader, wireframe, writeDepth, lineWidth := dc.Shader, dc.Wireframe, dc.WriteDepth, dc.LineWidth
+	dc.Wireframe = true
+	dc.WriteDepth = false
+	dc.LineWidth = float64(dc.Supersampling())
+	defer func() {
+		dc.Shader, dc.Wireframe, dc.WriteDepth, dc.LineWidth = shader, wireframe, writeDepth, lineWidth
+	}()
+
+	for _, node := range nodes {
+		for _, part := range node.materialNodes() {
+			if part.Mesh == nil {
+				continue
+			}
+			for _, model := range part.InstanceTransforms() {
+				wire := &debugWireShader{cameraMatrix, model, renderer.cameraPosition, debugWireColor}
+				dc.Shader = part.modify(wire, renderer.Time)
+				dc.DrawMesh(part.Mesh)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// Decal projects an image, such as a logo or a label, onto the surfaces of
+// meshes from a box shaped orthographic projector, so it follows curved
+// surfaces instead of being painted into a rectangle of their UV layout.
+// The projected image lands as geometry, see Mesh and Scene.AddDecal, or
+// blended into a base color texture, see Bake.
+type Decal struct {
+	Name string
+	// Projection maps world space to decal space, where the decal fills the
+	// cube from -1 to 1 and is projected along -Z onto the surfaces inside
+	// it, its image spanning X along U and Y along V
+	Projection Matrix
+	Texture    Texture
+	Color      Color // Multiplies the texture
+	// MaxAngle skips the surfaces turned further than it from facing the
+	// projector, in radians, against the image streaking along surfaces
+	// parallel to the projection
+	MaxAngle float64
+	// Offset lifts the decal geometry off the surfaces along their normals,
+	// in world units, so it doesn't fight them for depth
+	Offset float64
+}
+
+// NewDecal creates a decal of a texture at a position, projected in a
+// direction with the top of the image towards up, covering width by height
+// world units and reaching depth units in front of and behind the position
+func NewDecal(name string, texture Texture, position, direction, up Vector, width, height, depth float64) *Decal {
+	view := LookAt(position, position.Add(direction), up)
+	scale := Scale(Vector{2 / width, 2 / height, 1 / depth})
+	return &Decal{
+		Name:       name,
+		Projection: scale.Mul(view),
+		Texture:    texture,
+		Color:      White,
+		MaxAngle:   Radians(80),
+		Offset:     math.Max(width, height) * 1e-3,
+	}
+}
+
+// Direction returns the world direction the decal is projected in
+func (d *Decal) Direction() Vector {
+	return d.Projection.Inverse().MulDirection(Vector{0, 0, -1}).Normalize()
+}
+
+// decalPlanes are the faces of the decal cube as half-spaces plane·v >= 0
+var decalPlanes = []VectorW{
+	{1, 0, 0, 1}, {-1, 0, 0, 1},
+	{0, 1, 0, 1}, {0, -1, 0, 1},
+	{0, 0, 1, 1}, {0, 0, -1, 1},
+}
+
+// facing reports whether a world normal faces the projector within MaxAngle
+func (d *Decal) facing(normal, direction Vector) bool {
+	return normal.Dot(direction.Negate()) >= math.Cos(math.Min(d.MaxAngle, math.Pi/2))
+}
+
+// Mesh returns the decal geometry for a mesh placed by model: its triangles
+// facing the projector, clipped to the decal cube, in world space and
+// lifted by Offset, with texture coordinates of the decal image
+func (d *Decal) Mesh(mesh *Mesh, model Matrix) *Mesh {
+	normalMatrix := model.Inverse().Transpose()
+	inverse := d.Projection.Inverse()
+	direction := d.Direction()
+	tangent := inverse.MulDirection(Vector{1, 0, 0}).Normalize()
+
+	var triangles []*Triangle
+	for _, t := range mesh.Triangles {
+		world := [3]Vector{model.MulPosition(t.V1.Position), model.MulPosition(t.V2.Position), model.MulPosition(t.V3.Position)}
+		face := world[1].Sub(world[0]).Cross(world[2].Sub(world[0])).Normalize()
+		if !d.facing(face, direction) {
+			continue
+		}
+		polygon := make([]clipVertex, 3)
+		for i, p := range world {
+			polygon[i] = clipVertex{d.Projection.MulPositionW(p), Vector{}}
+		}
+		polygon[0].Weights, polygon[1].Weights, polygon[2].Weights = Vector{1, 0, 0}, Vector{0, 1, 0}, Vector{0, 0, 1}
+		for _, plane := range decalPlanes {
+			polygon = clipPolygon(polygon, plane)
+		}
+		if len(polygon) < 3 {
+			continue
+		}
+
+		vertices := make([]Vertex, len(polygon))
+		for i, c := range polygon {
+			b := VectorW{c.Weights.X, c.Weights.Y, c.Weights.Z, 1}
+			normal := normalMatrix.MulDirection(InterpolateVectors(t.V1.Normal, t.V2.Normal, t.V3.Normal, b)).Normalize()
+			if normal == (Vector{}) {
+				normal = face
+			}
+			p := c.Position.Vector()
+			// Tangents follow the image across the surface
+			along := tangent.Sub(normal.MulScalar(normal.Dot(tangent))).Normalize()
+			vertices[i] = Vertex{
+				Position: inverse.MulPosition(p).Add(normal.MulScalar(d.Offset)),
+				Normal:   normal,
+				Texture:  Vector{(p.X + 1) / 2, (p.Y + 1) / 2, 0},
+				Tangent:  VectorW{along.X, along.Y, along.Z, 1},
+				Color:    White,
+			}
+		}
+		for i := 1; i+1 < len(vertices); i++ {
+			triangles = append(triangles, NewTriangle(vertices[0], vertices[i], vertices[i+1]))
+		}
+	}
+	return NewTriangleMesh(triangles)
+}
+
+// Material returns an alpha blended material showing the decal image
+func (d *Decal) Material() *PBRMaterial {
+	material := NewPBRMaterial()
+	material.BaseColorFactor = d.Color
+	material.BaseColorTexture = d.Texture
+	material.AlphaMode = AlphaBlend
+	material.MetallicFactor = 0
+	return material
+}
+
+// AddDecal adds a node to the scene drawing a decal over target nodes, by
+// default every opaque node with a mesh. The decal geometry is generated
+// from their current pose and instances in world space and doesn't follow
+// them when they move. It returns nil when the decal misses the targets.
+func (scene *Scene) AddDecal(decal *Decal, targets ...*SceneNode) *SceneNode {
+	scene.UpdateTransforms()
+	if len(targets) == 0 {
+		for _, node := range scene.RootNode.GetRenderableNodes() {
+			if !node.transparent() {
+				targets = append(targets, node)
+			}
+		}
+	}
+	var triangles []*Triangle
+	for _, node := range targets {
+		if node.Mesh == nil {
+			continue
+		}
+		for _, model := range node.InstanceTransforms() {
+			triangles = append(triangles, decal.Mesh(node.Mesh, model).Triangles...)
+		}
+	}
+	if len(triangles) == 0 {
+		return nil
+	}
+
+	mesh := NewTriangleMesh(triangles)
+	material := decal.Material()
+	scene.AddMesh(decal.Name, mesh)
+	scene.AddMaterial(decal.Name, material)
+	node := NewSceneNode(decal.Name)
+	node.Mesh = mesh
+	node.Material = material
+	node.CastShadows = false
+	scene.RootNode.AddChild(node)
+	return node
+}
+
+// Bake blends the decal over a base color texture of a mesh placed by
+// model, in the UV layout of the mesh, and returns the result at the size
+// of the base. Texture coordinates outside 0 to 1 aren't wrapped.
+func (d *Decal) Bake(base image.Image, mesh *Mesh, model Matrix) *image.NRGBA {
+	bounds := base.Bounds()
+	normalMatrix := model.Inverse().Transpose()
+	direction := d.Direction()
+	layer := bakeTexels(mesh, bounds.Dx(), bounds.Dy(), 0, func(t *Triangle, b VectorW, rnd *rand.Rand) Color {
+		normal := normalMatrix.MulDirection(InterpolateVectors(t.V1.Normal, t.V2.Normal, t.V3.Normal, b)).Normalize()
+		if normal == (Vector{}) {
+			normal = normalMatrix.MulDirection(t.Normal()).Normalize()
+		}
+		if !d.facing(normal, direction) {
+			return Transparent
+		}
+		p := d.Projection.MulPosition(model.MulPosition(InterpolateVectors(t.V1.Position, t.V2.Position, t.V3.Position, b)))
+		if math.Abs(p.X) > 1 || math.Abs(p.Y) > 1 || math.Abs(p.Z) > 1 {
+			return Transparent
+		}
+		return SampleTextureGrad(d.Texture, (p.X+1)/2, (p.Y+1)/2, Vector{}, Vector{}).Mul(d.Color)
+	})
+
+	output := copyNRGBA(base)
+	parallelRows(bounds.Dy(), func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				c := layer.NRGBAAt(x, y)
+				if c.A == 0 {
+					continue
+				}
+				dst := MakeColor(output.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y))
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, MakeColor(c).Over(dst).NRGBA())
+			}
+		}
+	})
+	return output
+}
@@ -0,0 +1,155 @@
+package fauxgl
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// DeduplicateMeshes makes nodes whose meshes hold the same geometry share
+// a single Mesh. Copies whose positions differ only by a translation, as
+// exporters produce when they bake node transforms into duplicated meshes,
+// are shared too, the offset moving into the LocalTransform of their node.
+// Positions match to within a hundred thousandth of the mesh size, the
+// other vertex attributes to within 1e-4. Skinned and morphed nodes are
+// left alone, since they deform their meshes, as are instanced nodes.
+//
+// Mesh registry entries of exact duplicates are pointed at the shared
+// mesh; those of translated duplicates are removed, as the shared mesh is
+// not at their position. It returns the number of meshes removed.
+// LoadGLTFScene runs it on every import.
+func DeduplicateMeshes(scene *Scene) int {
+	type candidate struct {
+		mesh   *Mesh
+		origin Vector
+	}
+	buckets := make(map[uint64][]candidate)
+	replaced := make(map[*Mesh]candidate) // Duplicate to shared mesh and its offset
+	kept := make(map[*Mesh]bool)
+
+	var nodes []*SceneNode
+	scene.RootNode.VisitNodes(func(node *SceneNode) {
+		if node.Mesh != nil && node.Skin == nil && node.MorphTargets == nil && len(node.Instances) == 0 && len(node.Mesh.Triangles) > 0 {
+			nodes = append(nodes, node)
+		}
+	})
+	for _, node := range nodes {
+		mesh := node.Mesh
+		if _, ok := replaced[mesh]; ok || kept[mesh] {
+			continue
+		}
+		key, origin, tolerance := meshGeometryKey(mesh)
+		found := false
+		for _, c := range buckets[key] {
+			if sameMeshGeometry(c.mesh, mesh, c.origin, origin, tolerance) {
+				replaced[mesh] = candidate{c.mesh, origin.Sub(c.origin)}
+				found = true
+				break
+			}
+		}
+		if !found {
+			buckets[key] = append(buckets[key], candidate{mesh, origin})
+			kept[mesh] = true
+		}
+	}
+	if len(replaced) == 0 {
+		return 0
+	}
+
+	for _, node := range nodes {
+		if c, ok := replaced[node.Mesh]; ok {
+			node.Mesh = c.mesh
+			if c.origin != (Vector{}) {
+				node.SetTransform(node.LocalTransform.Mul(Translate(c.origin)))
+			}
+		}
+	}
+	for name, mesh := range scene.Meshes {
+		if c, ok := replaced[mesh]; ok {
+			if c.origin == (Vector{}) {
+				scene.Meshes[name] = c.mesh
+			} else {
+				delete(scene.Meshes, name)
+			}
+		}
+	}
+	scene.UpdateTransforms()
+	return len(replaced)
+}
+
+// meshGeometryKey hashes the geometry of a mesh with positions relative to
+// the minimum corner of its bounds, which it returns with the position
+// tolerance used for the comparison
+func meshGeometryKey(mesh *Mesh) (uint64, Vector, float64) {
+	box := mesh.BoundingBox()
+	tolerance := math.Max(box.Size().Length()*1e-5, 1e-9)
+	h := fnv.New64a()
+	var buffer [8]byte
+	write := func(x, step float64) {
+		binary.LittleEndian.PutUint64(buffer[:], uint64(int64(math.Round(x/step))))
+		h.Write(buffer[:])
+	}
+	// Quantize to a coarser grid than the tolerance, so that copies within
+	// the tolerance mostly fall into the same cell
+	step := tolerance * 16
+	binary.LittleEndian.PutUint64(buffer[:], uint64(len(mesh.Triangles)))
+	h.Write(buffer[:])
+	for _, t := range mesh.Triangles {
+		for _, v := range []*Vertex{&t.V1, &t.V2, &t.V3} {
+			p := v.Position.Sub(box.Min)
+			write(p.X, step)
+			write(p.Y, step)
+			write(p.Z, step)
+			write(v.Texture.X, 1e-3)
+			write(v.Texture.Y, 1e-3)
+		}
+	}
+	return h.Sum64(), box.Min, tolerance
+}
+
+// sameMeshGeometry reports whether two meshes have the same triangles once
+// their positions are taken relative to their origins
+func sameMeshGeometry(a, b *Mesh, originA, originB Vector, tolerance float64) bool {
+	if len(a.Triangles) != len(b.Triangles) || len(a.Lines) != len(b.Lines) {
+		return false
+	}
+	const epsilon = 1e-4
+	near := func(a, b Vector, e float64) bool {
+		return math.Abs(a.X-b.X) <= e && math.Abs(a.Y-b.Y) <= e && math.Abs(a.Z-b.Z) <= e
+	}
+	nearW := func(a, b VectorW) bool {
+		return math.Abs(a.X-b.X) <= epsilon && math.Abs(a.Y-b.Y) <= epsilon &&
+			math.Abs(a.Z-b.Z) <= epsilon && math.Abs(a.W-b.W) <= epsilon
+	}
+	sameVertex := func(u, v *Vertex) bool {
+		if !near(u.Position.Sub(originA), v.Position.Sub(originB), tolerance) ||
+			!near(u.Normal, v.Normal, epsilon) || !near(u.Texture, v.Texture, epsilon) ||
+			!nearW(u.Tangent, v.Tangent) || u.Joints != v.Joints {
+			return false
+		}
+		cu, cv := u.Color, v.Color
+		if math.Abs(cu.R-cv.R) > epsilon || math.Abs(cu.G-cv.G) > epsilon ||
+			math.Abs(cu.B-cv.B) > epsilon || math.Abs(cu.A-cv.A) > epsilon {
+			return false
+		}
+		for i := range u.Weights {
+			if math.Abs(u.Weights[i]-v.Weights[i]) > epsilon {
+				return false
+			}
+		}
+		return true
+	}
+	for i, t := range a.Triangles {
+		s := b.Triangles[i]
+		if !sameVertex(&t.V1, &s.V1) || !sameVertex(&t.V2, &s.V2) || !sameVertex(&t.V3, &s.V3) {
+			return false
+		}
+	}
+	for i, l := range a.Lines {
+		s := b.Lines[i]
+		if !sameVertex(&l.V1, &s.V1) || !sameVertex(&l.V2, &s.V2) {
+			return false
+		}
+	}
+	return true
+}
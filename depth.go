@@ -0,0 +1,217 @@
+package fauxgl
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// DepthMode selects how clip space depth is mapped into the depth buffer
+type DepthMode int
+
+const (
+	// DepthStandard - OpenGL style projections with clip z in [-w, w]; depth
+	// increases with distance and nearer fragments have smaller depth
+	DepthStandard DepthMode = iota
+	// DepthReversed - reversed-Z projections (see PerspectiveReversed) with
+	// clip z in [0, w]: depth is 1 at the near plane and 0 at the far plane
+	// and the depth test keeps the larger value. Depth precision no longer
+	// collapses with distance, avoiding z-fighting in large scenes.
+	DepthReversed
+)
+
+// DepthMap holds metric depth per pixel: the distance from the camera plane
+// along the view direction. Pixels where nothing was drawn are +Inf.
+type DepthMap struct {
+	Width  int
+	Height int
+	Depth  []float64
+}
+
+// LinearizeDepth converts a depth buffer value written with a perspective
+// projection into eye space distance
+func LinearizeDepth(depth, near, far float64) float64 {
+	if depth == math.MaxFloat64 {
+		return math.Inf(1)
+	}
+	ndc := depth*2 - 1
+	return 2 * near * far / (far + near - ndc*(far-near))
+}
+
+// LinearizeOrthographicDepth converts a depth buffer value written with an
+// orthographic projection into eye space distance
+func LinearizeOrthographicDepth(depth, near, far float64) float64 {
+	if depth == math.MaxFloat64 {
+		return math.Inf(1)
+	}
+	ndc := depth*2 - 1
+	return (ndc*(far-near) + far + near) / 2
+}
+
+// LinearizeReversedDepth converts a depth buffer value written with a
+// reversed-Z perspective projection into eye space distance. far may be
+// math.Inf(1).
+func LinearizeReversedDepth(depth, near, far float64) float64 {
+	if depth <= 0 {
+		return math.Inf(1)
+	}
+	if math.IsInf(far, 1) {
+		return near / depth
+	}
+	return far * near / (depth*(far-near) + near)
+}
+
+// LinearizeReversedOrthographicDepth converts a depth buffer value written
+// with a reversed-Z orthographic projection into eye space distance
+func LinearizeReversedOrthographicDepth(depth, near, far float64) float64 {
+	if depth == -math.MaxFloat64 {
+		return math.Inf(1)
+	}
+	return far - depth*(far-near)
+}
+
+// SetDepthMode changes the depth mapping and comparison of the context.
+// Existing depth values are meaningless under another mode, so the depth
+// buffer is cleared when the mode changes.
+func (dc *Context) SetDepthMode(mode DepthMode) {
+	if dc.DepthMode == mode {
+		return
+	}
+	dc.DepthMode = mode
+	dc.ClearDepthBuffer()
+}
+
+// clearDepth is the depth buffer value of pixels where nothing was drawn
+func (dc *Context) clearDepth() float64 {
+	if dc.DepthMode == DepthReversed {
+		return -math.MaxFloat64
+	}
+	return math.MaxFloat64
+}
+
+// windowDepth maps normalized device depth into the depth range
+func (dc *Context) windowDepth(z float64) float64 {
+	if dc.DepthMode != DepthReversed {
+		z = z*0.5 + 0.5
+	}
+	if dc.DepthRangeNear == 0 && dc.DepthRangeFar == 1 {
+		return z
+	}
+	return dc.DepthRangeNear + (dc.DepthRangeFar-dc.DepthRangeNear)*z
+}
+
+// depthPasses reports whether depth z passes the depth test against stored
+func depthPasses(z, stored float64, reversed bool) bool {
+	if reversed {
+		return z >= stored
+	}
+	return z <= stored
+}
+
+// DepthAt returns the raw depth buffer value of a pixel, which lies in the
+// depth range of the context, and whether anything was drawn there
+func (dc *Context) DepthAt(x, y int) (float64, bool) {
+	if x < 0 || y < 0 || x >= dc.Width || y >= dc.Height {
+		return 0, false
+	}
+	d := dc.DepthBuffer[y*dc.Width+x]
+	return d, d != dc.clearDepth()
+}
+
+// LinearDepth reads back the depth buffer as metric depth, assuming it was
+// written with a perspective projection using near and far
+func (dc *Context) LinearDepth(near, far float64) *DepthMap {
+	if dc.DepthMode == DepthReversed {
+		return dc.depthMap(func(d float64) float64 {
+			return LinearizeReversedDepth(d, near, far)
+		})
+	}
+	return dc.depthMap(func(d float64) float64 {
+		return LinearizeDepth(d, near, far)
+	})
+}
+
+// CameraDepth reads back the depth buffer as metric depth using the
+// projection and clip planes of camera
+func (dc *Context) CameraDepth(camera *Camera) *DepthMap {
+	if camera.ProjectionType == OrthographicProjection {
+		linearize := LinearizeOrthographicDepth
+		if dc.DepthMode == DepthReversed {
+			linearize = LinearizeReversedOrthographicDepth
+		}
+		return dc.depthMap(func(d float64) float64 {
+			return linearize(d, camera.NearPlane, camera.FarPlane)
+		})
+	}
+	return dc.LinearDepth(camera.NearPlane, camera.FarPlane)
+}
+
+// depthMap linearizes the depth buffer after undoing the depth range
+func (dc *Context) depthMap(linearize func(float64) float64) *DepthMap {
+	clear := dc.clearDepth()
+	rangeNear := dc.DepthRangeNear
+	rangeScale := 1.0
+	if dc.DepthRangeFar != dc.DepthRangeNear {
+		rangeScale = 1 / (dc.DepthRangeFar - dc.DepthRangeNear)
+	}
+	depth := make([]float64, len(dc.DepthBuffer))
+	for i, d := range dc.DepthBuffer {
+		if d == clear {
+			depth[i] = math.Inf(1)
+			continue
+		}
+		depth[i] = linearize((d - rangeNear) * rangeScale)
+	}
+	return &DepthMap{Width: dc.Width, Height: dc.Height, Depth: depth}
+}
+
+// At returns the depth of a pixel, +Inf outside the map
+func (m *DepthMap) At(x, y int) float64 {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return math.Inf(1)
+	}
+	return m.Depth[y*m.Width+x]
+}
+
+// Range returns the nearest and farthest finite depth, or (0, 0) if the map is empty
+func (m *DepthMap) Range() (float64, float64) {
+	lo := math.Inf(1)
+	hi := math.Inf(-1)
+	for _, d := range m.Depth {
+		if math.IsInf(d, 1) {
+			continue
+		}
+		lo = math.Min(lo, d)
+		hi = math.Max(hi, d)
+	}
+	if math.IsInf(lo, 1) {
+		return 0, 0
+	}
+	return lo, hi
+}
+
+// Image encodes the depth map as a 16-bit grayscale image with near at black
+// and far at white. Pixels beyond far, including empty ones, are white.
+func (m *DepthMap) Image(near, far float64) *image.Gray16 {
+	im := image.NewGray16(image.Rect(0, 0, m.Width, m.Height))
+	scale := 0.0
+	if far > near {
+		scale = 1 / (far - near)
+	}
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			t := 1.0
+			if d := m.Depth[y*m.Width+x]; !math.IsInf(d, 1) {
+				t = Clamp((d-near)*scale, 0, 1)
+			}
+			im.SetGray16(x, y, color.Gray16{uint16(t * 0xffff)})
+		}
+	}
+	return im
+}
+
+// NormalizedImage encodes the depth map using its own depth range
+func (m *DepthMap) NormalizedImage() *image.Gray16 {
+	near, far := m.Range()
+	return m.Image(near, far)
+}
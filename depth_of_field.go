@@ -0,0 +1,179 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+)
+
+// BokehShape is the shape out of focus highlights blur into, see
+// DepthOfFieldEffect
+type BokehShape int
+
+const (
+	// BokehDisk blurs into circles, like a lens wide open
+	BokehDisk BokehShape = iota
+	// BokehPolygon blurs into regular polygons, like a stopped down
+	// aperture of straight blades
+	BokehPolygon
+)
+
+// dofTileSize is the size in pixels of the tiles the largest circle of
+// confusion is searched in
+const dofTileSize = 16
+
+// NewCameraDepthOfFieldEffect creates a depth of field effect blurring by
+// the depth buffer of a context rendered through a camera, in focus at
+// focusDistance from it, e.g. the distance to the camera target. aperture
+// is the blur radius in pixels of the background at infinity.
+func NewCameraDepthOfFieldEffect(context *Context, camera *Camera, focusDistance, aperture float64) *DepthOfFieldEffect {
+	return &DepthOfFieldEffect{
+		FocusDepth:  focusDistance,
+		Aperture:    aperture,
+		Samples:     32,
+		Context:     context,
+		Camera:      camera,
+		MaxBlur:     32,
+		BokehBlades: 6,
+	}
+}
+
+// circleOfConfusion returns the blur radius in pixels of a depth, by the
+// thin lens model: proportional to the difference of the inverse depths of
+// the focus and the point
+func (dof *DepthOfFieldEffect) circleOfConfusion(depth float64) float64 {
+	coc := dof.Aperture
+	if !math.IsInf(depth, 1) && dof.FocusDepth > 0 {
+		coc *= math.Abs(1 - dof.FocusDepth/math.Max(depth, 1e-9))
+	}
+	if dof.MaxBlur > 0 && coc > dof.MaxBlur {
+		coc = dof.MaxBlur
+	}
+	return coc
+}
+
+// bokehDistance returns the distance of an offset from the center of the
+// bokeh shape, 1 on its boundary at unit radius
+func (dof *DepthOfFieldEffect) bokehDistance(x, y float64) float64 {
+	r := math.Hypot(x, y)
+	if dof.Bokeh != BokehPolygon || dof.BokehBlades < 3 || r == 0 {
+		return r
+	}
+	// The polygon edge lies at cos(pi/n) from the center across a blade
+	sector := 2 * math.Pi / float64(dof.BokehBlades)
+	a := math.Atan2(y, x) - dof.BokehRotation
+	a -= sector * math.Floor(a/sector)
+	return r * math.Cos(a-sector/2) / math.Cos(sector/2)
+}
+
+// applyDepth blurs every pixel of the input by gathering the neighbors
+// whose circle of confusion covers it. Nearer neighbors blur over the
+// pixel by their own circle, farther ones at most by the pixel's, so
+// sharp foreground edges stay sharp over blurred backgrounds while blurred
+// foregrounds spread over sharp ones.
+func (dof *DepthOfFieldEffect) applyDepth(input *image.NRGBA) *image.NRGBA {
+	bounds := input.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	depthMap := dof.Context.CameraDepth(dof.Camera)
+
+	// Depth and circle of confusion at the input resolution, which is the
+	// output resolution of a supersampled context
+	n := width * height
+	depth := make([]float64, n)
+	coc := make([]float64, n)
+	colors := make([]Color, n)
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			dy := ClampInt((2*y+1)*depthMap.Height/(2*height), 0, depthMap.Height-1)
+			for x := 0; x < width; x++ {
+				dx := ClampInt((2*x+1)*depthMap.Width/(2*width), 0, depthMap.Width-1)
+				i := y*width + x
+				depth[i] = depthMap.At(dx, dy)
+				coc[i] = dof.circleOfConfusion(depth[i])
+				colors[i] = MakeColor(input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)).Premultiply()
+			}
+		}
+	})
+
+	// The largest circle of confusion that can reach every tile
+	tilesX := (width + dofTileSize - 1) / dofTileSize
+	tilesY := (height + dofTileSize - 1) / dofTileSize
+	tileMax := make([]float64, tilesX*tilesY)
+	maxCoc := 0.0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			t := (y/dofTileSize)*tilesX + x/dofTileSize
+			tileMax[t] = math.Max(tileMax[t], coc[y*width+x])
+			maxCoc = math.Max(maxCoc, tileMax[t])
+		}
+	}
+	span := int(math.Ceil(maxCoc/dofTileSize)) + 1
+	reach := make([]float64, len(tileMax))
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			r := 0.0
+			for sy := maxInt(ty-span, 0); sy <= ty+span && sy < tilesY; sy++ {
+				for sx := maxInt(tx-span, 0); sx <= tx+span && sx < tilesX; sx++ {
+					c := tileMax[sy*tilesX+sx]
+					// Tiles further than their blur can't reach this one
+					gapX := math.Max(float64(AbsInt(sx-tx)-1), 0) * dofTileSize
+					gapY := math.Max(float64(AbsInt(sy-ty)-1), 0) * dofTileSize
+					if math.Hypot(gapX, gapY) <= c {
+						r = math.Max(r, c)
+					}
+				}
+			}
+			reach[ty*tilesX+tx] = r
+		}
+	}
+
+	samples := maxInt(dof.Samples, 1)
+	golden := math.Pi * (3 - math.Sqrt(5))
+	output := image.NewNRGBA(bounds)
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				i := y*width + x
+				radius := reach[(y/dofTileSize)*tilesX+x/dofTileSize]
+				if radius < 0.5 {
+					output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y))
+					continue
+				}
+				cp, dp := coc[i], depth[i]
+				weight := 1 / math.Max(cp*cp, 1)
+				sum := colors[i].MulScalar(weight)
+
+				// A Vogel spiral over the disk of the largest blur reaching
+				// the pixel, rotated per pixel against banding
+				noise := 0.06711056*float64(x) + 0.00583715*float64(y)
+				noise = 52.9829189 * (noise - math.Floor(noise))
+				rotation := 2 * math.Pi * (noise - math.Floor(noise))
+				for s := 0; s < samples; s++ {
+					r := radius * math.Sqrt((float64(s)+0.5)/float64(samples))
+					sin, cos := math.Sincos(rotation + golden*float64(s))
+					ox, oy := r*cos, r*sin
+					qx, qy := x+int(math.Round(ox)), y+int(math.Round(oy))
+					if qx < 0 || qy < 0 || qx >= width || qy >= height {
+						continue
+					}
+					j := qy*width + qx
+					c := coc[j]
+					if depth[j] > dp {
+						c = math.Min(c, cp)
+					}
+					// The shape of the neighbor's blur around it, scaled
+					// by its circle, covers the pixel at the offset back
+					w := Clamp(c-dof.bokehDistance(-ox, -oy)+0.5, 0, 1) / math.Max(c*c, 1)
+					if w > 0 {
+						sum = sum.Add(colors[j].MulScalar(w))
+						weight += w
+					}
+				}
+				c := sum.DivScalar(weight)
+				a := Clamp(c.A, 0, 1)
+				c = Color{Clamp(c.R, 0, a), Clamp(c.G, 0, a), Clamp(c.B, 0, a), a}
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, c.Unpremultiply().NRGBA())
+			}
+		}
+	})
+	return output
+}
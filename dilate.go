@@ -0,0 +1,81 @@
+package fauxgl
+
+import (
+	"image"
+	"sync/atomic"
+)
+
+// DilateImage extends the colors of the islands of an image into the empty,
+// fully transparent texels around them by up to pixels texels, so bilinear
+// lookups and mip levels at the edges of UV islands don't blend in the
+// empty background as dark seams. Each pass fills every empty texel next
+// to the islands with the average of its filled neighbours, so padding
+// grows from the nearest island and islands meeting in a gap split it
+// between them. Filled texels are unchanged.
+func DilateImage(src image.Image, pixels int) *image.NRGBA {
+	img := copyNRGBA(src)
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	next := copyNRGBA(img)
+	for pass := 0; pass < pixels; pass++ {
+		var changed atomic.Bool
+		parallelRows(height, func(y0, y1 int) {
+			for y := y0; y < y1; y++ {
+				for x := 0; x < width; x++ {
+					i := y*img.Stride + x*4
+					if img.Pix[i+3] != 0 {
+						continue
+					}
+					var r, g, bl, a, n int
+					for dy := -1; dy <= 1; dy++ {
+						for dx := -1; dx <= 1; dx++ {
+							nx, ny := x+dx, y+dy
+							if nx < 0 || nx >= width || ny < 0 || ny >= height {
+								continue
+							}
+							p := img.Pix[ny*img.Stride+nx*4:]
+							if p[3] == 0 {
+								continue
+							}
+							r += int(p[0])
+							g += int(p[1])
+							bl += int(p[2])
+							a += int(p[3])
+							n++
+						}
+					}
+					if n == 0 {
+						continue
+					}
+					q := next.Pix[i : i+4]
+					q[0] = uint8((r + n/2) / n)
+					q[1] = uint8((g + n/2) / n)
+					q[2] = uint8((bl + n/2) / n)
+					q[3] = uint8((a + n/2) / n)
+					changed.Store(true)
+				}
+			}
+		})
+		if !changed.Load() {
+			break
+		}
+		copy(img.Pix, next.Pix)
+	}
+	return img
+}
+
+// Dilate pads the islands of the texture by pixels texels, see
+// DilateImage, and regenerates its mip levels
+func (t *AdvancedTexture) Dilate(pixels int) {
+	t.Image = DilateImage(t.Image, pixels)
+	t.refreshMipmaps()
+}
+
+// copyNRGBA returns a copy of an image as NRGBA with origin (0, 0)
+func copyNRGBA(src image.Image) *image.NRGBA {
+	img := toNRGBA(src)
+	return &image.NRGBA{
+		Pix:    append([]uint8(nil), img.Pix...),
+		Stride: img.Stride,
+		Rect:   img.Rect,
+	}
+}
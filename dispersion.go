@@ -0,0 +1,236 @@
+package fauxgl
+
+import (
+	"math"
+)
+
+// Wavelengths in micrometers of the Fraunhofer lines an Abbe number is
+// defined by, and the visible range the dispersion bands cover
+const (
+	dispersionLineD = 0.5876 // Helium d line, where the IOR is given
+	dispersionLineF = 0.4861 // Hydrogen F line
+	dispersionLineC = 0.6563 // Hydrogen C line
+
+	dispersionMinWavelength = 0.40
+	dispersionMaxWavelength = 0.70
+
+	dispersionMaxBounces    = 8
+	dispersionMinThroughput = 0.02
+	dispersionMinLobeSharp  = 8.0
+	dispersionMaxLobeSharp  = 256.0
+	dispersionOriginEpsilon = 1e-5
+	dispersionMinBands      = 3
+	dispersionMaxBands      = 9
+)
+
+// dispersionIOR returns the index of refraction at a wavelength in
+// micrometers from the Cauchy equation fitted to the IOR at the d line and
+// the Abbe number of KHR_materials_dispersion, 20 / dispersion
+func dispersionIOR(ior, dispersion, wavelength float64) float64 {
+	if dispersion <= 0 {
+		return ior
+	}
+	abbe := 20 / dispersion
+	b := (ior - 1) / abbe / (1/(dispersionLineF*dispersionLineF) - 1/(dispersionLineC*dispersionLineC))
+	return ior + b*(1/(wavelength*wavelength)-1/(dispersionLineD*dispersionLineD))
+}
+
+// wavelengthColor returns the linear sRGB response to a wavelength in
+// micrometers, from the multi-lobe Gaussian fit of the CIE 1931 color
+// matching functions by Wyman, Sloan and Shirley
+func wavelengthColor(wavelength float64) Color {
+	nm := wavelength * 1000
+	g := func(mu, sigma1, sigma2 float64) float64 {
+		sigma := sigma1
+		if nm >= mu {
+			sigma = sigma2
+		}
+		t := (nm - mu) / sigma
+		return math.Exp(-0.5 * t * t)
+	}
+	x := 1.056*g(599.8, 37.9, 31.0) + 0.362*g(442.0, 16.0, 26.7) - 0.065*g(501.1, 20.4, 26.2)
+	y := 0.821*g(568.8, 46.9, 40.5) + 0.286*g(530.9, 16.3, 31.1)
+	z := 1.217*g(437.0, 11.8, 36.0) + 0.681*g(459.0, 26.0, 13.8)
+	return Color{
+		math.Max(3.2406*x-1.5372*y-0.4986*z, 0),
+		math.Max(-0.9689*x+1.8758*y+0.0415*z, 0),
+		math.Max(0.0557*x-0.2040*y+1.0570*z, 0),
+		1,
+	}
+}
+
+// dispersionBand is a wavelength traced by dispersionTracer and the color
+// it contributes
+type dispersionBand struct {
+	wavelength float64
+	weight     Color
+}
+
+// dispersionBands splits the visible range into n bands whose colors sum
+// to white, so materials without dispersion trace to their undispersed
+// color
+func dispersionBands(n int) []dispersionBand {
+	n = ClampInt(n, dispersionMinBands, dispersionMaxBands)
+	bands := make([]dispersionBand, n)
+	var sum Color
+	for i := range bands {
+		w := dispersionMinWavelength + (dispersionMaxWavelength-dispersionMinWavelength)*(float64(i)+0.5)/float64(n)
+		bands[i] = dispersionBand{w, wavelengthColor(w)}
+		sum = sum.Add(bands[i].weight)
+	}
+	for i := range bands {
+		c := bands[i].weight
+		bands[i].weight = Color{c.R / sum.R, c.G / sum.G, c.B / sum.B, 1}
+	}
+	return bands
+}
+
+// dispersionTracer traces the view rays refracted into a transmissive mesh
+// through it, once per wavelength band, following internal reflections
+// until they leave the mesh, which splits the light leaving the facets of
+// gems into its colors: their fire. It works in the model space of the
+// mesh; fragments, shaded in world space, are moved back to it.
+type dispersionTracer struct {
+	mesh    *Mesh
+	model   Matrix // Model to world space, for the lights
+	inverse Matrix // World to model space, for the fragments
+	eye     Vector // Camera position in model space
+	bands   []dispersionBand
+	epsilon float64 // Offset of ray origins from the surfaces they leave
+}
+
+// dispersionTracer returns the tracer of a node instance, or nil when
+// DispersionBands is off or the material of the node doesn't disperse
+func (renderer *SceneRenderer) dispersionTracer(node *SceneNode, model Matrix) *dispersionTracer {
+	material := node.Material
+	if renderer.DispersionBands <= 0 || material.TransmissionFactor <= 0 || material.DispersionFactor <= 0 {
+		return nil
+	}
+	size := node.Mesh.BoundingBox().Size().MaxComponent()
+	inverse := model.Inverse()
+	return &dispersionTracer{
+		mesh:    node.Mesh,
+		model:   model,
+		inverse: inverse,
+		eye:     inverse.MulPosition(renderer.cameraPosition),
+		bands:   dispersionBands(renderer.DispersionBands),
+		epsilon: math.Max(size, 1e-9) * dispersionOriginEpsilon,
+	}
+}
+
+// refract returns the direction of a ray refracted through a surface with
+// normal n facing it, eta being the ratio of the indices of refraction of
+// the sides it leaves and enters, false on total internal reflection
+func refract(d, n Vector, eta float64) (Vector, bool) {
+	cosi := -d.Dot(n)
+	k := 1 - eta*eta*(1-cosi*cosi)
+	if k < 0 {
+		return Vector{}, false
+	}
+	return d.MulScalar(eta).Add(n.MulScalar(eta*cosi - math.Sqrt(k))).Normalize(), true
+}
+
+// fresnelDielectric returns the unpolarized reflectance of a dielectric
+// boundary for an incident cosine, eta being the ratio of the indices of
+// refraction of the sides the ray leaves and enters
+func fresnelDielectric(cosi, eta float64) float64 {
+	sint2 := eta * eta * (1 - cosi*cosi)
+	if sint2 >= 1 {
+		return 1
+	}
+	cost := math.Sqrt(1 - sint2)
+	rs := (eta*cosi - cost) / (eta*cosi + cost)
+	rp := (cosi - eta*cost) / (cosi + eta*cost)
+	return (rs*rs + rp*rp) / 2
+}
+
+// trace returns the light a fragment of the mesh, at a world position and
+// normal, refracts towards the camera, summed over the bands, before the
+// transmission and body color of the material
+func (tracer *dispersionTracer) trace(shader *PBRShader, position, normal Vector, material *SampledMaterial) Color {
+	position = tracer.inverse.MulPosition(position)
+	normal = tracer.inverse.MulDirection(normal)
+	view := position.Sub(tracer.eye).Normalize()
+	if normal.Dot(view) > 0 {
+		normal = normal.Negate()
+	}
+	sharpness := dispersionMaxLobeSharp
+	if alpha := material.Roughness * material.Roughness; alpha > 0 {
+		sharpness = Clamp(2/(alpha*alpha), dispersionMinLobeSharp, dispersionMaxLobeSharp)
+	}
+
+	var result Color
+	for _, band := range tracer.bands {
+		ior := dispersionIOR(material.IOR, material.Dispersion, band.wavelength)
+		if ior <= 1 {
+			continue
+		}
+		dir, ok := refract(view, normal, 1/ior)
+		if !ok {
+			continue
+		}
+		throughput := 1 - fresnelDielectric(-view.Dot(normal), 1/ior)
+		origin := position.Add(dir.MulScalar(tracer.epsilon))
+		var radiance Color
+		tint := Color{1, 1, 1, 1}
+		for bounce := 0; bounce < dispersionMaxBounces && throughput > dispersionMinThroughput; bounce++ {
+			hit, ok := tracer.mesh.RayIntersect(origin, dir)
+			if !ok {
+				// Open or degenerate meshes let the ray out unrefracted
+				radiance = radiance.Add(tracer.radiance(shader, origin, dir, sharpness).Mul(tint).MulScalar(throughput))
+				break
+			}
+			tint = tint.Mul(absorption(material, hit.Distance))
+			inward := hit.Normal
+			if inward.Dot(dir) > 0 {
+				inward = inward.Negate()
+			}
+			reflected := dir.Reflect(inward)
+			if out, ok := refract(dir, inward, ior); ok {
+				f := fresnelDielectric(-dir.Dot(inward), ior)
+				radiance = radiance.Add(tracer.radiance(shader, hit.Position, out, sharpness).Mul(tint).MulScalar(throughput * (1 - f)))
+				throughput *= f
+			}
+			dir = reflected
+			origin = hit.Position.Add(dir.MulScalar(tracer.epsilon))
+		}
+		result = result.Add(radiance.Mul(band.weight))
+	}
+	result.A = 0
+	return result
+}
+
+// absorption returns the light left after a distance through the volume
+// of a material, see KHR_materials_volume
+func absorption(material *SampledMaterial, distance float64) Color {
+	if material.AttenuationDistance <= 0 || math.IsInf(material.AttenuationDistance, 0) {
+		return Color{1, 1, 1, 1}
+	}
+	c, d := material.AttenuationColor, distance/material.AttenuationDistance
+	return Color{math.Pow(c.R, d), math.Pow(c.G, d), math.Pow(c.B, d), 1}
+}
+
+// radiance returns the light arriving along a ray leaving the mesh at a
+// position in model space: the ambient light and a glossy highlight of
+// every light, whose sharpness comes from the roughness
+func (tracer *dispersionTracer) radiance(shader *PBRShader, position, dir Vector, sharpness float64) Color {
+	world := tracer.model.MulDirection(dir).Normalize()
+	result := shader.AmbientColor
+	for _, light := range shader.Lights {
+		var toLight Vector
+		switch light.Type {
+		case AmbientLight:
+			result = result.Add(light.Color.MulScalar(light.Intensity))
+			continue
+		case DirectionalLight:
+			toLight = light.Direction.Negate().Normalize()
+		default:
+			toLight = light.Position.Sub(tracer.model.MulPosition(position)).Normalize()
+		}
+		if lobe := world.Dot(toLight); lobe > 0 {
+			result = result.Add(light.Color.MulScalar(light.Intensity * math.Pow(lobe, sharpness)))
+		}
+	}
+	result.A = 0
+	return result
+}
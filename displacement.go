@@ -0,0 +1,135 @@
+package fauxgl
+
+import "math"
+
+// maxDisplaceDepth limits the recursion of adaptive tessellation
+const maxDisplaceDepth = 12
+
+// Displace returns a copy of the mesh with vertices moved along their normals
+// by the material's height map. The offset of a vertex is
+// (height - HeightMidpoint) * HeightScale * amount. Vertices sharing a
+// position are displaced together so UV seams and hard edges do not crack.
+// The mesh should be tessellated finely enough to resolve the height map,
+// see TessellateAndDisplace and DisplaceAdaptive.
+func (m *Mesh) Displace(material *PBRMaterial, amount float64) *Mesh {
+	result := m.Copy()
+	if material == nil || material.HeightTexture == nil || amount == 0 {
+		return result
+	}
+
+	scale := material.HeightScale * amount
+
+	// Accumulate direction and height per shared position
+	type displacement struct {
+		normal Vector
+		height float64
+		count  int
+	}
+	lookup := make(map[Vector]*displacement)
+	accumulate := func(v *Vertex) {
+		d, ok := lookup[v.Position]
+		if !ok {
+			d = &displacement{}
+			lookup[v.Position] = d
+		}
+		d.normal = d.normal.Add(v.Normal)
+		d.height += sampleHeight(material.HeightTexture, material.Swizzles.Height, v.Texture.X, v.Texture.Y)
+		d.count++
+	}
+	for _, t := range result.Triangles {
+		if t.V1.Normal == (Vector{}) || t.V2.Normal == (Vector{}) || t.V3.Normal == (Vector{}) {
+			t.FixNormals()
+		}
+		accumulate(&t.V1)
+		accumulate(&t.V2)
+		accumulate(&t.V3)
+	}
+
+	offsets := make(map[Vector]Vector, len(lookup))
+	for position, d := range lookup {
+		height := d.height/float64(d.count) - material.HeightMidpoint
+		offsets[position] = d.normal.Normalize().MulScalar(height * scale)
+	}
+
+	for _, t := range result.Triangles {
+		t.V1.Position = t.V1.Position.Add(offsets[t.V1.Position])
+		t.V2.Position = t.V2.Position.Add(offsets[t.V2.Position])
+		t.V3.Position = t.V3.Position.Add(offsets[t.V3.Position])
+		n := t.Normal()
+		t.V1.Normal, t.V2.Normal, t.V3.Normal = n, n, n
+	}
+
+	// Rebuild shading normals from the displaced surface, keeping creases
+	result.SmoothNormalsThreshold(Radians(60))
+	result.dirty()
+	return result
+}
+
+// TessellateAndDisplace splits triangles until no edge is longer than
+// maxEdgeLength and then applies the material's height map
+func (m *Mesh) TessellateAndDisplace(material *PBRMaterial, amount, maxEdgeLength float64) *Mesh {
+	return m.Tessellate(maxEdgeLength).Displace(material, amount)
+}
+
+// DisplaceAdaptive tessellates the mesh relative to the camera before
+// displacing it: triangles are split until each edge, divided by its distance
+// to eye, is no longer than edgeAngle (an approximate angular size in radians).
+// Close-up regions receive dense geometry while distant ones stay coarse.
+func (m *Mesh) DisplaceAdaptive(material *PBRMaterial, amount float64, eye Vector, edgeAngle float64) *Mesh {
+	var triangles []*Triangle
+
+	// The split decision depends only on the edge itself, so triangles sharing
+	// an edge always agree and the tessellation stays crack free
+	needsSplit := func(a, b Vector) bool {
+		distance := math.Max(a.Add(b).MulScalar(0.5).Distance(eye), 1e-6)
+		return a.Distance(b)/distance > edgeAngle
+	}
+
+	var split func(t *Triangle, depth int)
+	split = func(t *Triangle, depth int) {
+		p1 := t.V1.Position
+		p2 := t.V2.Position
+		p3 := t.V3.Position
+
+		// Pick the longest edge that fails the criterion
+		edge := -1
+		longest := 0.0
+		for i, e := range [3][2]Vector{{p1, p2}, {p2, p3}, {p3, p1}} {
+			if d := e[0].Distance(e[1]); d > longest && needsSplit(e[0], e[1]) {
+				edge, longest = i, d
+			}
+		}
+		if edge < 0 || depth >= maxDisplaceDepth {
+			triangles = append(triangles, t)
+			return
+		}
+
+		v1, v2, v3 := t.V1, t.V2, t.V3
+		switch edge {
+		case 0:
+			v := InterpolateVertexes(v1, v2, v3, VectorW{0.5, 0.5, 0, 1})
+			split(NewTriangle(v3, v1, v), depth+1)
+			split(NewTriangle(v2, v3, v), depth+1)
+		case 1:
+			v := InterpolateVertexes(v1, v2, v3, VectorW{0, 0.5, 0.5, 1})
+			split(NewTriangle(v1, v2, v), depth+1)
+			split(NewTriangle(v3, v1, v), depth+1)
+		default:
+			v := InterpolateVertexes(v1, v2, v3, VectorW{0.5, 0, 0.5, 1})
+			split(NewTriangle(v2, v3, v), depth+1)
+			split(NewTriangle(v1, v2, v), depth+1)
+		}
+	}
+
+	for _, t := range m.Triangles {
+		split(t, 0)
+	}
+
+	return NewTriangleMesh(triangles).Displace(material, amount)
+}
+
+// sampleHeight returns the luminance of a swizzled height texture
+func sampleHeight(texture Texture, swizzle Swizzle, u, v float64) float64 {
+	c := swizzle.Apply(texture.BilinearSample(u, v))
+	return c.R*0.299 + c.G*0.587 + c.B*0.114
+}
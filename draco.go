@@ -0,0 +1,166 @@
+package fauxgl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// DracoMesh is a decoded KHR_draco_mesh_compression primitive
+type DracoMesh struct {
+	// Indices lists the vertices of the triangles, three per triangle
+	Indices []uint32
+	// Attributes holds the dequantized vertex values of each attribute by
+	// its Draco unique ID
+	Attributes map[int]DracoAttribute
+}
+
+// DracoAttribute holds Components interleaved values per vertex
+type DracoAttribute struct {
+	Components int
+	Values     []float32
+}
+
+// DracoDecoder decodes the Draco bitstream of a compressed primitive
+type DracoDecoder func(data []byte) (*DracoMesh, error)
+
+var dracoDecoder DracoDecoder
+
+// SetDracoDecoder installs the decoder the glTF loader uses for primitives
+// compressed with KHR_draco_mesh_compression, such as a binding to the
+// reference Draco library; nil removes it. Set it before loading. Without a
+// decoder, compressed primitives load from their uncompressed fallback
+// data when the file has it, and fail to load otherwise.
+func SetDracoDecoder(decoder DracoDecoder) {
+	dracoDecoder = decoder
+}
+
+// gltfDraco is the KHR_draco_mesh_compression extension of a primitive
+type gltfDraco struct {
+	BufferView int            `json:"bufferView"`
+	Attributes map[string]int `json:"attributes"`
+}
+
+// decompressDraco decodes the Draco compressed primitives of the document
+// and rewrites them as ordinary accessors, so that the meshes load as if
+// they were uncompressed
+func (loader *GLTFLoader) decompressDraco() error {
+	for i, mesh := range loader.doc.Meshes {
+		for j, primitive := range mesh.Primitives {
+			ext, ok := primitive.Extensions["KHR_draco_mesh_compression"]
+			if !ok {
+				continue
+			}
+			if dracoDecoder == nil {
+				if loader.hasFallback(primitive) {
+					continue
+				}
+				return fmt.Errorf("primitive %d of mesh %d is Draco compressed, which needs a decoder, see SetDracoDecoder", j, i)
+			}
+			if err := loader.decompressDracoPrimitive(primitive, ext); err != nil {
+				return fmt.Errorf("failed to decode Draco primitive %d of mesh %d: %w", j, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// hasFallback reports whether the position accessor of a primitive has
+// data of its own
+func (loader *GLTFLoader) hasFallback(primitive *gltf.Primitive) bool {
+	index, ok := primitive.Attributes[gltf.POSITION]
+	return ok && index < len(loader.doc.Accessors) && loader.doc.Accessors[index].BufferView != nil
+}
+
+// decompressDracoPrimitive decodes one primitive and points its indices and
+// attributes at new accessors holding the decoded data
+func (loader *GLTFLoader) decompressDracoPrimitive(primitive *gltf.Primitive, ext interface{}) error {
+	data, ok := ext.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(ext); err != nil {
+			return err
+		}
+	}
+	var draco gltfDraco
+	if err := json.Unmarshal(data, &draco); err != nil {
+		return err
+	}
+	if draco.BufferView < 0 || draco.BufferView >= len(loader.doc.BufferViews) {
+		return fmt.Errorf("buffer view %d out of range", draco.BufferView)
+	}
+	compressed, err := modeler.ReadBufferView(loader.doc, loader.doc.BufferViews[draco.BufferView])
+	if err != nil {
+		return err
+	}
+	decoded, err := dracoDecoder(compressed)
+	if err != nil {
+		return err
+	}
+	if len(decoded.Indices)%3 != 0 {
+		return fmt.Errorf("%d indices do not form triangles", len(decoded.Indices))
+	}
+
+	doc := loader.doc
+	primitive.Indices = gltf.Index(modeler.WriteIndices(doc, decoded.Indices))
+	primitive.Mode = gltf.PrimitiveTriangles
+	for name, id := range draco.Attributes {
+		attribute, ok := decoded.Attributes[id]
+		if !ok {
+			return fmt.Errorf("attribute %s (Draco ID %d) missing from the decoded mesh", name, id)
+		}
+		values, err := dracoAccessorData(name, attribute)
+		if err != nil {
+			return err
+		}
+		primitive.Attributes[name] = modeler.WriteAccessor(doc, gltf.TargetArrayBuffer, values)
+	}
+	delete(primitive.Extensions, "KHR_draco_mesh_compression")
+	return nil
+}
+
+// dracoAccessorData converts decoded values to the slice type the glTF
+// readers expect for an attribute
+func dracoAccessorData(name string, attribute DracoAttribute) (interface{}, error) {
+	c, values := attribute.Components, attribute.Values
+	if c < 1 || c > 4 || len(values)%c != 0 {
+		return nil, fmt.Errorf("attribute %s has %d values of %d components", name, len(values), c)
+	}
+	n := len(values) / c
+	if strings.HasPrefix(name, "JOINTS_") {
+		if c != 4 {
+			return nil, fmt.Errorf("attribute %s has %d components", name, c)
+		}
+		joints := make([][4]uint16, n)
+		for i := range joints {
+			for k := 0; k < 4; k++ {
+				joints[i][k] = uint16(values[4*i+k])
+			}
+		}
+		return joints, nil
+	}
+	switch c {
+	case 1:
+		return values, nil
+	case 2:
+		out := make([][2]float32, n)
+		for i := range out {
+			copy(out[i][:], values[2*i:])
+		}
+		return out, nil
+	case 3:
+		out := make([][3]float32, n)
+		for i := range out {
+			copy(out[i][:], values[3*i:])
+		}
+		return out, nil
+	}
+	out := make([][4]float32, n)
+	for i := range out {
+		copy(out[i][:], values[4*i:])
+	}
+	return out, nil
+}
@@ -271,6 +271,7 @@ func renderWithShadows(context *fauxgl.Context, scene *fauxgl.Scene, light fauxg
 	cameraMatrix := scene.ActiveCamera.GetCameraMatrix()
 
 	// 获取所有可渲染节点
+	scene.UpdateTransforms()
 	renderables := scene.RootNode.GetRenderableNodes()
 
 	// 渲染每个节点（带阴影）
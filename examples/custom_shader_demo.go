@@ -197,6 +197,7 @@ func renderCustomShaderAnimation(context *fauxgl.Context, scene *fauxgl.Scene, c
 	cameraMatrix := camera.GetCameraMatrix()
 
 	// 获取所有可渲染节点
+	scene.UpdateTransforms()
 	renderables := scene.RootNode.GetRenderableNodes()
 
 	// 渲染多个动画帧
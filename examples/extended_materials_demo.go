@@ -75,7 +75,8 @@ func demonstrateExtendedMaterials(scene *fauxgl.Scene) {
 	emissiveMaterial := fauxgl.NewPBRMaterial()
 	emissiveMaterial.BaseColorFactor = fauxgl.Color{0.2, 0.2, 0.2, 1.0}
 	emissiveMaterial.EmissiveFactor = fauxgl.Color{1.0, 0.5, 0.0, 1.0}
-	emissiveMaterial.EmissiveStrength = 3.0 // KHR_materials_emissive_strength
+	emissiveStrength := 3.0
+	emissiveMaterial.EmissiveStrength = &emissiveStrength // KHR_materials_emissive_strength
 	fmt.Println("  ✅ Enhanced Emissive Strength: 3.0x intensity")
 
 	// 创建高折射率材质
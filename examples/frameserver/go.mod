@@ -0,0 +1,22 @@
+module github.com/swordkee/fauxgl-gltf/examples/frameserver
+
+go 1.20
+
+require (
+	github.com/swordkee/fauxgl-gltf v0.0.0
+	github.com/swordkee/fauxgl-gltf/frameserver v0.0.0
+)
+
+require (
+	github.com/gorilla/websocket v1.5.1 // indirect
+	github.com/klauspost/compress v1.17.4 // indirect
+	github.com/qmuntal/gltf v0.28.0 // indirect
+	golang.org/x/image v0.12.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+)
+
+replace (
+	github.com/swordkee/fauxgl-gltf => ../..
+	github.com/swordkee/fauxgl-gltf/frameserver => ../../frameserver
+)
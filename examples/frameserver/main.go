@@ -0,0 +1,84 @@
+// Frame server demo: renders a glTF scene on demand and streams the frames
+// to a browser over WebSocket.
+//
+//	cd examples/frameserver && go run . -addr :8080 ../gltf/mug.gltf
+//
+// Then open http://localhost:8080 and drag to orbit.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/swordkee/fauxgl-gltf"
+	"github.com/swordkee/fauxgl-gltf/frameserver"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<body style="background:#323232">
+<img id="frame" width="800" height="600" draggable="false">
+<script>
+const img = document.getElementById("frame");
+const ws = new WebSocket("ws://" + location.host + "/ws");
+ws.binaryType = "blob";
+ws.onmessage = (event) => {
+  if (typeof event.data === "string") {
+    console.error(JSON.parse(event.data).error);
+    return;
+  }
+  const url = URL.createObjectURL(event.data);
+  img.onload = () => URL.revokeObjectURL(url);
+  img.src = url;
+};
+let yaw = Math.PI / 2, pitch = Math.PI / 3, last = null;
+// Shade a quarter of the pixels while dragging, all of them once released
+img.onmousedown = (e) => {
+  last = [e.clientX, e.clientY];
+  ws.send(JSON.stringify({preview: "half"}));
+};
+window.onmouseup = () => {
+  if (last === null) return;
+  last = null;
+  ws.send(JSON.stringify({preview: "full"}));
+};
+window.onmousemove = (e) => {
+  if (last === null) return;
+  yaw += (e.clientX - last[0]) * 0.01;
+  pitch -= (e.clientY - last[1]) * 0.01;
+  last = [e.clientX, e.clientY];
+  ws.send(JSON.stringify({camera: {yaw: yaw, pitch: pitch}}));
+};
+</script>
+</body>
+</html>`
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		log.Fatal("usage: frameserver [-addr :8080] model.gltf")
+	}
+
+	scene, err := fauxgl.LoadGLTFScene(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", flag.Arg(0), err)
+	}
+	scene.RootNode.UpdateWorldTransform()
+	if len(scene.Lights) == 0 {
+		scene.AddAmbientLight(fauxgl.White, 0.2)
+		scene.AddDirectionalLight(fauxgl.V(-1, -1, -1), fauxgl.White, 3)
+	}
+
+	server := frameserver.New(scene)
+	server.Background = fauxgl.HexColor("#323232")
+
+	http.Handle("/ws", server)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
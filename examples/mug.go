@@ -72,7 +72,8 @@ func main() {
 	fmt.Printf("After transform center: %v\n", bounds.Center())
 
 	// 创建渲染上下文
-	context := fauxgl.NewContext(width*scale, height*scale)
+	context := fauxgl.NewContext(width, height)
+	context.SetSupersampling(scale)
 	context.ClearColor = fauxgl.White
 	context.ClearColorBuffer()
 
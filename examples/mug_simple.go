@@ -121,7 +121,8 @@ func renderScene(scene *fauxgl.Scene, light *fauxgl.Vector, filename string) {
 	fmt.Println("\n=== 渲染场景 ===")
 
 	// 创建渲染上下文
-	context := fauxgl.NewContext(width*scale, height*scale)
+	context := fauxgl.NewContext(width, height)
+	context.SetSupersampling(scale)
 	context.ClearColor = fauxgl.Color{0.05, 0.05, 0.05, 1.0} // 深色背景
 	context.ClearColorBuffer()
 	context.ClearDepthBuffer()
@@ -199,7 +199,8 @@ func renderScene(scene *fauxgl.Scene, filename, description string) {
 	fmt.Printf("  渲染场景: %s -> %s\n", description, filename)
 
 	// 创建渲染上下文
-	context := fauxgl.NewContext(width*scale, height*scale)
+	context := fauxgl.NewContext(width, height)
+	context.SetSupersampling(scale)
 	context.ClearColor = fauxgl.Color{0.1, 0.12, 0.15, 1.0} // 深色背景
 	context.ClearColorBuffer()
 	context.ClearDepthBuffer()
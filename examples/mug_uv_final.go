@@ -364,7 +364,8 @@ func renderHighQuality(scene *fauxgl.Scene, lightSystem *LightingSystem, filenam
 	fmt.Println("\n=== 多光源高质量渲染 ===")
 
 	// 创建渲染上下文
-	context := fauxgl.NewContext(width*scale, height*scale)
+	context := fauxgl.NewContext(width, height)
+	context.SetSupersampling(scale)
 	context.ClearColor = fauxgl.Color{0.05, 0.05, 0.05, 1.0} // 深色背景，增强对比度
 	context.ClearColorBuffer()
 	context.ClearDepthBuffer()
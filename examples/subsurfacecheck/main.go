@@ -0,0 +1,77 @@
+// Subsurface view check: renders a sphere lit from behind with and without
+// subsurface scattering from two cameras around it and compares the light
+// the scattering adds at the same point of the sphere. The translucency
+// lobe faces the camera looking into the light, so the scattering must
+// follow the camera; the program exits with status 1 when it doesn't.
+//
+//	go run ./examples/subsurfacecheck
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/swordkee/fauxgl-gltf"
+)
+
+const size = 128
+
+// point is the point of the sphere compared between the views, on the side
+// facing away from the light
+var point = fauxgl.Vector{X: 0.5, Y: 0, Z: 1}
+
+func main() {
+	front := fauxgl.Vector{X: 0.5, Y: 0, Z: 4}
+	side := fauxgl.Vector{X: 0.5 + 4*math.Sin(fauxgl.Radians(50)), Y: 0, Z: 4 * math.Cos(fauxgl.Radians(50))}
+
+	a := scattering(front)
+	b := scattering(side)
+	fmt.Printf("scattering seen from the front %.4f, from the side %.4f\n", a, b)
+	if a <= 0 || math.Abs(a-b) < 0.05*math.Max(a, b) {
+		fmt.Println("subsurface scattering doesn't follow the camera")
+		os.Exit(1)
+	}
+}
+
+// scattering returns the light subsurface scattering adds at point seen
+// from a camera position
+func scattering(eye fauxgl.Vector) float64 {
+	return luminance(render(eye, 1)) - luminance(render(eye, 0))
+}
+
+// render renders the sphere, placed by its node, from a camera position and
+// returns the color at point
+func render(eye fauxgl.Vector, subsurface float64) fauxgl.Color {
+	scene := fauxgl.NewScene("subsurface")
+	scene.AddMesh("sphere", fauxgl.NewSphere(4))
+
+	material := fauxgl.NewPBRMaterial()
+	material.BaseColorFactor = fauxgl.Color{R: 0.9, G: 0.6, B: 0.5, A: 1}
+	material.MetallicFactor = 0
+	material.RoughnessFactor = 1
+	material.SubsurfaceFactor = subsurface
+	material.SubsurfaceThickness = 0.2
+	scene.AddMaterial("skin", material)
+
+	// A transformed node, so the check also covers world space shading
+	node := scene.CreateMeshNode("sphere", "sphere", "skin")
+	node.SetTransform(fauxgl.Rotate(fauxgl.Vector{X: 0, Y: 1, Z: 0}, 1).Translate(fauxgl.Vector{X: 0.5, Y: 0, Z: 0}))
+	scene.RootNode.AddChild(node)
+	scene.AddPointLight(fauxgl.Vector{X: 0.5, Y: 0, Z: -4}, fauxgl.White, 40, 0)
+
+	camera := fauxgl.NewPerspectiveCamera("camera", eye, fauxgl.Vector{X: 0.5, Y: 0, Z: 0}, fauxgl.Vector{X: 0, Y: 1, Z: 0}, fauxgl.Radians(40), 1, 0.1, 100)
+	scene.ActiveCamera = camera
+	context := fauxgl.NewContext(size, size)
+	fauxgl.NewSceneRenderer(context).RenderScene(scene)
+
+	// The pixel of point
+	p := camera.GetProjectionMatrix().Mul(camera.GetViewMatrix()).MulPositionW(point)
+	x := int((p.X/p.W + 1) / 2 * size)
+	y := int((1 - p.Y/p.W) / 2 * size)
+	return fauxgl.MakeColor(context.Image().At(x, y))
+}
+
+func luminance(c fauxgl.Color) float64 {
+	return 0.2126*c.R + 0.7152*c.G + 0.0722*c.B
+}
@@ -0,0 +1,86 @@
+// Texture sampling benchmark: compares sampling a texture through
+// image.Image with the compact TexelImage formats.
+//
+//	go run ./examples/texturebench -size 4096 -samples 2000000
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/swordkee/fauxgl-gltf"
+)
+
+func main() {
+	size := flag.Int("size", 4096, "texture width and height")
+	samples := flag.Int("samples", 2000000, "lookups per measurement")
+	flag.Parse()
+
+	// Smooth color gradients with some detail, like a typical albedo map
+	img := image.NewNRGBA(image.Rect(0, 0, *size, *size))
+	for y := 0; y < *size; y++ {
+		for x := 0; x < *size; x++ {
+			u, v := float64(x)/float64(*size), float64(y)/float64(*size)
+			d := 0.5 + 0.5*math.Sin(u*40)*math.Sin(v*40)
+			img.SetNRGBA(x, y, color.NRGBA{uint8(255 * u), uint8(255 * d), uint8(255 * v), 255})
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	uvs := make([]fauxgl.Vector, *samples)
+	for i := range uvs {
+		uvs[i] = fauxgl.Vector{X: rnd.Float64(), Y: rnd.Float64()}
+	}
+	dx := fauxgl.Vector{X: 4 / float64(*size)}
+	dy := fauxgl.Vector{Y: 4 / float64(*size)}
+
+	reference := fauxgl.NewAdvancedTexture(img, fauxgl.BaseColorTexture)
+	fmt.Printf("%-10s %12s %12s %10s %10s\n", "storage", "bilinear", "trilinear", "MB", "max error")
+	for _, format := range []fauxgl.TexelFormat{-1, fauxgl.TexelRGBA8, fauxgl.TexelGray8, fauxgl.TexelBC1} {
+		texture := fauxgl.NewAdvancedTexture(img, fauxgl.BaseColorTexture)
+		texture.GenerateMipmaps()
+		name := "image"
+		if format >= 0 {
+			texture.Compact(format)
+			name = [...]string{"auto", "rgba8", "gray8", "bc1"}[format]
+		}
+
+		start := time.Now()
+		for _, uv := range uvs {
+			texture.Sample(uv.X, uv.Y)
+		}
+		bilinear := time.Since(start)
+
+		start = time.Now()
+		for _, uv := range uvs {
+			texture.SampleGrad(uv.X, uv.Y, dx, dy)
+		}
+		trilinear := time.Since(start)
+
+		var bytes int
+		for _, level := range texture.MipLevels {
+			if ti, ok := level.(*fauxgl.TexelImage); ok {
+				bytes += ti.Size()
+			} else {
+				bytes += len(level.(*image.NRGBA).Pix)
+			}
+		}
+
+		var maxError float64
+		for _, uv := range uvs[:10000] {
+			a := texture.Sample(uv.X, uv.Y)
+			b := reference.Sample(uv.X, uv.Y)
+			maxError = math.Max(maxError, math.Max(math.Abs(a.R-b.R), math.Max(math.Abs(a.G-b.G), math.Abs(a.B-b.B))))
+		}
+
+		fmt.Printf("%-10s %9.1f ns %9.1f ns %10.1f %10.3f\n", name,
+			float64(bilinear.Nanoseconds())/float64(len(uvs)),
+			float64(trilinear.Nanoseconds())/float64(len(uvs)),
+			float64(bytes)/(1<<20), maxError)
+	}
+}
@@ -0,0 +1,313 @@
+//go:build viewer
+
+// Interactive glTF viewer.
+//
+// The viewer is its own module, so the library doesn't depend on ebiten.
+// Build it from this directory with the viewer tag (requires the ebiten
+// system dependencies):
+//
+//	cd examples/viewer
+//	go run -tags viewer . model.gltf
+//
+// Controls:
+//
+//	left drag      orbit
+//	mouse wheel    zoom
+//	[ / ]          select previous / next material
+//	R / F          roughness up / down
+//	M / N          metallic up / down
+//	Q              toggle draft / preview quality
+//	V              cycle the preview mode used while orbiting and zooming
+//	P              save a screenshot
+//
+// The model is reloaded when it or its buffers and images change on disk,
+// keeping the camera and the material tweaks.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/swordkee/fauxgl-gltf"
+)
+
+const (
+	fovy = 30
+	near = 0.1
+	far  = 100
+)
+
+type viewer struct {
+	width, height int
+
+	scene     *fauxgl.Scene
+	bounds    fauxgl.Box
+	camera    *fauxgl.OrbitCamera
+	quality   fauxgl.RenderQuality
+	preview   fauxgl.PreviewMode
+	materials []string
+	selected  int
+	reloader  *fauxgl.SceneReloader
+	tweaks    map[string]materialTweak
+
+	frame      *image.RGBA
+	dirty      bool
+	refine     bool // The frame is a preview, to render in full once idle
+	lastCursor image.Point
+	dragging   bool
+	renderTime time.Duration
+	status     string
+}
+
+// materialTweak holds the factors of a material changed in the viewer,
+// reapplied when the model is reloaded
+type materialTweak struct {
+	roughness, metallic float64
+}
+
+func newViewer(scene *fauxgl.Scene, width, height int) *viewer {
+	// Frame the scene bounds
+	box := scene.GetBounds()
+	center := box.Center()
+	distance := box.Size().Length() / (2 * math.Tan(fauxgl.Radians(fovy)/2))
+	if distance == 0 || math.IsNaN(distance) || math.IsInf(distance, 0) {
+		center = fauxgl.Vector{}
+		distance = 5
+	}
+
+	camera := fauxgl.NewOrbitCamera("viewer", center, distance, fauxgl.Radians(fovy),
+		float64(width)/float64(height), near, far)
+	camera.HorizontalAngle = math.Pi / 2
+	camera.VerticalAngle = math.Pi / 3
+	camera.Update()
+	scene.ActiveCamera = camera.Camera
+
+	v := &viewer{
+		width:   width,
+		height:  height,
+		scene:   scene,
+		bounds:  box,
+		camera:  camera,
+		quality: fauxgl.QualityDraft,
+		preview: fauxgl.PreviewHalf,
+		tweaks:  make(map[string]materialTweak),
+		dirty:   true,
+	}
+	v.listMaterials()
+	return v
+}
+
+func (v *viewer) listMaterials() {
+	v.materials = v.materials[:0]
+	for name := range v.scene.Materials {
+		v.materials = append(v.materials, name)
+	}
+	sort.Strings(v.materials)
+	if v.selected >= len(v.materials) {
+		v.selected = 0
+	}
+}
+
+// applyTweaks reapplies the material tweaks to a reloaded scene
+func (v *viewer) applyTweaks(scene *fauxgl.Scene) {
+	for name, tweak := range v.tweaks {
+		if material := scene.Materials[name]; material != nil {
+			material.RoughnessFactor = tweak.roughness
+			material.MetallicFactor = tweak.metallic
+		}
+	}
+}
+
+func (v *viewer) selectedMaterial() *fauxgl.PBRMaterial {
+	if len(v.materials) == 0 {
+		return nil
+	}
+	return v.scene.Materials[v.materials[v.selected]]
+}
+
+func (v *viewer) Update() error {
+	// Orbit
+	interactive := false
+	x, y := ebiten.CursorPosition()
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		if v.dragging {
+			dx := float64(x - v.lastCursor.X)
+			dy := float64(y - v.lastCursor.Y)
+			if dx != 0 || dy != 0 {
+				v.camera.Rotate(dx*0.01, -dy*0.01)
+				v.dirty = true
+				interactive = true
+			}
+		}
+		v.dragging = true
+	} else {
+		v.dragging = false
+	}
+	v.lastCursor = image.Point{x, y}
+
+	// Zoom
+	if _, wheel := ebiten.Wheel(); wheel != 0 {
+		v.camera.Zoom(-wheel * v.camera.Distance * 0.1)
+		v.dirty = true
+		interactive = true
+	}
+
+	// Material selection and tweaking
+	if len(v.materials) > 0 {
+		if inpututil.IsKeyJustPressed(ebiten.KeyBracketRight) {
+			v.selected = (v.selected + 1) % len(v.materials)
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft) {
+			v.selected = (v.selected + len(v.materials) - 1) % len(v.materials)
+		}
+		material := v.selectedMaterial()
+		tweak := func(key ebiten.Key, value *float64, delta float64) {
+			if inpututil.IsKeyJustPressed(key) {
+				*value = fauxgl.Clamp(*value+delta, 0, 1)
+				v.tweaks[v.materials[v.selected]] = materialTweak{material.RoughnessFactor, material.MetallicFactor}
+				v.dirty = true
+			}
+		}
+		tweak(ebiten.KeyR, &material.RoughnessFactor, 0.05)
+		tweak(ebiten.KeyF, &material.RoughnessFactor, -0.05)
+		tweak(ebiten.KeyM, &material.MetallicFactor, 0.05)
+		tweak(ebiten.KeyN, &material.MetallicFactor, -0.05)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+		if v.quality == fauxgl.QualityDraft {
+			v.quality = fauxgl.QualityPreview
+		} else {
+			v.quality = fauxgl.QualityDraft
+		}
+		v.dirty = true
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyV) {
+		v.preview = (v.preview + 1) % (fauxgl.PreviewCheckerboard + 1)
+		v.status = "preview " + v.preview.String()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) && v.frame != nil {
+		path := fmt.Sprintf("screenshot-%s.png", time.Now().Format("20060102-150405"))
+		if err := fauxgl.SavePNG(path, v.frame); err != nil {
+			v.status = err.Error()
+		} else {
+			v.status = "saved " + path
+		}
+	}
+
+	if v.reloader != nil {
+		reloaded, err := v.reloader.Poll()
+		if err != nil {
+			v.status = err.Error()
+		} else if reloaded {
+			v.status = "reloaded " + v.reloader.Path
+		}
+		if reloaded {
+			v.scene.RootNode.UpdateWorldTransform()
+			v.bounds = v.scene.GetBounds()
+			v.listMaterials()
+			v.dirty = true
+		}
+	}
+
+	// Orbiting and zooming render previews, refined once the input stops
+	if v.dirty {
+		mode := fauxgl.PreviewFull
+		if interactive {
+			mode = v.preview
+		}
+		v.render(mode)
+		v.dirty = false
+		v.refine = mode != fauxgl.PreviewFull
+	} else if v.refine {
+		v.render(fauxgl.PreviewFull)
+		v.refine = false
+	}
+	return nil
+}
+
+func (v *viewer) render(mode fauxgl.PreviewMode) {
+	start := time.Now()
+
+	settings := v.quality.Settings()
+	settings.ApplyToScene(v.scene)
+	context := settings.NewContext(v.width, v.height)
+
+	v.scene.ActiveCamera.AspectRatio = float64(v.width) / float64(v.height)
+	v.scene.ActiveCamera.FitDepthRange(v.bounds, 0.05)
+	renderer := fauxgl.NewSceneRenderer(context)
+	fauxgl.NewPreviewRenderer(mode).Render(context, func() {
+		context.ClearColorBufferWith(fauxgl.HexColor("#323232"))
+		context.ClearDepthBuffer()
+		renderer.RenderScene(v.scene)
+	})
+
+	// ebiten expects premultiplied RGBA
+	resolved := settings.Resolve(context)
+	frame := image.NewRGBA(resolved.Bounds())
+	draw.Draw(frame, frame.Bounds(), resolved, image.Point{}, draw.Src)
+	v.frame = frame
+
+	v.renderTime = time.Since(start)
+}
+
+func (v *viewer) Draw(screen *ebiten.Image) {
+	if v.frame != nil {
+		screen.WritePixels(v.frame.Pix)
+	}
+
+	info := fmt.Sprintf("%s  %.0f ms", v.quality, float64(v.renderTime.Microseconds())/1000)
+	if material := v.selectedMaterial(); material != nil {
+		info += fmt.Sprintf("\n%s  roughness %.2f  metallic %.2f",
+			v.materials[v.selected], material.RoughnessFactor, material.MetallicFactor)
+	}
+	if v.status != "" {
+		info += "\n" + v.status
+	}
+	ebitenutil.DebugPrint(screen, info)
+}
+
+func (v *viewer) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return v.width, v.height
+}
+
+func main() {
+	width := flag.Int("width", 800, "window width")
+	height := flag.Int("height", 600, "window height")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		log.Fatal("usage: viewer [-width w] [-height h] model.gltf")
+	}
+
+	scene, err := fauxgl.LoadGLTFScene(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", flag.Arg(0), err)
+	}
+	scene.RootNode.UpdateWorldTransform()
+	if len(scene.Lights) == 0 {
+		scene.AddAmbientLight(fauxgl.White, 0.2)
+		scene.AddDirectionalLight(fauxgl.V(-1, -1, -1), fauxgl.White, 3)
+	}
+
+	ebiten.SetWindowSize(*width, *height)
+	ebiten.SetWindowTitle("fauxgl viewer - " + flag.Arg(0))
+	v := newViewer(scene, *width, *height)
+	if v.reloader, err = fauxgl.NewSceneReloader(scene, flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+	v.reloader.Apply = v.applyTweaks
+	if err := ebiten.RunGame(v); err != nil {
+		log.Fatal(err)
+	}
+}
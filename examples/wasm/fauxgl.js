@@ -0,0 +1,45 @@
+// Promise based wrapper around the fauxgl WebAssembly module.
+//
+//   const renderer = await loadFauxGL("fauxgl.wasm");
+//   const scene = renderer.loadScene(new Uint8Array(await file.arrayBuffer()));
+//   renderer.renderToCanvas(canvas, scene, { yaw: 1.2, pitch: 1.0, quality: "preview" });
+//
+// wasm_exec.js (shipped with Go) must be loaded before this file.
+
+export async function loadFauxGL(wasmURL) {
+  const go = new Go();
+  const result = await WebAssembly.instantiateStreaming(fetch(wasmURL), go.importObject);
+  go.run(result.instance);
+
+  const check = (result) => {
+    if (result && result.error) {
+      throw new Error(result.error);
+    }
+    return result;
+  };
+
+  return {
+    // loadScene parses a GLB or self-contained glTF and returns a scene handle
+    loadScene(bytes) {
+      return check(globalThis.fauxgl.loadScene(bytes)).id;
+    },
+
+    // freeScene releases a scene handle
+    freeScene(scene) {
+      globalThis.fauxgl.freeScene(scene);
+    },
+
+    // render returns the frame as an ImageData
+    render(scene, width, height, options = {}) {
+      const frame = check(globalThis.fauxgl.render(scene, width, height, options));
+      return new ImageData(frame.pixels, frame.width, frame.height);
+    },
+
+    // renderToCanvas renders at the canvas size and draws the frame into it
+    renderToCanvas(canvas, scene, options = {}) {
+      const image = this.render(scene, canvas.width, canvas.height, options);
+      canvas.getContext("2d").putImageData(image, 0, 0);
+      return image;
+    },
+  };
+}
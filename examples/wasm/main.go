@@ -0,0 +1,152 @@
+//go:build js && wasm
+
+// WebAssembly bindings for in-browser previews.
+//
+// Build:
+//
+//	GOOS=js GOARCH=wasm go build -o fauxgl.wasm ./examples/wasm
+//	cp "$(go env GOROOT)/misc/wasm/wasm_exec.js" .
+//
+// The module registers a global fauxgl object; see fauxgl.js for the
+// promise based wrapper and index.html for a drop-a-file demo.
+package main
+
+import (
+	"fmt"
+	"math"
+	"syscall/js"
+
+	"github.com/swordkee/fauxgl-gltf"
+)
+
+const (
+	fovy = 30
+	near = 0.1
+	far  = 100
+)
+
+var (
+	scenes    = make(map[int]*fauxgl.Scene)
+	nextScene = 1
+)
+
+// loadScene(bytes Uint8Array) -> {id} | {error}
+func loadScene(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return errorResult(fmt.Errorf("loadScene expects a Uint8Array"))
+	}
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+
+	// Browsers have no file system, so only self-contained files (GLB or
+	// glTF with data URIs) can be loaded
+	scene, err := fauxgl.LoadGLTFSceneFromBytes(data, nil)
+	if err != nil {
+		return errorResult(err)
+	}
+	scene.RootNode.UpdateWorldTransform()
+	if len(scene.Lights) == 0 {
+		scene.AddAmbientLight(fauxgl.White, 0.2)
+		scene.AddDirectionalLight(fauxgl.V(-1, -1, -1), fauxgl.White, 3)
+	}
+
+	id := nextScene
+	nextScene++
+	scenes[id] = scene
+	return map[string]interface{}{"id": id}
+}
+
+// freeScene(id)
+func freeScene(this js.Value, args []js.Value) interface{} {
+	if len(args) > 0 {
+		delete(scenes, args[0].Int())
+	}
+	return nil
+}
+
+// render(id, width, height, options) -> {width, height, pixels Uint8ClampedArray} | {error}
+//
+// options: {yaw, pitch (radians), zoom (distance multiplier), quality ("draft", "preview", "production")}
+func render(this js.Value, args []js.Value) interface{} {
+	if len(args) < 3 {
+		return errorResult(fmt.Errorf("render expects id, width and height"))
+	}
+	scene, ok := scenes[args[0].Int()]
+	if !ok {
+		return errorResult(fmt.Errorf("unknown scene %d", args[0].Int()))
+	}
+	width, height := args[1].Int(), args[2].Int()
+	if width <= 0 || height <= 0 {
+		return errorResult(fmt.Errorf("invalid size %dx%d", width, height))
+	}
+
+	yaw, pitch, zoom := math.Pi/2, math.Pi/3, 1.0
+	quality := fauxgl.QualityDraft
+	if len(args) > 3 && args[3].Type() == js.TypeObject {
+		options := args[3]
+		if v := options.Get("yaw"); v.Type() == js.TypeNumber {
+			yaw = v.Float()
+		}
+		if v := options.Get("pitch"); v.Type() == js.TypeNumber {
+			pitch = v.Float()
+		}
+		if v := options.Get("zoom"); v.Type() == js.TypeNumber && v.Float() > 0 {
+			zoom = v.Float()
+		}
+		if v := options.Get("quality"); v.Type() == js.TypeString {
+			q, err := fauxgl.ParseRenderQuality(v.String())
+			if err != nil {
+				return errorResult(err)
+			}
+			quality = q
+		}
+	}
+
+	// Frame the scene bounds
+	box := scene.GetBounds()
+	center := box.Center()
+	distance := box.Size().Length() / (2 * math.Tan(fauxgl.Radians(fovy)/2))
+	if distance == 0 || math.IsNaN(distance) || math.IsInf(distance, 0) {
+		center = fauxgl.Vector{}
+		distance = 5
+	}
+	camera := fauxgl.NewOrbitCamera("preview", center, distance*zoom, fauxgl.Radians(fovy),
+		float64(width)/float64(height), near, far)
+	camera.HorizontalAngle = yaw
+	camera.VerticalAngle = pitch
+	camera.Update()
+	camera.FitDepthRange(box, 0.05)
+	scene.ActiveCamera = camera.Camera
+
+	settings := quality.Settings()
+	settings.ApplyToScene(scene)
+	context := settings.NewContext(width, height)
+	context.ClearColorBufferWith(fauxgl.Transparent)
+	context.ClearDepthBuffer()
+	fauxgl.NewSceneRenderer(context).RenderScene(scene)
+	frame := settings.Resolve(context)
+
+	// ImageData expects straight (non-premultiplied) RGBA, which is NRGBA
+	pixels := js.Global().Get("Uint8ClampedArray").New(len(frame.Pix))
+	js.CopyBytesToJS(pixels, frame.Pix)
+	return map[string]interface{}{
+		"width":  frame.Bounds().Dx(),
+		"height": frame.Bounds().Dy(),
+		"pixels": pixels,
+	}
+}
+
+func errorResult(err error) interface{} {
+	return map[string]interface{}{"error": err.Error()}
+}
+
+func main() {
+	js.Global().Set("fauxgl", js.ValueOf(map[string]interface{}{
+		"loadScene": js.FuncOf(loadScene),
+		"freeScene": js.FuncOf(freeScene),
+		"render":    js.FuncOf(render),
+	}))
+
+	// Keep the module alive for callbacks
+	select {}
+}
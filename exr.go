@@ -0,0 +1,567 @@
+package fauxgl
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// exrMagic starts every OpenEXR file
+const exrMagic = "v/1\x01"
+
+// OpenEXR pixel types
+const (
+	exrUint  = 0
+	exrHalf  = 1
+	exrFloat = 2
+)
+
+// OpenEXR compressions
+const (
+	exrNoCompression   = 0
+	exrRLECompression  = 1
+	exrZIPSCompression = 2
+	exrZIPCompression  = 3
+)
+
+func init() {
+	// Let image.Decode, LoadImage and the glTF loader read OpenEXR files
+	// into HDR images
+	image.RegisterFormat("exr", exrMagic, func(r io.Reader) (image.Image, error) {
+		return DecodeEXR(r)
+	}, decodeEXRConfig)
+}
+
+// LoadEXR loads an OpenEXR (.exr) image, e.g. a panorama for
+// NewEquirectangularBackground
+func LoadEXR(path string) (*HDRImage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodeEXR(file)
+}
+
+// SaveEXR saves an image as an OpenEXR (.exr) file, e.g. the HDR buffer of
+// a context from Context.ResolveHDR for compositing
+func SaveEXR(path string, im image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := EncodeEXR(file, im); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// exrChannel is a channel of an OpenEXR image
+type exrChannel struct {
+	name      string
+	pixelType int32
+	xSampling int32
+	ySampling int32
+}
+
+// size returns the bytes of a value of the channel
+func (c exrChannel) size() int {
+	if c.pixelType == exrHalf {
+		return 2
+	}
+	return 4
+}
+
+// exrHeader holds the attributes of an OpenEXR image that decoding needs
+type exrHeader struct {
+	channels    []exrChannel
+	compression byte
+	dataWindow  [4]int32 // xMin, yMin, xMax, yMax
+}
+
+func (h *exrHeader) width() int {
+	return int(h.dataWindow[2]-h.dataWindow[0]) + 1
+}
+
+func (h *exrHeader) height() int {
+	return int(h.dataWindow[3]-h.dataWindow[1]) + 1
+}
+
+// linesPerBlock returns the scanlines compressed together
+func (h *exrHeader) linesPerBlock() int {
+	if h.compression == exrZIPCompression {
+		return 16
+	}
+	return 1
+}
+
+// readEXRHeader parses the header of an OpenEXR file and returns it with
+// the offset of the data after it
+func readEXRHeader(data []byte) (*exrHeader, int, error) {
+	if len(data) < 8 || string(data[:4]) != exrMagic {
+		return nil, 0, errors.New("invalid EXR file")
+	}
+	version := binary.LittleEndian.Uint32(data[4:])
+	switch {
+	case version&0xff != 2:
+		return nil, 0, fmt.Errorf("unsupported EXR version: %d", version&0xff)
+	case version&0x200 != 0:
+		return nil, 0, errors.New("tiled EXR files are not supported")
+	case version&0x1800 != 0:
+		return nil, 0, errors.New("deep and multipart EXR files are not supported")
+	}
+
+	pos := 8
+	readString := func() (string, error) {
+		end := bytes.IndexByte(data[pos:], 0)
+		if end < 0 {
+			return "", errors.New("truncated EXR header")
+		}
+		s := string(data[pos : pos+end])
+		pos += end + 1
+		return s, nil
+	}
+	header := &exrHeader{}
+	window := false
+	for {
+		name, err := readString()
+		if err != nil {
+			return nil, 0, err
+		}
+		if name == "" {
+			break
+		}
+		kind, err := readString()
+		if err != nil {
+			return nil, 0, err
+		}
+		if pos+4 > len(data) {
+			return nil, 0, errors.New("truncated EXR header")
+		}
+		size := int(int32(binary.LittleEndian.Uint32(data[pos:])))
+		pos += 4
+		if size < 0 || pos+size > len(data) {
+			return nil, 0, errors.New("truncated EXR header")
+		}
+		value := data[pos : pos+size]
+		pos += size
+
+		switch {
+		case name == "channels" && kind == "chlist":
+			for len(value) > 0 && value[0] != 0 {
+				end := bytes.IndexByte(value, 0)
+				if end < 0 || len(value) < end+17 {
+					return nil, 0, errors.New("invalid EXR channel list")
+				}
+				v := value[end+1:]
+				header.channels = append(header.channels, exrChannel{
+					name:      string(value[:end]),
+					pixelType: int32(binary.LittleEndian.Uint32(v)),
+					xSampling: int32(binary.LittleEndian.Uint32(v[8:])),
+					ySampling: int32(binary.LittleEndian.Uint32(v[12:])),
+				})
+				value = v[16:]
+			}
+		case name == "compression" && size == 1:
+			header.compression = value[0]
+		case name == "dataWindow" && kind == "box2i" && size == 16:
+			for i := range header.dataWindow {
+				header.dataWindow[i] = int32(binary.LittleEndian.Uint32(value[i*4:]))
+			}
+			window = true
+		}
+	}
+
+	if !window || header.width() <= 0 || header.height() <= 0 {
+		return nil, 0, errors.New("invalid EXR data window")
+	}
+	if len(header.channels) == 0 {
+		return nil, 0, errors.New("EXR file has no channels")
+	}
+	for _, c := range header.channels {
+		if c.pixelType < exrUint || c.pixelType > exrFloat {
+			return nil, 0, fmt.Errorf("unsupported EXR pixel type: %d", c.pixelType)
+		}
+		if c.xSampling != 1 || c.ySampling != 1 {
+			return nil, 0, fmt.Errorf("subsampled EXR channel %s is not supported", c.name)
+		}
+	}
+	if header.compression > exrZIPCompression {
+		return nil, 0, fmt.Errorf("unsupported EXR compression: %d", header.compression)
+	}
+	return header, pos, nil
+}
+
+func decodeEXRConfig(r io.Reader) (image.Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	header, _, err := readEXRHeader(data)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: header.width(), Height: header.height()}, nil
+}
+
+// DecodeEXR decodes a scanline OpenEXR image without compression or with
+// RLE, ZIPS or ZIP compression. It reads the R, G, B and A channels, of the
+// first layer when the file only has layers, or Y as gray; the image
+// covers the data window, with its top left at the origin. Alpha is
+// converted from the premultiplied alpha of EXR to straight alpha.
+func DecodeEXR(r io.Reader) (*HDRImage, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	header, pos, err := readEXRHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	width, height := header.width(), header.height()
+	lines := header.linesPerBlock()
+	blocks := (height + lines - 1) / lines
+	if pos+blocks*8 > len(data) {
+		return nil, errors.New("truncated EXR offset table")
+	}
+
+	// The channel feeding every component, -1 for none
+	roles := [5]string{"R", "G", "B", "A", "Y"}
+	sources := [5]int{-1, -1, -1, -1, -1}
+	for i, role := range roles {
+		sources[i] = exrFindChannel(header.channels, role)
+	}
+	offsets := make([]int, len(header.channels))
+	lineSize := 0
+	for i, c := range header.channels {
+		offsets[i] = lineSize * width
+		lineSize += c.size()
+	}
+	lineSize *= width
+
+	im := NewHDRImage(image.Rect(0, 0, width, height))
+	for block := 0; block < blocks; block++ {
+		offset := int(binary.LittleEndian.Uint64(data[pos+block*8:]))
+		if offset < 0 || offset+8 > len(data) {
+			return nil, fmt.Errorf("invalid EXR block offset %d", block)
+		}
+		y0 := int(int32(binary.LittleEndian.Uint32(data[offset:]))) - int(header.dataWindow[1])
+		size := int(int32(binary.LittleEndian.Uint32(data[offset+4:])))
+		if y0 < 0 || y0 >= height || size < 0 || offset+8+size > len(data) {
+			return nil, fmt.Errorf("invalid EXR block %d", block)
+		}
+		n := lines
+		if y0+n > height {
+			n = height - y0
+		}
+		pixels, err := exrDecompress(header.compression, data[offset+8:offset+8+size], n*lineSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress EXR block %d: %w", block, err)
+		}
+
+		for line := 0; line < n; line++ {
+			row := pixels[line*lineSize:]
+			for x := 0; x < width; x++ {
+				var values [5]float64
+				for i, source := range sources {
+					if source >= 0 {
+						c := header.channels[source]
+						values[i] = exrValue(row[offsets[source]+x*c.size():], c.pixelType)
+					}
+				}
+				c := Color{values[0], values[1], values[2], 1}
+				if sources[0] < 0 && sources[1] < 0 && sources[2] < 0 {
+					c.R, c.G, c.B = values[4], values[4], values[4]
+				}
+				if sources[3] >= 0 {
+					c.A = values[3]
+					c = c.Unpremultiply()
+				}
+				im.SetColor(x, y0+line, c)
+			}
+		}
+	}
+	return im, nil
+}
+
+// exrFindChannel returns the index of the channel of a name, else of the
+// first layer with it, or -1
+func exrFindChannel(channels []exrChannel, name string) int {
+	layer := -1
+	for i, c := range channels {
+		if c.name == name {
+			return i
+		}
+		if layer < 0 && strings.HasSuffix(c.name, "."+name) {
+			layer = i
+		}
+	}
+	return layer
+}
+
+// exrValue reads a little endian value of a pixel type
+func exrValue(b []byte, pixelType int32) float64 {
+	switch pixelType {
+	case exrHalf:
+		return float64(halfToFloat(binary.LittleEndian.Uint16(b)))
+	case exrFloat:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+	return float64(binary.LittleEndian.Uint32(b))
+}
+
+// exrDecompress decompresses a block of scanlines of a size. Blocks that
+// didn't shrink are stored uncompressed.
+func exrDecompress(compression byte, data []byte, size int) ([]byte, error) {
+	if compression == exrNoCompression || len(data) == size {
+		if len(data) != size {
+			return nil, errors.New("block size mismatch")
+		}
+		return data, nil
+	}
+	var t []byte
+	switch compression {
+	case exrRLECompression:
+		for i := 0; i < len(data); {
+			count := int(int8(data[i]))
+			i++
+			if count < 0 {
+				if i-count > len(data) {
+					return nil, errors.New("truncated literal")
+				}
+				t = append(t, data[i:i-count]...)
+				i -= count
+				continue
+			}
+			if i >= len(data) {
+				return nil, errors.New("truncated run")
+			}
+			for k := 0; k <= count; k++ {
+				t = append(t, data[i])
+			}
+			i++
+		}
+	default:
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		t, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(t) != size {
+		return nil, errors.New("block size mismatch")
+	}
+
+	// Undo the byte delta predictor, then interleave the two halves
+	for i := 1; i < len(t); i++ {
+		t[i] = byte(int(t[i-1]) + int(t[i]) - 128)
+	}
+	out := make([]byte, len(t))
+	half := (len(t) + 1) / 2
+	for i := range out {
+		if i%2 == 0 {
+			out[i] = t[i/2]
+		} else {
+			out[i] = t[half+i/2]
+		}
+	}
+	return out, nil
+}
+
+// EncodeEXR encodes an image as a ZIP compressed scanline OpenEXR image of
+// half float R, G, B and A channels, with premultiplied alpha. 8-bit
+// images are written as is, without linearizing them.
+func EncodeEXR(w io.Writer, im image.Image) error {
+	hdr, ok := im.(*HDRImage)
+	if !ok {
+		hdr = NewHDRImageFrom(toNRGBA(im))
+	}
+	width, height := hdr.Rect.Dx(), hdr.Rect.Dy()
+	if width == 0 || height == 0 {
+		return errors.New("empty image")
+	}
+
+	var header bytes.Buffer
+	header.WriteString(exrMagic)
+	binary.Write(&header, binary.LittleEndian, uint32(2))
+	attribute := func(name, kind string, value []byte) {
+		header.WriteString(name)
+		header.WriteByte(0)
+		header.WriteString(kind)
+		header.WriteByte(0)
+		binary.Write(&header, binary.LittleEndian, int32(len(value)))
+		header.Write(value)
+	}
+	le := func(values ...interface{}) []byte {
+		var b bytes.Buffer
+		for _, v := range values {
+			binary.Write(&b, binary.LittleEndian, v)
+		}
+		return b.Bytes()
+	}
+
+	// Channels are stored in alphabetical order
+	names := []string{"A", "B", "G", "R"}
+	var channels bytes.Buffer
+	for _, name := range names {
+		channels.WriteString(name)
+		channels.WriteByte(0)
+		channels.Write(le(int32(exrHalf), uint8(0), [3]uint8{}, int32(1), int32(1)))
+	}
+	channels.WriteByte(0)
+	window := le(int32(0), int32(0), int32(width-1), int32(height-1))
+	attribute("channels", "chlist", channels.Bytes())
+	attribute("compression", "compression", []byte{exrZIPCompression})
+	attribute("dataWindow", "box2i", window)
+	attribute("displayWindow", "box2i", window)
+	attribute("lineOrder", "lineOrder", []byte{0})
+	attribute("pixelAspectRatio", "float", le(float32(1)))
+	attribute("screenWindowCenter", "v2f", le(float32(0), float32(0)))
+	attribute("screenWindowWidth", "float", le(float32(1)))
+	header.WriteByte(0)
+
+	// Compress the blocks, then write them after the offset table
+	lines := 16
+	blocks := (height + lines - 1) / lines
+	compressed := make([][]byte, blocks)
+	lineSize := width * len(names) * 2
+	for block := range compressed {
+		y0 := block * lines
+		n := lines
+		if y0+n > height {
+			n = height - y0
+		}
+		raw := make([]byte, n*lineSize)
+		for line := 0; line < n; line++ {
+			row := raw[line*lineSize:]
+			for x := 0; x < width; x++ {
+				c := hdr.ColorAt(x+hdr.Rect.Min.X, y0+line+hdr.Rect.Min.Y).Premultiply()
+				values := [4]float64{c.A, c.B, c.G, c.R}
+				for i, v := range values {
+					binary.LittleEndian.PutUint16(row[(i*width+x)*2:], floatToHalf(float32(v)))
+				}
+			}
+		}
+		data, err := exrCompress(raw)
+		if err != nil {
+			return err
+		}
+		compressed[block] = data
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header.Bytes())
+	offset := header.Len() + blocks*8
+	for _, data := range compressed {
+		binary.Write(bw, binary.LittleEndian, uint64(offset))
+		offset += 8 + len(data)
+	}
+	for block, data := range compressed {
+		binary.Write(bw, binary.LittleEndian, int32(block*lines))
+		binary.Write(bw, binary.LittleEndian, int32(len(data)))
+		bw.Write(data)
+	}
+	return bw.Flush()
+}
+
+// exrCompress ZIP compresses a block of scanlines, or returns it as is
+// when that doesn't shrink it
+func exrCompress(raw []byte) ([]byte, error) {
+	// Split the even and odd bytes into halves, then delta encode
+	t := make([]byte, len(raw))
+	half := (len(raw) + 1) / 2
+	for i, b := range raw {
+		if i%2 == 0 {
+			t[i/2] = b
+		} else {
+			t[half+i/2] = b
+		}
+	}
+	for i := len(t) - 1; i > 0; i-- {
+		t[i] = byte(int(t[i]) - int(t[i-1]) + 128)
+	}
+
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	if _, err := zw.Write(t); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if b.Len() >= len(raw) {
+		return raw, nil
+	}
+	return b.Bytes(), nil
+}
+
+// halfToFloat converts an IEEE 754 half precision float
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exponent := uint32(h>>10) & 0x1f
+	mantissa := uint32(h & 0x3ff)
+	switch {
+	case exponent == 0:
+		// Zero or subnormal
+		f := float32(mantissa) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case exponent == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mantissa<<13)
+	}
+	return math.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+}
+
+// floatToHalf converts a float to IEEE 754 half precision, rounding to
+// nearest even; values beyond the range of halves become infinite
+func floatToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exponent := int(bits>>23) & 0xff
+	mantissa := bits & 0x7fffff
+	if exponent == 0xff {
+		if mantissa != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	}
+	e := exponent - 127 + 15
+	switch {
+	case e >= 0x1f:
+		return sign | 0x7c00
+	case e <= 0:
+		// Subnormal
+		if e < -10 {
+			return sign
+		}
+		mantissa |= 0x800000
+		shift := uint(14 - e)
+		h := uint16(mantissa >> shift)
+		rest, middle := mantissa&(1<<shift-1), uint32(1)<<(shift-1)
+		if rest > middle || (rest == middle && h&1 == 1) {
+			h++
+		}
+		return sign | h
+	}
+	h := sign | uint16(e<<10) | uint16(mantissa>>13)
+	rest := mantissa & 0x1fff
+	if rest > 0x1000 || (rest == 0x1000 && h&1 == 1) {
+		h++
+	}
+	return h
+}
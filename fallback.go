@@ -0,0 +1,77 @@
+package fauxgl
+
+import (
+	"image"
+)
+
+// missingColor marks missing materials and textures, and the placeholders
+// of broken nodes
+var missingColor = Color{1, 0, 1, 1}
+
+// NewMissingTexture returns the built-in missing texture: a magenta and
+// black checkerboard of 8 by 8 checks, sampled nearest so the checks stay
+// sharp, that stands out in renders wherever a texture failed to load
+func NewMissingTexture() *AdvancedTexture {
+	const size, checks = 64, 8
+	im := image.NewNRGBA(image.Rect(0, 0, size, size))
+	magenta, black := missingColor.NRGBA(), Black.NRGBA()
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			// Magenta at texture coordinates (0, 0), which meshes without
+			// texture coordinates sample everywhere
+			if (x*checks/size+(size-1-y)*checks/size)%2 == 0 {
+				im.SetNRGBA(x, y, magenta)
+			} else {
+				im.SetNRGBA(x, y, black)
+			}
+		}
+	}
+	texture := NewAdvancedTexture(im, BaseColorTexture)
+	texture.MagFilter = FilterNearest
+	return texture
+}
+
+// NewFallbackMaterial returns a rough dielectric material with the missing
+// texture as base color, for Scene.FallbackMaterial
+func NewFallbackMaterial() *PBRMaterial {
+	material := NewPBRMaterial()
+	material.BaseColorTexture = NewMissingTexture()
+	material.MetallicFactor = 0
+	material.RoughnessFactor = 1
+	return material
+}
+
+// fallbackMaterial returns the material to draw in place of a missing or
+// broken one: a copy of it with the scene's missing texture in place of
+// its textures without an image when that repairs it, otherwise the
+// scene's fallback material, nil without one
+func fallbackMaterial(scene *Scene, material *PBRMaterial) *PBRMaterial {
+	if material != nil && scene.MissingTexture != nil {
+		patched := *material
+		for _, slot := range patched.textureSlots() {
+			if texture, ok := (*slot).(*AdvancedTexture); ok && (texture == nil || (texture.Image == nil && len(texture.UDIMTiles) == 0)) {
+				*slot = scene.MissingTexture
+			}
+		}
+		if checkMaterial(&patched) == "" {
+			return &patched
+		}
+	}
+	return scene.FallbackMaterial
+}
+
+// fallbackNode returns a copy of a node with a missing or broken material
+// drawn with the fallbacks of the scene, or nil when they can't draw it
+func (renderer *SceneRenderer) fallbackNode(scene *Scene, node *SceneNode) *SceneNode {
+	material := fallbackMaterial(scene, node.Material)
+	if material == nil {
+		return nil
+	}
+	fallback := *node
+	fallback.source = node.sourceNode()
+	fallback.Material = material
+	if renderer.checkNode(&fallback) != "" {
+		return nil
+	}
+	return &fallback
+}
@@ -0,0 +1,323 @@
+package fauxgl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Asset is the content of a file loaded by LoadAny or saved by SaveAny:
+// a scene, a mesh or a texture, whichever the format holds
+type Asset struct {
+	Scene   *Scene
+	Mesh    *Mesh
+	Texture *AdvancedTexture
+}
+
+// AsScene returns the scene of the asset, or a scene holding its mesh with
+// a default material
+func (asset *Asset) AsScene() (*Scene, error) {
+	switch {
+	case asset.Scene != nil:
+		return asset.Scene, nil
+	case asset.Mesh != nil:
+		scene := NewScene("Mesh")
+		scene.AddMaterial("default", NewPBRMaterial())
+		scene.AddMesh("mesh", asset.Mesh)
+		scene.RootNode.AddChild(scene.CreateMeshNode("mesh", "mesh", "default"))
+		return scene, nil
+	}
+	return nil, errors.New("asset has no scene or mesh")
+}
+
+// AsMesh returns the mesh of the asset, or the meshes of the visible nodes
+// of its scene merged in world space, once per instance
+func (asset *Asset) AsMesh() (*Mesh, error) {
+	switch {
+	case asset.Mesh != nil:
+		return asset.Mesh, nil
+	case asset.Scene != nil:
+		asset.Scene.UpdateTransforms()
+		mesh := NewEmptyMesh()
+		asset.Scene.RootNode.VisitNodes(func(node *SceneNode) {
+			if !node.Visible || node.Mesh == nil {
+				return
+			}
+			for _, transform := range node.InstanceTransforms() {
+				instance := node.Mesh.Copy()
+				instance.Transform(transform)
+				mesh.Add(instance)
+			}
+		})
+		return mesh, nil
+	}
+	return nil, errors.New("asset has no mesh or scene")
+}
+
+// FileFormat imports and exports a file format for LoadAny and SaveAny,
+// see RegisterFileFormat
+type FileFormat struct {
+	Name string
+	// Extensions are the lower case file extensions of the format, with
+	// the dot, e.g. ".gltf"
+	Extensions []string
+	// Magic are prefixes of the file contents that identify the format
+	// whatever its extension
+	Magic []string
+	// Load loads a file; nil for formats that can only be saved
+	Load func(path string) (*Asset, error)
+	// Save saves an asset; nil for formats that can only be loaded
+	Save func(path string, asset *Asset) error
+}
+
+var (
+	fileFormatsMutex sync.RWMutex
+	fileFormats      []*FileFormat
+)
+
+// RegisterFileFormat registers a file format for LoadAny and SaveAny.
+// Formats registered later take precedence, so a downstream tool can
+// replace a built-in importer or exporter.
+func RegisterFileFormat(format *FileFormat) {
+	fileFormatsMutex.Lock()
+	defer fileFormatsMutex.Unlock()
+	fileFormats = append(fileFormats, format)
+}
+
+// FileFormats returns the registered file formats, most recent first
+func FileFormats() []*FileFormat {
+	fileFormatsMutex.RLock()
+	defer fileFormatsMutex.RUnlock()
+	formats := make([]*FileFormat, len(fileFormats))
+	for i, format := range fileFormats {
+		formats[len(formats)-1-i] = format
+	}
+	return formats
+}
+
+// fileFormatByExtension returns the format of a path from its extension
+func fileFormatByExtension(path string, capable func(*FileFormat) bool) *FileFormat {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, format := range FileFormats() {
+		if !capable(format) {
+			continue
+		}
+		for _, e := range format.Extensions {
+			if e == ext {
+				return format
+			}
+		}
+	}
+	return nil
+}
+
+// LoadAny loads a file with the registered format its contents or else
+// its extension identify
+func LoadAny(path string) (*Asset, error) {
+	canLoad := func(format *FileFormat) bool { return format.Load != nil }
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 64)
+	n, err := io.ReadFull(file, header)
+	file.Close()
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	header = header[:n]
+
+	for _, format := range FileFormats() {
+		if !canLoad(format) {
+			continue
+		}
+		for _, magic := range format.Magic {
+			if bytes.HasPrefix(header, []byte(magic)) {
+				return format.Load(path)
+			}
+		}
+	}
+	if format := fileFormatByExtension(path, canLoad); format != nil {
+		return format.Load(path)
+	}
+	return nil, fmt.Errorf("unknown file format: %s", path)
+}
+
+// SaveAny saves an asset with the registered format of the extension of
+// path. Scene formats accept meshes and mesh formats scenes, see
+// Asset.AsScene and Asset.AsMesh.
+func SaveAny(path string, asset *Asset) error {
+	format := fileFormatByExtension(path, func(format *FileFormat) bool { return format.Save != nil })
+	if format == nil {
+		return fmt.Errorf("unknown file format: %s", path)
+	}
+	if err := format.Save(path, asset); err != nil {
+		return fmt.Errorf("failed to save %s file: %w", format.Name, err)
+	}
+	return nil
+}
+
+// The built-in formats
+func init() {
+	saveScene := func(path string, asset *Asset) error {
+		scene, err := asset.AsScene()
+		if err != nil {
+			return err
+		}
+		return SaveGLTFScene(scene, path)
+	}
+	loadScene := func(path string) (*Asset, error) {
+		scene, err := LoadGLTFScene(path)
+		if err != nil {
+			return nil, err
+		}
+		return &Asset{Scene: scene}, nil
+	}
+	RegisterFileFormat(&FileFormat{
+		Name:       "glTF",
+		Extensions: []string{".gltf"},
+		Load:       loadScene,
+		Save:       saveScene,
+	})
+	RegisterFileFormat(&FileFormat{
+		Name:       "GLB",
+		Extensions: []string{".glb"},
+		Magic:      []string{"glTF"},
+		Load:       loadScene,
+		Save:       saveScene,
+	})
+
+	mesh := func(load func(string) (*Mesh, error)) func(string) (*Asset, error) {
+		return func(path string) (*Asset, error) {
+			mesh, err := load(path)
+			if err != nil {
+				return nil, err
+			}
+			return &Asset{Mesh: mesh}, nil
+		}
+	}
+	saveMesh := func(save func(string, *Mesh) error) func(string, *Asset) error {
+		return func(path string, asset *Asset) error {
+			mesh, err := asset.AsMesh()
+			if err != nil {
+				return err
+			}
+			return save(path, mesh)
+		}
+	}
+	RegisterFileFormat(&FileFormat{
+		Name:       "OBJ",
+		Extensions: []string{".obj"},
+		Load:       mesh(LoadOBJ),
+		Save:       saveMesh(SaveOBJ),
+	})
+	RegisterFileFormat(&FileFormat{
+		Name:       "STL",
+		Extensions: []string{".stl"},
+		Load:       mesh(LoadSTL),
+		Save: saveMesh(func(path string, mesh *Mesh) error {
+			return SaveSTL(path, mesh, false)
+		}),
+	})
+	RegisterFileFormat(&FileFormat{
+		Name:       "PLY",
+		Extensions: []string{".ply"},
+		Magic:      []string{"ply\n", "ply\r\n"},
+		Load:       mesh(LoadPLY),
+		Save: saveMesh(func(path string, mesh *Mesh) error {
+			return SavePLY(path, mesh, false)
+		}),
+	})
+
+	texture := func(path string) (*Asset, error) {
+		texture, err := LoadAdvancedTexture(path, BaseColorTexture)
+		if err != nil {
+			return nil, err
+		}
+		return &Asset{Texture: texture}, nil
+	}
+	saveTexture := func(save func(string, *AdvancedTexture) error) func(string, *Asset) error {
+		return func(path string, asset *Asset) error {
+			if asset.Texture == nil || asset.Texture.Image == nil {
+				return errors.New("asset has no texture")
+			}
+			return save(path, asset.Texture)
+		}
+	}
+	RegisterFileFormat(&FileFormat{
+		Name:       "KTX2",
+		Extensions: []string{".ktx2"},
+		Magic:      []string{string(KTX2_MAGIC[:])},
+		Load:       texture,
+		Save: saveTexture(func(path string, texture *AdvancedTexture) error {
+			// Color textures are stored sRGB, data textures linear
+			srgb := texture.Type == BaseColorTexture || texture.Type == EmissiveTexture
+			return SaveKTX2Texture(path, texture, srgb)
+		}),
+	})
+	RegisterFileFormat(&FileFormat{
+		Name:       "PNG",
+		Extensions: []string{".png"},
+		Magic:      []string{"\x89PNG\r\n\x1a\n"},
+		Load:       texture,
+		Save: saveTexture(func(path string, texture *AdvancedTexture) error {
+			return SavePNG(path, texture.Image)
+		}),
+	})
+	RegisterFileFormat(&FileFormat{
+		Name:       "JPEG",
+		Extensions: []string{".jpg", ".jpeg"},
+		Magic:      []string{"\xff\xd8\xff"},
+		Load:       texture,
+		Save: saveTexture(func(path string, texture *AdvancedTexture) error {
+			file, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			if err := jpeg.Encode(file, texture.Image, &jpeg.Options{Quality: 95}); err != nil {
+				file.Close()
+				return err
+			}
+			return file.Close()
+		}),
+	})
+	RegisterFileFormat(&FileFormat{
+		Name:       "Radiance HDR",
+		Extensions: []string{".hdr"},
+		Magic:      []string{"#?RADIANCE", "#?RGBE"},
+		Load:       texture,
+		Save: saveTexture(func(path string, texture *AdvancedTexture) error {
+			return SaveHDR(path, texture.Image)
+		}),
+	})
+	RegisterFileFormat(&FileFormat{
+		Name:       "OpenEXR",
+		Extensions: []string{".exr"},
+		Magic:      []string{exrMagic},
+		Load:       texture,
+		Save: saveTexture(func(path string, texture *AdvancedTexture) error {
+			return SaveEXR(path, texture.Image)
+		}),
+	})
+	RegisterFileFormat(&FileFormat{
+		Name:       "TIFF",
+		Extensions: []string{".tif", ".tiff"},
+		Magic:      []string{"II*\x00", "MM\x00*"},
+		Load:       texture,
+		Save: saveTexture(func(path string, texture *AdvancedTexture) error {
+			return SaveTIFF(path, texture.Image)
+		}),
+	})
+	RegisterFileFormat(&FileFormat{
+		Name:       "WebP",
+		Extensions: []string{".webp"},
+		Load:       texture,
+	})
+}
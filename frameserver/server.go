@@ -0,0 +1,374 @@
+// Package frameserver streams frames of a fauxgl scene to WebSocket
+// clients. It is a separate module so the core package doesn't depend on
+// a WebSocket implementation.
+package frameserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/swordkee/fauxgl-gltf"
+)
+
+// Format is the image encoding of streamed frames
+type Format int
+
+const (
+	// JPEG - lossy, small frames for interactive use
+	JPEG Format = iota
+	// PNG - lossless frames with alpha
+	PNG
+)
+
+// Message is a client request of the frame server protocol. Every
+// field is optional; the fields present are applied and a new frame is
+// rendered. Messages are JSON text messages, for example:
+//
+//	{"camera": {"yaw": 1.2, "pitch": 1.0, "distance": 4}}
+//	{"material": {"name": "material_0", "baseColor": [1, 0, 0, 1], "roughness": 0.3}}
+//	{"width": 1024, "height": 768, "format": "png", "quality": "preview"}
+//	{"preview": "half"}
+//
+// Frames are sent back as binary messages holding the encoded image. Errors
+// are sent as text messages of the form {"error": "..."}.
+type Message struct {
+	Camera      *Camera   `json:"camera,omitempty"`
+	Material    *Material `json:"material,omitempty"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	Format      string    `json:"format,omitempty"`      // "jpeg" or "png"
+	JPEGQuality int       `json:"jpegQuality,omitempty"` // 1-100
+	Quality     string    `json:"quality,omitempty"`     // Render quality preset name
+	// Preview mode name, e.g. "half" while the user drags the camera and
+	// "full" once they stop, see fauxgl.PreviewMode
+	Preview string `json:"preview,omitempty"`
+}
+
+// Camera updates the orbit camera of a session
+type Camera struct {
+	Target   *[3]float64 `json:"target,omitempty"`
+	Distance *float64    `json:"distance,omitempty"`
+	Yaw      *float64    `json:"yaw,omitempty"`   // Horizontal angle in radians
+	Pitch    *float64    `json:"pitch,omitempty"` // Vertical angle in radians, measured from +Y
+	FOV      *float64    `json:"fov,omitempty"`   // Field of view in degrees
+}
+
+// Material updates the factors of a scene material
+type Material struct {
+	Name      string      `json:"name"`
+	BaseColor *[4]float64 `json:"baseColor,omitempty"`
+	Metallic  *float64    `json:"metallic,omitempty"`
+	Roughness *float64    `json:"roughness,omitempty"`
+	Emissive  *[3]float64 `json:"emissive,omitempty"`
+}
+
+// Server renders a scene on request and streams the frames to
+// WebSocket clients. Each connection has its own camera and output
+// settings; material edits change the shared scene and are seen by all
+// clients. Rendering is serialized, and requests that arrive while a frame
+// is rendering are coalesced so slow clients never queue stale frames.
+type Server struct {
+	Scene       *fauxgl.Scene
+	Width       int                  // Default frame width
+	Height      int                  // Default frame height
+	Format      Format               // Default frame encoding
+	JPEGQuality int                  // Default JPEG quality
+	Quality     fauxgl.RenderQuality // Default render quality
+	Preview     fauxgl.PreviewMode   // Default preview mode
+	Background  fauxgl.Color         // Clear color
+	// Limits, when set, bound every render, see ResourceLimits; frames over
+	// them are sent as errors
+	Limits *fauxgl.ResourceLimits
+
+	// CheckOrigin decides whether a cross-origin WebSocket upgrade is
+	// allowed; nil accepts only same-origin requests
+	CheckOrigin func(r *http.Request) bool
+
+	mutex sync.Mutex
+}
+
+// New creates a frame server for a scene
+func New(scene *fauxgl.Scene) *Server {
+	return &Server{
+		Scene:       scene,
+		Width:       800,
+		Height:      600,
+		Format:      JPEG,
+		JPEGQuality: 85,
+		Quality:     fauxgl.QualityDraft,
+		Background:  fauxgl.Transparent,
+	}
+}
+
+// frameSession holds the per-connection state of the frame server
+type frameSession struct {
+	camera      *fauxgl.OrbitCamera
+	width       int
+	height      int
+	format      Format
+	jpegQuality int
+	quality     fauxgl.RenderQuality
+	preview     fauxgl.PreviewMode
+}
+
+// newSession creates a session with the server defaults and a camera
+// framing the scene bounds
+func (server *Server) newSession() *frameSession {
+	const fov = 30
+	box := server.Scene.GetBounds()
+	center := box.Center()
+	distance := box.Size().Length() / (2 * math.Tan(fauxgl.Radians(fov)/2))
+	if distance == 0 || math.IsNaN(distance) || math.IsInf(distance, 0) {
+		center = fauxgl.Vector{}
+		distance = 5
+	}
+
+	camera := fauxgl.NewOrbitCamera("frame_server", center, distance, fauxgl.Radians(fov), 1, 0.1, 100)
+	camera.HorizontalAngle = math.Pi / 2
+	camera.VerticalAngle = math.Pi / 3
+	camera.Update()
+
+	return &frameSession{
+		camera:      camera,
+		width:       server.Width,
+		height:      server.Height,
+		format:      server.Format,
+		jpegQuality: server.JPEGQuality,
+		quality:     server.Quality,
+		preview:     server.Preview,
+	}
+}
+
+// apply applies a client message to the session and the shared scene
+func (server *Server) apply(session *frameSession, message *Message) error {
+	if message.Width < 0 || message.Height < 0 || message.Width > 8192 || message.Height > 8192 {
+		return fmt.Errorf("invalid frame size %dx%d", message.Width, message.Height)
+	}
+	if message.Width > 0 {
+		session.width = message.Width
+	}
+	if message.Height > 0 {
+		session.height = message.Height
+	}
+
+	switch strings.ToLower(message.Format) {
+	case "":
+	case "jpeg", "jpg":
+		session.format = JPEG
+	case "png":
+		session.format = PNG
+	default:
+		return fmt.Errorf("unsupported frame format: %s", message.Format)
+	}
+	if message.JPEGQuality > 0 {
+		session.jpegQuality = fauxgl.ClampInt(message.JPEGQuality, 1, 100)
+	}
+
+	if message.Quality != "" {
+		quality, err := fauxgl.ParseRenderQuality(message.Quality)
+		if err != nil {
+			return err
+		}
+		session.quality = quality
+	}
+	if message.Preview != "" {
+		preview, err := fauxgl.ParsePreviewMode(message.Preview)
+		if err != nil {
+			return err
+		}
+		session.preview = preview
+	}
+
+	if c := message.Camera; c != nil {
+		camera := session.camera
+		if c.Target != nil {
+			camera.Target = fauxgl.V(c.Target[0], c.Target[1], c.Target[2])
+		}
+		if c.Distance != nil {
+			camera.Distance = math.Max(0.01, *c.Distance)
+		}
+		if c.Yaw != nil {
+			camera.HorizontalAngle = *c.Yaw
+		}
+		if c.Pitch != nil {
+			camera.VerticalAngle = fauxgl.Clamp(*c.Pitch, 0.01, math.Pi-0.01)
+		}
+		if c.FOV != nil {
+			camera.FOV = fauxgl.Radians(fauxgl.Clamp(*c.FOV, 1, 179))
+		}
+		camera.Update()
+	}
+
+	if m := message.Material; m != nil {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		material := server.Scene.GetMaterial(m.Name)
+		if material == nil {
+			return fmt.Errorf("unknown material: %s", m.Name)
+		}
+		if m.BaseColor != nil {
+			material.BaseColorFactor = fauxgl.Color{R: m.BaseColor[0], G: m.BaseColor[1], B: m.BaseColor[2], A: m.BaseColor[3]}
+		}
+		if m.Metallic != nil {
+			material.MetallicFactor = fauxgl.Clamp(*m.Metallic, 0, 1)
+		}
+		if m.Roughness != nil {
+			material.RoughnessFactor = fauxgl.Clamp(*m.Roughness, 0, 1)
+		}
+		if m.Emissive != nil {
+			material.EmissiveFactor = fauxgl.Color{R: m.Emissive[0], G: m.Emissive[1], B: m.Emissive[2], A: 1}
+		}
+	}
+
+	return nil
+}
+
+// render renders the scene for a session and encodes the frame; the render
+// is abandoned when ctx is cancelled
+func (server *Server) render(ctx context.Context, session *frameSession) ([]byte, error) {
+	server.mutex.Lock()
+	settings := session.quality.Settings()
+	settings.ApplyToScene(server.Scene)
+	dc := settings.NewContext(session.width, session.height)
+
+	previous := server.Scene.ActiveCamera
+	session.camera.AspectRatio = float64(session.width) / float64(session.height)
+	session.camera.FitDepthRange(server.Scene.GetBounds(), 0.05)
+	server.Scene.ActiveCamera = session.camera.Camera
+	renderer := fauxgl.NewSceneRenderer(dc)
+	settings.ApplyToRenderer(renderer)
+	renderer.Limits = server.Limits
+	var err error
+	fauxgl.NewPreviewRenderer(session.preview).Render(dc, func() {
+		dc.ClearColorBufferWith(server.Background)
+		dc.ClearDepthBuffer()
+		_, err = renderer.RenderSceneContext(ctx, server.Scene)
+	})
+	server.Scene.ActiveCamera = previous
+	server.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return Encode(settings.Resolve(dc), session.format, session.jpegQuality)
+}
+
+// Encode encodes an image in the given frame format
+func Encode(img image.Image, format Format, jpegQuality int) ([]byte, error) {
+	var buffer bytes.Buffer
+	var err error
+	switch format {
+	case PNG:
+		err = png.Encode(&buffer, img)
+	default:
+		err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode frame: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection and runs the
+// frame protocol until the client disconnects. A first frame is sent
+// immediately after the upgrade. A render still running when the client
+// disconnects is cancelled.
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: server.CheckOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has already replied with an HTTP error
+	}
+	defer conn.Close()
+
+	// Cancelled when the read loop returns, i.e. the connection is gone
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	session := server.newSession()
+	var sessionMutex sync.Mutex
+
+	// Render requests are coalesced: at most one is pending at a time
+	pending := make(chan struct{}, 1)
+	request := func() {
+		select {
+		case pending <- struct{}{}:
+		default:
+		}
+	}
+
+	var writeMutex sync.Mutex
+	writeError := func(err error) {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		data, _ := json.Marshal(map[string]string{"error": err.Error()})
+		conn.WriteMessage(websocket.TextMessage, data)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-pending:
+			}
+
+			sessionMutex.Lock()
+			snapshot := *session
+			camera := *session.camera.Camera
+			snapshot.camera = &fauxgl.OrbitCamera{Camera: &camera}
+			sessionMutex.Unlock()
+
+			frame, err := server.render(ctx, &snapshot)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				writeError(err)
+				continue
+			}
+			writeMutex.Lock()
+			err = conn.WriteMessage(websocket.BinaryMessage, frame)
+			writeMutex.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	request()
+	for {
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if kind != websocket.TextMessage {
+			continue
+		}
+
+		var message Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			writeError(fmt.Errorf("invalid message: %w", err))
+			continue
+		}
+
+		sessionMutex.Lock()
+		err = server.apply(session, &message)
+		sessionMutex.Unlock()
+		if err != nil {
+			writeError(err)
+			continue
+		}
+		request()
+	}
+}
@@ -0,0 +1,99 @@
+package fauxgl
+
+import "math"
+
+// GizmoFlags selects the debug gizmos drawn over a node, see
+// SceneNode.Gizmos and SceneRenderer.Gizmos
+type GizmoFlags int
+
+const (
+	// GizmoBounds draws the world space bounding box of the mesh of the
+	// node and its instances, in yellow
+	GizmoBounds GizmoFlags = 1 << iota
+	// GizmoAxes draws the local X, Y and Z axes of the node at its origin,
+	// in red, green and blue
+	GizmoAxes
+	// GizmoPivot marks the origin of the node, its pivot, with a white cross
+	GizmoPivot
+	// GizmoXRay draws the gizmos through the geometry in front of them
+	GizmoXRay
+
+	// GizmoAll draws every gizmo of the node
+	GizmoAll = GizmoBounds | GizmoAxes | GizmoPivot
+)
+
+// Gizmo colors
+var (
+	gizmoBoundsColor = Color{1, 0.8, 0, 1}
+	gizmoPivotColor  = White
+	gizmoAxisColors  = [3]Color{{1, 0.2, 0.2, 1}, {0.2, 1, 0.2, 1}, {0.3, 0.5, 1, 1}}
+)
+
+// drawGizmos draws the gizmos of every visible node of a scene, those of
+// the node and those of the renderer, over the frame with lines
+func (renderer *SceneRenderer) drawGizmos(scene *Scene, cameraMatrix Matrix) {
+	global := renderer.Gizmos
+	if renderer.DebugFlags&DebugBounds != 0 {
+		global |= GizmoBounds
+	}
+	var nodes []*SceneNode
+	scene.RootNode.VisitNodes(func(node *SceneNode) {
+		if node.Visible && (node.Gizmos|global)&GizmoAll != 0 {
+			nodes = append(nodes, node)
+		}
+	})
+	if len(nodes) == 0 {
+		return
+	}
+
+	// Axes without a size of their own span a tenth of the scene
+	sceneSize := 1.0
+	if bounds := scene.GetBounds(); bounds != EmptyBox {
+		sceneSize = math.Max(bounds.Size().Length(), 1e-9)
+	}
+
+	dc := renderer.context
+	shader, cull, writeDepth, readDepth, lineWidth := dc.Shader, dc.Cull, dc.WriteDepth, dc.ReadDepth, dc.LineWidth
+	dc.Cull = CullNone
+	dc.WriteDepth = false
+	dc.LineWidth = lineWidth * float64(dc.Supersampling())
+	defer func() {
+		dc.Shader, dc.Cull, dc.WriteDepth, dc.ReadDepth, dc.LineWidth = shader, cull, writeDepth, readDepth, lineWidth
+	}()
+
+	draw := func(color Color, lines []*Line) {
+		dc.Shader = NewSolidColorShader(cameraMatrix, color)
+		dc.DrawLines(lines)
+	}
+	for _, node := range nodes {
+		flags := node.Gizmos | global
+		dc.ReadDepth = readDepth && flags&GizmoXRay == 0
+		origin := node.WorldTransform.MulPosition(Vector{})
+
+		size := renderer.GizmoSize
+		if size <= 0 {
+			size = sceneSize / 10
+			if node.Mesh != nil {
+				size = math.Max(node.worldBounds().Size().MaxComponent()/2, sceneSize/100)
+			}
+		}
+
+		if flags&GizmoBounds != 0 && node.Mesh != nil {
+			draw(gizmoBoundsColor, NewCubeOutlineForBox(node.worldBounds()).Lines)
+		}
+		if flags&GizmoAxes != 0 {
+			for i, axis := range [3]Vector{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}} {
+				direction := node.WorldTransform.MulDirection(axis)
+				draw(gizmoAxisColors[i], []*Line{NewLineForPoints(origin, origin.Add(direction.MulScalar(size)))})
+			}
+		}
+		if flags&GizmoPivot != 0 {
+			r := size * 0.15
+			draw(gizmoPivotColor, []*Line{
+				NewLineForPoints(origin.Sub(Vector{r, 0, 0}), origin.Add(Vector{r, 0, 0})),
+				NewLineForPoints(origin.Sub(Vector{0, r, 0}), origin.Add(Vector{0, r, 0})),
+				NewLineForPoints(origin.Sub(Vector{0, 0, r}), origin.Add(Vector{0, 0, r})),
+			})
+		}
+	}
+}
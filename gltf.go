@@ -1,21 +1,98 @@
 package fauxgl
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	goimage "image"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/qmuntal/gltf"
 	"github.com/qmuntal/gltf/modeler"
 )
 
 // LoadGLTFScene loads a complete GLTF scene with materials, cameras, lights, etc.
+// External buffers and images are resolved relative to the file and must lie
+// in its directory; see GLTFLoadOptions.AllowOutsideFiles for images.
 func LoadGLTFScene(path string) (*Scene, error) {
+	return LoadGLTFSceneWithOptions(path, GLTFLoadOptions{})
+}
+
+// LoadGLTFSceneWithOptions loads a GLTF scene like LoadGLTFScene with
+// options, e.g. resource limits for untrusted files
+func LoadGLTFSceneWithOptions(path string, options GLTFLoadOptions) (*Scene, error) {
 	doc, err := gltf.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	fsys := &localFS{dir: filepath.Dir(path), outside: options.AllowOutsideFiles}
+	return loadGLTFDocument(doc, fsys, options)
+}
+
+// localFS opens the files a glTF document on disk refers to, see
+// GLTFLoadOptions.AllowOutsideFiles
+type localFS struct {
+	dir     string
+	outside bool // Follow absolute URIs and URIs leaving dir
+}
+
+// Open opens name relative to the document's directory
+func (fsys *localFS) Open(name string) (fs.File, error) {
+	name = filepath.FromSlash(name)
+	if filepath.IsAbs(name) && fsys.outside {
+		return os.Open(name)
+	}
+	if !filepath.IsLocal(name) && !fsys.outside {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errOutsideFile}
+	}
+	return os.Open(filepath.Join(fsys.dir, name))
+}
+
+// errOutsideFile refuses URIs leaving the directory of a glTF document
+var errOutsideFile = fmt.Errorf("%w: outside the document directory", fs.ErrPermission)
+
+// LoadGLTFSceneFromBytes loads a GLTF scene from glTF JSON or GLB data held in
+// memory. External buffers and images are read from fsys; pass nil for
+// self-contained files, in which case external images are skipped. No os file
+// access is needed, which makes this the entry point for WASM builds.
+func LoadGLTFSceneFromBytes(data []byte, fsys fs.FS) (*Scene, error) {
+	return LoadGLTFSceneFromBytesWithOptions(data, fsys, GLTFLoadOptions{})
+}
+
+// LoadGLTFSceneFromBytesWithOptions loads a GLTF scene held in memory like
+// LoadGLTFSceneFromBytes with options, e.g. resource limits for files
+// uploaded to a server
+func LoadGLTFSceneFromBytesWithOptions(data []byte, fsys fs.FS, options GLTFLoadOptions) (*Scene, error) {
+	doc := new(gltf.Document)
+	if err := gltf.NewDecoderFS(bytes.NewReader(data), fsys).Decode(doc); err != nil {
+		return nil, fmt.Errorf("failed to decode glTF data: %w", err)
+	}
+	for _, buffer := range doc.Buffers {
+		if len(buffer.Data) == 0 && buffer.ByteLength > 0 {
+			return nil, fmt.Errorf("failed to load external buffer %s: no file system provided", buffer.URI)
+		}
+	}
+	return loadGLTFDocument(doc, fsys, options)
+}
 
+// loadGLTFDocument converts a decoded GLTF document into a scene
+func loadGLTFDocument(doc *gltf.Document, fsys fs.FS, options GLTFLoadOptions) (*Scene, error) {
 	scene := NewScene("GLTF Scene")
-	loader := &GLTFLoader{doc: doc, scene: scene}
+	scene.Generator = doc.Asset.Generator
+	loader := &GLTFLoader{doc: doc, scene: scene, fsys: fsys, limits: options.Limits}
+
+	// Refuse documents over the limits before building anything
+	err := loader.checkDocument()
+	if err != nil {
+		return nil, err
+	}
 
 	// Load textures
 	err = loader.loadTextures()
@@ -29,6 +106,18 @@ func LoadGLTFScene(path string) (*Scene, error) {
 		return nil, err
 	}
 
+	// Load texture transform animations
+	err = loader.loadTextureTransformAnimations()
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode Draco compressed primitives into plain accessors
+	err = loader.decompressDraco()
+	if err != nil {
+		return nil, err
+	}
+
 	// Load meshes
 	err = loader.loadMeshes()
 	if err != nil {
@@ -55,6 +144,21 @@ func LoadGLTFScene(path string) (*Scene, error) {
 		}
 	}
 
+	// Load skins, which reference the loaded nodes as joints
+	err = loader.loadSkins()
+	if err != nil {
+		return nil, err
+	}
+
+	// Load node animations, which target the loaded nodes
+	err = loader.loadAnimations()
+	if err != nil {
+		return nil, err
+	}
+
+	// Share the meshes of copies exported as separate geometry
+	DeduplicateMeshes(scene)
+
 	return scene, nil
 }
 
@@ -62,38 +166,185 @@ func LoadGLTFScene(path string) (*Scene, error) {
 type GLTFLoader struct {
 	doc   *gltf.Document
 	scene *Scene
+	fsys  fs.FS              // Source of external images, nil when unavailable
+	nodes map[int]*SceneNode // Loaded scene nodes by glTF node index
+
+	limits *ResourceLimits // See GLTFLoadOptions
+	memory int64           // Estimated bytes loaded, checked against limits
 }
 
 // loadTextures loads all textures from the GLTF document
 func (loader *GLTFLoader) loadTextures() error {
 	for i, texture := range loader.doc.Textures {
-		if texture.Source == nil {
-			continue
+		sourceIndex := -1
+		if texture.Source != nil {
+			sourceIndex = int(*texture.Source)
 		}
-
-		sourceIndex := int(*texture.Source)
-		if sourceIndex >= len(loader.doc.Images) {
+		// Prefer the KTX2 image of KHR_texture_basisu, then the WebP image
+		// of EXT_texture_webp, over the fallback
+		if source, ok := extensionSource(texture.Extensions, "KHR_texture_basisu"); ok {
+			sourceIndex = source
+		} else if source, ok := extensionSource(texture.Extensions, "EXT_texture_webp"); ok {
+			sourceIndex = source
+		}
+		if sourceIndex < 0 || sourceIndex >= len(loader.doc.Images) {
 			continue
 		}
 
-		image := loader.doc.Images[sourceIndex]
-		if image.URI == "" {
-			continue // Skip embedded images for now
+		advTexture, err := loader.loadImage(loader.doc.Images[sourceIndex])
+		var limitErr *LimitError
+		if errors.As(err, &limitErr) {
+			return err
 		}
-
-		// Load texture from URI
-		textureName := fmt.Sprintf("texture_%d", i)
-		advTexture, err := LoadAdvancedTexture(image.URI, BaseColorTexture)
-		if err != nil {
+		if err != nil && texture.Source != nil && int(*texture.Source) != sourceIndex && int(*texture.Source) < len(loader.doc.Images) {
+			// Fall back to the source of the texture when its extension
+			// image can't be decoded, e.g. a UASTC KTX2 image
+			advTexture, err = loader.loadImage(loader.doc.Images[*texture.Source])
+			if errors.As(err, &limitErr) {
+				return err
+			}
+		}
+		if errors.Is(err, ErrUASTCUnsupported) || errors.Is(err, errOutsideFile) {
+			// Without a fallback the materials would silently lose the
+			// texture, typically a normal or ORM map
+			return fmt.Errorf("texture %d: %w", i, err)
+		}
+		if err == nil {
+			err = loader.checkTexture(advTexture)
+			if err != nil {
+				return err
+			}
+		}
+		if err != nil || advTexture == nil {
 			continue // Skip failed textures
 		}
 
+		textureName := fmt.Sprintf("texture_%d", i)
 		loader.scene.AddTexture(textureName, advTexture)
 	}
 
 	return nil
 }
 
+// loadImage decodes a glTF image stored in a buffer view, in a data URI or
+// in an external file, nil for external files without a file system
+func (loader *GLTFLoader) loadImage(image *gltf.Image) (*AdvancedTexture, error) {
+	if image.BufferView != nil {
+		if *image.BufferView < 0 || *image.BufferView >= len(loader.doc.BufferViews) {
+			return nil, fmt.Errorf("buffer view %d out of range", *image.BufferView)
+		}
+		data, err := modeler.ReadBufferView(loader.doc, loader.doc.BufferViews[*image.BufferView])
+		if err != nil {
+			return nil, err
+		}
+		return loader.decodeImage(data)
+	}
+	if strings.HasPrefix(image.URI, "data:") {
+		data, err := decodeDataURI(image.URI)
+		if err != nil {
+			return nil, err
+		}
+		return loader.decodeImage(data)
+	}
+	if image.URI == "" || loader.fsys == nil {
+		return nil, nil
+	}
+
+	uri, err := url.PathUnescape(image.URI)
+	if err != nil {
+		uri = image.URI
+	}
+	uri = path.Clean(uri)
+	if IsUDIMPath(uri) {
+		return LoadUDIMTextureFS(loader.fsys, uri, BaseColorTexture)
+	}
+	if loader.limits != nil {
+		file, err := loader.fsys.Open(uri)
+		if err != nil {
+			return nil, err
+		}
+		config, _, err := goimage.DecodeConfig(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if err := loader.checkImageConfig(config); err != nil {
+			return nil, err
+		}
+	}
+	return LoadAdvancedTextureFS(loader.fsys, uri, BaseColorTexture)
+}
+
+// decodeImage decodes an image held in memory, checking its size against
+// the limits first
+func (loader *GLTFLoader) decodeImage(data []byte) (*AdvancedTexture, error) {
+	if loader.limits != nil {
+		config, _, err := goimage.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if err := loader.checkImageConfig(config); err != nil {
+			return nil, err
+		}
+	}
+	return LoadAdvancedTextureFromBytes(data, BaseColorTexture)
+}
+
+// decodeDataURI returns the data of a base64 encoded data URI
+func decodeDataURI(uri string) ([]byte, error) {
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+		return nil, fmt.Errorf("unsupported data URI")
+	}
+	return base64.StdEncoding.DecodeString(uri[comma+1:])
+}
+
+// extensionSource returns the image index of a texture extension with a
+// source property, such as KHR_texture_basisu
+func extensionSource(extensions gltf.Extensions, name string) (int, bool) {
+	ext, ok := extensions[name]
+	if !ok {
+		return 0, false
+	}
+	var source struct {
+		Source *int `json:"source"`
+	}
+	switch v := ext.(type) {
+	case json.RawMessage:
+		if err := json.Unmarshal(v, &source); err != nil {
+			return 0, false
+		}
+	case map[string]interface{}:
+		if index, ok := v["source"].(float64); ok {
+			i := int(index)
+			source.Source = &i
+		}
+	}
+	if source.Source == nil {
+		return 0, false
+	}
+	return *source.Source, true
+}
+
+// materialTexture returns the loaded texture of a material texture
+// reference. A KHR_texture_transform of the reference is applied to a copy
+// of the texture, so materials and slots sharing an image keep their own
+// transforms. Only TEXCOORD_0 is loaded, so texCoord overrides are ignored.
+func (loader *GLTFLoader) materialTexture(index int, extensions gltf.Extensions) *AdvancedTexture {
+	texture := loader.scene.GetTexture(fmt.Sprintf("texture_%d", index))
+	if texture == nil {
+		return nil
+	}
+	ext, ok := textureTransformExtension(extensions)
+	if !ok {
+		return texture
+	}
+	transformed := *texture
+	transformed.UVModifier = NewUVModifier()
+	transformed.UVModifier.SetGlobalTransform(NewKHRUVTransform(ext.Offset, ext.Rotation, ext.ScaleOrDefault()))
+	return &transformed
+}
+
 // loadMaterials loads all materials from the GLTF document
 func (loader *GLTFLoader) loadMaterials() error {
 	for i, gltfMat := range loader.doc.Materials {
@@ -120,25 +371,22 @@ func (loader *GLTFLoader) loadMaterials() error {
 
 			// Base color texture
 			if pbr.BaseColorTexture != nil {
-				textureName := fmt.Sprintf("texture_%d", pbr.BaseColorTexture.Index)
-				if texture := loader.scene.GetTexture(textureName); texture != nil {
+				if texture := loader.materialTexture(pbr.BaseColorTexture.Index, pbr.BaseColorTexture.Extensions); texture != nil {
 					material.BaseColorTexture = texture
 				}
 			}
 
 			// Metallic roughness texture
 			if pbr.MetallicRoughnessTexture != nil {
-				textureName := fmt.Sprintf("texture_%d", pbr.MetallicRoughnessTexture.Index)
-				if texture := loader.scene.GetTexture(textureName); texture != nil {
+				if texture := loader.materialTexture(pbr.MetallicRoughnessTexture.Index, pbr.MetallicRoughnessTexture.Extensions); texture != nil {
 					material.MetallicRoughnessTexture = texture
 				}
 			}
 		}
 
 		// Normal texture
-		if gltfMat.NormalTexture != nil {
-			textureName := fmt.Sprintf("texture_%d", gltfMat.NormalTexture.Index)
-			if texture := loader.scene.GetTexture(textureName); texture != nil {
+		if gltfMat.NormalTexture != nil && gltfMat.NormalTexture.Index != nil {
+			if texture := loader.materialTexture(*gltfMat.NormalTexture.Index, gltfMat.NormalTexture.Extensions); texture != nil {
 				material.NormalTexture = texture
 				if gltfMat.NormalTexture.Scale != nil {
 					material.NormalScale = float64(*gltfMat.NormalTexture.Scale)
@@ -147,9 +395,8 @@ func (loader *GLTFLoader) loadMaterials() error {
 		}
 
 		// Occlusion texture
-		if gltfMat.OcclusionTexture != nil {
-			textureName := fmt.Sprintf("texture_%d", gltfMat.OcclusionTexture.Index)
-			if texture := loader.scene.GetTexture(textureName); texture != nil {
+		if gltfMat.OcclusionTexture != nil && gltfMat.OcclusionTexture.Index != nil {
+			if texture := loader.materialTexture(*gltfMat.OcclusionTexture.Index, gltfMat.OcclusionTexture.Extensions); texture != nil {
 				material.OcclusionTexture = texture
 				if gltfMat.OcclusionTexture.Strength != nil {
 					material.OcclusionStrength = float64(*gltfMat.OcclusionTexture.Strength)
@@ -166,8 +413,7 @@ func (loader *GLTFLoader) loadMaterials() error {
 		}
 
 		if gltfMat.EmissiveTexture != nil {
-			textureName := fmt.Sprintf("texture_%d", gltfMat.EmissiveTexture.Index)
-			if texture := loader.scene.GetTexture(textureName); texture != nil {
+			if texture := loader.materialTexture(gltfMat.EmissiveTexture.Index, gltfMat.EmissiveTexture.Extensions); texture != nil {
 				material.EmissiveTexture = texture
 			}
 		}
@@ -186,6 +432,7 @@ func (loader *GLTFLoader) loadMaterials() error {
 		}
 
 		material.DoubleSided = gltfMat.DoubleSided
+		loader.loadMaterialExtensions(material, gltfMat.Extensions)
 
 		materialName := fmt.Sprintf("material_%d", i)
 		loader.scene.AddMaterial(materialName, material)
@@ -194,6 +441,118 @@ func (loader *GLTFLoader) loadMaterials() error {
 	return nil
 }
 
+// loadTextureTransformAnimations loads KHR_animation_pointer channels that
+// animate KHR_texture_transform properties of material textures
+func (loader *GLTFLoader) loadTextureTransformAnimations() error {
+	for i, gltfAnim := range loader.doc.Animations {
+		name := gltfAnim.Name
+		if name == "" {
+			name = fmt.Sprintf("animation_%d", i)
+		}
+		animation := NewAnimation(name, 0)
+
+		for _, gltfChannel := range gltfAnim.Channels {
+			pointer, ok := animationPointer(gltfChannel.Target)
+			if !ok || gltfChannel.Sampler >= len(gltfAnim.Samplers) {
+				continue
+			}
+			materialIndex, slot, property, err := ParseTextureTransformPointer(pointer)
+			if err != nil || materialIndex >= len(loader.doc.Materials) {
+				continue
+			}
+			material := loader.scene.GetMaterial(fmt.Sprintf("material_%d", materialIndex))
+			if material == nil {
+				continue
+			}
+
+			// Start from the static transform declared on the texture
+			transform, err := MaterialTextureTransform(material, slot)
+			if err != nil {
+				continue
+			}
+			ext, ok := textureTransformExtension(gltfTextureExtensions(loader.doc.Materials[materialIndex], slot))
+			if ok {
+				transform.SetKHRTextureTransform(ext.Offset, ext.Rotation, ext.ScaleOrDefault())
+			}
+
+			sampler := gltfAnim.Samplers[gltfChannel.Sampler]
+			keyframes, err := loader.readTextureTransformKeyframes(sampler, property)
+			if err != nil {
+				return fmt.Errorf("failed to read texture transform animation: %w", err)
+			}
+			if len(keyframes) == 0 {
+				continue
+			}
+
+			interpolation := Linear
+			if sampler.Interpolation == gltf.InterpolationStep {
+				interpolation = Step
+			}
+
+			animation.AddChannel(AnimationChannel{
+				Property:      property,
+				Keyframes:     keyframes,
+				Interpolation: interpolation,
+				UVTarget:      transform,
+				Pointer:       pointer,
+			})
+			if last := keyframes[len(keyframes)-1].Time; last > animation.Duration {
+				animation.Duration = last
+			}
+		}
+
+		if len(animation.Channels) > 0 {
+			loader.scene.AddAnimation(name, animation)
+		}
+	}
+
+	return nil
+}
+
+// readTextureTransformKeyframes reads the keyframes of a texture transform sampler
+func (loader *GLTFLoader) readTextureTransformKeyframes(sampler *gltf.AnimationSampler, property AnimationProperty) ([]Keyframe, error) {
+	if sampler.Input >= len(loader.doc.Accessors) || sampler.Output >= len(loader.doc.Accessors) {
+		return nil, fmt.Errorf("sampler accessor out of range")
+	}
+
+	input, err := modeler.ReadAccessor(loader.doc, loader.doc.Accessors[sampler.Input], nil)
+	if err != nil {
+		return nil, err
+	}
+	output, err := modeler.ReadAccessor(loader.doc, loader.doc.Accessors[sampler.Output], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	times, ok := input.([]float32)
+	if !ok {
+		return nil, fmt.Errorf("unsupported keyframe time format")
+	}
+
+	// Cubic spline samplers store in-tangent, value, out-tangent per keyframe
+	stride, offset := 1, 0
+	if sampler.Interpolation == gltf.InterpolationCubicSpline {
+		stride, offset = 3, 1
+	}
+
+	keyframes := make([]Keyframe, 0, len(times))
+	for k, time := range times {
+		index := k*stride + offset
+		switch values := output.(type) {
+		case [][2]float32:
+			if index < len(values) {
+				keyframes = append(keyframes, Keyframe{Time: float64(time), Value: Vector{float64(values[index][0]), float64(values[index][1]), 0}})
+			}
+		case []float32:
+			if index < len(values) && property == TextureRotation {
+				keyframes = append(keyframes, Keyframe{Time: float64(time), Value: float64(values[index])})
+			}
+		}
+	}
+
+	return keyframes, nil
+}
+
 // loadCameras loads all cameras from the GLTF document
 func (loader *GLTFLoader) loadCameras() error {
 	for i, gltfCamera := range loader.doc.Cameras {
@@ -281,99 +640,41 @@ func (loader *GLTFLoader) loadNode(nodeIndex int, parent *SceneNode) (*SceneNode
 	}
 
 	node := NewSceneNode(nodeName)
-
-	// Set transform
-	var hasMatrix bool
-	for _, v := range gltfNode.Matrix {
-		if v != 0 {
-			hasMatrix = true
-			break
-		}
+	if loader.nodes == nil {
+		loader.nodes = make(map[int]*SceneNode)
 	}
+	loader.nodes[nodeIndex] = node
 
-	if hasMatrix {
-		// Matrix transform
-		m := gltfNode.Matrix
+	// Set transform. A node carries either a matrix or TRS properties; the
+	// decoder fills absent properties with their identity defaults, so an
+	// identity matrix means the TRS properties apply.
+	if m := gltfNode.MatrixOrDefault(); m != gltf.DefaultMatrix {
+		// glTF matrices are column-major
 		node.SetTransform(Matrix{
-			float64(m[0]), float64(m[4]), float64(m[8]), float64(m[12]),
-			float64(m[1]), float64(m[5]), float64(m[9]), float64(m[13]),
-			float64(m[2]), float64(m[6]), float64(m[10]), float64(m[14]),
-			float64(m[3]), float64(m[7]), float64(m[11]), float64(m[15]),
+			m[0], m[4], m[8], m[12],
+			m[1], m[5], m[9], m[13],
+			m[2], m[6], m[10], m[14],
+			m[3], m[7], m[11], m[15],
 		})
 	} else {
-		// TRS transform
-		transform := Identity()
-
-		// Translation
-		var hasTranslation bool
-		for _, v := range gltfNode.Translation {
-			if v != 0 {
-				hasTranslation = true
-				break
-			}
-		}
-		if hasTranslation {
-			t := gltfNode.Translation
-			transform = transform.Translate(Vector{float64(t[0]), float64(t[1]), float64(t[2])})
-		}
-
-		// Rotation (quaternion)
-		var hasRotation bool
-		for _, v := range gltfNode.Rotation {
-			if v != 0 {
-				hasRotation = true
-				break
-			}
-		}
-		if hasRotation {
-			// Convert quaternion to rotation matrix
-			q := gltfNode.Rotation
-			// 创建四元数 [x, y, z, w]
-			x, y, z, w := float64(q[0]), float64(q[1]), float64(q[2]), float64(q[3])
-
-			// 四元数到矩阵的转换
-			// https://www.euclideanspace.com/maths/geometry/rotations/conversions/quaternionToMatrix/
-			// 注意GLTF使用的是[x, y, z, w]格式的四元数
-			xx := 2 * x * x
-			yy := 2 * y * y
-			zz := 2 * z * z
-			xy := 2 * x * y
-			xz := 2 * x * z
-			yz := 2 * y * z
-			wx := 2 * w * x
-			wy := 2 * w * y
-			wz := 2 * w * z
-
-			rotationMatrix := Matrix{
-				1 - yy - zz, xy - wz, xz + wy, 0,
-				xy + wz, 1 - xx - zz, yz - wx, 0,
-				xz - wy, yz + wx, 1 - xx - yy, 0,
-				0, 0, 0, 1,
-			}
-
-			transform = transform.Mul(rotationMatrix)
-		}
-
-		// Scale
-		var hasScale bool
-		for i, v := range gltfNode.Scale {
-			if i < 3 && v != 1.0 { // Scale default is 1.0
-				hasScale = true
-				break
-			}
-		}
-		if hasScale {
-			s := gltfNode.Scale
-			transform = transform.Scale(Vector{float64(s[0]), float64(s[1]), float64(s[2])})
-		}
-
-		node.SetTransform(transform)
+		// TRS transform composed as T * R * S
+		t := gltfNode.TranslationOrDefault()
+		r := gltfNode.RotationOrDefault()
+		s := gltfNode.ScaleOrDefault()
+		rotation := Quaternion{X: r[0], Y: r[1], Z: r[2], W: r[3]}
+		node.SetTransform(Translate(Vector{t[0], t[1], t[2]}).
+			Mul(rotation.ToMatrix()).
+			Mul(Scale(Vector{s[0], s[1], s[2]})))
 	}
 
 	// Assign mesh and material - create separate nodes for each primitive
 	if gltfNode.Mesh != nil {
 		meshIndex := *gltfNode.Mesh
 		gltfMesh := loader.doc.Meshes[meshIndex]
+		instances, err := loader.readInstances(gltfNode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read instances of node %d: %w", nodeIndex, err)
+		}
 
 		// 为每个primitive创建独立的子节点，实现正确的多材质UV分区
 		for j, primitive := range gltfMesh.Primitives {
@@ -385,6 +686,22 @@ func (loader *GLTFLoader) loadNode(nodeIndex int, parent *SceneNode) (*SceneNode
 				primitiveNodeName := fmt.Sprintf("%s_primitive_%d", nodeName, j)
 				primitiveNode := NewSceneNode(primitiveNodeName)
 				primitiveNode.Mesh = mesh
+				primitiveNode.Instances = instances
+
+				// Targets are shared between nodes, weights are per node
+				if targets := loader.scene.GetMorphTargets(meshName); targets != nil {
+					weights := make([]float64, len(targets.Targets))
+					if len(gltfNode.Weights) > 0 {
+						copy(weights, gltfNode.Weights)
+					} else {
+						copy(weights, targets.Weights)
+					}
+					primitiveNode.MorphTargets = &MorphTargets{
+						Targets: targets.Targets,
+						Weights: weights,
+						Indices: targets.Indices,
+					}
+				}
 
 				// 正确分配材质
 				if primitive.Material != nil {
@@ -458,6 +775,61 @@ func (loader *GLTFLoader) loadMeshes() error {
 				}
 			}
 
+			// Tangents for normal mapping (if present)
+			var tangentBuffer [][4]float32
+			if tangentIndex, ok := primitive.Attributes[gltf.TANGENT]; ok {
+				tangentBuffer, err = modeler.ReadTangent(loader.doc, loader.doc.Accessors[tangentIndex], nil)
+				if err != nil {
+					return fmt.Errorf("failed to read tangents of mesh %d: %w", i, err)
+				}
+			}
+
+			// Vertex colors (if present), e.g. the masks of BlendedMaterial
+			var colorBuffer [][4]uint16
+			if colorIndex, ok := primitive.Attributes[gltf.COLOR_0]; ok {
+				colorBuffer, err = modeler.ReadColor64(loader.doc, loader.doc.Accessors[colorIndex], nil)
+				if err != nil {
+					return fmt.Errorf("failed to read colors of mesh %d: %w", i, err)
+				}
+			}
+
+			// Skinning influences (if present)
+			var jointBuffer [][4]uint16
+			var weightBuffer [][4]float32
+			if jointsIndex, ok := primitive.Attributes[gltf.JOINTS_0]; ok {
+				jointBuffer, err = modeler.ReadJoints(loader.doc, loader.doc.Accessors[jointsIndex], nil)
+				if err != nil {
+					return fmt.Errorf("failed to read joints of mesh %d: %w", i, err)
+				}
+			}
+			if weightsIndex, ok := primitive.Attributes[gltf.WEIGHTS_0]; ok {
+				weightBuffer, err = modeler.ReadWeights(loader.doc, loader.doc.Accessors[weightsIndex], nil)
+				if err != nil {
+					return fmt.Errorf("failed to read weights of mesh %d: %w", i, err)
+				}
+			}
+			setTangent := func(v *Vertex, index uint32) {
+				if int(index) < len(tangentBuffer) {
+					t := tangentBuffer[index]
+					v.Tangent = VectorW{float64(t[0]), float64(t[1]), float64(t[2]), float64(t[3])}
+				}
+			}
+			setColor := func(v *Vertex, index uint32) {
+				if int(index) < len(colorBuffer) {
+					c := colorBuffer[index]
+					v.Color = Color{float64(c[0]) / 65535, float64(c[1]) / 65535, float64(c[2]) / 65535, float64(c[3]) / 65535}
+				}
+			}
+			setInfluences := func(v *Vertex, index uint32) {
+				if int(index) >= len(jointBuffer) || int(index) >= len(weightBuffer) {
+					return
+				}
+				for c := 0; c < 4; c++ {
+					v.Joints[c] = int(jointBuffer[index][c])
+					v.Weights[c] = float64(weightBuffer[index][c])
+				}
+			}
+
 			// 获取索引数据
 			var indices []uint32
 			if primitive.Indices != nil {
@@ -500,6 +872,9 @@ func (loader *GLTFLoader) loadMeshes() error {
 						0,
 					}
 				}
+				setTangent(&t.V1, i1)
+				setColor(&t.V1, i1)
+				setInfluences(&t.V1, i1)
 
 				// 第二个顶点
 				i2 := indices[k+1]
@@ -522,6 +897,9 @@ func (loader *GLTFLoader) loadMeshes() error {
 						0,
 					}
 				}
+				setTangent(&t.V2, i2)
+				setColor(&t.V2, i2)
+				setInfluences(&t.V2, i2)
 
 				// 第三个顶点
 				i3 := indices[k+2]
@@ -544,6 +922,9 @@ func (loader *GLTFLoader) loadMeshes() error {
 						0,
 					}
 				}
+				setTangent(&t.V3, i3)
+				setColor(&t.V3, i3)
+				setInfluences(&t.V3, i3)
 
 				// 如果没有法线数据，则自动计算
 				if len(normalBuffer) == 0 {
@@ -555,10 +936,78 @@ func (loader *GLTFLoader) loadMeshes() error {
 
 			// 为每个primitive创建独立的mesh
 			mesh := NewTriangleMesh(triangles)
+
+			// Normal maps need tangents, generate them when not supplied
+			if len(tangentBuffer) == 0 && len(texCoordBuffer) > 0 && primitive.Material != nil {
+				if int(*primitive.Material) < len(loader.doc.Materials) && loader.doc.Materials[*primitive.Material].NormalTexture != nil {
+					mesh.ComputeTangents()
+				}
+			}
 			meshName := fmt.Sprintf("mesh_%d_primitive_%d", i, j)
 			loader.scene.AddMesh(meshName, mesh)
+
+			// Morph targets share the primitive's vertex indices
+			if len(primitive.Targets) > 0 {
+				targets, err := loader.readMorphTargets(primitive.Targets, indices, gltfMesh.Weights)
+				if err != nil {
+					return fmt.Errorf("failed to read morph targets of mesh %d: %w", i, err)
+				}
+				loader.scene.AddMorphTargets(meshName, targets)
+			}
 		}
 	}
 
 	return nil
 }
+
+// readMorphTargets reads the POSITION, NORMAL and TANGENT displacements of
+// a primitive's morph targets. Triangle corners map to the target entries
+// through the primitive's indices.
+func (loader *GLTFLoader) readMorphTargets(gltfTargets []gltf.PrimitiveAttributes, indices []uint32, weights []float64) (*MorphTargets, error) {
+	targets := &MorphTargets{
+		Targets: make([]MorphTarget, len(gltfTargets)),
+		Weights: make([]float64, len(gltfTargets)),
+		Indices: make([]int, len(indices)),
+	}
+	copy(targets.Weights, weights)
+	for k, index := range indices {
+		targets.Indices[k] = int(index)
+	}
+
+	toVectors := func(values [][3]float32) []Vector {
+		vectors := make([]Vector, len(values))
+		for i, v := range values {
+			vectors[i] = Vector{float64(v[0]), float64(v[1]), float64(v[2])}
+		}
+		return vectors
+	}
+	for k, attributes := range gltfTargets {
+		target := &targets.Targets[k]
+		target.Name = fmt.Sprintf("target_%d", k)
+		if index, ok := attributes[gltf.POSITION]; ok {
+			positions, err := modeler.ReadPosition(loader.doc, loader.doc.Accessors[index], nil)
+			if err != nil {
+				return nil, err
+			}
+			target.Positions = toVectors(positions)
+		}
+		if index, ok := attributes[gltf.NORMAL]; ok {
+			normals, err := modeler.ReadNormal(loader.doc, loader.doc.Accessors[index], nil)
+			if err != nil {
+				return nil, err
+			}
+			target.Normals = toVectors(normals)
+		}
+		if index, ok := attributes[gltf.TANGENT]; ok {
+			// Tangent displacements have no handedness component
+			tangents, err := modeler.ReadAccessor(loader.doc, loader.doc.Accessors[index], nil)
+			if err != nil {
+				return nil, err
+			}
+			if values, ok := tangents.([][3]float32); ok {
+				target.Tangents = toVectors(values)
+			}
+		}
+	}
+	return targets, nil
+}
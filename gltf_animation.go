@@ -0,0 +1,232 @@
+package fauxgl
+
+import (
+	"fmt"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// loadAnimations loads node translation, rotation, scale and morph weight
+// channels. Channels of an animation that also animates texture transforms
+// are merged into the animation loaded by loadTextureTransformAnimations.
+func (loader *GLTFLoader) loadAnimations() error {
+	for i, gltfAnim := range loader.doc.Animations {
+		name := gltfAnim.Name
+		if name == "" {
+			name = fmt.Sprintf("animation_%d", i)
+		}
+		animation := loader.scene.GetAnimation(name)
+		isNew := animation == nil
+		if isNew {
+			animation = NewAnimation(name, 0)
+		}
+
+		for _, gltfChannel := range gltfAnim.Channels {
+			target := gltfChannel.Target
+			if target.Node == nil || gltfChannel.Sampler >= len(gltfAnim.Samplers) {
+				continue
+			}
+			node := loader.nodes[*target.Node]
+			if node == nil {
+				continue
+			}
+
+			var property AnimationProperty
+			switch target.Path {
+			case gltf.TRSTranslation:
+				property = Translation
+			case gltf.TRSRotation:
+				property = Rotation
+			case gltf.TRSScale:
+				property = ScaleProperty
+			case gltf.TRSWeights:
+				property = Weights
+			default:
+				continue
+			}
+
+			sampler := gltfAnim.Samplers[gltfChannel.Sampler]
+			keyframes, err := loader.readNodeKeyframes(sampler, property)
+			if err != nil {
+				return fmt.Errorf("failed to read animation %s: %w", name, err)
+			}
+			if len(keyframes) == 0 {
+				continue
+			}
+
+			animation.AddChannel(AnimationChannel{
+				Target:        node,
+				Property:      property,
+				Keyframes:     keyframes,
+				Interpolation: gltfInterpolation(sampler.Interpolation),
+			})
+			if last := keyframes[len(keyframes)-1].Time; last > animation.Duration {
+				animation.Duration = last
+			}
+		}
+
+		if isNew && len(animation.Channels) > 0 {
+			loader.scene.AddAnimation(name, animation)
+		}
+	}
+
+	return nil
+}
+
+// gltfInterpolation converts a glTF sampler interpolation mode
+func gltfInterpolation(interpolation gltf.Interpolation) InterpolationType {
+	switch interpolation {
+	case gltf.InterpolationStep:
+		return Step
+	case gltf.InterpolationCubicSpline:
+		return CubicSpline
+	default:
+		return Linear
+	}
+}
+
+// readNodeKeyframes reads the keyframes of a node animation sampler. Values
+// are Vector for translation and scale, Quaternion for rotation and
+// []float64 for weights. CubicSpline keyframes carry their tangents.
+func (loader *GLTFLoader) readNodeKeyframes(sampler *gltf.AnimationSampler, property AnimationProperty) ([]Keyframe, error) {
+	if sampler.Input >= len(loader.doc.Accessors) || sampler.Output >= len(loader.doc.Accessors) {
+		return nil, fmt.Errorf("sampler accessor out of range")
+	}
+
+	input, err := modeler.ReadAccessor(loader.doc, loader.doc.Accessors[sampler.Input], nil)
+	if err != nil {
+		return nil, err
+	}
+	output, err := modeler.ReadAccessor(loader.doc, loader.doc.Accessors[sampler.Output], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	times, ok := input.([]float32)
+	if !ok {
+		return nil, fmt.Errorf("unsupported keyframe time format")
+	}
+	values, ok := accessorFloats(output)
+	if !ok {
+		return nil, fmt.Errorf("unsupported keyframe value format")
+	}
+
+	// Cubic spline samplers store in-tangent, value, out-tangent per keyframe
+	cubic := sampler.Interpolation == gltf.InterpolationCubicSpline
+	elements := len(times)
+	if cubic {
+		elements *= 3
+	}
+	if elements == 0 {
+		return nil, nil
+	}
+
+	// Components per element; weights have one per morph target
+	size := 0
+	switch property {
+	case Translation, ScaleProperty:
+		size = 3
+	case Rotation:
+		size = 4
+	case Weights:
+		size = len(values) / elements
+	}
+	if size == 0 || len(values) < elements*size {
+		return nil, fmt.Errorf("keyframe output holds %d values for %d keyframes", len(values), len(times))
+	}
+
+	element := func(index int) interface{} {
+		v := values[index*size : (index+1)*size]
+		switch property {
+		case Translation, ScaleProperty:
+			return Vector{v[0], v[1], v[2]}
+		case Rotation:
+			return Quaternion{v[0], v[1], v[2], v[3]}
+		default:
+			weights := make([]float64, size)
+			copy(weights, v)
+			return weights
+		}
+	}
+
+	keyframes := make([]Keyframe, len(times))
+	for k, time := range times {
+		keyframes[k].Time = float64(time)
+		if cubic {
+			keyframes[k].InTangent = element(3 * k)
+			keyframes[k].Value = element(3*k + 1)
+			keyframes[k].OutTangent = element(3*k + 2)
+		} else {
+			keyframes[k].Value = element(k)
+		}
+	}
+	return keyframes, nil
+}
+
+// accessorFloats flattens float and normalized integer accessor data into
+// float64 components
+func accessorFloats(data interface{}) ([]float64, bool) {
+	var out []float64
+	switch v := data.(type) {
+	case []float32:
+		for _, x := range v {
+			out = append(out, float64(x))
+		}
+	case [][2]float32:
+		for _, e := range v {
+			out = append(out, float64(e[0]), float64(e[1]))
+		}
+	case [][3]float32:
+		for _, e := range v {
+			out = append(out, float64(e[0]), float64(e[1]), float64(e[2]))
+		}
+	case [][4]float32:
+		for _, e := range v {
+			out = append(out, float64(e[0]), float64(e[1]), float64(e[2]), float64(e[3]))
+		}
+	case []int8:
+		for _, x := range v {
+			out = append(out, normalizedInt8(x))
+		}
+	case [][4]int8:
+		for _, e := range v {
+			out = append(out, normalizedInt8(e[0]), normalizedInt8(e[1]), normalizedInt8(e[2]), normalizedInt8(e[3]))
+		}
+	case []uint8:
+		for _, x := range v {
+			out = append(out, float64(x)/255)
+		}
+	case [][4]uint8:
+		for _, e := range v {
+			out = append(out, float64(e[0])/255, float64(e[1])/255, float64(e[2])/255, float64(e[3])/255)
+		}
+	case []int16:
+		for _, x := range v {
+			out = append(out, normalizedInt16(x))
+		}
+	case [][4]int16:
+		for _, e := range v {
+			out = append(out, normalizedInt16(e[0]), normalizedInt16(e[1]), normalizedInt16(e[2]), normalizedInt16(e[3]))
+		}
+	case []uint16:
+		for _, x := range v {
+			out = append(out, float64(x)/65535)
+		}
+	case [][4]uint16:
+		for _, e := range v {
+			out = append(out, float64(e[0])/65535, float64(e[1])/65535, float64(e[2])/65535, float64(e[3])/65535)
+		}
+	default:
+		return nil, false
+	}
+	return out, true
+}
+
+func normalizedInt8(x int8) float64 {
+	return Clamp(float64(x)/127, -1, 1)
+}
+
+func normalizedInt16(x int16) float64 {
+	return Clamp(float64(x)/32767, -1, 1)
+}
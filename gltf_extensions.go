@@ -117,7 +117,8 @@ func (ext *KHRTextureTransformExtension) GetName() string {
 }
 
 func (ext *KHRTextureTransformExtension) Process(data map[string]interface{}, scene *Scene) error {
-	// Process texture coordinate transformations (offset, rotation, scale)
+	// Texture transforms belong to texture references rather than the
+	// document, the glTF loader applies them to the material textures
 	return nil
 }
 
@@ -456,10 +457,8 @@ func (ext *EXTMeshGPUInstancingExtension) GetName() string {
 }
 
 func (ext *EXTMeshGPUInstancingExtension) Process(data map[string]interface{}, scene *Scene) error {
-	// Process GPU instancing attributes (can be simulated with CPU)
-	if attributes, ok := data["attributes"].(map[string]interface{}); ok {
-		_ = attributes // Process instance transforms, colors, etc.
-	}
+	// Instance transforms belong to nodes, the loader reads them into
+	// SceneNode.Instances
 	return nil
 }
 
@@ -473,9 +472,7 @@ func (ext *EXTTextureWebPExtension) GetName() string {
 }
 
 func (ext *EXTTextureWebPExtension) Process(data map[string]interface{}, scene *Scene) error {
-	// Process WebP texture format support
-	if source, ok := data["source"].(float64); ok {
-		_ = source // Reference to WebP image
-	}
+	// The loader decodes the WebP source of textures in place of their
+	// fallback image
 	return nil
 }
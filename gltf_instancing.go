@@ -0,0 +1,97 @@
+package fauxgl
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// gltfInstancing is the EXT_mesh_gpu_instancing extension of a glTF node
+type gltfInstancing struct {
+	Attributes map[string]int `json:"attributes"`
+}
+
+// readInstances reads the instance transforms of the EXT_mesh_gpu_instancing
+// extension of a node, nil when it has none. Each instance is the
+// TRANSLATION, ROTATION and SCALE of its attributes, missing ones defaulting
+// to identity. Custom attributes such as feature IDs are ignored.
+func (loader *GLTFLoader) readInstances(node *gltf.Node) ([]Matrix, error) {
+	ext, ok := node.Extensions["EXT_mesh_gpu_instancing"]
+	if !ok {
+		return nil, nil
+	}
+	data, ok := ext.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(ext); err != nil {
+			return nil, err
+		}
+	}
+	var instancing gltfInstancing
+	if err := json.Unmarshal(data, &instancing); err != nil {
+		return nil, err
+	}
+
+	read := func(attribute string, size int) ([]float64, int, error) {
+		index, ok := instancing.Attributes[attribute]
+		if !ok {
+			return nil, 0, nil
+		}
+		if index < 0 || index >= len(loader.doc.Accessors) {
+			return nil, 0, fmt.Errorf("%s accessor %d out of range", attribute, index)
+		}
+		accessor := loader.doc.Accessors[index]
+		values, err := modeler.ReadAccessor(loader.doc, accessor, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		floats, ok := accessorFloats(values)
+		if !ok || len(floats) < int(accessor.Count)*size {
+			return nil, 0, fmt.Errorf("unsupported %s format", attribute)
+		}
+		return floats, int(accessor.Count), nil
+	}
+	translations, n1, err := read("TRANSLATION", 3)
+	if err != nil {
+		return nil, err
+	}
+	rotations, n2, err := read("ROTATION", 4)
+	if err != nil {
+		return nil, err
+	}
+	scales, n3, err := read("SCALE", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	// All attributes hold one element per instance
+	count := n1
+	if n2 > count {
+		count = n2
+	}
+	if n3 > count {
+		count = n3
+	}
+	if (n1 > 0 && n1 != count) || (n2 > 0 && n2 != count) || (n3 > 0 && n3 != count) {
+		return nil, fmt.Errorf("instance attributes have different counts")
+	}
+
+	instances := make([]Matrix, count)
+	for i := range instances {
+		transform := Identity()
+		if scales != nil {
+			transform = Scale(Vector{scales[3*i], scales[3*i+1], scales[3*i+2]})
+		}
+		if rotations != nil {
+			q := Quaternion{rotations[4*i], rotations[4*i+1], rotations[4*i+2], rotations[4*i+3]}
+			transform = q.ToMatrix().Mul(transform)
+		}
+		if translations != nil {
+			transform = Translate(Vector{translations[3*i], translations[3*i+1], translations[3*i+2]}).Mul(transform)
+		}
+		instances[i] = transform
+	}
+	return instances, nil
+}
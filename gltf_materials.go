@@ -0,0 +1,214 @@
+package fauxgl
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/qmuntal/gltf"
+)
+
+// gltfMaterialExtensions holds the properties of the KHR material
+// extensions; absent properties keep the PBRMaterial defaults
+type gltfMaterialExtensions struct {
+	EmissiveStrength *struct {
+		EmissiveStrength *float64 `json:"emissiveStrength"`
+	} `json:"KHR_materials_emissive_strength"`
+	IOR *struct {
+		IOR *float64 `json:"ior"`
+	} `json:"KHR_materials_ior"`
+	Specular *struct {
+		SpecularFactor       *float64          `json:"specularFactor"`
+		SpecularTexture      *gltf.TextureInfo `json:"specularTexture"`
+		SpecularColorFactor  *[3]float64       `json:"specularColorFactor"`
+		SpecularColorTexture *gltf.TextureInfo `json:"specularColorTexture"`
+	} `json:"KHR_materials_specular"`
+	Transmission *struct {
+		TransmissionFactor  *float64          `json:"transmissionFactor"`
+		TransmissionTexture *gltf.TextureInfo `json:"transmissionTexture"`
+	} `json:"KHR_materials_transmission"`
+	Volume *struct {
+		ThicknessFactor     *float64          `json:"thicknessFactor"`
+		ThicknessTexture    *gltf.TextureInfo `json:"thicknessTexture"`
+		AttenuationDistance *float64          `json:"attenuationDistance"`
+		AttenuationColor    *[3]float64       `json:"attenuationColor"`
+	} `json:"KHR_materials_volume"`
+	Anisotropy *struct {
+		AnisotropyStrength *float64          `json:"anisotropyStrength"`
+		AnisotropyRotation *float64          `json:"anisotropyRotation"`
+		AnisotropyTexture  *gltf.TextureInfo `json:"anisotropyTexture"`
+	} `json:"KHR_materials_anisotropy"`
+	Sheen *struct {
+		SheenColorFactor      *[3]float64       `json:"sheenColorFactor"`
+		SheenColorTexture     *gltf.TextureInfo `json:"sheenColorTexture"`
+		SheenRoughnessFactor  *float64          `json:"sheenRoughnessFactor"`
+		SheenRoughnessTexture *gltf.TextureInfo `json:"sheenRoughnessTexture"`
+	} `json:"KHR_materials_sheen"`
+	Iridescence *struct {
+		IridescenceFactor           *float64          `json:"iridescenceFactor"`
+		IridescenceTexture          *gltf.TextureInfo `json:"iridescenceTexture"`
+		IridescenceIor              *float64          `json:"iridescenceIor"`
+		IridescenceThicknessMinimum *float64          `json:"iridescenceThicknessMinimum"`
+		IridescenceThicknessMaximum *float64          `json:"iridescenceThicknessMaximum"`
+		IridescenceThicknessTexture *gltf.TextureInfo `json:"iridescenceThicknessTexture"`
+	} `json:"KHR_materials_iridescence"`
+	Dispersion *struct {
+		Dispersion *float64 `json:"dispersion"`
+	} `json:"KHR_materials_dispersion"`
+	Clearcoat *struct {
+		ClearcoatFactor           *float64            `json:"clearcoatFactor"`
+		ClearcoatTexture          *gltf.TextureInfo   `json:"clearcoatTexture"`
+		ClearcoatRoughnessFactor  *float64            `json:"clearcoatRoughnessFactor"`
+		ClearcoatRoughnessTexture *gltf.TextureInfo   `json:"clearcoatRoughnessTexture"`
+		ClearcoatNormalTexture    *gltf.NormalTexture `json:"clearcoatNormalTexture"`
+	} `json:"KHR_materials_clearcoat"`
+	SpecularGlossiness *struct {
+		DiffuseFactor             *[4]float64       `json:"diffuseFactor"`
+		DiffuseTexture            *gltf.TextureInfo `json:"diffuseTexture"`
+		SpecularFactor            *[3]float64       `json:"specularFactor"`
+		GlossinessFactor          *float64          `json:"glossinessFactor"`
+		SpecularGlossinessTexture *gltf.TextureInfo `json:"specularGlossinessTexture"`
+	} `json:"KHR_materials_pbrSpecularGlossiness"`
+}
+
+// decodeMaterialExtensions decodes the known material extensions, whether
+// they are still raw JSON or were decoded by a registered extension type
+func decodeMaterialExtensions(extensions gltf.Extensions) (*gltfMaterialExtensions, error) {
+	raw := make(map[string]json.RawMessage, len(extensions))
+	for name, ext := range extensions {
+		if data, ok := ext.(json.RawMessage); ok {
+			raw[name] = data
+			continue
+		}
+		data, err := json.Marshal(ext)
+		if err != nil {
+			return nil, err
+		}
+		raw[name] = data
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	decoded := new(gltfMaterialExtensions)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// loadMaterialExtensions sets the properties of the KHR material extensions
+// of a glTF material. Extensions that fail to decode are ignored.
+func (loader *GLTFLoader) loadMaterialExtensions(material *PBRMaterial, extensions gltf.Extensions) {
+	if len(extensions) == 0 {
+		return
+	}
+	ext, err := decodeMaterialExtensions(extensions)
+	if err != nil {
+		return
+	}
+	texture := func(info *gltf.TextureInfo) Texture {
+		if info == nil {
+			return nil
+		}
+		if t := loader.materialTexture(info.Index, info.Extensions); t != nil {
+			return t
+		}
+		return nil
+	}
+	setFloat := func(dst *float64, src *float64) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setColor := func(dst *Color, src *[3]float64) {
+		if src != nil {
+			*dst = Color{src[0], src[1], src[2], 1}
+		}
+	}
+
+	if e := ext.EmissiveStrength; e != nil && e.EmissiveStrength != nil {
+		material.EmissiveStrength = e.EmissiveStrength
+	}
+	if e := ext.IOR; e != nil {
+		setFloat(&material.IOR, e.IOR)
+	}
+	if e := ext.Specular; e != nil {
+		setColor(&material.SpecularColorFactor, e.SpecularColorFactor)
+		// The specular factor scales the dielectric reflectance like the
+		// specular color
+		if e.SpecularFactor != nil {
+			f := *e.SpecularFactor
+			c := material.SpecularColorFactor
+			material.SpecularColorFactor = Color{c.R * f, c.G * f, c.B * f, c.A}
+		}
+		material.SpecularTexture = texture(e.SpecularTexture)
+		material.SpecularColorTexture = texture(e.SpecularColorTexture)
+	}
+	if e := ext.Transmission; e != nil {
+		setFloat(&material.TransmissionFactor, e.TransmissionFactor)
+		material.TransmissionTexture = texture(e.TransmissionTexture)
+	}
+	if e := ext.Volume; e != nil {
+		setFloat(&material.ThicknessFactor, e.ThicknessFactor)
+		material.ThicknessTexture = texture(e.ThicknessTexture)
+		setFloat(&material.AttenuationDistance, e.AttenuationDistance)
+		if material.AttenuationDistance <= 0 {
+			material.AttenuationDistance = math.Inf(1)
+		}
+		setColor(&material.AttenuationColor, e.AttenuationColor)
+	}
+	if e := ext.Anisotropy; e != nil {
+		setFloat(&material.AnisotropyStrength, e.AnisotropyStrength)
+		setFloat(&material.AnisotropyRotation, e.AnisotropyRotation)
+		material.AnisotropyTexture = texture(e.AnisotropyTexture)
+	}
+	if e := ext.Sheen; e != nil {
+		setColor(&material.SheenColorFactor, e.SheenColorFactor)
+		material.SheenColorTexture = texture(e.SheenColorTexture)
+		setFloat(&material.SheenRoughnessFactor, e.SheenRoughnessFactor)
+		material.SheenRoughnessTexture = texture(e.SheenRoughnessTexture)
+	}
+	if e := ext.Iridescence; e != nil {
+		setFloat(&material.IridescenceFactor, e.IridescenceFactor)
+		material.IridescenceTexture = texture(e.IridescenceTexture)
+		setFloat(&material.IridescenceIor, e.IridescenceIor)
+		setFloat(&material.IridescenceThicknessMinimum, e.IridescenceThicknessMinimum)
+		setFloat(&material.IridescenceThicknessMaximum, e.IridescenceThicknessMaximum)
+		material.IridescenceThicknessTexture = texture(e.IridescenceThicknessTexture)
+	}
+	if e := ext.Dispersion; e != nil {
+		setFloat(&material.DispersionFactor, e.Dispersion)
+	}
+	if e := ext.Clearcoat; e != nil {
+		setFloat(&material.ClearcoatFactor, e.ClearcoatFactor)
+		material.ClearcoatTexture = texture(e.ClearcoatTexture)
+		setFloat(&material.ClearcoatRoughnessFactor, e.ClearcoatRoughnessFactor)
+		material.ClearcoatRoughnessTexture = texture(e.ClearcoatRoughnessTexture)
+		if n := e.ClearcoatNormalTexture; n != nil && n.Index != nil {
+			if t := loader.materialTexture(*n.Index, n.Extensions); t != nil {
+				material.ClearcoatNormalTexture = t
+			}
+		}
+	}
+	if e := ext.SpecularGlossiness; e != nil {
+		material.Workflow = SpecularGlossiness
+		material.DiffuseFactor = Color{1, 1, 1, 1}
+		if e.DiffuseFactor != nil {
+			d := e.DiffuseFactor
+			material.DiffuseFactor = Color{d[0], d[1], d[2], d[3]}
+		}
+		material.SpecularFactor = Color{1, 1, 1, 1}
+		setColor(&material.SpecularFactor, e.SpecularFactor)
+		material.GlossinessFactor = 1
+		setFloat(&material.GlossinessFactor, e.GlossinessFactor)
+		material.DiffuseTexture = texture(e.DiffuseTexture)
+		material.SpecularGlossinessTexture = texture(e.SpecularGlossinessTexture)
+		// Shade the diffuse color as a rough dielectric base color
+		if material.BaseColorTexture == nil {
+			material.BaseColorFactor = material.DiffuseFactor
+			material.BaseColorTexture = material.DiffuseTexture
+			material.MetallicFactor = 0
+			material.RoughnessFactor = 1 - material.GlossinessFactor
+		}
+	}
+}
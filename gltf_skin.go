@@ -0,0 +1,75 @@
+package fauxgl
+
+import (
+	"fmt"
+
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// loadSkins loads the skins of the document and assigns them to the
+// primitive nodes of the skinned mesh nodes. Joints outside the loaded
+// scene are replaced by placeholder nodes at the origin.
+func (loader *GLTFLoader) loadSkins() error {
+	skins := make([]*Skin, len(loader.doc.Skins))
+	for i, gltfSkin := range loader.doc.Skins {
+		name := gltfSkin.Name
+		if name == "" {
+			name = fmt.Sprintf("skin_%d", i)
+		}
+
+		var inverseBindMatrices [][4][4]float32
+		if gltfSkin.InverseBindMatrices != nil {
+			index := *gltfSkin.InverseBindMatrices
+			if index >= len(loader.doc.Accessors) {
+				return fmt.Errorf("failed to load skin %s: accessor %d out of range", name, index)
+			}
+			var err error
+			inverseBindMatrices, err = modeler.ReadInverseBindMatrices(loader.doc, loader.doc.Accessors[index], nil)
+			if err != nil {
+				return fmt.Errorf("failed to load skin %s: %w", name, err)
+			}
+		}
+
+		skin := NewSkin(name)
+		for j, jointIndex := range gltfSkin.Joints {
+			// Keep joint indices aligned with JOINTS_0 even for missing joints
+			joint := loader.nodes[jointIndex]
+			if joint == nil {
+				joint = NewSceneNode(fmt.Sprintf("%s_joint_%d", name, j))
+			}
+			inverseBind := Identity()
+			if j < len(inverseBindMatrices) {
+				// The accessor reader returns matrices indexed [row][column]
+				m := inverseBindMatrices[j]
+				inverseBind = Matrix{
+					float64(m[0][0]), float64(m[0][1]), float64(m[0][2]), float64(m[0][3]),
+					float64(m[1][0]), float64(m[1][1]), float64(m[1][2]), float64(m[1][3]),
+					float64(m[2][0]), float64(m[2][1]), float64(m[2][2]), float64(m[2][3]),
+					float64(m[3][0]), float64(m[3][1]), float64(m[3][2]), float64(m[3][3]),
+				}
+			}
+			skin.AddJoint(joint, inverseBind)
+		}
+		if gltfSkin.Skeleton != nil {
+			skin.Skeleton = loader.nodes[*gltfSkin.Skeleton]
+		}
+
+		skins[i] = skin
+		loader.scene.AddSkin(name, skin)
+	}
+
+	for i, gltfNode := range loader.doc.Nodes {
+		node := loader.nodes[i]
+		if gltfNode.Skin == nil || *gltfNode.Skin >= len(skins) || node == nil {
+			continue
+		}
+		// Meshes live on the primitive children created by loadNode
+		for _, child := range node.Children {
+			if child.Mesh != nil {
+				child.Skin = skins[*gltfNode.Skin]
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,871 @@
+package fauxgl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/ext/texturetransform"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// SaveGLTFScene writes a scene as glTF 2.0. A path ending in .glb produces a
+// single binary file with embedded textures; any other path produces .gltf
+// JSON with a .bin buffer and PNG textures written next to it.
+//
+// Meshes, PBR materials and their AdvancedTexture images, the node
+// hierarchy, cameras and punctual lights are written. Cameras and lights,
+// which live in world space in a Scene, become nodes under the scene root.
+// Node LODs with a screen coverage are written as MSFT_lod levels. UV
+// transforms of textures are written as KHR_texture_transform, and the
+// animations of the scene that target them, as well as their scroll and
+// rotation speeds baked with BakeUVAnimation, as KHR_animation_pointer
+// animations. Ambient lights, node animations, skins and morph targets are
+// not exported.
+func SaveGLTFScene(scene *Scene, path string) error {
+	return SaveGLTFSceneWithOptions(scene, path, GLTFExportOptions{})
+}
+
+// MeshCompression selects how exported vertex and index data is stored
+type MeshCompression int
+
+const (
+	// MeshCompressionNone - plain buffer views
+	MeshCompressionNone MeshCompression = iota
+	// MeshCompressionMeshopt - EXT_meshopt_compression streams
+	MeshCompressionMeshopt
+)
+
+// TextureCompression selects how exported texture images are stored
+type TextureCompression int
+
+const (
+	// TextureCompressionPNG - lossless PNG images
+	TextureCompressionPNG TextureCompression = iota
+	// TextureCompressionETC1S - KTX2 BasisLZ images via KHR_texture_basisu
+	TextureCompressionETC1S
+)
+
+// GLTFExportOptions controls optional compression of exported scenes
+type GLTFExportOptions struct {
+	MeshCompression    MeshCompression
+	TextureCompression TextureCompression
+	// UVAnimationDuration is the length in seconds of the animations the
+	// scroll and rotation speeds of texture transforms are baked into; 0
+	// bakes one period of the motion, which loops seamlessly for a scroll
+	// along one axis or a rotation
+	UVAnimationDuration float64
+}
+
+// SaveGLTFSceneWithOptions writes a scene as glTF 2.0 like SaveGLTFScene,
+// optionally compressing it for the web. Meshopt compression declares
+// EXT_meshopt_compression as required, and ETC1S textures are KTX2 files
+// with their full mip chain referenced through KHR_texture_basisu, so
+// loaders without these extensions cannot read the result.
+func SaveGLTFSceneWithOptions(scene *Scene, path string, options GLTFExportOptions) error {
+	binary := strings.EqualFold(filepath.Ext(path), ".glb")
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	writer := &gltfWriter{
+		doc:       gltf.NewDocument(),
+		scene:     scene,
+		options:   options,
+		binary:    binary,
+		dir:       filepath.Dir(path),
+		base:      base,
+		materials: make(map[*PBRMaterial]int),
+		textures:  make(map[*AdvancedTexture]int),
+		samplers:  make(map[gltfSamplerKey]int),
+		meshes:    make(map[gltfMeshKey]int),
+		uvIndex:   make(map[*UVTransform]*gltfUVTransform),
+	}
+	writer.doc.Asset.Generator = "fauxgl"
+	if err := writer.write(); err != nil {
+		return err
+	}
+	if options.MeshCompression == MeshCompressionMeshopt {
+		if err := compressMeshopt(writer.doc); err != nil {
+			return fmt.Errorf("failed to save glTF scene: %w", err)
+		}
+	}
+
+	if !binary && len(writer.doc.Buffers) > 0 {
+		writer.doc.Buffers[0].URI = base + ".bin"
+	}
+	var err error
+	if binary {
+		err = gltf.SaveBinary(writer.doc, path)
+	} else {
+		err = gltf.Save(writer.doc, path)
+	}
+	if err == nil && writer.pointers {
+		err = patchAnimationPointerPaths(path, binary)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save glTF scene: %w", err)
+	}
+	return nil
+}
+
+// gltfMeshKey identifies an exported glTF mesh: the material lives on the
+// primitive, so one Mesh drawn with two materials becomes two glTF meshes
+type gltfMeshKey struct {
+	mesh     *Mesh
+	material *PBRMaterial
+}
+
+// gltfVertexKey deduplicates vertices when building indexed primitives
+type gltfVertexKey struct {
+	position [3]float32
+	normal   [3]float32
+	texture  [2]float32
+	tangent  [4]float32
+}
+
+// gltfSamplerKey deduplicates samplers
+type gltfSamplerKey struct {
+	mag          gltf.MagFilter
+	min          gltf.MinFilter
+	wrapS, wrapT gltf.WrappingMode
+}
+
+// gltfWriter converts a scene into a glTF document
+type gltfWriter struct {
+	doc       *gltf.Document
+	scene     *Scene
+	options   GLTFExportOptions
+	binary    bool
+	dir       string // Output directory of external files
+	base      string // File name prefix of external files
+	materials map[*PBRMaterial]int
+	textures  map[*AdvancedTexture]int
+	samplers  map[gltfSamplerKey]int
+	meshes    map[gltfMeshKey]int
+	lights    []interface{}
+	basisu    bool // KHR_texture_basisu declared
+	// uvTransforms are the exported KHR_texture_transform transforms in
+	// the order they were written
+	uvTransforms []*gltfUVTransform
+	uvIndex      map[*UVTransform]*gltfUVTransform
+	pointers     bool // KHR_animation_pointer channels written
+}
+
+// gltfUVTransform is a UV transform exported as KHR_texture_transform and
+// the material texture pointers of its references
+type gltfUVTransform struct {
+	transform *UVTransform
+	animated  bool // Its UV modifier applies scroll and rotation speeds
+	pointers  []string
+}
+
+func (w *gltfWriter) write() error {
+	// Materials registered by name first so they keep their names, then any
+	// material only referenced by nodes
+	names := make([]string, 0, len(w.scene.Materials))
+	for name := range w.scene.Materials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := w.material(w.scene.Materials[name], name); err != nil {
+			return err
+		}
+	}
+
+	root := w.doc.Scenes[0]
+	for _, child := range w.scene.RootNode.Children {
+		index, err := w.node(child)
+		if err != nil {
+			return err
+		}
+		root.Nodes = append(root.Nodes, index)
+	}
+	if w.scene.RootNode.LocalTransform != Identity() && len(root.Nodes) > 0 {
+		// Keep the root transform by wrapping the top level nodes
+		w.doc.Nodes = append(w.doc.Nodes, &gltf.Node{
+			Name:     w.scene.RootNode.Name,
+			Matrix:   gltfMatrix(w.scene.RootNode.LocalTransform),
+			Children: root.Nodes,
+		})
+		root.Nodes = []int{len(w.doc.Nodes) - 1}
+	}
+
+	for _, camera := range w.scene.Cameras {
+		root.Nodes = append(root.Nodes, w.camera(camera))
+	}
+	for _, light := range w.scene.Lights {
+		if index, ok := w.light(light); ok {
+			root.Nodes = append(root.Nodes, index)
+		}
+	}
+	if len(w.lights) > 0 {
+		w.doc.Extensions = gltf.Extensions{"KHR_lights_punctual": map[string]interface{}{"lights": w.lights}}
+		w.doc.ExtensionsUsed = append(w.doc.ExtensionsUsed, "KHR_lights_punctual")
+	}
+	w.animations()
+	return nil
+}
+
+// node writes a scene node and its subtree and returns its index
+func (w *gltfWriter) node(node *SceneNode) (int, error) {
+	gltfNode := &gltf.Node{Name: node.Name}
+	if node.LocalTransform != Identity() {
+		gltfNode.Matrix = gltfMatrix(node.LocalTransform)
+	}
+	if node.Mesh != nil && len(node.Mesh.Triangles) > 0 {
+		var mesh int
+		var err error
+		if parts := node.materialNodes(); len(parts) > 1 || parts[0] != node {
+			mesh, err = w.multiMaterialMesh(parts)
+		} else {
+			mesh, err = w.mesh(node.Mesh, node.Material)
+			if err == nil {
+				err = w.lods(node, gltfNode)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		gltfNode.Mesh = gltf.Index(mesh)
+	}
+	for _, child := range node.Children {
+		index, err := w.node(child)
+		if err != nil {
+			return 0, err
+		}
+		gltfNode.Children = append(gltfNode.Children, index)
+	}
+	w.doc.Nodes = append(w.doc.Nodes, gltfNode)
+	return len(w.doc.Nodes) - 1, nil
+}
+
+// lods writes the LODs of a node that have a screen coverage as MSFT_lod
+// nodes with the node's transform, outside the scene hierarchy, and their
+// coverages as the MSFT_screencoverage extra of the node
+func (w *gltfWriter) lods(node *SceneNode, gltfNode *gltf.Node) error {
+	var ids []int
+	var coverage []float64
+	for i, lod := range node.LODs {
+		if i >= len(node.LODCoverage) || len(lod.Triangles) == 0 {
+			break
+		}
+		mesh, err := w.mesh(lod, node.Material)
+		if err != nil {
+			return err
+		}
+		w.doc.Nodes = append(w.doc.Nodes, &gltf.Node{
+			Name:   fmt.Sprintf("%s_lod%d", node.Name, i+1),
+			Matrix: gltfNode.Matrix,
+			Mesh:   gltf.Index(mesh),
+		})
+		ids = append(ids, len(w.doc.Nodes)-1)
+		coverage = append(coverage, node.LODCoverage[i])
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	// The last level is drawn down to no coverage
+	gltfNode.Extensions = gltf.Extensions{"MSFT_lod": map[string]interface{}{"ids": ids}}
+	gltfNode.Extras = map[string]interface{}{"MSFT_screencoverage": append(coverage, 0)}
+	w.useExtension("MSFT_lod")
+	return nil
+}
+
+// mesh writes the triangles of a mesh as one indexed primitive
+func (w *gltfWriter) mesh(mesh *Mesh, material *PBRMaterial) (int, error) {
+	key := gltfMeshKey{mesh, material}
+	if index, ok := w.meshes[key]; ok {
+		return index, nil
+	}
+	primitive, err := w.primitive(mesh, material)
+	if err != nil {
+		return 0, err
+	}
+	w.doc.Meshes = append(w.doc.Meshes, &gltf.Mesh{Primitives: []*gltf.Primitive{primitive}})
+	index := len(w.doc.Meshes) - 1
+	w.meshes[key] = index
+	return index, nil
+}
+
+// multiMaterialMesh writes the per-material parts of a node whose mesh
+// mixes materials as one mesh with a primitive per material
+func (w *gltfWriter) multiMaterialMesh(parts []*SceneNode) (int, error) {
+	mesh := &gltf.Mesh{}
+	for _, part := range parts {
+		primitive, err := w.primitive(part.Mesh, part.Material)
+		if err != nil {
+			return 0, err
+		}
+		mesh.Primitives = append(mesh.Primitives, primitive)
+	}
+	w.doc.Meshes = append(w.doc.Meshes, mesh)
+	return len(w.doc.Meshes) - 1, nil
+}
+
+// primitive writes the triangles of a mesh as an indexed primitive
+func (w *gltfWriter) primitive(mesh *Mesh, material *PBRMaterial) (*gltf.Primitive, error) {
+
+	var positions, normals [][3]float32
+	var uvs [][2]float32
+	var tangents [][4]float32
+	var indices []uint32
+	hasUVs, hasTangents := false, false
+	vertices := make(map[gltfVertexKey]uint32)
+	for _, t := range mesh.Triangles {
+		for _, v := range [3]*Vertex{&t.V1, &t.V2, &t.V3} {
+			vk := gltfVertexKey{
+				position: [3]float32{float32(v.Position.X), float32(v.Position.Y), float32(v.Position.Z)},
+				normal:   [3]float32{float32(v.Normal.X), float32(v.Normal.Y), float32(v.Normal.Z)},
+				texture:  [2]float32{float32(v.Texture.X), float32(v.Texture.Y)},
+			}
+			if v.Tangent != (VectorW{}) {
+				t := v.Tangent.Vector().Normalize()
+				w := float32(1)
+				if v.Tangent.W < 0 {
+					w = -1
+				}
+				vk.tangent = [4]float32{float32(t.X), float32(t.Y), float32(t.Z), w}
+			}
+			index, ok := vertices[vk]
+			if !ok {
+				index = uint32(len(positions))
+				vertices[vk] = index
+				positions = append(positions, vk.position)
+				normals = append(normals, vk.normal)
+				uvs = append(uvs, vk.texture)
+				tangents = append(tangents, vk.tangent)
+				hasUVs = hasUVs || vk.texture != [2]float32{}
+				hasTangents = hasTangents || vk.tangent != [4]float32{}
+			}
+			indices = append(indices, index)
+		}
+	}
+
+	primitive := &gltf.Primitive{
+		Mode: gltf.PrimitiveTriangles,
+		Attributes: gltf.PrimitiveAttributes{
+			gltf.POSITION: modeler.WritePosition(w.doc, positions),
+			gltf.NORMAL:   modeler.WriteNormal(w.doc, normals),
+		},
+		Indices: gltf.Index(modeler.WriteIndices(w.doc, indices)),
+	}
+	if hasUVs {
+		primitive.Attributes[gltf.TEXCOORD_0] = modeler.WriteTextureCoord(w.doc, uvs)
+	}
+	if hasTangents {
+		for k := range tangents {
+			// Every vertex needs a valid tangent once the attribute exists
+			if tangents[k] == ([4]float32{}) {
+				t := Vector{float64(normals[k][0]), float64(normals[k][1]), float64(normals[k][2])}.Perpendicular().Normalize()
+				tangents[k] = [4]float32{float32(t.X), float32(t.Y), float32(t.Z), 1}
+			}
+		}
+		primitive.Attributes[gltf.TANGENT] = modeler.WriteTangent(w.doc, tangents)
+	}
+	if material != nil {
+		index, err := w.material(material, "")
+		if err != nil {
+			return nil, err
+		}
+		primitive.Material = gltf.Index(index)
+	}
+	return primitive, nil
+}
+
+// material writes a PBR material and its textures and returns its index
+func (w *gltfWriter) material(material *PBRMaterial, name string) (int, error) {
+	if index, ok := w.materials[material]; ok {
+		return index, nil
+	}
+
+	// Textures are written before the material, so its index is the next
+	materialIndex := len(w.doc.Materials)
+	textureInfo := func(texture Texture, srgb bool, slot string) (*gltf.TextureInfo, error) {
+		index, ok, err := w.texture(texture, srgb)
+		if err != nil || !ok {
+			return nil, err
+		}
+		pointer := fmt.Sprintf("/materials/%d/%s", materialIndex, slot)
+		return &gltf.TextureInfo{Index: index, Extensions: w.textureTransform(texture, pointer)}, nil
+	}
+
+	c := material.BaseColorFactor
+	gltfMat := &gltf.Material{
+		Name: name,
+		PBRMetallicRoughness: &gltf.PBRMetallicRoughness{
+			BaseColorFactor: &[4]float64{c.R, c.G, c.B, c.A},
+			MetallicFactor:  gltf.Float(material.MetallicFactor),
+			RoughnessFactor: gltf.Float(material.RoughnessFactor),
+		},
+		EmissiveFactor: [3]float64{material.EmissiveFactor.R, material.EmissiveFactor.G, material.EmissiveFactor.B},
+		DoubleSided:    material.DoubleSided,
+	}
+
+	var err error
+	pbr := gltfMat.PBRMetallicRoughness
+	if pbr.BaseColorTexture, err = textureInfo(material.BaseColorTexture, true, TextureSlotBaseColor); err != nil {
+		return 0, err
+	}
+	if pbr.MetallicRoughnessTexture, err = textureInfo(material.MetallicRoughnessTexture, false, TextureSlotMetallicRoughness); err != nil {
+		return 0, err
+	}
+	if gltfMat.EmissiveTexture, err = textureInfo(material.EmissiveTexture, true, TextureSlotEmissive); err != nil {
+		return 0, err
+	}
+	if info, err := textureInfo(material.NormalTexture, false, TextureSlotNormal); err != nil {
+		return 0, err
+	} else if info != nil {
+		gltfMat.NormalTexture = &gltf.NormalTexture{Index: gltf.Index(info.Index), Scale: gltf.Float(material.NormalScale), Extensions: info.Extensions}
+	}
+	if info, err := textureInfo(material.OcclusionTexture, false, TextureSlotOcclusion); err != nil {
+		return 0, err
+	} else if info != nil {
+		gltfMat.OcclusionTexture = &gltf.OcclusionTexture{Index: gltf.Index(info.Index), Strength: gltf.Float(material.OcclusionStrength), Extensions: info.Extensions}
+	}
+
+	switch material.AlphaMode {
+	case AlphaMask:
+		gltfMat.AlphaMode = gltf.AlphaMask
+		gltfMat.AlphaCutoff = gltf.Float(material.AlphaCutoff)
+	case AlphaBlend:
+		gltfMat.AlphaMode = gltf.AlphaBlend
+	default:
+		gltfMat.AlphaMode = gltf.AlphaOpaque
+	}
+
+	// Scalar material extensions that differ from their defaults
+	if strength := material.emissiveStrength(); strength != 1 && strength >= 0 {
+		w.materialExtension(gltfMat, "KHR_materials_emissive_strength", map[string]interface{}{"emissiveStrength": strength})
+	}
+	if material.IOR != 1.5 && material.IOR > 0 {
+		w.materialExtension(gltfMat, "KHR_materials_ior", map[string]interface{}{"ior": material.IOR})
+	}
+
+	w.doc.Materials = append(w.doc.Materials, gltfMat)
+	index := len(w.doc.Materials) - 1
+	w.materials[material] = index
+	return index, nil
+}
+
+// materialExtension adds an extension to a material and declares it used
+func (w *gltfWriter) materialExtension(material *gltf.Material, name string, data map[string]interface{}) {
+	if material.Extensions == nil {
+		material.Extensions = gltf.Extensions{}
+	}
+	material.Extensions[name] = data
+	w.useExtension(name)
+}
+
+// useExtension declares an extension used, once
+func (w *gltfWriter) useExtension(name string) {
+	for _, used := range w.doc.ExtensionsUsed {
+		if used == name {
+			return
+		}
+	}
+	w.doc.ExtensionsUsed = append(w.doc.ExtensionsUsed, name)
+}
+
+// texture writes the image and sampler of a texture. Only AdvancedTexture
+// images can be exported; other textures report false. srgb marks color
+// textures for the KTX2 transfer function.
+func (w *gltfWriter) texture(texture Texture, srgb bool) (int, bool, error) {
+	advanced, ok := texture.(*AdvancedTexture)
+	if !ok || advanced == nil || advanced.Image == nil {
+		return 0, false, nil
+	}
+	if index, ok := w.textures[advanced]; ok {
+		return index, true, nil
+	}
+
+	mimeType, extension := "image/png", ".png"
+	var data bytes.Buffer
+	if w.options.TextureCompression == TextureCompressionETC1S {
+		mimeType, extension = "image/ktx2", ".ktx2"
+		data.Write(encodeKTX2ETC1S(advanced, srgb))
+	} else if err := png.Encode(&data, advanced.Image); err != nil {
+		return 0, false, fmt.Errorf("failed to encode texture image: %w", err)
+	}
+	imageName := fmt.Sprintf("%s_texture_%d", w.base, len(w.doc.Textures))
+	var imageIndex int
+	if w.binary {
+		var err error
+		imageIndex, err = modeler.WriteImage(w.doc, imageName, mimeType, &data)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to write texture image: %w", err)
+		}
+	} else {
+		uri := imageName + extension
+		if err := os.WriteFile(filepath.Join(w.dir, uri), data.Bytes(), 0644); err != nil {
+			return 0, false, fmt.Errorf("failed to write texture image: %w", err)
+		}
+		w.doc.Images = append(w.doc.Images, &gltf.Image{Name: imageName, URI: uri})
+		imageIndex = len(w.doc.Images) - 1
+	}
+
+	gltfTexture := &gltf.Texture{Sampler: gltf.Index(w.sampler(advanced))}
+	if w.options.TextureCompression == TextureCompressionETC1S {
+		// KTX2 images are only referenced through the extension
+		gltfTexture.Extensions = gltf.Extensions{"KHR_texture_basisu": map[string]interface{}{"source": imageIndex}}
+		if !w.basisu {
+			w.basisu = true
+			w.doc.ExtensionsUsed = append(w.doc.ExtensionsUsed, "KHR_texture_basisu")
+			w.doc.ExtensionsRequired = append(w.doc.ExtensionsRequired, "KHR_texture_basisu")
+		}
+	} else {
+		gltfTexture.Source = gltf.Index(imageIndex)
+	}
+	w.doc.Textures = append(w.doc.Textures, gltfTexture)
+	index := len(w.doc.Textures) - 1
+	w.textures[advanced] = index
+	return index, true, nil
+}
+
+// sampler returns the index of a sampler matching the texture's filtering
+// and wrapping, writing it on first use
+func (w *gltfWriter) sampler(texture *AdvancedTexture) int {
+	wrap := func(mode TextureWrap) gltf.WrappingMode {
+		switch mode {
+		case WrapClamp, WrapBorder:
+			// glTF has no border color
+			return gltf.WrapClampToEdge
+		case WrapMirror:
+			return gltf.WrapMirroredRepeat
+		default:
+			return gltf.WrapRepeat
+		}
+	}
+	sampler := gltf.Sampler{
+		MagFilter: gltf.MagLinear,
+		MinFilter: gltf.MinLinearMipMapLinear,
+		WrapS:     wrap(texture.WrapS),
+		WrapT:     wrap(texture.WrapT),
+	}
+	if texture.MagFilter == FilterNearest {
+		sampler.MagFilter = gltf.MagNearest
+	}
+	switch texture.MinFilter {
+	case FilterNearest:
+		sampler.MinFilter = gltf.MinNearest
+	case FilterLinear:
+		sampler.MinFilter = gltf.MinLinear
+	}
+
+	key := gltfSamplerKey{sampler.MagFilter, sampler.MinFilter, sampler.WrapS, sampler.WrapT}
+	if index, ok := w.samplers[key]; ok {
+		return index
+	}
+	w.doc.Samplers = append(w.doc.Samplers, &sampler)
+	index := len(w.doc.Samplers) - 1
+	w.samplers[key] = index
+	return index
+}
+
+// camera writes a camera and the node placing it, returning the node index
+func (w *gltfWriter) camera(camera *Camera) int {
+	gltfCamera := &gltf.Camera{Name: camera.Name}
+	if camera.ProjectionType == OrthographicProjection {
+		gltfCamera.Orthographic = &gltf.Orthographic{
+			Xmag:  camera.OrthoSize * camera.AspectRatio / 2,
+			Ymag:  camera.OrthoSize / 2,
+			Znear: camera.NearPlane,
+			Zfar:  camera.FarPlane,
+		}
+	} else {
+		perspective := &gltf.Perspective{
+			Yfov:  camera.FOV,
+			Znear: camera.NearPlane,
+		}
+		if camera.AspectRatio > 0 {
+			perspective.AspectRatio = gltf.Float(camera.AspectRatio)
+		}
+		if !math.IsInf(camera.FarPlane, 1) {
+			perspective.Zfar = gltf.Float(camera.FarPlane)
+		}
+		gltfCamera.Perspective = perspective
+	}
+	w.doc.Cameras = append(w.doc.Cameras, gltfCamera)
+
+	// glTF cameras look down their local -Z axis, as LookAt does
+	w.doc.Nodes = append(w.doc.Nodes, &gltf.Node{
+		Name:   camera.Name,
+		Camera: gltf.Index(len(w.doc.Cameras) - 1),
+		Matrix: gltfMatrix(camera.GetViewMatrix().Inverse()),
+	})
+	return len(w.doc.Nodes) - 1
+}
+
+// light writes a KHR_lights_punctual light and the node placing it,
+// returning the node index. Ambient lights have no glTF equivalent.
+func (w *gltfWriter) light(light Light) (int, bool) {
+	data := map[string]interface{}{
+		"color":     [3]float64{light.Color.R, light.Color.G, light.Color.B},
+		"intensity": light.Intensity,
+	}
+	switch light.Type {
+	case DirectionalLight:
+		data["type"] = "directional"
+	case PointLight:
+		data["type"] = "point"
+	case SpotLight:
+		data["type"] = "spot"
+		data["spot"] = map[string]interface{}{
+			"innerConeAngle": light.InnerCone,
+			"outerConeAngle": light.OuterCone,
+		}
+	default:
+		return 0, false
+	}
+	if light.Range > 0 && light.Type != DirectionalLight {
+		data["range"] = light.Range
+	}
+	w.lights = append(w.lights, data)
+
+	// Lights shine down their local -Z axis
+	transform := Translate(light.Position)
+	if light.Type != PointLight && light.Direction != (Vector{}) {
+		direction := light.Direction.Normalize()
+		up := Vector{0, 1, 0}
+		if math.Abs(direction.Y) > 0.99 {
+			up = Vector{0, 0, 1}
+		}
+		transform = LookAt(light.Position, light.Position.Add(direction), up).Inverse()
+	}
+	w.doc.Nodes = append(w.doc.Nodes, &gltf.Node{
+		Name:       fmt.Sprintf("light_%d", len(w.lights)-1),
+		Matrix:     gltfMatrix(transform),
+		Extensions: gltf.Extensions{"KHR_lights_punctual": map[string]interface{}{"light": len(w.lights) - 1}},
+	})
+	return len(w.doc.Nodes) - 1, true
+}
+
+// gltfMatrix converts a matrix to glTF's column-major layout
+func gltfMatrix(m Matrix) [16]float64 {
+	return [16]float64{
+		m.X00, m.X10, m.X20, m.X30,
+		m.X01, m.X11, m.X21, m.X31,
+		m.X02, m.X12, m.X22, m.X32,
+		m.X03, m.X13, m.X23, m.X33,
+	}
+}
+
+// textureTransform returns the KHR_texture_transform extension of a
+// texture reference when the texture has a UV transform, and records the
+// pointer of the reference for exporting animations of the transform.
+// Transforms with a pivot or skew, which the extension can't express, are
+// left out.
+func (w *gltfWriter) textureTransform(texture Texture, pointer string) gltf.Extensions {
+	advanced, ok := texture.(*AdvancedTexture)
+	if !ok || advanced == nil || advanced.UVModifier == nil {
+		return nil
+	}
+	transform := advanced.UVModifier.GlobalTransform()
+	if transform == nil || transform.PivotU != 0 || transform.PivotV != 0 || transform.SkewU != 0 || transform.SkewV != 0 {
+		return nil
+	}
+	exported, ok := w.uvIndex[transform]
+	if !ok {
+		exported = &gltfUVTransform{transform: transform, animated: advanced.UVModifier.animationEnabled}
+		w.uvIndex[transform] = exported
+		w.uvTransforms = append(w.uvTransforms, exported)
+	}
+	exported.pointers = append(exported.pointers, pointer)
+	w.useExtension(texturetransform.ExtensionName)
+
+	offset, rotation, scale := transform.KHRTextureTransform()
+	return gltf.Extensions{texturetransform.ExtensionName: &texturetransform.TextureTranform{
+		Offset:   offset,
+		Rotation: rotation,
+		Scale:    scale,
+	}}
+}
+
+// animations writes the animations of the scene that target exported
+// texture transforms, then the scroll and rotation speeds of the exported
+// transforms baked into keyframes
+func (w *gltfWriter) animations() {
+	names := make([]string, 0, len(w.scene.Animations))
+	for name := range w.scene.Animations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.animation(name, w.scene.Animations[name].Channels)
+	}
+
+	for i, exported := range w.uvTransforms {
+		if !exported.animated {
+			continue
+		}
+		duration := w.options.UVAnimationDuration
+		if duration <= 0 {
+			duration = uvAnimationPeriod(exported.transform)
+		}
+		if duration <= 0 {
+			continue
+		}
+		// Bake a copy, keeping the speeds of the scene
+		baked := *exported.transform
+		animation := BakeUVAnimation(fmt.Sprintf("uv_animation_%d", i), &baked, "", duration)
+		for j := range animation.Channels {
+			animation.Channels[j].UVTarget = exported.transform
+		}
+		w.animation(animation.Name, animation.Channels)
+	}
+}
+
+// animation writes the channels of an animation that target exported
+// texture transforms as KHR_animation_pointer channels, one per reference
+// of the transform. Other channels are skipped.
+func (w *gltfWriter) animation(name string, channels []AnimationChannel) {
+	gltfAnimation := &gltf.Animation{Name: name}
+	for _, channel := range channels {
+		exported, ok := w.uvIndex[channel.UVTarget]
+		if !ok || len(channel.Keyframes) == 0 {
+			continue
+		}
+		sampler, ok := w.animationSampler(channel)
+		if !ok {
+			continue
+		}
+		gltfAnimation.Samplers = append(gltfAnimation.Samplers, sampler)
+		for _, prefix := range exported.pointers {
+			gltfAnimation.Channels = append(gltfAnimation.Channels, &gltf.AnimationChannel{
+				Sampler: len(gltfAnimation.Samplers) - 1,
+				Target: gltf.AnimationChannelTarget{
+					Path: gltfPointerPath,
+					Extensions: gltf.Extensions{animationPointerExtension: map[string]interface{}{
+						"pointer": textureTransformPropertyPointer(prefix, channel.Property),
+					}},
+				},
+			})
+		}
+	}
+	if len(gltfAnimation.Channels) == 0 {
+		return
+	}
+	w.doc.Animations = append(w.doc.Animations, gltfAnimation)
+	w.useExtension(animationPointerExtension)
+	w.pointers = true
+}
+
+// animationSampler writes the keyframes of a texture transform channel,
+// with their tangents for cubic splines that have them. It reports false
+// when the keyframes don't hold values of the property.
+func (w *gltfWriter) animationSampler(channel AnimationChannel) (*gltf.AnimationSampler, bool) {
+	keyframes := append([]Keyframe(nil), channel.Keyframes...)
+	sort.SliceStable(keyframes, func(i, j int) bool {
+		return keyframes[i].Time < keyframes[j].Time
+	})
+
+	interpolation := gltf.InterpolationLinear
+	switch channel.Interpolation {
+	case Step:
+		interpolation = gltf.InterpolationStep
+	case CubicSpline:
+		interpolation = gltf.InterpolationCubicSpline
+		for _, keyframe := range keyframes {
+			if keyframe.InTangent == nil || keyframe.OutTangent == nil {
+				interpolation = gltf.InterpolationLinear
+			}
+		}
+	}
+
+	values := make([]interface{}, 0, len(keyframes)*3)
+	for _, keyframe := range keyframes {
+		if interpolation == gltf.InterpolationCubicSpline {
+			values = append(values, keyframe.InTangent, keyframe.Value, keyframe.OutTangent)
+		} else {
+			values = append(values, keyframe.Value)
+		}
+	}
+	var output interface{}
+	if channel.Property == TextureRotation {
+		data := make([]float32, len(values))
+		for i, value := range values {
+			r, ok := value.(float64)
+			if !ok {
+				return nil, false
+			}
+			data[i] = float32(r)
+		}
+		output = data
+	} else {
+		data := make([][2]float32, len(values))
+		for i, value := range values {
+			v, ok := value.(Vector)
+			if !ok {
+				return nil, false
+			}
+			data[i] = [2]float32{float32(v.X), float32(v.Y)}
+		}
+		output = data
+	}
+
+	times := make([]float32, len(keyframes))
+	for i, keyframe := range keyframes {
+		times[i] = float32(keyframe.Time)
+	}
+	input := modeler.WriteAccessor(w.doc, gltf.TargetNone, times)
+	// Animation inputs require their bounds
+	w.doc.Accessors[input].Min = []float64{float64(times[0])}
+	w.doc.Accessors[input].Max = []float64{float64(times[len(times)-1])}
+	return &gltf.AnimationSampler{
+		Input:         input,
+		Interpolation: interpolation,
+		Output:        modeler.WriteAccessor(w.doc, gltf.TargetNone, output),
+	}, true
+}
+
+// gltfPointerPath is the path of KHR_animation_pointer channel targets.
+// The TRSProperty of qmuntal/gltf has no "pointer" value, so it is written
+// as an empty path, which patchAnimationPointerPaths replaces after saving.
+const gltfPointerPath = gltf.TRSProperty(math.MaxUint8)
+
+// emptyAnimationPath matches the paths written for gltfPointerPath
+var emptyAnimationPath = regexp.MustCompile(`"path":\s*""`)
+
+// patchAnimationPointerPaths sets the paths of the KHR_animation_pointer
+// channel targets of a saved glTF or GLB file to "pointer"
+func patchAnimationPointerPaths(path string, glb bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	pointerPath := []byte(`"path":"pointer"`)
+	if !glb {
+		return os.WriteFile(path, emptyAnimationPath.ReplaceAll(data, pointerPath), 0644)
+	}
+
+	// A GLB file is a 12 byte header followed by the JSON chunk, padded
+	// with spaces to 4 bytes, and the binary chunk
+	if len(data) < 20 || string(data[16:20]) != "JSON" {
+		return errors.New("invalid GLB file")
+	}
+	end := 20 + int(binary.LittleEndian.Uint32(data[12:16]))
+	if end > len(data) {
+		return errors.New("invalid GLB file")
+	}
+	chunk := bytes.TrimRight(emptyAnimationPath.ReplaceAll(data[20:end], pointerPath), " ")
+	for len(chunk)%4 != 0 {
+		chunk = append(chunk, ' ')
+	}
+	var out bytes.Buffer
+	out.Write(data[:12])
+	binary.Write(&out, binary.LittleEndian, uint32(len(chunk)))
+	out.WriteString("JSON")
+	out.Write(chunk)
+	out.Write(data[end:])
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[8:12], uint32(len(result)))
+	return os.WriteFile(path, result, 0644)
+}
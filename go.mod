@@ -2,4 +2,10 @@ module github.com/swordkee/fauxgl-gltf
 
 go 1.20
 
-require github.com/qmuntal/gltf v0.28.0
+require (
+	github.com/klauspost/compress v1.17.4
+	github.com/qmuntal/gltf v0.28.0
+	golang.org/x/image v0.12.0
+)
+
+require golang.org/x/text v0.13.0 // indirect
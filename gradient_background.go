@@ -0,0 +1,194 @@
+package fauxgl
+
+import (
+	"math"
+	"sort"
+)
+
+// GradientType selects the shape of a GradientBackground
+type GradientType int
+
+const (
+	// LinearGradient runs along a direction across the frame
+	LinearGradient GradientType = iota
+	// RadialGradient runs out from a center, like a lit studio sweep
+	RadialGradient
+)
+
+// GradientStop is a color of a gradient at a position from 0 to 1
+type GradientStop struct {
+	Position float64
+	Color    Color
+}
+
+// GradientFloor mirrors the backdrop of a GradientBackground below a
+// horizon onto a floor, fading the reflection out towards the bottom of
+// the frame
+type GradientFloor struct {
+	Horizon    float64 // Height of the horizon from the top of the frame, 0 to 1
+	Color      Color   // Color of the floor without reflection
+	Reflection float64 // Strength of the reflection at the horizon, 0 to 1
+	Fade       float64 // Fraction of the frame below the horizon over which the reflection fades out
+	Softness   float64 // Fraction of the frame over which the backdrop blends into the floor
+}
+
+// GradientBackground is a studio backdrop drawn in place of a single clear
+// color. It is computed per pixel in floating point, straight into the HDR
+// buffer when the context has one, so tone mapping and grading don't band
+// it; ColorBuffer receives it rounded to 8 bits.
+type GradientBackground struct {
+	Type  GradientType
+	Stops []GradientStop // Sorted by position when drawn
+
+	// Angle is the direction of linear gradients in radians, 0 running
+	// from the bottom of the frame to the top, counterclockwise
+	Angle float64
+	// Center and Radius place radial gradients in normalized frame
+	// coordinates, from 0,0 at the top left to 1,1 at the bottom right;
+	// Radius is relative to half the frame diagonal
+	Center Vector
+	Radius float64
+
+	// Floor, when set, adds a reflective floor below a horizon
+	Floor *GradientFloor
+}
+
+// NewLinearGradientBackground creates a vertical gradient from a bottom
+// color to a top color
+func NewLinearGradientBackground(bottom, top Color) *GradientBackground {
+	return &GradientBackground{
+		Type:   LinearGradient,
+		Stops:  []GradientStop{{0, bottom}, {1, top}},
+		Center: Vector{0.5, 0.5, 0},
+		Radius: 1,
+	}
+}
+
+// NewRadialGradientBackground creates a gradient from a center color in the
+// middle of the frame, slightly above center like a lit sweep, to an edge
+// color in the corners
+func NewRadialGradientBackground(center, edge Color) *GradientBackground {
+	return &GradientBackground{
+		Type:   RadialGradient,
+		Stops:  []GradientStop{{0, center}, {1, edge}},
+		Center: Vector{0.5, 0.4, 0},
+		Radius: 1,
+	}
+}
+
+// NewGradientFloor creates a floor at a horizon reflecting the backdrop at
+// a strength, fading out over the rest of the frame
+func NewGradientFloor(horizon float64, color Color, reflection float64) *GradientFloor {
+	return &GradientFloor{
+		Horizon:    horizon,
+		Color:      color,
+		Reflection: reflection,
+		Fade:       1,
+		Softness:   0.02,
+	}
+}
+
+// Draw fills the color buffer of a context, and its HDR buffer if enabled,
+// with the background, like ClearColorBuffer
+func (bg *GradientBackground) Draw(dc *Context) {
+	stops := append([]GradientStop(nil), bg.Stops...)
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].Position < stops[j].Position
+	})
+	// Map pixels through the inverse screen matrix of the context to frame
+	// coordinates, so tiles and jittered passes draw their part of the
+	// frame, whose aspect ratio is that of the screen matrix
+	inverse := dc.screenMatrix.Inverse()
+	aspect := math.Abs(dc.screenMatrix.X00 / dc.screenMatrix.X11)
+	parallelRows(dc.Height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			i := dc.ColorBuffer.PixOffset(0, y)
+			for x := 0; x < dc.Width; x++ {
+				ndc := inverse.MulPosition(Vector{float64(x) + 0.5, float64(y) + 0.5, 0})
+				c := bg.colorAt(stops, (ndc.X+1)/2, (1-ndc.Y)/2, aspect)
+				if dc.HDRBuffer != nil {
+					dc.HDRBuffer.SetColor(x, y, c)
+				}
+				n := c.NRGBA()
+				dc.ColorBuffer.Pix[i+0] = n.R
+				dc.ColorBuffer.Pix[i+1] = n.G
+				dc.ColorBuffer.Pix[i+2] = n.B
+				dc.ColorBuffer.Pix[i+3] = n.A
+				i += 4
+			}
+		}
+	})
+}
+
+// ColorAt returns the color of the background at normalized frame
+// coordinates, for a frame of an aspect ratio
+func (bg *GradientBackground) ColorAt(x, y, aspect float64) Color {
+	stops := append([]GradientStop(nil), bg.Stops...)
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].Position < stops[j].Position
+	})
+	return bg.colorAt(stops, x, y, aspect)
+}
+
+// colorAt returns the color at normalized frame coordinates, given the
+// sorted stops
+func (bg *GradientBackground) colorAt(stops []GradientStop, x, y, aspect float64) Color {
+	floor := bg.Floor
+	if floor == nil || y < floor.Horizon-floor.Softness/2 {
+		return bg.backdrop(stops, x, y, aspect)
+	}
+	// Mirror the backdrop about the horizon, fading with the distance
+	below := y - floor.Horizon
+	reflection := floor.Reflection
+	if floor.Fade > 0 {
+		reflection *= Clamp(1-math.Max(below, 0)/(floor.Fade*(1-floor.Horizon)+1e-9), 0, 1)
+	}
+	c := floor.Color.Lerp(bg.backdrop(stops, x, floor.Horizon-math.Abs(below), aspect), Clamp(reflection, 0, 1))
+	if floor.Softness > 0 && below < floor.Softness/2 {
+		t := Clamp(below/floor.Softness+0.5, 0, 1)
+		c = bg.backdrop(stops, x, y, aspect).Lerp(c, t*t*(3-2*t))
+	}
+	return c
+}
+
+// backdrop returns the gradient at normalized frame coordinates
+func (bg *GradientBackground) backdrop(stops []GradientStop, x, y, aspect float64) Color {
+	var t float64
+	switch bg.Type {
+	case RadialGradient:
+		// Distances in the frame, square pixels, relative to half the
+		// diagonal
+		dx, dy := (x-bg.Center.X)*aspect, y-bg.Center.Y
+		diagonal := math.Hypot(aspect, 1) / 2
+		t = math.Hypot(dx, dy) / (diagonal * math.Max(bg.Radius, 1e-9))
+	default:
+		// Project onto the direction, spanning the frame corner to corner
+		sin, cos := math.Sincos(bg.Angle)
+		dx, dy := -sin, cos
+		px, py := (x-0.5)*aspect, 0.5-y
+		extent := (math.Abs(dx)*aspect + math.Abs(dy)) / 2
+		t = (px*dx+py*dy)/(extent+1e-9)*0.5 + 0.5
+	}
+	return gradientColor(stops, t)
+}
+
+// gradientColor interpolates sorted gradient stops at a position, clamped
+// to the first and last stops
+func gradientColor(stops []GradientStop, t float64) Color {
+	if len(stops) == 0 {
+		return Transparent
+	}
+	if t <= stops[0].Position {
+		return stops[0].Color
+	}
+	for i := 1; i < len(stops); i++ {
+		a, b := stops[i-1], stops[i]
+		if t <= b.Position {
+			if b.Position <= a.Position {
+				return b.Color
+			}
+			return a.Color.Lerp(b.Color, (t-a.Position)/(b.Position-a.Position))
+		}
+	}
+	return stops[len(stops)-1].Color
+}
@@ -0,0 +1,238 @@
+package fauxgl
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// HDRImage is a floating point image of linear, unclamped colors with
+// straight alpha. It is the HDR color buffer of a Context and the input of
+// HDR post-processing; Resolve converts it to 8 bits.
+type HDRImage struct {
+	Pix    []float32 // R, G, B, A per pixel, rows top to bottom
+	Stride int       // Values between vertically adjacent pixels
+	Rect   image.Rectangle
+}
+
+// NewHDRImage creates a transparent HDR image
+func NewHDRImage(r image.Rectangle) *HDRImage {
+	return &HDRImage{
+		Pix:    make([]float32, 4*r.Dx()*r.Dy()),
+		Stride: 4 * r.Dx(),
+		Rect:   r,
+	}
+}
+
+// NewHDRImageFrom converts an 8-bit image to an HDR image
+func NewHDRImageFrom(img *image.NRGBA) *HDRImage {
+	b := img.Bounds()
+	im := NewHDRImage(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		src := img.Pix[img.PixOffset(b.Min.X, y):img.PixOffset(b.Max.X, y)]
+		dst := im.Pix[im.PixOffset(b.Min.X, y):]
+		for i, v := range src {
+			dst[i] = float32(v) / 255
+		}
+	}
+	return im
+}
+
+func (im *HDRImage) Bounds() image.Rectangle {
+	return im.Rect
+}
+
+func (im *HDRImage) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+// At returns the color of a pixel clamped to 8 bits
+func (im *HDRImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(im.Rect)) {
+		return color.NRGBA{}
+	}
+	return im.ColorAt(x, y).NRGBA()
+}
+
+// PixOffset returns the index of the first value of a pixel in Pix
+func (im *HDRImage) PixOffset(x, y int) int {
+	return (y-im.Rect.Min.Y)*im.Stride + (x-im.Rect.Min.X)*4
+}
+
+// ColorAt returns the color of a pixel
+func (im *HDRImage) ColorAt(x, y int) Color {
+	p := im.Pix[im.PixOffset(x, y):]
+	return Color{float64(p[0]), float64(p[1]), float64(p[2]), float64(p[3])}
+}
+
+// SetColor sets the color of a pixel
+func (im *HDRImage) SetColor(x, y int, c Color) {
+	p := im.Pix[im.PixOffset(x, y):]
+	p[0], p[1], p[2], p[3] = float32(c.R), float32(c.G), float32(c.B), float32(c.A)
+}
+
+// Clear sets every pixel to a color
+func (im *HDRImage) Clear(c Color) {
+	v := [4]float32{float32(c.R), float32(c.G), float32(c.B), float32(c.A)}
+	for i := 0; i < len(im.Pix); i += 4 {
+		copy(im.Pix[i:i+4], v[:])
+	}
+}
+
+// Resolve converts the image to 8 bits, clamping values to [0, 1]. Apply
+// tone mapping first to keep highlights.
+func (im *HDRImage) Resolve() *image.NRGBA {
+	b := im.Rect
+	out := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		src := im.Pix[im.PixOffset(b.Min.X, y):im.PixOffset(b.Max.X, y)]
+		dst := out.Pix[out.PixOffset(b.Min.X, y):]
+		for i, v := range src {
+			dst[i] = uint8(Clamp(float64(v), 0, 1)*255 + 0.5)
+		}
+	}
+	return out
+}
+
+// blend composites a fragment over a pixel like the 8-bit color buffer
+func (im *HDRImage) blend(x, y int, c Color) {
+	im.SetColor(x, y, c.Over(im.ColorAt(x, y)))
+}
+
+// HDRPostProcessingEffect is a post-processing effect that also operates on
+// linear HDR values
+type HDRPostProcessingEffect interface {
+	PostProcessingEffect
+	ApplyHDR(input *HDRImage) *HDRImage
+}
+
+// ProcessHDR applies the effects of the pipeline to an HDR image and
+// resolves it to 8 bits. Effects run on HDR values until the first effect
+// that only supports 8-bit images; tone mapping should come before it.
+func (pp *PostProcessingPipeline) ProcessHDR(input *HDRImage) *image.NRGBA {
+	hdr := input
+	var result *image.NRGBA
+	for _, effect := range pp.Effects {
+		if hdrEffect, ok := effect.(HDRPostProcessingEffect); ok && hdr != nil {
+			hdr = hdrEffect.ApplyHDR(hdr)
+			continue
+		}
+		if hdr != nil {
+			result = hdr.Resolve()
+			hdr = nil
+		}
+		result = effect.Apply(result)
+	}
+	if hdr != nil {
+		result = hdr.Resolve()
+	}
+	return result
+}
+
+// ApplyHDR blurs an HDR image
+func (be *BlurEffect) ApplyHDR(input *HDRImage) *HDRImage {
+	temp := NewHDRImage(input.Rect)
+	output := NewHDRImage(input.Rect)
+	if be.Radius <= 0 {
+		copy(output.Pix, input.Pix)
+		return output
+	}
+	weights := make([]float32, 2*be.Radius+1)
+	for i := range weights {
+		weights[i] = float32(gaussian(float64(i-be.Radius), float64(be.Radius)/2.0))
+	}
+	blurHDR(temp, input, weights, false)
+	blurHDR(output, temp, weights, true)
+	return output
+}
+
+// blurHDR convolves src horizontally or vertically, normalizing the weights
+// at the borders
+func blurHDR(dst, src *HDRImage, weights []float32, vertical bool) {
+	radius := len(weights) / 2
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	step := 4
+	if vertical {
+		step = src.Stride
+	}
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				n, pos := width, x
+				if vertical {
+					n, pos = height, y
+				}
+				i := y*src.Stride + x*4
+				var r, g, b, a, total float32
+				for k, w := range weights {
+					d := k - radius
+					if pos+d < 0 || pos+d >= n {
+						continue
+					}
+					p := src.Pix[i+d*step:]
+					r += p[0] * w
+					g += p[1] * w
+					b += p[2] * w
+					a += p[3] * w
+					total += w
+				}
+				p := dst.Pix[i:]
+				p[0], p[1], p[2], p[3] = r/total, g/total, b/total, a/total
+			}
+		}
+	})
+}
+
+// ApplyHDR adds the blurred parts of an HDR image whose average channel
+// value exceeds the threshold, without clamping them
+func (be *BloomEffect) ApplyHDR(input *HDRImage) *HDRImage {
+	bright := NewHDRImage(input.Rect)
+	for i := 0; i < len(input.Pix); i += 4 {
+		p := input.Pix[i : i+4]
+		if float64(p[0]+p[1]+p[2])/3 > be.Threshold {
+			copy(bright.Pix[i:i+3], p[:3])
+		}
+		bright.Pix[i+3] = p[3]
+	}
+	blurred := NewBlurEffect(be.BlurRadius).ApplyHDR(bright)
+
+	output := NewHDRImage(input.Rect)
+	intensity := float32(be.Intensity)
+	for i := 0; i < len(input.Pix); i += 4 {
+		output.Pix[i] = input.Pix[i] + blurred.Pix[i]*intensity
+		output.Pix[i+1] = input.Pix[i+1] + blurred.Pix[i+1]*intensity
+		output.Pix[i+2] = input.Pix[i+2] + blurred.Pix[i+2]*intensity
+		output.Pix[i+3] = input.Pix[i+3]
+	}
+	return output
+}
+
+// ApplyHDR tone maps an HDR image to display values between 0 and 1
+func (tme *ToneMappingEffect) ApplyHDR(input *HDRImage) *HDRImage {
+	output := NewHDRImage(input.Rect)
+	exposure := math.Pow(2.0, tme.Exposure)
+	parallelRows(input.Rect.Dy(), func(y0, y1 int) {
+		for i := y0 * input.Stride; i < y1*input.Stride; i += 4 {
+			for c := 0; c < 3; c++ {
+				v := math.Max(float64(input.Pix[i+c]), 0) * exposure
+				v = v / (v + 1.0)
+				output.Pix[i+c] = float32(math.Pow(v, 1.0/tme.Gamma))
+			}
+			output.Pix[i+3] = input.Pix[i+3]
+		}
+	})
+	return output
+}
+
+// ApplyHDR converts the colors of an HDR image between color spaces
+func (cse *ColorSpaceEffect) ApplyHDR(input *HDRImage) *HDRImage {
+	output := NewHDRImage(input.Rect)
+	parallelRows(input.Rect.Dy(), func(y0, y1 int) {
+		for i := y0 * input.Stride; i < y1*input.Stride; i += 4 {
+			p := input.Pix[i : i+4]
+			c := Color{float64(p[0]), float64(p[1]), float64(p[2]), float64(p[3])}.Convert(cse.From, cse.To)
+			output.Pix[i], output.Pix[i+1], output.Pix[i+2], output.Pix[i+3] = float32(c.R), float32(c.G), float32(c.B), p[3]
+		}
+	})
+	return output
+}
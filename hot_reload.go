@@ -0,0 +1,208 @@
+package fauxgl
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qmuntal/gltf"
+)
+
+// SceneReloader re-imports a glTF file into a live scene when the file, or
+// a buffer or image it references, changes on disk, for iterating on assets
+// while an interactive viewer shows them. The nodes, meshes, materials,
+// textures and animations of the scene are replaced; its cameras are kept,
+// and so are its lights unless the file defines lights. Textures loaded
+// outside the glTF file can be watched too, see WatchTexture.
+//
+// Files are polled rather than watched, from the application's own loop,
+// so the scene is never swapped while it is being rendered. A change is
+// picked up once a file stays the same for one Interval, so files still
+// being written are not read.
+type SceneReloader struct {
+	Scene *Scene
+	Path  string
+	// Interval is the least time between two checks of the files
+	Interval time.Duration
+	// Apply, when set, is called after every reload to reapply the
+	// application's overrides, e.g. material tweaks, to the new nodes and
+	// materials
+	Apply func(scene *Scene)
+
+	files     []*watchedFile // The glTF file and its dependencies
+	textures  []*watchedTexture
+	lastCheck time.Time
+}
+
+// fileStamp identifies a version of a file; the zero stamp is a missing
+// file
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+func statFile(name string) fileStamp {
+	info, err := os.Stat(name)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{info.ModTime().UnixNano(), info.Size()}
+}
+
+// watchedFile is a file at the version last loaded and the version seen by
+// the last check
+type watchedFile struct {
+	path         string
+	loaded, seen fileStamp
+}
+
+// changed checks the file and reports whether it changed since it was
+// loaded and has stayed the same since the previous check
+func (f *watchedFile) changed() bool {
+	stamp := statFile(f.path)
+	stable := stamp == f.seen
+	f.seen = stamp
+	return stamp != f.loaded && stable
+}
+
+type watchedTexture struct {
+	watchedFile
+	texture *AdvancedTexture
+}
+
+// NewSceneReloader watches the glTF file at path, from which scene was
+// loaded, and its external buffers and images
+func NewSceneReloader(scene *Scene, path string) (*SceneReloader, error) {
+	r := &SceneReloader{Scene: scene, Path: path, Interval: 500 * time.Millisecond}
+	if err := r.watchDocument(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// watchDocument records the current versions of the glTF file and of the
+// files it references
+func (r *SceneReloader) watchDocument() error {
+	doc, err := gltf.Open(r.Path)
+	if err != nil {
+		return err
+	}
+	paths := []string{r.Path}
+	dir := filepath.Dir(r.Path)
+	addURI := func(uri string) {
+		if uri == "" || strings.HasPrefix(uri, "data:") {
+			return
+		}
+		if unescaped, err := url.PathUnescape(uri); err == nil {
+			uri = unescaped
+		}
+		uri = path.Clean(uri)
+		if IsUDIMPath(uri) {
+			return // The tiles are only known once loaded
+		}
+		paths = append(paths, filepath.Join(dir, filepath.FromSlash(uri)))
+	}
+	for _, buffer := range doc.Buffers {
+		addURI(buffer.URI)
+	}
+	for _, image := range doc.Images {
+		addURI(image.URI)
+	}
+	r.files = r.files[:0]
+	for _, name := range paths {
+		stamp := statFile(name)
+		r.files = append(r.files, &watchedFile{path: name, loaded: stamp, seen: stamp})
+	}
+	return nil
+}
+
+// WatchTexture reloads a texture in place from the image file at path when
+// the file changes, keeping its sampling settings, so the materials using
+// it pick up the new image. Textures of the glTF file itself are replaced
+// by the reload of the file instead.
+func (r *SceneReloader) WatchTexture(path string, texture *AdvancedTexture) {
+	stamp := statFile(path)
+	r.textures = append(r.textures, &watchedTexture{watchedFile{path, stamp, stamp}, texture})
+}
+
+// Poll checks the watched files, at most once per Interval, and reloads
+// those that changed. It reports whether the scene changed. When loading
+// fails the scene is left as it was and the file is retried on its next
+// change.
+func (r *SceneReloader) Poll() (bool, error) {
+	now := time.Now()
+	if now.Sub(r.lastCheck) < r.Interval {
+		return false, nil
+	}
+	r.lastCheck = now
+
+	reloaded := false
+	var errs []string
+	documentChanged := false
+	for _, file := range r.files {
+		if file.changed() {
+			documentChanged = true
+		}
+	}
+	if documentChanged {
+		if err := r.reloadDocument(); err != nil {
+			errs = append(errs, err.Error())
+			for _, file := range r.files {
+				file.loaded = file.seen
+			}
+		} else {
+			reloaded = true
+		}
+	}
+	for _, watched := range r.textures {
+		if !watched.changed() {
+			continue
+		}
+		watched.loaded = watched.seen
+		loaded, err := LoadAdvancedTexture(watched.path, watched.texture.Type)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to reload %s: %v", watched.path, err))
+			continue
+		}
+		texture := watched.texture
+		texture.Image, texture.Width, texture.Height = loaded.Image, loaded.Width, loaded.Height
+		texture.MipLevels, texture.mipmaps = loaded.MipLevels, loaded.mipmaps
+		reloaded = true
+	}
+	if reloaded && r.Apply != nil {
+		r.Apply(r.Scene)
+	}
+	if len(errs) > 0 {
+		return reloaded, fmt.Errorf("hot reload: %s", strings.Join(errs, "; "))
+	}
+	return reloaded, nil
+}
+
+// reloadDocument loads the glTF file and swaps its contents into the scene
+func (r *SceneReloader) reloadDocument() error {
+	loaded, err := LoadGLTFScene(r.Path)
+	if err != nil {
+		return fmt.Errorf("failed to reload %s: %w", r.Path, err)
+	}
+	scene := r.Scene
+	scene.RootNode = loaded.RootNode
+	scene.Materials = loaded.Materials
+	scene.Textures = loaded.Textures
+	scene.Meshes = loaded.Meshes
+	scene.Animations = loaded.Animations
+	scene.Skins = loaded.Skins
+	scene.MorphTargets = loaded.MorphTargets
+	scene.Extensions = loaded.Extensions
+	scene.Generator = loaded.Generator
+	if len(loaded.Lights) > 0 {
+		scene.Lights = loaded.Lights
+	}
+	if len(scene.Cameras) == 0 {
+		scene.Cameras, scene.ActiveCamera = loaded.Cameras, loaded.ActiveCamera
+	}
+	return r.watchDocument()
+}
@@ -0,0 +1,99 @@
+package fauxgl
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+
+	"golang.org/x/image/tiff"
+)
+
+// SavePNG16 saves an image as a 16-bit PNG file, e.g. a frame graded in
+// floating point or a Gray16 pass, against the banding of 8 bits. HDR
+// images are clamped to [0, 1]; apply tone mapping first to keep highlights.
+func SavePNG16(path string, im image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, to16Bit(im)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// SaveTIFF saves an image as a Deflate compressed 16-bit TIFF file, like
+// SavePNG16
+func SaveTIFF(path string, im image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := tiff.Encode(file, to16Bit(im), &tiff.Options{Compression: tiff.Deflate}); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// to16Bit returns the image with 16 bits per channel: 16-bit images as is,
+// gray images as Gray16 and the others as NRGBA64
+func to16Bit(im image.Image) image.Image {
+	bounds := im.Bounds()
+	switch src := im.(type) {
+	case *image.NRGBA64, *image.RGBA64, *image.Gray16:
+		return im
+	case *image.Gray:
+		dst := image.NewGray16(bounds)
+		draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
+		return dst
+	case *HDRImage:
+		dst := image.NewNRGBA64(bounds)
+		parallelRows(bounds.Dy(), func(y0, y1 int) {
+			for y := bounds.Min.Y + y0; y < bounds.Min.Y+y1; y++ {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					c := src.ColorAt(x, y)
+					dst.SetNRGBA64(x, y, color.NRGBA64{
+						R: unit16(c.R),
+						G: unit16(c.G),
+						B: unit16(c.B),
+						A: unit16(c.A),
+					})
+				}
+			}
+		})
+		return dst
+	}
+	dst := image.NewNRGBA64(bounds)
+	draw.Draw(dst, bounds, im, bounds.Min, draw.Src)
+	return dst
+}
+
+// unit16 quantizes a value in [0, 1] to 16 bits, clamping it
+func unit16(x float64) uint16 {
+	return uint16(Clamp(x, 0, 1)*0xffff + 0.5)
+}
+
+// AlphaImage returns the alpha channel of an image, e.g. a rendered frame,
+// as a 16-bit grayscale matte for compositing
+func AlphaImage(im image.Image) *image.Gray16 {
+	bounds := im.Bounds()
+	dst := image.NewGray16(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var a uint16
+			switch src := im.(type) {
+			case *HDRImage:
+				a = unit16(src.ColorAt(x, y).A)
+			default:
+				_, _, _, a32 := im.At(x, y).RGBA()
+				a = uint16(a32)
+			}
+			dst.SetGray16(x, y, color.Gray16{a})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,63 @@
+package fauxgl
+
+// IndexedMesh stores triangles as an array of distinct vertices and three
+// indices into it per triangle, like a vertex and an index buffer. Shared
+// vertices are stored and shaded once, see Context.DrawIndexedMesh.
+type IndexedMesh struct {
+	Vertices []Vertex
+	Indices  []int // Three per triangle
+	Lines    []*Line
+	// MaterialIDs optionally holds the material of each triangle, as
+	// Mesh.MaterialIDs
+	MaterialIDs []int
+}
+
+// Indexed returns the mesh as an indexed mesh, sharing the vertices whose
+// attributes are all equal. Weld the mesh first to share vertices that are
+// only nearly coincident.
+func (m *Mesh) Indexed() *IndexedMesh {
+	index := make(map[Vertex]int)
+	mesh := &IndexedMesh{
+		Indices: make([]int, 0, 3*len(m.Triangles)),
+		Lines:   m.Lines,
+	}
+	for _, t := range m.Triangles {
+		for _, v := range [3]Vertex{t.V1, t.V2, t.V3} {
+			i, ok := index[v]
+			if !ok {
+				i = len(mesh.Vertices)
+				index[v] = i
+				mesh.Vertices = append(mesh.Vertices, v)
+			}
+			mesh.Indices = append(mesh.Indices, i)
+		}
+	}
+	if m.hasMaterialIDs() {
+		mesh.MaterialIDs = append([]int(nil), m.MaterialIDs...)
+	}
+	return mesh
+}
+
+// Triangles returns the number of triangles
+func (m *IndexedMesh) Triangles() int {
+	return len(m.Indices) / 3
+}
+
+// Triangle returns the vertices of a triangle
+func (m *IndexedMesh) Triangle(i int) (Vertex, Vertex, Vertex) {
+	return m.Vertices[m.Indices[3*i]], m.Vertices[m.Indices[3*i+1]], m.Vertices[m.Indices[3*i+2]]
+}
+
+// Mesh expands the indexed mesh into a mesh of independent triangles
+func (m *IndexedMesh) Mesh() *Mesh {
+	triangles := make([]*Triangle, m.Triangles())
+	for i := range triangles {
+		v1, v2, v3 := m.Triangle(i)
+		triangles[i] = &Triangle{v1, v2, v3}
+	}
+	mesh := NewMesh(triangles, m.Lines)
+	if len(m.MaterialIDs) == len(triangles) {
+		mesh.MaterialIDs = append([]int(nil), m.MaterialIDs...)
+	}
+	return mesh
+}
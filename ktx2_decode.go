@@ -0,0 +1,252 @@
+package fauxgl
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// VkFormat values of the uncompressed 8-bit formats KTX2 levels can be
+// decoded from
+const (
+	vkFormatR8Unorm       = 9
+	vkFormatR8SRGB        = 15
+	vkFormatR8G8Unorm     = 16
+	vkFormatR8G8SRGB      = 22
+	vkFormatR8G8B8Unorm   = 23
+	vkFormatR8G8B8SRGB    = 29
+	vkFormatB8G8R8Unorm   = 30
+	vkFormatB8G8R8SRGB    = 36
+	vkFormatR8G8B8A8Unorm = 37
+	vkFormatR8G8B8A8SRGB  = 43
+	vkFormatB8G8R8A8Unorm = 44
+	vkFormatB8G8R8A8SRGB  = 50
+)
+
+// DFD color models of Basis Universal payloads
+const (
+	dfdModelETC1S = 163
+	dfdModelUASTC = 166
+)
+
+func init() {
+	// Let image.Decode, LoadImage and the glTF loader read KTX2 files
+	image.RegisterFormat("ktx2", string(KTX2_MAGIC[:]), decodeKTX2, decodeKTX2Config)
+}
+
+func decodeKTX2(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := NewKTX2Reader(data)
+	if err != nil {
+		return nil, err
+	}
+	return reader.DecodeLevel(0)
+}
+
+func decodeKTX2Config(r io.Reader) (image.Config, error) {
+	data := make([]byte, HeaderLength)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return image.Config{}, err
+	}
+	header, err := HeaderFromBytes(data)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: image.NewNRGBA(image.Rect(0, 0, 1, 1)).ColorModel(),
+		Width:      int(header.PixelWidth),
+		Height:     int(maxUint32(header.PixelHeight, 1)),
+	}, nil
+}
+
+// ErrUASTCUnsupported is returned for KTX2 images holding UASTC payloads of
+// Basis Universal, which aren't transcoded
+var ErrUASTCUnsupported = errors.New("UASTC transcoding is not supported, only BasisLZ (ETC1S)")
+
+// DecodeLevels decodes every mip level of the first layer and face, level 0
+// first. Zstd and Zlib supercompressed levels are inflated and BasisLZ
+// (ETC1S) payloads are transcoded to RGBA. UASTC payloads of Basis
+// Universal fail with ErrUASTCUnsupported.
+func (r *Reader) DecodeLevels() ([]image.Image, error) {
+	levels, err := r.Levels()
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := r.newLevelDecoder()
+	if err != nil {
+		return nil, err
+	}
+	images := make([]image.Image, len(levels))
+	for i, level := range levels {
+		img, err := decoder.decode(i, level)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode KTX2 level %d: %w", i, err)
+		}
+		images[i] = img
+	}
+	return images, nil
+}
+
+// DecodeLevel decodes one mip level of the first layer and face
+func (r *Reader) DecodeLevel(index int) (image.Image, error) {
+	levels, err := r.Levels()
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(levels) {
+		return nil, fmt.Errorf("KTX2 level %d out of range", index)
+	}
+	decoder, err := r.newLevelDecoder()
+	if err != nil {
+		return nil, err
+	}
+	img, err := decoder.decode(index, levels[index])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode KTX2 level %d: %w", index, err)
+	}
+	return img, nil
+}
+
+// ktx2LevelDecoder decodes the levels of one file
+type ktx2LevelDecoder struct {
+	header         *Header
+	format         uint32
+	scheme         SupercompressionScheme
+	colorModel     uint8
+	imagesPerLevel int
+	etc1s          *etc1sTranscoder
+}
+
+func (r *Reader) newLevelDecoder() (*ktx2LevelDecoder, error) {
+	header := r.header
+	if header.SupercompressionScheme == nil {
+		return nil, errors.New("unsupported KTX2 supercompression scheme")
+	}
+	if header.PixelDepth > 1 {
+		return nil, errors.New("3D KTX2 textures are not supported")
+	}
+
+	d := &ktx2LevelDecoder{
+		header:         header,
+		scheme:         *header.SupercompressionScheme,
+		imagesPerLevel: int(maxUint32(header.LayerCount, 1) * header.FaceCount),
+	}
+	if header.Format != nil {
+		d.format = header.Format.Value()
+	}
+	if blocks, err := r.DFDBlocks(); err == nil && len(blocks) > 0 {
+		if basic, err := DFDBlockHeaderBasicFromBytes(blocks[0].Data); err == nil && basic.ColorModel != nil {
+			d.colorModel = basic.ColorModel.Value()
+		}
+	}
+
+	if d.scheme == SupercompressionBasisLZ {
+		levelCount := int(maxUint32(header.LevelCount, 1))
+		etc1s, err := newETC1STranscoder(r.SupercompressionGlobalData(), levelCount*d.imagesPerLevel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read BasisLZ global data: %w", err)
+		}
+		d.etc1s = etc1s
+	}
+	return d, nil
+}
+
+// decode decodes the first image of a mip level
+func (d *ktx2LevelDecoder) decode(index int, level *Level) (image.Image, error) {
+	width := int(maxUint32(d.header.PixelWidth>>uint(index), 1))
+	height := int(maxUint32(d.header.PixelHeight>>uint(index), 1))
+
+	if d.etc1s != nil {
+		return d.etc1s.decodeImage(width, height, d.etc1s.images[index*d.imagesPerLevel], level.Data)
+	}
+
+	data := level.Data
+	switch d.scheme {
+	case SupercompressionZstd:
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		data, err = decoder.DecodeAll(data, make([]byte, 0, level.UncompressedByteLength))
+		if err != nil {
+			return nil, fmt.Errorf("failed to inflate Zstd level: %w", err)
+		}
+	case SupercompressionZLIB:
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to inflate Zlib level: %w", err)
+		}
+		data, err = io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inflate Zlib level: %w", err)
+		}
+	}
+
+	if d.format == 0 {
+		if d.colorModel == dfdModelUASTC {
+			return nil, ErrUASTCUnsupported
+		}
+		return nil, fmt.Errorf("unsupported KTX2 color model %d", d.colorModel)
+	}
+	return decodeKTX2Pixels(d.format, width, height, data)
+}
+
+// decodeKTX2Pixels converts tightly packed 8-bit texels to an NRGBA image
+func decodeKTX2Pixels(format uint32, width, height int, data []byte) (*image.NRGBA, error) {
+	var channels int
+	bgr := false
+	switch format {
+	case vkFormatR8Unorm, vkFormatR8SRGB:
+		channels = 1
+	case vkFormatR8G8Unorm, vkFormatR8G8SRGB:
+		channels = 2
+	case vkFormatR8G8B8Unorm, vkFormatR8G8B8SRGB:
+		channels = 3
+	case vkFormatB8G8R8Unorm, vkFormatB8G8R8SRGB:
+		channels, bgr = 3, true
+	case vkFormatR8G8B8A8Unorm, vkFormatR8G8B8A8SRGB:
+		channels = 4
+	case vkFormatB8G8R8A8Unorm, vkFormatB8G8R8A8SRGB:
+		channels, bgr = 4, true
+	default:
+		return nil, fmt.Errorf("unsupported KTX2 format %d", format)
+	}
+	if len(data) < width*height*channels {
+		return nil, UnexpectedEnd
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < width*height; i++ {
+		src := data[i*channels : (i+1)*channels]
+		dst := img.Pix[i*4 : i*4+4]
+		dst[3] = 255
+		switch channels {
+		case 1:
+			dst[0] = src[0]
+		case 2:
+			dst[0], dst[1] = src[0], src[1]
+		default:
+			copy(dst, src)
+			if bgr {
+				dst[0], dst[2] = dst[2], dst[0]
+			}
+		}
+	}
+	return img, nil
+}
+
+func maxUint32(a, b uint32) uint32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,253 @@
+package fauxgl
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DFD channel types of ETC1S slices and RGBSDA samples
+const (
+	dfdChannelETC1SRGB = 0
+	dfdChannelETC1SAAA = 15
+	dfdChannelRed      = 0
+	dfdChannelGreen    = 1
+	dfdChannelBlue     = 2
+	dfdChannelAlpha    = 15
+)
+
+// ktx2Writer assembles a KTX2 file from its parts
+type ktx2Writer struct {
+	header   Header
+	colorDFD DFDBlockHeaderBasic
+	samples  []SampleInformation
+	keyValue []KeyValuePair
+	sgd      []byte
+	levels   [][]byte // Level 0 first
+	// Uncompressed byte length of every level, 0 for BasisLZ
+	uncompressed []uint64
+}
+
+// bytes lays out the file: header, level index, DFD, key/value data,
+// supercompression global data and the levels, smallest first
+func (k *ktx2Writer) bytes() []byte {
+	levelCount := len(k.levels)
+	offset := HeaderLength + levelCount*LevelIndexLength
+
+	// Data format descriptor: total size, block header, basic block and
+	// samples
+	blockSize := DFDHeaderLength + DFDBlockHeaderBasicLength + len(k.samples)*SampleInformationLength
+	dfd := make([]byte, 4, 4+blockSize)
+	binary.LittleEndian.PutUint32(dfd, uint32(4+blockSize))
+	dfd = append(dfd, DFDHeaderBasic.AsBytes(uint16(blockSize))...)
+	dfd = append(dfd, k.colorDFD.AsBytes()...)
+	for i := range k.samples {
+		dfd = append(dfd, k.samples[i].AsBytes()...)
+	}
+	k.header.Index.DFDByteOffset = uint32(offset)
+	k.header.Index.DFDByteLength = uint32(len(dfd))
+	offset += len(dfd)
+
+	var kvd []byte
+	for _, pair := range k.keyValue {
+		entry := append(append([]byte(pair.Key), 0), pair.Value...)
+		var length [4]byte
+		binary.LittleEndian.PutUint32(length[:], uint32(len(entry)))
+		kvd = append(kvd, length[:]...)
+		kvd = append(kvd, entry...)
+		for len(kvd)%4 != 0 {
+			kvd = append(kvd, 0)
+		}
+	}
+	if len(kvd) > 0 {
+		k.header.Index.KVDByteOffset = uint32(offset)
+		k.header.Index.KVDByteLength = uint32(len(kvd))
+		offset += len(kvd)
+	}
+
+	sgdPadding := 0
+	if len(k.sgd) > 0 {
+		sgdPadding = (8 - offset%8) % 8
+		offset += sgdPadding
+		k.header.Index.SGDByteOffset = uint64(offset)
+		k.header.Index.SGDByteLength = uint64(len(k.sgd))
+		offset += len(k.sgd)
+	}
+
+	// Levels are stored smallest first
+	indices := make([]LevelIndex, levelCount)
+	for i := levelCount - 1; i >= 0; i-- {
+		indices[i] = LevelIndex{
+			ByteOffset: uint64(offset),
+			ByteLength: uint64(len(k.levels[i])),
+		}
+		if i < len(k.uncompressed) {
+			indices[i].UncompressedByteLength = k.uncompressed[i]
+		}
+		offset += len(k.levels[i])
+	}
+
+	k.header.LevelCount = uint32(levelCount)
+	out := make([]byte, 0, offset)
+	out = append(out, k.header.AsBytes()...)
+	for i := range indices {
+		out = append(out, indices[i].AsBytes()...)
+	}
+	out = append(out, dfd...)
+	out = append(out, kvd...)
+	out = append(out, make([]byte, sgdPadding)...)
+	out = append(out, k.sgd...)
+	for i := levelCount - 1; i >= 0; i-- {
+		out = append(out, k.levels[i]...)
+	}
+	return out
+}
+
+// textureLevels returns the mip chain of a texture as NRGBA images, level
+// 0 first, generating it when the texture has none
+func textureLevels(texture *AdvancedTexture) []*image.NRGBA {
+	sources := texture.MipLevels
+	if len(sources) == 0 {
+		generated := &AdvancedTexture{Image: texture.Image, Width: texture.Width, Height: texture.Height}
+		generated.GenerateMipmaps()
+		sources = generated.MipLevels
+	}
+	levels := make([]*image.NRGBA, len(sources))
+	for i, level := range sources {
+		levels[i] = toNRGBA(level)
+	}
+	return levels
+}
+
+// hasTransparency reports whether any pixel of an image is not opaque
+func hasTransparency(img *image.NRGBA) bool {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):img.PixOffset(b.Max.X, y)]
+		for i := 3; i < len(row); i += 4 {
+			if row[i] != 255 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// encodeKTX2ETC1S encodes a texture and its mip chain as a BasisLZ (ETC1S)
+// KTX2 file, the payload of KHR_texture_basisu. Color textures use the sRGB
+// transfer function, data textures such as normal maps the linear one.
+func encodeKTX2ETC1S(texture *AdvancedTexture, srgb bool) []byte {
+	levels := textureLevels(texture)
+	alpha := hasTransparency(levels[0])
+	sgd, payloads := encodeETC1S(levels, alpha)
+
+	b := levels[0].Bounds()
+	k := &ktx2Writer{sgd: sgd, levels: payloads}
+	k.header = Header{
+		TypeSize:               1,
+		PixelWidth:             uint32(b.Dx()),
+		PixelHeight:            uint32(b.Dy()),
+		FaceCount:              1,
+		SupercompressionScheme: NewSupercompressionScheme(uint32(SupercompressionBasisLZ)),
+	}
+
+	transfer := TransferFunctionLinear
+	if srgb {
+		transfer = TransferFunctionSRGB
+	}
+	k.colorDFD = DFDBlockHeaderBasic{
+		ColorModel:           NewColorModel(dfdModelETC1S),
+		ColorPrimaries:       NewColorPrimaries(uint8(ColorPrimariesBT709)),
+		TransferFunction:     NewTransferFunction(uint8(transfer)),
+		TexelBlockDimensions: [4]uint8{4, 4, 1, 1},
+	}
+	k.samples = []SampleInformation{{BitLength: 64, ChannelType: dfdChannelETC1SRGB, Upper: 0xFFFFFFFF}}
+	if alpha {
+		k.samples = append(k.samples, SampleInformation{BitOffset: 64, BitLength: 64, ChannelType: dfdChannelETC1SAAA, Upper: 0xFFFFFFFF})
+	}
+	k.keyValue = []KeyValuePair{{Key: "KTXwriter", Value: append([]byte("fauxgl"), 0)}}
+	return k.bytes()
+}
+
+// EncodeKTX2Texture encodes a texture and its mip chain as an RGBA8 KTX2
+// file with Zstd supercompressed levels. Textures without mip levels get a
+// generated chain. Color textures should pass srgb true; data such as
+// ambient occlusion, normal or roughness maps is stored linear.
+func EncodeKTX2Texture(texture *AdvancedTexture, srgb bool) ([]byte, error) {
+	if texture == nil || texture.Image == nil {
+		return nil, fmt.Errorf("failed to encode KTX2 texture: no image")
+	}
+	levels := textureLevels(texture)
+
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode KTX2 texture: %w", err)
+	}
+	defer encoder.Close()
+
+	k := &ktx2Writer{
+		levels:       make([][]byte, len(levels)),
+		uncompressed: make([]uint64, len(levels)),
+	}
+	for i, level := range levels {
+		// Tightly packed rows; toNRGBA images may have a larger stride
+		b := level.Bounds()
+		pixels := make([]byte, 0, b.Dx()*b.Dy()*4)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			pixels = append(pixels, level.Pix[level.PixOffset(b.Min.X, y):level.PixOffset(b.Max.X, y)]...)
+		}
+		k.levels[i] = encoder.EncodeAll(pixels, nil)
+		k.uncompressed[i] = uint64(len(pixels))
+	}
+
+	format := uint32(vkFormatR8G8B8A8Unorm)
+	transfer := TransferFunctionLinear
+	alphaQualifiers := ChannelTypeQualifiers(0)
+	if srgb {
+		// Alpha stays linear in sRGB formats
+		format = vkFormatR8G8B8A8SRGB
+		transfer = TransferFunctionSRGB
+		alphaQualifiers = QualifierLinear
+	}
+
+	b := levels[0].Bounds()
+	k.header = Header{
+		Format:                 NewFormat(format),
+		TypeSize:               1,
+		PixelWidth:             uint32(b.Dx()),
+		PixelHeight:            uint32(b.Dy()),
+		FaceCount:              1,
+		SupercompressionScheme: NewSupercompressionScheme(uint32(SupercompressionZstd)),
+	}
+	k.colorDFD = DFDBlockHeaderBasic{
+		ColorModel:       NewColorModel(uint8(ColorModelRGBSDA)),
+		ColorPrimaries:   NewColorPrimaries(uint8(ColorPrimariesBT709)),
+		TransferFunction: NewTransferFunction(uint8(transfer)),
+		BytesPlanes:      [8]uint8{4},
+	}
+	for i, channel := range []uint8{dfdChannelRed, dfdChannelGreen, dfdChannelBlue, dfdChannelAlpha} {
+		sample := SampleInformation{BitOffset: uint16(8 * i), BitLength: 8, ChannelType: channel, Upper: 255}
+		if channel == dfdChannelAlpha {
+			sample.ChannelTypeQualifiers = alphaQualifiers
+		}
+		k.samples = append(k.samples, sample)
+	}
+	k.keyValue = []KeyValuePair{{Key: "KTXwriter", Value: append([]byte("fauxgl"), 0)}}
+	return k.bytes(), nil
+}
+
+// SaveKTX2Texture writes a texture and its mip chain to a KTX2 file, see
+// EncodeKTX2Texture
+func SaveKTX2Texture(path string, texture *AdvancedTexture, srgb bool) error {
+	data, err := EncodeKTX2Texture(texture, srgb)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write KTX2 file: %w", err)
+	}
+	return nil
+}
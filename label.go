@@ -0,0 +1,275 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// labelTexels is the resolution of the distance fields of labels, in texels
+// per em, and labelSpread the distance in texels they reach out of the
+// glyphs
+const (
+	labelTexels = 48
+	labelSpread = 8
+)
+
+// Label is a line or lines of text annotating a scene node, such as a part
+// name or a dimension, drawn by the SceneRenderer as a billboard facing the
+// camera over the node, see SceneNode.Labels. Glyphs are drawn from a signed
+// distance field, so their edges stay crisp at any size.
+type Label struct {
+	Text  string
+	Font  *Font // nil uses DefaultFont
+	Align TextAlign
+	// Offset places the label relative to the node, in its local space
+	Offset Vector
+	// Anchor is the point of the label placed at Offset, from 0,0 at the
+	// bottom left of its text to 1,1 at the top right
+	Anchor Vector
+	// Size is the font size, the height of an em, in world units, or in
+	// output pixels at any distance when PixelSize is set
+	Size      float64
+	PixelSize bool
+	Color     Color
+	// Outline draws a border around the glyphs OutlineWidth output pixels
+	// wide, to keep them readable over any background
+	Outline      Color
+	OutlineWidth float64
+	// Background fills the box of the label behind the text
+	Background Color
+	// OnTop draws the label over the geometry in front of it
+	OnTop bool
+
+	field *labelField
+}
+
+// NewLabel creates a white label of a text with a black outline, 16
+// pixels high, centered above the node
+func NewLabel(text string) *Label {
+	return &Label{
+		Text:         text,
+		Align:        TextAlignCenter,
+		Anchor:       Vector{0.5, 0, 0},
+		Size:         16,
+		PixelSize:    true,
+		Color:        White,
+		Outline:      Black,
+		OutlineWidth: 1.5,
+	}
+}
+
+// labelField is the signed distance field of the text of a label, in
+// texels, negative inside the glyphs, with its box in em units
+type labelField struct {
+	text          string
+	font          *Font
+	align         TextAlign
+	width, height int
+	distance      []float64
+	bounds        Box
+}
+
+// distanceField returns the distance field of the text of the label,
+// generating it when the text or font changed
+func (label *Label) distanceField() *labelField {
+	f := label.Font
+	if f == nil {
+		f = DefaultFont()
+	}
+	if field := label.field; field != nil && field.text == label.Text && field.font == f && field.align == label.Align {
+		return field
+	}
+	label.field = newLabelField(f, label.Text, label.Align)
+	return label.field
+}
+
+// newLabelField rasterizes the outline of a text and measures the distance
+// of every texel to its edges
+func newLabelField(f *Font, text string, align TextAlign) *labelField {
+	field := &labelField{text: text, font: f, align: align}
+	contours := f.Outline(text, align)
+	if len(contours) == 0 {
+		return field
+	}
+	bounds := Box{contours[0][0], contours[0][0]}
+	for _, contour := range contours {
+		for _, p := range contour {
+			bounds = Box{bounds.Min.Min(p), bounds.Max.Max(p)}
+		}
+	}
+	margin := float64(labelSpread) / labelTexels
+	bounds.Min = bounds.Min.Sub(Vector{margin, margin, 0})
+	bounds.Max = bounds.Max.Add(Vector{margin, margin, 0})
+	w := int(math.Ceil(bounds.Size().X * labelTexels))
+	h := int(math.Ceil(bounds.Size().Y * labelTexels))
+	bounds.Max = bounds.Min.Add(Vector{float64(w) / labelTexels, float64(h) / labelTexels, 0})
+
+	// Coverage with the top row of the image at the top of the box
+	z := vector.NewRasterizer(w, h)
+	for _, contour := range contours {
+		for i, p := range contour {
+			x, y := float32((p.X-bounds.Min.X)*labelTexels), float32((bounds.Max.Y-p.Y)*labelTexels)
+			if i == 0 {
+				z.MoveTo(x, y)
+			} else {
+				z.LineTo(x, y)
+			}
+		}
+		z.ClosePath()
+	}
+	coverage := image.NewAlpha(image.Rect(0, 0, w, h))
+	z.Draw(coverage, coverage.Bounds(), image.Opaque, image.Point{})
+
+	// Distances to the nearest texel across the edge, refined by the
+	// coverage of the texels on it
+	inside := make([]int, w*h)
+	outside := make([]int, w*h)
+	for i, a := range coverage.Pix {
+		if a >= 128 {
+			inside[i] = 1
+		} else {
+			outside[i] = 1
+		}
+	}
+	limit := float64(labelSpread) + 1
+	toInside := selectionDistance(inside, w, h, limit)
+	toOutside := selectionDistance(outside, w, h, limit)
+	field.distance = make([]float64, w*h)
+	for i, a := range coverage.Pix {
+		switch {
+		case a > 0 && a < 255:
+			field.distance[i] = 0.5 - float64(a)/255
+		case inside[i] != 0:
+			field.distance[i] = 0.5 - toOutside[i]
+		default:
+			field.distance[i] = toInside[i] - 0.5
+		}
+	}
+	field.width, field.height, field.bounds = w, h, bounds
+	return field
+}
+
+// sample returns the distance at texture coordinates, v pointing up,
+// bilinearly interpolated
+func (field *labelField) sample(u, v float64) float64 {
+	x := u*float64(field.width) - 0.5
+	y := (1-v)*float64(field.height) - 0.5
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	at := func(x, y int) float64 {
+		x = ClampInt(x, 0, field.width-1)
+		y = ClampInt(y, 0, field.height-1)
+		return field.distance[y*field.width+x]
+	}
+	top := at(x0, y0)*(1-fx) + at(x0+1, y0)*fx
+	bottom := at(x0, y0+1)*(1-fx) + at(x0+1, y0+1)*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// labelShader draws the text of a label from its distance field, antialiased
+// over the footprint of every pixel
+type labelShader struct {
+	Matrix Matrix
+	Label  *Label
+	Field  *labelField
+	Scale  float64 // Buffer pixels per output pixel
+}
+
+func (shader *labelShader) Vertex(v Vertex) Vertex {
+	v.Output = shader.Matrix.MulPositionW(v.Position)
+	return v
+}
+
+func (shader *labelShader) Fragment(v Vertex) Color {
+	label, field := shader.Label, shader.Field
+	d := field.sample(v.Texture.X, v.Texture.Y)
+	// Field texels per buffer pixel
+	texels := math.Max(math.Hypot(v.TextureDx.X*float64(field.width), v.TextureDx.Y*float64(field.height)),
+		math.Hypot(v.TextureDy.X*float64(field.width), v.TextureDy.Y*float64(field.height)))
+	pixels := d / math.Max(texels, 1e-9)
+
+	c := label.Background
+	if label.OutlineWidth > 0 && label.Outline.A > 0 {
+		outline := Clamp(0.5-(pixels-label.OutlineWidth*shader.Scale), 0, 1)
+		c = label.Outline.Alpha(label.Outline.A * outline).Over(c)
+	}
+	fill := Clamp(0.5-pixels, 0, 1)
+	c = label.Color.Alpha(label.Color.A * fill).Over(c)
+	if c.A <= 0 {
+		return Discard
+	}
+	return c
+}
+
+// drawLabels draws the labels of every visible node of a scene as
+// billboards facing the camera
+func (renderer *SceneRenderer) drawLabels(scene *Scene, cameraMatrix Matrix) {
+	var nodes []*SceneNode
+	scene.RootNode.VisitNodes(func(node *SceneNode) {
+		if node.Visible && len(node.Labels) > 0 {
+			nodes = append(nodes, node)
+		}
+	})
+	if len(nodes) == 0 {
+		return
+	}
+
+	dc := renderer.context
+	shader, cull, writeDepth, readDepth, alphaBlend := dc.Shader, dc.Cull, dc.WriteDepth, dc.ReadDepth, dc.AlphaBlend
+	dc.Cull = CullNone
+	dc.WriteDepth = false
+	dc.AlphaBlend = true
+	defer func() {
+		dc.Shader, dc.Cull, dc.WriteDepth, dc.ReadDepth, dc.AlphaBlend = shader, cull, writeDepth, readDepth, alphaBlend
+	}()
+
+	// The camera axes, and the world size of a buffer pixel at a view depth
+	// of one for labels sized in pixels
+	view := renderer.viewMatrix
+	right := Vector{view.X00, view.X01, view.X02}.Normalize()
+	up := Vector{view.X10, view.X11, view.X12}.Normalize()
+	projection := cameraMatrix.Mul(view.Inverse())
+	scale := float64(dc.Supersampling())
+	pixel := 2 / (math.Abs(projection.X11) * float64(dc.Height))
+
+	for _, node := range nodes {
+		for _, label := range node.Labels {
+			field := label.distanceField()
+			if field.width == 0 {
+				continue
+			}
+			position := node.WorldTransform.MulPosition(label.Offset)
+			size := label.Size
+			if label.PixelSize {
+				w := cameraMatrix.MulPositionW(position).W
+				if w <= 0 {
+					continue
+				}
+				size *= pixel * scale * w
+			}
+
+			// The box of the text in em units, scaled to the line size and
+			// placed by its anchor
+			box := field.bounds
+			origin := Vector{
+				box.Min.X + (box.Max.X-box.Min.X)*label.Anchor.X,
+				box.Min.Y + (box.Max.Y-box.Min.Y)*label.Anchor.Y,
+				0,
+			}
+			corner := func(x, y float64) Vertex {
+				p := position.Add(right.MulScalar((x - origin.X) * size)).Add(up.MulScalar((y - origin.Y) * size))
+				u := (x - box.Min.X) / (box.Max.X - box.Min.X)
+				v := (y - box.Min.Y) / (box.Max.Y - box.Min.Y)
+				return Vertex{Position: p, Texture: Vector{u, v, 0}, Color: White}
+			}
+			bl, br := corner(box.Min.X, box.Min.Y), corner(box.Max.X, box.Min.Y)
+			tl, tr := corner(box.Min.X, box.Max.Y), corner(box.Max.X, box.Max.Y)
+
+			dc.ReadDepth = readDepth && !label.OnTop
+			dc.Shader = &labelShader{cameraMatrix, label, field, scale}
+			dc.DrawTriangles([]*Triangle{NewTriangle(bl, br, tr), NewTriangle(bl, tr, tl)})
+		}
+	}
+}
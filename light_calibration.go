@@ -0,0 +1,80 @@
+package fauxgl
+
+import (
+	"math"
+)
+
+// Energy conventions of the shaders, used to light a scene the same way
+// through PhongShader and PBRShader:
+//
+// PBRShader lights are physical: Color times Intensity is the radiance of a
+// light, and a Lambertian surface of albedo a lit head-on reflects
+// a*radiance/π. AmbientLight radiance is reflected as a*radiance without the
+// 1/π, like the legacy ambient color.
+//
+// PhongShader colors are reflectances: a surface of color a lit head-on
+// reflects a*DiffuseColor, and a*AmbientColor everywhere.
+//
+// A Phong DiffuseColor d therefore matches a directional light of radiance
+// π*d, and a Phong AmbientColor matches an AmbientLight of the same color.
+// PBRShader outputs linear values, so set PhongShader.Linear for comparable
+// output.
+
+// PhongIntensityScale is the PBR light intensity that reflects as much
+// diffuse light as a Phong DiffuseColor of one
+const PhongIntensityScale = math.Pi
+
+// DirectionalLightFromPhong returns the PBR directional light matching a
+// Phong light shining from lightDirection with a diffuse color
+func DirectionalLightFromPhong(lightDirection Vector, diffuse Color) Light {
+	return Light{
+		Type:      DirectionalLight,
+		Direction: lightDirection.Negate().Normalize(),
+		Color:     diffuse.Alpha(1),
+		Intensity: PhongIntensityScale,
+	}
+}
+
+// Lights returns PBR lights matching the lighting of the shader: an
+// ambient light and a directional light
+func (shader *PhongShader) Lights() []Light {
+	return []Light{
+		{Type: AmbientLight, Color: shader.AmbientColor.Alpha(1), Intensity: 1},
+		DirectionalLightFromPhong(shader.LightDirection, shader.DiffuseColor),
+	}
+}
+
+// SetLights sets the light direction, diffuse and ambient colors of the
+// shader from PBR lights. Phong shading has a single light: the brightest
+// non-ambient light is used, point and spot lights shining from their
+// position towards the origin without attenuation. Ambient lights add up.
+// Specular color and power are left unchanged.
+func (shader *PhongShader) SetLights(lights []Light) {
+	ambient := Color{0, 0, 0, 1}
+	var diffuse Color
+	brightest := -1.0
+	for _, light := range lights {
+		radiance := light.Color.MulScalar(light.Intensity)
+		if light.Type == AmbientLight {
+			ambient = ambient.Add(radiance.Alpha(0))
+			continue
+		}
+		brightness := radiance.R + radiance.G + radiance.B
+		if brightness <= brightest {
+			continue
+		}
+		brightest = brightness
+		diffuse = radiance.DivScalar(PhongIntensityScale).Alpha(1)
+		if light.Type == DirectionalLight {
+			shader.LightDirection = light.Direction.Negate().Normalize()
+		} else {
+			shader.LightDirection = light.Position.Normalize()
+		}
+	}
+	shader.AmbientColor = ambient
+	if brightest >= 0 {
+		shader.DiffuseColor = diffuse
+	} else {
+		shader.DiffuseColor = Color{0, 0, 0, 1}
+	}
+}
@@ -0,0 +1,128 @@
+package fauxgl
+
+import "math"
+
+// DefaultLightTileSize is the edge length in pixels of a light grid tile
+const DefaultLightTileSize = 16
+
+// LightGrid bins lights into screen space tiles (forward+ shading) so each
+// fragment only evaluates the lights that can reach it. Point and spot
+// lights with a Range are assigned to the tiles covered by the screen bounds
+// of their range sphere; directional, ambient and unbounded lights affect
+// every tile. Lights keep their scene order within each tile.
+type LightGrid struct {
+	Width    int
+	Height   int
+	TileSize int
+	Columns  int
+	Rows     int
+	tiles    [][]Light
+}
+
+// NewLightGrid builds a light grid for a width x height render target.
+// matrix is the view-projection matrix of the camera; lights are in world
+// space. A tileSize <= 0 uses DefaultLightTileSize.
+func NewLightGrid(lights []Light, matrix Matrix, width, height, tileSize int) *LightGrid {
+	if tileSize <= 0 {
+		tileSize = DefaultLightTileSize
+	}
+	g := &LightGrid{
+		Width:    width,
+		Height:   height,
+		TileSize: tileSize,
+		Columns:  maxInt(1, (width+tileSize-1)/tileSize),
+		Rows:     maxInt(1, (height+tileSize-1)/tileSize),
+	}
+	g.tiles = make([][]Light, g.Columns*g.Rows)
+
+	for _, light := range lights {
+		x0, y0, x1, y1, visible := g.lightTiles(light, matrix)
+		if !visible {
+			continue
+		}
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				i := y*g.Columns + x
+				g.tiles[i] = append(g.tiles[i], light)
+			}
+		}
+	}
+	return g
+}
+
+// lightTiles returns the inclusive tile range a light affects and whether it
+// is visible at all
+func (g *LightGrid) lightTiles(light Light, matrix Matrix) (x0, y0, x1, y1 int, visible bool) {
+	all := func() (int, int, int, int, bool) {
+		return 0, 0, g.Columns - 1, g.Rows - 1, true
+	}
+	if (light.Type != PointLight && light.Type != SpotLight) || light.Range <= 0 {
+		return all()
+	}
+
+	// Project the corners of the box around the range sphere
+	r := light.Range
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	beyondFar := true
+	for i := 0; i < 8; i++ {
+		corner := light.Position.Sub(Vector{r, r, r})
+		if i&1 != 0 {
+			corner.X += 2 * r
+		}
+		if i&2 != 0 {
+			corner.Y += 2 * r
+		}
+		if i&4 != 0 {
+			corner.Z += 2 * r
+		}
+		clip := matrix.MulPositionW(corner)
+		if clip.W <= 0 {
+			// The sphere reaches behind the camera plane, where the
+			// projected bounds are unreliable
+			return all()
+		}
+		ndc := clip.DivScalar(clip.W)
+		minX = math.Min(minX, ndc.X)
+		maxX = math.Max(maxX, ndc.X)
+		minY = math.Min(minY, ndc.Y)
+		maxY = math.Max(maxY, ndc.Y)
+		if ndc.Z <= 1 {
+			beyondFar = false
+		}
+	}
+	if beyondFar || maxX < -1 || minX > 1 || maxY < -1 || minY > 1 {
+		return 0, 0, 0, 0, false
+	}
+
+	// NDC to pixels, matching Screen
+	w2 := float64(g.Width) / 2
+	h2 := float64(g.Height) / 2
+	x0 = g.tileIndex((minX+1)*w2, g.Columns)
+	x1 = g.tileIndex((maxX+1)*w2, g.Columns)
+	y0 = g.tileIndex((1-maxY)*h2, g.Rows)
+	y1 = g.tileIndex((1-minY)*h2, g.Rows)
+	return x0, y0, x1, y1, true
+}
+
+func (g *LightGrid) tileIndex(pixel float64, count int) int {
+	return ClampInt(int(math.Floor(pixel))/g.TileSize, 0, count-1)
+}
+
+// TileLights returns the lights affecting the tile containing pixel (x, y)
+func (g *LightGrid) TileLights(x, y int) []Light {
+	tx := ClampInt(x/g.TileSize, 0, g.Columns-1)
+	ty := ClampInt(y/g.TileSize, 0, g.Rows-1)
+	return g.tiles[ty*g.Columns+tx]
+}
+
+// LightsAt returns the lights affecting a fragment given its clip space
+// position, as found in Vertex.Output during fragment shading
+func (g *LightGrid) LightsAt(clip VectorW) []Light {
+	if clip.W == 0 {
+		return g.tiles[0]
+	}
+	x := (clip.X/clip.W + 1) * float64(g.Width) / 2
+	y := (1 - clip.Y/clip.W) * float64(g.Height) / 2
+	return g.TileLights(int(math.Floor(x)), int(math.Floor(y)))
+}
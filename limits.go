@@ -0,0 +1,355 @@
+package fauxgl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"time"
+	"unsafe"
+
+	"github.com/qmuntal/gltf"
+)
+
+// ResourceLimits bound the work one request may cause on a shared server,
+// so hostile or broken assets fail with a LimitError instead of exhausting
+// it. They are checked by the glTF loader, see GLTFLoadOptions, and by
+// SceneRenderer.RenderSceneContext. Zero fields are unlimited.
+type ResourceLimits struct {
+	// MaxTriangles bounds the triangles of a scene, counting every
+	// instance of a mesh
+	MaxTriangles int
+	// MaxTextureSize bounds the width and height of every texture image,
+	// checked from its header before it is decoded
+	MaxTextureSize int
+	// MaxMemory bounds the estimated bytes of the buffers, meshes and
+	// textures of a scene, and of the render buffers when rendering
+	MaxMemory int64
+	// MaxRenderTime bounds the time of one render
+	MaxRenderTime time.Duration
+}
+
+// LimitError reports a resource limit a scene or a render exceeded
+type LimitError struct {
+	Resource string // "triangles", "texture size", "memory" or "render time"
+	Value    int64  // Amount needed, or spent for render time, in nanoseconds
+	Limit    int64
+}
+
+func (e *LimitError) Error() string {
+	if e.Resource == "render time" {
+		return fmt.Sprintf("render time exceeds the limit of %v", time.Duration(e.Limit))
+	}
+	return fmt.Sprintf("%s: %d exceeds the limit of %d", e.Resource, e.Value, e.Limit)
+}
+
+// Memory estimates: triangles hold their three vertices, textures 8 bit
+// RGBA texels and a third more for mip levels
+const (
+	triangleBytes = int64(unsafe.Sizeof(Triangle{}) + unsafe.Sizeof(&Triangle{}))
+	texelBytes    = 4 * 4 / 3.0
+)
+
+func (limits *ResourceLimits) checkTriangles(n int64) error {
+	if limits == nil || limits.MaxTriangles <= 0 || n <= int64(limits.MaxTriangles) {
+		return nil
+	}
+	return &LimitError{"triangles", n, int64(limits.MaxTriangles)}
+}
+
+func (limits *ResourceLimits) checkTextureSize(width, height int) error {
+	if limits == nil || limits.MaxTextureSize <= 0 {
+		return nil
+	}
+	size := maxInt(width, height)
+	if size <= limits.MaxTextureSize {
+		return nil
+	}
+	return &LimitError{"texture size", int64(size), int64(limits.MaxTextureSize)}
+}
+
+func (limits *ResourceLimits) checkMemory(n int64) error {
+	if limits == nil || limits.MaxMemory <= 0 || n <= limits.MaxMemory {
+		return nil
+	}
+	return &LimitError{"memory", n, limits.MaxMemory}
+}
+
+// textureMemory estimates the bytes of a texture and its UDIM tiles
+func textureMemory(texture *AdvancedTexture) int64 {
+	bytes := int64(float64(texture.Width) * float64(texture.Height) * texelBytes)
+	for _, tile := range texture.UDIMTiles {
+		if tile != nil {
+			bytes += textureMemory(tile)
+		}
+	}
+	return bytes
+}
+
+// MemoryEstimate estimates the bytes held by the meshes and textures of
+// the scene, as checked against ResourceLimits.MaxMemory
+func (scene *Scene) MemoryEstimate() int64 {
+	meshes := make(map[*Mesh]bool)
+	textures := make(map[*AdvancedTexture]bool)
+	for _, mesh := range scene.Meshes {
+		meshes[mesh] = true
+	}
+	for _, texture := range scene.Textures {
+		textures[texture] = true
+	}
+	addMaterial := func(material *PBRMaterial) {
+		if material == nil {
+			return
+		}
+		for _, slot := range material.textureSlots() {
+			if texture, ok := (*slot).(*AdvancedTexture); ok && texture != nil {
+				textures[texture] = true
+			}
+		}
+	}
+	for _, material := range scene.Materials {
+		addMaterial(material)
+	}
+	scene.RootNode.VisitNodes(func(node *SceneNode) {
+		if node.Mesh != nil {
+			meshes[node.Mesh] = true
+		}
+		addMaterial(node.Material)
+		for _, material := range node.Materials {
+			addMaterial(material)
+		}
+	})
+
+	total := int64(0)
+	for mesh := range meshes {
+		total += int64(len(mesh.Triangles)) * triangleBytes
+	}
+	for texture := range textures {
+		total += textureMemory(texture)
+	}
+	return total
+}
+
+// memoryEstimate estimates the bytes of the buffers of the context
+func (dc *Context) memoryEstimate() int64 {
+	pixels := int64(dc.Width) * int64(dc.Height)
+	bytes := pixels * (4 + 8) // Color and depth
+	if dc.HDRBuffer != nil {
+		bytes += pixels * 16
+	}
+	return bytes
+}
+
+// cancelled reports whether the render drawing into the context was
+// cancelled, see SceneRenderer.RenderSceneContext
+func (dc *Context) cancelled() bool {
+	if dc.done == nil {
+		return false
+	}
+	select {
+	case <-dc.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkLimits checks the nodes to draw against the limits of the renderer
+func (renderer *SceneRenderer) checkLimits(scene *Scene, nodes []*SceneNode) error {
+	limits := renderer.Limits
+	if limits == nil {
+		return nil
+	}
+	triangles := int64(0)
+	for _, node := range nodes {
+		triangles += int64(len(node.Mesh.Triangles)) * int64(len(node.InstanceTransforms()))
+	}
+	if err := limits.checkTriangles(triangles); err != nil {
+		return err
+	}
+	if limits.MaxMemory > 0 {
+		memory := scene.MemoryEstimate() + renderer.context.memoryEstimate()
+		if renderer.shadowContext != nil {
+			memory += renderer.shadowContext.memoryEstimate()
+		}
+		return limits.checkMemory(memory)
+	}
+	return nil
+}
+
+// RenderSceneContext renders a scene like RenderScene, checking the scene
+// against Limits before drawing anything and stopping when ctx is done or
+// Limits.MaxRenderTime has passed. Stopped renders leave the nodes not yet
+// drawn out of the frame and return an error: a LimitError for exceeded
+// limits, otherwise the error of ctx.
+func (renderer *SceneRenderer) RenderSceneContext(ctx context.Context, scene *Scene) (*RenderReport, error) {
+	return renderer.renderContext(ctx, func() *RenderReport {
+		return renderer.RenderSceneWithReport(scene)
+	})
+}
+
+// RenderSceneContext renders a scene with frustum culling like
+// RenderScene, with the checks of SceneRenderer.RenderSceneContext
+func (csr *CullingSceneRenderer) RenderSceneContext(ctx context.Context, scene *Scene) (*RenderReport, error) {
+	return csr.renderContext(ctx, func() *RenderReport {
+		return csr.RenderSceneWithReport(scene)
+	})
+}
+
+func (renderer *SceneRenderer) renderContext(parent context.Context, render func() *RenderReport) (*RenderReport, error) {
+	ctx := parent
+	limits := renderer.Limits
+	if limits != nil && limits.MaxRenderTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, limits.MaxRenderTime)
+		defer cancel()
+	}
+	start := time.Now()
+	renderer.context.done = ctx.Done()
+	defer func() { renderer.context.done = nil }()
+
+	renderer.limitErr = nil
+	report := render()
+	if renderer.limitErr != nil {
+		return report, renderer.limitErr
+	}
+	err := ctx.Err()
+	if err == nil {
+		return report, nil
+	}
+	if parent.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		err = &LimitError{"render time", int64(time.Since(start)), int64(limits.MaxRenderTime)}
+	} else {
+		err = fmt.Errorf("render stopped: %w", err)
+	}
+	report.warn(nil, "%v", err)
+	return report, err
+}
+
+// GLTFLoadOptions configure loading glTF files
+type GLTFLoadOptions struct {
+	// Limits, when set, are checked while loading: the buffers and the
+	// triangles of the meshes before the meshes are built, and the size of
+	// every image before it is decoded
+	Limits *ResourceLimits
+	// AllowOutsideFiles lets LoadGLTFSceneWithOptions read images through
+	// absolute URIs and URIs leaving the directory of the document, which
+	// glTF allows. By default they are refused, so that untrusted files
+	// can't read other files of the host.
+	AllowOutsideFiles bool
+}
+
+// checkDocument checks the buffers and triangles of a glTF document
+// against the loader's limits
+func (loader *GLTFLoader) checkDocument() error {
+	limits := loader.limits
+	if limits == nil {
+		return nil
+	}
+	doc := loader.doc
+	for _, buffer := range doc.Buffers {
+		loader.memory += int64(buffer.ByteLength)
+	}
+	if err := limits.checkMemory(loader.memory); err != nil {
+		return err
+	}
+
+	// Triangles of every primitive, once per node and instance using it
+	meshTriangles := make([]int64, len(doc.Meshes))
+	for i, mesh := range doc.Meshes {
+		for _, primitive := range mesh.Primitives {
+			meshTriangles[i] += primitiveTriangles(doc, primitive)
+		}
+	}
+	triangles, meshBytes := int64(0), int64(0)
+	for i, mesh := range meshTriangles {
+		meshBytes += mesh * triangleBytes
+		for _, node := range doc.Nodes {
+			if node.Mesh != nil && int(*node.Mesh) == i {
+				triangles += mesh * gltfInstanceCount(doc, node)
+			}
+		}
+	}
+	if err := limits.checkTriangles(triangles); err != nil {
+		return err
+	}
+	loader.memory += meshBytes
+	return limits.checkMemory(loader.memory)
+}
+
+// primitiveTriangles returns the number of triangles of a glTF primitive
+// from the counts of its accessors
+func primitiveTriangles(doc *gltf.Document, primitive *gltf.Primitive) int64 {
+	count := int64(0)
+	if index := primitive.Indices; index != nil && *index >= 0 && *index < len(doc.Accessors) {
+		count = int64(doc.Accessors[*index].Count)
+	} else if position, ok := primitive.Attributes[gltf.POSITION]; ok && position >= 0 && position < len(doc.Accessors) {
+		count = int64(doc.Accessors[position].Count)
+	}
+	switch primitive.Mode {
+	case gltf.PrimitiveTriangles:
+		return count / 3
+	case gltf.PrimitiveTriangleStrip, gltf.PrimitiveTriangleFan:
+		if count > 2 {
+			return count - 2
+		}
+	}
+	return 0
+}
+
+// gltfInstanceCount returns the number of EXT_mesh_gpu_instancing
+// instances of a node, 1 without instancing
+func gltfInstanceCount(doc *gltf.Document, node *gltf.Node) int64 {
+	ext, ok := node.Extensions["EXT_mesh_gpu_instancing"]
+	if !ok {
+		return 1
+	}
+	data, ok := ext.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(ext); err != nil {
+			return 1
+		}
+	}
+	var instancing gltfInstancing
+	if json.Unmarshal(data, &instancing) != nil {
+		return 1
+	}
+	for _, index := range instancing.Attributes {
+		if index >= 0 && index < len(doc.Accessors) {
+			return int64(doc.Accessors[index].Count)
+		}
+	}
+	return 1
+}
+
+// checkImageConfig checks the size of an image from its header against the
+// loader's limits, before it is decoded
+func (loader *GLTFLoader) checkImageConfig(config image.Config) error {
+	if loader.limits == nil {
+		return nil
+	}
+	if err := loader.limits.checkTextureSize(config.Width, config.Height); err != nil {
+		return err
+	}
+	return loader.limits.checkMemory(loader.memory + int64(float64(config.Width)*float64(config.Height)*texelBytes))
+}
+
+// checkTexture checks a decoded texture against the loader's limits and
+// counts its memory
+func (loader *GLTFLoader) checkTexture(texture *AdvancedTexture) error {
+	if loader.limits == nil || texture == nil {
+		return nil
+	}
+	if err := loader.limits.checkTextureSize(texture.Width, texture.Height); err != nil {
+		return err
+	}
+	for _, tile := range texture.UDIMTiles {
+		if err := loader.limits.checkTextureSize(tile.Width, tile.Height); err != nil {
+			return err
+		}
+	}
+	loader.memory += textureMemory(texture)
+	return loader.limits.checkMemory(loader.memory)
+}
@@ -0,0 +1,67 @@
+package fauxgl
+
+// hasMaterialIDs reports whether the mesh has a material ID per triangle
+func (m *Mesh) hasMaterialIDs() bool {
+	return len(m.MaterialIDs) > 0 && len(m.MaterialIDs) == len(m.Triangles)
+}
+
+// NewMultiMaterialMesh joins meshes into one whose triangles keep the index
+// of their source mesh as material ID, to be drawn by a single node with
+// one material per source in SceneNode.Materials
+func NewMultiMaterialMesh(meshes []*Mesh) *Mesh {
+	mesh := NewEmptyMesh()
+	for id, m := range meshes {
+		mesh.Triangles = append(mesh.Triangles, m.Triangles...)
+		mesh.Lines = append(mesh.Lines, m.Lines...)
+		for range m.Triangles {
+			mesh.MaterialIDs = append(mesh.MaterialIDs, id)
+		}
+	}
+	return mesh
+}
+
+// materialNodes returns the node itself, or, when its mesh mixes
+// materials, one copy of the node per material drawing the triangles that
+// use it, in order of the material IDs, the copy of Material first. Lines
+// go with the first copy. The copies are only valid for drawing.
+func (node *SceneNode) materialNodes() []*SceneNode {
+	if node.Mesh == nil || len(node.Materials) == 0 || !node.Mesh.hasMaterialIDs() {
+		return []*SceneNode{node}
+	}
+	// Group 0 holds the triangles of Material, group i+1 those of ID i
+	groups := make([][]*Triangle, len(node.Materials)+1)
+	for i, t := range node.Mesh.Triangles {
+		group := 0
+		if id := node.Mesh.MaterialIDs[i]; id >= 0 && id < len(node.Materials) && node.Materials[id] != nil {
+			group = id + 1
+		}
+		groups[group] = append(groups[group], t)
+	}
+	var nodes []*SceneNode
+	lines := node.Mesh.Lines
+	for i, triangles := range groups {
+		if len(triangles) == 0 {
+			continue
+		}
+		part := *node
+		part.source = node.sourceNode()
+		part.Mesh = NewMesh(triangles, lines)
+		part.Materials = nil
+		if i > 0 {
+			part.Material = node.Materials[i-1]
+		}
+		nodes = append(nodes, &part)
+		lines = nil
+	}
+	return nodes
+}
+
+// expandMaterialNodes replaces the nodes mixing materials by their
+// per-material copies
+func expandMaterialNodes(nodes []*SceneNode) []*SceneNode {
+	expanded := nodes[:0:0]
+	for _, node := range nodes {
+		expanded = append(expanded, node.materialNodes()...)
+	}
+	return expanded
+}
@@ -0,0 +1,253 @@
+package fauxgl
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// PresetParams configures a procedural material preset
+type PresetParams struct {
+	BaseColor  Color   // Tint applied to the preset's base color (zero uses white)
+	Roughness  float64 // Base roughness (0 uses the preset default)
+	Resolution int     // Size of the generated textures in pixels
+	Tiling     float64 // Number of pattern repeats across the UV range
+	Seed       int64   // Seed for the procedural noise
+}
+
+// NewPresetParams returns the default preset parameters
+func NewPresetParams() *PresetParams {
+	return &PresetParams{
+		BaseColor:  Color{1, 1, 1, 1},
+		Resolution: 256,
+		Tiling:     1,
+		Seed:       1,
+	}
+}
+
+// presetBuilder builds a material for a named preset
+type presetBuilder func(params *PresetParams) *PBRMaterial
+
+var materialPresets = map[string]presetBuilder{
+	"brushed_metal": newBrushedMetalPreset,
+	"plastic":       newPlasticPreset,
+	"ceramic_glaze": newCeramicGlazePreset,
+	"rubber":        newRubberPreset,
+	"wood":          newWoodPreset,
+}
+
+// PresetMaterialNames returns the names of all built-in material presets
+func PresetMaterialNames() []string {
+	names := make([]string, 0, len(materialPresets))
+	for name := range materialPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPresetMaterial creates a procedural PBR material from a built-in preset.
+// A nil params uses NewPresetParams.
+func NewPresetMaterial(name string, params *PresetParams) (*PBRMaterial, error) {
+	builder, ok := materialPresets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown material preset: %s", name)
+	}
+	if params == nil {
+		params = NewPresetParams()
+	}
+	p := *params
+	if p.BaseColor == (Color{}) {
+		p.BaseColor = White
+	}
+	if p.Resolution <= 0 {
+		p.Resolution = 256
+	}
+	if p.Tiling <= 0 {
+		p.Tiling = 1
+	}
+	return builder(&p), nil
+}
+
+func presetRoughness(params *PresetParams, fallback float64) float64 {
+	if params.Roughness > 0 {
+		return params.Roughness
+	}
+	return fallback
+}
+
+// newPresetTexture bakes a procedural function into a tiling texture
+func newPresetTexture(params *PresetParams, textureType TextureType, f func(u, v float64) Color) *AdvancedTexture {
+	texture := NewAdvancedTexture(bakeProceduralImage(params.Resolution, params.Resolution, f), textureType)
+	texture.Transform = Scale(Vector{params.Tiling, params.Tiling, 1})
+	return texture
+}
+
+// bakeProceduralImage evaluates f at every texel center. V follows the
+// texture sampler convention (v = 0 at the bottom row).
+func bakeProceduralImage(width, height int, f func(u, v float64) Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		v := 1 - (float64(y)+0.5)/float64(height)
+		for x := 0; x < width; x++ {
+			u := (float64(x) + 0.5) / float64(width)
+			img.SetNRGBA(x, y, f(u, v).NRGBA())
+		}
+	}
+	return img
+}
+
+// ormColor packs occlusion, roughness and metallic the way PBRMaterial samples them
+func ormColor(occlusion, roughness, metallic float64) Color {
+	return Color{occlusion, roughness, metallic, 1}
+}
+
+func newBrushedMetalPreset(params *PresetParams) *PBRMaterial {
+	material := NewPBRMaterial()
+	material.BaseColorFactor = Color{0.91, 0.92, 0.92, 1}.Mul(params.BaseColor)
+	material.MetallicFactor = 1
+	material.RoughnessFactor = 1
+	roughness := presetRoughness(params, 0.35)
+
+	// Long streaks along U: high frequency in V, very low in U
+	material.MetallicRoughnessTexture = newPresetTexture(params, MetallicTexture, func(u, v float64) Color {
+		streak := tileableFBM(u*2, v*128, 2, 128, 3, params.Seed)
+		return ormColor(1, Clamp(roughness+(streak-0.5)*0.25, 0.05, 1), 1)
+	})
+	material.AnisotropyStrength = 0.6
+	return material
+}
+
+func newPlasticPreset(params *PresetParams) *PBRMaterial {
+	material := NewPBRMaterial()
+	material.BaseColorFactor = params.BaseColor // Plastic takes any color
+	material.MetallicFactor = 0
+	material.RoughnessFactor = presetRoughness(params, 0.4)
+	material.IOR = 1.46
+	return material
+}
+
+func newCeramicGlazePreset(params *PresetParams) *PBRMaterial {
+	material := NewPBRMaterial()
+	material.MetallicFactor = 0
+	material.RoughnessFactor = presetRoughness(params, 0.2)
+	material.ClearcoatFactor = 1
+	material.ClearcoatRoughnessFactor = 0.03
+
+	// Subtle glaze pooling variation
+	tint := Color{0.95, 0.94, 0.9, 1}.Mul(params.BaseColor)
+	material.BaseColorTexture = newPresetTexture(params, BaseColorTexture, func(u, v float64) Color {
+		n := tileableFBM(u*4, v*4, 4, 4, 4, params.Seed)
+		return tint.MulScalar(0.92 + 0.08*n).Alpha(1)
+	})
+	return material
+}
+
+func newRubberPreset(params *PresetParams) *PBRMaterial {
+	material := NewPBRMaterial()
+	material.BaseColorFactor = Color{0.05, 0.05, 0.05, 1}.Mul(params.BaseColor)
+	material.MetallicFactor = 0
+	material.RoughnessFactor = 1
+	roughness := presetRoughness(params, 0.85)
+
+	// Fine grain in roughness, metallic channel kept at zero
+	material.MetallicRoughnessTexture = newPresetTexture(params, MetallicTexture, func(u, v float64) Color {
+		n := tileableFBM(u*64, v*64, 64, 64, 2, params.Seed)
+		return ormColor(1, Clamp(roughness+(n-0.5)*0.15, 0, 1), 0)
+	})
+	material.SheenColorFactor = Color{0.1, 0.1, 0.1, 1}
+	material.SheenRoughnessFactor = 0.8
+	return material
+}
+
+func newWoodPreset(params *PresetParams) *PBRMaterial {
+	material := NewPBRMaterial()
+	material.MetallicFactor = 0
+	material.RoughnessFactor = 1
+	roughness := presetRoughness(params, 0.6)
+
+	light := Color{0.72, 0.52, 0.32, 1}.Mul(params.BaseColor)
+	dark := Color{0.45, 0.28, 0.15, 1}.Mul(params.BaseColor)
+
+	// Concentric growth rings distorted by noise; 0 = early wood, 1 = late wood
+	rings := func(u, v float64) float64 {
+		grain := tileableFBM(u*4, v*16, 4, 16, 4, params.Seed)
+		dist := math.Abs(math.Sin(math.Pi * u))
+		r := dist*8 + grain*1.5
+		return math.Pow(r-math.Floor(r), 3)
+	}
+
+	material.BaseColorTexture = newPresetTexture(params, BaseColorTexture, func(u, v float64) Color {
+		fibers := tileableFBM(u*96, v*4, 96, 4, 2, params.Seed+1)
+		return light.Lerp(dark, Clamp(rings(u, v)+(fibers-0.5)*0.2, 0, 1)).Alpha(1)
+	})
+	material.MetallicRoughnessTexture = newPresetTexture(params, MetallicTexture, func(u, v float64) Color {
+		return ormColor(1, Clamp(roughness+rings(u, v)*0.15, 0, 1), 0)
+	})
+	return material
+}
+
+// hashLattice returns a pseudo-random value in [0, 1) for an integer lattice point
+func hashLattice(x, y int, seed int64) float64 {
+	h := uint64(x)*0x9E3779B185EBCA87 ^ uint64(y)*0xC2B2AE3D27D4EB4F ^ uint64(seed)*0x165667B19E3779F9
+	h ^= h >> 33
+	h *= 0xFF51AFD7ED558CCD
+	h ^= h >> 33
+	h *= 0xC4CEB9FE1A85EC53
+	h ^= h >> 33
+	return float64(h>>11) / float64(1<<53)
+}
+
+// tileableValueNoise is smooth value noise that repeats every periodX/periodY units
+func tileableValueNoise(x, y float64, periodX, periodY int, seed int64) float64 {
+	x0 := math.Floor(x)
+	y0 := math.Floor(y)
+	fx := x - x0
+	fy := y - y0
+	ix := int(x0)
+	iy := int(y0)
+
+	wrap := func(i, period int) int {
+		if period <= 0 {
+			return i
+		}
+		i %= period
+		if i < 0 {
+			i += period
+		}
+		return i
+	}
+
+	ix0, ix1 := wrap(ix, periodX), wrap(ix+1, periodX)
+	iy0, iy1 := wrap(iy, periodY), wrap(iy+1, periodY)
+
+	// Smoothstep fade
+	sx := fx * fx * (3 - 2*fx)
+	sy := fy * fy * (3 - 2*fy)
+
+	n00 := hashLattice(ix0, iy0, seed)
+	n10 := hashLattice(ix1, iy0, seed)
+	n01 := hashLattice(ix0, iy1, seed)
+	n11 := hashLattice(ix1, iy1, seed)
+
+	nx0 := n00 + (n10-n00)*sx
+	nx1 := n01 + (n11-n01)*sx
+	return nx0 + (nx1-nx0)*sy
+}
+
+// tileableFBM sums octaves of tileable value noise, normalized to [0, 1]
+func tileableFBM(x, y float64, periodX, periodY, octaves int, seed int64) float64 {
+	var sum, norm float64
+	amplitude := 1.0
+	for i := 0; i < octaves; i++ {
+		sum += tileableValueNoise(x, y, periodX, periodY, seed+int64(i)) * amplitude
+		norm += amplitude
+		x *= 2
+		y *= 2
+		periodX *= 2
+		periodY *= 2
+		amplitude *= 0.5
+	}
+	return sum / norm
+}
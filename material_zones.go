@@ -0,0 +1,148 @@
+package fauxgl
+
+import "math"
+
+// MaterialZone is a named region of the texture space of a material whose
+// base color, metallic and roughness are overridden when the material is
+// sampled, e.g. the print area of a mug or the panels of a shoe, so they
+// can be recolored without baking new textures.
+type MaterialZone struct {
+	Name    string
+	Enabled bool
+
+	// Region bounds the zone in UV coordinates. Circle regions fade out
+	// towards their rim and gradient regions along U, as for UVModifier.
+	Region UVRegion
+	// Mask, when set, further limits the zone to its red channel, e.g. a
+	// painted selection of a region of any shape
+	Mask Texture
+
+	// BaseColor replaces the base color of the material in the zone, times
+	// BaseColorTexture when set, which is stretched over the bounds of the
+	// region, e.g. a print. The alpha of both fades the zone out.
+	BaseColor        Color
+	BaseColorTexture Texture
+	// Metallic and Roughness replace the factors of the material in the
+	// zone, negative values keep those of the material
+	Metallic  float64
+	Roughness float64
+	// Opacity scales the influence of the zone over the material
+	Opacity float64
+}
+
+// NewMaterialZone creates an enabled zone over a region, white and keeping
+// the metallic and roughness of the material
+func NewMaterialZone(name string, region UVRegion) *MaterialZone {
+	return &MaterialZone{
+		Name:      name,
+		Enabled:   true,
+		Region:    region,
+		BaseColor: White,
+		Metallic:  -1,
+		Roughness: -1,
+		Opacity:   1,
+	}
+}
+
+// MaterialZones is the ordered list of zones of a material, later zones
+// overriding earlier ones where they overlap, see PBRMaterial.Zones
+type MaterialZones struct {
+	Zones []*MaterialZone
+}
+
+// NewMaterialZones creates a zone list
+func NewMaterialZones(zones ...*MaterialZone) *MaterialZones {
+	return &MaterialZones{Zones: zones}
+}
+
+// AddZone adds a zone over a region to the zones of the material, creating
+// them when needed, and returns it for its overrides to be set
+func (m *PBRMaterial) AddZone(name string, region UVRegion) *MaterialZone {
+	if m.Zones == nil {
+		m.Zones = NewMaterialZones()
+	}
+	zone := NewMaterialZone(name, region)
+	m.Zones.Add(zone)
+	return zone
+}
+
+// Add appends a zone, which overrides the zones before it
+func (zones *MaterialZones) Add(zone *MaterialZone) {
+	zones.Zones = append(zones.Zones, zone)
+}
+
+// Zone returns the first zone with a name, nil if there is none
+func (zones *MaterialZones) Zone(name string) *MaterialZone {
+	for _, zone := range zones.Zones {
+		if zone.Name == name {
+			return zone
+		}
+	}
+	return nil
+}
+
+// Remove removes the zones with a name and reports whether there were any
+func (zones *MaterialZones) Remove(name string) bool {
+	kept := zones.Zones[:0]
+	for _, zone := range zones.Zones {
+		if zone.Name != name {
+			kept = append(kept, zone)
+		}
+	}
+	removed := len(kept) < len(zones.Zones)
+	for i := len(kept); i < len(zones.Zones); i++ {
+		zones.Zones[i] = nil
+	}
+	zones.Zones = kept
+	return removed
+}
+
+// apply blends the overrides of the zones covering UV coordinates into a
+// sampled material
+func (zones *MaterialZones) apply(result *SampledMaterial, u, v float64, dx, dy Vector) {
+	for _, zone := range zones.Zones {
+		if zone == nil || !zone.Enabled || zone.Opacity <= 0 {
+			continue
+		}
+		weight := zone.Region.Weight(u, v) * zone.Opacity
+		if weight <= 0 {
+			continue
+		}
+		if zone.Mask != nil {
+			weight *= Clamp(SampleTextureGrad(zone.Mask, u, v, dx, dy).R, 0, 1)
+			if weight <= 0 {
+				continue
+			}
+		}
+
+		color := zone.BaseColor
+		if zone.BaseColorTexture != nil {
+			color = color.Mul(zone.sampleTexture(u, v, dx, dy))
+		}
+		weight = math.Min(weight*Clamp(color.A, 0, 1), 1)
+		if weight <= 0 {
+			continue
+		}
+		// The zone keeps the opacity of the material
+		color.A = result.BaseColor.A
+		result.BaseColor = result.BaseColor.Lerp(color, weight)
+		if zone.Metallic >= 0 {
+			result.Metallic += (zone.Metallic - result.Metallic) * weight
+		}
+		if zone.Roughness >= 0 {
+			result.Roughness += (zone.Roughness - result.Roughness) * weight
+		}
+	}
+}
+
+// sampleTexture samples the base color texture of the zone stretched over
+// the bounds of its region
+func (zone *MaterialZone) sampleTexture(u, v float64, dx, dy Vector) Color {
+	r := zone.Region
+	du, dv := r.MaxU-r.MinU, r.MaxV-r.MinV
+	if du <= 0 || dv <= 0 {
+		return SampleTextureGrad(zone.BaseColorTexture, u, v, dx, dy)
+	}
+	scale := Vector{1 / du, 1 / dv, 1}
+	return SampleTextureGrad(zone.BaseColorTexture, (u-r.MinU)/du, (v-r.MinV)/dv, dx.Mul(scale), dy.Mul(scale))
+}
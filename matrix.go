@@ -98,6 +98,39 @@ func Perspective(fovy, aspect, near, far float64) Matrix {
 	return Frustum(-xmax, xmax, -ymax, ymax, near, far)
 }
 
+// FrustumReversed is Frustum for reversed-Z depth: z/w is 1 at the near plane
+// and 0 at the far plane, which may be math.Inf(1). Use with DepthReversed.
+func FrustumReversed(l, r, b, t, n, f float64) Matrix {
+	za, zb := 0.0, n
+	if !math.IsInf(f, 1) {
+		za = n / (f - n)
+		zb = f * n / (f - n)
+	}
+	return Matrix{
+		2 * n / (r - l), 0, (r + l) / (r - l), 0,
+		0, 2 * n / (t - b), (t + b) / (t - b), 0,
+		0, 0, za, zb,
+		0, 0, -1, 0}
+}
+
+// OrthographicReversed is Orthographic for reversed-Z depth: z is 1 at the
+// near plane and 0 at the far plane. Use with DepthReversed.
+func OrthographicReversed(l, r, b, t, n, f float64) Matrix {
+	return Matrix{
+		2 / (r - l), 0, 0, -(r + l) / (r - l),
+		0, 2 / (t - b), 0, -(t + b) / (t - b),
+		0, 0, 1 / (f - n), f / (f - n),
+		0, 0, 0, 1}
+}
+
+// PerspectiveReversed is Perspective for reversed-Z depth. far may be
+// math.Inf(1) for an infinite far plane. Use with DepthReversed.
+func PerspectiveReversed(fovy, aspect, near, far float64) Matrix {
+	ymax := near * math.Tan(fovy*math.Pi/360)
+	xmax := ymax * aspect
+	return FrustumReversed(-xmax, xmax, -ymax, ymax, near, far)
+}
+
 // LookAt f
 func LookAt(eye, center, up Vector) Matrix {
 	z := eye.Sub(center).Normalize()
@@ -185,6 +218,11 @@ func (a Matrix) Perspective(fovy, aspect, near, far float64) Matrix {
 	return Perspective(fovy, aspect, near, far).Mul(a)
 }
 
+// PerspectiveReversed f
+func (a Matrix) PerspectiveReversed(fovy, aspect, near, far float64) Matrix {
+	return PerspectiveReversed(fovy, aspect, near, far).Mul(a)
+}
+
 // LookAt f
 func (a Matrix) LookAt(eye, center, up Vector) Matrix {
 	return LookAt(eye, center, up).Mul(a)
@@ -9,7 +9,12 @@ import (
 type Mesh struct {
 	Triangles []*Triangle
 	Lines     []*Line
-	box       *Box
+	// MaterialIDs optionally holds the material of each triangle, an index
+	// into SceneNode.Materials. It is ignored unless it has one entry per
+	// triangle, so operations that rebuild the triangles drop it.
+	MaterialIDs []int
+	box         *Box
+	bvh         *meshBVH // Triangle BVH of RayIntersect
 }
 
 // NewEmptyMesh returns an empty mesh
@@ -19,21 +24,22 @@ func NewEmptyMesh() *Mesh {
 
 // NewMesh returns a mesh with given data
 func NewMesh(triangles []*Triangle, lines []*Line) *Mesh {
-	return &Mesh{triangles, lines, nil}
+	return &Mesh{triangles, lines, nil, nil, nil}
 }
 
 // NewTriangleMesh returns a mesh with given data
 func NewTriangleMesh(triangles []*Triangle) *Mesh {
-	return &Mesh{triangles, nil, nil}
+	return &Mesh{triangles, nil, nil, nil, nil}
 }
 
 // NewLineMesh returns a mesh with given data
 func NewLineMesh(lines []*Line) *Mesh {
-	return &Mesh{nil, lines, nil}
+	return &Mesh{nil, lines, nil, nil, nil}
 }
 
 func (m *Mesh) dirty() {
 	m.box = nil
+	m.bvh = nil
 }
 
 // Copy f
@@ -48,11 +54,28 @@ func (m *Mesh) Copy() *Mesh {
 		a := *l
 		lines[i] = &a
 	}
-	return NewMesh(triangles, lines)
+	mesh := NewMesh(triangles, lines)
+	if m.MaterialIDs != nil {
+		mesh.MaterialIDs = append([]int(nil), m.MaterialIDs...)
+	}
+	return mesh
 }
 
 // Add f
 func (m *Mesh) Add(b *Mesh) {
+	// Material IDs of the mesh without them default to 0
+	if m.hasMaterialIDs() || b.hasMaterialIDs() {
+		ids := make([]int, len(m.Triangles), len(m.Triangles)+len(b.Triangles))
+		if m.hasMaterialIDs() {
+			copy(ids, m.MaterialIDs)
+		}
+		if b.hasMaterialIDs() {
+			ids = append(ids, b.MaterialIDs...)
+		} else {
+			ids = append(ids, make([]int, len(b.Triangles))...)
+		}
+		m.MaterialIDs = ids
+	}
 	m.Triangles = append(m.Triangles, b.Triangles...)
 	m.Lines = append(m.Lines, b.Lines...)
 	m.dirty()
@@ -160,6 +183,74 @@ func (m *Mesh) WeldVertices(threshold float64) {
 	m.dirty()
 }
 
+// Weld snaps vertex positions within epsilon of each other to a shared
+// position, so the triangles of separate primitives or split at seams
+// connect for SmoothNormals and subdivision, and removes the triangles that
+// collapse. Other vertex attributes are kept; see Indexed to share whole
+// vertices. It returns the number of positions merged and triangles
+// removed.
+func (m *Mesh) Weld(epsilon float64) (int, int) {
+	type cell struct{ x, y, z int64 }
+	cellOf := func(p Vector) cell {
+		return cell{int64(math.Floor(p.X / epsilon)), int64(math.Floor(p.Y / epsilon)), int64(math.Floor(p.Z / epsilon))}
+	}
+	grid := make(map[cell][]Vector)
+	canonical := make(map[Vector]Vector)
+	welded := 0
+
+	snap := func(p Vector) Vector {
+		if q, ok := canonical[p]; ok {
+			return q
+		}
+		if epsilon <= 0 {
+			return p
+		}
+		c := cellOf(p)
+		for dz := int64(-1); dz <= 1; dz++ {
+			for dy := int64(-1); dy <= 1; dy++ {
+				for dx := int64(-1); dx <= 1; dx++ {
+					for _, q := range grid[cell{c.x + dx, c.y + dy, c.z + dz}] {
+						if p.DistanceSq(q) <= epsilon*epsilon {
+							canonical[p] = q
+							welded++
+							return q
+						}
+					}
+				}
+			}
+		}
+		grid[c] = append(grid[c], p)
+		canonical[p] = p
+		return p
+	}
+
+	ids := m.hasMaterialIDs()
+	triangles := m.Triangles[:0]
+	removed := 0
+	for i, t := range m.Triangles {
+		t.V1.Position = snap(t.V1.Position)
+		t.V2.Position = snap(t.V2.Position)
+		t.V3.Position = snap(t.V3.Position)
+		if t.V1.Position == t.V2.Position || t.V2.Position == t.V3.Position || t.V3.Position == t.V1.Position {
+			removed++
+			continue
+		}
+		if ids {
+			m.MaterialIDs[len(triangles)] = m.MaterialIDs[i]
+		}
+		triangles = append(triangles, t)
+	}
+	for i := len(triangles); i < len(m.Triangles); i++ {
+		m.Triangles[i] = nil
+	}
+	if ids {
+		m.MaterialIDs = m.MaterialIDs[:len(triangles)]
+	}
+	m.Triangles = triangles
+	m.dirty()
+	return welded, removed
+}
+
 // SmoothNormalsThreshold f
 func (m *Mesh) SmoothNormalsThreshold(radians float64) {
 	threshold := math.Cos(radians)
@@ -275,6 +366,7 @@ func (m *Mesh) transformWithSIMD(matrix Matrix) {
 
 	// 计算法线变换矩阵（逆转置）
 	normalMatrix := matrix.Transpose().Inverse()
+	mirror := matrix.Determinant() < 0
 
 	// 批量处理三角形顶点
 	for _, t := range m.Triangles {
@@ -299,6 +391,9 @@ func (m *Mesh) transformWithSIMD(matrix Matrix) {
 			t.V2.Normal = normalMatrix.MulDirection(t.V2.Normal)
 			t.V3.Normal = normalMatrix.MulDirection(t.V3.Normal)
 		}
+		t.V1.Tangent = transformTangent(matrix, t.V1.Tangent, mirror)
+		t.V2.Tangent = transformTangent(matrix, t.V2.Tangent, mirror)
+		t.V3.Tangent = transformTangent(matrix, t.V3.Tangent, mirror)
 	}
 
 	// 批量处理线条顶点
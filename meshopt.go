@@ -0,0 +1,286 @@
+package fauxgl
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/qmuntal/gltf"
+)
+
+// EXT_meshopt_compression encoding. Vertex streams use the attribute codec
+// (byte-wise deltas between consecutive vertices, packed in groups of 16
+// with 0, 2, 4 or 8 bits per delta) and index streams the index sequence
+// codec (zigzag varint deltas against two running baselines). Both decode
+// with the reference meshoptimizer decoders used by web runtimes.
+
+const (
+	meshoptVertexHeader   = 0xa0
+	meshoptSequenceHeader = 0xd1
+	meshoptByteGroupSize  = 16
+	meshoptVertexBlockMax = 256
+	meshoptVertexBlockLen = 8192
+	meshoptTailMinSize    = 32
+)
+
+// encodeMeshoptVertices compresses count vertices of stride bytes each. The
+// stride must be a multiple of 4 and at most 256.
+func encodeMeshoptVertices(data []byte, count, stride int) []byte {
+	out := []byte{meshoptVertexHeader}
+
+	lastVertex := make([]byte, stride)
+	if count > 0 {
+		copy(lastVertex, data[:stride])
+	}
+	blockSize := meshoptVertexBlockLen / stride &^ (meshoptByteGroupSize - 1)
+	if blockSize > meshoptVertexBlockMax {
+		blockSize = meshoptVertexBlockMax
+	}
+
+	buffer := make([]byte, meshoptVertexBlockMax)
+	for offset := 0; offset < count; offset += blockSize {
+		n := count - offset
+		if n > blockSize {
+			n = blockSize
+		}
+		aligned := (n + meshoptByteGroupSize - 1) &^ (meshoptByteGroupSize - 1)
+		block := data[offset*stride : (offset+n)*stride]
+		for k := 0; k < stride; k++ {
+			// Zigzag deltas of byte k against the previous vertex
+			p := lastVertex[k]
+			for i := 0; i < aligned; i++ {
+				buffer[i] = 0
+				if i < n {
+					v := block[i*stride+k]
+					d := v - p
+					buffer[i] = (d << 1) ^ byte(int8(d)>>7)
+					p = v
+				}
+			}
+			out = meshoptEncodeBytes(out, buffer[:aligned])
+		}
+		copy(lastVertex, block[(n-1)*stride:n*stride])
+	}
+
+	// The tail holds the first vertex, the baseline of the first block,
+	// padded to a minimum size
+	tail := stride
+	if tail < meshoptTailMinSize {
+		out = append(out, make([]byte, meshoptTailMinSize-tail)...)
+	}
+	first := make([]byte, stride)
+	if count > 0 {
+		copy(first, data[:stride])
+	}
+	return append(out, first...)
+}
+
+// meshoptEncodeBytes encodes a multiple of 16 bytes as groups, each prefixed
+// by a 2 bit mode in a shared header
+func meshoptEncodeBytes(out, buffer []byte) []byte {
+	groups := len(buffer) / meshoptByteGroupSize
+	headerStart := len(out)
+	out = append(out, make([]byte, (groups+3)/4)...)
+
+	for g := 0; g < groups; g++ {
+		group := buffer[g*meshoptByteGroupSize : (g+1)*meshoptByteGroupSize]
+		bestMode, bestSize := 3, meshoptByteGroupSize
+		for mode, bits := range [3]int{0, 2, 4} {
+			if size := meshoptGroupSize(group, bits); size >= 0 && size < bestSize {
+				bestMode, bestSize = mode, size
+			}
+		}
+		out[headerStart+g/4] |= byte(bestMode << ((g % 4) * 2))
+
+		switch bestMode {
+		case 0:
+		case 3:
+			out = append(out, group...)
+		default:
+			out = meshoptEncodeGroup(out, group, 1<<bestMode)
+		}
+	}
+	return out
+}
+
+// meshoptGroupSize returns the encoded size of a group with the given bits
+// per value, or -1 when bits is 0 and the group isn't all zero
+func meshoptGroupSize(group []byte, bits int) int {
+	if bits == 0 {
+		for _, v := range group {
+			if v != 0 {
+				return -1
+			}
+		}
+		return 0
+	}
+	size := meshoptByteGroupSize * bits / 8
+	sentinel := byte(1<<bits - 1)
+	for _, v := range group {
+		if v >= sentinel {
+			size++
+		}
+	}
+	return size
+}
+
+// meshoptEncodeGroup packs 16 values MSB first; values that don't fit are
+// stored as the all ones sentinel and appended as raw bytes
+func meshoptEncodeGroup(out, group []byte, bits int) []byte {
+	sentinel := byte(1<<bits - 1)
+	perByte := 8 / bits
+	for i := 0; i < meshoptByteGroupSize; i += perByte {
+		var packed byte
+		for j := 0; j < perByte; j++ {
+			v := group[i+j]
+			if v >= sentinel {
+				v = sentinel
+			}
+			packed = packed<<bits | v
+		}
+		out = append(out, packed)
+	}
+	for _, v := range group {
+		if v >= sentinel {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// encodeMeshoptIndices compresses an index sequence
+func encodeMeshoptIndices(indices []uint32) []byte {
+	out := []byte{meshoptSequenceHeader}
+	var last [2]uint32
+	var scratch [binary.MaxVarintLen32]byte
+	for _, index := range indices {
+		// Delta against the closer of the two baselines
+		d0 := int32(index - last[0])
+		d1 := int32(index - last[1])
+		current := 0
+		if abs32(d1) < abs32(d0) {
+			current = 1
+		}
+		d := int32(index - last[current])
+		v := uint32(d<<1) ^ uint32(d>>31)
+		n := binary.PutUvarint(scratch[:], uint64(v)<<1|uint64(current))
+		out = append(out, scratch[:n]...)
+		last[current] = index
+	}
+	// The tail is reserved for future use and must be zero
+	return append(out, 0, 0, 0, 0)
+}
+
+func abs32(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// compressMeshopt moves the vertex and index buffer views of the document
+// into EXT_meshopt_compression streams. Compressed data replaces buffer 0,
+// which keeps the views that are not compressed (such as images), and the
+// original layout is described by a fallback buffer without data.
+func compressMeshopt(doc *gltf.Document) error {
+	if len(doc.Buffers) == 0 {
+		return nil
+	}
+	source := doc.Buffers[0]
+
+	// Stream mode, element count and stride per buffer view
+	type stream struct {
+		mode   string
+		count  int
+		stride int
+	}
+	streams := make(map[int]stream)
+	for _, mesh := range doc.Meshes {
+		for _, primitive := range mesh.Primitives {
+			for _, accessor := range primitive.Attributes {
+				a := doc.Accessors[accessor]
+				if a.BufferView != nil {
+					streams[*a.BufferView] = stream{"ATTRIBUTES", a.Count, gltf.SizeOfElement(a.ComponentType, a.Type)}
+				}
+			}
+			if primitive.Indices != nil {
+				a := doc.Accessors[*primitive.Indices]
+				if a.BufferView != nil {
+					streams[*a.BufferView] = stream{"INDICES", a.Count, gltf.SizeOfElement(a.ComponentType, a.Type)}
+				}
+			}
+		}
+	}
+	if len(streams) == 0 {
+		return nil
+	}
+
+	var data []byte
+	align := func() {
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	for i, view := range doc.BufferViews {
+		if view.Buffer != 0 {
+			continue
+		}
+		raw := source.Data[view.ByteOffset : view.ByteOffset+view.ByteLength]
+		s, ok := streams[i]
+		if !ok {
+			align()
+			view.ByteOffset = len(data)
+			data = append(data, raw...)
+			continue
+		}
+
+		var encoded []byte
+		switch {
+		case s.mode == "ATTRIBUTES" && s.stride%4 == 0 && s.stride <= meshoptVertexBlockMax:
+			encoded = encodeMeshoptVertices(raw, s.count, s.stride)
+			view.ByteStride = s.stride
+		case s.mode == "INDICES" && (s.stride == 2 || s.stride == 4):
+			indices := make([]uint32, s.count)
+			for k := range indices {
+				if s.stride == 2 {
+					indices[k] = uint32(binary.LittleEndian.Uint16(raw[2*k:]))
+				} else {
+					indices[k] = binary.LittleEndian.Uint32(raw[4*k:])
+				}
+			}
+			encoded = encodeMeshoptIndices(indices)
+		default:
+			return fmt.Errorf("failed to compress buffer view %d: unsupported %s stride %d", i, s.mode, s.stride)
+		}
+
+		align()
+		view.Buffer = 1
+		view.Extensions = gltf.Extensions{"EXT_meshopt_compression": map[string]interface{}{
+			"buffer":     0,
+			"byteOffset": len(data),
+			"byteLength": len(encoded),
+			"byteStride": s.stride,
+			"count":      s.count,
+			"mode":       s.mode,
+		}}
+		data = append(data, encoded...)
+	}
+	align()
+
+	fallback := &gltf.Buffer{
+		ByteLength: source.ByteLength,
+		Extensions: gltf.Extensions{"EXT_meshopt_compression": map[string]interface{}{"fallback": true}},
+	}
+	source.Data = data
+	source.ByteLength = len(data)
+	doc.Buffers = append([]*gltf.Buffer{source, fallback}, doc.Buffers[1:]...)
+	for _, view := range doc.BufferViews {
+		if view.Buffer > 0 && view.Extensions == nil {
+			view.Buffer++
+		}
+	}
+
+	// The fallback buffer holds no data, so loaders must decode the streams
+	doc.ExtensionsUsed = append(doc.ExtensionsUsed, "EXT_meshopt_compression")
+	doc.ExtensionsRequired = append(doc.ExtensionsRequired, "EXT_meshopt_compression")
+	return nil
+}
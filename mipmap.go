@@ -0,0 +1,348 @@
+package fauxgl
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+)
+
+// MipmapFilter selects the downsampling filter used to build mip levels
+type MipmapFilter int
+
+const (
+	// MipmapBox - 2x2 box filter, fast and free of ringing
+	MipmapBox MipmapFilter = iota
+	// MipmapLanczos - Lanczos-3 filter, sharper distant textures
+	MipmapLanczos
+)
+
+// lanczosRadius is the support of the Lanczos kernel in destination pixels
+const lanczosRadius = 3
+
+// lazyMipmaps is a mip chain built on first use. Textures only pay the
+// extra third of memory for levels once they are minified, and copies of a
+// texture share the chain.
+type lazyMipmaps struct {
+	once   sync.Once
+	levels []image.Image
+}
+
+// mipLevels returns the mip chain of minified lookups: MipLevels when set,
+// otherwise the chain built from the image on the first call
+func (t *AdvancedTexture) mipLevels() []image.Image {
+	if len(t.MipLevels) > 0 || t.mipmaps == nil {
+		return t.MipLevels
+	}
+	mipmaps := t.mipmaps
+	mipmaps.once.Do(func() {
+		generated := &AdvancedTexture{Image: t.Image, Width: t.Width, Height: t.Height}
+		generated.GenerateMipmaps()
+		mipmaps.levels = generated.MipLevels
+	})
+	return mipmaps.levels
+}
+
+// refreshMipmaps rebuilds the mip chain after the texture image changed:
+// MipLevels right away when set, otherwise on the next minified lookup
+func (t *AdvancedTexture) refreshMipmaps() {
+	if len(t.MipLevels) > 0 {
+		t.GenerateMipmaps()
+	} else if t.mipmaps != nil {
+		t.mipmaps = &lazyMipmaps{}
+	}
+}
+
+// GenerateMipmaps generates mipmap levels for the texture with a box filter.
+// Textures build them on their first minified lookup otherwise; generating
+// them up front avoids that cost during rendering.
+func (t *AdvancedTexture) GenerateMipmaps() {
+	t.GenerateMipmapsWithFilter(MipmapBox)
+}
+
+// GenerateMipmapsWithFilter generates the full mip chain down to 1x1. Level 0
+// is the texture image; every following level halves the previous one.
+// Color is filtered with alpha weighting so transparent texels don't bleed
+// dark fringes into smaller levels. HDR images are box filtered into HDR
+// levels.
+func (t *AdvancedTexture) GenerateMipmapsWithFilter(filter MipmapFilter) {
+	t.MipLevels = []image.Image{t.Image}
+	if t.Width <= 1 && t.Height <= 1 {
+		return
+	}
+
+	// HDR images keep their range in floating point levels
+	if hdr, ok := t.Image.(*HDRImage); ok {
+		for current := hdr; current.Rect.Dx() > 1 || current.Rect.Dy() > 1; {
+			current = downsampleHDRBox(current, maxInt(1, current.Rect.Dx()/2), maxInt(1, current.Rect.Dy()/2))
+			t.MipLevels = append(t.MipLevels, current)
+		}
+		return
+	}
+
+	current := toNRGBA(t.Image)
+	for {
+		bounds := current.Bounds()
+		width, height := bounds.Dx(), bounds.Dy()
+		if width <= 1 && height <= 1 {
+			break
+		}
+		newWidth := maxInt(1, width/2)
+		newHeight := maxInt(1, height/2)
+
+		var next *image.NRGBA
+		if filter == MipmapLanczos {
+			next = downsampleLanczos(current, newWidth, newHeight)
+		} else {
+			next = downsampleBox(current, newWidth, newHeight)
+		}
+		t.MipLevels = append(t.MipLevels, next)
+		current = next
+	}
+}
+
+// lod returns the mip level of detail for texture coordinate derivatives:
+// log2 of the larger footprint axis in texels of level 0
+func (t *AdvancedTexture) lod(dx, dy Vector) float64 {
+	w := float64(t.Width)
+	h := float64(t.Height)
+	lx := dx.X*dx.X*w*w + dx.Y*dx.Y*h*h
+	ly := dy.X*dy.X*w*w + dy.Y*dy.Y*h*h
+	rho := math.Max(lx, ly)
+	if rho <= 0 {
+		return math.Inf(-1)
+	}
+	return 0.5 * math.Log2(rho)
+}
+
+// sampleTrilinear bilinearly samples the two mip levels around lod and
+// blends them. Without mip levels the base image is sampled. Lookups are
+// inset into region at every level, see AdvancedTexture.HalfTexelClamp.
+func (t *AdvancedTexture) sampleTrilinear(u, v float64, lod float64, region *texelRegion) Color {
+	levels := t.mipLevels()
+	if len(levels) == 0 {
+		u, v = region.inset(u, v, t.Width, t.Height)
+		return t.sampleBilinear(u, v)
+	}
+
+	maxLevel := float64(len(levels) - 1)
+	lod = Clamp(lod, 0, maxLevel)
+	l0 := int(lod)
+	f := lod - float64(l0)
+
+	c0 := sampleMipLevel(levels[l0], u, v, region)
+	if f == 0 || l0+1 >= len(levels) {
+		return c0
+	}
+	c1 := sampleMipLevel(levels[l0+1], u, v, region)
+	return c0.Lerp(c1, f)
+}
+
+// sampleMipLevel bilinearly samples a mip level image
+func sampleMipLevel(img image.Image, u, v float64, region *texelRegion) Color {
+	bounds := img.Bounds()
+	u, v = region.inset(u, v, bounds.Dx(), bounds.Dy())
+	return sampleImageBilinear(img, bounds.Dx(), bounds.Dy(), u, v)
+}
+
+// sampleNRGBABilinear is sampleImageBilinear with direct pixel access
+func sampleNRGBABilinear(img *image.NRGBA, width, height int, u, v float64) Color {
+	x := u * float64(width-1)
+	y := v * float64(height-1)
+	x0 := ClampInt(int(x), 0, width-1)
+	y0 := ClampInt(int(y), 0, height-1)
+	x1 := ClampInt(x0+1, 0, width-1)
+	y1 := ClampInt(y0+1, 0, height-1)
+	fx := x - float64(int(x))
+	fy := y - float64(int(y))
+
+	texel := func(x, y int) Color {
+		i := y*img.Stride + x*4
+		p := img.Pix[i : i+4 : i+4]
+		// Premultiply so the blend matches MakeColor on the base level
+		a := float64(p[3]) / 255
+		return Color{float64(p[0]) / 255 * a, float64(p[1]) / 255 * a, float64(p[2]) / 255 * a, a}
+	}
+
+	top := texel(x0, y0).Lerp(texel(x1, y0), fx)
+	bottom := texel(x0, y1).Lerp(texel(x1, y1), fx)
+	return top.Lerp(bottom, fy)
+}
+
+// toNRGBA returns the image as an NRGBA image with origin (0, 0)
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Bounds().Min == (image.Point{}) {
+		return nrgba
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	return dst
+}
+
+// downsampleBox reduces an image to width x height averaging the source
+// texels covered by each destination texel
+func downsampleBox(src *image.NRGBA, width, height int) *image.NRGBA {
+	bounds := src.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy0 := y * sh / height
+		sy1 := maxInt(sy0+1, (y+1)*sh/height)
+		for x := 0; x < width; x++ {
+			sx0 := x * sw / width
+			sx1 := maxInt(sx0+1, (x+1)*sw/width)
+			var r, g, b, a, n float64
+			for sy := sy0; sy < sy1; sy++ {
+				i := sy*src.Stride + sx0*4
+				for sx := sx0; sx < sx1; sx++ {
+					alpha := float64(src.Pix[i+3])
+					r += float64(src.Pix[i+0]) * alpha
+					g += float64(src.Pix[i+1]) * alpha
+					b += float64(src.Pix[i+2]) * alpha
+					a += alpha
+					n++
+					i += 4
+				}
+			}
+			dst.SetNRGBA(x, y, weightedNRGBA(r, g, b, a, n))
+		}
+	}
+	return dst
+}
+
+// downsampleHDRBox is downsampleBox for HDR images, whatever the filter
+func downsampleHDRBox(src *HDRImage, width, height int) *HDRImage {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dst := NewHDRImage(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy0 := y * sh / height
+		sy1 := maxInt(sy0+1, (y+1)*sh/height)
+		for x := 0; x < width; x++ {
+			sx0 := x * sw / width
+			sx1 := maxInt(sx0+1, (x+1)*sw/width)
+			var sum Color
+			n := 0.0
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					sum = sum.Add(src.ColorAt(sx+src.Rect.Min.X, sy+src.Rect.Min.Y).Premultiply())
+					n++
+				}
+			}
+			dst.SetColor(x, y, sum.DivScalar(n).Unpremultiply())
+		}
+	}
+	return dst
+}
+
+// downsampleLanczos reduces an image to width x height with a separable
+// Lanczos-3 filter
+func downsampleLanczos(src *image.NRGBA, width, height int) *image.NRGBA {
+	bounds := src.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+
+	// Horizontal pass into alpha-premultiplied floats
+	horizontal := make([]float64, width*sh*4)
+	xWeights := lanczosWeights(sw, width)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < width; x++ {
+			var r, g, b, a float64
+			for _, w := range xWeights[x] {
+				i := y*src.Stride + w.index*4
+				alpha := float64(src.Pix[i+3])
+				r += float64(src.Pix[i+0]) * alpha * w.weight
+				g += float64(src.Pix[i+1]) * alpha * w.weight
+				b += float64(src.Pix[i+2]) * alpha * w.weight
+				a += alpha * w.weight
+			}
+			j := (y*width + x) * 4
+			horizontal[j+0], horizontal[j+1], horizontal[j+2], horizontal[j+3] = r, g, b, a
+		}
+	}
+
+	// Vertical pass
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	yWeights := lanczosWeights(sh, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var r, g, b, a float64
+			for _, w := range yWeights[y] {
+				j := (w.index*width + x) * 4
+				r += horizontal[j+0] * w.weight
+				g += horizontal[j+1] * w.weight
+				b += horizontal[j+2] * w.weight
+				a += horizontal[j+3] * w.weight
+			}
+			dst.SetNRGBA(x, y, weightedNRGBA(r, g, b, a, 1))
+		}
+	}
+	return dst
+}
+
+// filterTap is one source texel contributing to a destination texel
+type filterTap struct {
+	index  int
+	weight float64
+}
+
+// lanczosWeights returns the normalized taps for each destination texel when
+// resampling srcSize texels to dstSize texels
+func lanczosWeights(srcSize, dstSize int) [][]filterTap {
+	scale := float64(srcSize) / float64(dstSize)
+	support := lanczosRadius * math.Max(scale, 1)
+	weights := make([][]filterTap, dstSize)
+	for i := range weights {
+		center := (float64(i)+0.5)*scale - 0.5
+		first := int(math.Floor(center - support))
+		last := int(math.Ceil(center + support))
+		var taps []filterTap
+		total := 0.0
+		for j := first; j <= last; j++ {
+			w := lanczos((float64(j) - center) / math.Max(scale, 1))
+			if w == 0 {
+				continue
+			}
+			taps = append(taps, filterTap{ClampInt(j, 0, srcSize-1), w})
+			total += w
+		}
+		for k := range taps {
+			taps[k].weight /= total
+		}
+		weights[i] = taps
+	}
+	return weights
+}
+
+// lanczos evaluates the Lanczos-3 kernel
+func lanczos(x float64) float64 {
+	x = math.Abs(x)
+	if x == 0 {
+		return 1
+	}
+	if x >= lanczosRadius {
+		return 0
+	}
+	px := math.Pi * x
+	return lanczosRadius * math.Sin(px) * math.Sin(px/lanczosRadius) / (px * px)
+}
+
+// weightedNRGBA converts alpha weighted color sums over n texels to NRGBA
+func weightedNRGBA(r, g, b, a, n float64) color.NRGBA {
+	if a <= 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{
+		R: uint8(Clamp(r/a, 0, 255) + 0.5),
+		G: uint8(Clamp(g/a, 0, 255) + 0.5),
+		B: uint8(Clamp(b/a, 0, 255) + 0.5),
+		A: uint8(Clamp(a/n, 0, 255) + 0.5),
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,86 @@
+package fauxgl
+
+// ApplyMorph deforms the node's mesh by its weighted morph targets. As with
+// ApplySkin the undeformed mesh is kept in BindMesh and node.Mesh becomes a
+// deformed copy, so weights can change between calls.
+func (node *SceneNode) ApplyMorph() {
+	if node.MorphTargets == nil || node.Mesh == nil {
+		return
+	}
+	node.prepareDeformedMesh()
+	MorphMeshInto(node.Mesh, node.BindMesh, node.MorphTargets)
+}
+
+// prepareDeformedMesh keeps the undeformed mesh in BindMesh on first use
+// and makes node.Mesh a copy of it for deformations to write into
+func (node *SceneNode) prepareDeformedMesh() {
+	if node.BindMesh == nil {
+		node.BindMesh = node.Mesh
+	}
+	if node.Mesh == node.BindMesh || len(node.Mesh.Triangles) != len(node.BindMesh.Triangles) {
+		node.Mesh = node.BindMesh.Copy()
+	}
+}
+
+// ApplyMorphTargets returns a copy of baseMesh deformed by the weighted
+// morph targets
+func ApplyMorphTargets(baseMesh *Mesh, targets *MorphTargets) *Mesh {
+	if len(targets.Targets) == 0 || len(targets.Weights) == 0 {
+		return baseMesh
+	}
+	result := baseMesh.Copy()
+	MorphMeshInto(result, baseMesh, targets)
+	return result
+}
+
+// MorphMeshInto writes the morphed vertices of base into dst, which must
+// have the same triangle layout (e.g. a copy of base)
+func MorphMeshInto(dst, base *Mesh, targets *MorphTargets) {
+	for i, t := range base.Triangles {
+		if i >= len(dst.Triangles) {
+			break
+		}
+		d := dst.Triangles[i]
+		d.V1 = morphVertex(t.V1, 3*i, targets)
+		d.V2 = morphVertex(t.V2, 3*i+1, targets)
+		d.V3 = morphVertex(t.V3, 3*i+2, targets)
+	}
+	dst.dirty()
+}
+
+// morphVertex adds the weighted displacements of the primitive vertex
+// behind a triangle corner
+func morphVertex(v Vertex, corner int, targets *MorphTargets) Vertex {
+	index := corner
+	if targets.Indices != nil {
+		if corner >= len(targets.Indices) {
+			return v
+		}
+		index = targets.Indices[corner]
+	}
+
+	var normal, tangent Vector
+	for k, w := range targets.Weights {
+		if w == 0 || k >= len(targets.Targets) {
+			continue
+		}
+		target := &targets.Targets[k]
+		if index < len(target.Positions) {
+			v.Position = v.Position.Add(target.Positions[index].MulScalar(w))
+		}
+		if index < len(target.Normals) {
+			normal = normal.Add(target.Normals[index].MulScalar(w))
+		}
+		if index < len(target.Tangents) {
+			tangent = tangent.Add(target.Tangents[index].MulScalar(w))
+		}
+	}
+	if normal != (Vector{}) && v.Normal != (Vector{}) {
+		v.Normal = v.Normal.Add(normal).Normalize()
+	}
+	if tangent != (Vector{}) && v.Tangent != (VectorW{}) {
+		t := v.Tangent.Vector().Add(tangent).Normalize()
+		v.Tangent = VectorW{t.X, t.Y, t.Z, v.Tangent.W}
+	}
+	return v
+}
@@ -0,0 +1,156 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+)
+
+// motionTileSize is the size in pixels of the tiles the largest motion is
+// searched in
+const motionTileSize = 16
+
+// NewVelocityMotionBlurEffect creates a motion blur effect blurring every
+// pixel along its motion in the VelocityBuffer of a context, with a 180
+// degree shutter. Enable the buffer with Context.EnableVelocityBuffer
+// before rendering the frames with a SceneRenderer, which tracks the
+// camera and nodes between them.
+func NewVelocityMotionBlurEffect(context *Context) *MotionBlurEffect {
+	return &MotionBlurEffect{
+		Samples: 16,
+		Context: context,
+		Shutter: 0.5,
+		MaxBlur: 32,
+	}
+}
+
+// applyVelocity blurs every pixel along the largest motion around it,
+// weighting each sample by whether its own motion or the pixel's covers the
+// distance between them: moving foregrounds smear over still backgrounds,
+// and still foregrounds stay sharp over moving backgrounds.
+func (mbe *MotionBlurEffect) applyVelocity(input *image.NRGBA) *image.NRGBA {
+	bounds := input.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dc := mbe.Context
+	reversed := dc.DepthMode == DepthReversed
+	scale := float64(width) / float64(dc.Width)
+
+	// Motion over the shutter and depth at the input resolution, which is
+	// the output resolution of a supersampled context
+	n := width * height
+	velocity := make([]Vector, n)
+	speed := make([]float64, n)
+	depth := make([]float64, n)
+	colors := make([]Color, n)
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			by := ClampInt((2*y+1)*dc.Height/(2*height), 0, dc.Height-1)
+			for x := 0; x < width; x++ {
+				bx := ClampInt((2*x+1)*dc.Width/(2*width), 0, dc.Width-1)
+				i := y*width + x
+				v := dc.VelocityBuffer[by*dc.Width+bx].MulScalar(scale * mbe.Shutter)
+				if l := v.Length(); mbe.MaxBlur > 0 && l > mbe.MaxBlur {
+					v = v.MulScalar(mbe.MaxBlur / l)
+				}
+				velocity[i], speed[i] = v, v.Length()
+				depth[i] = dc.DepthBuffer[by*dc.Width+bx]
+				colors[i] = MakeColor(input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)).Premultiply()
+			}
+		}
+	})
+
+	// The largest motion of every tile, then of the tiles reaching it
+	tilesX := (width + motionTileSize - 1) / motionTileSize
+	tilesY := (height + motionTileSize - 1) / motionTileSize
+	tileMax := make([]Vector, tilesX*tilesY)
+	maxSpeed := 0.0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i, t := y*width+x, (y/motionTileSize)*tilesX+x/motionTileSize
+			if speed[i] > tileMax[t].Length() {
+				tileMax[t] = velocity[i]
+				maxSpeed = math.Max(maxSpeed, speed[i])
+			}
+		}
+	}
+	// Samples span half the motion either side of a pixel
+	span := int(math.Ceil(maxSpeed/2/motionTileSize)) + 1
+	neighborMax := make([]Vector, len(tileMax))
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			var best Vector
+			for sy := maxInt(ty-span, 0); sy <= ty+span && sy < tilesY; sy++ {
+				for sx := maxInt(tx-span, 0); sx <= tx+span && sx < tilesX; sx++ {
+					if v := tileMax[sy*tilesX+sx]; v.Length() > best.Length() {
+						best = v
+					}
+				}
+			}
+			neighborMax[ty*tilesX+tx] = best
+		}
+	}
+
+	// cone is the coverage of a pixel a distance away by a smear of a
+	// length, cylinder that of its own extent
+	cone := func(distance, length float64) float64 {
+		if length <= 0 {
+			return 0
+		}
+		return Clamp(1-distance/length, 0, 1)
+	}
+	cylinder := func(distance, length float64) float64 {
+		t := Clamp((distance-0.95*length)/(0.1*length+1e-9), 0, 1)
+		return 1 - t*t*(3-2*t)
+	}
+
+	samples := maxInt(mbe.Samples, 1)
+	output := image.NewNRGBA(bounds)
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				i := y*width + x
+				direction := neighborMax[(y/motionTileSize)*tilesX+x/motionTileSize]
+				if direction.Length() < 0.5 {
+					output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y))
+					continue
+				}
+				sx := speed[i]
+				weight := 1 / math.Max(sx, 1)
+				sum := colors[i].MulScalar(weight)
+
+				// Jitter the samples per pixel against banding
+				noise := 0.06711056*float64(x) + 0.00583715*float64(y)
+				noise = 52.9829189 * (noise - math.Floor(noise))
+				jitter := noise - math.Floor(noise) - 0.5
+				for s := 0; s < samples; s++ {
+					t := (float64(s)+0.5+jitter)/float64(samples) - 0.5
+					offset := direction.MulScalar(t)
+					qx, qy := x+int(math.Round(offset.X)), y+int(math.Round(offset.Y))
+					if qx < 0 || qy < 0 || qx >= width || qy >= height || (qx == x && qy == y) {
+						continue
+					}
+					j := qy*width + qx
+					distance := offset.Length()
+					sy := speed[j]
+					var w float64
+					if depthPasses(depth[j], depth[i], reversed) && depth[j] != depth[i] {
+						// A nearer sample smears over the pixel by its motion
+						w = cone(distance, sy)
+					} else {
+						// A farther one shows where the pixel smears away
+						w = cone(distance, sx)
+					}
+					w += cylinder(distance, sy) * cylinder(distance, sx) * 2
+					if w > 0 {
+						sum = sum.Add(colors[j].MulScalar(w))
+						weight += w
+					}
+				}
+				c := sum.DivScalar(weight)
+				a := Clamp(c.A, 0, 1)
+				c = Color{Clamp(c.R, 0, a), Clamp(c.G, 0, a), Clamp(c.B, 0, a), a}
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, c.Unpremultiply().NRGBA())
+			}
+		}
+	})
+	return output
+}
@@ -0,0 +1,72 @@
+package fauxgl
+
+// velocity returns the motion in pixels since the previous frame of a
+// fragment at a pixel, given its position as the vertex shader left it
+func (dc *Context) velocity(x, y int, position Vector) Vector {
+	p := dc.PreviousMatrix.MulPositionW(position)
+	if p.W <= 0 {
+		// No previous matrix, or behind the previous camera
+		return Vector{}
+	}
+	s := dc.screenMatrix.MulPosition(p.DivScalar(p.W).Vector())
+	return Vector{float64(x) + 0.5 - s.X, float64(y) + 0.5 - s.Y, 0}
+}
+
+// motionHistory holds the camera matrix and instance transforms of the
+// current and previous frames of a SceneRenderer, for the velocity buffer
+// of its context
+type motionHistory struct {
+	camera         Matrix
+	previousCamera Matrix
+	transforms     map[motionKey]Matrix
+	previous       map[motionKey]Matrix
+}
+
+// motionKey identifies an instance of a node across frames
+type motionKey struct {
+	node     *SceneNode
+	instance int
+}
+
+// beginMotion starts a frame through a camera matrix, making the last one
+// the previous frame, when the context has a velocity buffer. The first
+// frame has no motion.
+func (renderer *SceneRenderer) beginMotion(cameraMatrix Matrix) {
+	if renderer.context.VelocityBuffer == nil {
+		return
+	}
+	if renderer.motion == nil {
+		renderer.motion = &motionHistory{camera: cameraMatrix}
+	}
+	m := renderer.motion
+	m.previousCamera, m.camera = m.camera, cameraMatrix
+	m.previous, m.transforms = m.transforms, make(map[motionKey]Matrix)
+}
+
+// ResetMotion forgets the previous frame, so the next one renders without
+// motion, e.g. after a cut
+func (renderer *SceneRenderer) ResetMotion() {
+	renderer.motion = nil
+}
+
+// previousMatrix records the model matrix of an instance of a node for the
+// next frame and returns its clip space matrix in the previous frame, the
+// current model matrix for instances that weren't drawn then
+func (m *motionHistory) previousMatrix(node *SceneNode, instance int, model Matrix) Matrix {
+	key := motionKey{node.sourceNode(), instance}
+	m.transforms[key] = model
+	previous, ok := m.previous[key]
+	if !ok {
+		previous = model
+	}
+	return m.previousCamera.Mul(previous)
+}
+
+// sourceNode returns the scene node a per-frame copy of a node was made
+// from, such as its parts by material, or the node itself
+func (node *SceneNode) sourceNode() *SceneNode {
+	if node.source != nil {
+		return node.source
+	}
+	return node
+}
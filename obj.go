@@ -0,0 +1,217 @@
+package fauxgl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadOBJ loads a Wavefront OBJ file as a mesh
+func LoadOBJ(path string) (*Mesh, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadOBJFromBytes(data)
+}
+
+// LoadOBJFromBytes loads Wavefront OBJ data as a mesh. Faces are
+// triangulated as fans. Texture coordinates, normals and the common vertex
+// color extension ("v x y z r g b") are read; without normals the mesh gets
+// smooth normals from its faces. Groups, materials and curves are ignored.
+func LoadOBJFromBytes(data []byte) (*Mesh, error) {
+	var positions, textures, normals []Vector
+	var colors []Color
+	var triangles []*Triangle
+	hasNormals := false
+
+	// index resolves a 1-based or negative, relative OBJ index
+	index := func(s string, count, line int) (int, error) {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("OBJ line %d: %w", line, err)
+		}
+		if i < 0 {
+			i += count
+		} else {
+			i--
+		}
+		if i < 0 || i >= count {
+			return 0, fmt.Errorf("OBJ line %d: index %s out of range", line, s)
+		}
+		return i, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		floats := func(values []string, n int) ([]float64, error) {
+			if len(values) < n {
+				return nil, fmt.Errorf("OBJ line %d: expected %d values", line, n)
+			}
+			result := make([]float64, len(values))
+			for i, s := range values {
+				f, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					return nil, fmt.Errorf("OBJ line %d: %w", line, err)
+				}
+				result[i] = f
+			}
+			return result, nil
+		}
+		switch fields[0] {
+		case "v":
+			f, err := floats(fields[1:], 3)
+			if err != nil {
+				return nil, err
+			}
+			positions = append(positions, Vector{f[0], f[1], f[2]})
+			color := Color{}
+			if len(f) >= 6 {
+				color = Color{f[3], f[4], f[5], 1}
+			}
+			colors = append(colors, color)
+		case "vt":
+			f, err := floats(fields[1:], 1)
+			if err != nil {
+				return nil, err
+			}
+			f = append(f, 0)
+			textures = append(textures, Vector{f[0], f[1], 0})
+		case "vn":
+			f, err := floats(fields[1:], 3)
+			if err != nil {
+				return nil, err
+			}
+			normals = append(normals, Vector{f[0], f[1], f[2]}.Normalize())
+		case "f":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("OBJ line %d: face has %d vertices", line, len(fields)-1)
+			}
+			face := make([]Vertex, len(fields)-1)
+			faceNormals := true
+			for i, field := range fields[1:] {
+				parts := strings.Split(field, "/")
+				p, err := index(parts[0], len(positions), line)
+				if err != nil {
+					return nil, err
+				}
+				v := Vertex{Position: positions[p], Color: colors[p]}
+				if len(parts) > 1 && parts[1] != "" {
+					t, err := index(parts[1], len(textures), line)
+					if err != nil {
+						return nil, err
+					}
+					v.Texture = textures[t]
+				}
+				if len(parts) > 2 && parts[2] != "" {
+					n, err := index(parts[2], len(normals), line)
+					if err != nil {
+						return nil, err
+					}
+					v.Normal = normals[n]
+				} else {
+					faceNormals = false
+				}
+				face[i] = v
+			}
+			hasNormals = hasNormals || faceNormals
+			for i := 1; i+1 < len(face); i++ {
+				triangles = append(triangles, NewTriangle(face[0], face[i], face[i+1]))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	mesh := NewTriangleMesh(triangles)
+	if !hasNormals {
+		mesh.SmoothNormals()
+	}
+	return mesh, nil
+}
+
+// SaveOBJ saves a mesh as a Wavefront OBJ file
+func SaveOBJ(path string, mesh *Mesh) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := WriteOBJ(w, mesh); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteOBJ writes a mesh as Wavefront OBJ with the vertices its triangles
+// share written once. Positions and normals are always written; texture
+// coordinates and vertex colors when any vertex has them.
+func WriteOBJ(w io.Writer, mesh *Mesh) error {
+	indexed := mesh.Indexed()
+	textured, colored := false, false
+	for _, v := range indexed.Vertices {
+		textured = textured || v.Texture != (Vector{})
+		colored = colored || v.Color != (Color{})
+	}
+
+	if _, err := io.WriteString(w, "# fauxgl\n"); err != nil {
+		return err
+	}
+	var buf []byte
+	float := func(prefix string, values ...float64) {
+		buf = append(buf, prefix...)
+		for _, f := range values {
+			buf = append(buf, ' ')
+			buf = strconv.AppendFloat(buf, f, 'g', -1, 32)
+		}
+		buf = append(buf, '\n')
+	}
+	for _, v := range indexed.Vertices {
+		buf = buf[:0]
+		if colored {
+			float("v", v.Position.X, v.Position.Y, v.Position.Z, v.Color.R, v.Color.G, v.Color.B)
+		} else {
+			float("v", v.Position.X, v.Position.Y, v.Position.Z)
+		}
+		if textured {
+			float("vt", v.Texture.X, v.Texture.Y)
+		}
+		float("vn", v.Normal.X, v.Normal.Y, v.Normal.Z)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	// Every vertex has its own texture coordinate and normal, so the three
+	// indices of a corner are equal
+	for i := 0; i < indexed.Triangles(); i++ {
+		buf = append(buf[:0], 'f')
+		for _, k := range indexed.Indices[3*i : 3*i+3] {
+			if textured {
+				buf = fmt.Appendf(buf, " %d/%d/%d", k+1, k+1, k+1)
+			} else {
+				buf = fmt.Appendf(buf, " %d//%d", k+1, k+1)
+			}
+		}
+		buf = append(buf, '\n')
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
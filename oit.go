@@ -0,0 +1,90 @@
+package fauxgl
+
+import (
+	"math"
+)
+
+// Weighted blended order-independent transparency (McGuire and Bavoil,
+// 2013). Between BeginTransparency and ResolveTransparency fragments are
+// not composited over the color buffer; their premultiplied colors are
+// summed with a weight favouring the nearest ones, and the product of their
+// transparencies is kept per pixel. ResolveTransparency composites the
+// weighted average color with the total coverage, so the result does not
+// depend on the order surfaces are drawn in. It approximates sorted
+// blending well when the overlapping layers have similar opacity.
+
+// oitBuffer holds the accumulation of weighted blended transparency
+type oitBuffer struct {
+	accum     []float64 // premultiplied R, G, B and alpha times weight
+	revealage []float64 // product of (1 - alpha) of the fragments
+}
+
+// BeginTransparency starts accumulating drawn fragments for order
+// independent transparency. Fragments still read the depth buffer, so draw
+// opaque geometry first, and usually disable WriteDepth.
+func (dc *Context) BeginTransparency() {
+	n := dc.Width * dc.Height
+	if dc.oit == nil || len(dc.oit.revealage) != n {
+		dc.oit = &oitBuffer{make([]float64, 4*n), make([]float64, n)}
+	}
+	for i := range dc.oit.accum {
+		dc.oit.accum[i] = 0
+	}
+	for i := range dc.oit.revealage {
+		dc.oit.revealage[i] = 1
+	}
+}
+
+// ResolveTransparency composites the fragments accumulated since
+// BeginTransparency over the color buffer, and the HDR buffer if enabled,
+// and ends the accumulation
+func (dc *Context) ResolveTransparency() {
+	oit := dc.oit
+	if oit == nil {
+		return
+	}
+	dc.oit = nil
+	parallelRows(dc.Height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < dc.Width; x++ {
+				i := y*dc.Width + x
+				alpha := 1 - oit.revealage[i]
+				if alpha <= 0 {
+					continue
+				}
+				a := oit.accum[i*4 : i*4+4 : i*4+4]
+				w := math.Max(a[3], 1e-5)
+				c := Color{a[0] / w, a[1] / w, a[2] / w, alpha}
+				if dc.HDRBuffer != nil {
+					dc.HDRBuffer.blend(x, y, c)
+				}
+				j := dc.ColorBuffer.PixOffset(x, y)
+				p := dc.ColorBuffer.Pix[j : j+4 : j+4]
+				dst := Color{float64(p[0]) / 255, float64(p[1]) / 255, float64(p[2]) / 255, float64(p[3]) / 255}
+				n := c.Over(dst).NRGBA()
+				p[0], p[1], p[2], p[3] = n.R, n.G, n.B, n.A
+			}
+		}
+	})
+}
+
+// add accumulates a straight alpha fragment of window depth z at pixel i.
+// The caller holds the pixel lock.
+func (oit *oitBuffer) add(i int, c Color, z float64, reversed bool) {
+	a := Clamp(c.A, 0, 1)
+	if a <= 0 {
+		return
+	}
+	// Window depth is 0 at the near plane, 1 at the far plane
+	d := Clamp(z, 0, 1)
+	if reversed {
+		d = 1 - d
+	}
+	w := a * Clamp(3e3*math.Pow(1-d, 3), 1e-2, 3e3)
+	p := oit.accum[i*4 : i*4+4 : i*4+4]
+	p[0] += c.R * a * w
+	p[1] += c.G * a * w
+	p[2] += c.B * a * w
+	p[3] += a * w
+	oit.revealage[i] *= 1 - a
+}
@@ -0,0 +1,772 @@
+package fauxgl
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// OptimizeOptions selects the steps of OptimizeScene
+type OptimizeOptions struct {
+	// StripUnused removes meshes, materials and textures that no node uses
+	StripUnused bool
+	// MaxTextureSize downsamples textures whose larger side exceeds it; 0
+	// keeps texture sizes
+	MaxTextureSize int
+	// AtlasTextures packs the textures of materials whose texture
+	// coordinates stay within [0, 1] into shared atlases, remapping the UVs
+	// of their meshes. Atlases are at most AtlasMaxSize pixels wide and
+	// tall, with AtlasPadding pixels of replicated edge around each region.
+	AtlasTextures bool
+	AtlasMaxSize  int
+	AtlasPadding  int
+	// WeldThreshold merges vertex positions closer than this distance and
+	// drops the triangles that collapse, then merges the normals, texture
+	// coordinates and tangents of each position that are this close, so
+	// Mesh.Indexed and the exporters share the welded vertices; 0 disables
+	// welding
+	WeldThreshold float64
+	// LODRatios generates one lower detail mesh per ratio, the fraction of
+	// triangles kept, into SceneNode.LODs. Each is drawn below a screen
+	// coverage of the square root of its ratio, which keeps the triangles
+	// per pixel about constant.
+	LODRatios []float64
+}
+
+// DefaultOptimizeOptions returns options preparing a scene for web delivery
+func DefaultOptimizeOptions() OptimizeOptions {
+	return OptimizeOptions{
+		StripUnused:    true,
+		MaxTextureSize: 2048,
+		AtlasTextures:  true,
+		AtlasMaxSize:   4096,
+		AtlasPadding:   4,
+		WeldThreshold:  1e-5,
+		LODRatios:      []float64{0.5, 0.25},
+	}
+}
+
+// SceneStats counts the resources of a scene
+type SceneStats struct {
+	Nodes         int
+	Meshes        int
+	Triangles     int
+	Vertices      int // Distinct vertices, as an indexed export would store them
+	Materials     int
+	Textures      int
+	TexturePixels int64
+}
+
+// OptimizeReport describes what OptimizeScene changed
+type OptimizeReport struct {
+	Before           SceneStats
+	After            SceneStats
+	ResizedTextures  int
+	AtlasedMaterials int
+	Atlases          int
+	WeldedVertices   int // Vertex positions merged into a neighbor
+	RemovedTriangles int // Triangles that collapsed while welding
+	LODMeshes        int
+	LODTriangles     int
+}
+
+// String summarizes the report
+func (r *OptimizeReport) String() string {
+	return fmt.Sprintf("meshes %d -> %d, triangles %d -> %d, vertices %d -> %d, materials %d -> %d, textures %d -> %d (%d -> %d pixels); "+
+		"%d textures resized, %d materials in %d atlases, %d vertices welded, %d triangles removed, %d LOD meshes with %d triangles",
+		r.Before.Meshes, r.After.Meshes, r.Before.Triangles, r.After.Triangles, r.Before.Vertices, r.After.Vertices,
+		r.Before.Materials, r.After.Materials, r.Before.Textures, r.After.Textures, r.Before.TexturePixels, r.After.TexturePixels,
+		r.ResizedTextures, r.AtlasedMaterials, r.Atlases, r.WeldedVertices, r.RemovedTriangles, r.LODMeshes, r.LODTriangles)
+}
+
+// OptimizeScene runs an asset optimization pipeline over a scene in place:
+// unused resources are stripped, oversized textures downsampled, textures
+// packed into atlases, vertices welded and LOD meshes generated, in that
+// order. Meshes and textures are modified in place, so share them with
+// other scenes only if that is intended.
+func OptimizeScene(scene *Scene, options OptimizeOptions) *OptimizeReport {
+	report := &OptimizeReport{Before: SceneStatistics(scene)}
+
+	if options.StripUnused {
+		stripUnused(scene)
+	}
+	if options.MaxTextureSize > 0 {
+		for _, texture := range sceneTextures(scene) {
+			if resizeTexture(texture, options.MaxTextureSize) {
+				report.ResizedTextures++
+			}
+		}
+	}
+	if options.AtlasTextures {
+		report.AtlasedMaterials, report.Atlases = atlasSceneTextures(scene, options.AtlasMaxSize, options.AtlasPadding)
+	}
+	if options.WeldThreshold > 0 {
+		for _, mesh := range sceneMeshes(scene) {
+			welded, removed := mesh.Weld(options.WeldThreshold)
+			weldAttributes(mesh, options.WeldThreshold)
+			report.WeldedVertices += welded
+			report.RemovedTriangles += removed
+		}
+	}
+	if len(options.LODRatios) > 0 {
+		lods := make(map[*Mesh][]*Mesh)
+		for _, node := range sceneMeshNodes(scene) {
+			if _, ok := lods[node.Mesh]; !ok {
+				for _, ratio := range options.LODRatios {
+					lod := simplifyClustered(node.Mesh, ratio)
+					lods[node.Mesh] = append(lods[node.Mesh], lod)
+					report.LODMeshes++
+					report.LODTriangles += len(lod.Triangles)
+				}
+			}
+			node.LODs = lods[node.Mesh]
+			node.LODCoverage = node.LODCoverage[:0]
+			for _, ratio := range options.LODRatios {
+				node.LODCoverage = append(node.LODCoverage, math.Sqrt(Clamp(ratio, 0, 1)))
+			}
+		}
+	}
+
+	report.After = SceneStatistics(scene)
+	return report
+}
+
+// SceneStatistics counts the nodes, meshes, materials and textures of a
+// scene, including registered resources that no node uses
+func SceneStatistics(scene *Scene) SceneStats {
+	var stats SceneStats
+	scene.RootNode.VisitNodes(func(*SceneNode) {
+		stats.Nodes++
+	})
+	for _, mesh := range sceneMeshes(scene) {
+		stats.Meshes++
+		stats.Triangles += len(mesh.Triangles)
+		stats.Vertices += distinctVertices(mesh)
+	}
+	stats.Materials = len(sceneMaterials(scene))
+	for _, texture := range sceneTextures(scene) {
+		stats.Textures++
+		stats.TexturePixels += int64(texture.Width) * int64(texture.Height)
+	}
+	return stats
+}
+
+// sceneMeshNodes returns the nodes with a mesh
+func sceneMeshNodes(scene *Scene) []*SceneNode {
+	var nodes []*SceneNode
+	scene.RootNode.VisitNodes(func(node *SceneNode) {
+		if node.Mesh != nil {
+			nodes = append(nodes, node)
+		}
+	})
+	return nodes
+}
+
+// sceneMeshes returns the distinct meshes of the nodes and the mesh registry
+func sceneMeshes(scene *Scene) []*Mesh {
+	var meshes []*Mesh
+	seen := make(map[*Mesh]bool)
+	add := func(mesh *Mesh) {
+		if mesh != nil && !seen[mesh] {
+			seen[mesh] = true
+			meshes = append(meshes, mesh)
+		}
+	}
+	for _, node := range sceneMeshNodes(scene) {
+		add(node.Mesh)
+	}
+	names := make([]string, 0, len(scene.Meshes))
+	for name := range scene.Meshes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(scene.Meshes[name])
+	}
+	return meshes
+}
+
+// sceneMaterials returns the distinct materials of the nodes and the
+// material registry
+func sceneMaterials(scene *Scene) []*PBRMaterial {
+	var materials []*PBRMaterial
+	seen := make(map[*PBRMaterial]bool)
+	add := func(material *PBRMaterial) {
+		if material != nil && !seen[material] {
+			seen[material] = true
+			materials = append(materials, material)
+		}
+	}
+	scene.RootNode.VisitNodes(func(node *SceneNode) {
+		add(node.Material)
+	})
+	names := make([]string, 0, len(scene.Materials))
+	for name := range scene.Materials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(scene.Materials[name])
+	}
+	return materials
+}
+
+// sceneTextures returns the distinct textures of the materials and the
+// texture registry
+func sceneTextures(scene *Scene) []*AdvancedTexture {
+	var textures []*AdvancedTexture
+	seen := make(map[*AdvancedTexture]bool)
+	add := func(texture *AdvancedTexture) {
+		if texture != nil && !seen[texture] {
+			seen[texture] = true
+			textures = append(textures, texture)
+		}
+	}
+	for _, material := range sceneMaterials(scene) {
+		for _, slot := range material.textureSlots() {
+			if texture, ok := (*slot).(*AdvancedTexture); ok {
+				add(texture)
+			}
+		}
+	}
+	names := make([]string, 0, len(scene.Textures))
+	for name := range scene.Textures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(scene.Textures[name])
+	}
+	return textures
+}
+
+// textureSlots returns pointers to every texture field of the material
+func (material *PBRMaterial) textureSlots() []*Texture {
+	return []*Texture{
+		&material.BaseColorTexture, &material.MetallicRoughnessTexture,
+		&material.DiffuseTexture, &material.SpecularGlossinessTexture,
+		&material.NormalTexture, &material.OcclusionTexture, &material.EmissiveTexture,
+		&material.HeightTexture, &material.SpecularColorTexture, &material.SpecularTexture,
+		&material.TransmissionTexture, &material.ThicknessTexture, &material.AnisotropyTexture,
+		&material.SheenColorTexture, &material.SheenRoughnessTexture,
+		&material.IridescenceTexture, &material.IridescenceThicknessTexture,
+		&material.ClearcoatTexture, &material.ClearcoatRoughnessTexture, &material.ClearcoatNormalTexture,
+		&material.SubsurfaceThicknessTexture,
+	}
+}
+
+// atlasSlotNames are the texture slots that can be packed into atlases:
+// the core metallic-roughness slots
+var atlasSlotNames = []string{
+	TextureSlotBaseColor, TextureSlotMetallicRoughness,
+	TextureSlotNormal, TextureSlotOcclusion, TextureSlotEmissive,
+}
+
+// atlasSlots returns the texture fields of atlasSlotNames
+func (material *PBRMaterial) atlasSlots() []*Texture {
+	slots := make([]*Texture, len(atlasSlotNames))
+	for i, name := range atlasSlotNames {
+		slots[i] = materialTextureSlot(material, name)
+	}
+	return slots
+}
+
+// stripUnused removes registered meshes, materials and textures that no
+// node references
+func stripUnused(scene *Scene) {
+	usedMeshes := make(map[*Mesh]bool)
+	usedMaterials := make(map[*PBRMaterial]bool)
+	scene.RootNode.VisitNodes(func(node *SceneNode) {
+		if node.Mesh != nil {
+			usedMeshes[node.Mesh] = true
+		}
+		if node.Material != nil {
+			usedMaterials[node.Material] = true
+		}
+	})
+	for name, mesh := range scene.Meshes {
+		if !usedMeshes[mesh] {
+			delete(scene.Meshes, name)
+		}
+	}
+	for name, material := range scene.Materials {
+		if !usedMaterials[material] {
+			delete(scene.Materials, name)
+		}
+	}
+
+	usedTextures := make(map[*AdvancedTexture]bool)
+	for material := range usedMaterials {
+		for _, slot := range material.textureSlots() {
+			if texture, ok := (*slot).(*AdvancedTexture); ok {
+				usedTextures[texture] = true
+			}
+		}
+	}
+	for name, texture := range scene.Textures {
+		if !usedTextures[texture] {
+			delete(scene.Textures, name)
+		}
+	}
+}
+
+// resizeTexture downsamples a texture so its larger side is at most
+// maxSize, regenerating its mip chain, and reports whether it changed
+func resizeTexture(texture *AdvancedTexture, maxSize int) bool {
+	if texture.Image == nil || (texture.Width <= maxSize && texture.Height <= maxSize) {
+		return false
+	}
+	scale := float64(maxSize) / float64(maxInt(texture.Width, texture.Height))
+	width := maxInt(1, int(float64(texture.Width)*scale+0.5))
+	height := maxInt(1, int(float64(texture.Height)*scale+0.5))
+
+	texture.Image = downsampleBox(toNRGBA(texture.Image), width, height)
+	texture.Width = width
+	texture.Height = height
+	texture.refreshMipmaps()
+	for _, tile := range texture.UDIMTiles {
+		resizeTexture(tile, maxSize)
+	}
+	return true
+}
+
+// atlasRegion is the placement of one material's textures in an atlas
+type atlasRegion struct {
+	material *PBRMaterial
+	width    int
+	height   int
+	x, y     int // Top left corner of the region, inside its padding
+}
+
+// atlasSceneTextures packs the textures of eligible materials into one
+// atlas per texture slot. It returns the number of materials packed and
+// the number of atlases created.
+func atlasSceneTextures(scene *Scene, maxSize, padding int) (int, int) {
+	if maxSize <= 0 {
+		maxSize = 4096
+	}
+	if padding < 0 {
+		padding = 0
+	}
+
+	// Materials per mesh and meshes per material
+	meshMaterials := make(map[*Mesh]map[*PBRMaterial]bool)
+	materialMeshes := make(map[*PBRMaterial][]*Mesh)
+	var materials []*PBRMaterial
+	for _, node := range sceneMeshNodes(scene) {
+		if node.Material == nil {
+			continue
+		}
+		if meshMaterials[node.Mesh] == nil {
+			meshMaterials[node.Mesh] = make(map[*PBRMaterial]bool)
+		}
+		if !meshMaterials[node.Mesh][node.Material] {
+			meshMaterials[node.Mesh][node.Material] = true
+			if _, ok := materialMeshes[node.Material]; !ok {
+				materials = append(materials, node.Material)
+			}
+			materialMeshes[node.Material] = append(materialMeshes[node.Material], node.Mesh)
+		}
+	}
+
+	var regions []*atlasRegion
+	for _, material := range materials {
+		if width, height, ok := atlasEligible(material, materialMeshes[material], meshMaterials); ok {
+			regions = append(regions, &atlasRegion{material: material, width: width, height: height})
+		}
+	}
+	regions = packAtlasRegions(regions, maxSize, padding)
+	if len(regions) < 2 {
+		return 0, 0
+	}
+	atlasWidth, atlasHeight := 0, 0
+	for _, r := range regions {
+		atlasWidth = maxInt(atlasWidth, r.x+r.width+padding)
+		atlasHeight = maxInt(atlasHeight, r.y+r.height+padding)
+	}
+
+	// One atlas per slot used by any packed material
+	atlases := 0
+	for slot, name := range atlasSlotNames {
+		var template *AdvancedTexture
+		for _, r := range regions {
+			if texture, ok := (*r.material.atlasSlots()[slot]).(*AdvancedTexture); ok {
+				template = texture
+				break
+			}
+		}
+		if template == nil {
+			continue
+		}
+
+		// Regions of materials without this texture get a neutral fill
+		fill := color.NRGBA{255, 255, 255, 255}
+		if name == TextureSlotNormal {
+			fill = color.NRGBA{128, 128, 255, 255} // Flat normal
+		}
+		img := image.NewNRGBA(image.Rect(0, 0, atlasWidth, atlasHeight))
+		for _, r := range regions {
+			var src image.Image
+			if texture, ok := (*r.material.atlasSlots()[slot]).(*AdvancedTexture); ok {
+				src = texture.Image
+			}
+			drawAtlasRegion(img, src, fill, r, padding)
+		}
+
+		atlas := NewAdvancedTexture(img, template.Type)
+		atlas.WrapS = WrapClamp
+		atlas.WrapT = WrapClamp
+		atlas.MinFilter = template.MinFilter
+		atlas.MagFilter = template.MagFilter
+		for _, r := range regions {
+			*r.material.atlasSlots()[slot] = atlas
+		}
+		scene.AddTexture(fmt.Sprintf("atlas_%d", slot), atlas)
+		atlases++
+	}
+
+	// Remap texture coordinates into the regions, matching the texel
+	// mapping of the samplers, which flip v
+	for _, r := range regions {
+		for _, mesh := range materialMeshes[r.material] {
+			for _, t := range mesh.Triangles {
+				for _, v := range [3]*Vertex{&t.V1, &t.V2, &t.V3} {
+					v.Texture.X = (float64(r.x) + v.Texture.X*float64(r.width-1)) / float64(atlasWidth-1)
+					v.Texture.Y = 1 - (float64(r.y)+(1-v.Texture.Y)*float64(r.height-1))/float64(atlasHeight-1)
+				}
+			}
+		}
+	}
+	return len(regions), atlases
+}
+
+// atlasEligible reports whether a material's textures can move into an
+// atlas and returns the region size it needs
+func atlasEligible(material *PBRMaterial, meshes []*Mesh, meshMaterials map[*Mesh]map[*PBRMaterial]bool) (int, int, bool) {
+	// Zones are placed in the texture coordinates atlases remap
+	if material.Zones != nil && len(material.Zones.Zones) > 0 {
+		return 0, 0, false
+	}
+	core := make(map[*Texture]bool)
+	width, height := 0, 0
+	for _, slot := range material.atlasSlots() {
+		core[slot] = true
+		if *slot == nil {
+			continue
+		}
+		texture, ok := (*slot).(*AdvancedTexture)
+		if !ok || texture.Image == nil || len(texture.UDIMTiles) > 0 || texture.UVModifier != nil || texture.Transform != Identity() {
+			return 0, 0, false
+		}
+		width = maxInt(width, texture.Width)
+		height = maxInt(height, texture.Height)
+	}
+	if width == 0 {
+		return 0, 0, false
+	}
+	for _, slot := range material.textureSlots() {
+		if !core[slot] && *slot != nil {
+			return 0, 0, false
+		}
+	}
+
+	// Remapped UVs must not wrap, and meshes must not be shared with other
+	// materials whose textures stay put
+	const eps = 1e-6
+	for _, mesh := range meshes {
+		if len(meshMaterials[mesh]) > 1 {
+			return 0, 0, false
+		}
+		for _, t := range mesh.Triangles {
+			for _, uv := range [3]Vector{t.V1.Texture, t.V2.Texture, t.V3.Texture} {
+				if uv.X < -eps || uv.X > 1+eps || uv.Y < -eps || uv.Y > 1+eps {
+					return 0, 0, false
+				}
+			}
+		}
+	}
+	return width, height, true
+}
+
+// packAtlasRegions places regions with a shelf packer, tallest first, and
+// returns the regions that fit within maxSize
+func packAtlasRegions(regions []*atlasRegion, maxSize, padding int) []*atlasRegion {
+	area := 0
+	for _, r := range regions {
+		// Regions larger than the atlas are scaled down to fit
+		limit := maxSize - 2*padding
+		if limit <= 1 {
+			return nil
+		}
+		if r.width > limit || r.height > limit {
+			scale := float64(limit) / float64(maxInt(r.width, r.height))
+			r.width = maxInt(1, int(float64(r.width)*scale))
+			r.height = maxInt(1, int(float64(r.height)*scale))
+		}
+		area += (r.width + 2*padding) * (r.height + 2*padding)
+	}
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].height > regions[j].height
+	})
+
+	// Start from the smallest power of two width that could hold the area
+	width := 1
+	for width*width < area && width < maxSize {
+		width *= 2
+	}
+	if width > maxSize {
+		width = maxSize
+	}
+
+	var packed []*atlasRegion
+	x, y, shelf := 0, 0, 0
+	for _, r := range regions {
+		w := r.width + 2*padding
+		h := r.height + 2*padding
+		if x+w > width {
+			x, y = 0, y+shelf
+			shelf = 0
+		}
+		if x+w > width || y+h > maxSize {
+			continue
+		}
+		r.x, r.y = x+padding, y+padding
+		x += w
+		if h > shelf {
+			shelf = h
+		}
+		packed = append(packed, r)
+	}
+	return packed
+}
+
+// drawAtlasRegion resamples src into a region of the atlas, or fills it
+// when src is nil, and replicates the region's edge into its padding
+func drawAtlasRegion(dst *image.NRGBA, src image.Image, fill color.NRGBA, r *atlasRegion, padding int) {
+	var nrgba *image.NRGBA
+	if src != nil {
+		nrgba = toNRGBA(src)
+	}
+	for y := -padding; y < r.height+padding; y++ {
+		v := 0.0
+		if r.height > 1 {
+			v = Clamp(float64(y)/float64(r.height-1), 0, 1)
+		}
+		for x := -padding; x < r.width+padding; x++ {
+			c := fill
+			if nrgba != nil {
+				u := 0.0
+				if r.width > 1 {
+					u = Clamp(float64(x)/float64(r.width-1), 0, 1)
+				}
+				b := nrgba.Bounds()
+				c = sampleNRGBABilinear(nrgba, b.Dx(), b.Dy(), u, v).NRGBA()
+			}
+			dst.SetNRGBA(r.x+x, r.y+y, c)
+		}
+	}
+}
+
+// weldAttributes merges the normals, texture coordinates and tangents of
+// the vertices at each position that are within epsilon of each other
+// into the first of them, so vertices that only differ by rounding become
+// equal
+func weldAttributes(mesh *Mesh, epsilon float64) {
+	type variants struct {
+		normals, textures []Vector
+		tangents          []VectorW
+	}
+	snap := func(values []Vector, v Vector) ([]Vector, Vector) {
+		for _, u := range values {
+			if v.DistanceSq(u) <= epsilon*epsilon {
+				return values, u
+			}
+		}
+		return append(values, v), v
+	}
+	positions := make(map[Vector]*variants)
+	for _, t := range mesh.Triangles {
+		for _, v := range [3]*Vertex{&t.V1, &t.V2, &t.V3} {
+			p := positions[v.Position]
+			if p == nil {
+				p = &variants{}
+				positions[v.Position] = p
+			}
+			p.normals, v.Normal = snap(p.normals, v.Normal)
+			p.textures, v.Texture = snap(p.textures, v.Texture)
+
+			found := false
+			for _, u := range p.tangents {
+				d := Vector{v.Tangent.X - u.X, v.Tangent.Y - u.Y, v.Tangent.Z - u.Z}
+				if v.Tangent.W == u.W && d.LengthSquared() <= epsilon*epsilon {
+					v.Tangent, found = u, true
+					break
+				}
+			}
+			if !found {
+				p.tangents = append(p.tangents, v.Tangent)
+			}
+		}
+	}
+}
+
+// LODMesh returns the mesh to draw for a node placed by model: the last of
+// its LODs whose LODCoverage is above the screen coverage of the node, or
+// Mesh. The coverage is the larger of the fractions of the viewport width
+// and height the bounds of Mesh span when projected by cameraMatrix. Nodes
+// crossing the camera plane, skinned and morphed nodes use Mesh.
+func (node *SceneNode) LODMesh(model, cameraMatrix Matrix) *Mesh {
+	if len(node.LODs) == 0 || len(node.LODCoverage) == 0 || node.Skin != nil || node.MorphTargets != nil {
+		return node.Mesh
+	}
+	box := node.Mesh.BoundingBox()
+	matrix := cameraMatrix.Mul(model)
+	min := Vector{math.Inf(1), math.Inf(1), 0}
+	max := Vector{math.Inf(-1), math.Inf(-1), 0}
+	for i := 0; i < 8; i++ {
+		corner := box.Min
+		if i&1 != 0 {
+			corner.X = box.Max.X
+		}
+		if i&2 != 0 {
+			corner.Y = box.Max.Y
+		}
+		if i&4 != 0 {
+			corner.Z = box.Max.Z
+		}
+		clip := matrix.MulPositionW(corner)
+		if clip.W <= 0 {
+			return node.Mesh
+		}
+		ndc := Vector{clip.X / clip.W, clip.Y / clip.W, 0}
+		min, max = min.Min(ndc), max.Max(ndc)
+	}
+	// Normalized device coordinates span 2 across the viewport
+	coverage := math.Max(max.X-min.X, max.Y-min.Y) / 2
+
+	mesh := node.Mesh
+	for i, lod := range node.LODs {
+		if i < len(node.LODCoverage) && coverage < node.LODCoverage[i] {
+			mesh = lod
+		}
+	}
+	return mesh
+}
+
+// distinctVertices counts the distinct position, normal and texture
+// coordinate combinations of a mesh
+func distinctVertices(mesh *Mesh) int {
+	type key struct{ position, normal, texture Vector }
+	seen := make(map[key]bool)
+	for _, t := range mesh.Triangles {
+		for _, v := range [3]*Vertex{&t.V1, &t.V2, &t.V3} {
+			seen[key{v.Position, v.Normal, v.Texture}] = true
+		}
+	}
+	return len(seen)
+}
+
+// simplifyClustered returns a copy of the mesh simplified by vertex
+// clustering to about ratio of its triangles. Vertices in the same grid
+// cell merge into their average position; the grid resolution is searched
+// for the finest grid that meets the triangle budget.
+func simplifyClustered(mesh *Mesh, ratio float64) *Mesh {
+	target := int(float64(len(mesh.Triangles)) * ratio)
+	if ratio >= 1 || len(mesh.Triangles) == 0 {
+		return mesh.Copy()
+	}
+	if target < 1 {
+		target = 1
+	}
+
+	box := mesh.BoundingBox()
+	size := box.Size()
+	extent := math.Max(size.X, math.Max(size.Y, size.Z))
+	if extent <= 0 {
+		return mesh.Copy()
+	}
+
+	lo, hi := 1, 1024
+	best := clusterMesh(mesh, box, extent, lo)
+	for lo <= hi {
+		resolution := (lo + hi) / 2
+		result := clusterMesh(mesh, box, extent, resolution)
+		if len(result.Triangles) <= target {
+			best = result
+			lo = resolution + 1
+		} else {
+			hi = resolution - 1
+		}
+	}
+	return best
+}
+
+// clusterMesh merges the vertices of each cell of a grid with resolution
+// cells along the largest extent of box
+func clusterMesh(mesh *Mesh, box Box, extent float64, resolution int) *Mesh {
+	type cell = clusterCell
+	cellSize := extent / float64(resolution)
+	cellOf := func(p Vector) cell {
+		d := p.Sub(box.Min).DivScalar(cellSize)
+		return cell{
+			ClampInt(int(d.X), 0, resolution-1),
+			ClampInt(int(d.Y), 0, resolution-1),
+			ClampInt(int(d.Z), 0, resolution-1),
+		}
+	}
+
+	// Average position per cell
+	sums := make(map[cell]Vector)
+	counts := make(map[cell]float64)
+	for _, t := range mesh.Triangles {
+		for _, p := range [3]Vector{t.V1.Position, t.V2.Position, t.V3.Position} {
+			c := cellOf(p)
+			sums[c] = sums[c].Add(p)
+			counts[c]++
+		}
+	}
+
+	type face struct{ a, b, c cell }
+	seen := make(map[face]bool)
+	var triangles []*Triangle
+	for _, t := range mesh.Triangles {
+		c1, c2, c3 := cellOf(t.V1.Position), cellOf(t.V2.Position), cellOf(t.V3.Position)
+		if c1 == c2 || c2 == c3 || c3 == c1 {
+			continue
+		}
+		// Rotate so the key is independent of the starting vertex but
+		// keeps the winding
+		f := face{c1, c2, c3}
+		if cellLess(c2, f.a) && cellLess(c2, c3) {
+			f = face{c2, c3, c1}
+		} else if cellLess(c3, f.a) && cellLess(c3, c2) {
+			f = face{c3, c1, c2}
+		}
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+
+		n := *t
+		n.V1.Position = sums[c1].DivScalar(counts[c1])
+		n.V2.Position = sums[c2].DivScalar(counts[c2])
+		n.V3.Position = sums[c3].DivScalar(counts[c3])
+		triangles = append(triangles, &n)
+	}
+	return NewTriangleMesh(triangles)
+}
+
+// clusterCell is a grid cell of the clustering simplifier
+type clusterCell struct{ x, y, z int }
+
+func cellLess(a, b clusterCell) bool {
+	if a.x != b.x {
+		return a.x < b.x
+	}
+	if a.y != b.y {
+		return a.y < b.y
+	}
+	return a.z < b.z
+}
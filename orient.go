@@ -0,0 +1,238 @@
+package fauxgl
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Orientation is the up and front direction of a scene guessed by
+// DetectOrientation
+type Orientation struct {
+	Up    Vector // Detected up direction of the model, one of the six axes
+	Front Vector // Detected front direction of the model, perpendicular to Up
+	// Rotation turns Up to +Y and Front to +Z, the glTF convention
+	Rotation Matrix
+	// Confidence from 0 to 1 of how clearly the evidence favors Up over the
+	// next best axis
+	Confidence float64
+	Reasons    []string // Evidence behind the guess, for display
+}
+
+// IsIdentity reports whether the scene already appears correctly oriented
+func (o *Orientation) IsIdentity() bool {
+	return o.Rotation == Identity()
+}
+
+// Generators known to convert their scenes to the glTF Y-up convention,
+// matched as lower case substrings of the asset generator
+var yUpGenerators = []string{
+	"khronos gltf blender", "three.gltfexporter", "fbx2gltf", "babylon",
+	"sketchfab", "fauxgl", "gltf-transform", "obj2gltf", "maya2gltf",
+}
+
+// orientationAxes are the candidate up and front directions
+var orientationAxes = []Vector{
+	{0, 1, 0}, {0, 0, 1}, {1, 0, 0}, {0, -1, 0}, {0, 0, -1}, {-1, 0, 0},
+}
+
+// DetectOrientation guesses which way is up and front in a scene from its
+// world space geometry and metadata:
+//
+//   - normal statistics: flat faces are mostly floors, tops and bases, so
+//     the up axis collects the most area of faces aligned with it, and open
+//     surfaces such as terrain face up rather than down
+//   - bounding box aspect: the up extent tends to be the odd one out, the
+//     longest or shortest, rather than the middle one
+//   - mass distribution: surface area usually sits lower, around bases and
+//     feet, than the middle of the bounding box
+//   - glTF metadata: the format is Y-up, a prior strengthened when the
+//     generator is an exporter known to convert to it
+//
+// The front is the side with the most finely tessellated faces, where
+// models carry their detail, unless no side clearly stands out; then it is
+// the direction the smallest rotation of Up to +Y brings to +Z.
+func DetectOrientation(scene *Scene) *Orientation {
+	scene.UpdateTransforms()
+	bounds := scene.GetBounds()
+	o := &Orientation{Up: Vector{0, 1, 0}, Front: Vector{0, 0, 1}, Rotation: Identity()}
+	if bounds == EmptyBox {
+		o.Reasons = append(o.Reasons, "empty scene")
+		return o
+	}
+
+	// Area facing each axis direction within about 18 degrees, area
+	// weighted centroid and triangle count facing each direction
+	var aligned, facing [6]float64
+	var counts [6]int
+	var centroid Vector
+	total := 0.0
+	for _, node := range scene.RootNode.GetRenderableNodes() {
+		if node.Material != nil && node.Material.ShadowCatcher {
+			continue
+		}
+		for _, transform := range node.InstanceTransforms() {
+			for _, t := range node.Mesh.Triangles {
+				p1 := transform.MulPosition(t.V1.Position)
+				p2 := transform.MulPosition(t.V2.Position)
+				p3 := transform.MulPosition(t.V3.Position)
+				n := p2.Sub(p1).Cross(p3.Sub(p1))
+				area := n.Length() / 2
+				if area == 0 {
+					continue
+				}
+				n = n.DivScalar(2 * area)
+				for i, axis := range orientationAxes {
+					d := n.Dot(axis)
+					if d > 0.95 {
+						aligned[i] += area
+					}
+					if d > 0 {
+						facing[i] += area * d
+					}
+					if d > 0.5 {
+						counts[i]++
+					}
+				}
+				centroid = centroid.Add(p1.Add(p2).Add(p3).MulScalar(area / 3))
+				total += area
+			}
+		}
+	}
+	if total == 0 {
+		o.Reasons = append(o.Reasons, "no surface area")
+		return o
+	}
+	centroid = centroid.DivScalar(total)
+	center := bounds.Center()
+	size := bounds.Size()
+	extents := [3]float64{size.Y, size.Z, size.X}
+
+	generator := strings.ToLower(scene.Generator)
+	converted := false
+	for _, g := range yUpGenerators {
+		if g != "" && strings.Contains(generator, g) {
+			converted = true
+		}
+	}
+
+	var scores [6]float64
+	for i, axis := range orientationAxes {
+		j := (i + 3) % 6
+		k := i % 3
+		// Flat faces along the axis, regardless of sign
+		scores[i] += 2 * (aligned[i] + aligned[j]) / total
+		// Open surfaces facing this way rather than the opposite
+		scores[i] += (facing[i] - facing[j]) / total
+		// Bounding box aspect: distance from the geometric mean of the
+		// other two extents, in octaves
+		other := math.Sqrt(extents[(k+1)%3] * extents[(k+2)%3])
+		if extents[k] > 0 && other > 0 {
+			scores[i] += 0.25 * math.Min(math.Abs(math.Log2(extents[k]/other)), 2)
+		}
+		// Mass below the middle of the bounding box
+		if extents[k] > 0 {
+			scores[i] += 2 * center.Sub(centroid).Dot(axis) / extents[k]
+		}
+	}
+	if converted {
+		scores[0] += 1
+		o.Reasons = append(o.Reasons, fmt.Sprintf("generator %q exports Y-up", scene.Generator))
+	} else {
+		scores[0] += 0.25
+	}
+
+	best, second := 0, -1
+	for i := range scores {
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+	for i := range scores {
+		if i != best && (second < 0 || scores[i] > scores[second]) {
+			second = i
+		}
+	}
+	o.Up = orientationAxes[best]
+	o.Confidence = Clamp((scores[best]-scores[second])/math.Max(math.Abs(scores[best]), 1e-9), 0, 1)
+	o.Reasons = append(o.Reasons,
+		fmt.Sprintf("up %s scores %.2f, next %s %.2f", axisName(o.Up), scores[best], axisName(orientationAxes[second]), scores[second]),
+		fmt.Sprintf("%.0f%% of the area is flat along the up axis", 100*(aligned[best]+aligned[(best+3)%6])/total))
+
+	// The front from the smallest rotation, unless one side is clearly more
+	// detailed
+	o.Front = snapAxis(RotateTo(Vector{0, 1, 0}, o.Up).MulDirection(Vector{0, 0, 1}))
+	front, detail := -1, 0
+	for i := range orientationAxes {
+		if i%3 != best%3 && counts[i] > detail {
+			front, detail = i, counts[i]
+		}
+	}
+	if front >= 0 {
+		runnerUp := 0
+		for i := range orientationAxes {
+			if i%3 != best%3 && i != front && counts[i] > runnerUp {
+				runnerUp = counts[i]
+			}
+		}
+		if float64(detail) > 1.25*float64(runnerUp) {
+			o.Front = orientationAxes[front]
+			o.Reasons = append(o.Reasons, fmt.Sprintf("front %s has the most detail, %d faces", axisName(o.Front), detail))
+		}
+	}
+
+	right := o.Up.Cross(o.Front)
+	o.Rotation = Matrix{
+		right.X, right.Y, right.Z, 0,
+		o.Up.X, o.Up.Y, o.Up.Z, 0,
+		o.Front.X, o.Front.Y, o.Front.Z, 0,
+		0, 0, 0, 1,
+	}
+	return o
+}
+
+// AutoOrient detects the orientation of a scene and, when it is not
+// already upright and the confidence reaches minConfidence, rotates the
+// root node to bring it to Y-up, Z-front, about the center of the bottom
+// of its bounds so that it keeps standing in place. Cameras and lights are
+// not moved. It returns the detected orientation either way.
+func AutoOrient(scene *Scene, minConfidence float64) *Orientation {
+	o := DetectOrientation(scene)
+	if o.IsIdentity() || o.Confidence < minConfidence {
+		return o
+	}
+	bounds := scene.GetBounds()
+	pivot := bounds.Center().Sub(o.Up.MulScalar(math.Abs(bounds.Size().Dot(o.Up)) / 2))
+	rotation := Translate(pivot).Mul(o.Rotation).Mul(Translate(pivot.Negate()))
+	scene.RootNode.SetTransform(rotation.Mul(scene.RootNode.LocalTransform))
+	scene.UpdateTransforms()
+	return o
+}
+
+// snapAxis returns the axis direction closest to v
+func snapAxis(v Vector) Vector {
+	best := orientationAxes[0]
+	for _, axis := range orientationAxes {
+		if axis.Dot(v) > best.Dot(v) {
+			best = axis
+		}
+	}
+	return best
+}
+
+// axisName formats an axis direction as +X, -Y and so on
+func axisName(v Vector) string {
+	switch {
+	case v.X > 0.5:
+		return "+X"
+	case v.X < -0.5:
+		return "-X"
+	case v.Y > 0.5:
+		return "+Y"
+	case v.Y < -0.5:
+		return "-Y"
+	case v.Z > 0.5:
+		return "+Z"
+	}
+	return "-Z"
+}
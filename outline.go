@@ -0,0 +1,161 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+)
+
+// OutlineEffect draws ink lines over a frame along the silhouettes and
+// creases found in the buffers of Context, rendered through Camera, for
+// technical illustrations and toon renders (see ToonShader):
+//
+//   - Silhouettes are jumps in depth, where the inverse depth of a pixel
+//     rises above that of its neighbors by more than DepthThreshold of its
+//     own; planes seen at grazing angles don't jump, as their inverse depth
+//     is linear across the screen. Lines are drawn on the nearer side.
+//   - Creases are neighbors whose normals differ by more than CreaseAngle,
+//     from the NormalBuffer of the context when enabled, otherwise from the
+//     depth buffer.
+//
+// Lines of supersampled contexts are antialiased by the resolve.
+type OutlineEffect struct {
+	Color          Color   // Line color, its alpha is the line opacity
+	Width          float64 // Line width in output pixels
+	DepthThreshold float64
+	CreaseAngle    float64 // Radians, 0 draws silhouettes only
+	Context        *Context
+	Camera         *Camera
+}
+
+// NewOutlineEffect creates one pixel black lines at silhouettes and at
+// creases sharper than 40 degrees
+func NewOutlineEffect(context *Context, camera *Camera) *OutlineEffect {
+	return &OutlineEffect{
+		Color:          Black,
+		Width:          1,
+		DepthThreshold: 0.1,
+		CreaseAngle:    Radians(40),
+		Context:        context,
+		Camera:         camera,
+	}
+}
+
+// Apply draws the lines of Context over the input, scaled to it when it is
+// a resolved supersampled frame
+func (e *OutlineEffect) Apply(input *image.NRGBA) *image.NRGBA {
+	if e.Context == nil || e.Camera == nil {
+		return input
+	}
+	dc := e.Context
+	bounds := input.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	coverage := e.Lines(dc, e.Camera, float64(dc.Width)/float64(width))
+
+	output := image.NewNRGBA(bounds)
+	line := e.Color.Opaque()
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			by0, by1 := y*dc.Height/height, maxInt((y+1)*dc.Height/height, y*dc.Height/height+1)
+			for x := 0; x < width; x++ {
+				bx0, bx1 := x*dc.Width/width, maxInt((x+1)*dc.Width/width, x*dc.Width/width+1)
+				// Average the coverage of the buffer pixels of the output pixel
+				sum, n := 0.0, 0
+				for by := by0; by < by1 && by < dc.Height; by++ {
+					for bx := bx0; bx < bx1 && bx < dc.Width; bx++ {
+						sum += coverage[by*dc.Width+bx]
+						n++
+					}
+				}
+				c := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
+				if n == 0 || sum == 0 {
+					output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, c)
+					continue
+				}
+				alpha := sum / float64(n) * e.Color.A
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, line.Alpha(alpha).Over(MakeColor(c)).NRGBA())
+			}
+		}
+	})
+	return output
+}
+
+// Lines returns the coverage, from 0 to 1, of the lines of every pixel of a
+// context rendered through a camera, with lines Width times scale pixels
+// wide
+func (e *OutlineEffect) Lines(dc *Context, camera *Camera, scale float64) []float64 {
+	w, h := dc.Width, dc.Height
+	depth := dc.CameraDepth(camera)
+	inverse := make([]float64, w*h)
+	for i, d := range depth.Depth {
+		if !math.IsInf(d, 1) && d > 0 {
+			inverse[i] = 1 / d
+		}
+	}
+	var normals []Vector
+	if e.CreaseAngle > 0 {
+		normals = dc.NormalBuffer
+		if len(normals) != w*h {
+			normals = depthNormals(viewPositions(dc, camera.GetProjectionMatrix(), depth), depth, w, h)
+		}
+	}
+	creaseCos := math.Cos(e.CreaseAngle)
+
+	// Edge pixels are marked 1, like the IDs of selectionDistance
+	edges := make([]int, w*h)
+	parallelRows(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				wi := inverse[i]
+				if wi == 0 {
+					continue
+				}
+				// Neighbors beyond the frame mirror the pixel
+				at := func(x, y int) float64 {
+					if x < 0 || y < 0 || x >= w || y >= h {
+						return wi
+					}
+					return inverse[y*w+x]
+				}
+				horizontal := at(x-1, y) + at(x+1, y) - 2*wi
+				vertical := at(x, y-1) + at(x, y+1) - 2*wi
+				if math.Min(horizontal, vertical) < -e.DepthThreshold*wi {
+					edges[i] = 1
+					continue
+				}
+				if normals == nil || normals[i] == (Vector{}) {
+					continue
+				}
+				for _, j := range [4]int{i - 1, i + 1, i - w, i + w} {
+					if (j == i-1 && x == 0) || (j == i+1 && x == w-1) || j < 0 || j >= w*h {
+						continue
+					}
+					// The nearer pixel of a crease draws it, the first
+					// when level
+					wj := inverse[j]
+					if wj == 0 || normals[j] == (Vector{}) || wj > wi || (wj == wi && j < i) {
+						continue
+					}
+					if normals[i].Dot(normals[j]) < creaseCos {
+						edges[i] = 1
+						break
+					}
+				}
+			}
+		}
+	})
+
+	// Distances are between pixel centers, and edge pixels are the middle
+	// of their lines
+	half := math.Max(e.Width*scale, 1) / 2
+	distance := selectionDistance(edges, w, h, half+1)
+	coverage := make([]float64, w*h)
+	for i, d := range distance {
+		if edges[i] != 0 {
+			coverage[i] = 1
+		} else {
+			coverage[i] = Clamp(half+0.5-d, 0, 1)
+		}
+	}
+	return coverage
+}
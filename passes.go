@@ -0,0 +1,147 @@
+package fauxgl
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// AuxiliaryPasses are the auxiliary images of a render for compositing:
+// the depth, world normals and object IDs of its pixels. They are rendered
+// without antialiasing or shading, so every pixel holds the values of the
+// one surface nearest to the camera. Alpha masked texels aren't cut out.
+// Export the alpha of the beauty frame with AlphaImage.
+type AuxiliaryPasses struct {
+	Width, Height int
+	// Depth is the metric depth along the camera axis, +Inf where nothing
+	// was drawn
+	Depth *DepthMap
+	// Normals are the world space unit normals, zero where nothing was
+	// drawn
+	Normals []Vector
+	// IDs are 0 where nothing was drawn, otherwise one plus the index in
+	// Nodes of the node drawn
+	IDs   []int
+	Nodes []*SceneNode
+}
+
+// RenderAuxiliaryPasses renders the auxiliary passes of a scene from its
+// active camera at a size, with the vertex modifiers of the nodes at Time.
+// It returns nil without an active camera.
+func (renderer *SceneRenderer) RenderAuxiliaryPasses(scene *Scene, width, height int) *AuxiliaryPasses {
+	camera := scene.ActiveCamera
+	if camera == nil {
+		return nil
+	}
+	scene.UpdateTransforms()
+	scene.ApplyMorphTargetsToMeshes()
+	scene.UpdateSkinnedMeshes()
+
+	dc := NewContext(width, height)
+	dc.SetDepthMode(camera.DepthMode)
+	dc.EnableNormalBuffer()
+	dc.AlphaBlend = false
+	cameraMatrix := camera.GetProjectionMatrix().Mul(camera.GetViewMatrix())
+	passes := &AuxiliaryPasses{Width: width, Height: height}
+	for _, node := range scene.RootNode.GetRenderableNodes() {
+		passes.Nodes = append(passes.Nodes, node)
+		// IDs are stored in the red, green and blue bytes
+		id := len(passes.Nodes)
+		c := Color{
+			(float64(id&0xff) + 0.5) / 255,
+			(float64(id>>8&0xff) + 0.5) / 255,
+			(float64(id>>16&0xff) + 0.5) / 255,
+			1,
+		}
+		if node.Material != nil && node.Material.DoubleSided {
+			dc.Cull = CullNone
+		} else {
+			dc.Cull = CullBack
+		}
+		for _, transform := range node.InstanceTransforms() {
+			dc.NormalMatrix = transform.Inverse().Transpose()
+			dc.Shader = node.modify(NewSolidColorShader(cameraMatrix.Mul(transform), c), renderer.Time)
+			dc.DrawMesh(node.Mesh)
+		}
+	}
+
+	passes.Depth = dc.CameraDepth(camera)
+	passes.Normals = make([]Vector, width*height)
+	passes.IDs = make([]int, width*height)
+	for y := 0; y < height; y++ {
+		row := dc.ColorBuffer.Pix[dc.ColorBuffer.PixOffset(0, y):]
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			p := row[x*4:]
+			if p[3] == 0 {
+				continue
+			}
+			passes.IDs[i] = int(p[0]) | int(p[1])<<8 | int(p[2])<<16
+			passes.Normals[i] = dc.NormalBuffer[i].Normalize()
+		}
+	}
+	return passes
+}
+
+// DepthImage returns the depth as an opaque HDR image of the depth in red,
+// green and blue, for saving with SaveEXR. Empty pixels hold far.
+func (passes *AuxiliaryPasses) DepthImage(far float64) *HDRImage {
+	im := NewHDRImage(image.Rect(0, 0, passes.Width, passes.Height))
+	for i, d := range passes.Depth.Depth {
+		if math.IsInf(d, 1) {
+			d = far
+		}
+		im.SetColor(i%passes.Width, i/passes.Width, Color{d, d, d, 1})
+	}
+	return im
+}
+
+// NormalImage returns the normals as a 16-bit image, each axis mapped
+// from -1 to 1 onto 0 to 1. Empty pixels are transparent.
+func (passes *AuxiliaryPasses) NormalImage() *image.NRGBA64 {
+	im := image.NewNRGBA64(image.Rect(0, 0, passes.Width, passes.Height))
+	for i, n := range passes.Normals {
+		if passes.IDs[i] == 0 {
+			continue
+		}
+		im.SetNRGBA64(i%passes.Width, i/passes.Width, color.NRGBA64{
+			R: unit16(n.X*0.5 + 0.5),
+			G: unit16(n.Y*0.5 + 0.5),
+			B: unit16(n.Z*0.5 + 0.5),
+			A: 0xffff,
+		})
+	}
+	return im
+}
+
+// IDImage returns the object IDs as a 16-bit grayscale image of the IDs
+// themselves, for selecting objects by value when compositing. IDs beyond
+// 65535 are clamped.
+func (passes *AuxiliaryPasses) IDImage() *image.Gray16 {
+	im := image.NewGray16(image.Rect(0, 0, passes.Width, passes.Height))
+	for i, id := range passes.IDs {
+		im.SetGray16(i%passes.Width, i/passes.Width, color.Gray16{uint16(ClampInt(id, 0, 0xffff))})
+	}
+	return im
+}
+
+// Save saves the passes next to each other for compositing: the depth as
+// base_depth.exr, empty pixels at the farthest depth drawn, the normals
+// and IDs as base_normal.png and base_id.png 16-bit PNG files, and the
+// alpha of a frame as base_alpha.png when it isn't nil
+func (passes *AuxiliaryPasses) Save(base string, frame image.Image) error {
+	_, far := passes.Depth.Range()
+	if err := SaveEXR(base+"_depth.exr", passes.DepthImage(far)); err != nil {
+		return err
+	}
+	if err := SavePNG16(base+"_normal.png", passes.NormalImage()); err != nil {
+		return err
+	}
+	if err := SavePNG16(base+"_id.png", passes.IDImage()); err != nil {
+		return err
+	}
+	if frame != nil {
+		return SavePNG16(base+"_alpha.png", AlphaImage(frame))
+	}
+	return nil
+}
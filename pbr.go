@@ -44,9 +44,15 @@ type PBRMaterial struct {
 	EmissiveFactor  Color
 	EmissiveTexture Texture
 
+	// Displacement mapping (applied to geometry by Mesh.Displace)
+	HeightTexture  Texture
+	HeightScale    float64 // Displacement distance for a height of 1
+	HeightMidpoint float64 // Height value that leaves the surface in place
+
 	// Extended material properties (GLTF Extensions)
-	// KHR_materials_emissive_strength
-	EmissiveStrength float64
+	// KHR_materials_emissive_strength, multiplies EmissiveFactor; nil
+	// means 1, so an explicit 0 turns emission off
+	EmissiveStrength *float64
 
 	// KHR_materials_ior
 	IOR float64 // Index of Refraction
@@ -95,11 +101,34 @@ type PBRMaterial struct {
 	ClearcoatRoughnessTexture Texture
 	ClearcoatNormalTexture    Texture
 
+	// Subsurface scattering (approximate, wrap diffuse + translucency)
+	SubsurfaceFactor           float64 // 0 disables scattering, 1 fully replaces Lambert diffuse
+	SubsurfaceColor            Color   // Per-channel scatter distance, e.g. reddish for skin
+	SubsurfaceRadius           float64 // Mean free path relative to the thickness map range
+	SubsurfaceThickness        float64 // Thickness used when no thickness texture is set
+	SubsurfaceThicknessTexture Texture // Thickness map (G channel), e.g. from BakeThicknessMap
+
+	// Swizzles rearrange the channels of the textures before they are read
+	Swizzles TextureSwizzles
+
+	// Blend, when set, samples the material as a mix of two others and
+	// replaces its own factors and textures, see NewBlendedMaterial
+	Blend *BlendedMaterial
+
+	// Zones override the base color, metallic and roughness of regions of
+	// the texture space, see MaterialZones
+	Zones *MaterialZones
+
 	// Additional properties
 	AlphaCutoff float64
 	AlphaMode   AlphaMode
 	DoubleSided bool
 	Workflow    PBRWorkflow
+
+	// ShadowCatcher materials only show the shadows and occlusion they
+	// receive: black, with the shadowed fraction times the base color alpha
+	// as alpha. See Scene.AddShadowCatcher.
+	ShadowCatcher bool
 }
 
 // AlphaMode represents how alpha blending should be handled
@@ -123,9 +152,9 @@ func NewPBRMaterial() *PBRMaterial {
 		NormalScale:       1.0,
 		OcclusionStrength: 1.0,
 		EmissiveFactor:    Color{0, 0, 0, 1},
+		HeightScale:       1.0,
 
 		// Extended properties defaults
-		EmissiveStrength:    1.0,               // KHR_materials_emissive_strength
 		IOR:                 1.5,               // KHR_materials_ior (typical for glass/plastic)
 		SpecularColorFactor: Color{1, 1, 1, 1}, // KHR_materials_specular
 		TransmissionFactor:  0.0,               // KHR_materials_transmission (opaque by default)
@@ -154,6 +183,12 @@ func NewPBRMaterial() *PBRMaterial {
 		ClearcoatFactor:          0.0, // No clearcoat by default
 		ClearcoatRoughnessFactor: 0.0,
 
+		// Subsurface defaults
+		SubsurfaceFactor:    0.0, // No scattering by default
+		SubsurfaceColor:     Color{1, 1, 1, 1},
+		SubsurfaceRadius:    1.0,
+		SubsurfaceThickness: 1.0,
+
 		AlphaCutoff: 0.5,
 		AlphaMode:   AlphaOpaque,
 		DoubleSided: false,
@@ -161,14 +196,52 @@ func NewPBRMaterial() *PBRMaterial {
 	}
 }
 
+// normalMapped reports whether sampling the material perturbs the normal
+func (m *PBRMaterial) normalMapped() bool {
+	if m == nil {
+		return false
+	}
+	if m.Blend != nil {
+		return m.Blend.normalMapped()
+	}
+	return m.NormalTexture != nil
+}
+
+// emissiveStrength returns the emissive strength, 1 when unset
+func (m *PBRMaterial) emissiveStrength() float64 {
+	if m.EmissiveStrength == nil {
+		return 1
+	}
+	return *m.EmissiveStrength
+}
+
 // Sample samples the material at given texture coordinates
 func (m *PBRMaterial) Sample(u, v float64) *SampledMaterial {
+	return m.SampleGrad(u, v, Vector{}, Vector{})
+}
+
+// SampleGrad samples the material using the screen space derivatives of the
+// texture coordinates so mipmapped textures are filtered by their footprint
+func (m *PBRMaterial) SampleGrad(u, v float64, dx, dy Vector) *SampledMaterial {
+	return m.sample(u, v, dx, dy, White)
+}
+
+// sample samples the material like SampleGrad, with the interpolated
+// vertex color of the fragment for the mask of a blended material
+func (m *PBRMaterial) sample(u, v float64, dx, dy Vector, vertex Color) *SampledMaterial {
+	if m.Blend != nil {
+		result := m.Blend.sample(u, v, dx, dy, vertex)
+		if m.Zones != nil {
+			m.Zones.apply(result, u, v, dx, dy)
+		}
+		return result
+	}
 	result := &SampledMaterial{}
 
 	// Sample base color
 	result.BaseColor = m.BaseColorFactor
 	if m.BaseColorTexture != nil {
-		textureColor := m.BaseColorTexture.BilinearSample(u, v)
+		textureColor := m.Swizzles.BaseColor.Apply(SampleTextureGrad(m.BaseColorTexture, u, v, dx, dy))
 		result.BaseColor = result.BaseColor.Mul(textureColor)
 	}
 
@@ -176,15 +249,20 @@ func (m *PBRMaterial) Sample(u, v float64) *SampledMaterial {
 	result.Metallic = m.MetallicFactor
 	result.Roughness = m.RoughnessFactor
 	if m.MetallicRoughnessTexture != nil {
-		mr := m.MetallicRoughnessTexture.BilinearSample(u, v)
+		mr := m.Swizzles.MetallicRoughness.Apply(SampleTextureGrad(m.MetallicRoughnessTexture, u, v, dx, dy))
 		result.Metallic *= mr.B  // Blue channel for metallic
 		result.Roughness *= mr.G // Green channel for roughness
 	}
 
+	// Recolor the material zones
+	if m.Zones != nil {
+		m.Zones.apply(result, u, v, dx, dy)
+	}
+
 	// Sample normal
 	result.Normal = Vector{0, 0, 1} // Default normal in tangent space
 	if m.NormalTexture != nil {
-		normalColor := m.NormalTexture.BilinearSample(u, v)
+		normalColor := m.Swizzles.Normal.Apply(SampleTextureGrad(m.NormalTexture, u, v, dx, dy))
 		// Convert from [0,1] to [-1,1] range
 		result.Normal = Vector{
 			(normalColor.R*2.0 - 1.0) * m.NormalScale,
@@ -196,39 +274,45 @@ func (m *PBRMaterial) Sample(u, v float64) *SampledMaterial {
 	// Sample occlusion
 	result.Occlusion = 1.0
 	if m.OcclusionTexture != nil {
-		occlusionColor := m.OcclusionTexture.BilinearSample(u, v)
+		occlusionColor := m.Swizzles.Occlusion.Apply(SampleTextureGrad(m.OcclusionTexture, u, v, dx, dy))
 		result.Occlusion = 1.0 - (1.0-occlusionColor.R)*m.OcclusionStrength
 	}
 
 	// Sample emissive
 	result.Emissive = m.EmissiveFactor
 	if m.EmissiveTexture != nil {
-		emissiveColor := m.EmissiveTexture.BilinearSample(u, v)
+		emissiveColor := m.Swizzles.Emissive.Apply(SampleTextureGrad(m.EmissiveTexture, u, v, dx, dy))
 		result.Emissive = result.Emissive.Mul(emissiveColor)
 	}
 
 	// Sample extended properties
-	result.EmissiveStrength = m.EmissiveStrength
+	result.EmissiveStrength = m.emissiveStrength()
 	result.IOR = m.IOR
 
 	// Sample specular color (KHR_materials_specular)
 	result.SpecularColor = m.SpecularColorFactor
 	if m.SpecularColorTexture != nil {
-		specularColor := m.SpecularColorTexture.BilinearSample(u, v)
+		specularColor := m.Swizzles.SpecularColor.Apply(SampleTextureGrad(m.SpecularColorTexture, u, v, dx, dy))
 		result.SpecularColor = result.SpecularColor.Mul(specularColor)
 	}
+	if m.SpecularTexture != nil {
+		// The specular strength is in the alpha channel
+		s := SampleTextureGrad(m.SpecularTexture, u, v, dx, dy).A
+		c := result.SpecularColor
+		result.SpecularColor = Color{c.R * s, c.G * s, c.B * s, c.A}
+	}
 
 	// Sample transmission (KHR_materials_transmission)
 	result.Transmission = m.TransmissionFactor
 	if m.TransmissionTexture != nil {
-		transmissionColor := m.TransmissionTexture.BilinearSample(u, v)
+		transmissionColor := m.Swizzles.Transmission.Apply(SampleTextureGrad(m.TransmissionTexture, u, v, dx, dy))
 		result.Transmission *= transmissionColor.R // Red channel for transmission
 	}
 
 	// Sample thickness (KHR_materials_volume)
 	result.Thickness = m.ThicknessFactor
 	if m.ThicknessTexture != nil {
-		thicknessColor := m.ThicknessTexture.BilinearSample(u, v)
+		thicknessColor := m.Swizzles.Thickness.Apply(SampleTextureGrad(m.ThicknessTexture, u, v, dx, dy))
 		result.Thickness *= thicknessColor.G // Green channel for thickness
 	}
 	result.AttenuationColor = m.AttenuationColor
@@ -238,7 +322,7 @@ func (m *PBRMaterial) Sample(u, v float64) *SampledMaterial {
 	result.AnisotropyStrength = m.AnisotropyStrength
 	result.AnisotropyRotation = m.AnisotropyRotation
 	if m.AnisotropyTexture != nil {
-		anisotropyColor := m.AnisotropyTexture.BilinearSample(u, v)
+		anisotropyColor := m.Swizzles.Anisotropy.Apply(SampleTextureGrad(m.AnisotropyTexture, u, v, dx, dy))
 		result.AnisotropyStrength *= anisotropyColor.R
 		result.AnisotropyRotation += (anisotropyColor.G*2.0 - 1.0) * math.Pi
 	}
@@ -246,19 +330,19 @@ func (m *PBRMaterial) Sample(u, v float64) *SampledMaterial {
 	// Sample sheen (KHR_materials_sheen)
 	result.SheenColor = m.SheenColorFactor
 	if m.SheenColorTexture != nil {
-		sheenColor := m.SheenColorTexture.BilinearSample(u, v)
+		sheenColor := m.Swizzles.SheenColor.Apply(SampleTextureGrad(m.SheenColorTexture, u, v, dx, dy))
 		result.SheenColor = result.SheenColor.Mul(sheenColor)
 	}
 	result.SheenRoughness = m.SheenRoughnessFactor
 	if m.SheenRoughnessTexture != nil {
-		sheenRoughnessColor := m.SheenRoughnessTexture.BilinearSample(u, v)
+		sheenRoughnessColor := m.Swizzles.SheenRoughness.Apply(SampleTextureGrad(m.SheenRoughnessTexture, u, v, dx, dy))
 		result.SheenRoughness *= sheenRoughnessColor.A
 	}
 
 	// Sample iridescence (KHR_materials_iridescence)
 	result.Iridescence = m.IridescenceFactor
 	if m.IridescenceTexture != nil {
-		iridescenceColor := m.IridescenceTexture.BilinearSample(u, v)
+		iridescenceColor := m.Swizzles.Iridescence.Apply(SampleTextureGrad(m.IridescenceTexture, u, v, dx, dy))
 		result.Iridescence *= iridescenceColor.R
 	}
 	result.IridescenceIor = m.IridescenceIor
@@ -267,7 +351,7 @@ func (m *PBRMaterial) Sample(u, v float64) *SampledMaterial {
 	thicknessRange := m.IridescenceThicknessMaximum - m.IridescenceThicknessMinimum
 	result.IridescenceThickness = m.IridescenceThicknessMinimum
 	if m.IridescenceThicknessTexture != nil {
-		thicknessColor := m.IridescenceThicknessTexture.BilinearSample(u, v)
+		thicknessColor := m.Swizzles.IridescenceThickness.Apply(SampleTextureGrad(m.IridescenceThicknessTexture, u, v, dx, dy))
 		result.IridescenceThickness += thicknessColor.G * thicknessRange
 	} else {
 		result.IridescenceThickness += thicknessRange * 0.5 // Use middle value
@@ -279,19 +363,19 @@ func (m *PBRMaterial) Sample(u, v float64) *SampledMaterial {
 	// Sample clearcoat (KHR_materials_clearcoat)
 	result.Clearcoat = m.ClearcoatFactor
 	if m.ClearcoatTexture != nil {
-		clearcoatColor := m.ClearcoatTexture.BilinearSample(u, v)
+		clearcoatColor := m.Swizzles.Clearcoat.Apply(SampleTextureGrad(m.ClearcoatTexture, u, v, dx, dy))
 		result.Clearcoat *= clearcoatColor.R
 	}
 	result.ClearcoatRoughness = m.ClearcoatRoughnessFactor
 	if m.ClearcoatRoughnessTexture != nil {
-		clearcoatRoughnessColor := m.ClearcoatRoughnessTexture.BilinearSample(u, v)
+		clearcoatRoughnessColor := m.Swizzles.ClearcoatRoughness.Apply(SampleTextureGrad(m.ClearcoatRoughnessTexture, u, v, dx, dy))
 		result.ClearcoatRoughness *= clearcoatRoughnessColor.G
 	}
 
 	// Sample clearcoat normal
 	result.ClearcoatNormal = Vector{0, 0, 1} // Default normal
 	if m.ClearcoatNormalTexture != nil {
-		clearcoatNormalColor := m.ClearcoatNormalTexture.BilinearSample(u, v)
+		clearcoatNormalColor := m.Swizzles.ClearcoatNormal.Apply(SampleTextureGrad(m.ClearcoatNormalTexture, u, v, dx, dy))
 		result.ClearcoatNormal = Vector{
 			clearcoatNormalColor.R*2.0 - 1.0,
 			clearcoatNormalColor.G*2.0 - 1.0,
@@ -299,6 +383,16 @@ func (m *PBRMaterial) Sample(u, v float64) *SampledMaterial {
 		}.Normalize()
 	}
 
+	// Sample subsurface scattering
+	result.Subsurface = m.SubsurfaceFactor
+	result.SubsurfaceColor = m.SubsurfaceColor
+	result.SubsurfaceRadius = m.SubsurfaceRadius
+	result.SubsurfaceThickness = m.SubsurfaceThickness
+	if m.SubsurfaceThicknessTexture != nil {
+		thicknessColor := m.Swizzles.SubsurfaceThickness.Apply(SampleTextureGrad(m.SubsurfaceThicknessTexture, u, v, dx, dy))
+		result.SubsurfaceThickness *= thicknessColor.G // Green channel for thickness
+	}
+
 	return result
 }
 
@@ -332,6 +426,13 @@ type SampledMaterial struct {
 	Clearcoat            float64
 	ClearcoatRoughness   float64
 	ClearcoatNormal      Vector
+	Tangent              Vector // Anisotropy direction, derived from the normal when zero
+
+	// Subsurface scattering
+	Subsurface          float64
+	SubsurfaceColor     Color
+	SubsurfaceRadius    float64
+	SubsurfaceThickness float64
 }
 
 // Light represents a light source
@@ -344,6 +445,9 @@ type Light struct {
 	Range     float64
 	InnerCone float64 // For spot lights
 	OuterCone float64 // For spot lights
+	// Shadow, when set, darkens the light where its shadow map is occluded,
+	// see SceneRenderer.EnableShadows
+	Shadow *LightShadow
 }
 
 // LightType represents the type of light
@@ -372,11 +476,12 @@ func (pbrL *PBRLighting) CalculatePBR(
 	lights []Light,
 	ambientColor Color,
 ) Color {
-	// Convert roughness to alpha for calculations
-	alpha := material.Roughness * material.Roughness
+	// Convert roughness to alpha for calculations; zero roughness would
+	// make the GGX distribution 0/0
+	alpha := math.Max(material.Roughness*material.Roughness, minAlpha)
 
-	// Calculate F0 (base reflectance)
-	f0 := Vector{0.04, 0.04, 0.04} // Non-metallic base reflectance
+	// Calculate F0 (base reflectance) from the IOR and specular color
+	f0 := dielectricF0(material)
 	if material.Metallic > 0 {
 		// Metallic materials use base color as F0
 		metallic := Vector{material.BaseColor.R, material.BaseColor.G, material.BaseColor.B}
@@ -392,12 +497,14 @@ func (pbrL *PBRLighting) CalculatePBR(
 		}
 	}
 
-	// Initialize final color with emissive
-	finalColor := material.Emissive
+	// Initialize final color with emissive, seen through the clearcoat
+	coat := 1 - pbrL.clearcoatFresnel(material, math.Max(0, worldNormal.Dot(viewDir)))
+	emissive := material.EmissiveStrength * coat
+	finalColor := Color{material.Emissive.R * emissive, material.Emissive.G * emissive, material.Emissive.B * emissive, material.Emissive.A}
 
 	// Add legacy ambient color only if no AmbientLight sources are present
 	if !hasAmbientLights && (ambientColor.R > 0 || ambientColor.G > 0 || ambientColor.B > 0) {
-		ambientContrib := material.BaseColor.Mul(ambientColor).MulScalar(material.Occlusion)
+		ambientContrib := material.BaseColor.Mul(ambientColor).MulScalar(material.Occlusion * pbrL.diffuseWeight(material))
 		finalColor = finalColor.Add(ambientContrib)
 	}
 
@@ -471,25 +578,56 @@ func (pbrL *PBRLighting) calculateLightContribution(
 	case AmbientLight:
 		// Ambient light provides uniform illumination to all surfaces
 		// It contributes directly to the base color without BRDF calculations
-		ambientContrib := material.BaseColor.Mul(light.Color).MulScalar(light.Intensity * material.Occlusion)
+		ambientContrib := material.BaseColor.Mul(light.Color).MulScalar(light.Intensity * material.Occlusion * pbrL.diffuseWeight(material))
 		return Color{ambientContrib.R, ambientContrib.G, ambientContrib.B, 0}
 	}
 
+	radiance := Vector{lightColor.R, lightColor.G, lightColor.B}
+
+	// Subsurface scattering reaches past the terminator and through thin
+	// regions; it is weighted the same on both sides so it stays continuous
+	var subsurface Vector
+	if material.Subsurface > 0 {
+		subsurface = pbrL.subsurfaceScattering(material, normal, viewDir, lightDir).MulScalar(1.0 - material.Metallic)
+	}
+
 	// Calculate lighting terms
 	NdotL := math.Max(0, normal.Dot(lightDir))
+	NdotV := math.Max(0, normal.Dot(viewDir))
+	coat := 1 - pbrL.clearcoatFresnel(material, NdotV)
 	if NdotL <= 0 {
-		return Color{0, 0, 0, 0}
+		var contribution Vector
+		if material.Subsurface > 0 {
+			contribution = subsurface.Mul(radiance)
+		}
+		if material.Transmission > 0 {
+			// Light from behind passing through transmissive surfaces
+			transmission := pbrL.transmittance(material)
+			btdf := pbrL.transmissionLobe(material, normal, viewDir, lightDir, f0)
+			contribution = contribution.Add(btdf.Mul(radiance).MulScalar(transmission))
+		}
+		contribution = contribution.MulScalar(coat)
+		return Color{contribution.X, contribution.Y, contribution.Z, 0}
 	}
 
 	halfVector := lightDir.Add(viewDir).Normalize()
-	NdotV := math.Max(0, normal.Dot(viewDir))
 	NdotH := math.Max(0, normal.Dot(halfVector))
 	VdotH := math.Max(0, viewDir.Dot(halfVector))
 
 	// BRDF calculations
-	D := pbrL.distributionGGX(NdotH, alpha)
+	var D float64
+	if material.AnisotropyStrength > 0 {
+		D = pbrL.distributionAnisotropicGGX(material, normal, halfVector, alpha)
+	} else {
+		D = pbrL.distributionGGX(NdotH, alpha)
+	}
 	G := pbrL.geometrySmith(NdotV, NdotL, alpha)
 	F := pbrL.fresnelSchlick(VdotH, f0)
+	if material.Iridescence > 0 {
+		// Thin-film interference replaces part of the Fresnel term
+		film := pbrL.iridescenceFresnel(VdotH, material.IridescenceIor, material.IridescenceThickness, f0)
+		F = F.Lerp(film, Clamp(material.Iridescence, 0, 1))
+	}
 
 	// Cook-Torrance BRDF
 	numerator := D * G
@@ -499,7 +637,8 @@ func (pbrL *PBRLighting) calculateLightContribution(
 	// Calculate kS and kD for energy conservation
 	kS := Vector{F.X, F.Y, F.Z}
 	kD := Vector{1.0, 1.0, 1.0}.Sub(kS)
-	kD = kD.MulScalar(1.0 - material.Metallic) // Metallic materials have no diffuse
+	// Metallic materials have no diffuse, and transmitted light leaves it
+	kD = kD.MulScalar(1.0 - material.Metallic - pbrL.transmittance(material))
 
 	// Combine diffuse and specular
 	diffuse := Vector{
@@ -508,15 +647,74 @@ func (pbrL *PBRLighting) calculateLightContribution(
 		material.BaseColor.B / math.Pi,
 	}
 
-	brdf := kD.Mul(diffuse).Add(Vector{specular * F.X, specular * F.Y, specular * F.Z})
+	diffuseWeight := 1.0 - material.Subsurface
+	brdf := kD.Mul(diffuse).MulScalar(diffuseWeight).Add(Vector{specular * F.X, specular * F.Y, specular * F.Z})
+	if material.SheenColor.R > 0 || material.SheenColor.G > 0 || material.SheenColor.B > 0 {
+		brdf = brdf.Add(pbrL.sheenLobe(material, NdotL, NdotV, NdotH))
+	}
 
 	// Final color contribution
-	radiance := Vector{lightColor.R, lightColor.G, lightColor.B}
 	contribution := brdf.Mul(radiance).MulScalar(NdotL)
+	if material.Subsurface > 0 {
+		contribution = contribution.Add(subsurface.Mul(radiance))
+	}
+
+	// The clearcoat reflects part of the light before it reaches the base
+	if material.Clearcoat > 0 {
+		clearcoat := pbrL.clearcoatLobe(material, normal, viewDir, lightDir, halfVector) * material.Clearcoat * NdotL
+		contribution = contribution.MulScalar(coat).Add(radiance.MulScalar(clearcoat))
+	}
 
 	return Color{contribution.X, contribution.Y, contribution.Z, 0}
 }
 
+// transmittance returns the share of light transmitted through the
+// surface rather than diffusely reflected; only the dielectric part of a
+// material transmits
+func (pbrL *PBRLighting) transmittance(material *SampledMaterial) float64 {
+	return material.Transmission * (1.0 - material.Metallic)
+}
+
+// diffuseWeight returns the share of the diffuse lobe left over by
+// transmission and the clearcoat, used for ambient light. The transmitted
+// share leaves it as it leaves kD of direct light.
+func (pbrL *PBRLighting) diffuseWeight(material *SampledMaterial) float64 {
+	return (1.0 - pbrL.transmittance(material)) * (1.0 - 0.04*material.Clearcoat)
+}
+
+// Translucency lobe shape for subsurface scattering
+const (
+	subsurfaceDistortion = 0.2 // Bends transmitted light along the surface normal
+	subsurfacePower      = 4.0 // Tightness of the view-dependent translucency lobe
+)
+
+// subsurfaceScattering returns the subsurface diffuse response (already
+// including the cosine term) weighted by the material's subsurface factor.
+// Each channel wraps light past the terminator in proportion to its scatter
+// distance, and a thickness driven translucency term adds back lighting.
+func (pbrL *PBRLighting) subsurfaceScattering(material *SampledMaterial, normal, viewDir, lightDir Vector) Vector {
+	s := material.Subsurface
+	NdotL := normal.Dot(lightDir)
+	scatter := material.SubsurfaceColor
+	albedo := Vector{material.BaseColor.R, material.BaseColor.G, material.BaseColor.B}
+
+	// Wrap diffuse per channel, keeping full response facing the light
+	wrap := func(w float64) float64 {
+		w *= s
+		return math.Max(0, NdotL+w) / (1 + w)
+	}
+	wrapped := Vector{wrap(scatter.R), wrap(scatter.G), wrap(scatter.B)}.DivScalar(math.Pi)
+
+	// Translucency attenuated by thickness (Beer-Lambert)
+	radius := math.Max(material.SubsurfaceRadius, 1e-4)
+	transmittance := math.Exp(-material.SubsurfaceThickness / radius)
+	back := lightDir.Add(normal.MulScalar(subsurfaceDistortion)).Negate().Normalize()
+	lobe := math.Pow(Clamp(viewDir.Dot(back), 0, 1), subsurfacePower)
+	translucency := Vector{scatter.R, scatter.G, scatter.B}.MulScalar(transmittance * lobe / math.Pi)
+
+	return albedo.Mul(wrapped.Add(translucency)).MulScalar(s)
+}
+
 // distributionGGX calculates the normal distribution function using GGX/Trowbridge-Reitz
 func (pbrL *PBRLighting) distributionGGX(NdotH, alpha float64) float64 {
 	a2 := alpha * alpha
@@ -0,0 +1,182 @@
+package fauxgl
+
+import (
+	"math"
+)
+
+// Extension lobes of the PBR BRDF: KHR_materials_clearcoat, sheen,
+// anisotropy, iridescence and transmission. Each is skipped when its factor
+// is zero, so materials without extensions shade as the core BRDF.
+
+// minAlpha keeps GGX lobes of perfectly smooth surfaces finite
+const minAlpha = 1e-3
+
+// Wavelengths in nm at which the thin film of iridescent materials is
+// evaluated for the red, green and blue channels
+var iridescenceWavelengths = Vector{650, 510, 475}
+
+// dielectricF0 returns the normal incidence reflectance of the dielectric
+// part of a material from its IOR and specular color, 0.04 for the default
+// IOR of 1.5
+func dielectricF0(material *SampledMaterial) Vector {
+	ior := material.IOR
+	if ior <= 0 {
+		ior = 1.5
+	}
+	r := (ior - 1) / (ior + 1)
+	f0 := Vector{r * r, r * r, r * r}
+	if material.SpecularColor != (Color{}) {
+		c := material.SpecularColor
+		f0 = f0.Mul(Vector{c.R, c.G, c.B}).Min(Vector{1, 1, 1})
+	}
+	return f0
+}
+
+// tangentFrame returns the tangent and bitangent of the anisotropy
+// direction: the material tangent, or one derived from the normal when the
+// surface has none, rotated by the anisotropy rotation
+func tangentFrame(material *SampledMaterial, normal Vector) (Vector, Vector) {
+	tangent := material.Tangent
+	tangent = tangent.Sub(normal.MulScalar(normal.Dot(tangent)))
+	if tangent.LengthSquared() < 1e-12 {
+		// Horizontal tangent, or along X where the normal points up
+		tangent = Vector{0, 1, 0}.Cross(normal)
+		if tangent.LengthSquared() < 1e-12 {
+			tangent = Vector{1, 0, 0}
+		}
+	}
+	tangent = tangent.Normalize()
+	bitangent := normal.Cross(tangent)
+	if material.AnisotropyRotation != 0 {
+		s, c := math.Sincos(material.AnisotropyRotation)
+		tangent, bitangent = tangent.MulScalar(c).Add(bitangent.MulScalar(s)), bitangent.MulScalar(c).Sub(tangent.MulScalar(s))
+	}
+	return tangent, bitangent
+}
+
+// distributionAnisotropicGGX is the GGX distribution stretched along the
+// tangent, with the tangent roughness raised towards 1 by the strength as in
+// KHR_materials_anisotropy
+func (pbrL *PBRLighting) distributionAnisotropicGGX(material *SampledMaterial, normal, halfVector Vector, alpha float64) float64 {
+	s := Clamp(material.AnisotropyStrength, 0, 1)
+	at := math.Max(alpha+(1-alpha)*s*s, minAlpha)
+	ab := math.Max(alpha, minAlpha)
+	tangent, bitangent := tangentFrame(material, normal)
+	th := tangent.Dot(halfVector) / at
+	bh := bitangent.Dot(halfVector) / ab
+	nh := normal.Dot(halfVector)
+	d := th*th + bh*bh + nh*nh
+	return 1 / (math.Pi * at * ab * d * d)
+}
+
+// iridescenceFresnel returns the reflectance of a thin film of the given
+// IOR and thickness (nm) over a base of reflectance f0, from the Airy sum of
+// the two interfaces averaged over s and p polarization
+func (pbrL *PBRLighting) iridescenceFresnel(cosTheta, filmIOR, thickness float64, f0 Vector) Vector {
+	if thickness <= 0 || filmIOR <= 0 {
+		return pbrL.fresnelSchlick(cosTheta, f0)
+	}
+	sin2 := (1 - cosTheta*cosTheta) / (filmIOR * filmIOR)
+	if sin2 >= 1 {
+		return Vector{1, 1, 1}
+	}
+	cosFilm := math.Sqrt(1 - sin2)
+
+	reflectance := func(wavelength, baseF0 float64) float64 {
+		// Base IOR from its reflectance, ignoring absorption of metals
+		sqrtF0 := math.Sqrt(Clamp(baseF0, 0, 0.9999))
+		baseIOR := (1 + sqrtF0) / (1 - sqrtF0)
+		sinBase2 := sin2 * filmIOR * filmIOR / (baseIOR * baseIOR)
+		cosBase := math.Sqrt(math.Max(0, 1-sinBase2))
+
+		phase := 4 * math.Pi * filmIOR * thickness * cosFilm / wavelength
+		cosPhase := math.Cos(phase)
+		airy := func(r12, r23 float64) float64 {
+			return (r12*r12 + r23*r23 + 2*r12*r23*cosPhase) / (1 + r12*r12*r23*r23 + 2*r12*r23*cosPhase)
+		}
+		s := airy(
+			(cosTheta-filmIOR*cosFilm)/(cosTheta+filmIOR*cosFilm),
+			(filmIOR*cosFilm-baseIOR*cosBase)/(filmIOR*cosFilm+baseIOR*cosBase))
+		p := airy(
+			(filmIOR*cosTheta-cosFilm)/(filmIOR*cosTheta+cosFilm),
+			(baseIOR*cosFilm-filmIOR*cosBase)/(baseIOR*cosFilm+filmIOR*cosBase))
+		return Clamp((s+p)/2, 0, 1)
+	}
+	w := iridescenceWavelengths
+	return Vector{reflectance(w.X, f0.X), reflectance(w.Y, f0.Y), reflectance(w.Z, f0.Z)}
+}
+
+// sheenLobe returns the Charlie sheen BRDF with the Neubelt visibility
+// term. The base layer is not darkened by the sheen albedo, which needs a
+// precomputed table.
+func (pbrL *PBRLighting) sheenLobe(material *SampledMaterial, NdotL, NdotV, NdotH float64) Vector {
+	roughness := math.Max(material.SheenRoughness, 0.07)
+	invAlpha := 1 / (roughness * roughness)
+	sin2 := math.Max(1-NdotH*NdotH, 0.0078125)
+	d := (2 + invAlpha) * math.Pow(sin2, invAlpha*0.5) / (2 * math.Pi)
+	v := 1 / (4 * (NdotL + NdotV - NdotL*NdotV))
+	c := material.SheenColor
+	return Vector{c.R, c.G, c.B}.MulScalar(d * v)
+}
+
+// clearcoatLobe returns the specular BRDF of the clearcoat layer, a
+// dielectric GGX lobe with IOR 1.5 over the geometric normal
+func (pbrL *PBRLighting) clearcoatLobe(material *SampledMaterial, normal, viewDir, lightDir, halfVector Vector) float64 {
+	alpha := math.Max(material.ClearcoatRoughness*material.ClearcoatRoughness, minAlpha)
+	NdotL := math.Max(0, normal.Dot(lightDir))
+	NdotV := math.Max(0, normal.Dot(viewDir))
+	NdotH := math.Max(0, normal.Dot(halfVector))
+	VdotH := math.Max(0, viewDir.Dot(halfVector))
+	D := pbrL.distributionGGX(NdotH, alpha)
+	G := pbrL.geometrySmith(NdotV, NdotL, alpha)
+	F := pbrL.fresnelSchlick(VdotH, Vector{0.04, 0.04, 0.04}).X
+	return D * G * F / (4.0*NdotV*NdotL + 0.001)
+}
+
+// clearcoatFresnel returns the view dependent reflectance of the clearcoat
+// layer, the fraction of light that never reaches the base
+func (pbrL *PBRLighting) clearcoatFresnel(material *SampledMaterial, NdotV float64) float64 {
+	if material.Clearcoat <= 0 {
+		return 0
+	}
+	return material.Clearcoat * pbrL.fresnelSchlick(NdotV, Vector{0.04, 0.04, 0.04}).X
+}
+
+// transmissionLobe returns the BTDF of a thin-walled transmissive surface
+// lit from behind: the light is mirrored through the surface and seen
+// through a GGX lobe whose roughness grows with the IOR, tinted by the base
+// color and by volume attenuation over the material thickness
+func (pbrL *PBRLighting) transmissionLobe(material *SampledMaterial, normal, viewDir, lightDir Vector, f0 Vector) Vector {
+	mirrored := lightDir.Sub(normal.MulScalar(2 * lightDir.Dot(normal))).Normalize()
+	NdotL := math.Max(0, normal.Dot(mirrored))
+	NdotV := math.Max(0, normal.Dot(viewDir))
+	if NdotL <= 0 || NdotV <= 0 {
+		return Vector{}
+	}
+	halfVector := mirrored.Add(viewDir).Normalize()
+	NdotH := math.Max(0, normal.Dot(halfVector))
+	VdotH := math.Max(0, viewDir.Dot(halfVector))
+
+	ior := material.IOR
+	if ior <= 0 {
+		ior = 1.5
+	}
+	roughness := material.Roughness * Clamp(ior*2-2, 0, 1)
+	alpha := math.Max(roughness*roughness, minAlpha)
+	D := pbrL.distributionGGX(NdotH, alpha)
+	G := pbrL.geometrySmith(NdotV, NdotL, alpha)
+	btdf := D * G / (4.0*NdotV + 0.001) // Includes the cosine term
+
+	// Light not reflected at the surface enters it
+	F := pbrL.fresnelSchlick(VdotH, f0)
+	color := Vector{material.BaseColor.R, material.BaseColor.G, material.BaseColor.B}
+	result := color.Mul(Vector{1, 1, 1}.Sub(F)).MulScalar(btdf)
+
+	// KHR_materials_volume attenuation through the thickness
+	if material.Thickness > 0 && material.AttenuationDistance > 0 && !math.IsInf(material.AttenuationDistance, 1) {
+		c := material.AttenuationColor
+		e := material.Thickness / material.AttenuationDistance
+		result = result.Mul(Vector{math.Pow(c.R, e), math.Pow(c.G, e), math.Pow(c.B, e)})
+	}
+	return result
+}
@@ -0,0 +1,227 @@
+package fauxgl
+
+import (
+	"math"
+	"sort"
+)
+
+// MeshHit describes where a ray hits a triangle of a mesh
+type MeshHit struct {
+	Triangle    int     // Index into Mesh.Triangles
+	Distance    float64 // Distance along the ray, in units of its direction
+	Barycentric Vector  // Weights of the triangle's V1, V2 and V3
+	UV          Vector  // Interpolated texture coordinates
+	Position    Vector
+	Normal      Vector // Interpolated vertex normal
+}
+
+// meshBVH is a bounding volume hierarchy over the triangles of a mesh,
+// built by RayIntersect and dropped when the mesh changes
+type meshBVH struct {
+	nodes     []meshBVHNode
+	triangles []int // Indices into Mesh.Triangles, grouped by leaf
+}
+
+type meshBVHNode struct {
+	bounds      Box
+	left, right int // Children of inner nodes
+	first       int // First triangle of a leaf
+	count       int // Triangles of a leaf, 0 for inner nodes
+}
+
+// newMeshBVH builds a BVH over the triangles of a mesh
+func newMeshBVH(m *Mesh) *meshBVH {
+	bvh := &meshBVH{triangles: make([]int, len(m.Triangles))}
+	bounds := make([]Box, len(m.Triangles))
+	for i, t := range m.Triangles {
+		bounds[i] = t.BoundingBox()
+		bvh.triangles[i] = i
+	}
+	if len(m.Triangles) > 0 {
+		bvh.split(bounds, 0, len(m.Triangles))
+	}
+	return bvh
+}
+
+// split builds the BVH node over triangles[first:last] and returns its
+// index, splitting at the median of the triangle centers along the longest
+// axis like SceneBVH
+func (bvh *meshBVH) split(bounds []Box, first, last int) int {
+	index := len(bvh.nodes)
+	bvh.nodes = append(bvh.nodes, meshBVHNode{})
+	triangles := bvh.triangles[first:last]
+	box, centers := EmptyBox, EmptyBox
+	for _, i := range triangles {
+		box = box.Extend(bounds[i])
+		center := bounds[i].Center()
+		centers = centers.Extend(Box{center, center})
+	}
+	if len(triangles) <= bvhLeafSize {
+		bvh.nodes[index] = meshBVHNode{bounds: box, first: first, count: len(triangles)}
+		return index
+	}
+
+	size := centers.Size()
+	axis := func(b Box) float64 { return b.Center().X }
+	if size.Y > size.X && size.Y >= size.Z {
+		axis = func(b Box) float64 { return b.Center().Y }
+	} else if size.Z > size.X && size.Z > size.Y {
+		axis = func(b Box) float64 { return b.Center().Z }
+	}
+	sort.Slice(triangles, func(a, b int) bool {
+		return axis(bounds[triangles[a]]) < axis(bounds[triangles[b]])
+	})
+	mid := first + len(triangles)/2
+	left := bvh.split(bounds, first, mid)
+	right := bvh.split(bounds, mid, last)
+	bvh.nodes[index] = meshBVHNode{bounds: box, left: left, right: right}
+	return index
+}
+
+// rayTriangle returns the distance along a ray and the barycentric weights
+// of V2 and V3 at which it hits a triangle from either side
+// (Möller–Trumbore); the distance may be negative
+func rayTriangle(origin, direction Vector, t *Triangle) (float64, float64, float64, bool) {
+	e1 := t.V2.Position.Sub(t.V1.Position)
+	e2 := t.V3.Position.Sub(t.V1.Position)
+	p := direction.Cross(e2)
+	det := e1.Dot(p)
+	if math.Abs(det) < 1e-9 {
+		return 0, 0, 0, false
+	}
+	inv := 1 / det
+	s := origin.Sub(t.V1.Position)
+	u := s.Dot(p) * inv
+	if u < 0 || u > 1 {
+		return 0, 0, 0, false
+	}
+	q := s.Cross(e1)
+	v := direction.Dot(q) * inv
+	if v < 0 || u+v > 1 {
+		return 0, 0, 0, false
+	}
+	return e2.Dot(q) * inv, u, v, true
+}
+
+// RayIntersect returns the nearest triangle a ray hits in front of its
+// origin, from either side. The direction need not be normalized; the
+// distance of the hit is in units of it. A BVH over the triangles is built
+// on the first call and kept until the mesh changes.
+func (m *Mesh) RayIntersect(origin, direction Vector) (MeshHit, bool) {
+	if m.bvh == nil {
+		m.bvh = newMeshBVH(m)
+	}
+	bvh := m.bvh
+	if len(bvh.nodes) == 0 {
+		return MeshHit{}, false
+	}
+	best, triangle := math.Inf(1), -1
+	var u, v float64
+	stack := []int{0}
+	for len(stack) > 0 {
+		node := &bvh.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if t0, _, ok := node.bounds.IntersectRay(origin, direction); !ok || t0 > best {
+			continue
+		}
+		if node.count == 0 {
+			stack = append(stack, node.left, node.right)
+			continue
+		}
+		for _, i := range bvh.triangles[node.first : node.first+node.count] {
+			t, tu, tv, ok := rayTriangle(origin, direction, m.Triangles[i])
+			if ok && t >= 0 && t < best {
+				best, triangle, u, v = t, i, tu, tv
+			}
+		}
+	}
+	if triangle < 0 {
+		return MeshHit{}, false
+	}
+
+	t := m.Triangles[triangle]
+	w := 1 - u - v
+	interpolate := func(a, b, c Vector) Vector {
+		return a.MulScalar(w).Add(b.MulScalar(u)).Add(c.MulScalar(v))
+	}
+	return MeshHit{
+		Triangle:    triangle,
+		Distance:    best,
+		Barycentric: Vector{w, u, v},
+		UV:          interpolate(t.V1.Texture, t.V2.Texture, t.V3.Texture),
+		Position:    origin.Add(direction.MulScalar(best)),
+		Normal:      interpolate(t.V1.Normal, t.V2.Normal, t.V3.Normal).Normalize(),
+	}, true
+}
+
+// PickResult describes the surface of a scene under a ray or screen pixel
+type PickResult struct {
+	Node     *SceneNode
+	Instance int // Index into Node.Instances, 0 without instances
+	MeshHit      // Hit in the node's mesh; Position and Normal in world space
+	// Material is the material of the hit triangle, from Node.Materials
+	// when its mesh mixes materials
+	Material *PBRMaterial
+}
+
+// ScreenRay returns the ray through a point of a width by height image
+// rendered from the camera, in pixels from the top left corner, with pixel
+// centers at half units. The ray starts on the near plane.
+func (camera *Camera) ScreenRay(x, y float64, width, height int) (origin, direction Vector) {
+	inverse := camera.GetCameraMatrix().Inverse()
+	ndc := Vector{2*x/float64(width) - 1, 1 - 2*y/float64(height), -1}
+	mid := 0.0
+	if camera.DepthMode == DepthReversed {
+		ndc.Z, mid = 1, 0.5
+	}
+	unproject := func(p Vector) Vector {
+		clip := inverse.MulPositionW(p)
+		return clip.DivScalar(clip.W).Vector()
+	}
+	origin = unproject(ndc)
+	direction = unproject(Vector{ndc.X, ndc.Y, mid}).Sub(origin).Normalize()
+	return origin, direction
+}
+
+// Pick returns the nearest surface under a point of a width by height
+// image rendered from the camera, for selecting objects with the mouse;
+// see ScreenRay for the coordinates.
+func (scene *Scene) Pick(camera *Camera, x, y float64, width, height int) (PickResult, bool) {
+	origin, direction := camera.ScreenRay(x, y, width, height)
+	return scene.RayIntersect(origin, direction)
+}
+
+// RayIntersect returns the nearest surface of the visible nodes a ray hits
+// in front of its origin, using the BVH of the scene to skip nodes the ray
+// misses. Meshes are tested as stored: vertex modifiers are ignored.
+func (scene *Scene) RayIntersect(origin, direction Vector) (PickResult, bool) {
+	var best PickResult
+	found := false
+	for _, node := range scene.BVH().QueryRay(origin, direction) {
+		// Nodes come nearest first, so no later node can be nearer
+		if t0, _, _ := node.worldBounds().IntersectRay(origin, direction); found && t0 > best.Distance {
+			break
+		}
+		for instance, transform := range node.InstanceTransforms() {
+			inverse := transform.Inverse()
+			localOrigin := inverse.MulPosition(origin)
+			// The local direction keeps its scale, so distances stay in
+			// units of the world direction
+			localDirection := inverse.MulPosition(origin.Add(direction)).Sub(localOrigin)
+			hit, ok := node.Mesh.RayIntersect(localOrigin, localDirection)
+			if !ok || (found && hit.Distance >= best.Distance) {
+				continue
+			}
+			hit.Position = origin.Add(direction.MulScalar(hit.Distance))
+			hit.Normal = inverse.Transpose().MulDirection(hit.Normal)
+			best = PickResult{Node: node, Instance: instance, MeshHit: hit, Material: node.Material}
+			found = true
+		}
+	}
+	if found && best.Node.Mesh.hasMaterialIDs() {
+		if id := best.Node.Mesh.MaterialIDs[best.Triangle]; id >= 0 && id < len(best.Node.Materials) && best.Node.Materials[id] != nil {
+			best.Material = best.Node.Materials[id]
+		}
+	}
+	return best, found
+}
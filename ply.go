@@ -0,0 +1,418 @@
+package fauxgl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// plyProperty is a property of a PLY element: a scalar, or a list of
+// scalars preceded by its length
+type plyProperty struct {
+	name      string
+	kind      string // Scalar type of the value, or of the list items
+	countKind string // Scalar type of the list length, "" for scalars
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// plySizes are the sizes in bytes of the PLY scalar types, by their names
+// and the aliases of PLY 1.0
+var plySizes = map[string]int{
+	"char": 1, "uchar": 1, "int8": 1, "uint8": 1,
+	"short": 2, "ushort": 2, "int16": 2, "uint16": 2,
+	"int": 4, "uint": 4, "int32": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8,
+}
+
+// plyReader reads the scalars of the body of a PLY file
+type plyReader struct {
+	r     *bufio.Reader
+	ascii bool
+	order binary.ByteOrder
+	buf   [8]byte
+}
+
+func (p *plyReader) next(kind string) (float64, error) {
+	if p.ascii {
+		word, err := p.word()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseFloat(word, 64)
+	}
+	b := p.buf[:plySizes[kind]]
+	if _, err := io.ReadFull(p.r, b); err != nil {
+		return 0, err
+	}
+	switch kind {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(p.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(p.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(p.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(p.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(p.order.Uint32(b))), nil
+	}
+	return math.Float64frombits(p.order.Uint64(b)), nil
+}
+
+// word returns the next whitespace separated word of an ASCII body
+func (p *plyReader) word() (string, error) {
+	var word []byte
+	for {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(word) > 0 {
+				return string(word), nil
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			if len(word) > 0 {
+				return string(word), nil
+			}
+			continue
+		}
+		word = append(word, c)
+	}
+}
+
+// LoadPLY loads an ASCII or binary PLY file as a mesh
+func LoadPLY(path string) (*Mesh, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadPLYFromBytes(data)
+}
+
+// LoadPLYFromBytes loads ASCII or binary PLY data as a mesh. Faces are
+// triangulated as fans. Vertex normals, texture coordinates (s and t or u
+// and v) and colors are read when present; without normals the mesh gets
+// smooth normals from its faces. Point clouds, without faces, are not
+// supported.
+func LoadPLYFromBytes(data []byte) (*Mesh, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	reader, elements, err := readPLYHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var vertices []Vertex
+	var faces [][]int
+	hasNormals := false
+	for _, element := range elements {
+		index := make(map[string]int, len(element.properties))
+		for i, property := range element.properties {
+			index[property.name] = i
+		}
+		values := make([]float64, len(element.properties))
+		var list []int
+		for n := 0; n < element.count; n++ {
+			for i, property := range element.properties {
+				if property.countKind == "" {
+					if values[i], err = reader.next(property.kind); err != nil {
+						return nil, fmt.Errorf("failed to read PLY %s %d: %w", element.name, n, err)
+					}
+					continue
+				}
+				count, err := reader.next(property.countKind)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read PLY %s %d: %w", element.name, n, err)
+				}
+				list = list[:0]
+				for k := 0; k < int(count); k++ {
+					value, err := reader.next(property.kind)
+					if err != nil {
+						return nil, fmt.Errorf("failed to read PLY %s %d: %w", element.name, n, err)
+					}
+					list = append(list, int(value))
+				}
+				if element.name == "face" && (property.name == "vertex_indices" || property.name == "vertex_index") {
+					faces = append(faces, append([]int(nil), list...))
+				}
+			}
+			if element.name == "vertex" {
+				v, normal := plyVertex(element, index, values)
+				hasNormals = hasNormals || normal
+				vertices = append(vertices, v)
+			}
+		}
+	}
+	if len(faces) == 0 {
+		return nil, errors.New("PLY file has no faces; point clouds are not supported")
+	}
+
+	var triangles []*Triangle
+	for i, face := range faces {
+		for _, k := range face {
+			if k < 0 || k >= len(vertices) {
+				return nil, fmt.Errorf("PLY face %d: vertex index %d out of range", i, k)
+			}
+		}
+		for k := 1; k+1 < len(face); k++ {
+			triangles = append(triangles, &Triangle{vertices[face[0]], vertices[face[k]], vertices[face[k+1]]})
+		}
+	}
+	mesh := NewTriangleMesh(triangles)
+	if !hasNormals {
+		for _, t := range mesh.Triangles {
+			t.FixNormals()
+		}
+		mesh.SmoothNormals()
+	}
+	return mesh, nil
+}
+
+// readPLYHeader reads the header of a PLY file, returning a reader of its
+// body and its elements
+func readPLYHeader(r *bufio.Reader) (*plyReader, []plyElement, error) {
+	reader := &plyReader{r: r}
+	var elements []plyElement
+	line := func() (string, error) {
+		s, err := r.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("failed to read PLY header: %w", err)
+		}
+		return strings.TrimSpace(s), nil
+	}
+	magic, err := line()
+	if err != nil {
+		return nil, nil, err
+	}
+	if magic != "ply" {
+		return nil, nil, errors.New("invalid PLY file")
+	}
+	format := ""
+	for {
+		s, err := line()
+		if err != nil {
+			return nil, nil, err
+		}
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) < 2 {
+				return nil, nil, errors.New("invalid PLY format line")
+			}
+			format = fields[1]
+		case "element":
+			if len(fields) != 3 {
+				return nil, nil, fmt.Errorf("invalid PLY element line: %s", s)
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return nil, nil, fmt.Errorf("invalid PLY element count: %s", s)
+			}
+			elements = append(elements, plyElement{name: fields[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return nil, nil, errors.New("PLY property outside of an element")
+			}
+			var property plyProperty
+			switch {
+			case len(fields) == 5 && fields[1] == "list":
+				property = plyProperty{name: fields[4], kind: fields[3], countKind: fields[2]}
+			case len(fields) == 3:
+				property = plyProperty{name: fields[2], kind: fields[1]}
+			default:
+				return nil, nil, fmt.Errorf("invalid PLY property line: %s", s)
+			}
+			for _, kind := range []string{property.kind, property.countKind} {
+				if _, ok := plySizes[kind]; !ok && kind != "" {
+					return nil, nil, fmt.Errorf("unsupported PLY property type: %s", kind)
+				}
+			}
+			element := &elements[len(elements)-1]
+			element.properties = append(element.properties, property)
+		case "end_header":
+			switch format {
+			case "ascii":
+				reader.ascii = true
+			case "binary_little_endian":
+				reader.order = binary.LittleEndian
+			case "binary_big_endian":
+				reader.order = binary.BigEndian
+			default:
+				return nil, nil, fmt.Errorf("unsupported PLY format: %s", format)
+			}
+			return reader, elements, nil
+		}
+	}
+}
+
+// plyVertex builds a vertex from the property values of a PLY vertex, and
+// reports whether it has a normal
+func plyVertex(element plyElement, index map[string]int, values []float64) (Vertex, bool) {
+	get := func(names ...string) (float64, bool) {
+		for _, name := range names {
+			if i, ok := index[name]; ok {
+				return values[i], true
+			}
+		}
+		return 0, false
+	}
+	var v Vertex
+	v.Position.X, _ = get("x")
+	v.Position.Y, _ = get("y")
+	v.Position.Z, _ = get("z")
+	nx, hasNormal := get("nx")
+	v.Normal = Vector{nx, 0, 0}
+	v.Normal.Y, _ = get("ny")
+	v.Normal.Z, _ = get("nz")
+	v.Texture.X, _ = get("s", "u", "texture_s", "texture_u")
+	v.Texture.Y, _ = get("t", "v", "texture_t", "texture_v")
+
+	// Integer colors span their type, float colors are 0-1
+	channel := func(names ...string) (float64, bool) {
+		for _, name := range names {
+			i, ok := index[name]
+			if !ok {
+				continue
+			}
+			switch element.properties[i].kind {
+			case "uchar", "uint8":
+				return values[i] / 255, true
+			case "ushort", "uint16":
+				return values[i] / 65535, true
+			}
+			return values[i], true
+		}
+		return 0, false
+	}
+	if r, ok := channel("red", "diffuse_red"); ok {
+		g, _ := channel("green", "diffuse_green")
+		b, _ := channel("blue", "diffuse_blue")
+		a, ok := channel("alpha", "diffuse_alpha")
+		if !ok {
+			a = 1
+		}
+		v.Color = Color{r, g, b, a}
+	}
+	return v, hasNormal
+}
+
+// SavePLY saves a mesh as a binary little endian PLY file, or an ASCII one
+func SavePLY(path string, mesh *Mesh, ascii bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := WritePLY(w, mesh, ascii); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WritePLY writes a mesh as binary little endian PLY, or ASCII PLY, with
+// the vertices its triangles share written once. Positions and normals are
+// always written; texture coordinates and colors when any vertex has them.
+func WritePLY(w io.Writer, mesh *Mesh, ascii bool) error {
+	indexed := mesh.Indexed()
+	textured, colored := false, false
+	for _, v := range indexed.Vertices {
+		textured = textured || v.Texture != (Vector{})
+		colored = colored || v.Color != (Color{})
+	}
+
+	format := "binary_little_endian"
+	if ascii {
+		format = "ascii"
+	}
+	var header strings.Builder
+	fmt.Fprintf(&header, "ply\nformat %s 1.0\ncomment fauxgl\nelement vertex %d\n", format, len(indexed.Vertices))
+	header.WriteString("property float x\nproperty float y\nproperty float z\n")
+	header.WriteString("property float nx\nproperty float ny\nproperty float nz\n")
+	if textured {
+		header.WriteString("property float s\nproperty float t\n")
+	}
+	if colored {
+		header.WriteString("property uchar red\nproperty uchar green\nproperty uchar blue\nproperty uchar alpha\n")
+	}
+	fmt.Fprintf(&header, "element face %d\nproperty list uchar int vertex_indices\nend_header\n", indexed.Triangles())
+	if _, err := io.WriteString(w, header.String()); err != nil {
+		return err
+	}
+
+	var buf []byte
+	for _, v := range indexed.Vertices {
+		floats := []float64{v.Position.X, v.Position.Y, v.Position.Z, v.Normal.X, v.Normal.Y, v.Normal.Z}
+		if textured {
+			floats = append(floats, v.Texture.X, v.Texture.Y)
+		}
+		buf = buf[:0]
+		for i, f := range floats {
+			if ascii {
+				if i > 0 {
+					buf = append(buf, ' ')
+				}
+				buf = strconv.AppendFloat(buf, f, 'g', -1, 32)
+			} else {
+				buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(f)))
+			}
+		}
+		if colored {
+			c := v.Color.NRGBA()
+			for _, b := range [4]uint8{c.R, c.G, c.B, c.A} {
+				if ascii {
+					buf = append(buf, ' ')
+					buf = strconv.AppendInt(buf, int64(b), 10)
+				} else {
+					buf = append(buf, b)
+				}
+			}
+		}
+		if ascii {
+			buf = append(buf, '\n')
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < indexed.Triangles(); i++ {
+		face := indexed.Indices[3*i : 3*i+3]
+		if ascii {
+			buf = fmt.Appendf(buf[:0], "3 %d %d %d\n", face[0], face[1], face[2])
+		} else {
+			buf = append(buf[:0], 3)
+			for _, k := range face {
+				buf = binary.LittleEndian.AppendUint32(buf, uint32(k))
+			}
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"image"
 	"image/color"
 	"math"
+	"runtime"
+	"sync"
 )
 
 // PostProcessingEffect represents a post-processing effect
@@ -37,6 +39,70 @@ func (pp *PostProcessingPipeline) Process(input *image.NRGBA) *image.NRGBA {
 	return result
 }
 
+// parallelRows splits the rows of an image into bands processed by one
+// goroutine per CPU, calling f with the half-open row range of each band.
+// Effects write every output row from a single band, so no locking is
+// needed.
+func parallelRows(height int, f func(y0, y1 int)) {
+	wn := runtime.NumCPU()
+	if wn > height {
+		wn = height
+	}
+	if wn <= 1 {
+		f(0, height)
+		return
+	}
+	// More bands than workers balances rows of uneven cost
+	bands := wn * 4
+	if bands > height {
+		bands = height
+	}
+	var next int
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for wi := 0; wi < wn; wi++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mutex.Lock()
+				band := next
+				next++
+				mutex.Unlock()
+				if band >= bands {
+					return
+				}
+				f(band*height/bands, (band+1)*height/bands)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// postImages recycles the temporary images of post-processing effects
+var postImages sync.Pool
+
+// newPostImage returns a cleared temporary image, reusing a released one
+// when it is large enough
+func newPostImage(bounds image.Rectangle) *image.NRGBA {
+	if img, ok := postImages.Get().(*image.NRGBA); ok {
+		n := 4 * bounds.Dx() * bounds.Dy()
+		if cap(img.Pix) >= n {
+			pix := img.Pix[:n]
+			for i := range pix {
+				pix[i] = 0
+			}
+			return &image.NRGBA{Pix: pix, Stride: 4 * bounds.Dx(), Rect: bounds}
+		}
+	}
+	return image.NewNRGBA(bounds)
+}
+
+// releasePostImage returns a temporary image that is no longer used
+func releasePostImage(img *image.NRGBA) {
+	postImages.Put(img)
+}
+
 // BlurEffect implements a simple blur effect
 type BlurEffect struct {
 	Radius int
@@ -56,75 +122,80 @@ func (be *BlurEffect) Apply(input *image.NRGBA) *image.NRGBA {
 	output := image.NewNRGBA(bounds)
 
 	// Horizontal blur pass
-	temp := image.NewNRGBA(bounds)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			var r, g, b, a float64
-			var count float64
-
-			for dx := -be.Radius; dx <= be.Radius; dx++ {
-				nx := x + dx
-				if nx >= 0 && nx < width {
-					c := input.NRGBAAt(nx+bounds.Min.X, y+bounds.Min.Y)
-					weight := gaussian(float64(dx), float64(be.Radius)/2.0)
-					r += float64(c.R) * weight
-					g += float64(c.G) * weight
-					b += float64(c.B) * weight
-					a += float64(c.A) * weight
-					count += weight
+	temp := newPostImage(bounds)
+	defer releasePostImage(temp)
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				var r, g, b, a float64
+				var count float64
+
+				for dx := -be.Radius; dx <= be.Radius; dx++ {
+					nx := x + dx
+					if nx >= 0 && nx < width {
+						c := input.NRGBAAt(nx+bounds.Min.X, y+bounds.Min.Y)
+						weight := gaussian(float64(dx), float64(be.Radius)/2.0)
+						r += float64(c.R) * weight
+						g += float64(c.G) * weight
+						b += float64(c.B) * weight
+						a += float64(c.A) * weight
+						count += weight
+					}
 				}
-			}
 
-			if count > 0 {
-				r /= count
-				g /= count
-				b /= count
-				a /= count
-			}
+				if count > 0 {
+					r /= count
+					g /= count
+					b /= count
+					a /= count
+				}
 
-			temp.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
-				R: uint8(math.Min(255, math.Max(0, r))),
-				G: uint8(math.Min(255, math.Max(0, g))),
-				B: uint8(math.Min(255, math.Max(0, b))),
-				A: uint8(math.Min(255, math.Max(0, a))),
-			})
+				temp.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
+					R: uint8(math.Min(255, math.Max(0, r))),
+					G: uint8(math.Min(255, math.Max(0, g))),
+					B: uint8(math.Min(255, math.Max(0, b))),
+					A: uint8(math.Min(255, math.Max(0, a))),
+				})
+			}
 		}
-	}
+	})
 
 	// Vertical blur pass
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			var r, g, b, a float64
-			var count float64
-
-			for dy := -be.Radius; dy <= be.Radius; dy++ {
-				ny := y + dy
-				if ny >= 0 && ny < height {
-					c := temp.NRGBAAt(x+bounds.Min.X, ny+bounds.Min.Y)
-					weight := gaussian(float64(dy), float64(be.Radius)/2.0)
-					r += float64(c.R) * weight
-					g += float64(c.G) * weight
-					b += float64(c.B) * weight
-					a += float64(c.A) * weight
-					count += weight
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				var r, g, b, a float64
+				var count float64
+
+				for dy := -be.Radius; dy <= be.Radius; dy++ {
+					ny := y + dy
+					if ny >= 0 && ny < height {
+						c := temp.NRGBAAt(x+bounds.Min.X, ny+bounds.Min.Y)
+						weight := gaussian(float64(dy), float64(be.Radius)/2.0)
+						r += float64(c.R) * weight
+						g += float64(c.G) * weight
+						b += float64(c.B) * weight
+						a += float64(c.A) * weight
+						count += weight
+					}
 				}
-			}
 
-			if count > 0 {
-				r /= count
-				g /= count
-				b /= count
-				a /= count
-			}
+				if count > 0 {
+					r /= count
+					g /= count
+					b /= count
+					a /= count
+				}
 
-			output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
-				R: uint8(math.Min(255, math.Max(0, r))),
-				G: uint8(math.Min(255, math.Max(0, g))),
-				B: uint8(math.Min(255, math.Max(0, b))),
-				A: uint8(math.Min(255, math.Max(0, a))),
-			})
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
+					R: uint8(math.Min(255, math.Max(0, r))),
+					G: uint8(math.Min(255, math.Max(0, g))),
+					B: uint8(math.Min(255, math.Max(0, b))),
+					A: uint8(math.Min(255, math.Max(0, a))),
+				})
+			}
 		}
-	}
+	})
 
 	return output
 }
@@ -157,43 +228,49 @@ func (be *BloomEffect) Apply(input *image.NRGBA) *image.NRGBA {
 	height := bounds.Dy()
 
 	// Extract bright parts
-	bright := image.NewNRGBA(bounds)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			c := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
-			brightness := (float64(c.R) + float64(c.G) + float64(c.B)) / (3.0 * 255.0)
-			if brightness > be.Threshold {
-				bright.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, c)
-			} else {
-				bright.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{0, 0, 0, c.A})
+	bright := newPostImage(bounds)
+	defer releasePostImage(bright)
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				c := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
+				brightness := (float64(c.R) + float64(c.G) + float64(c.B)) / (3.0 * 255.0)
+				if brightness > be.Threshold {
+					bright.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, c)
+				} else {
+					bright.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{0, 0, 0, c.A})
+				}
 			}
 		}
-	}
+	})
 
 	// Blur the bright parts
 	blur := NewBlurEffect(be.BlurRadius)
 	blurred := blur.Apply(bright)
+	defer releasePostImage(blurred)
 
 	// Combine original with blurred bright parts
 	output := image.NewNRGBA(bounds)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			original := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
-			bloom := blurred.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
-
-			r := float64(original.R) + float64(bloom.R)*be.Intensity
-			g := float64(original.G) + float64(bloom.G)*be.Intensity
-			b := float64(original.B) + float64(bloom.B)*be.Intensity
-			a := float64(original.A)
-
-			output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
-				R: uint8(math.Min(255, math.Max(0, r))),
-				G: uint8(math.Min(255, math.Max(0, g))),
-				B: uint8(math.Min(255, math.Max(0, b))),
-				A: uint8(math.Min(255, math.Max(0, a))),
-			})
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				original := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
+				bloom := blurred.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
+
+				r := float64(original.R) + float64(bloom.R)*be.Intensity
+				g := float64(original.G) + float64(bloom.G)*be.Intensity
+				b := float64(original.B) + float64(bloom.B)*be.Intensity
+				a := float64(original.A)
+
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
+					R: uint8(math.Min(255, math.Max(0, r))),
+					G: uint8(math.Min(255, math.Max(0, g))),
+					B: uint8(math.Min(255, math.Max(0, b))),
+					A: uint8(math.Min(255, math.Max(0, a))),
+				})
+			}
 		}
-	}
+	})
 
 	return output
 }
@@ -220,38 +297,79 @@ func (tme *ToneMappingEffect) Apply(input *image.NRGBA) *image.NRGBA {
 
 	output := image.NewNRGBA(bounds)
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			c := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
-
-			// Convert to linear space
-			r := float64(c.R) / 255.0
-			g := float64(c.G) / 255.0
-			b := float64(c.B) / 255.0
-
-			// Apply exposure
-			r *= math.Pow(2.0, tme.Exposure)
-			g *= math.Pow(2.0, tme.Exposure)
-			b *= math.Pow(2.0, tme.Exposure)
-
-			// Reinhard tone mapping
-			r = r / (r + 1.0)
-			g = g / (g + 1.0)
-			b = b / (b + 1.0)
-
-			// Apply gamma correction
-			r = math.Pow(r, 1.0/tme.Gamma)
-			g = math.Pow(g, 1.0/tme.Gamma)
-			b = math.Pow(b, 1.0/tme.Gamma)
-
-			output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
-				R: uint8(r * 255),
-				G: uint8(g * 255),
-				B: uint8(b * 255),
-				A: c.A,
-			})
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				c := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
+
+				// Convert to linear space
+				r := float64(c.R) / 255.0
+				g := float64(c.G) / 255.0
+				b := float64(c.B) / 255.0
+
+				// Apply exposure
+				r *= math.Pow(2.0, tme.Exposure)
+				g *= math.Pow(2.0, tme.Exposure)
+				b *= math.Pow(2.0, tme.Exposure)
+
+				// Reinhard tone mapping
+				r = r / (r + 1.0)
+				g = g / (g + 1.0)
+				b = b / (b + 1.0)
+
+				// Apply gamma correction
+				r = math.Pow(r, 1.0/tme.Gamma)
+				g = math.Pow(g, 1.0/tme.Gamma)
+				b = math.Pow(b, 1.0/tme.Gamma)
+
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
+					R: uint8(r * 255),
+					G: uint8(g * 255),
+					B: uint8(b * 255),
+					A: c.A,
+				})
+			}
 		}
+	})
+
+	return output
+}
+
+// ColorSpaceEffect converts the colors of a frame between color spaces,
+// such as encoding a linear frame for display without tone mapping
+type ColorSpaceEffect struct {
+	From ColorSpace
+	To   ColorSpace
+}
+
+// NewColorSpaceEffect creates a new color space conversion effect
+func NewColorSpaceEffect(from, to ColorSpace) *ColorSpaceEffect {
+	return &ColorSpaceEffect{From: from, To: to}
+}
+
+// Apply converts the colors of the input image
+func (cse *ColorSpaceEffect) Apply(input *image.NRGBA) *image.NRGBA {
+	bounds := input.Bounds()
+	output := image.NewNRGBA(bounds)
+
+	// 8-bit channels take one of 256 values, convert each once
+	var lut [256]uint8
+	for i := range lut {
+		c := Gray(float64(i)/255).Convert(cse.From, cse.To)
+		lut[i] = uint8(Clamp(c.R, 0, 1)*255 + 0.5)
 	}
+	parallelRows(bounds.Dy(), func(y0, y1 int) {
+		for y := bounds.Min.Y + y0; y < bounds.Min.Y+y1; y++ {
+			src := input.Pix[input.PixOffset(bounds.Min.X, y):input.PixOffset(bounds.Max.X, y)]
+			dst := output.Pix[output.PixOffset(bounds.Min.X, y):output.PixOffset(bounds.Max.X, y)]
+			for i := 0; i < len(src); i += 4 {
+				dst[i] = lut[src[i]]
+				dst[i+1] = lut[src[i+1]]
+				dst[i+2] = lut[src[i+2]]
+				dst[i+3] = src[i+3]
+			}
+		}
+	})
 
 	return output
 }
@@ -278,68 +396,70 @@ func (fxaa *FXAAEffect) Apply(input *image.NRGBA) *image.NRGBA {
 
 	output := image.NewNRGBA(bounds)
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			// Sample the current pixel and its neighbors
-			rgbM := getColor(input, x, y, bounds)
-			rgbN := getColor(input, x, y-1, bounds)
-			rgbS := getColor(input, x, y+1, bounds)
-			rgbE := getColor(input, x+1, y, bounds)
-			rgbW := getColor(input, x-1, y, bounds)
-
-			// Compute local contrast
-			rgbL := minColor(rgbN, rgbW)
-			rgbU := maxColor(rgbN, rgbW)
-			rgbL = minColor(rgbL, rgbM)
-			rgbU = maxColor(rgbU, rgbM)
-
-			rgbL = minColor(rgbL, rgbS)
-			rgbU = maxColor(rgbU, rgbS)
-			rgbL = minColor(rgbL, rgbE)
-			rgbU = maxColor(rgbU, rgbE)
-
-			// Compute edge direction
-			dir := subColor(rgbS, rgbN)
-			dir = addColor(dir, subColor(rgbE, rgbW))
-
-			// Compute gradient
-			dirAbs := absColor(dir)
-			dirAbs = addColor(dirAbs, dirAbs)
-
-			// Reduce gradient
-			temp := addScalarColor(dirAbs, fxaa.ReduceMul)
-			dirAbs = maxColor(dirAbs, temp)
-
-			// Compute edge lerp
-			dirAbs = invColor(dirAbs)
-			dirAbs = mulScalarColor(dirAbs, 1.0/16.0)
-			dir = mulColor(dir, dirAbs)
-
-			// Clamp direction
-			dir = clampColor(dir, -fxaa.SpanMax, fxaa.SpanMax)
-
-			// Sample along the gradient
-			rgbA := getColorBilinear(input, float64(x)+dir.X, float64(y)+dir.Y, bounds)
-			rgbB := getColorBilinear(input, float64(x)-dir.X, float64(y)-dir.Y, bounds)
-
-			// Compute final color
-			rgbF := addColor(rgbA, rgbB)
-			rgbF = mulScalarColor(rgbF, 0.5)
-
-			// Blend with original
-			blend := dotColor(absColor(subColor(rgbF, rgbM)), 1.0)
-			blend = math.Min(1.0, blend*4.0)
-
-			rgbR := lerpColor(rgbM, rgbF, blend)
-
-			output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
-				R: uint8(math.Min(255, math.Max(0, rgbR.X*255))),
-				G: uint8(math.Min(255, math.Max(0, rgbR.Y*255))),
-				B: uint8(math.Min(255, math.Max(0, rgbR.Z*255))),
-				A: 255,
-			})
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				// Sample the current pixel and its neighbors
+				rgbM := getColor(input, x, y, bounds)
+				rgbN := getColor(input, x, y-1, bounds)
+				rgbS := getColor(input, x, y+1, bounds)
+				rgbE := getColor(input, x+1, y, bounds)
+				rgbW := getColor(input, x-1, y, bounds)
+
+				// Compute local contrast
+				rgbL := minColor(rgbN, rgbW)
+				rgbU := maxColor(rgbN, rgbW)
+				rgbL = minColor(rgbL, rgbM)
+				rgbU = maxColor(rgbU, rgbM)
+
+				rgbL = minColor(rgbL, rgbS)
+				rgbU = maxColor(rgbU, rgbS)
+				rgbL = minColor(rgbL, rgbE)
+				rgbU = maxColor(rgbU, rgbE)
+
+				// Compute edge direction
+				dir := subColor(rgbS, rgbN)
+				dir = addColor(dir, subColor(rgbE, rgbW))
+
+				// Compute gradient
+				dirAbs := absColor(dir)
+				dirAbs = addColor(dirAbs, dirAbs)
+
+				// Reduce gradient
+				temp := addScalarColor(dirAbs, fxaa.ReduceMul)
+				dirAbs = maxColor(dirAbs, temp)
+
+				// Compute edge lerp
+				dirAbs = invColor(dirAbs)
+				dirAbs = mulScalarColor(dirAbs, 1.0/16.0)
+				dir = mulColor(dir, dirAbs)
+
+				// Clamp direction
+				dir = clampColor(dir, -fxaa.SpanMax, fxaa.SpanMax)
+
+				// Sample along the gradient
+				rgbA := getColorBilinear(input, float64(x)+dir.X, float64(y)+dir.Y, bounds)
+				rgbB := getColorBilinear(input, float64(x)-dir.X, float64(y)-dir.Y, bounds)
+
+				// Compute final color
+				rgbF := addColor(rgbA, rgbB)
+				rgbF = mulScalarColor(rgbF, 0.5)
+
+				// Blend with original
+				blend := dotColor(absColor(subColor(rgbF, rgbM)), 1.0)
+				blend = math.Min(1.0, blend*4.0)
+
+				rgbR := lerpColor(rgbM, rgbF, blend)
+
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
+					R: uint8(math.Min(255, math.Max(0, rgbR.X*255))),
+					G: uint8(math.Min(255, math.Max(0, rgbR.Y*255))),
+					B: uint8(math.Min(255, math.Max(0, rgbR.Z*255))),
+					A: 255,
+				})
+			}
 		}
-	}
+	})
 
 	return output
 }
@@ -453,38 +573,40 @@ func (cae *ChromaticAberrationEffect) Apply(input *image.NRGBA) *image.NRGBA {
 
 	output := image.NewNRGBA(bounds)
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			// Sample red channel with offset
-			redX := x + int(cae.RedOffset.X)
-			redY := y + int(cae.RedOffset.Y)
-			var r uint8
-			if redX >= 0 && redX < width && redY >= 0 && redY < height {
-				r = input.NRGBAAt(redX+bounds.Min.X, redY+bounds.Min.Y).R
-			}
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				// Sample red channel with offset
+				redX := x + int(cae.RedOffset.X)
+				redY := y + int(cae.RedOffset.Y)
+				var r uint8
+				if redX >= 0 && redX < width && redY >= 0 && redY < height {
+					r = input.NRGBAAt(redX+bounds.Min.X, redY+bounds.Min.Y).R
+				}
 
-			// Sample green channel with offset
-			greenX := x + int(cae.GreenOffset.X)
-			greenY := y + int(cae.GreenOffset.Y)
-			var g uint8
-			if greenX >= 0 && greenX < width && greenY >= 0 && greenY < height {
-				g = input.NRGBAAt(greenX+bounds.Min.X, greenY+bounds.Min.Y).G
-			}
+				// Sample green channel with offset
+				greenX := x + int(cae.GreenOffset.X)
+				greenY := y + int(cae.GreenOffset.Y)
+				var g uint8
+				if greenX >= 0 && greenX < width && greenY >= 0 && greenY < height {
+					g = input.NRGBAAt(greenX+bounds.Min.X, greenY+bounds.Min.Y).G
+				}
 
-			// Sample blue channel with offset
-			blueX := x + int(cae.BlueOffset.X)
-			blueY := y + int(cae.BlueOffset.Y)
-			var b uint8
-			if blueX >= 0 && blueX < width && blueY >= 0 && blueY < height {
-				b = input.NRGBAAt(blueX+bounds.Min.X, blueY+bounds.Min.Y).B
-			}
+				// Sample blue channel with offset
+				blueX := x + int(cae.BlueOffset.X)
+				blueY := y + int(cae.BlueOffset.Y)
+				var b uint8
+				if blueX >= 0 && blueX < width && blueY >= 0 && blueY < height {
+					b = input.NRGBAAt(blueX+bounds.Min.X, blueY+bounds.Min.Y).B
+				}
 
-			// Get original alpha
-			a := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y).A
+				// Get original alpha
+				a := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y).A
 
-			output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{r, g, b, a})
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{r, g, b, a})
+			}
 		}
-	}
+	})
 
 	return output
 }
@@ -510,31 +632,33 @@ func (ve *VignetteEffect) Apply(input *image.NRGBA) *image.NRGBA {
 
 	output := image.NewNRGBA(bounds)
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			c := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
-
-			// Calculate distance from center
-			dx := float64(x) - centerX
-			dy := float64(y) - centerY
-			dist := math.Sqrt(dx*dx + dy*dy)
-
-			// Calculate vignette factor
-			factor := 1.0 - (dist/maxDist)*ve.Strength
-
-			// Apply vignette
-			r := float64(c.R) * factor
-			g := float64(c.G) * factor
-			b := float64(c.B) * factor
-
-			output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
-				R: uint8(math.Min(255, math.Max(0, r))),
-				G: uint8(math.Min(255, math.Max(0, g))),
-				B: uint8(math.Min(255, math.Max(0, b))),
-				A: c.A,
-			})
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				c := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
+
+				// Calculate distance from center
+				dx := float64(x) - centerX
+				dy := float64(y) - centerY
+				dist := math.Sqrt(dx*dx + dy*dy)
+
+				// Calculate vignette factor
+				factor := 1.0 - (dist/maxDist)*ve.Strength
+
+				// Apply vignette
+				r := float64(c.R) * factor
+				g := float64(c.G) * factor
+				b := float64(c.B) * factor
+
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
+					R: uint8(math.Min(255, math.Max(0, r))),
+					G: uint8(math.Min(255, math.Max(0, g))),
+					B: uint8(math.Min(255, math.Max(0, b))),
+					A: c.A,
+				})
+			}
 		}
-	}
+	})
 
 	return output
 }
@@ -565,50 +689,52 @@ func (cge *ColorGradingEffect) Apply(input *image.NRGBA) *image.NRGBA {
 
 	output := image.NewNRGBA(bounds)
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			c := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
-
-			// Convert to [0,1] range
-			r := float64(c.R) / 255.0
-			g := float64(c.G) / 255.0
-			b := float64(c.B) / 255.0
-
-			// Apply brightness
-			r += cge.Brightness
-			g += cge.Brightness
-			b += cge.Brightness
-
-			// Apply contrast
-			r = (r-0.5)*cge.Contrast + 0.5
-			g = (g-0.5)*cge.Contrast + 0.5
-			b = (b-0.5)*cge.Contrast + 0.5
-
-			// Apply saturation
-			lum := 0.299*r + 0.587*g + 0.114*b
-			r = lum + (r-lum)*cge.Saturation
-			g = lum + (g-lum)*cge.Saturation
-			b = lum + (b-lum)*cge.Saturation
-
-			// Apply hue shift (simplified)
-			if cge.HueShift != 0 {
-				// Simple hue rotation approximation
-				r, g, b = rotateHue(r, g, b, cge.HueShift)
-			}
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				c := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
+
+				// Convert to [0,1] range
+				r := float64(c.R) / 255.0
+				g := float64(c.G) / 255.0
+				b := float64(c.B) / 255.0
+
+				// Apply brightness
+				r += cge.Brightness
+				g += cge.Brightness
+				b += cge.Brightness
+
+				// Apply contrast
+				r = (r-0.5)*cge.Contrast + 0.5
+				g = (g-0.5)*cge.Contrast + 0.5
+				b = (b-0.5)*cge.Contrast + 0.5
+
+				// Apply saturation
+				lum := 0.299*r + 0.587*g + 0.114*b
+				r = lum + (r-lum)*cge.Saturation
+				g = lum + (g-lum)*cge.Saturation
+				b = lum + (b-lum)*cge.Saturation
+
+				// Apply hue shift (simplified)
+				if cge.HueShift != 0 {
+					// Simple hue rotation approximation
+					r, g, b = rotateHue(r, g, b, cge.HueShift)
+				}
 
-			// Clamp and convert back to [0,255] range
-			r = math.Max(0, math.Min(1, r))
-			g = math.Max(0, math.Min(1, g))
-			b = math.Max(0, math.Min(1, b))
-
-			output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
-				R: uint8(r * 255),
-				G: uint8(g * 255),
-				B: uint8(b * 255),
-				A: c.A,
-			})
+				// Clamp and convert back to [0,255] range
+				r = math.Max(0, math.Min(1, r))
+				g = math.Max(0, math.Min(1, g))
+				b = math.Max(0, math.Min(1, b))
+
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
+					R: uint8(r * 255),
+					G: uint8(g * 255),
+					B: uint8(b * 255),
+					A: c.A,
+				})
+			}
 		}
-	}
+	})
 
 	return output
 }
@@ -628,11 +754,20 @@ func rotateHue(r, g, b, hueShift float64) (float64, float64, float64) {
 	return newR, newG, newB
 }
 
-// MotionBlurEffect implements motion blur
+// MotionBlurEffect implements motion blur. With Context set it blurs every
+// pixel along its own motion, see NewVelocityMotionBlurEffect; otherwise
+// the whole frame is blurred along Angle by Length pixels.
 type MotionBlurEffect struct {
 	Angle   float64
 	Length  float64
 	Samples int
+	// Context, when set and given a VelocityBuffer, provides the motion of
+	// every pixel since the previous frame. Shutter is the fraction of that
+	// motion the blur spans, 0.5 for a 180 degree shutter, limited to
+	// MaxBlur pixels.
+	Context *Context
+	Shutter float64
+	MaxBlur float64
 }
 
 // NewMotionBlurEffect creates a new motion blur effect
@@ -646,6 +781,9 @@ func NewMotionBlurEffect(angle, length float64, samples int) *MotionBlurEffect {
 
 // Apply applies motion blur to the input image
 func (mbe *MotionBlurEffect) Apply(input *image.NRGBA) *image.NRGBA {
+	if mbe.Context != nil && len(mbe.Context.VelocityBuffer) == mbe.Context.Width*mbe.Context.Height {
+		return mbe.applyVelocity(input)
+	}
 	bounds := input.Bounds()
 	width := bounds.Dx()
 	height := bounds.Dy()
@@ -656,50 +794,67 @@ func (mbe *MotionBlurEffect) Apply(input *image.NRGBA) *image.NRGBA {
 	dx := math.Cos(mbe.Angle) * mbe.Length
 	dy := math.Sin(mbe.Angle) * mbe.Length
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			var r, g, b, a float64
-			var count float64
-
-			// Sample along the blur direction
-			for i := 0; i < mbe.Samples; i++ {
-				t := float64(i) / float64(mbe.Samples-1)
-				sampleX := float64(x) + dx*t
-				sampleY := float64(y) + dy*t
-
-				// Bilinear sampling
-				sampleColor := getColorBilinear(input, sampleX, sampleY, bounds)
-				r += sampleColor.X
-				g += sampleColor.Y
-				b += sampleColor.Z
-				a += 1.0
-				count += 1.0
-			}
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				var r, g, b, a float64
+				var count float64
+
+				// Sample along the blur direction
+				for i := 0; i < mbe.Samples; i++ {
+					t := float64(i) / float64(mbe.Samples-1)
+					sampleX := float64(x) + dx*t
+					sampleY := float64(y) + dy*t
+
+					// Bilinear sampling
+					sampleColor := getColorBilinear(input, sampleX, sampleY, bounds)
+					r += sampleColor.X
+					g += sampleColor.Y
+					b += sampleColor.Z
+					a += 1.0
+					count += 1.0
+				}
 
-			if count > 0 {
-				r /= count
-				g /= count
-				b /= count
-				a /= count
-			}
+				if count > 0 {
+					r /= count
+					g /= count
+					b /= count
+					a /= count
+				}
 
-			output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
-				R: uint8(math.Min(255, math.Max(0, r*255))),
-				G: uint8(math.Min(255, math.Max(0, g*255))),
-				B: uint8(math.Min(255, math.Max(0, b*255))),
-				A: uint8(math.Min(255, math.Max(0, a*255))),
-			})
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
+					R: uint8(math.Min(255, math.Max(0, r*255))),
+					G: uint8(math.Min(255, math.Max(0, g*255))),
+					B: uint8(math.Min(255, math.Max(0, b*255))),
+					A: uint8(math.Min(255, math.Max(0, a*255))),
+				})
+			}
 		}
-	}
+	})
 
 	return output
 }
 
-// DepthOfFieldEffect implements depth of field
+// DepthOfFieldEffect implements depth of field. With Context and Camera
+// set it blurs by the depth buffer, see NewCameraDepthOfFieldEffect;
+// otherwise it simulates depth from the distance of the rows to the middle
+// of the frame, with FocusDepth and Aperture relative to it.
 type DepthOfFieldEffect struct {
 	FocusDepth float64
 	Aperture   float64
 	Samples    int
+	// Context and Camera, when set, provide the depth buffer and the
+	// projection it was rendered with. FocusDepth is then the distance from
+	// the camera in focus and Aperture the radius in pixels of the circle of
+	// confusion of points at infinity, limited to MaxBlur.
+	Context *Context
+	Camera  *Camera
+	MaxBlur float64
+	// Bokeh shapes the blur of out of focus highlights: a disk, or a
+	// polygon of BokehBlades rotated by BokehRotation radians
+	Bokeh         BokehShape
+	BokehBlades   int
+	BokehRotation float64
 }
 
 // NewDepthOfFieldEffect creates a new depth of field effect
@@ -712,8 +867,10 @@ func NewDepthOfFieldEffect(focusDepth, aperture float64, samples int) *DepthOfFi
 }
 
 // Apply applies depth of field to the input image
-// Note: This is a simplified implementation that simulates DoF based on pixel position
 func (dof *DepthOfFieldEffect) Apply(input *image.NRGBA) *image.NRGBA {
+	if dof.Context != nil && dof.Camera != nil {
+		return dof.applyDepth(input)
+	}
 	bounds := input.Bounds()
 	width := bounds.Dx()
 	height := bounds.Dy()
@@ -723,35 +880,38 @@ func (dof *DepthOfFieldEffect) Apply(input *image.NRGBA) *image.NRGBA {
 	// Create a blurred version of the input for bokeh effect
 	blurEffect := NewBlurEffect(int(dof.Aperture * 10))
 	blurred := blurEffect.Apply(input)
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			// Simulate depth based on Y position (closer to center = in focus)
-			centerY := float64(height) / 2.0
-			distanceFromCenter := math.Abs(float64(y)-centerY) / centerY
-			depth := distanceFromCenter // Simple depth simulation
-
-			// Calculate blur amount based on distance from focus depth
-			blurAmount := math.Abs(depth-dof.FocusDepth) * dof.Aperture
-
-			// Mix original and blurred based on blur amount
-			original := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
-			blurredPixel := blurred.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
-
-			mix := math.Min(1.0, blurAmount)
-			r := float64(original.R)*(1-mix) + float64(blurredPixel.R)*mix
-			g := float64(original.G)*(1-mix) + float64(blurredPixel.G)*mix
-			b := float64(original.B)*(1-mix) + float64(blurredPixel.B)*mix
-			a := float64(original.A)*(1-mix) + float64(blurredPixel.A)*mix
-
-			output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
-				R: uint8(math.Min(255, math.Max(0, r))),
-				G: uint8(math.Min(255, math.Max(0, g))),
-				B: uint8(math.Min(255, math.Max(0, b))),
-				A: uint8(math.Min(255, math.Max(0, a))),
-			})
+	defer releasePostImage(blurred)
+
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				// Simulate depth based on Y position (closer to center = in focus)
+				centerY := float64(height) / 2.0
+				distanceFromCenter := math.Abs(float64(y)-centerY) / centerY
+				depth := distanceFromCenter // Simple depth simulation
+
+				// Calculate blur amount based on distance from focus depth
+				blurAmount := math.Abs(depth-dof.FocusDepth) * dof.Aperture
+
+				// Mix original and blurred based on blur amount
+				original := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
+				blurredPixel := blurred.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
+
+				mix := math.Min(1.0, blurAmount)
+				r := float64(original.R)*(1-mix) + float64(blurredPixel.R)*mix
+				g := float64(original.G)*(1-mix) + float64(blurredPixel.G)*mix
+				b := float64(original.B)*(1-mix) + float64(blurredPixel.B)*mix
+				a := float64(original.A)*(1-mix) + float64(blurredPixel.A)*mix
+
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
+					R: uint8(math.Min(255, math.Max(0, r))),
+					G: uint8(math.Min(255, math.Max(0, g))),
+					B: uint8(math.Min(255, math.Max(0, b))),
+					A: uint8(math.Min(255, math.Max(0, a))),
+				})
+			}
 		}
-	}
+	})
 
 	return output
 }
@@ -0,0 +1,203 @@
+package fauxgl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PreviewMode selects how many pixels of a frame PreviewRenderer shades
+type PreviewMode int
+
+const (
+	// PreviewFull - every pixel, the final output path
+	PreviewFull PreviewMode = iota
+	// PreviewHalf - one pixel in every 2x2 block, 4x fewer
+	PreviewHalf
+	// PreviewQuarter - one pixel in every 4x4 block, 16x fewer
+	PreviewQuarter
+	// PreviewCheckerboard - every other pixel in a checkerboard, 2x fewer
+	PreviewCheckerboard
+)
+
+var previewNames = map[PreviewMode]string{
+	PreviewFull:         "full",
+	PreviewHalf:         "half",
+	PreviewQuarter:      "quarter",
+	PreviewCheckerboard: "checkerboard",
+}
+
+// String returns the name of the preview mode
+func (m PreviewMode) String() string {
+	if name, ok := previewNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("PreviewMode(%d)", int(m))
+}
+
+// ParsePreviewMode parses a preview mode name (full, half, quarter or
+// checkerboard)
+func ParsePreviewMode(name string) (PreviewMode, error) {
+	for m, n := range previewNames {
+		if strings.EqualFold(n, name) {
+			return m, nil
+		}
+	}
+	return PreviewFull, fmt.Errorf("unknown preview mode: %s", name)
+}
+
+// step returns the spacing of the shaded pixels of a lattice mode, or 1
+func (m PreviewMode) step() int {
+	switch m {
+	case PreviewHalf:
+		return 2
+	case PreviewQuarter:
+		return 4
+	}
+	return 1
+}
+
+// shaded reports whether the mode shades a pixel
+func (m PreviewMode) shaded(x, y int) bool {
+	if m == PreviewCheckerboard {
+		return (x+y)%2 == 0
+	}
+	s := m.step()
+	return x%s == 0 && y%s == 0
+}
+
+// PreviewRenderer renders fast previews for interactive feedback by
+// running the fragment shader on only a subset of the pixels, see
+// PreviewMode. Every pixel is still rasterized into the depth buffer, and
+// the unshaded pixels are filled from the shaded ones around them weighted
+// by how close their depths are, so edges between surfaces stay sharp
+// instead of blurring like a plain upscale. Shading dominates the cost of
+// PBR scenes, so previews render close to as many times faster as they
+// shade fewer pixels. Final frames should use PreviewFull.
+type PreviewRenderer struct {
+	Mode PreviewMode
+	// DepthThreshold is the window depth difference at which a shaded
+	// pixel's weight drops to half; shaded pixels much farther in depth
+	// than the filled pixel, across an edge, barely contribute
+	DepthThreshold float64
+	// ShadedPixels counts the pixels shaded by the last Render
+	ShadedPixels int
+}
+
+// NewPreviewRenderer returns a preview renderer for a mode
+func NewPreviewRenderer(mode PreviewMode) *PreviewRenderer {
+	return &PreviewRenderer{
+		Mode:           mode,
+		DepthThreshold: 1e-4,
+	}
+}
+
+// Render renders a frame into dc with draw, which must draw the complete
+// frame including clearing the buffers, e.g. by calling
+// SceneRenderer.RenderScene after clearing. draw is called once. The
+// color buffer, and the HDR buffer when enabled, receive the shaded and
+// filled pixels; the depth buffer is complete.
+func (p *PreviewRenderer) Render(dc *Context, draw func()) {
+	if p.Mode == PreviewFull {
+		draw()
+		p.ShadedPixels = dc.Width * dc.Height
+		return
+	}
+
+	mask := make([]bool, dc.Width*dc.Height)
+	p.ShadedPixels = 0
+	for y := 0; y < dc.Height; y++ {
+		for x := 0; x < dc.Width; x++ {
+			if p.Mode.shaded(x, y) {
+				mask[y*dc.Width+x] = true
+				p.ShadedPixels++
+			}
+		}
+	}
+	dc.shadeMask = mask
+	draw()
+	dc.shadeMask = nil
+	p.fill(dc, mask)
+}
+
+// fill sets the covered unshaded pixels to the depth weighted average of
+// the shaded pixels around them
+func (p *PreviewRenderer) fill(dc *Context, mask []bool) {
+	w, h := dc.Width, dc.Height
+	clear := dc.clearDepth()
+	threshold := p.DepthThreshold
+	if threshold <= 0 {
+		threshold = 1e-4
+	}
+	s := p.Mode.step()
+	parallelRows(h, func(y0, y1 int) {
+		type sample struct {
+			x, y   int
+			weight float64
+		}
+		var samples [4]sample
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				z := dc.DepthBuffer[i]
+				if mask[i] || z == clear {
+					continue
+				}
+
+				// The shaded pixels around, with their bilinear weights
+				n := 0
+				if p.Mode == PreviewCheckerboard {
+					for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+						if sx, sy := x+d[0], y+d[1]; sx >= 0 && sx < w && sy >= 0 && sy < h {
+							samples[n] = sample{sx, sy, 1}
+							n++
+						}
+					}
+				} else {
+					sx0, sy0 := x/s*s, y/s*s
+					sx1, sy1 := sx0+s, sy0+s
+					fx, fy := float64(x-sx0)/float64(s), float64(y-sy0)/float64(s)
+					if sx1 >= w {
+						sx1, fx = sx0, 0
+					}
+					if sy1 >= h {
+						sy1, fy = sy0, 0
+					}
+					samples = [4]sample{
+						{sx0, sy0, (1 - fx) * (1 - fy)},
+						{sx1, sy0, fx * (1 - fy)},
+						{sx0, sy1, (1 - fx) * fy},
+						{sx1, sy1, fx * fy},
+					}
+					n = 4
+				}
+
+				var sum, hdrSum Color
+				total := 0.0
+				for _, sample := range samples[:n] {
+					j := sample.y*w + sample.x
+					sz := dc.DepthBuffer[j]
+					if sample.weight == 0 || sz == clear {
+						continue
+					}
+					dz := sz - z
+					if dz < 0 {
+						dz = -dz
+					}
+					weight := sample.weight * threshold / (threshold + dz)
+					sum = sum.Add(MakeColor(dc.ColorBuffer.NRGBAAt(sample.x, sample.y)).MulScalar(weight))
+					if dc.HDRBuffer != nil {
+						hdrSum = hdrSum.Add(dc.HDRBuffer.ColorAt(sample.x, sample.y).Premultiply().MulScalar(weight))
+					}
+					total += weight
+				}
+				if total == 0 {
+					continue
+				}
+				dc.ColorBuffer.SetNRGBA(x, y, sum.DivScalar(total).Unpremultiply().NRGBA())
+				if dc.HDRBuffer != nil {
+					dc.HDRBuffer.SetColor(x, y, hdrSum.DivScalar(total).Unpremultiply())
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package fauxgl
+
+import (
+	"math"
+	"sort"
+)
+
+// ProceduralParams configures the procedural textures, see
+// NewCheckerTexture, NewGridTexture, NewNoiseTexture, NewVoronoiTexture
+// and NewGradientTexture
+type ProceduralParams struct {
+	Resolution int // Size of the texture in pixels
+	// Scale is the number of checks, grid cells, noise features or Voronoi
+	// cells across the texture, rounded to a whole number so it tiles
+	Scale float64
+	// Color1 and Color2 are the two colors of the patterns; continuous
+	// patterns run from Color1 at 0 to Color2 at 1, or through Stops when set
+	Color1, Color2 Color
+	Stops          []GradientStop
+	Octaves        int     // Octaves of noise, each of twice the frequency and half the amplitude
+	LineWidth      float64 // Width of grid lines as a fraction of a cell
+	Seed           int64
+}
+
+// NewProceduralParams returns 256 pixel black and white textures of 8
+// cells with 4 noise octaves
+func NewProceduralParams() *ProceduralParams {
+	return &ProceduralParams{
+		Resolution: 256,
+		Scale:      8,
+		Color1:     Black,
+		Color2:     White,
+		Octaves:    4,
+		LineWidth:  0.05,
+		Seed:       1,
+	}
+}
+
+// normalized returns a copy of the parameters with usable defaults
+func (params *ProceduralParams) normalized() *ProceduralParams {
+	if params == nil {
+		params = NewProceduralParams()
+	}
+	p := *params
+	if p.Resolution <= 0 {
+		p.Resolution = 256
+	}
+	p.Scale = math.Max(math.Round(p.Scale), 1)
+	if p.Octaves <= 0 {
+		p.Octaves = 1
+	}
+	return &p
+}
+
+// ramp returns the color of the parameters at a position from 0 to 1
+func (params *ProceduralParams) ramp(t float64) Color {
+	if len(params.Stops) == 0 {
+		return params.Color1.Lerp(params.Color2, Clamp(t, 0, 1))
+	}
+	stops := append([]GradientStop(nil), params.Stops...)
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].Position < stops[j].Position
+	})
+	return gradientColor(stops, t)
+}
+
+// newProceduralTexture bakes a pattern into a tiling base color texture
+func newProceduralTexture(params *ProceduralParams, f func(u, v float64) Color) *AdvancedTexture {
+	texture := NewAdvancedTexture(bakeProceduralImage(params.Resolution, params.Resolution, f), BaseColorTexture)
+	texture.WrapS, texture.WrapT = WrapRepeat, WrapRepeat
+	return texture
+}
+
+// NewCheckerTexture creates a checkerboard of Scale by Scale checks in
+// Color1 and Color2, Color1 at the bottom left, for checking texture
+// coordinates for stretching and seams. It is magnified without filtering,
+// keeping the checks sharp. A nil params uses NewProceduralParams.
+func NewCheckerTexture(params *ProceduralParams) *AdvancedTexture {
+	p := params.normalized()
+	texture := newProceduralTexture(p, func(u, v float64) Color {
+		if (int(u*p.Scale)+int(v*p.Scale))%2 == 0 {
+			return p.Color1
+		}
+		return p.Color2
+	})
+	texture.MagFilter = FilterNearest
+	return texture
+}
+
+// NewGridTexture creates a grid of Scale by Scale cells of Color1 divided
+// by lines of Color2, LineWidth of a cell wide
+func NewGridTexture(params *ProceduralParams) *AdvancedTexture {
+	p := params.normalized()
+	half := p.LineWidth / 2
+	return newProceduralTexture(p, func(u, v float64) Color {
+		// The distance to the nearest line in cells, antialiased over a texel
+		cu, cv := u*p.Scale, v*p.Scale
+		d := math.Min(math.Abs(cu-math.Round(cu)), math.Abs(cv-math.Round(cv)))
+		texel := p.Scale / float64(p.Resolution)
+		t := Clamp((half-d)/texel+0.5, 0, 1)
+		return p.Color1.Lerp(p.Color2, t)
+	})
+}
+
+// NewNoiseTexture creates tiling Perlin gradient noise with Scale features
+// across and Octaves of detail, colored by the ramp of the parameters
+func NewNoiseTexture(params *ProceduralParams) *AdvancedTexture {
+	p := params.normalized()
+	period := int(p.Scale)
+	return newProceduralTexture(p, func(u, v float64) Color {
+		var sum, norm float64
+		amplitude := 1.0
+		for i := 0; i < p.Octaves; i++ {
+			scale := period << uint(i)
+			sum += tileablePerlinNoise(u*float64(scale), v*float64(scale), scale, p.Seed+int64(i)) * amplitude
+			norm += amplitude
+			amplitude *= 0.5
+		}
+		// Perlin noise spans about -0.7 to 0.7
+		return p.ramp(sum/norm/1.4 + 0.5)
+	})
+}
+
+// NewVoronoiTexture creates tiling Voronoi cells, Scale across with one
+// random point each, colored by the ramp of the parameters from Color1 at
+// the points to Color2 at the cell borders
+func NewVoronoiTexture(params *ProceduralParams) *AdvancedTexture {
+	p := params.normalized()
+	period := int(p.Scale)
+	return newProceduralTexture(p, func(u, v float64) Color {
+		x, y := u*p.Scale, v*p.Scale
+		cx, cy := int(math.Floor(x)), int(math.Floor(y))
+		// The distances to the nearest and second nearest points
+		f1, f2 := math.Inf(1), math.Inf(1)
+		for j := -1; j <= 1; j++ {
+			for i := -1; i <= 1; i++ {
+				ix, iy := cx+i, cy+j
+				wx, wy := ((ix%period)+period)%period, ((iy%period)+period)%period
+				px := float64(ix) + hashLattice(wx, wy, p.Seed)
+				py := float64(iy) + hashLattice(wx, wy, p.Seed+1)
+				d := math.Hypot(px-x, py-y)
+				if d < f1 {
+					f1, f2 = d, f1
+				} else if d < f2 {
+					f2 = d
+				}
+			}
+		}
+		// 0 at the point, 1 halfway to the neighboring point
+		return p.ramp(f1 / math.Max(f1+f2, 1e-9) * 2)
+	})
+}
+
+// NewGradientTexture creates a gradient along the ramp of the parameters:
+// linear from the left to the right edge of the texture, or radial from
+// its center to the middle of its edges. Scale is ignored.
+func NewGradientTexture(params *ProceduralParams, gradientType GradientType) *AdvancedTexture {
+	p := params.normalized()
+	texture := newProceduralTexture(p, func(u, v float64) Color {
+		if gradientType == RadialGradient {
+			return p.ramp(math.Hypot(u-0.5, v-0.5) * 2)
+		}
+		return p.ramp(u)
+	})
+	texture.WrapS, texture.WrapT = WrapClamp, WrapClamp
+	return texture
+}
+
+// tileablePerlinNoise is Perlin gradient noise in about -0.7 to 0.7 that
+// repeats every period units
+func tileablePerlinNoise(x, y float64, period int, seed int64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+	wrap := func(i int) int {
+		return ((i % period) + period) % period
+	}
+	// gradient returns the dot product of the random unit gradient of a
+	// lattice point with the offset to it
+	gradient := func(i, j int, dx, dy float64) float64 {
+		angle := hashLattice(wrap(i), wrap(j), seed) * 2 * math.Pi
+		return math.Cos(angle)*dx + math.Sin(angle)*dy
+	}
+	n00 := gradient(ix, iy, fx, fy)
+	n10 := gradient(ix+1, iy, fx-1, fy)
+	n01 := gradient(ix, iy+1, fx, fy-1)
+	n11 := gradient(ix+1, iy+1, fx-1, fy-1)
+
+	// Quintic fade
+	sx := fx * fx * fx * (fx*(fx*6-15) + 10)
+	sy := fy * fy * fy * (fy*(fy*6-15) + 10)
+	nx0 := n00 + (n10-n00)*sx
+	nx1 := n01 + (n11-n01)*sx
+	return nx0 + (nx1-nx0)*sy
+}
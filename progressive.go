@@ -0,0 +1,256 @@
+package fauxgl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+)
+
+// progressiveMagic identifies progressive render checkpoint files
+var progressiveMagic = [8]byte{'F', 'G', 'L', 'A', 'C', 'C', '0', '1'}
+
+// ProgressiveRenderer renders a frame as the average of many jittered
+// passes, for final frames whose quality is worth minutes or hours of
+// rendering. The accumulated sums can be checkpointed to a file while
+// rendering, so a job interrupted by a crash or a restart resumes from its
+// last checkpoint instead of starting over; the jitter sequence continues
+// where it stopped, so a resumed render equals an uninterrupted one.
+type ProgressiveRenderer struct {
+	Width, Height int
+	// HDR records whether the HDR buffer is accumulated along the color
+	// buffer; it is set by the first pass from the context
+	HDR bool
+	// Passes is the number of passes accumulated so far
+	Passes int
+	// CheckpointPath, when set, is the file Render checkpoints to
+	CheckpointPath string
+	// CheckpointInterval is the number of passes between checkpoints; the
+	// final pass is always checkpointed
+	CheckpointInterval int
+
+	color []float64 // Premultiplied RGBA sums of the color buffer
+	hdr   []float64 // Premultiplied RGBA sums of the HDR buffer
+}
+
+// NewProgressiveRenderer creates an empty progressive render of a size
+func NewProgressiveRenderer(width, height int) *ProgressiveRenderer {
+	return &ProgressiveRenderer{
+		Width:              width,
+		Height:             height,
+		CheckpointInterval: 16,
+		color:              make([]float64, 4*width*height),
+	}
+}
+
+// ResumeProgressiveRenderer continues the progressive render checkpointed
+// at path, or starts a new one checkpointing there if the file doesn't
+// exist yet. It fails if the checkpoint has a different size.
+func ResumeProgressiveRenderer(path string, width, height int) (*ProgressiveRenderer, error) {
+	p, err := LoadProgressiveCheckpoint(path)
+	if errors.Is(err, os.ErrNotExist) {
+		p = NewProgressiveRenderer(width, height)
+	} else if err != nil {
+		return nil, err
+	} else if p.Width != width || p.Height != height {
+		return nil, fmt.Errorf("checkpoint %s is %dx%d, not %dx%d", path, p.Width, p.Height, width, height)
+	}
+	p.CheckpointPath = path
+	return p, nil
+}
+
+// LoadProgressiveCheckpoint reads a progressive render checkpoint
+func LoadProgressiveCheckpoint(path string) (*ProgressiveRenderer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	p, err := readProgressiveCheckpoint(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %w", path, err)
+	}
+	return p, nil
+}
+
+func readProgressiveCheckpoint(r io.Reader) (*ProgressiveRenderer, error) {
+	var header struct {
+		Magic                 [8]byte
+		Width, Height, Passes uint32
+		HDR                   uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != progressiveMagic {
+		return nil, errors.New("not a progressive render checkpoint")
+	}
+	p := NewProgressiveRenderer(int(header.Width), int(header.Height))
+	p.Passes = int(header.Passes)
+	p.HDR = header.HDR != 0
+	if err := binary.Read(r, binary.LittleEndian, p.color); err != nil {
+		return nil, err
+	}
+	if p.HDR {
+		p.hdr = make([]float64, len(p.color))
+		if err := binary.Read(r, binary.LittleEndian, p.hdr); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// SaveCheckpoint writes the accumulated passes to a file. The file is
+// replaced atomically, so an interruption while saving keeps the previous
+// checkpoint intact.
+func (p *ProgressiveRenderer) SaveCheckpoint(path string) error {
+	temp := path + ".tmp"
+	file, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	err = p.writeCheckpoint(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+func (p *ProgressiveRenderer) writeCheckpoint(w io.Writer) error {
+	var hdr uint32
+	if p.HDR {
+		hdr = 1
+	}
+	header := []uint32{uint32(p.Width), uint32(p.Height), uint32(p.Passes), hdr}
+	if err := binary.Write(w, binary.LittleEndian, progressiveMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, p.color); err != nil {
+		return err
+	}
+	if p.HDR {
+		return binary.Write(w, binary.LittleEndian, p.hdr)
+	}
+	return nil
+}
+
+// Render accumulates passes into the render until it holds passes in
+// total, then resolves the average into dc. draw must draw the complete
+// frame including clearing the buffers, and is called once per pass with
+// the projection jittered within the pixel. With CheckpointPath set the
+// sums are saved every CheckpointInterval passes and after the last one.
+// dc must have the size of the render, and keep the HDR buffer enabled or
+// disabled across resumes.
+func (p *ProgressiveRenderer) Render(dc *Context, passes int, draw func()) error {
+	if dc.Width != p.Width || dc.Height != p.Height {
+		return fmt.Errorf("context is %dx%d, progressive render is %dx%d", dc.Width, dc.Height, p.Width, p.Height)
+	}
+	if p.Passes == 0 {
+		p.HDR = dc.HDRBuffer != nil
+	}
+	if p.HDR != (dc.HDRBuffer != nil) {
+		return errors.New("context HDR buffer doesn't match the progressive render")
+	}
+	if p.HDR && p.hdr == nil {
+		p.hdr = make([]float64, len(p.color))
+	}
+
+	screen := dc.screenMatrix
+	defer func() { dc.screenMatrix = screen }()
+	for p.Passes < passes {
+		dc.screenMatrix = Translate(jitterOffset(p.Passes)).Mul(screen)
+		draw()
+		p.accumulate(dc)
+		p.Passes++
+		if p.CheckpointPath != "" && p.CheckpointInterval > 0 && p.Passes%p.CheckpointInterval == 0 && p.Passes < passes {
+			if err := p.SaveCheckpoint(p.CheckpointPath); err != nil {
+				return err
+			}
+		}
+	}
+	if p.CheckpointPath != "" {
+		if err := p.SaveCheckpoint(p.CheckpointPath); err != nil {
+			return err
+		}
+	}
+	p.Resolve(dc)
+	return nil
+}
+
+// accumulate adds the frame rendered into dc to the sums
+func (p *ProgressiveRenderer) accumulate(dc *Context) {
+	parallelRows(p.Height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < p.Width; x++ {
+				i := 4 * (y*p.Width + x)
+				c := MakeColor(dc.ColorBuffer.NRGBAAt(x, y))
+				addSum(p.color[i:i+4], c)
+				if p.HDR {
+					addSum(p.hdr[i:i+4], dc.HDRBuffer.ColorAt(x, y).Premultiply())
+				}
+			}
+		}
+	})
+}
+
+// Resolve writes the average of the accumulated passes into the color
+// buffer of dc, and into its HDR buffer when accumulated
+func (p *ProgressiveRenderer) Resolve(dc *Context) {
+	if p.Passes == 0 {
+		return
+	}
+	for y := 0; y < p.Height; y++ {
+		for x := 0; x < p.Width; x++ {
+			i := 4 * (y*p.Width + x)
+			dc.ColorBuffer.SetNRGBA(x, y, p.average(p.color[i:i+4]).NRGBA())
+			if p.HDR && dc.HDRBuffer != nil {
+				dc.HDRBuffer.SetColor(x, y, p.average(p.hdr[i:i+4]))
+			}
+		}
+	}
+}
+
+// Image returns the average of the accumulated passes
+func (p *ProgressiveRenderer) Image() *image.NRGBA {
+	im := image.NewNRGBA(image.Rect(0, 0, p.Width, p.Height))
+	if p.Passes == 0 {
+		return im
+	}
+	for y := 0; y < p.Height; y++ {
+		for x := 0; x < p.Width; x++ {
+			i := 4 * (y*p.Width + x)
+			im.SetNRGBA(x, y, p.average(p.color[i:i+4]).NRGBA())
+		}
+	}
+	return im
+}
+
+// average returns the straight alpha mean of a premultiplied sum
+func (p *ProgressiveRenderer) average(sum []float64) Color {
+	n := float64(p.Passes)
+	return Color{sum[0] / n, sum[1] / n, sum[2] / n, sum[3] / n}.Unpremultiply()
+}
+
+func addSum(sum []float64, c Color) {
+	sum[0] += c.R
+	sum[1] += c.G
+	sum[2] += c.B
+	sum[3] += c.A
+}
@@ -0,0 +1,267 @@
+package fauxgl
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// RenderQuality selects a global quality level for the rendering pipeline
+type RenderQuality int
+
+const (
+	// QualityDraft - fastest settings for interactive iteration
+	QualityDraft RenderQuality = iota
+	// QualityPreview - balanced settings for look development
+	QualityPreview
+	// QualityProduction - highest settings for final output
+	QualityProduction
+)
+
+// QualitySettings holds the parameters controlled by a RenderQuality preset
+type QualitySettings struct {
+	Supersampling   int             // Render scale per axis, resolved with a box filter
+	ShadowMapSize   int             // Shadow map resolution
+	ShadowTechnique ShadowTechnique // Shadow filtering technique
+	PCFSize         int             // PCF kernel radius in texels
+	PCSSSamples     int             // PCSS blocker search and filter samples
+	TextureFilter   TextureFilter   // Minification and magnification filter
+	EffectSamples   int             // Samples for motion blur and depth of field
+	FXAA            bool            // Whether FXAA runs at the end of the pipeline
+	DispersionBands int             // Wavelength bands traced through dispersive gems
+}
+
+var qualityNames = map[RenderQuality]string{
+	QualityDraft:      "draft",
+	QualityPreview:    "preview",
+	QualityProduction: "production",
+}
+
+// String returns the name of the quality level
+func (q RenderQuality) String() string {
+	if name, ok := qualityNames[q]; ok {
+		return name
+	}
+	return fmt.Sprintf("RenderQuality(%d)", int(q))
+}
+
+// ParseRenderQuality parses a quality name (draft, preview or production)
+func ParseRenderQuality(name string) (RenderQuality, error) {
+	for q, n := range qualityNames {
+		if strings.EqualFold(n, name) {
+			return q, nil
+		}
+	}
+	return QualityDraft, fmt.Errorf("unknown render quality: %s", name)
+}
+
+// Settings returns the parameters of the quality preset
+func (q RenderQuality) Settings() *QualitySettings {
+	switch q {
+	case QualityDraft:
+		return &QualitySettings{
+			Supersampling:   1,
+			ShadowMapSize:   512,
+			ShadowTechnique: SimpleShadow,
+			PCFSize:         0,
+			PCSSSamples:     4,
+			TextureFilter:   FilterNearest,
+			EffectSamples:   4,
+			FXAA:            false,
+			DispersionBands: 3,
+		}
+	case QualityProduction:
+		return &QualitySettings{
+			Supersampling:   4,
+			ShadowMapSize:   4096,
+			ShadowTechnique: PCSSShadow,
+			PCFSize:         3,
+			PCSSSamples:     32,
+			TextureFilter:   FilterMipmap,
+			EffectSamples:   32,
+			FXAA:            false, // Supersampling already resolves edges
+			DispersionBands: 9,
+		}
+	default:
+		return &QualitySettings{
+			Supersampling:   2,
+			ShadowMapSize:   2048,
+			ShadowTechnique: PCFShadow,
+			PCFSize:         2,
+			PCSSSamples:     16,
+			TextureFilter:   FilterLinear,
+			EffectSamples:   12,
+			FXAA:            true,
+			DispersionBands: 5,
+		}
+	}
+}
+
+// NewContext creates a context at the supersampled resolution for an output of width x height
+func (s *QualitySettings) NewContext(width, height int) *Context {
+	context := NewContext(width, height)
+	context.SetSupersampling(s.supersampling())
+	return context
+}
+
+// Resolve downsamples the context color buffer to the output resolution
+func (s *QualitySettings) Resolve(context *Context) *image.NRGBA {
+	if context.Supersampling() > 1 {
+		return context.Resolve()
+	}
+	return DownsampleImage(context.ColorBuffer, s.supersampling())
+}
+
+func (s *QualitySettings) supersampling() int {
+	if s.Supersampling < 1 {
+		return 1
+	}
+	return s.Supersampling
+}
+
+// NewShadowMapRenderer creates a shadow map renderer using the preset size and technique
+func (s *QualitySettings) NewShadowMapRenderer(context *Context, light Light) *ShadowMapRenderer {
+	return NewShadowMapRenderer(context, s.ShadowMapSize, light, s.ShadowTechnique)
+}
+
+// ApplyToShadowReceiver sets the PCF kernel of a shadow receiver shader
+func (s *QualitySettings) ApplyToShadowReceiver(shader *ShadowReceiverShader) {
+	shader.PCFSize = s.PCFSize
+}
+
+// ApplyToPCSS sets the sample counts of a PCSS shadow receiver shader
+func (s *QualitySettings) ApplyToPCSS(shader *PCSShadowReceiverShader) {
+	s.ApplyToShadowReceiver(shader.ShadowReceiverShader)
+	shader.BlockerSearchSamples = s.PCSSSamples
+	shader.PCFSamples = s.PCSSSamples
+}
+
+// ApplyToSoftShadowReceiver sets the technique and filter size of a soft shadow receiver shader
+func (s *QualitySettings) ApplyToSoftShadowReceiver(shader *SoftShadowReceiverShader) {
+	shader.SoftShadowTechnique = s.ShadowTechnique
+	shader.FilterSize = s.PCFSize
+}
+
+// ApplyToRenderer sets the shadow map size, PCF kernel and dispersion
+// bands of a scene renderer, and the samples of its ambient occlusion
+func (s *QualitySettings) ApplyToRenderer(renderer *SceneRenderer) {
+	renderer.ShadowMapSize = s.ShadowMapSize
+	renderer.ShadowPCFSize = s.PCFSize
+	renderer.DispersionBands = s.DispersionBands
+	if renderer.AmbientOcclusion != nil {
+		renderer.AmbientOcclusion.Samples = s.EffectSamples
+	}
+}
+
+// ApplyToTexture sets the texture filtering of a texture
+func (s *QualitySettings) ApplyToTexture(texture *AdvancedTexture) {
+	texture.MinFilter = s.TextureFilter
+	texture.MagFilter = s.TextureFilter
+	if s.TextureFilter == FilterMipmap {
+		// Magnification never uses mip levels
+		texture.MagFilter = FilterLinear
+		if len(texture.MipLevels) == 0 && texture.mipmaps == nil {
+			texture.mipmaps = &lazyMipmaps{}
+		}
+	}
+}
+
+// ApplyToScene sets the texture filtering of every texture in the scene,
+// including textures referenced only by materials and UDIM tiles
+func (s *QualitySettings) ApplyToScene(scene *Scene) {
+	seen := make(map[*AdvancedTexture]bool)
+	var apply func(texture Texture)
+	apply = func(texture Texture) {
+		t, ok := texture.(*AdvancedTexture)
+		if !ok || t == nil || seen[t] {
+			return
+		}
+		seen[t] = true
+		s.ApplyToTexture(t)
+		for _, tile := range t.UDIMTiles {
+			apply(tile)
+		}
+	}
+
+	for _, texture := range scene.Textures {
+		apply(texture)
+	}
+	for _, material := range scene.Materials {
+		for _, slot := range material.textureSlots() {
+			apply(*slot)
+		}
+	}
+}
+
+// ApplyToPipeline adjusts the sample counts of post-processing effects and
+// adds or removes the final FXAA pass
+func (s *QualitySettings) ApplyToPipeline(pipeline *PostProcessingPipeline) {
+	effects := make([]PostProcessingEffect, 0, len(pipeline.Effects)+1)
+	for _, effect := range pipeline.Effects {
+		if _, ok := effect.(*FXAAEffect); ok {
+			continue
+		}
+		s.applyToEffect(effect)
+		effects = append(effects, effect)
+	}
+	if s.FXAA {
+		effects = append(effects, NewFXAAEffect())
+	}
+	pipeline.Effects = effects
+}
+
+func (s *QualitySettings) applyToEffect(effect PostProcessingEffect) {
+	switch e := effect.(type) {
+	case *MotionBlurEffect:
+		e.Samples = s.EffectSamples
+	case *DepthOfFieldEffect:
+		e.Samples = s.EffectSamples
+	case *SSAOEffect:
+		e.Samples = s.EffectSamples
+	case *CompositeEffect:
+		for _, child := range e.Effects {
+			s.applyToEffect(child)
+		}
+	}
+}
+
+// DownsampleImage reduces an image by an integer factor using a box filter.
+// Color is averaged with alpha weighting so transparent pixels don't darken edges.
+func DownsampleImage(src *image.NRGBA, factor int) *image.NRGBA {
+	if factor <= 1 {
+		return src
+	}
+
+	bounds := src.Bounds()
+	width := bounds.Dx() / factor
+	height := bounds.Dy() / factor
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var r, g, b, a float64
+			for sy := 0; sy < factor; sy++ {
+				for sx := 0; sx < factor; sx++ {
+					c := src.NRGBAAt(bounds.Min.X+x*factor+sx, bounds.Min.Y+y*factor+sy)
+					alpha := float64(c.A)
+					r += float64(c.R) * alpha
+					g += float64(c.G) * alpha
+					b += float64(c.B) * alpha
+					a += alpha
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r/a + 0.5),
+				G: uint8(g/a + 0.5),
+				B: uint8(b/a + 0.5),
+				A: uint8(a/float64(factor*factor) + 0.5),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,296 @@
+package fauxgl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	// Let image.Decode, LoadImage and the glTF loader read Radiance files
+	// into HDR images
+	image.RegisterFormat("hdr", "#?", func(r io.Reader) (image.Image, error) {
+		return DecodeHDR(r)
+	}, decodeHDRConfig)
+}
+
+// LoadHDR loads a Radiance RGBE (.hdr) image, e.g. a panorama for
+// NewEquirectangularBackground
+func LoadHDR(path string) (*HDRImage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodeHDR(file)
+}
+
+// SaveHDR saves an image as a Radiance RGBE (.hdr) file, e.g. the HDR
+// buffer of a context from Context.ResolveHDR. Alpha is dropped.
+func SaveHDR(path string, im image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := EncodeHDR(file, im); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// DecodeHDR decodes a Radiance RGBE image, flat or run length encoded
+func DecodeHDR(r io.Reader) (*HDRImage, error) {
+	br := bufio.NewReader(r)
+	width, height, bottomUp, err := readHDRHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	im := NewHDRImage(image.Rect(0, 0, width, height))
+	scanline := make([]byte, width*4)
+	for i := 0; i < height; i++ {
+		if err := readHDRScanline(br, scanline, width); err != nil {
+			return nil, fmt.Errorf("failed to read HDR scanline %d: %w", i, err)
+		}
+		y := i
+		if bottomUp {
+			y = height - 1 - i
+		}
+		for x := 0; x < width; x++ {
+			p := scanline[x*4 : x*4+4]
+			r, g, b := rgbeToFloat(p[0], p[1], p[2], p[3])
+			im.SetColor(x, y, Color{r, g, b, 1})
+		}
+	}
+	return im, nil
+}
+
+func decodeHDRConfig(r io.Reader) (image.Config, error) {
+	width, height, _, err := readHDRHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: width, Height: height}, nil
+}
+
+// readHDRHeader reads the header and resolution line of a Radiance file
+func readHDRHeader(br *bufio.Reader) (width, height int, bottomUp bool, err error) {
+	line, err := br.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "#?") {
+		return 0, 0, false, errors.New("invalid HDR file")
+	}
+	for {
+		line, err = br.ReadString('\n')
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("failed to read HDR header: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if format := strings.TrimPrefix(line, "FORMAT="); format != line && format != "32-bit_rle_rgbe" {
+			return 0, 0, false, fmt.Errorf("unsupported HDR format: %s", format)
+		}
+	}
+
+	line, err = br.ReadString('\n')
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to read HDR resolution: %w", err)
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 4 || fields[2] != "+X" || (fields[0] != "-Y" && fields[0] != "+Y") {
+		return 0, 0, false, fmt.Errorf("unsupported HDR resolution: %s", strings.TrimSpace(line))
+	}
+	height, err1 := strconv.Atoi(fields[1])
+	width, err2 := strconv.Atoi(fields[3])
+	if err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 0, 0, false, fmt.Errorf("invalid HDR resolution: %s", strings.TrimSpace(line))
+	}
+	return width, height, fields[0] == "+Y", nil
+}
+
+// readHDRScanline reads a scanline of RGBE pixels, run length encoded per
+// component, flat, or with the repeats of the original format
+func readHDRScanline(br *bufio.Reader, scanline []byte, width int) error {
+	if width >= 8 && width <= 0x7fff {
+		header, err := br.Peek(4)
+		if err != nil {
+			return err
+		}
+		if header[0] == 2 && header[1] == 2 && header[2]&0x80 == 0 {
+			if int(header[2])<<8|int(header[3]) != width {
+				return errors.New("scanline width mismatch")
+			}
+			br.Discard(4)
+			return readHDRRunLengths(br, scanline, width)
+		}
+	}
+
+	shift := uint(0)
+	for x := 0; x < width; {
+		p := scanline[x*4 : x*4+4]
+		if _, err := io.ReadFull(br, p); err != nil {
+			return err
+		}
+		if p[0] == 1 && p[1] == 1 && p[2] == 1 {
+			// Repeat the previous pixel
+			if x == 0 {
+				return errors.New("invalid run")
+			}
+			count := int(p[3]) << shift
+			if x+count > width {
+				return errors.New("run past the end of the scanline")
+			}
+			for i := 0; i < count; i++ {
+				copy(scanline[(x+i)*4:(x+i)*4+4], scanline[(x-1)*4:x*4])
+			}
+			x += count
+			shift += 8
+			continue
+		}
+		x++
+		shift = 0
+	}
+	return nil
+}
+
+// readHDRRunLengths reads the four components of a scanline, each as runs
+// and literals
+func readHDRRunLengths(br *bufio.Reader, scanline []byte, width int) error {
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			count, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			if count > 128 {
+				n := int(count) - 128
+				value, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				if x+n > width {
+					return errors.New("run past the end of the scanline")
+				}
+				for i := 0; i < n; i++ {
+					scanline[(x+i)*4+c] = value
+				}
+				x += n
+				continue
+			}
+			n := int(count)
+			if n == 0 || x+n > width {
+				return errors.New("invalid literal")
+			}
+			for i := 0; i < n; i++ {
+				value, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				scanline[(x+i)*4+c] = value
+			}
+			x += n
+		}
+	}
+	return nil
+}
+
+// EncodeHDR encodes an image as a run length encoded Radiance RGBE image.
+// 8-bit images are written as is, without linearizing them.
+func EncodeHDR(w io.Writer, im image.Image) error {
+	hdr, ok := im.(*HDRImage)
+	if !ok {
+		hdr = NewHDRImageFrom(toNRGBA(im))
+	}
+	width, height := hdr.Rect.Dx(), hdr.Rect.Dy()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", height, width)
+	scanline := make([]byte, width*4)
+	component := make([]byte, width)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := hdr.ColorAt(x+hdr.Rect.Min.X, y+hdr.Rect.Min.Y)
+			r, g, b, e := floatToRGBE(c.R, c.G, c.B)
+			scanline[x*4], scanline[x*4+1], scanline[x*4+2], scanline[x*4+3] = r, g, b, e
+		}
+		if width < 8 || width > 0x7fff {
+			bw.Write(scanline)
+			continue
+		}
+		bw.Write([]byte{2, 2, byte(width >> 8), byte(width)})
+		for c := 0; c < 4; c++ {
+			for x := range component {
+				component[x] = scanline[x*4+c]
+			}
+			writeHDRRunLengths(bw, component)
+		}
+	}
+	return bw.Flush()
+}
+
+// writeHDRRunLengths writes a component of a scanline as runs of at least
+// 4 equal bytes and literals between them
+func writeHDRRunLengths(bw *bufio.Writer, data []byte) {
+	const minRun = 4
+	n := len(data)
+	for i := 0; i < n; {
+		// Find the next run long enough to encode
+		start, run := i, 0
+		for run < minRun && start < n {
+			start += run
+			run = 1
+			for start+run < n && run < 127 && data[start+run] == data[start] {
+				run++
+			}
+		}
+		for i < start {
+			count := start - i
+			if count > 128 {
+				count = 128
+			}
+			bw.WriteByte(byte(count))
+			bw.Write(data[i : i+count])
+			i += count
+		}
+		if run >= minRun {
+			bw.WriteByte(byte(128 + run))
+			bw.WriteByte(data[start])
+			i += run
+		}
+	}
+}
+
+// rgbeToFloat decodes a shared exponent pixel
+func rgbeToFloat(r, g, b, e byte) (float64, float64, float64) {
+	if e == 0 {
+		return 0, 0, 0
+	}
+	f := math.Ldexp(1, int(e)-(128+8))
+	return (float64(r) + 0.5) * f, (float64(g) + 0.5) * f, (float64(b) + 0.5) * f
+}
+
+// floatToRGBE encodes a color as a shared exponent pixel, clamping the
+// components to the range of the format and NaN to zero
+func floatToRGBE(r, g, b float64) (byte, byte, byte, byte) {
+	clamp := func(x float64) float64 {
+		if math.IsNaN(x) {
+			return 0
+		}
+		return Clamp(x, 0, 1e38)
+	}
+	r, g, b = clamp(r), clamp(g), clamp(b)
+	v := math.Max(r, math.Max(g, b))
+	if v < 1e-32 {
+		return 0, 0, 0, 0
+	}
+	m, e := math.Frexp(v)
+	scale := m * 256 / v
+	return byte(r * scale), byte(g * scale), byte(b * scale), byte(e + 128)
+}
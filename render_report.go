@@ -0,0 +1,268 @@
+package fauxgl
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// nanColor marks the fragments shaded NaN or infinite with
+// SceneRenderer.DebugNaN
+var nanColor = Color{0, 1, 1, 1}
+
+// RenderWarning is a problem SceneRenderer found with a node of a scene,
+// or with the scene itself when Node is empty
+type RenderWarning struct {
+	Node    string
+	Problem string
+}
+
+func (w RenderWarning) String() string {
+	if w.Node == "" {
+		return w.Problem
+	}
+	return fmt.Sprintf("%s: %s", w.Node, w.Problem)
+}
+
+// RenderReport describes a frame drawn by RenderSceneWithReport. Nodes
+// with broken meshes or materials are skipped instead of failing the frame,
+// each with a warning, and drawn as placeholders when
+// SceneRenderer.Placeholders is set. Nodes with missing or broken materials
+// are drawn with the fallbacks of the scene instead when it has them, see
+// Scene.FallbackMaterial.
+type RenderReport struct {
+	Nodes        int // Nodes drawn
+	Skipped      int // Nodes skipped
+	Placeholders int // Placeholders drawn for skipped nodes
+	Fallbacks    int // Nodes drawn with Scene.FallbackMaterial or Scene.MissingTexture
+	NaNFragments int // Fragments shaded NaN or infinite, with DebugNaN
+	Warnings     []RenderWarning
+}
+
+// OK reports whether the whole scene was drawn
+func (r *RenderReport) OK() bool {
+	return len(r.Warnings) == 0
+}
+
+func (r *RenderReport) warn(node *SceneNode, format string, args ...interface{}) {
+	name := ""
+	if node != nil {
+		name = node.Name
+	}
+	r.Warnings = append(r.Warnings, RenderWarning{name, fmt.Sprintf(format, args...)})
+}
+
+// finite reports whether none of the values is NaN or infinite
+func finite(values ...float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func finiteMatrix(m Matrix) bool {
+	return finite(m.X00, m.X01, m.X02, m.X03, m.X10, m.X11, m.X12, m.X13,
+		m.X20, m.X21, m.X22, m.X23, m.X30, m.X31, m.X32, m.X33)
+}
+
+// meshCheck is the cached result of checking a mesh, valid while the mesh
+// keeps the bounding box it was checked with
+type meshCheck struct {
+	box     *Box
+	problem string
+}
+
+// checkMesh returns why a mesh can't be drawn, or ""
+func (renderer *SceneRenderer) checkMesh(mesh *Mesh) string {
+	mesh.BoundingBox()
+	if renderer.meshChecks == nil {
+		renderer.meshChecks = make(map[*Mesh]meshCheck)
+	}
+	check, ok := renderer.meshChecks[mesh]
+	if !ok {
+		check, ok = renderer.previousChecks[mesh]
+	}
+	if ok && check.box == mesh.box {
+		renderer.meshChecks[mesh] = check
+		return check.problem
+	}
+	broken := 0
+	for _, t := range mesh.Triangles {
+		for _, v := range [3]*Vertex{&t.V1, &t.V2, &t.V3} {
+			if !finite(v.Position.X, v.Position.Y, v.Position.Z, v.Normal.X, v.Normal.Y, v.Normal.Z, v.Texture.X, v.Texture.Y) {
+				broken++
+			}
+		}
+	}
+	problem := ""
+	if broken > 0 {
+		problem = fmt.Sprintf("mesh has %d vertices with NaN or infinite attributes", broken)
+	}
+	renderer.meshChecks[mesh] = meshCheck{mesh.box, problem}
+	return problem
+}
+
+// checkMaterial returns why a material can't be drawn, or ""
+func checkMaterial(material *PBRMaterial) string {
+	if material == nil {
+		return "no material"
+	}
+	c, e := material.BaseColorFactor, material.EmissiveFactor
+	if !finite(c.R, c.G, c.B, c.A, e.R, e.G, e.B, material.MetallicFactor, material.RoughnessFactor,
+		material.NormalScale, material.OcclusionStrength, material.emissiveStrength()) {
+		return "material has NaN or infinite factors"
+	}
+	for _, slot := range material.namedTextures() {
+		if texture, ok := slot.texture.(*AdvancedTexture); ok && (texture == nil || (texture.Image == nil && len(texture.UDIMTiles) == 0)) {
+			return fmt.Sprintf("%s texture has no image", slot.name)
+		}
+	}
+	return ""
+}
+
+// checkNode returns why a node can't be drawn, or ""
+func (renderer *SceneRenderer) checkNode(node *SceneNode) string {
+	for _, transform := range node.InstanceTransforms() {
+		if !finiteMatrix(transform) {
+			return "transform is NaN or infinite"
+		}
+	}
+	if problem := renderer.checkMesh(node.Mesh); problem != "" {
+		return problem
+	}
+	if problem := checkMaterial(node.Material); problem != "" {
+		return problem
+	}
+	for _, material := range node.Materials {
+		if material == nil {
+			continue
+		}
+		if problem := checkMaterial(material); problem != "" {
+			return problem
+		}
+	}
+	return ""
+}
+
+// checkNodes records a warning for every broken node, and for visible
+// meshes without a material, drawing placeholders for them when enabled,
+// and returns the nodes that can be drawn
+func (renderer *SceneRenderer) checkNodes(scene *Scene, nodes []*SceneNode, cameraMatrix Matrix) []*SceneNode {
+	report := renderer.report
+	renderer.previousChecks, renderer.meshChecks = renderer.meshChecks, nil
+	defer func() { renderer.previousChecks = nil }()
+	valid := nodes[:0:0]
+	scene.RootNode.VisitNodes(func(node *SceneNode) {
+		if node.Visible && node.Mesh != nil && node.Material == nil {
+			report.warn(node, "no material")
+			if fallback := renderer.fallbackNode(scene, node); fallback != nil {
+				valid = append(valid, fallback)
+				report.Fallbacks++
+				return
+			}
+			report.Skipped++
+			renderer.drawPlaceholder(node, cameraMatrix, renderer.checkMesh(node.Mesh) == "")
+		}
+	})
+	for _, node := range nodes {
+		problem := renderer.checkNode(node)
+		if problem == "" {
+			valid = append(valid, node)
+			continue
+		}
+		report.warn(node, "%s", problem)
+		if fallback := renderer.fallbackNode(scene, node); fallback != nil {
+			valid = append(valid, fallback)
+			report.Fallbacks++
+			continue
+		}
+		report.Skipped++
+		renderer.drawPlaceholder(node, cameraMatrix, renderer.checkMesh(node.Mesh) == "")
+	}
+	if err := renderer.checkLimits(scene, valid); err != nil {
+		report.warn(nil, "%v", err)
+		renderer.limitErr = err
+		return nil
+	}
+	return valid
+}
+
+// drawPlaceholder draws a skipped node in missingColor, unlit: its
+// mesh when it is intact, otherwise a box over its finite vertices
+func (renderer *SceneRenderer) drawPlaceholder(node *SceneNode, cameraMatrix Matrix, meshIntact bool) {
+	if !renderer.Placeholders {
+		return
+	}
+	mesh := node.Mesh
+	if !meshIntact {
+		box := EmptyBox
+		for _, t := range mesh.Triangles {
+			for _, p := range [3]Vector{t.V1.Position, t.V2.Position, t.V3.Position} {
+				if finite(p.X, p.Y, p.Z) {
+					box = box.Extend(Box{p, p})
+				}
+			}
+		}
+		if box == EmptyBox {
+			return
+		}
+		// The cube spans -1 to 1
+		mesh = NewCube()
+		mesh.Transform(Scale(box.Size().MulScalar(0.5)).Translate(box.Center()))
+	}
+	drawn := false
+	for _, transform := range node.InstanceTransforms() {
+		if !finiteMatrix(transform) {
+			continue
+		}
+		renderer.context.Shader = NewSolidColorShader(cameraMatrix.Mul(transform), missingColor)
+		renderer.context.DrawMesh(mesh)
+		drawn = true
+	}
+	if drawn {
+		renderer.report.Placeholders++
+	}
+}
+
+// drawChecked draws a node, turning a panic of its shader or mesh into a
+// warning of the report. Nodes are skipped once the render is cancelled.
+func (renderer *SceneRenderer) drawChecked(node *SceneNode, draw func(node *SceneNode)) {
+	if renderer.context.cancelled() {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			renderer.report.warn(node, "draw failed: %v", r)
+			renderer.report.Skipped++
+		}
+	}()
+	draw(node)
+	renderer.report.Nodes++
+}
+
+// nanShader draws the fragments its shader shades NaN or infinite in
+// nanColor, counting them
+type nanShader struct {
+	Shader
+	count int64
+}
+
+func (shader *nanShader) Fragment(v Vertex) Color {
+	color := shader.Shader.Fragment(v)
+	if !color.IsFinite() {
+		atomic.AddInt64(&shader.count, 1)
+		return nanColor
+	}
+	return color
+}
+
+// reportNaN warns of the NaN or infinite fragments a node was drawn with
+func (renderer *SceneRenderer) reportNaN(node *SceneNode, shader *nanShader) {
+	if shader.count == 0 || renderer.report == nil {
+		return
+	}
+	renderer.report.NaNFragments += int(shader.count)
+	renderer.report.warn(node, "%d NaN or infinite fragments", shader.count)
+}
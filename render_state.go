@@ -0,0 +1,131 @@
+package fauxgl
+
+// FillRule selects which pixels a triangle covers
+type FillRule int
+
+const (
+	// FillTopLeft - pixels whose center is inside the triangle; centers exactly
+	// on a shared edge belong to the triangle for which it is a top or left edge
+	FillTopLeft FillRule = iota
+	// FillInclusive - pixels whose center is inside or on the boundary of the
+	// triangle, so shared edges are drawn by both triangles
+	FillInclusive
+	// FillConservative - every pixel the triangle overlaps, so thin and small
+	// triangles never drop pixels
+	FillConservative
+)
+
+// DepthState controls depth testing and writing
+type DepthState struct {
+	Test  bool    // Discard fragments behind the depth buffer
+	Write bool    // Store fragment depth in the depth buffer
+	Bias  float64 // Constant offset added to fragment depth before the test
+	// SlopeBias scales the maximum depth slope of each triangle, see Context.DepthSlopeBias
+	SlopeBias float64
+}
+
+// DecalDepthState returns a depth state for geometry drawn coplanar on top of
+// existing surfaces: it tests against but doesn't write depth and is pulled
+// toward the camera by a small constant and slope scaled bias
+func DecalDepthState() DepthState {
+	return DepthState{Test: true, Write: false, Bias: -1e-5, SlopeBias: -1}
+}
+
+// BlendState controls how fragments are combined with the color buffer
+type BlendState struct {
+	Enabled    bool // Alpha blend translucent fragments over the color buffer
+	WriteColor bool // Store fragments in the color buffer
+}
+
+// CullState controls face culling
+type CullState struct {
+	Mode      Cull // Faces to discard
+	FrontFace Face // Winding of front faces
+}
+
+// RenderState is the complete fixed-function state of a draw call
+type RenderState struct {
+	Depth     DepthState
+	Blend     BlendState
+	Cull      CullState
+	Fill      FillRule
+	Centroid  bool
+	Wireframe bool
+	LineWidth float64
+}
+
+// NewRenderState returns the default state of a new Context
+func NewRenderState() *RenderState {
+	return &RenderState{
+		Depth:     DepthState{Test: true, Write: true},
+		Blend:     BlendState{Enabled: true, WriteColor: true},
+		Cull:      CullState{Mode: CullBack, FrontFace: FaceCCW},
+		LineWidth: 2,
+	}
+}
+
+// State returns the current fixed-function state of the context
+func (dc *Context) State() *RenderState {
+	return &RenderState{
+		Depth:     DepthState{Test: dc.ReadDepth, Write: dc.WriteDepth, Bias: dc.DepthBias, SlopeBias: dc.DepthSlopeBias},
+		Blend:     BlendState{Enabled: dc.AlphaBlend, WriteColor: dc.WriteColor},
+		Cull:      CullState{Mode: dc.Cull, FrontFace: dc.FrontFace},
+		Fill:      dc.FillRule,
+		Centroid:  dc.Centroid,
+		Wireframe: dc.Wireframe,
+		LineWidth: dc.LineWidth,
+	}
+}
+
+// SetState applies a fixed-function state to the context
+func (dc *Context) SetState(state *RenderState) {
+	dc.ReadDepth = state.Depth.Test
+	dc.WriteDepth = state.Depth.Write
+	dc.DepthBias = state.Depth.Bias
+	dc.DepthSlopeBias = state.Depth.SlopeBias
+	dc.AlphaBlend = state.Blend.Enabled
+	dc.WriteColor = state.Blend.WriteColor
+	dc.Cull = state.Cull.Mode
+	dc.FrontFace = state.Cull.FrontFace
+	dc.FillRule = state.Fill
+	dc.Centroid = state.Centroid
+	dc.Wireframe = state.Wireframe
+	dc.LineWidth = state.LineWidth
+}
+
+// DrawTrianglesWith draws a triangle list in immediate mode with an explicit
+// shader and state. The previous shader and state of the context are restored
+// afterwards, so custom passes can be interleaved with scene rendering
+// without leaking settings. A nil state uses the context's current state.
+// Calls must not run concurrently on the same context.
+func (dc *Context) DrawTrianglesWith(triangles []*Triangle, shader Shader, state *RenderState) RasterizeInfo {
+	previousShader := dc.Shader
+	previousState := dc.State()
+	defer func() {
+		dc.Shader = previousShader
+		dc.SetState(previousState)
+	}()
+
+	dc.Shader = shader
+	if state != nil {
+		dc.SetState(state)
+	}
+	return dc.DrawTriangles(triangles)
+}
+
+// DrawLinesWith draws a line list in immediate mode with an explicit shader
+// and state, restoring the previous shader and state afterwards
+func (dc *Context) DrawLinesWith(lines []*Line, shader Shader, state *RenderState) RasterizeInfo {
+	previousShader := dc.Shader
+	previousState := dc.State()
+	defer func() {
+		dc.Shader = previousShader
+		dc.SetState(previousState)
+	}()
+
+	dc.Shader = shader
+	if state != nil {
+		dc.SetState(state)
+	}
+	return dc.DrawLines(lines)
+}
@@ -14,6 +14,23 @@ type Scene struct {
 	Extensions   *ExtensionRegistry       // GLTF extensions support
 	ActiveCamera *Camera
 	Name         string
+	Generator    string // Authoring tool of a loaded file, from the glTF asset metadata
+	// OnTransformChanged, when set, is called by UpdateTransforms for each
+	// node whose world transform it recomputed, e.g. to invalidate caches
+	// derived from node positions
+	OnTransformChanged func(node *SceneNode)
+	// FallbackMaterial, when set, draws the mesh nodes without a material
+	// or with a broken one instead of skipping them, e.g. a material from
+	// NewFallbackMaterial, so broken assets stand out in renders
+	FallbackMaterial *PBRMaterial
+	// MissingTexture, when set, replaces the textures of materials that
+	// have no image, e.g. NewMissingTexture
+	MissingTexture *AdvancedTexture
+	// Background, when set, is drawn by the scene renderers behind the
+	// geometry instead of the cleared color buffer
+	Background *Background
+
+	bvh *SceneBVH // See BVH
 }
 
 // NewScene creates a new empty scene
@@ -41,12 +58,40 @@ type SceneNode struct {
 	Parent         *SceneNode
 	Children       []*SceneNode
 	Mesh           *Mesh
+	LODs           []*Mesh // Lower detail versions of Mesh, most detailed first
+	// LODCoverage holds the screen coverage of each LOD below which it is
+	// drawn instead of Mesh, decreasing; see LODMesh
+	LODCoverage    []float64
 	Material       *PBRMaterial
 	Skin           *Skin         // Skinned mesh support
+	BindMesh       *Mesh         // Undeformed mesh of a skinned or morphed node, see ApplySkin
 	MorphTargets   *MorphTargets // Morph target support
 	Visible        bool
 	CastShadows    bool
 	ReceiveShadows bool
+	// Grade, when set, adjusts the shaded colors of the node
+	Grade *ColorGrade
+	// Materials holds the material of each ID of Mesh.MaterialIDs, for
+	// meshes mixing materials; triangles with IDs out of range use Material
+	Materials []*PBRMaterial
+	// Modifier deforms the vertices of Mesh as they are drawn, at the Time
+	// of the SceneRenderer, without changing the mesh
+	Modifier VertexModifier
+	// Instances draws Mesh once per transform, each relative to the node,
+	// instead of once at the node, as loaded from EXT_mesh_gpu_instancing.
+	// The copies share the mesh, see InstanceTransforms.
+	Instances []Matrix
+	// Gizmos draws debug gizmos over the node, such as its bounds and axes
+	Gizmos GizmoFlags
+	// Labels annotate the node with text facing the camera
+	Labels []*Label
+
+	dirty         bool // LocalTransform changed since WorldTransform was computed
+	dirtyChildren bool // A descendant is dirty
+	bounds        *Box // Cached world bounds of Mesh, see worldBounds
+	boundsMesh    *Box // Mesh bounds the cached world bounds were computed from
+
+	source *SceneNode // Node a per-frame copy was made from, see sourceNode
 }
 
 // NewSceneNode creates a new scene node
@@ -62,7 +107,8 @@ func NewSceneNode(name string) *SceneNode {
 	}
 }
 
-// AddChild adds a child node to this node
+// AddChild adds a child node to this node. The world transforms of the
+// child's subtree are updated by the next Scene.UpdateTransforms.
 func (node *SceneNode) AddChild(child *SceneNode) {
 	if child == nil {
 		return
@@ -76,9 +122,7 @@ func (node *SceneNode) AddChild(child *SceneNode) {
 	// Add to this node
 	child.Parent = node
 	node.Children = append(node.Children, child)
-
-	// Update transforms
-	child.UpdateWorldTransform()
+	child.invalidate()
 }
 
 // RemoveChild removes a child node from this node
@@ -87,47 +131,94 @@ func (node *SceneNode) RemoveChild(child *SceneNode) {
 		if c == child {
 			node.Children = append(node.Children[:i], node.Children[i+1:]...)
 			child.Parent = nil
+			child.invalidate()
 			break
 		}
 	}
 }
 
-// UpdateWorldTransform updates the world transform based on parent transforms
+// UpdateWorldTransform recomputes the world transforms of the node and its
+// whole subtree from the parent's world transform. Scene.UpdateTransforms
+// recomputes only the changed subtrees of a scene.
 func (node *SceneNode) UpdateWorldTransform() {
+	node.updateWorldTransform(nil)
+}
+
+func (node *SceneNode) updateWorldTransform(changed func(*SceneNode)) {
 	if node.Parent != nil {
 		node.WorldTransform = node.Parent.WorldTransform.Mul(node.LocalTransform)
 	} else {
 		node.WorldTransform = node.LocalTransform
 	}
+	node.dirty = false
+	node.dirtyChildren = false
+	node.bounds = nil
+	if changed != nil {
+		changed(node)
+	}
 
 	// Update all children
 	for _, child := range node.Children {
-		child.UpdateWorldTransform()
+		child.updateWorldTransform(changed)
+	}
+}
+
+// invalidate marks the world transform of the node out of date, and flags
+// its ancestors so UpdateTransforms finds it
+func (node *SceneNode) invalidate() {
+	node.dirty = true
+	for parent := node.Parent; parent != nil && !parent.dirtyChildren; parent = parent.Parent {
+		parent.dirtyChildren = true
 	}
 }
 
-// SetTransform sets the local transform of the node
+// SetTransform sets the local transform of the node. Like the other
+// transform setters it only marks the node changed; the world transforms
+// are recomputed once for all changes by Scene.UpdateTransforms, which the
+// renderers call before drawing.
 func (node *SceneNode) SetTransform(transform Matrix) {
 	node.LocalTransform = transform
-	node.UpdateWorldTransform()
+	node.invalidate()
 }
 
 // Translate translates the node by the given vector
 func (node *SceneNode) Translate(translation Vector) {
 	node.LocalTransform = node.LocalTransform.Translate(translation)
-	node.UpdateWorldTransform()
+	node.invalidate()
 }
 
 // Rotate rotates the node around the given axis by the given angle
 func (node *SceneNode) Rotate(axis Vector, angle float64) {
 	node.LocalTransform = node.LocalTransform.Rotate(axis, angle)
-	node.UpdateWorldTransform()
+	node.invalidate()
 }
 
 // Scale scales the node by the given factors
 func (node *SceneNode) Scale(scale Vector) {
 	node.LocalTransform = node.LocalTransform.Scale(scale)
-	node.UpdateWorldTransform()
+	node.invalidate()
+}
+
+// UpdateTransforms recomputes the world transforms of the nodes changed
+// since the last update, and of their descendants, visiting only the
+// branches holding changes. Call it once per frame after animating, before
+// reading WorldTransform; the renderers call it themselves.
+func (scene *Scene) UpdateTransforms() {
+	scene.RootNode.updateTransforms(scene.OnTransformChanged)
+}
+
+func (node *SceneNode) updateTransforms(changed func(*SceneNode)) {
+	if node.dirty {
+		node.updateWorldTransform(changed)
+		return
+	}
+	if !node.dirtyChildren {
+		return
+	}
+	node.dirtyChildren = false
+	for _, child := range node.Children {
+		child.updateTransforms(changed)
+	}
 }
 
 // GetWorldPosition returns the world position of the node
@@ -171,16 +262,46 @@ func (node *SceneNode) FindChild(name string) *SceneNode {
 	return nil
 }
 
+// InstanceTransforms returns the world transforms the mesh of a node is
+// drawn with: its world transform, or one per instance
+func (node *SceneNode) InstanceTransforms() []Matrix {
+	if len(node.Instances) == 0 {
+		return []Matrix{node.WorldTransform}
+	}
+	transforms := make([]Matrix, len(node.Instances))
+	for i, instance := range node.Instances {
+		transforms[i] = node.WorldTransform.Mul(instance)
+	}
+	return transforms
+}
+
+// worldBounds returns the world space bounds of the mesh of a node and all
+// its instances. The bounds of nodes without instances are cached until the
+// world transform or the mesh changes.
+func (node *SceneNode) worldBounds() Box {
+	meshBounds := node.Mesh.BoundingBox()
+	if len(node.Instances) == 0 {
+		if node.bounds == nil || node.boundsMesh != node.Mesh.box {
+			bounds := node.WorldTransform.MulBox(meshBounds)
+			node.bounds, node.boundsMesh = &bounds, node.Mesh.box
+		}
+		return *node.bounds
+	}
+	bounds := EmptyBox
+	for _, transform := range node.InstanceTransforms() {
+		bounds = bounds.Extend(transform.MulBox(meshBounds))
+	}
+	return bounds
+}
+
 // SceneBounds calculates the bounding box of the entire scene
 func (scene *Scene) GetBounds() Box {
+	scene.UpdateTransforms()
 	bounds := EmptyBox
 
 	scene.RootNode.VisitNodes(func(node *SceneNode) {
 		if node.Mesh != nil {
-			// Transform mesh bounds to world space
-			meshBounds := node.Mesh.BoundingBox()
-			worldBounds := node.WorldTransform.MulBox(meshBounds)
-			bounds = bounds.Extend(worldBounds)
+			bounds = bounds.Extend(node.worldBounds())
 		}
 	})
 
@@ -374,24 +495,20 @@ func (scene *Scene) CreateMorphTargetMeshNode(name, meshName, materialName, morp
 
 // UpdateSkinnedMeshes updates all skinned meshes in the scene
 func (scene *Scene) UpdateSkinnedMeshes() {
+	scene.UpdateTransforms()
 	scene.RootNode.VisitNodes(func(node *SceneNode) {
 		if node.Skin != nil && node.Mesh != nil {
-			// Update joint matrices
-			node.Skin.UpdateJointMatrices()
-
-			// Apply skinning (would need joint matrices array)
-			// This is a placeholder for the actual skinning implementation
+			node.ApplySkin()
 		}
 	})
 }
 
-// ApplyMorphTargetsToMeshes applies morph targets to all relevant meshes in the scene
+// ApplyMorphTargetsToMeshes deforms the meshes of all nodes with morph
+// targets by their current weights. Skinned nodes are morphed by ApplySkin.
 func (scene *Scene) ApplyMorphTargetsToMeshes() {
 	scene.RootNode.VisitNodes(func(node *SceneNode) {
-		if node.MorphTargets != nil && node.Mesh != nil {
-			// Apply morph target deformation
-			deformedMesh := ApplyMorphTargets(node.Mesh, node.MorphTargets)
-			node.Mesh = deformedMesh
+		if node.MorphTargets != nil && node.Mesh != nil && node.Skin == nil {
+			node.ApplyMorph()
 		}
 	})
 }
@@ -0,0 +1,383 @@
+package fauxgl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// SceneDump is a plain description of a scene for inspection: what a
+// loader produced, or what a test expects. Resources are referenced by
+// their names in the scene's maps; unregistered textures are referenced by
+// their Go type in parentheses. Map keys are sorted by encoding/json, so
+// the dump of a scene is stable.
+type SceneDump struct {
+	Name       string                  `json:"name"`
+	Generator  string                  `json:"generator,omitempty"`
+	Root       NodeDump                `json:"root"`
+	Meshes     map[string]MeshDump     `json:"meshes"`
+	Materials  map[string]MaterialDump `json:"materials"`
+	Textures   map[string]TextureDump  `json:"textures"`
+	Cameras    []CameraDump            `json:"cameras"`
+	Lights     []LightDump             `json:"lights"`
+	Animations []AnimationDump         `json:"animations"`
+}
+
+// NodeDump describes a scene node and its children. Matrices are listed
+// row by row, vectors as [x, y, z] and colors as [r, g, b, a].
+type NodeDump struct {
+	Name           string      `json:"name"`
+	Local          [16]float64 `json:"local"`
+	World          [16]float64 `json:"world"`
+	Visible        bool        `json:"visible"`
+	CastShadows    bool        `json:"cast_shadows"`
+	ReceiveShadows bool        `json:"receive_shadows"`
+	Mesh           *MeshDump   `json:"mesh,omitempty"`
+	Material       string      `json:"material,omitempty"`
+	Materials      []string    `json:"materials,omitempty"` // Per material ID
+	LODs           []MeshDump  `json:"lods,omitempty"`
+	Skin           string      `json:"skin,omitempty"`
+	MorphTargets   int         `json:"morph_targets,omitempty"`
+	Instances      int         `json:"instances,omitempty"`
+	Modifier       bool        `json:"modifier,omitempty"`
+	Grade          bool        `json:"grade,omitempty"`
+	Children       []NodeDump  `json:"children"`
+}
+
+// MeshDump holds the statistics of a mesh
+type MeshDump struct {
+	Name        string        `json:"name,omitempty"`
+	Triangles   int           `json:"triangles"`
+	Lines       int           `json:"lines"`
+	Bounds      [2][3]float64 `json:"bounds"` // Min and max corners
+	MaterialIDs bool          `json:"material_ids"`
+}
+
+// MaterialDump holds the factors of a material and the textures of its
+// slots, keyed by slot
+type MaterialDump struct {
+	Workflow    string            `json:"workflow"`
+	BaseColor   [4]float64        `json:"base_color"`
+	Metallic    float64           `json:"metallic"`
+	Roughness   float64           `json:"roughness"`
+	Emissive    [4]float64        `json:"emissive"`
+	AlphaMode   string            `json:"alpha_mode"`
+	AlphaCutoff float64           `json:"alpha_cutoff"`
+	DoubleSided bool              `json:"double_sided"`
+	Textures    map[string]string `json:"textures,omitempty"`
+}
+
+// TextureDump holds the size and sampling of a texture
+type TextureDump struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Type   string `json:"type"`
+	Mips   int    `json:"mips"`
+	WrapS  string `json:"wrap_s"`
+	WrapT  string `json:"wrap_t"`
+}
+
+// CameraDump describes a camera; Far is 0 for an infinite far plane
+type CameraDump struct {
+	Name       string     `json:"name"`
+	Projection string     `json:"projection"`
+	Position   [3]float64 `json:"position"`
+	Target     [3]float64 `json:"target"`
+	Up         [3]float64 `json:"up"`
+	FOV        float64    `json:"fov"`
+	OrthoSize  float64    `json:"ortho_size,omitempty"`
+	Near       float64    `json:"near"`
+	Far        float64    `json:"far"`
+	Active     bool       `json:"active"`
+}
+
+// LightDump describes a light
+type LightDump struct {
+	Type      string     `json:"type"`
+	Position  [3]float64 `json:"position"`
+	Direction [3]float64 `json:"direction"`
+	Color     [4]float64 `json:"color"`
+	Intensity float64    `json:"intensity"`
+	Range     float64    `json:"range,omitempty"`
+	Shadow    bool       `json:"shadow"`
+}
+
+// AnimationDump describes an animation and the nodes and properties it
+// animates
+type AnimationDump struct {
+	Name     string   `json:"name"`
+	Duration float64  `json:"duration"`
+	Channels []string `json:"channels"` // "node.property", in channel order
+}
+
+var (
+	alphaModeNames   = []string{"opaque", "mask", "blend"}
+	workflowNames    = []string{"metallic_roughness", "specular_glossiness"}
+	lightTypeNames   = []string{"directional", "point", "spot", "ambient"}
+	projectionNames  = []string{"perspective", "orthographic"}
+	textureWrapNames = []string{"repeat", "clamp", "mirror", "border"}
+	textureTypeNames = []string{"base_color", "normal", "metallic", "roughness", "occlusion", "emissive", "height"}
+	propertyNames    = []string{"translation", "rotation", "scale", "weights", "joints", "texture_offset", "texture_rotation"}
+)
+
+// enumName returns the name of an enum value, or its number when unnamed
+func enumName(names []string, value int) string {
+	if value >= 0 && value < len(names) {
+		return names[value]
+	}
+	return fmt.Sprint(value)
+}
+
+// DumpJSON writes the hierarchy, resources, cameras, lights and animations
+// of the scene as indented JSON, see SceneDump
+func (scene *Scene) DumpJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(scene.Dump())
+}
+
+// Dump describes the scene, updating its transforms first
+func (scene *Scene) Dump() SceneDump {
+	scene.UpdateTransforms()
+	meshNames := make(map[*Mesh]string, len(scene.Meshes))
+	for name, mesh := range scene.Meshes {
+		meshNames[mesh] = name
+	}
+	materialNames := make(map[*PBRMaterial]string, len(scene.Materials))
+	for name, material := range scene.Materials {
+		materialNames[material] = name
+	}
+	textureNames := make(map[*AdvancedTexture]string, len(scene.Textures))
+	for name, texture := range scene.Textures {
+		textureNames[texture] = name
+	}
+	skinNames := make(map[*Skin]string, len(scene.Skins))
+	for name, skin := range scene.Skins {
+		skinNames[skin] = name
+	}
+
+	dump := SceneDump{
+		Name:       scene.Name,
+		Generator:  scene.Generator,
+		Meshes:     make(map[string]MeshDump, len(scene.Meshes)),
+		Materials:  make(map[string]MaterialDump, len(scene.Materials)),
+		Textures:   make(map[string]TextureDump, len(scene.Textures)),
+		Cameras:    make([]CameraDump, 0, len(scene.Cameras)),
+		Lights:     make([]LightDump, 0, len(scene.Lights)),
+		Animations: make([]AnimationDump, 0, len(scene.Animations)),
+	}
+	for name, mesh := range scene.Meshes {
+		dump.Meshes[name] = dumpMesh(mesh, name)
+	}
+	for name, material := range scene.Materials {
+		dump.Materials[name] = dumpMaterial(material, textureNames)
+	}
+	for name, texture := range scene.Textures {
+		dump.Textures[name] = TextureDump{
+			Width:  texture.Width,
+			Height: texture.Height,
+			Type:   enumName(textureTypeNames, int(texture.Type)),
+			Mips:   len(texture.MipLevels),
+			WrapS:  enumName(textureWrapNames, int(texture.WrapS)),
+			WrapT:  enumName(textureWrapNames, int(texture.WrapT)),
+		}
+	}
+
+	var dumpNode func(node *SceneNode) NodeDump
+	dumpNode = func(node *SceneNode) NodeDump {
+		d := NodeDump{
+			Name:           node.Name,
+			Local:          matrixRows(node.LocalTransform),
+			World:          matrixRows(node.WorldTransform),
+			Visible:        node.Visible,
+			CastShadows:    node.CastShadows,
+			ReceiveShadows: node.ReceiveShadows,
+			Instances:      len(node.Instances),
+			Modifier:       node.Modifier != nil,
+			Grade:          node.Grade != nil,
+			Children:       make([]NodeDump, 0, len(node.Children)),
+		}
+		if node.Mesh != nil {
+			mesh := dumpMesh(node.Mesh, meshNames[node.Mesh])
+			d.Mesh = &mesh
+		}
+		if node.Material != nil {
+			d.Material = materialName(node.Material, materialNames)
+		}
+		for _, material := range node.Materials {
+			d.Materials = append(d.Materials, materialName(material, materialNames))
+		}
+		for _, lod := range node.LODs {
+			d.LODs = append(d.LODs, dumpMesh(lod, meshNames[lod]))
+		}
+		if node.Skin != nil {
+			d.Skin = skinNames[node.Skin]
+			if d.Skin == "" {
+				d.Skin = node.Skin.Name
+			}
+		}
+		if node.MorphTargets != nil {
+			d.MorphTargets = len(node.MorphTargets.Targets)
+		}
+		for _, child := range node.Children {
+			d.Children = append(d.Children, dumpNode(child))
+		}
+		return d
+	}
+	dump.Root = dumpNode(scene.RootNode)
+
+	for _, camera := range scene.Cameras {
+		far := camera.FarPlane
+		if math.IsInf(far, 0) {
+			far = 0
+		}
+		dump.Cameras = append(dump.Cameras, CameraDump{
+			Name:       camera.Name,
+			Projection: enumName(projectionNames, int(camera.ProjectionType)),
+			Position:   vectorArray(camera.Position),
+			Target:     vectorArray(camera.Target),
+			Up:         vectorArray(camera.Up),
+			FOV:        camera.FOV,
+			OrthoSize:  camera.OrthoSize,
+			Near:       camera.NearPlane,
+			Far:        far,
+			Active:     camera == scene.ActiveCamera,
+		})
+	}
+	for _, light := range scene.Lights {
+		dump.Lights = append(dump.Lights, LightDump{
+			Type:      enumName(lightTypeNames, int(light.Type)),
+			Position:  vectorArray(light.Position),
+			Direction: vectorArray(light.Direction),
+			Color:     colorArray(light.Color),
+			Intensity: light.Intensity,
+			Range:     light.Range,
+			Shadow:    light.Shadow != nil,
+		})
+	}
+	names := make([]string, 0, len(scene.Animations))
+	for name := range scene.Animations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		animation := scene.Animations[name]
+		d := AnimationDump{Name: name, Duration: animation.Duration, Channels: make([]string, 0, len(animation.Channels))}
+		for _, channel := range animation.Channels {
+			target := ""
+			if channel.Target != nil {
+				target = channel.Target.Name
+			}
+			d.Channels = append(d.Channels, target+"."+enumName(propertyNames, int(channel.Property)))
+		}
+		dump.Animations = append(dump.Animations, d)
+	}
+	return dump
+}
+
+// dumpMesh returns the statistics of a mesh
+func dumpMesh(mesh *Mesh, name string) MeshDump {
+	box := mesh.BoundingBox()
+	if len(mesh.Triangles) == 0 && len(mesh.Lines) == 0 {
+		box = Box{}
+	}
+	return MeshDump{
+		Name:        name,
+		Triangles:   len(mesh.Triangles),
+		Lines:       len(mesh.Lines),
+		Bounds:      [2][3]float64{vectorArray(box.Min), vectorArray(box.Max)},
+		MaterialIDs: mesh.hasMaterialIDs(),
+	}
+}
+
+// dumpMaterial returns the factors and texture references of a material
+func dumpMaterial(material *PBRMaterial, textureNames map[*AdvancedTexture]string) MaterialDump {
+	d := MaterialDump{
+		Workflow:    enumName(workflowNames, int(material.Workflow)),
+		BaseColor:   colorArray(material.BaseColorFactor),
+		Metallic:    material.MetallicFactor,
+		Roughness:   material.RoughnessFactor,
+		Emissive:    colorArray(material.EmissiveFactor),
+		AlphaMode:   enumName(alphaModeNames, int(material.AlphaMode)),
+		AlphaCutoff: material.AlphaCutoff,
+		DoubleSided: material.DoubleSided,
+	}
+	for _, slot := range material.namedTextures() {
+		if slot.texture == nil {
+			continue
+		}
+		if d.Textures == nil {
+			d.Textures = make(map[string]string)
+		}
+		texture, _ := slot.texture.(*AdvancedTexture)
+		name, ok := textureNames[texture]
+		if !ok || texture == nil {
+			name = fmt.Sprintf("(%T)", slot.texture)
+		}
+		d.Textures[slot.name] = name
+	}
+	return d
+}
+
+// textureSlot is a texture field of a material and its name in dumps and
+// render reports
+type textureSlot struct {
+	name    string
+	texture Texture
+}
+
+// namedTextures returns the texture fields of a material with their names
+func (material *PBRMaterial) namedTextures() []textureSlot {
+	return []textureSlot{
+		{"base_color", material.BaseColorTexture},
+		{"metallic_roughness", material.MetallicRoughnessTexture},
+		{"diffuse", material.DiffuseTexture},
+		{"specular_glossiness", material.SpecularGlossinessTexture},
+		{"normal", material.NormalTexture},
+		{"occlusion", material.OcclusionTexture},
+		{"emissive", material.EmissiveTexture},
+		{"height", material.HeightTexture},
+		{"specular", material.SpecularTexture},
+		{"specular_color", material.SpecularColorTexture},
+		{"transmission", material.TransmissionTexture},
+		{"thickness", material.ThicknessTexture},
+		{"anisotropy", material.AnisotropyTexture},
+		{"sheen_color", material.SheenColorTexture},
+		{"sheen_roughness", material.SheenRoughnessTexture},
+		{"iridescence", material.IridescenceTexture},
+		{"iridescence_thickness", material.IridescenceThicknessTexture},
+		{"clearcoat", material.ClearcoatTexture},
+		{"clearcoat_roughness", material.ClearcoatRoughnessTexture},
+		{"clearcoat_normal", material.ClearcoatNormalTexture},
+		{"subsurface_thickness", material.SubsurfaceThicknessTexture},
+	}
+}
+
+// materialName returns the name of a registered material, or "" for
+// materials not in the scene's map
+func materialName(material *PBRMaterial, names map[*PBRMaterial]string) string {
+	if material == nil {
+		return ""
+	}
+	return names[material]
+}
+
+// matrixRows returns the elements of a matrix row by row
+func matrixRows(m Matrix) [16]float64 {
+	return [16]float64{
+		m.X00, m.X01, m.X02, m.X03,
+		m.X10, m.X11, m.X12, m.X13,
+		m.X20, m.X21, m.X22, m.X23,
+		m.X30, m.X31, m.X32, m.X33,
+	}
+}
+
+func vectorArray(v Vector) [3]float64 {
+	return [3]float64{v.X, v.Y, v.Z}
+}
+
+func colorArray(c Color) [4]float64 {
+	return [4]float64{c.R, c.G, c.B, c.A}
+}
@@ -0,0 +1,179 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+)
+
+// SelectionOutline highlights selected scene nodes in a rendered frame with
+// an image-space outline and an optional glow, as used by editors and
+// configurators. The selected nodes are rendered into an ID buffer and the
+// outline is drawn around the pixels they cover.
+type SelectionOutline struct {
+	// Nodes are the selected nodes; selecting a node selects its descendants
+	Nodes []*SceneNode
+	Color Color   // Outline color, its alpha is the outline opacity
+	Width float64 // Outline thickness in pixels
+	// Glow extends a soft halo of GlowStrength opacity this many pixels
+	// beyond the outline, 0 disables it
+	Glow         float64
+	GlowStrength float64
+	// ShowHidden outlines the selection through other geometry instead of
+	// only around its visible parts
+	ShowHidden bool
+}
+
+// NewSelectionOutline creates an orange two pixel outline around the nodes
+func NewSelectionOutline(nodes ...*SceneNode) *SelectionOutline {
+	return &SelectionOutline{
+		Nodes:        nodes,
+		Color:        Color{1, 0.6, 0.1, 1},
+		Width:        2,
+		GlowStrength: 0.5,
+	}
+}
+
+// Render outlines the selection over frame, a render of scene from its
+// active camera with the same size
+func (s *SelectionOutline) Render(scene *Scene, frame *image.NRGBA) {
+	b := frame.Bounds()
+	ids := s.RenderIDs(scene, b.Dx(), b.Dy())
+	s.Apply(frame, ids)
+}
+
+// RenderIDs renders the ID buffer of the selection from the scene's active
+// camera: 0 where no selected node is visible, otherwise one plus the index
+// in Nodes of the selected node covering the pixel
+func (s *SelectionOutline) RenderIDs(scene *Scene, width, height int) []int {
+	ids := make([]int, width*height)
+	if scene.ActiveCamera == nil || len(s.Nodes) == 0 {
+		return ids
+	}
+	scene.UpdateTransforms()
+
+	// Selected mesh nodes by ID
+	selected := make(map[*SceneNode]int)
+	for i, node := range s.Nodes {
+		if node == nil {
+			continue
+		}
+		id := i + 1
+		node.VisitNodes(func(n *SceneNode) {
+			if _, ok := selected[n]; !ok {
+				selected[n] = id
+			}
+		})
+	}
+
+	dc := NewContext(width, height)
+	dc.SetDepthMode(scene.ActiveCamera.DepthMode)
+	dc.AlphaBlend = false
+	cameraMatrix := scene.ActiveCamera.GetProjectionMatrix().Mul(scene.ActiveCamera.GetViewMatrix())
+	for _, node := range scene.RootNode.GetRenderableNodes() {
+		id, ok := selected[node]
+		if !ok && s.ShowHidden {
+			continue
+		}
+		// IDs are stored in the red and green bytes, other nodes only
+		// occlude the selection
+		c := Color{(float64(id&0xff) + 0.5) / 255, (float64(id>>8&0xff) + 0.5) / 255, 0, 1}
+		if node.Material != nil && node.Material.DoubleSided {
+			dc.Cull = CullNone
+		} else {
+			dc.Cull = CullBack
+		}
+		for _, transform := range node.InstanceTransforms() {
+			dc.Shader = NewSolidColorShader(cameraMatrix.Mul(transform), c)
+			dc.DrawMesh(node.Mesh)
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		row := dc.ColorBuffer.Pix[dc.ColorBuffer.PixOffset(0, y):]
+		for x := 0; x < width; x++ {
+			p := row[x*4:]
+			if p[3] != 0 {
+				ids[y*width+x] = int(p[0]) | int(p[1])<<8
+			}
+		}
+	}
+	return ids
+}
+
+// Apply draws the outline and glow of an ID buffer from RenderIDs over
+// frame. Both are drawn outside the selected pixels, with antialiased edges.
+func (s *SelectionOutline) Apply(frame *image.NRGBA, ids []int) {
+	b := frame.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if len(ids) < width*height {
+		return
+	}
+	distance := selectionDistance(ids, width, height, s.Width+s.Glow+1)
+
+	outline := s.Color.Opaque()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			d := distance[y*width+x]
+			if d == 0 || d > s.Width+s.Glow+1 {
+				continue
+			}
+			// Distances are between pixel centers, the selection edge lies
+			// half a pixel from the nearest selected one
+			edge := d - 0.5
+			alpha := Clamp(s.Width+0.5-edge, 0, 1) * s.Color.A
+			if s.Glow > 0 && edge > s.Width {
+				t := Clamp((edge-s.Width)/s.Glow, 0, 1)
+				alpha = math.Max(alpha, (1-t)*(1-t)*s.GlowStrength*s.Color.A)
+			}
+			if alpha <= 0 {
+				continue
+			}
+			i := frame.PixOffset(b.Min.X+x, b.Min.Y+y)
+			p := frame.Pix[i : i+4 : i+4]
+			dst := Color{float64(p[0]) / 255, float64(p[1]) / 255, float64(p[2]) / 255, float64(p[3]) / 255}
+			n := outline.Alpha(alpha).Over(dst).NRGBA()
+			p[0], p[1], p[2], p[3] = n.R, n.G, n.B, n.A
+		}
+	}
+}
+
+// selectionDistance returns the approximate Euclidean distance in pixels
+// from every pixel to the nearest selected pixel, 0 inside the selection,
+// using a two pass chamfer transform. Distances are capped at limit.
+func selectionDistance(ids []int, width, height int, limit float64) []float64 {
+	distance := make([]float64, width*height)
+	for i, id := range ids {
+		if id == 0 {
+			distance[i] = limit
+		}
+	}
+
+	const diagonal = math.Sqrt2
+	relax := func(i, x, y int, step float64) {
+		if x < 0 || y < 0 || x >= width || y >= height {
+			return
+		}
+		if d := distance[y*width+x] + step; d < distance[i] {
+			distance[i] = d
+		}
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			relax(i, x-1, y, 1)
+			relax(i, x, y-1, 1)
+			relax(i, x-1, y-1, diagonal)
+			relax(i, x+1, y-1, diagonal)
+		}
+	}
+	for y := height - 1; y >= 0; y-- {
+		for x := width - 1; x >= 0; x-- {
+			i := y*width + x
+			relax(i, x+1, y, 1)
+			relax(i, x, y+1, 1)
+			relax(i, x+1, y+1, diagonal)
+			relax(i, x-1, y+1, diagonal)
+		}
+	}
+	return distance
+}
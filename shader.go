@@ -44,7 +44,7 @@ func (shader *TextureShader) Vertex(v Vertex) Vertex {
 }
 
 func (shader *TextureShader) Fragment(v Vertex) Color {
-	return shader.Texture.BilinearSample(v.Texture.X, v.Texture.Y)
+	return SampleTextureGrad(shader.Texture, v.Texture.X, v.Texture.Y, v.TextureDx, v.TextureDy)
 }
 
 // PhongShader implements Phong shading with an optional texture.
@@ -58,6 +58,11 @@ type PhongShader struct {
 	SpecularColor  Color
 	Texture        Texture
 	SpecularPower  float64
+	// Linear lights the colors and the texture in linear space, decoding
+	// them from sRGB, and outputs linear color like PBRShader. By default
+	// they are lit as authored in gamma space and the output is gamma
+	// encoded.
+	Linear bool
 }
 
 // NewPhongShader f
@@ -67,7 +72,7 @@ func NewPhongShader(matrix Matrix, lightDirection, cameraPosition Vector) *Phong
 	specular := Color{1, 1, 1, 1}
 	return &PhongShader{
 		matrix, lightDirection, cameraPosition,
-		Discard, ambient, diffuse, specular, nil, 32}
+		Discard, ambient, diffuse, specular, nil, 32, false}
 }
 
 // Vertex f
@@ -77,28 +82,61 @@ func (shader *PhongShader) Vertex(v Vertex) Vertex {
 }
 
 func (shader *PhongShader) Fragment(v Vertex) Color {
-	light := shader.AmbientColor
+	ambientColor, diffuseColor, specularColor := shader.AmbientColor, shader.DiffuseColor, shader.SpecularColor
 	color := v.Color
 	if shader.ObjectColor != Discard {
 		color = shader.ObjectColor
 	}
 	if shader.Texture != nil {
-		color = shader.Texture.BilinearSample(v.Texture.X, v.Texture.Y)
+		color = SampleTextureGrad(shader.Texture, v.Texture.X, v.Texture.Y, v.TextureDx, v.TextureDy)
 	}
+	if shader.Linear {
+		ambientColor, diffuseColor, specularColor = ambientColor.Linear(), diffuseColor.Linear(), specularColor.Linear()
+		color = color.Linear()
+	}
+	light := ambientColor
 	diffuse := math.Max(v.Normal.Dot(shader.LightDirection), 0)
-	light = light.Add(shader.DiffuseColor.MulScalar(diffuse))
+	light = light.Add(diffuseColor.MulScalar(diffuse))
 	if diffuse > 0 && shader.SpecularPower > 0 {
 		camera := shader.CameraPosition.Sub(v.Position).Normalize()
 		reflected := shader.LightDirection.Negate().Reflect(v.Normal)
 		specular := math.Max(camera.Dot(reflected), 0)
 		if specular > 0 {
 			specular = math.Pow(specular, shader.SpecularPower)
-			light = light.Add(shader.SpecularColor.MulScalar(specular))
+			light = light.Add(specularColor.MulScalar(specular))
 		}
 	}
 	return color.Mul(light).Min(White).Alpha(color.A)
 }
 
+// ColorSpaceShader converts the fragment colors of another shader between
+// color spaces, so that shaders of either convention can share a frame:
+// wrap gamma space shaders From ColorSpaceSRGB To ColorSpaceLinear when the
+// frame is gamma encoded by a final pass, as for PBRShader.
+type ColorSpaceShader struct {
+	Shader Shader
+	From   ColorSpace
+	To     ColorSpace
+}
+
+// NewColorSpaceShader converts the output of shader from one color space to
+// another
+func NewColorSpaceShader(shader Shader, from, to ColorSpace) *ColorSpaceShader {
+	return &ColorSpaceShader{shader, from, to}
+}
+
+func (shader *ColorSpaceShader) Vertex(v Vertex) Vertex {
+	return shader.Shader.Vertex(v)
+}
+
+func (shader *ColorSpaceShader) Fragment(v Vertex) Color {
+	color := shader.Shader.Fragment(v)
+	if color == Discard {
+		return color
+	}
+	return color.Convert(shader.From, shader.To)
+}
+
 // PBRShader implements physically-based rendering
 type PBRShader struct {
 	Matrix         Matrix
@@ -106,7 +144,21 @@ type PBRShader struct {
 	Lights         []Light
 	AmbientColor   Color
 	CameraPosition Vector
-	pbrLighting    *PBRLighting
+	// LightGrid, when set, restricts each fragment to the lights of its
+	// screen tile instead of evaluating every light in Lights
+	LightGrid *LightGrid
+	// Model transforms vertices to world space, where fragments are lit
+	// and shadowed, and ReceiveShadows enables the Shadow of the lights and
+	// ContactShadows. Grade adjusts the shaded colors, see SceneNode.Grade.
+	Model          Matrix
+	ReceiveShadows bool
+	ContactShadows *ContactShadows
+	Grade          *ColorGrade
+	// AmbientOcclusion darkens the ambient light by the occlusion of its
+	// prepass, see SceneRenderer.AmbientOcclusion
+	AmbientOcclusion *SSAOEffect
+	pbrLighting      *PBRLighting
+	dispersion       *dispersionTracer // See SceneRenderer.DispersionBands
 }
 
 // NewPBRShader creates a new PBR shader
@@ -117,44 +169,87 @@ func NewPBRShader(matrix Matrix, material *PBRMaterial, lights []Light, cameraPo
 		Lights:         lights,
 		AmbientColor:   Color{0.1, 0.1, 0.1, 1.0},
 		CameraPosition: cameraPos,
+		Model:          Identity(),
+		ReceiveShadows: true,
 		pbrLighting:    &PBRLighting{},
 	}
 }
 
-// Vertex processes a vertex through the PBR shader pipeline
+// Vertex processes a vertex through the PBR shader pipeline, moving it to
+// world space for Fragment
 func (shader *PBRShader) Vertex(v Vertex) Vertex {
 	v.Output = shader.Matrix.MulPositionW(v.Position)
+	return worldVertex(v, shader.Model)
+}
+
+// worldVertex moves the position, normal and tangent of a vertex from model
+// to world space. Mirroring transforms flip the handedness of the tangent
+// frame.
+func worldVertex(v Vertex, model Matrix) Vertex {
+	v.Position = model.MulPosition(v.Position)
+	v.Normal = model.MulDirection(v.Normal)
+	if v.Tangent != (VectorW{}) {
+		t := model.MulDirection(v.Tangent.Vector())
+		w := v.Tangent.W
+		if model.Determinant() < 0 {
+			w = -w
+		}
+		v.Tangent = VectorW{t.X, t.Y, t.Z, w}
+	}
 	return v
 }
 
 // Fragment performs PBR shading calculations
 func (shader *PBRShader) Fragment(v Vertex) Color {
 	if shader.Material == nil {
-		return Color{1, 0, 1, 1} // Magenta for missing material
+		return missingColor
 	}
 
 	// Sample material properties at current texture coordinates
-	sampledMaterial := shader.Material.Sample(v.Texture.X, v.Texture.Y)
-
-	// Transform normal from tangent space to world space
-	// For simplicity, we'll use the vertex normal directly
-	// In a full implementation, you'd calculate TBN matrix
-	worldNormal := v.Normal.Normalize()
+	sampledMaterial := shader.Material.sample(v.Texture.X, v.Texture.Y, v.TextureDx, v.TextureDy, v.Color)
+	sampledMaterial.Occlusion *= shader.AmbientOcclusion.visibility(v.Output)
 
 	// Calculate view direction
 	viewDir := shader.CameraPosition.Sub(v.Position).Normalize()
 
+	// Transform the normal map normal from tangent space to world space
+	normal := surfaceNormal(v.Normal, viewDir)
+	worldNormal := normal
+	if shader.Material.normalMapped() {
+		worldNormal = TangentToWorld(worldNormal, v.Tangent, sampledMaterial.Normal)
+	}
+	sampledMaterial.Tangent = v.Tangent.Vector()
+
+	lights := shader.Lights
+	if shader.LightGrid != nil {
+		lights = shader.LightGrid.LightsAt(v.Output)
+	}
+	unshadowed := lights
+	lights = shader.shadowLights(lights, v.Position, normal)
+	if shader.Material.ShadowCatcher {
+		return shader.catchShadow(sampledMaterial, v.Position, worldNormal, unshadowed, lights)
+	}
+
 	// Perform PBR lighting calculation
 	finalColor := shader.pbrLighting.CalculatePBR(
 		sampledMaterial,
 		v.Position,
 		worldNormal,
 		viewDir,
-		shader.Lights,
+		lights,
 		shader.AmbientColor,
 	)
+	if shader.dispersion != nil {
+		// Add the light refracted through the mesh, split into its colors
+		transmission := sampledMaterial.Transmission * (1 - sampledMaterial.Metallic)
+		traced := shader.dispersion.trace(shader, v.Position, normal, sampledMaterial)
+		finalColor = finalColor.Add(traced.Mul(sampledMaterial.BaseColor).MulScalar(transmission))
+	}
+
+	finalColor = shader.Grade.Apply(finalColor)
 
-	// Handle alpha mode
+	// Handle alpha mode; coverage comes from the base color
+	finalColor.A = sampledMaterial.BaseColor.A
 	switch shader.Material.AlphaMode {
 	case AlphaMask:
 		if finalColor.A < shader.Material.AlphaCutoff {
@@ -188,6 +283,8 @@ func NewMetallicRoughnessShader(matrix Matrix, lights []Light, cameraPos Vector)
 		Lights:         lights,
 		AmbientColor:   Color{0.1, 0.1, 0.1, 1.0},
 		CameraPosition: cameraPos,
+		Model:          Identity(),
+		ReceiveShadows: true,
 		pbrLighting:    &PBRLighting{},
 	}
 
@@ -203,52 +300,58 @@ func (shader *MetallicRoughnessShader) Fragment(v Vertex) Color {
 	// Sample base color
 	baseColor := shader.Material.BaseColorFactor
 	if shader.BaseColorTexture != nil {
-		baseColor = baseColor.Mul(shader.BaseColorTexture.Sample(u, v_coord))
+		baseColor = baseColor.Mul(shader.BaseColorTexture.SampleGrad(u, v_coord, v.TextureDx, v.TextureDy))
 	}
 
 	// Sample metallic and roughness
 	metallic := shader.Material.MetallicFactor
 	roughness := shader.Material.RoughnessFactor
 	if shader.MetallicRoughnessTexture != nil {
-		mr := shader.MetallicRoughnessTexture.Sample(u, v_coord)
+		mr := shader.MetallicRoughnessTexture.SampleGrad(u, v_coord, v.TextureDx, v.TextureDy)
 		metallic *= mr.B  // Blue channel
 		roughness *= mr.G // Green channel
 	}
 
+	// Calculate view direction
+	viewDir := shader.CameraPosition.Sub(v.Position).Normalize()
+
 	// Sample normal
-	normal := v.Normal.Normalize()
+	geometricNormal := surfaceNormal(v.Normal, viewDir)
+	normal := geometricNormal
 	if shader.NormalTexture != nil {
 		tangentNormal := shader.NormalTexture.SampleNormal(u, v_coord)
-		// For simplicity, just use the normal directly
-		// In practice, you'd transform from tangent to world space
-		normal = tangentNormal
+		normal = TangentToWorld(normal, v.Tangent, tangentNormal)
 	}
 
 	// Sample occlusion
 	occlusion := 1.0
 	if shader.OcclusionTexture != nil {
-		occlusionColor := shader.OcclusionTexture.Sample(u, v_coord)
+		occlusionColor := shader.OcclusionTexture.SampleGrad(u, v_coord, v.TextureDx, v.TextureDy)
 		occlusion = occlusionColor.R
 	}
 
 	// Sample emissive
 	emissive := shader.Material.EmissiveFactor
 	if shader.EmissiveTexture != nil {
-		emissive = emissive.Mul(shader.EmissiveTexture.Sample(u, v_coord))
+		emissive = emissive.Mul(shader.EmissiveTexture.SampleGrad(u, v_coord, v.TextureDx, v.TextureDy))
 	}
 
-	// Create sampled material
-	sampledMaterial := &SampledMaterial{
-		BaseColor: baseColor,
-		Metallic:  metallic,
-		Roughness: roughness,
-		Normal:    normal,
-		Occlusion: occlusion,
-		Emissive:  emissive,
+	// Extension properties come from the material, core ones from the
+	// shader's textures
+	sampledMaterial := shader.Material.SampleGrad(u, v_coord, v.TextureDx, v.TextureDy)
+	sampledMaterial.BaseColor = baseColor
+	sampledMaterial.Metallic = metallic
+	sampledMaterial.Roughness = roughness
+	sampledMaterial.Normal = normal
+	sampledMaterial.Tangent = v.Tangent.Vector()
+	sampledMaterial.Occlusion = occlusion
+	sampledMaterial.Emissive = emissive
+
+	lights := shader.Lights
+	if shader.LightGrid != nil {
+		lights = shader.LightGrid.LightsAt(v.Output)
 	}
-
-	// Calculate view direction
-	viewDir := shader.CameraPosition.Sub(v.Position).Normalize()
+	lights = shader.shadowLights(lights, v.Position, geometricNormal)
 
 	// Perform PBR lighting calculation
 	finalColor := shader.pbrLighting.CalculatePBR(
@@ -256,11 +359,11 @@ func (shader *MetallicRoughnessShader) Fragment(v Vertex) Color {
 		v.Position,
 		normal,
 		viewDir,
-		shader.Lights,
+		lights,
 		shader.AmbientColor,
 	)
 
-	return finalColor
+	return shader.Grade.Apply(finalColor)
 }
 
 // EnvironmentShader renders environment mapping and reflections
@@ -295,7 +398,7 @@ func (shader *EnvironmentShader) Fragment(v Vertex) Color {
 
 	// Calculate reflection direction
 	viewDir := shader.CameraPosition.Sub(v.Position).Normalize()
-	reflectionDir := viewDir.Reflect(v.Normal)
+	reflectionDir := viewDir.Reflect(surfaceNormal(v.Normal, viewDir))
 
 	// Sample cube map
 	reflectionColor := shader.CubeMap.SampleCubeMap(reflectionDir)
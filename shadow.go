@@ -108,7 +108,7 @@ func (shader *ShadowReceiverShader) Fragment(v Vertex) Color {
 	// Sample base color
 	color := shader.ObjectColor
 	if shader.Texture != nil {
-		color = shader.Texture.BilinearSample(v.Texture.X, v.Texture.Y)
+		color = SampleTextureGrad(shader.Texture, v.Texture.X, v.Texture.Y, v.TextureDx, v.TextureDy)
 	}
 
 	// Calculate lighting without shadows first
@@ -328,15 +328,20 @@ func (sr *ShadowRenderer) GenerateShadowMap(scene *Scene) *ShadowMap {
 	originalDepthBuffer := sr.context.DepthBuffer
 	originalWriteColor := sr.context.WriteColor
 	originalWriteDepth := sr.context.WriteDepth
+	originalDepthMode := sr.context.DepthMode
+	originalDepthRange := [2]float64{sr.context.DepthRangeNear, sr.context.DepthRangeFar}
 
 	// Set up context for shadow map rendering
 	sr.context.Shader = shadowShader
+	sr.context.DepthMode = DepthStandard // Light projections are standard
+	sr.context.DepthRangeNear, sr.context.DepthRangeFar = 0, 1
 	sr.context.ColorBuffer = image.NewNRGBA(image.Rect(0, 0, sr.shadowMap.Width, sr.shadowMap.Height))
 	sr.context.DepthBuffer = make([]float64, sr.shadowMap.Width*sr.shadowMap.Height)
 	sr.context.WriteColor = false // We only care about depth
 	sr.context.WriteDepth = true
 
 	// Render scene from light's perspective
+	scene.UpdateTransforms()
 	renderables := scene.RootNode.GetRenderableNodes()
 	for _, node := range renderables {
 		if node.Mesh != nil {
@@ -353,6 +358,8 @@ func (sr *ShadowRenderer) GenerateShadowMap(scene *Scene) *ShadowMap {
 	sr.context.DepthBuffer = originalDepthBuffer
 	sr.context.WriteColor = originalWriteColor
 	sr.context.WriteDepth = originalWriteDepth
+	sr.context.DepthMode = originalDepthMode
+	sr.context.DepthRangeNear, sr.context.DepthRangeFar = originalDepthRange[0], originalDepthRange[1]
 
 	return sr.shadowMap
 }
@@ -455,15 +462,20 @@ func (sr *ShadowMapRenderer) GenerateShadowMap(scene *Scene) *ShadowMap {
 	originalDepthBuffer := sr.context.DepthBuffer
 	originalWriteColor := sr.context.WriteColor
 	originalWriteDepth := sr.context.WriteDepth
+	originalDepthMode := sr.context.DepthMode
+	originalDepthRange := [2]float64{sr.context.DepthRangeNear, sr.context.DepthRangeFar}
 
 	// Set up context for shadow map rendering
 	sr.context.Shader = shadowShader
+	sr.context.DepthMode = DepthStandard // Light projections are standard
+	sr.context.DepthRangeNear, sr.context.DepthRangeFar = 0, 1
 	sr.context.ColorBuffer = image.NewNRGBA(image.Rect(0, 0, sr.shadowMap.Width, sr.shadowMap.Height))
 	sr.context.DepthBuffer = make([]float64, sr.shadowMap.Width*sr.shadowMap.Height)
 	sr.context.WriteColor = false // We only care about depth
 	sr.context.WriteDepth = true
 
 	// Render scene from light's perspective
+	scene.UpdateTransforms()
 	renderables := scene.RootNode.GetRenderableNodes()
 	for _, node := range renderables {
 		if node.Mesh != nil && node.CastShadows {
@@ -480,6 +492,8 @@ func (sr *ShadowMapRenderer) GenerateShadowMap(scene *Scene) *ShadowMap {
 	sr.context.DepthBuffer = originalDepthBuffer
 	sr.context.WriteColor = originalWriteColor
 	sr.context.WriteDepth = originalWriteDepth
+	sr.context.DepthMode = originalDepthMode
+	sr.context.DepthRangeNear, sr.context.DepthRangeFar = originalDepthRange[0], originalDepthRange[1]
 
 	return sr.shadowMap
 }
@@ -587,19 +601,31 @@ type OmniShadowMap struct {
 	ShadowMaps    []*ShadowMap // 6 shadow maps for cube faces
 	LightPosition Vector
 	LightMatrices []Matrix
+	// Near and Far bound the distances from the light held by the maps,
+	// see Generate
+	Near, Far float64
 }
 
 // NewOmniShadowMap creates a new omnidirectional shadow map for point lights
 func NewOmniShadowMap(shadowMapSize int, lightPosition Vector) *OmniShadowMap {
 	osm := &OmniShadowMap{
 		ShadowMaps:    make([]*ShadowMap, 6),
-		LightPosition: lightPosition,
 		LightMatrices: make([]Matrix, 6),
 	}
 
 	for i := 0; i < 6; i++ {
 		osm.ShadowMaps[i] = NewShadowMap(shadowMapSize, shadowMapSize)
+		osm.ShadowMaps[i].Clear(math.MaxFloat64)
 	}
+	osm.SetLightPosition(lightPosition)
+
+	return osm
+}
+
+// SetLightPosition moves the light and updates the view matrices of the
+// cube faces
+func (osm *OmniShadowMap) SetLightPosition(lightPosition Vector) {
+	osm.LightPosition = lightPosition
 
 	// Create view matrices for each cube face
 	osm.LightMatrices[0] = LookAt(lightPosition, lightPosition.Add(Vector{1, 0, 0}), Vector{0, -1, 0})  // +X
@@ -608,8 +634,100 @@ func NewOmniShadowMap(shadowMapSize int, lightPosition Vector) *OmniShadowMap {
 	osm.LightMatrices[3] = LookAt(lightPosition, lightPosition.Add(Vector{0, -1, 0}), Vector{0, 0, -1}) // -Y
 	osm.LightMatrices[4] = LookAt(lightPosition, lightPosition.Add(Vector{0, 0, 1}), Vector{0, -1, 0})  // +Z
 	osm.LightMatrices[5] = LookAt(lightPosition, lightPosition.Add(Vector{0, 0, -1}), Vector{0, -1, 0}) // -Z
+}
 
-	return osm
+// Generate renders the shadow casting nodes of a scene into the six faces.
+// The maps hold the distance from the light to the nearest caster, between
+// near and far; each map's LightView is the view-projection matrix of its
+// face. Vertex modifiers are evaluated at time 0.
+func (osm *OmniShadowMap) Generate(scene *Scene, near, far float64) {
+	size := osm.ShadowMaps[0].Width
+	dc := NewContext(size, size)
+	dc.WriteColor = false
+	dc.Cull = CullNone
+	osm.render(dc, shadowCasters(scene), near, far, 0)
+}
+
+// render renders casters, deformed at time, into the faces with a context
+// of the size of the maps
+func (osm *OmniShadowMap) render(dc *Context, casters []*SceneNode, near, far, time float64) {
+	osm.Near, osm.Far = near, far
+	projection := Perspective(90, 1, near, far)
+	for face, view := range osm.LightMatrices {
+		matrix := projection.Mul(view)
+		renderShadowDepth(dc, casters, matrix, time)
+
+		// Convert window depth to the distance along the ray of each texel
+		sm := osm.ShadowMaps[face]
+		sm.LightView = matrix
+		for y := 0; y < sm.Height; y++ {
+			ny := 1 - (float64(y)+0.5)/float64(sm.Height)*2
+			for x := 0; x < sm.Width; x++ {
+				i := y*sm.Width + x
+				depth := dc.DepthBuffer[i]
+				if depth >= 1 {
+					sm.DepthMap[i] = math.MaxFloat64
+					continue
+				}
+				nx := (float64(x)+0.5)/float64(sm.Width)*2 - 1
+				z := 2 * far * near / (far + near - (2*depth-1)*(far-near))
+				sm.DepthMap[i] = z * math.Sqrt(1+nx*nx+ny*ny)
+			}
+		}
+	}
+}
+
+// Visibility returns the fraction of the (2*pcf+1)² texels around a world
+// position in its cube face that don't occlude it from the light. Positions
+// beyond Far are lit.
+func (osm *OmniShadowMap) Visibility(position Vector, pcf int) float64 {
+	d := position.Sub(osm.LightPosition)
+	distance := d.Length()
+	if distance == 0 || distance >= osm.Far {
+		return 1
+	}
+	sm := osm.ShadowMaps[cubeFace(d)]
+	p := sm.LightView.MulPositionW(position)
+	p = p.DivScalar(p.W)
+	x := int(math.Floor((p.X*0.5 + 0.5) * float64(sm.Width)))
+	y := int(math.Floor((0.5 - p.Y*0.5) * float64(sm.Height)))
+
+	var lit, samples float64
+	for dy := -pcf; dy <= pcf; dy++ {
+		for dx := -pcf; dx <= pcf; dx++ {
+			// Filters are clamped to the face
+			sx := ClampInt(x+dx, 0, sm.Width-1)
+			sy := ClampInt(y+dy, 0, sm.Height-1)
+			if distance <= sm.GetDepth(sx, sy) {
+				lit++
+			}
+			samples++
+		}
+	}
+	return lit / samples
+}
+
+// cubeFace returns the index of the cube face a direction points at, in the
+// order +X, -X, +Y, -Y, +Z, -Z
+func cubeFace(d Vector) int {
+	ax, ay, az := math.Abs(d.X), math.Abs(d.Y), math.Abs(d.Z)
+	switch {
+	case ax >= ay && ax >= az:
+		if d.X > 0 {
+			return 0
+		}
+		return 1
+	case ay >= az:
+		if d.Y > 0 {
+			return 2
+		}
+		return 3
+	default:
+		if d.Z > 0 {
+			return 4
+		}
+		return 5
+	}
 }
 
 // SoftShadowReceiverShader implements advanced soft shadow techniques
@@ -654,7 +772,7 @@ func (shader *SoftShadowReceiverShader) Fragment(v Vertex) Color {
 	// Sample base color
 	color := shader.ObjectColor
 	if shader.Texture != nil {
-		color = shader.Texture.BilinearSample(v.Texture.X, v.Texture.Y)
+		color = SampleTextureGrad(shader.Texture, v.Texture.X, v.Texture.Y, v.TextureDx, v.TextureDy)
 	}
 
 	// Calculate lighting without shadows first
@@ -794,3 +912,262 @@ func (shader *SoftShadowReceiverShader) calculatePCFShadowWithSize(v Vertex, fil
 
 	return shadow / samples
 }
+
+// LightShadow is the shadow of a light: a depth map rendered from the light
+// and how it is filtered when shading
+type LightShadow struct {
+	Map *ShadowMap // LightView is the view-projection matrix of the light
+	// Cube holds the shadow of a point light instead of Map
+	Cube *OmniShadowMap
+	// Bias moves receivers towards the light, in world units, against
+	// shadow acne. Surfaces at grazing angles to the light are moved
+	// further, by up to ten Texels, the size of a shadow map texel at the
+	// receivers, or at a distance of one from point and spot lights.
+	Bias    float64
+	Texel   float64
+	PCFSize int // Radius in texels of the percentage closer filter
+	// Position is the position of a point or spot light, directional
+	// lights use Direction
+	Position   Vector
+	Direction  Vector
+	positional bool
+}
+
+// Visibility returns the fraction of the light reaching a world position
+// with a normal, 1 being fully lit. Positions outside the shadow map are
+// lit.
+func (s *LightShadow) Visibility(position, normal Vector) float64 {
+	toLight := s.Direction.Negate()
+	texel := s.Texel
+	if s.positional {
+		toLight = s.Position.Sub(position)
+		texel *= toLight.Length()
+		toLight = toLight.Normalize()
+	}
+	bias := s.Bias
+	if normal != (Vector{}) {
+		cos := math.Max(math.Abs(normal.Normalize().Dot(toLight)), 0.1)
+		tan := math.Sqrt(1-cos*cos) / cos
+		bias += texel * (float64(s.PCFSize) + 1) * math.Min(tan, 10)
+	}
+	position = position.Add(toLight.MulScalar(bias))
+	if s.Cube != nil {
+		return s.Cube.Visibility(position, s.PCFSize)
+	}
+	return s.Map.visibility(position, s.PCFSize)
+}
+
+// visibility returns the fraction of the (2*pcf+1)² texels around the
+// projection of a world position through LightView that don't occlude it
+func (sm *ShadowMap) visibility(position Vector, pcf int) float64 {
+	p := sm.LightView.MulPositionW(position)
+	if p.W <= 0 {
+		return 1
+	}
+	p = p.DivScalar(p.W)
+	if p.Z > 1 {
+		return 1
+	}
+	// The map holds window depth with rows top to bottom, see Screen
+	x := int(math.Floor((p.X*0.5 + 0.5) * float64(sm.Width)))
+	y := int(math.Floor((0.5 - p.Y*0.5) * float64(sm.Height)))
+	depth := p.Z*0.5 + 0.5
+
+	var lit, samples float64
+	for dy := -pcf; dy <= pcf; dy++ {
+		for dx := -pcf; dx <= pcf; dx++ {
+			if depth <= sm.GetDepth(x+dx, y+dy) {
+				lit++
+			}
+			samples++
+		}
+	}
+	return lit / samples
+}
+
+// shadowLights returns the lights dimmed by their shadows and contact
+// shadows at a fragment, given in world space
+func (shader *PBRShader) shadowLights(lights []Light, world, worldNormal Vector) []Light {
+	if !shader.ReceiveShadows {
+		return lights
+	}
+	var shadowed []Light
+	for i, light := range lights {
+		contact := shader.ContactShadows != nil && light.Type != AmbientLight
+		if light.Shadow == nil && !contact {
+			continue
+		}
+		if shadowed == nil {
+			shadowed = append([]Light(nil), lights...)
+		}
+		if light.Shadow != nil {
+			shadowed[i].Intensity *= light.Shadow.Visibility(world, worldNormal)
+		}
+		if contact && shadowed[i].Intensity != 0 {
+			toLight := light.Direction.Negate()
+			if light.Type != DirectionalLight {
+				toLight = light.Position.Sub(world)
+			}
+			shadowed[i].Intensity *= shader.ContactShadows.Visibility(world, toLight)
+		}
+	}
+	if shadowed == nil {
+		return lights
+	}
+	return shadowed
+}
+
+// shadowCasters returns the renderable nodes of a scene that cast shadows
+func shadowCasters(scene *Scene) []*SceneNode {
+	scene.UpdateTransforms()
+	var casters []*SceneNode
+	for _, node := range scene.RootNode.GetRenderableNodes() {
+		if node.Mesh != nil && node.CastShadows {
+			casters = append(casters, node)
+		}
+	}
+	return casters
+}
+
+// renderShadowDepth clears the depth buffer of a shadow context and renders
+// the depth of casters through a light matrix, deformed at time
+func renderShadowDepth(dc *Context, casters []*SceneNode, matrix Matrix, time float64) {
+	dc.ClearDepthBuffer()
+	for _, node := range casters {
+		for _, transform := range node.InstanceTransforms() {
+			dc.Shader = node.modify(NewShadowMapShader(matrix.Mul(transform)), time)
+			dc.DrawMesh(node.Mesh)
+		}
+	}
+}
+
+// shadowLights renders the shadow maps of the lights of a scene from its
+// shadow casting nodes, and returns the lights of the scene with their
+// shadows
+func (renderer *SceneRenderer) shadowLights(scene *Scene) []Light {
+	if !renderer.EnableShadows {
+		return scene.Lights
+	}
+	casters := shadowCasters(scene)
+	if len(casters) == 0 {
+		return scene.Lights
+	}
+	bounds := EmptyBox
+	for _, node := range casters {
+		bounds = bounds.Extend(node.worldBounds())
+	}
+
+	size := renderer.ShadowMapSize
+	if size <= 0 {
+		size = 1024
+	}
+	pcf := renderer.ShadowPCFSize
+	if pcf < 0 {
+		pcf = 0
+	}
+	if renderer.shadowContext == nil || renderer.shadowContext.Width != size {
+		renderer.shadowContext = NewContext(size, size)
+		renderer.shadowContext.WriteColor = false
+		renderer.shadowContext.Cull = CullNone
+	}
+	dc := renderer.shadowContext
+
+	center := bounds.Center()
+	radius := bounds.Size().Length() / 2
+	lights := make([]Light, len(scene.Lights))
+	copy(lights, scene.Lights)
+	index, cubes := 0, 0
+	for i, light := range lights {
+		var matrix Matrix
+		shadow := &LightShadow{PCFSize: pcf}
+		// Spot lights and point lights with a range only shadow the
+		// casters within their reach, found with the scene BVH
+		lightCasters := casters
+		switch light.Type {
+		case DirectionalLight:
+			// An orthographic projection around the bounding sphere of
+			// the casters
+			direction := light.Direction.Normalize()
+			eye := center.Sub(direction.MulScalar(2 * radius))
+			view := LookAt(eye, center, shadowUp(direction))
+			matrix = Orthographic(-radius, radius, -radius, radius, radius*0.99, radius*3.01).Mul(view)
+			shadow.Direction = direction
+			shadow.Texel = 2 * radius / float64(size)
+		case SpotLight:
+			direction := light.Direction.Normalize()
+			far := light.Position.Distance(center) + radius
+			if light.Range > 0 {
+				far = math.Min(far, light.Range)
+			}
+			cone := math.Min(light.OuterCone, Radians(85))
+			view := LookAt(light.Position, light.Position.Add(direction), shadowUp(direction))
+			matrix = Perspective(Degrees(2*cone), 1, far/1000, far).Mul(view)
+			lightCasters = scene.BVH().shadowCasters(NewViewFrustumFromMatrix(matrix).IntersectsBox)
+			shadow.Position = light.Position
+			shadow.Direction = direction
+			shadow.positional = true
+			shadow.Texel = 2 * math.Tan(cone) / float64(size)
+		case PointLight:
+			far := light.Position.Distance(center) + radius
+			if light.Range > 0 {
+				far = math.Min(far, light.Range)
+			}
+			if cubes == len(renderer.shadowCubes) {
+				renderer.shadowCubes = append(renderer.shadowCubes, nil)
+			}
+			cube := renderer.shadowCubes[cubes]
+			if cube == nil || cube.ShadowMaps[0].Width != size {
+				cube = NewOmniShadowMap(size, light.Position)
+				renderer.shadowCubes[cubes] = cube
+			}
+			cubes++
+			if light.Range > 0 {
+				reach := Vector{far, far, far}
+				lightCasters = scene.BVH().shadowCasters(Box{light.Position.Sub(reach), light.Position.Add(reach)}.Intersects)
+			}
+			cube.SetLightPosition(light.Position)
+			cube.render(dc, lightCasters, far/1000, far, renderer.Time)
+			shadow.Cube = cube
+			shadow.Position = light.Position
+			shadow.positional = true
+			shadow.Texel = 2 / float64(size)
+		default:
+			continue
+		}
+		shadow.Bias = shadow.Texel
+		if shadow.positional {
+			// One texel at the distance of the casters
+			shadow.Bias *= light.Position.Distance(center)
+		}
+		if renderer.ShadowBias > 0 {
+			shadow.Bias = renderer.ShadowBias
+		}
+		lights[i].Shadow = shadow
+		if shadow.Cube != nil {
+			continue
+		}
+
+		renderShadowDepth(dc, lightCasters, matrix, renderer.Time)
+		if index == len(renderer.shadowMaps) {
+			renderer.shadowMaps = append(renderer.shadowMaps, nil)
+		}
+		shadowMap := renderer.shadowMaps[index]
+		if shadowMap == nil || shadowMap.Width != size {
+			shadowMap = NewShadowMap(size, size)
+			renderer.shadowMaps[index] = shadowMap
+		}
+		index++
+		copy(shadowMap.DepthMap, dc.DepthBuffer)
+		shadowMap.LightView = matrix
+		shadow.Map = shadowMap
+	}
+	return lights
+}
+
+// shadowUp returns an up vector for looking along a direction
+func shadowUp(direction Vector) Vector {
+	if math.Abs(direction.Y) > 0.99 {
+		return Vector{0, 0, 1}
+	}
+	return Vector{0, 1, 0}
+}
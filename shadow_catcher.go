@@ -0,0 +1,229 @@
+package fauxgl
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// ShadowCatcherOptions configures Scene.AddShadowCatcher
+type ShadowCatcherOptions struct {
+	Size    float64 // Plane size relative to the larger horizontal extent of the scene
+	Offset  float64 // Distance of the plane below the scene bounds
+	Falloff float64 // Fraction of the plane radius fading out towards its edge, 0 for a hard edge
+	Opacity float64 // Alpha of fully shadowed areas
+	// Occlusion bakes the ambient occlusion of the scene onto the plane at
+	// Resolution texels, tracing Samples rays per texel up to Distance (0
+	// uses half the scene height) against a height field of the scene seen
+	// from below
+	Occlusion  bool
+	Resolution int
+	Samples    int
+	Distance   float64
+}
+
+// NewShadowCatcherOptions returns the default shadow catcher options: a
+// plane three times the size of the scene, fading out over its outer half,
+// with baked occlusion
+func NewShadowCatcherOptions() *ShadowCatcherOptions {
+	return &ShadowCatcherOptions{
+		Size:       3,
+		Offset:     0,
+		Falloff:    0.5,
+		Opacity:    1,
+		Occlusion:  true,
+		Resolution: 256,
+		Samples:    32,
+	}
+}
+
+// AddShadowCatcher adds a ground plane under the scene bounds whose
+// material only shows the shadows and ambient occlusion it receives,
+// transparent elsewhere, to ground objects rendered over any background.
+// Shadows require SceneRenderer.EnableShadows. It returns nil for an empty
+// scene.
+func (scene *Scene) AddShadowCatcher(options *ShadowCatcherOptions) *SceneNode {
+	if options == nil {
+		options = NewShadowCatcherOptions()
+	}
+	scene.UpdateTransforms()
+	bounds := scene.GetBounds()
+	if bounds == EmptyBox {
+		return nil
+	}
+	size := bounds.Size()
+	center := bounds.Center()
+	h := math.Max(math.Max(size.X, size.Z)*options.Size/2, 1e-3)
+	ground := bounds.Min.Y - options.Offset
+	x0, z0 := center.X-h, center.Z-h
+
+	// Facing up, with UVs mapping X and Z to u and v
+	vertex := func(x, z float64) Vertex {
+		return Vertex{
+			Position: Vector{x, ground, z},
+			Normal:   Vector{0, 1, 0},
+			Texture:  Vector{(x - x0) / (2 * h), (z - z0) / (2 * h), 0},
+			Tangent:  VectorW{1, 0, 0, 1},
+			Color:    White,
+		}
+	}
+	x1, z1 := center.X+h, center.Z+h
+	mesh := NewTriangleMesh([]*Triangle{
+		NewTriangle(vertex(x0, z1), vertex(x1, z1), vertex(x1, z0)),
+		NewTriangle(vertex(x0, z1), vertex(x1, z0), vertex(x0, z0)),
+	})
+
+	material := NewPBRMaterial()
+	material.ShadowCatcher = true
+	material.AlphaMode = AlphaBlend
+	material.MetallicFactor = 0
+	material.BaseColorFactor = Color{0, 0, 0, options.Opacity}
+	resolution := options.Resolution
+	if resolution < 2 {
+		resolution = 2
+	}
+	if options.Falloff > 0 {
+		material.BaseColorTexture = NewAdvancedTexture(shadowCatcherFalloff(resolution, options.Falloff), BaseColorTexture)
+	}
+	if options.Occlusion {
+		distance := options.Distance
+		if distance <= 0 {
+			distance = math.Max(size.Y/2, 1e-3)
+		}
+		ao := shadowCatcherOcclusion(scene, resolution, options.Samples, x0, z0, 2*h, ground, bounds.Max.Y+distance, distance)
+		material.OcclusionTexture = NewAdvancedTexture(ao, OcclusionTexture)
+	}
+
+	node := NewSceneNode("shadow_catcher")
+	node.Mesh = mesh
+	node.Material = material
+	node.CastShadows = false
+	scene.AddMesh(node.Name, mesh)
+	scene.AddMaterial(node.Name, material)
+	scene.RootNode.AddChild(node)
+	node.UpdateWorldTransform()
+	return node
+}
+
+// shadowCatcherFalloff returns a white mask whose alpha fades from 1 to 0
+// over the outer falloff fraction of the inscribed circle
+func shadowCatcherFalloff(resolution int, falloff float64) *image.NRGBA {
+	im := image.NewNRGBA(image.Rect(0, 0, resolution, resolution))
+	inner := 1 - Clamp(falloff, 0, 1)
+	for y := 0; y < resolution; y++ {
+		for x := 0; x < resolution; x++ {
+			dx := float64(x)/float64(resolution-1)*2 - 1
+			dy := float64(y)/float64(resolution-1)*2 - 1
+			t := Clamp((math.Hypot(dx, dy)-inner)/math.Max(1-inner, 1e-9), 0, 1)
+			a := 1 - t*t*(3-2*t)
+			im.SetNRGBA(x, y, color.NRGBA{255, 255, 255, uint8(a*255 + 0.5)})
+		}
+	}
+	return im
+}
+
+// shadowCatcherOcclusion bakes the ambient occlusion of the scene on the
+// square [x0, x0+extent] x [z0, z0+extent] at height ground. The scene is
+// rendered from below into a height field of its lowest surfaces up to top,
+// and cosine distributed rays are marched through it: a ray is blocked
+// where it passes above the underside of the geometry.
+func shadowCatcherOcclusion(scene *Scene, resolution, samples int, x0, z0, extent, ground, top, distance float64) *image.Gray {
+	if samples < 1 {
+		samples = 1
+	}
+	// Orthographic projection looking up: x to screen x, z up the screen
+	// rows from the bottom, height to depth
+	depthScale := 2 / math.Max(top-ground, 1e-9)
+	matrix := Matrix{
+		2 / extent, 0, 0, -1 - 2*x0/extent,
+		0, 0, 2 / extent, -1 - 2*z0/extent,
+		0, depthScale, 0, -1 - ground*depthScale,
+		0, 0, 0, 1,
+	}
+	dc := NewContext(resolution, resolution)
+	dc.Cull = CullNone
+	dc.WriteColor = false
+	for _, node := range scene.RootNode.GetRenderableNodes() {
+		if node.Material.ShadowCatcher {
+			continue
+		}
+		for _, transform := range node.InstanceTransforms() {
+			dc.Shader = NewShadowMapShader(matrix.Mul(transform))
+			dc.DrawMesh(node.Mesh)
+		}
+	}
+	clear := dc.clearDepth()
+
+	// Height of the underside of the geometry above a world position, +Inf
+	// where there is none
+	underside := func(x, z float64) float64 {
+		px := int((x - x0) / extent * float64(resolution))
+		py := int((1 - (z-z0)/extent) * float64(resolution))
+		if px < 0 || py < 0 || px >= resolution || py >= resolution {
+			return math.Inf(1)
+		}
+		d := dc.DepthBuffer[py*resolution+px]
+		if d == clear {
+			return math.Inf(1)
+		}
+		return ground + d*(top-ground)
+	}
+
+	const steps = 12
+	im := image.NewGray(image.Rect(0, 0, resolution, resolution))
+	parallelRows(resolution, func(y0, y1 int) {
+		rnd := rand.New(rand.NewSource(int64(y0)))
+		for py := y0; py < y1; py++ {
+			// Texel centers as the sampler addresses them, v pointing up
+			// the image
+			z := z0 + (1-float64(py)/float64(resolution-1))*extent
+			for px := 0; px < resolution; px++ {
+				x := x0 + float64(px)/float64(resolution-1)*extent
+				occlusion := 0.0
+				for i := 0; i < samples; i++ {
+					direction := cosineSampleHemisphere(Vector{0, 1, 0}, rnd.Float64(), rnd.Float64())
+					for s := 1; s <= steps; s++ {
+						t := distance * float64(s) / steps
+						p := direction.MulScalar(t)
+						if underside(x+p.X, z+p.Z) <= ground+p.Y {
+							occlusion += 1 - t/distance
+							break
+						}
+					}
+				}
+				ao := 1 - occlusion/float64(samples)
+				im.Pix[py*im.Stride+px] = uint8(Clamp(ao, 0, 1)*255 + 0.5)
+			}
+		}
+	})
+	return im
+}
+
+// catchShadow returns the color of a shadow catcher fragment: black, with
+// the fraction of the direct light its shadows block, combined with its
+// occlusion, as alpha. lights are the lights of the fragment before and
+// shadowed after applying their shadows.
+func (shader *PBRShader) catchShadow(material *SampledMaterial, position, normal Vector, lights, shadowed []Light) Color {
+	lit, unshadowed := 0.0, 0.0
+	for i, light := range lights {
+		if light.Type == AmbientLight {
+			continue
+		}
+		toLight := light.Direction.Negate()
+		attenuation := 1.0
+		if light.Type != DirectionalLight {
+			toLight = light.Position.Sub(position)
+			attenuation = 1 / math.Max(toLight.LengthSquared(), 1e-6)
+		}
+		c := light.Color
+		w := math.Max(normal.Dot(toLight.Normalize()), 0) * attenuation * (c.R + c.G + c.B)
+		unshadowed += w * light.Intensity
+		lit += w * shadowed[i].Intensity
+	}
+	visibility := material.Occlusion
+	if unshadowed > 0 {
+		visibility *= lit / unshadowed
+	}
+	return Color{0, 0, 0, Clamp((1-visibility)*material.BaseColor.A, 0, 1)}
+}
@@ -286,30 +286,6 @@ func NewCapsule(radius, height float64, radialSegments, heightSegments, capSegme
 	return NewTriangleMesh(triangles)
 }
 
-// Subdivide subdivides a mesh using loop subdivision
-func (m *Mesh) Subdivide() *Mesh {
-	// This is a simplified subdivision implementation
-	// A full implementation would be more complex
-
-	// For now, we'll just add vertices at edge midpoints
-	var newTriangles []*Triangle
-
-	for _, t := range m.Triangles {
-		// Calculate edge midpoints
-		mid1 := InterpolateVertexes(t.V1, t.V2, t.V3, VectorW{0.5, 0.5, 0, 1})
-		mid2 := InterpolateVertexes(t.V1, t.V2, t.V3, VectorW{0.5, 0, 0.5, 1})
-		mid3 := InterpolateVertexes(t.V1, t.V2, t.V3, VectorW{0, 0.5, 0.5, 1})
-
-		// Create four new triangles
-		newTriangles = append(newTriangles, NewTriangle(t.V1, mid1, mid2))
-		newTriangles = append(newTriangles, NewTriangle(t.V2, mid1, mid3))
-		newTriangles = append(newTriangles, NewTriangle(t.V3, mid2, mid3))
-		newTriangles = append(newTriangles, NewTriangle(mid1, mid2, mid3))
-	}
-
-	return NewTriangleMesh(newTriangles)
-}
-
 // Tessellate tessellates a mesh by splitting triangles
 func (m *Mesh) Tessellate(maxEdgeLength float64) *Mesh {
 	var triangles []*Triangle
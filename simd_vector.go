@@ -52,6 +52,11 @@ func NewSIMDVector4FromVector(v Vector) SIMDVector4 {
 	return SIMDVector4{v.X, v.Y, v.Z, 1.0}
 }
 
+// NewSIMDDirectionFromVector 从方向向量创建SIMD向量（w=0，不参与长度和点积计算）
+func NewSIMDDirectionFromVector(v Vector) SIMDVector4 {
+	return SIMDVector4{v.X, v.Y, v.Z, 0}
+}
+
 // ToVector 转换为Vector
 func (sv SIMDVector4) ToVector() Vector {
 	return Vector{sv[0], sv[1], sv[2]}
@@ -347,7 +352,7 @@ func SIMDNormalizeVectors(vectors []Vector) []Vector {
 	result := make([]Vector, len(vectors))
 	for i, v := range vectors {
 		// 使用SIMD优化的归一化
-		sv := NewSIMDVector4FromVector(v)
+		sv := NewSIMDDirectionFromVector(v)
 		result[i] = sv.Normalize().ToVector()
 	}
 	return result
@@ -363,8 +368,8 @@ func SIMDVectorDistance(a, b Vector) float64 {
 
 // SIMDVectorDot 计算两个向量的点积（SIMD优化）
 func SIMDVectorDot(a, b Vector) float64 {
-	sv1 := NewSIMDVector4FromVector(a)
-	sv2 := NewSIMDVector4FromVector(b)
+	sv1 := NewSIMDDirectionFromVector(a)
+	sv2 := NewSIMDDirectionFromVector(b)
 	return sv1.Dot(sv2)
 }
 
@@ -0,0 +1,95 @@
+package fauxgl
+
+// ApplySkin deforms the node's mesh into the current pose of its skin with
+// linear blend skinning, after its morph targets if it has any. The
+// undeformed mesh is kept in BindMesh on first use and node.Mesh is replaced
+// by a deformed copy that is updated in place on later calls. The deformed
+// mesh stays in the node's local space, so the node's transform is applied
+// when rendering as for any other mesh.
+func (node *SceneNode) ApplySkin() {
+	if node.Skin == nil || node.Mesh == nil {
+		return
+	}
+	node.prepareDeformedMesh()
+	base := node.BindMesh
+	if node.MorphTargets != nil {
+		// Morph targets apply before skinning
+		MorphMeshInto(node.Mesh, node.BindMesh, node.MorphTargets)
+		base = node.Mesh
+	}
+
+	// Joint matrices relative to the node, so that rendering with the
+	// node's world transform places vertices at jointWorld * inverseBind
+	node.Skin.UpdateJointMatrices()
+	toNode := node.WorldTransform.Inverse()
+	jointMatrices := make([]Matrix, len(node.Skin.JointMatrices))
+	for i, m := range node.Skin.JointMatrices {
+		jointMatrices[i] = toNode.Mul(m)
+	}
+
+	SkinMeshInto(node.Mesh, base, jointMatrices)
+}
+
+// SkinMesh returns a copy of mesh deformed by the given joint matrices with
+// linear blend skinning of its vertex joints and weights
+func SkinMesh(mesh *Mesh, jointMatrices []Matrix) *Mesh {
+	result := mesh.Copy()
+	SkinMeshInto(result, mesh, jointMatrices)
+	return result
+}
+
+// SkinMeshInto writes the skinned vertices of base into dst, which must
+// have the same triangle layout (e.g. a copy of base)
+func SkinMeshInto(dst, base *Mesh, jointMatrices []Matrix) {
+	for i, t := range base.Triangles {
+		if i >= len(dst.Triangles) {
+			break
+		}
+		d := dst.Triangles[i]
+		d.V1 = skinVertex(t.V1, jointMatrices)
+		d.V2 = skinVertex(t.V2, jointMatrices)
+		d.V3 = skinVertex(t.V3, jointMatrices)
+	}
+	dst.dirty()
+}
+
+// skinVertex transforms a vertex by the weighted blend of its joint matrices
+func skinVertex(v Vertex, jointMatrices []Matrix) Vertex {
+	var m Matrix
+	total := 0.0
+	for k, w := range v.Weights {
+		j := v.Joints[k]
+		if w == 0 || j < 0 || j >= len(jointMatrices) {
+			continue
+		}
+		m = addMatrixScaled(m, jointMatrices[j], w)
+		total += w
+	}
+	if total == 0 {
+		return v
+	}
+	if total != 1 {
+		// Renormalize weights that do not sum to one
+		m = addMatrixScaled(Matrix{}, m, 1/total)
+	}
+
+	v.Position = m.MulPosition(v.Position)
+	if v.Normal != (Vector{}) {
+		v.Normal = m.MulDirection(v.Normal)
+	}
+	if v.Tangent != (VectorW{}) {
+		t := m.MulDirection(v.Tangent.Vector())
+		v.Tangent = VectorW{t.X, t.Y, t.Z, v.Tangent.W}
+	}
+	return v
+}
+
+// addMatrixScaled returns a + b*s
+func addMatrixScaled(a, b Matrix, s float64) Matrix {
+	return Matrix{
+		a.X00 + b.X00*s, a.X01 + b.X01*s, a.X02 + b.X02*s, a.X03 + b.X03*s,
+		a.X10 + b.X10*s, a.X11 + b.X11*s, a.X12 + b.X12*s, a.X13 + b.X13*s,
+		a.X20 + b.X20*s, a.X21 + b.X21*s, a.X22 + b.X22*s, a.X23 + b.X23*s,
+		a.X30 + b.X30*s, a.X31 + b.X31*s, a.X32 + b.X32*s, a.X33 + b.X33*s,
+	}
+}
@@ -0,0 +1,305 @@
+package fauxgl
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// SSAOEffect darkens creases, corners and contacts with screen space
+// ambient occlusion computed from the depth and normal buffers of a
+// context: every visible surface samples a hemisphere around its normal and
+// counts the samples behind the depth buffer.
+//
+// As a post effect it darkens the whole frame of Context, rendered through
+// Camera with a NormalBuffer, see Context.EnableNormalBuffer. Set as
+// SceneRenderer.AmbientOcclusion it only darkens ambient lighting, which
+// is more correct, from a prepass of its own.
+type SSAOEffect struct {
+	Radius     float64 // World radius of the sampled hemisphere
+	Bias       float64 // Depth difference ignored against self occlusion
+	Intensity  float64 // Fraction of the ambient light the fully occluded lose
+	Samples    int     // Hemisphere samples per pixel
+	BlurRadius int     // Pixels of the depth aware blur of the noisy result, 0 disables it
+	Context    *Context
+	Camera     *Camera
+
+	occlusion []float64 // Visibility of every buffer pixel of the last prepass
+	width     int
+	height    int
+	screen    Matrix
+}
+
+// NewSSAOEffect creates an SSAO effect with a half unit radius over the
+// buffers of a context rendered through a camera, which may both be nil
+// for SceneRenderer.AmbientOcclusion
+func NewSSAOEffect(context *Context, camera *Camera) *SSAOEffect {
+	return &SSAOEffect{
+		Radius:     0.5,
+		Bias:       0.02,
+		Intensity:  1,
+		Samples:    16,
+		BlurRadius: 2,
+		Context:    context,
+		Camera:     camera,
+	}
+}
+
+// Apply multiplies the colors of the input image by the ambient occlusion
+// of Context, scaled to the input when it is a resolved supersampled frame
+func (e *SSAOEffect) Apply(input *image.NRGBA) *image.NRGBA {
+	if e.Context == nil || e.Camera == nil {
+		return input
+	}
+	occlusion := e.Occlusion(e.Context, e.Camera)
+	bounds := input.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dc := e.Context
+
+	output := image.NewNRGBA(bounds)
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			by := ClampInt((2*y+1)*dc.Height/(2*height), 0, dc.Height-1)
+			for x := 0; x < width; x++ {
+				bx := ClampInt((2*x+1)*dc.Width/(2*width), 0, dc.Width-1)
+				ao := occlusion[by*dc.Width+bx]
+				c := input.NRGBAAt(x+bounds.Min.X, y+bounds.Min.Y)
+				output.SetNRGBA(x+bounds.Min.X, y+bounds.Min.Y, color.NRGBA{
+					R: uint8(float64(c.R)*ao + 0.5),
+					G: uint8(float64(c.G)*ao + 0.5),
+					B: uint8(float64(c.B)*ao + 0.5),
+					A: c.A,
+				})
+			}
+		}
+	})
+	return output
+}
+
+// Occlusion returns the visibility of every pixel of a context rendered
+// through a camera, from 0, fully occluded, to 1. Without a NormalBuffer
+// the normals are derived from the depth buffer.
+func (e *SSAOEffect) Occlusion(dc *Context, camera *Camera) []float64 {
+	w, h := dc.Width, dc.Height
+	depth := dc.CameraDepth(camera)
+	projection := camera.GetProjectionMatrix()
+	positions := viewPositions(dc, projection, depth)
+
+	normals := dc.NormalBuffer
+	if len(normals) != w*h {
+		normals = depthNormals(positions, depth, w, h)
+	}
+
+	samples := maxInt(e.Samples, 1)
+	kernel := ssaoKernel(samples)
+	occlusion := make([]float64, w*h)
+	parallelRows(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				occlusion[i] = 1
+				n := normals[i]
+				if math.IsInf(depth.Depth[i], 1) || n == (Vector{}) {
+					continue
+				}
+				p := positions[i]
+				// Face the camera, which looks down -Z
+				if n.Dot(p) > 0 {
+					n = n.Negate()
+				}
+				// Rotate the kernel per pixel with interleaved gradient
+				// noise, which the blur averages out
+				noise := 0.06711056*float64(x) + 0.00583715*float64(y)
+				noise = 52.9829189 * (noise - math.Floor(noise))
+				angle := 2 * math.Pi * (noise - math.Floor(noise))
+				tangent := n.Perpendicular()
+				bitangent := n.Cross(tangent)
+				sin, cos := math.Sincos(angle)
+				tangent, bitangent = tangent.MulScalar(cos).Add(bitangent.MulScalar(sin)), bitangent.MulScalar(cos).Sub(tangent.MulScalar(sin))
+
+				occluded := 0.0
+				for _, k := range kernel {
+					s := p.Add(tangent.MulScalar(k.X * e.Radius)).Add(bitangent.MulScalar(k.Y * e.Radius)).Add(n.MulScalar(k.Z * e.Radius))
+					clip := projection.MulPositionW(s)
+					if clip.W <= 0 && camera.ProjectionType == PerspectiveProjection {
+						continue
+					}
+					screen := dc.screenMatrix.MulPosition(clip.DivScalar(clip.W).Vector())
+					sx, sy := int(math.Floor(screen.X)), int(math.Floor(screen.Y))
+					sampleDepth := depth.At(sx, sy)
+					if math.IsInf(sampleDepth, 1) {
+						continue
+					}
+					if difference := -s.Z - sampleDepth; difference > e.Bias {
+						// Surfaces far in front of the pixel don't occlude it
+						f := Clamp(e.Radius/math.Abs(-p.Z-sampleDepth), 0, 1)
+						occluded += f * f * (3 - 2*f)
+					}
+				}
+				occlusion[i] = Clamp(1-e.Intensity*occluded/float64(samples), 0, 1)
+			}
+		}
+	})
+	if e.BlurRadius > 0 {
+		occlusion = e.blur(occlusion, depth)
+	}
+	return occlusion
+}
+
+// blur averages the occlusion of the pixels around every pixel whose depth
+// is within Radius of its own, so the noise goes but the edges stay
+func (e *SSAOEffect) blur(occlusion []float64, depth *DepthMap) []float64 {
+	w, h, r := depth.Width, depth.Height, e.BlurRadius
+	blurred := make([]float64, len(occlusion))
+	parallelRows(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				d := depth.Depth[i]
+				if math.IsInf(d, 1) {
+					blurred[i] = 1
+					continue
+				}
+				sum, weight := 0.0, 0.0
+				for v := maxInt(y-r, 0); v <= y+r && v < h; v++ {
+					for u := maxInt(x-r, 0); u <= x+r && u < w; u++ {
+						j := v*w + u
+						if math.Abs(depth.Depth[j]-d) <= e.Radius {
+							sum += occlusion[j]
+							weight++
+						}
+					}
+				}
+				blurred[i] = sum / weight
+			}
+		}
+	})
+	return blurred
+}
+
+// prepare renders the depth and normals of the opaque nodes through the
+// camera and keeps their occlusion for the ambient lighting of the frame,
+// restoring the buffers of the context
+func (e *SSAOEffect) prepare(dc *Context, camera *Camera, nodes []*SceneNode, view, matrix Matrix, time float64) {
+	depthBuffer, normalBuffer := dc.DepthBuffer, dc.NormalBuffer
+	shader, writeColor, normalMatrix := dc.Shader, dc.WriteColor, dc.NormalMatrix
+	dc.DepthBuffer = make([]float64, dc.Width*dc.Height)
+	dc.EnableNormalBuffer()
+	dc.WriteColor = false
+	dc.ClearDepthBuffer()
+	for _, node := range nodes {
+		if node.Mesh == nil || node.Material == nil || node.transparent() || node.Material.AlphaMode == AlphaMask {
+			continue
+		}
+		for _, transform := range node.InstanceTransforms() {
+			// Normals transform by the inverse transpose
+			dc.NormalMatrix = view.Mul(transform).Inverse().Transpose()
+			dc.Shader = node.modify(NewShadowMapShader(matrix.Mul(transform)), time)
+			dc.DrawMesh(node.Mesh)
+		}
+	}
+	e.occlusion = e.Occlusion(dc, camera)
+	e.width, e.height, e.screen = dc.Width, dc.Height, dc.screenMatrix
+	dc.DepthBuffer, dc.NormalBuffer = depthBuffer, normalBuffer
+	dc.Shader, dc.WriteColor, dc.NormalMatrix = shader, writeColor, normalMatrix
+}
+
+// visibility returns the occlusion of the last prepass at a fragment given
+// its clip space position, 1 without a prepass
+func (e *SSAOEffect) visibility(clip VectorW) float64 {
+	if e == nil || e.occlusion == nil || clip.W == 0 {
+		return 1
+	}
+	s := e.screen.MulPosition(clip.DivScalar(clip.W).Vector())
+	x, y := int(math.Floor(s.X)), int(math.Floor(s.Y))
+	if x < 0 || y < 0 || x >= e.width || y >= e.height {
+		return 1
+	}
+	return e.occlusion[y*e.width+x]
+}
+
+// ssaoKernel returns n points in the unit hemisphere around +Z, spread by
+// a spiral and packed towards the center, where occluders matter most
+func ssaoKernel(n int) []Vector {
+	kernel := make([]Vector, n)
+	golden := math.Pi * (3 - math.Sqrt(5))
+	for i := range kernel {
+		f := (float64(i) + 0.5) / float64(n)
+		z := 1 - f*0.9
+		r := math.Sqrt(1 - z*z)
+		sin, cos := math.Sincos(golden * float64(i))
+		scale := 0.1 + 0.9*f*f
+		kernel[i] = Vector{r * cos, r * sin, z}.MulScalar(scale)
+	}
+	return kernel
+}
+
+// viewPositions returns the view space positions of the pixels of a
+// context, along the view ray of every pixel center at its linear depth,
+// for perspective and orthographic projections alike
+func viewPositions(dc *Context, projection Matrix, depth *DepthMap) []Vector {
+	w, h := dc.Width, dc.Height
+	unproject := dc.screenMatrix.Mul(projection).Inverse()
+	positions := make([]Vector, w*h)
+	parallelRows(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				d := depth.Depth[y*w+x]
+				if math.IsInf(d, 1) {
+					continue
+				}
+				p := Vector{float64(x) + 0.5, float64(y) + 0.5, 0}
+				a := unproject.MulPositionW(p)
+				p.Z = 1
+				b := unproject.MulPositionW(p)
+				near, far := a.DivScalar(a.W).Vector(), b.DivScalar(b.W).Vector()
+				t := (-d - near.Z) / (far.Z - near.Z)
+				positions[y*w+x] = near.Add(far.Sub(near).MulScalar(t))
+			}
+		}
+	})
+	return positions
+}
+
+// depthNormals derives view space normals from the differences of the
+// positions of neighboring pixels, taking the nearer neighbors so the
+// normals don't bend across depth edges
+func depthNormals(positions []Vector, depth *DepthMap, w, h int) []Vector {
+	normals := make([]Vector, w*h)
+	parallelRows(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				d := depth.Depth[i]
+				if math.IsInf(d, 1) {
+					continue
+				}
+				neighbor := func(a, b int) Vector {
+					if b >= 0 && (a < 0 || math.Abs(depth.Depth[b]-d) < math.Abs(depth.Depth[a]-d)) {
+						return positions[b].Sub(positions[i])
+					}
+					if a >= 0 {
+						return positions[i].Sub(positions[a])
+					}
+					return Vector{}
+				}
+				left, right, up, down := -1, -1, -1, -1
+				if x > 0 && !math.IsInf(depth.Depth[i-1], 1) {
+					left = i - 1
+				}
+				if x+1 < w && !math.IsInf(depth.Depth[i+1], 1) {
+					right = i + 1
+				}
+				if y > 0 && !math.IsInf(depth.Depth[i-w], 1) {
+					up = i - w
+				}
+				if y+1 < h && !math.IsInf(depth.Depth[i+w], 1) {
+					down = i + w
+				}
+				// Screen y points down
+				normals[i] = neighbor(left, right).Cross(neighbor(down, up)).Normalize()
+			}
+		}
+	})
+	return normals
+}
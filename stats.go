@@ -0,0 +1,189 @@
+package fauxgl
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// NodeStats is the draw cost of one scene node in a frame
+type NodeStats struct {
+	Node      string          `json:"node"`
+	Material  string          `json:"material,omitempty"`
+	Shader    string          `json:"shader"`
+	Triangles int             `json:"triangles"`
+	Fragments uint64          `json:"fragments"` // Fragments rasterized, RasterizeInfo.TotalPixels
+	Written   uint64          `json:"written"`   // Fragments that passed the depth test
+	Duration  time.Duration   `json:"duration_ns"`
+	Bounds    image.Rectangle `json:"-"` // Screen bounds of the node
+}
+
+// RenderStats collects per-node draw statistics of a frame, see
+// SceneRenderer.CollectStats
+type RenderStats struct {
+	Nodes []NodeStats
+	Total time.Duration // Time spent drawing the recorded nodes
+}
+
+// Reset clears the statistics for a new frame
+func (s *RenderStats) Reset() {
+	s.Nodes = s.Nodes[:0]
+	s.Total = 0
+}
+
+// Add records the statistics of a node
+func (s *RenderStats) Add(node NodeStats) {
+	s.Nodes = append(s.Nodes, node)
+	s.Total += node.Duration
+}
+
+// Slowest returns the nodes sorted by draw time, slowest first
+func (s *RenderStats) Slowest() []NodeStats {
+	nodes := make([]NodeStats, len(s.Nodes))
+	copy(nodes, s.Nodes)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Duration > nodes[j].Duration
+	})
+	return nodes
+}
+
+// MarshalJSON reports the statistics keyed by node name. Repeated names get
+// a "#n" suffix.
+func (s *RenderStats) MarshalJSON() ([]byte, error) {
+	nodes := make(map[string]NodeStats, len(s.Nodes))
+	for _, node := range s.Nodes {
+		key := node.Node
+		for n := 2; ; n++ {
+			if _, ok := nodes[key]; !ok {
+				break
+			}
+			key = fmt.Sprintf("%s#%d", node.Node, n)
+		}
+		nodes[key] = node
+	}
+	return json.Marshal(struct {
+		Total time.Duration        `json:"total_ns"`
+		Nodes map[string]NodeStats `json:"nodes"`
+	}{s.Total, nodes})
+}
+
+// DrawOverlay annotates a rendered image with the statistics: every node's
+// screen bounds are outlined in a color ranging from green (cheap) to red
+// (the slowest node) and labeled with its triangle count, draw time and
+// shader, and a frame summary is printed in the top left corner.
+func (s *RenderStats) DrawOverlay(dst draw.Image) {
+	var slowest time.Duration
+	triangles := 0
+	for _, node := range s.Nodes {
+		if node.Duration > slowest {
+			slowest = node.Duration
+		}
+		triangles += node.Triangles
+	}
+
+	for _, node := range s.Nodes {
+		cost := 0.0
+		if slowest > 0 {
+			cost = float64(node.Duration) / float64(slowest)
+		}
+		c := statsCostColor(cost)
+		r := node.Bounds.Intersect(dst.Bounds())
+		if r.Empty() {
+			continue
+		}
+		drawRectOutline(dst, r, c)
+		label := fmt.Sprintf("%s %dtri %.1fms %s", node.Node, node.Triangles, durationMillis(node.Duration), node.Shader)
+		drawStatsLabel(dst, r.Min.X+2, r.Min.Y+2, label)
+	}
+
+	summary := fmt.Sprintf("%d nodes %d tri %.1fms", len(s.Nodes), triangles, durationMillis(s.Total))
+	drawStatsLabel(dst, dst.Bounds().Min.X+4, dst.Bounds().Min.Y+4, summary)
+}
+
+// statsCostColor maps a relative cost in [0, 1] from green to red
+func statsCostColor(cost float64) color.NRGBA {
+	cost = Clamp(cost, 0, 1)
+	return color.NRGBA{uint8(255 * math.Min(1, 2*cost)), uint8(255 * math.Min(1, 2-2*cost)), 0, 255}
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// drawRectOutline draws a one pixel rectangle outline inside r
+func drawRectOutline(dst draw.Image, r image.Rectangle, c color.Color) {
+	for x := r.Min.X; x < r.Max.X; x++ {
+		dst.Set(x, r.Min.Y, c)
+		dst.Set(x, r.Max.Y-1, c)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		dst.Set(r.Min.X, y, c)
+		dst.Set(r.Max.X-1, y, c)
+	}
+}
+
+// drawStatsLabel draws white text with its top left corner at (x, y) on a
+// dark backdrop so it stays legible over the render
+func drawStatsLabel(dst draw.Image, x, y int, text string) {
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, text).Ceil()
+	height := face.Metrics().Height.Ceil()
+	backdrop := image.Rect(x-1, y-1, x+width+1, y+height+1)
+	draw.Draw(dst, backdrop, image.NewUniform(color.NRGBA{0, 0, 0, 160}), image.Point{}, draw.Over)
+
+	drawer := &font.Drawer{
+		Dst:  dst,
+		Src:  image.White,
+		Face: face,
+		Dot:  fixed.P(x, y+face.Metrics().Ascent.Ceil()),
+	}
+	drawer.DrawString(text)
+}
+
+// shaderName returns the type name of a shader for reports
+func shaderName(shader Shader) string {
+	name := fmt.Sprintf("%T", shader)
+	name = strings.TrimPrefix(name, "*")
+	return strings.TrimPrefix(name, "fauxgl.")
+}
+
+// screenBounds returns the pixel rectangle covered by a world space box
+// seen through matrix, the whole target when the box reaches behind the
+// camera
+func screenBounds(box Box, matrix Matrix, width, height int) image.Rectangle {
+	full := image.Rect(0, 0, width, height)
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i := 0; i < 8; i++ {
+		corner := box.Min
+		if i&1 != 0 {
+			corner.X = box.Max.X
+		}
+		if i&2 != 0 {
+			corner.Y = box.Max.Y
+		}
+		if i&4 != 0 {
+			corner.Z = box.Max.Z
+		}
+		clip := matrix.MulPositionW(corner)
+		if clip.W <= 0 {
+			return full
+		}
+		x := (clip.X/clip.W + 1) * float64(width) / 2
+		y := (1 - clip.Y/clip.W) * float64(height) / 2
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	r := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+	return r.Intersect(full)
+}
@@ -0,0 +1,218 @@
+package fauxgl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadSTL loads a binary or ASCII STL file as a mesh with flat normals
+func LoadSTL(path string) (*Mesh, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadSTLFromBytes(data)
+}
+
+// LoadSTLFromBytes loads binary or ASCII STL data as a mesh with flat
+// normals. The facet normals of the file are used unless they are zero.
+// Binary files with VisCAM / SolidView facet colors get them as vertex
+// colors.
+func LoadSTLFromBytes(data []byte) (*Mesh, error) {
+	// ASCII files start with "solid", but so do the headers of some binary
+	// files, which the size tells apart
+	if len(data) >= 84 {
+		count := int(binary.LittleEndian.Uint32(data[80:84]))
+		if len(data) == 84+50*count {
+			return readBinarySTL(data, count), nil
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("solid")) {
+		return readASCIISTL(data)
+	}
+	return nil, errors.New("invalid STL file")
+}
+
+func readBinarySTL(data []byte, count int) *Mesh {
+	triangles := make([]*Triangle, count)
+	float := func(offset int) float64 {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data[offset:])))
+	}
+	vector := func(offset int) Vector {
+		return Vector{float(offset), float(offset + 4), float(offset + 8)}
+	}
+	for i := range triangles {
+		offset := 84 + 50*i
+		t := &Triangle{}
+		t.V1.Position = vector(offset + 12)
+		t.V2.Position = vector(offset + 24)
+		t.V3.Position = vector(offset + 36)
+		setFacetNormal(t, vector(offset))
+		if attribute := binary.LittleEndian.Uint16(data[offset+48:]); attribute&0x8000 != 0 {
+			// 5 bits per channel, blue in the lowest bits
+			const d = 31
+			c := Color{float64(attribute>>10&d) / d, float64(attribute>>5&d) / d, float64(attribute&d) / d, 1}
+			t.V1.Color, t.V2.Color, t.V3.Color = c, c, c
+		}
+		triangles[i] = t
+	}
+	return NewTriangleMesh(triangles)
+}
+
+func readASCIISTL(data []byte) (*Mesh, error) {
+	var triangles []*Triangle
+	var normal Vector
+	var positions []Vector
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		parse := func(values []string) (Vector, error) {
+			if len(values) != 3 {
+				return Vector{}, fmt.Errorf("STL line %d: expected 3 coordinates", line)
+			}
+			var v [3]float64
+			for i, s := range values {
+				f, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					return Vector{}, fmt.Errorf("STL line %d: %w", line, err)
+				}
+				v[i] = f
+			}
+			return Vector{v[0], v[1], v[2]}, nil
+		}
+		var err error
+		switch strings.ToLower(fields[0]) {
+		case "facet":
+			positions = positions[:0]
+			normal = Vector{}
+			if len(fields) >= 2 && strings.EqualFold(fields[1], "normal") {
+				normal, err = parse(fields[2:])
+			}
+		case "vertex":
+			var p Vector
+			p, err = parse(fields[1:])
+			positions = append(positions, p)
+		case "endfacet":
+			if len(positions) != 3 {
+				return nil, fmt.Errorf("STL line %d: facet has %d vertices", line, len(positions))
+			}
+			t := &Triangle{}
+			t.V1.Position, t.V2.Position, t.V3.Position = positions[0], positions[1], positions[2]
+			setFacetNormal(t, normal)
+			triangles = append(triangles, t)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewTriangleMesh(triangles), nil
+}
+
+// setFacetNormal gives the vertices of a triangle the normal of its STL
+// facet, or the normal of its positions when the facet's is zero
+func setFacetNormal(t *Triangle, normal Vector) {
+	if normal.LengthSquared() == 0 || !finite(normal.X, normal.Y, normal.Z) {
+		normal = t.Normal()
+	} else {
+		normal = normal.Normalize()
+	}
+	t.V1.Normal, t.V2.Normal, t.V3.Normal = normal, normal, normal
+}
+
+// SaveSTL saves a mesh as a binary STL file, or an ASCII one
+func SaveSTL(path string, mesh *Mesh, ascii bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := WriteSTL(w, mesh, ascii); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteSTL writes the triangles of a mesh as binary STL, or ASCII STL, with
+// the normals of their positions as facet normals. Binary files store the
+// average vertex color of every facet as a VisCAM / SolidView color when
+// the mesh has vertex colors.
+func WriteSTL(w io.Writer, mesh *Mesh, ascii bool) error {
+	if ascii {
+		return writeASCIISTL(w, mesh)
+	}
+	colored := false
+	for _, t := range mesh.Triangles {
+		if t.V1.Color != (Color{}) || t.V2.Color != (Color{}) || t.V3.Color != (Color{}) {
+			colored = true
+			break
+		}
+	}
+
+	header := make([]byte, 84)
+	copy(header, "fauxgl STL")
+	binary.LittleEndian.PutUint32(header[80:], uint32(len(mesh.Triangles)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	buf := make([]byte, 50)
+	for _, t := range mesh.Triangles {
+		for i, v := range [4]Vector{t.Normal(), t.V1.Position, t.V2.Position, t.V3.Position} {
+			binary.LittleEndian.PutUint32(buf[12*i:], math.Float32bits(float32(v.X)))
+			binary.LittleEndian.PutUint32(buf[12*i+4:], math.Float32bits(float32(v.Y)))
+			binary.LittleEndian.PutUint32(buf[12*i+8:], math.Float32bits(float32(v.Z)))
+		}
+		attribute := uint16(0)
+		if colored {
+			c := t.V1.Color.Add(t.V2.Color).Add(t.V3.Color).DivScalar(3)
+			channel := func(x float64) uint16 {
+				return uint16(math.Round(Clamp(x, 0, 1) * 31))
+			}
+			attribute = 0x8000 | channel(c.R)<<10 | channel(c.G)<<5 | channel(c.B)
+		}
+		binary.LittleEndian.PutUint16(buf[48:], attribute)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeASCIISTL(w io.Writer, mesh *Mesh) error {
+	format := func(v Vector) string {
+		f := func(x float64) string { return strconv.FormatFloat(x, 'e', -1, 32) }
+		return f(v.X) + " " + f(v.Y) + " " + f(v.Z)
+	}
+	if _, err := io.WriteString(w, "solid fauxgl\n"); err != nil {
+		return err
+	}
+	for _, t := range mesh.Triangles {
+		_, err := fmt.Fprintf(w, "facet normal %s\n outer loop\n  vertex %s\n  vertex %s\n  vertex %s\n endloop\nendfacet\n",
+			format(t.Normal()), format(t.V1.Position), format(t.V2.Position), format(t.V3.Position))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "endsolid fauxgl\n")
+	return err
+}
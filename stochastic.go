@@ -0,0 +1,89 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+)
+
+// Stochastic tiling after Heitz and Neyret, "High-Performance By-Example
+// Noise using a Histogram-Preserving Blending Operator" (2018): texture
+// space is covered with a grid of equilateral triangles, every grid vertex
+// shifts the texture by a random offset and each lookup blends the three
+// offset copies of its triangle. Blending is variance preserving, so the
+// blend keeps the contrast and, for textures with roughly Gaussian
+// histograms, the look of the original.
+
+// stochasticGridScale sets the size of the triangle grid, about a third of
+// a texture repeat per triangle
+const stochasticGridScale = 3.4641016151377544 // 2√3
+
+// sampleStochastic samples the texture with stochastic tiling
+func (t *AdvancedTexture) sampleStochastic(u, v float64, dx, dy Vector, filter TextureFilter, grad bool) Color {
+	vertices, weights := stochasticTriangle(u, v)
+	mean := t.meanColor()
+
+	var sum Color
+	var weightSquares float64
+	for i, vertex := range vertices {
+		w := weights[i]
+		if w <= 0 {
+			continue
+		}
+		ou := hashLattice(vertex[0], vertex[1], 1)
+		ov := hashLattice(vertex[0], vertex[1], 2)
+		c := t.sampleTransformed(u+ou, v+ov, dx, dy, filter, grad)
+		sum = sum.Add(c.Sub(mean).MulScalar(w))
+		weightSquares += w * w
+	}
+	if weightSquares == 0 {
+		return t.sampleTransformed(u, v, dx, dy, filter, grad)
+	}
+	return mean.Add(sum.DivScalar(math.Sqrt(weightSquares))).Min(White).Max(Transparent)
+}
+
+// stochasticTriangle returns the grid vertices of the triangle containing a
+// texture coordinate and the barycentric weights of the coordinate
+func stochasticTriangle(u, v float64) ([3][2]int, [3]float64) {
+	u *= stochasticGridScale
+	v *= stochasticGridScale
+	// Skew so that the triangle grid becomes a grid of split squares
+	su := u - v/math.Sqrt(3)
+	sv := v * 2 / math.Sqrt(3)
+	bu, bv := math.Floor(su), math.Floor(sv)
+	fu, fv := su-bu, sv-bv
+	x, y := int(bu), int(bv)
+	if fw := 1 - fu - fv; fw > 0 {
+		return [3][2]int{{x, y}, {x, y + 1}, {x + 1, y}}, [3]float64{fw, fv, fu}
+	}
+	return [3][2]int{{x + 1, y + 1}, {x + 1, y}, {x, y + 1}}, [3]float64{fu + fv - 1, 1 - fv, 1 - fu}
+}
+
+// meanColor returns the average color of the texture, the last level of a
+// full mip chain
+func (t *AdvancedTexture) meanColor() Color {
+	img := t.Image
+	if n := len(t.MipLevels); n > 0 {
+		img = t.MipLevels[n-1]
+	}
+	b := img.Bounds()
+	if b.Dx() == 1 && b.Dy() == 1 {
+		return MakeColor(img.At(b.Min.X, b.Min.Y))
+	}
+	return averageColor(img)
+}
+
+// averageColor returns the average color of an image
+func averageColor(img image.Image) Color {
+	b := img.Bounds()
+	var sum Color
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			sum = sum.Add(MakeColor(img.At(x, y)))
+		}
+	}
+	n := float64(b.Dx() * b.Dy())
+	if n == 0 {
+		return Transparent
+	}
+	return sum.DivScalar(n)
+}
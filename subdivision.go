@@ -0,0 +1,318 @@
+package fauxgl
+
+import (
+	"math"
+	"sort"
+)
+
+// SubdivideOptions control Loop subdivision, see SubdivideWithOptions
+type SubdivideOptions struct {
+	Levels int // Subdivision steps, each splitting every triangle in four
+	// HardEdges keeps the edges across which the vertex normals differ
+	// sharp, as creases. Otherwise the normals are recomputed from the
+	// smoothed surface.
+	HardEdges bool
+	// CreaseAngle, when positive, keeps the edges whose faces meet at a
+	// larger angle, in radians, sharp
+	CreaseAngle float64
+	// Creases are edges kept sharp, given by the positions of their ends
+	Creases [][2]Vector
+}
+
+// Subdivide smooths a mesh by one step of Loop subdivision, keeping its
+// hard edges, see SubdivideWithOptions
+func (m *Mesh) Subdivide() *Mesh {
+	return m.SubdivideWithOptions(SubdivideOptions{Levels: 1, HardEdges: true})
+}
+
+// SubdivideWithOptions smooths a mesh by Loop subdivision. Triangles are
+// connected through their shared positions, so meshes split at UV or
+// normal seams still smooth as one surface. Boundary and non-manifold
+// edges and the crease edges of the options are kept sharp. UVs, colors,
+// normals, tangents and skinning weights are interpolated along the edges
+// of each triangle, keeping their seams; material IDs are kept.
+func (m *Mesh) SubdivideWithOptions(options SubdivideOptions) *Mesh {
+	creases := make(map[[2]Vector]bool, len(options.Creases))
+	for _, edge := range options.Creases {
+		creases[positionEdge(edge[0], edge[1])] = true
+	}
+	triangles := m.Triangles
+	ids := m.MaterialIDs
+	if !m.hasMaterialIDs() {
+		ids = nil
+	}
+	for level := 0; level < options.Levels; level++ {
+		s := newSubdivision(triangles, creases)
+		if level == 0 {
+			s.markCreases(options.HardEdges, options.CreaseAngle)
+		}
+		triangles, creases = s.subdivide()
+		if ids != nil {
+			subdivided := make([]int, 0, 4*len(ids))
+			for _, id := range ids {
+				subdivided = append(subdivided, id, id, id, id)
+			}
+			ids = subdivided
+		}
+	}
+
+	if options.Levels <= 0 {
+		triangles = m.Copy().Triangles
+	}
+	mesh := NewTriangleMesh(triangles)
+	mesh.MaterialIDs = ids
+	if !options.HardEdges && options.Levels > 0 {
+		for _, t := range mesh.Triangles {
+			n := t.Normal()
+			t.V1.Normal, t.V2.Normal, t.V3.Normal = n, n, n
+		}
+		if options.CreaseAngle > 0 {
+			mesh.SmoothNormalsThreshold(options.CreaseAngle)
+		} else {
+			mesh.SmoothNormals()
+		}
+	}
+	return mesh
+}
+
+// positionEdge returns the key of the edge between two positions,
+// independent of their order
+func positionEdge(a, b Vector) [2]Vector {
+	if b.X < a.X || (b.X == a.X && (b.Y < a.Y || (b.Y == a.Y && b.Z < a.Z))) {
+		a, b = b, a
+	}
+	return [2]Vector{a, b}
+}
+
+// subdivisionEdge is an edge of the welded mesh
+type subdivisionEdge struct {
+	triangles []int // Triangles sharing the edge
+	opposite  []int // Vertex across the edge in each triangle
+	crease    bool
+	odd       Vector // Position of the vertex inserted on the edge
+}
+
+// subdivision is one step of Loop subdivision over welded positions
+type subdivision struct {
+	triangles []*Triangle
+	positions []Vector
+	corners   [][3]int // Position index of each triangle corner
+	edges     map[[2]int]*subdivisionEdge
+}
+
+func newSubdivision(triangles []*Triangle, creases map[[2]Vector]bool) *subdivision {
+	s := &subdivision{
+		triangles: triangles,
+		corners:   make([][3]int, len(triangles)),
+		edges:     make(map[[2]int]*subdivisionEdge),
+	}
+	index := make(map[Vector]int)
+	for i, t := range triangles {
+		for j, p := range [3]Vector{t.V1.Position, t.V2.Position, t.V3.Position} {
+			k, ok := index[p]
+			if !ok {
+				k = len(s.positions)
+				index[p] = k
+				s.positions = append(s.positions, p)
+			}
+			s.corners[i][j] = k
+		}
+	}
+	for i, c := range s.corners {
+		for j := 0; j < 3; j++ {
+			a, b := c[j], c[(j+1)%3]
+			if a == b {
+				continue
+			}
+			key := s.edgeKey(a, b)
+			edge := s.edges[key]
+			if edge == nil {
+				edge = &subdivisionEdge{crease: creases[positionEdge(s.positions[a], s.positions[b])]}
+				s.edges[key] = edge
+			}
+			edge.triangles = append(edge.triangles, i)
+			edge.opposite = append(edge.opposite, c[(j+2)%3])
+		}
+	}
+	// Boundary and non-manifold edges are always sharp
+	for _, edge := range s.edges {
+		if len(edge.triangles) != 2 {
+			edge.crease = true
+		}
+	}
+	return s
+}
+
+func (s *subdivision) edgeKey(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
+
+// corner returns the vertex of a triangle at a position index
+func (s *subdivision) corner(triangle, position int) Vertex {
+	t := s.triangles[triangle]
+	switch position {
+	case s.corners[triangle][0]:
+		return t.V1
+	case s.corners[triangle][1]:
+		return t.V2
+	}
+	return t.V3
+}
+
+// markCreases makes the edges with differing normals on their two sides,
+// or with faces meeting at more than angle, creases
+func (s *subdivision) markCreases(hardEdges bool, angle float64) {
+	const normalTolerance = 1e-6
+	for key, edge := range s.edges {
+		if edge.crease {
+			continue
+		}
+		t0, t1 := edge.triangles[0], edge.triangles[1]
+		if hardEdges {
+			for _, p := range key {
+				if s.corner(t0, p).Normal.Dot(s.corner(t1, p).Normal) < 1-normalTolerance {
+					edge.crease = true
+				}
+			}
+		}
+		if angle > 0 {
+			n0, n1 := s.triangles[t0].Normal(), s.triangles[t1].Normal()
+			if math.Acos(Clamp(n0.Dot(n1), -1, 1)) > angle {
+				edge.crease = true
+			}
+		}
+	}
+}
+
+// subdivide splits every triangle in four, returning the triangles and the
+// crease edges of the result
+func (s *subdivision) subdivide() ([]*Triangle, map[[2]Vector]bool) {
+	// Odd vertices, on the edges
+	for key, edge := range s.edges {
+		a, b := s.positions[key[0]], s.positions[key[1]]
+		if edge.crease {
+			edge.odd = a.Add(b).MulScalar(0.5)
+			continue
+		}
+		c, d := s.positions[edge.opposite[0]], s.positions[edge.opposite[1]]
+		edge.odd = a.Add(b).MulScalar(3.0 / 8).Add(c.Add(d).MulScalar(1.0 / 8))
+	}
+
+	// Even vertices, moved from the original positions
+	neighbors := make([][]int, len(s.positions))
+	creaseNeighbors := make([][]int, len(s.positions))
+	keys := make([][2]int, 0, len(s.edges))
+	for key := range s.edges {
+		keys = append(keys, key)
+	}
+	// Sum the neighbors in a fixed order so results don't depend on map order
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || (keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1])
+	})
+	for _, key := range keys {
+		a, b := key[0], key[1]
+		neighbors[a] = append(neighbors[a], b)
+		neighbors[b] = append(neighbors[b], a)
+		if s.edges[key].crease {
+			creaseNeighbors[a] = append(creaseNeighbors[a], b)
+			creaseNeighbors[b] = append(creaseNeighbors[b], a)
+		}
+	}
+	even := make([]Vector, len(s.positions))
+	for i, p := range s.positions {
+		switch creases := creaseNeighbors[i]; {
+		case len(creases) == 2:
+			// Crease vertex: smoothed along the crease only
+			even[i] = p.MulScalar(3.0 / 4).Add(s.positions[creases[0]].Add(s.positions[creases[1]]).MulScalar(1.0 / 8))
+		case len(creases) > 2 || len(neighbors[i]) == 0:
+			// Corner vertex: fixed
+			even[i] = p
+		default:
+			n := len(neighbors[i])
+			beta := 3.0 / 16
+			if n > 3 {
+				beta = 3 / (8 * float64(n))
+			}
+			sum := Vector{}
+			for _, j := range neighbors[i] {
+				sum = sum.Add(s.positions[j])
+			}
+			even[i] = p.MulScalar(1 - float64(n)*beta).Add(sum.MulScalar(beta))
+		}
+	}
+
+	triangles := make([]*Triangle, 0, 4*len(s.triangles))
+	for i, t := range s.triangles {
+		c := s.corners[i]
+		v1, v2, v3 := t.V1, t.V2, t.V3
+		v1.Position, v2.Position, v3.Position = even[c[0]], even[c[1]], even[c[2]]
+		m12 := s.midpoint(t.V1, t.V2, c[0], c[1])
+		m23 := s.midpoint(t.V2, t.V3, c[1], c[2])
+		m31 := s.midpoint(t.V3, t.V1, c[2], c[0])
+		triangles = append(triangles,
+			&Triangle{v1, m12, m31},
+			&Triangle{m12, v2, m23},
+			&Triangle{m31, m23, v3},
+			&Triangle{m12, m23, m31})
+	}
+
+	creases := make(map[[2]Vector]bool)
+	for key, edge := range s.edges {
+		if edge.crease {
+			creases[positionEdge(even[key[0]], edge.odd)] = true
+			creases[positionEdge(edge.odd, even[key[1]])] = true
+		}
+	}
+	return triangles, creases
+}
+
+// midpoint returns the vertex inserted on the edge between two corners of a
+// triangle, with their attributes averaged
+func (s *subdivision) midpoint(a, b Vertex, i, j int) Vertex {
+	v := InterpolateVertexes(a, b, b, VectorW{0.5, 0.5, 0, 1})
+	if i == j {
+		v.Position = s.positions[i]
+	} else {
+		v.Position = s.edges[s.edgeKey(i, j)].odd
+	}
+	v.Joints, v.Weights = mixSkinWeights(a, b)
+	return v
+}
+
+// mixSkinWeights returns the four strongest joint influences of two
+// vertices weighted equally, normalized
+func mixSkinWeights(a, b Vertex) ([4]int, [4]float64) {
+	weights := make(map[int]float64, 8)
+	for k := 0; k < 4; k++ {
+		weights[a.Joints[k]] += a.Weights[k] / 2
+		weights[b.Joints[k]] += b.Weights[k] / 2
+	}
+	joints := make([]int, 0, len(weights))
+	for joint, weight := range weights {
+		if weight > 0 {
+			joints = append(joints, joint)
+		}
+	}
+	sort.Slice(joints, func(i, j int) bool {
+		if weights[joints[i]] != weights[joints[j]] {
+			return weights[joints[i]] > weights[joints[j]]
+		}
+		return joints[i] < joints[j]
+	})
+	var outJoints [4]int
+	var outWeights [4]float64
+	total := 0.0
+	for k := 0; k < 4 && k < len(joints); k++ {
+		outJoints[k], outWeights[k] = joints[k], weights[joints[k]]
+		total += outWeights[k]
+	}
+	if total > 0 {
+		for k := range outWeights {
+			outWeights[k] /= total
+		}
+	}
+	return outJoints, outWeights
+}
@@ -0,0 +1,182 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+)
+
+// ResolveFilter selects how a supersampled context is reduced to its
+// output resolution, see Context.SetSupersampling
+type ResolveFilter int
+
+const (
+	// ResolveBox averages the samples of every output pixel
+	ResolveBox ResolveFilter = iota
+	// ResolveLanczos weights the samples around every output pixel with a
+	// Lanczos-3 windowed sinc, like MipmapLanczos: sharper than box and
+	// with less aliasing of fine detail, at the cost of faint ringing
+	// along hard edges
+	ResolveLanczos
+)
+
+// SetSupersampling renders the context at n by n samples per output pixel,
+// reallocating its buffers at n times the output resolution, which is
+// Width and Height divided by the previous factor. Width and Height
+// become the render resolution; Resolve, or Image, returns the frame at
+// the output resolution filtered with ResolveFilter. 1 turns supersampling
+// off.
+func (dc *Context) SetSupersampling(n int) {
+	if n < 1 {
+		n = 1
+	}
+	factor := dc.Supersampling()
+	if n == factor {
+		return
+	}
+	width, height := dc.Width/factor*n, dc.Height/factor*n
+	dc.supersampling = n
+	dc.Width = width
+	dc.Height = height
+	dc.ColorBuffer = image.NewNRGBA(image.Rect(0, 0, width, height))
+	dc.DepthBuffer = make([]float64, width*height)
+	if dc.HDRBuffer != nil {
+		dc.EnableHDR()
+	}
+	if dc.NormalBuffer != nil {
+		dc.EnableNormalBuffer()
+	}
+	if dc.VelocityBuffer != nil {
+		dc.EnableVelocityBuffer()
+	}
+	dc.screenMatrix = Screen(width, height)
+	dc.mask = nil
+	dc.shadeMask = nil
+	dc.ClearColorBuffer()
+	dc.ClearDepthBuffer()
+}
+
+// Supersampling returns the samples per axis of every output pixel, see
+// SetSupersampling
+func (dc *Context) Supersampling() int {
+	if dc.supersampling < 1 {
+		return 1
+	}
+	return dc.supersampling
+}
+
+// Resolve returns the color buffer at the output resolution, the color
+// buffer itself without supersampling
+func (dc *Context) Resolve() *image.NRGBA {
+	n := dc.Supersampling()
+	if n == 1 {
+		return dc.ColorBuffer
+	}
+	pixels := make([]float64, 4*dc.Width*dc.Height)
+	parallelRows(dc.Height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < dc.Width; x++ {
+				c := MakeColor(dc.ColorBuffer.NRGBAAt(x, y)).Premultiply()
+				i := 4 * (y*dc.Width + x)
+				pixels[i], pixels[i+1], pixels[i+2], pixels[i+3] = c.R, c.G, c.B, c.A
+			}
+		}
+	})
+	width, height := dc.Width/n, dc.Height/n
+	pixels = dc.resolve(pixels, n)
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	parallelRows(height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				p := pixels[4*(y*width+x):]
+				a := Clamp(p[3], 0, 1)
+				c := Color{Clamp(p[0], 0, a), Clamp(p[1], 0, a), Clamp(p[2], 0, a), a}
+				out.SetNRGBA(x, y, c.Unpremultiply().NRGBA())
+			}
+		}
+	})
+	return out
+}
+
+// ResolveHDR returns the HDR buffer at the output resolution, nil when it
+// is not enabled
+func (dc *Context) ResolveHDR() *HDRImage {
+	n := dc.Supersampling()
+	if dc.HDRBuffer == nil || n == 1 {
+		return dc.HDRBuffer
+	}
+	pixels := make([]float64, len(dc.HDRBuffer.Pix))
+	parallelRows(dc.Height, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < dc.Width; x++ {
+				c := dc.HDRBuffer.ColorAt(x, y).Premultiply()
+				i := 4 * (y*dc.Width + x)
+				pixels[i], pixels[i+1], pixels[i+2], pixels[i+3] = c.R, c.G, c.B, c.A
+			}
+		}
+	})
+	width, height := dc.Width/n, dc.Height/n
+	pixels = dc.resolve(pixels, n)
+	out := NewHDRImage(image.Rect(0, 0, width, height))
+	for i := 0; i < len(pixels); i += 4 {
+		// Ringing may undershoot; HDR colors may exceed 1
+		c := Color{math.Max(pixels[i], 0), math.Max(pixels[i+1], 0), math.Max(pixels[i+2], 0), Clamp(pixels[i+3], 0, 1)}
+		c = c.Unpremultiply()
+		out.Pix[i], out.Pix[i+1], out.Pix[i+2], out.Pix[i+3] = float32(c.R), float32(c.G), float32(c.B), float32(c.A)
+	}
+	return out
+}
+
+// resolveTaps returns the samples of every output pixel along an axis of
+// size source samples, n per output pixel, with normalized weights
+func (dc *Context) resolveTaps(size, n int) [][]filterTap {
+	if dc.ResolveFilter == ResolveLanczos {
+		return lanczosWeights(size, size/n)
+	}
+	taps := make([][]filterTap, size/n)
+	for o := range taps {
+		for i := 0; i < n; i++ {
+			taps[o] = append(taps[o], filterTap{o*n + i, 1 / float64(n)})
+		}
+	}
+	return taps
+}
+
+// resolve filters premultiplied RGBA samples at the render resolution down
+// to the output resolution, horizontally and then vertically
+func (dc *Context) resolve(pixels []float64, n int) []float64 {
+	w, h := dc.Width, dc.Height
+	ow, oh := w/n, h/n
+	xTaps, yTaps := dc.resolveTaps(w, n), dc.resolveTaps(h, n)
+
+	rows := make([]float64, 4*ow*h)
+	parallelRows(h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x, taps := range xTaps {
+				d := rows[4*(y*ow+x):]
+				for _, tap := range taps {
+					s := pixels[4*(y*w+tap.index):]
+					d[0] += s[0] * tap.weight
+					d[1] += s[1] * tap.weight
+					d[2] += s[2] * tap.weight
+					d[3] += s[3] * tap.weight
+				}
+			}
+		}
+	})
+	out := make([]float64, 4*ow*oh)
+	parallelRows(oh, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < ow; x++ {
+				d := out[4*(y*ow+x):]
+				for _, tap := range yTaps[y] {
+					s := rows[4*(tap.index*ow+x):]
+					d[0] += s[0] * tap.weight
+					d[1] += s[1] * tap.weight
+					d[2] += s[2] * tap.weight
+					d[3] += s[3] * tap.weight
+				}
+			}
+		}
+	})
+	return out
+}
@@ -0,0 +1,173 @@
+package fauxgl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TextureChannel selects the source of a channel in a Swizzle
+type TextureChannel int
+
+const (
+	// ChannelSame - the channel itself, so the zero Swizzle changes nothing
+	ChannelSame TextureChannel = iota
+	// ChannelRed - the red channel
+	ChannelRed
+	// ChannelGreen - the green channel
+	ChannelGreen
+	// ChannelBlue - the blue channel
+	ChannelBlue
+	// ChannelAlpha - the alpha channel
+	ChannelAlpha
+	// ChannelZero - the constant 0
+	ChannelZero
+	// ChannelOne - the constant 1
+	ChannelOne
+)
+
+// Swizzle rearranges the channels of texture samples: the red, green, blue
+// and alpha of the result come from the channels it lists, in that order.
+// For example Swizzle{1: ChannelBlue} reads green from blue.
+type Swizzle [4]TextureChannel
+
+// ParseSwizzle parses a swizzle of up to four channels from the letters r,
+// g, b, a and the digits 0 and 1, such as "bgra" or "rrr1". Missing channels
+// are kept.
+func ParseSwizzle(s string) (Swizzle, error) {
+	var swizzle Swizzle
+	if len(s) > 4 {
+		return swizzle, fmt.Errorf("invalid swizzle %q: more than four channels", s)
+	}
+	for i, r := range strings.ToLower(s) {
+		channel, err := parseTextureChannel(string(r))
+		if err != nil {
+			return Swizzle{}, fmt.Errorf("invalid swizzle %q: %w", s, err)
+		}
+		swizzle[i] = channel
+	}
+	return swizzle, nil
+}
+
+func parseTextureChannel(s string) (TextureChannel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "r", "red":
+		return ChannelRed, nil
+	case "g", "green":
+		return ChannelGreen, nil
+	case "b", "blue":
+		return ChannelBlue, nil
+	case "a", "alpha":
+		return ChannelAlpha, nil
+	case "0":
+		return ChannelZero, nil
+	case "1":
+		return ChannelOne, nil
+	}
+	return ChannelSame, fmt.Errorf("unknown channel %q", s)
+}
+
+// Apply rearranges the channels of a color
+func (s Swizzle) Apply(c Color) Color {
+	if s == (Swizzle{}) {
+		return c
+	}
+	source := [4]float64{c.R, c.G, c.B, c.A}
+	var result [4]float64
+	for i, channel := range s {
+		switch channel {
+		case ChannelSame:
+			result[i] = source[i]
+		case ChannelRed, ChannelGreen, ChannelBlue, ChannelAlpha:
+			result[i] = source[channel-ChannelRed]
+		case ChannelOne:
+			result[i] = 1
+		}
+	}
+	return Color{result[0], result[1], result[2], result[3]}
+}
+
+// TextureSwizzles holds the swizzle of every texture binding of a
+// PBRMaterial, applied to samples before the material reads its channels,
+// so packed maps with a non-standard layout can be used as they are
+type TextureSwizzles struct {
+	BaseColor            Swizzle
+	MetallicRoughness    Swizzle
+	Normal               Swizzle
+	Occlusion            Swizzle
+	Emissive             Swizzle
+	Height               Swizzle
+	SpecularColor        Swizzle
+	Transmission         Swizzle
+	Thickness            Swizzle
+	Anisotropy           Swizzle
+	SheenColor           Swizzle
+	SheenRoughness       Swizzle
+	Iridescence          Swizzle
+	IridescenceThickness Swizzle
+	Clearcoat            Swizzle
+	ClearcoatRoughness   Swizzle
+	ClearcoatNormal      Swizzle
+	SubsurfaceThickness  Swizzle
+}
+
+// textureChannelProperties maps the scalar material properties read from a
+// single texture channel to their binding and standard channel
+var textureChannelProperties = map[string]struct {
+	swizzle func(s *TextureSwizzles) *Swizzle
+	channel int
+}{
+	"alpha":                {func(s *TextureSwizzles) *Swizzle { return &s.BaseColor }, 3},
+	"roughness":            {func(s *TextureSwizzles) *Swizzle { return &s.MetallicRoughness }, 1},
+	"metallic":             {func(s *TextureSwizzles) *Swizzle { return &s.MetallicRoughness }, 2},
+	"occlusion":            {func(s *TextureSwizzles) *Swizzle { return &s.Occlusion }, 0},
+	"transmission":         {func(s *TextureSwizzles) *Swizzle { return &s.Transmission }, 0},
+	"thickness":            {func(s *TextureSwizzles) *Swizzle { return &s.Thickness }, 1},
+	"sheenroughness":       {func(s *TextureSwizzles) *Swizzle { return &s.SheenRoughness }, 3},
+	"iridescence":          {func(s *TextureSwizzles) *Swizzle { return &s.Iridescence }, 0},
+	"iridescencethickness": {func(s *TextureSwizzles) *Swizzle { return &s.IridescenceThickness }, 1},
+	"clearcoat":            {func(s *TextureSwizzles) *Swizzle { return &s.Clearcoat }, 0},
+	"clearcoatroughness":   {func(s *TextureSwizzles) *Swizzle { return &s.ClearcoatRoughness }, 1},
+	"subsurfacethickness":  {func(s *TextureSwizzles) *Swizzle { return &s.SubsurfaceThickness }, 1},
+}
+
+// SetTextureChannel reads a scalar material property from another channel
+// of its texture, e.g. SetTextureChannel("roughness", ChannelBlue). The
+// properties are alpha, roughness, metallic, occlusion, transmission,
+// thickness, sheenRoughness, iridescence, iridescenceThickness, clearcoat,
+// clearcoatRoughness, subsurfaceThickness and height, whose gray value is
+// read from the channel.
+func (m *PBRMaterial) SetTextureChannel(property string, channel TextureChannel) error {
+	key := strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(property))
+	if key == "height" {
+		m.Swizzles.Height = Swizzle{channel, channel, channel, ChannelSame}
+		return nil
+	}
+	entry, ok := textureChannelProperties[key]
+	if !ok {
+		return fmt.Errorf("unknown texture channel property: %s", property)
+	}
+	entry.swizzle(&m.Swizzles)[entry.channel] = channel
+	return nil
+}
+
+// SetTextureChannels applies a list of property channels such as
+// "roughness: B, occlusion: A", see SetTextureChannel
+func (m *PBRMaterial) SetTextureChannels(spec string) error {
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid texture channel %q: expected property: channel", entry)
+		}
+		channel, err := parseTextureChannel(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return fmt.Errorf("invalid texture channel %q: %w", entry, err)
+		}
+		if err := m.SetTextureChannel(strings.TrimSpace(parts[0]), channel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
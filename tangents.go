@@ -0,0 +1,142 @@
+package fauxgl
+
+import (
+	"math"
+)
+
+// Tangent space of normal maps: the tangent points along +u, the bitangent
+// along +v (up in textures, see AdvancedTexture.Sample) and the normal out
+// of the surface. Vertex tangents store the bitangent as its handedness in
+// W, the bitangent being cross(normal, tangent) * W as in glTF.
+
+// ComputeTangents generates the tangents of the mesh's triangles from their
+// texture coordinates, in the manner of MikkTSpace: triangle tangents are
+// weighted by the corner angle and averaged over the corners sharing a
+// position, normal, texture coordinate and UV orientation, then made
+// orthogonal to the vertex normal. Corners whose triangles have degenerate
+// texture coordinates get an arbitrary tangent perpendicular to the normal.
+func (m *Mesh) ComputeTangents() {
+	type key struct {
+		position, normal, texture Vector
+		mirrored                  bool
+	}
+	type frame struct {
+		tangent, bitangent Vector
+	}
+	lookup := make(map[key]frame)
+	keys := make([][3]key, len(m.Triangles))
+
+	for i, t := range m.Triangles {
+		vertices := [3]*Vertex{&t.V1, &t.V2, &t.V3}
+		e1 := t.V2.Position.Sub(t.V1.Position)
+		e2 := t.V3.Position.Sub(t.V1.Position)
+		du1, dv1 := t.V2.Texture.X-t.V1.Texture.X, t.V2.Texture.Y-t.V1.Texture.Y
+		du2, dv2 := t.V3.Texture.X-t.V1.Texture.X, t.V3.Texture.Y-t.V1.Texture.Y
+		det := du1*dv2 - du2*dv1
+
+		var tangent, bitangent Vector
+		if math.Abs(det) > 1e-12 {
+			r := 1 / det
+			tangent = e1.MulScalar(dv2 * r).Sub(e2.MulScalar(dv1 * r))
+			bitangent = e2.MulScalar(du1 * r).Sub(e1.MulScalar(du2 * r))
+		}
+
+		for c, v := range vertices {
+			k := key{v.Position, v.Normal, v.Texture, det < 0}
+			keys[i][c] = k
+			if tangent == (Vector{}) {
+				continue
+			}
+			// Weight by the angle of the triangle at the corner
+			a := vertices[(c+1)%3].Position.Sub(v.Position)
+			b := vertices[(c+2)%3].Position.Sub(v.Position)
+			angle := 0.0
+			if la, lb := a.Length(), b.Length(); la > 0 && lb > 0 {
+				angle = math.Acos(Clamp(a.Dot(b)/(la*lb), -1, 1))
+			}
+			f := lookup[k]
+			f.tangent = f.tangent.Add(tangent.Normalize().MulScalar(angle))
+			f.bitangent = f.bitangent.Add(bitangent.Normalize().MulScalar(angle))
+			lookup[k] = f
+		}
+	}
+
+	for i, t := range m.Triangles {
+		vertices := [3]*Vertex{&t.V1, &t.V2, &t.V3}
+		for c, v := range vertices {
+			f := lookup[keys[i][c]]
+			normal := v.Normal
+			if normal == (Vector{}) {
+				normal = t.Normal()
+			}
+			v.Tangent = orthogonalTangent(normal, f.tangent, f.bitangent)
+		}
+	}
+}
+
+// orthogonalTangent makes a tangent orthogonal to the normal and returns it
+// with the handedness of the bitangent
+func orthogonalTangent(normal, tangent, bitangent Vector) VectorW {
+	tangent = tangent.Sub(normal.MulScalar(normal.Dot(tangent)))
+	if tangent.LengthSquared() < 1e-12 {
+		tangent = normal.Perpendicular()
+		if tangent == (Vector{}) {
+			return VectorW{}
+		}
+	}
+	tangent = tangent.Normalize()
+	w := 1.0
+	if normal.Cross(tangent).Dot(bitangent) < 0 {
+		w = -1
+	}
+	return VectorW{tangent.X, tangent.Y, tangent.Z, w}
+}
+
+// surfaceNormal normalizes an interpolated vertex normal. Zero length and
+// NaN normals, which some exporters write for degenerate triangles, face
+// the viewer instead so they shade as flat rather than as NaN.
+func surfaceNormal(normal, viewDir Vector) Vector {
+	length := normal.Length()
+	if !(length > 1e-12) || math.IsInf(length, 0) {
+		return viewDir
+	}
+	return normal.DivScalar(length)
+}
+
+// TangentToWorld transforms a tangent space normal, such as one sampled from
+// a normal map, to the space of the normal and tangent of a surface. It
+// returns the surface normal when the tangent is zero.
+func TangentToWorld(normal Vector, tangent VectorW, tangentNormal Vector) Vector {
+	t := tangent.Vector()
+	// Interpolated tangents are neither unit length nor orthogonal to the
+	// interpolated normal
+	t = t.Sub(normal.MulScalar(normal.Dot(t)))
+	// Zero and NaN tangents, e.g. from degenerate UVs, fail the comparison
+	if !(t.LengthSquared() >= 1e-12) {
+		return normal
+	}
+	t = t.Normalize()
+	b := normal.Cross(t)
+	if tangent.W < 0 {
+		b = b.Negate()
+	}
+	n := t.MulScalar(tangentNormal.X).Add(b.MulScalar(tangentNormal.Y)).Add(normal.MulScalar(tangentNormal.Z))
+	if !(n.LengthSquared() > 0) {
+		return normal
+	}
+	return n.Normalize()
+}
+
+// transformTangent transforms a vertex tangent by a matrix, flipping its
+// handedness when the matrix mirrors
+func transformTangent(matrix Matrix, tangent VectorW, mirror bool) VectorW {
+	if tangent == (VectorW{}) {
+		return tangent
+	}
+	t := matrix.MulDirection(tangent.Vector())
+	w := tangent.W
+	if mirror {
+		w = -w
+	}
+	return VectorW{t.X, t.Y, t.Z, w}
+}
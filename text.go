@@ -0,0 +1,384 @@
+package fauxgl
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Font is a TrueType or OpenType font for text meshes and labels, see
+// NewTextMesh and Label. It is safe for concurrent use.
+type Font struct {
+	font   *sfnt.Font
+	mu     sync.Mutex
+	buffer sfnt.Buffer
+}
+
+// TextAlign aligns the lines of a text
+type TextAlign int
+
+const (
+	TextAlignLeft TextAlign = iota
+	TextAlignCenter
+	TextAlignRight
+)
+
+var (
+	defaultFontOnce sync.Once
+	defaultFont     *Font
+)
+
+// LoadFont loads a TrueType or OpenType font file
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadFontFromBytes(data)
+}
+
+// LoadFontFromBytes loads a TrueType or OpenType font
+func LoadFontFromBytes(data []byte) (*Font, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+	return &Font{font: f}, nil
+}
+
+// DefaultFont returns the Go Regular font
+func DefaultFont() *Font {
+	defaultFontOnce.Do(func() {
+		f, err := LoadFontFromBytes(goregular.TTF)
+		if err != nil {
+			panic(err)
+		}
+		defaultFont = f
+	})
+	return defaultFont
+}
+
+// Outline returns the closed contours of the glyphs of a text in em units,
+// with the first baseline along y = 0, y pointing up and lines of the text
+// stacked downwards, aligned at x = 0. Curves are flattened into lines.
+// Runes the font lacks are skipped. Contours follow the font's winding,
+// filled by the nonzero rule.
+func (f *Font) Outline(text string, align TextAlign) [][]Vector {
+	var contours [][]Vector
+	for _, glyph := range f.layout(text, align) {
+		contours = append(contours, glyph...)
+	}
+	return contours
+}
+
+// layout returns the contours of every glyph of a text, see Outline
+func (f *Font) layout(text string, align TextAlign) [][][]Vector {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b := &f.buffer
+	upm := float64(f.font.UnitsPerEm())
+	ppem := fixed.I(int(f.font.UnitsPerEm()))
+	// Glyph coordinates are 26.6 fixed point units of the font
+	scale := 1 / (64 * upm)
+	lineHeight := 1.2
+	if metrics, err := f.font.Metrics(b, ppem, font.HintingNone); err == nil && metrics.Height > 0 {
+		lineHeight = float64(metrics.Height) * scale
+	}
+
+	var glyphs [][][]Vector
+	for i, line := range strings.Split(text, "\n") {
+		y := -float64(i) * lineHeight
+		var lineGlyphs [][][]Vector
+		x := 0.0
+		previous, hasPrevious := sfnt.GlyphIndex(0), false
+		for _, r := range line {
+			index, err := f.font.GlyphIndex(b, r)
+			if err != nil || index == 0 {
+				hasPrevious = false
+				continue
+			}
+			if hasPrevious {
+				if kern, err := f.font.Kern(b, previous, index, ppem, font.HintingNone); err == nil {
+					x += float64(kern) * scale
+				}
+			}
+			if segments, err := f.font.LoadGlyph(b, index, ppem, nil); err == nil {
+				if contours := flattenSegments(segments, x, y, scale); len(contours) > 0 {
+					lineGlyphs = append(lineGlyphs, contours)
+				}
+			}
+			if advance, err := f.font.GlyphAdvance(b, index, ppem, font.HintingNone); err == nil {
+				x += float64(advance) * scale
+			}
+			previous, hasPrevious = index, true
+		}
+
+		shift := 0.0
+		switch align {
+		case TextAlignCenter:
+			shift = -x / 2
+		case TextAlignRight:
+			shift = -x
+		}
+		for _, glyph := range lineGlyphs {
+			for _, contour := range glyph {
+				for j := range contour {
+					contour[j].X += shift
+				}
+			}
+		}
+		glyphs = append(glyphs, lineGlyphs...)
+	}
+	return glyphs
+}
+
+// flattenSegments converts the segments of a glyph into closed polylines
+// in em units at x, y, flipping y up and dropping the closing point
+func flattenSegments(segments sfnt.Segments, x, y, scale float64) [][]Vector {
+	point := func(p fixed.Point26_6) Vector {
+		return Vector{x + float64(p.X)*scale, y - float64(p.Y)*scale, 0}
+	}
+	// steps flattens curves into segments of about a fortieth of an em
+	steps := func(points ...Vector) int {
+		length := 0.0
+		for i := 1; i < len(points); i++ {
+			length += points[i].Distance(points[i-1])
+		}
+		return ClampInt(int(math.Ceil(length*40)), 1, 16)
+	}
+
+	var contours [][]Vector
+	var contour []Vector
+	closeContour := func() {
+		if n := len(contour); n > 1 && contour[n-1].Distance(contour[0]) < 1e-9 {
+			contour = contour[:n-1]
+		}
+		if len(contour) >= 3 {
+			contours = append(contours, contour)
+		}
+		contour = nil
+	}
+	for _, s := range segments {
+		switch s.Op {
+		case sfnt.SegmentOpMoveTo:
+			closeContour()
+			contour = append(contour, point(s.Args[0]))
+		case sfnt.SegmentOpLineTo:
+			contour = append(contour, point(s.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			if len(contour) == 0 {
+				continue
+			}
+			p0, p1, p2 := contour[len(contour)-1], point(s.Args[0]), point(s.Args[1])
+			n := steps(p0, p1, p2)
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				a, b := p0.Lerp(p1, t), p1.Lerp(p2, t)
+				contour = append(contour, a.Lerp(b, t))
+			}
+		case sfnt.SegmentOpCubeTo:
+			if len(contour) == 0 {
+				continue
+			}
+			p0, p1, p2, p3 := contour[len(contour)-1], point(s.Args[0]), point(s.Args[1]), point(s.Args[2])
+			n := steps(p0, p1, p2, p3)
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				a, b, c := p0.Lerp(p1, t), p1.Lerp(p2, t), p2.Lerp(p3, t)
+				ab, bc := a.Lerp(b, t), b.Lerp(c, t)
+				contour = append(contour, ab.Lerp(bc, t))
+			}
+		}
+	}
+	closeContour()
+	return contours
+}
+
+// NewTextMesh creates a mesh of the glyphs of a text at a font size of size
+// world units per em, see Font.Outline: a flat front facing +Z at z = 0 and, when
+// depth is positive, extruded to a back at z = -depth with side walls,
+// smooth along curves. Texture coordinates map x and y by size. A nil font
+// uses DefaultFont.
+func NewTextMesh(f *Font, text string, align TextAlign, size, depth float64) *Mesh {
+	if f == nil {
+		f = DefaultFont()
+	}
+	var triangles []*Triangle
+	vertex := func(p, normal Vector, z float64) Vertex {
+		return Vertex{
+			Position: Vector{p.X * size, p.Y * size, z},
+			Normal:   normal,
+			Texture:  Vector{p.X, p.Y, 0},
+			Tangent:  VectorW{1, 0, 0, 1},
+			Color:    White,
+		}
+	}
+	for _, glyph := range f.layout(text, align) {
+		for _, t := range fillContours(glyph) {
+			a, b, c := t[0], t[1], t[2]
+			triangles = append(triangles, NewTriangle(vertex(a, Vector{0, 0, 1}, 0), vertex(b, Vector{0, 0, 1}, 0), vertex(c, Vector{0, 0, 1}, 0)))
+			if depth > 0 {
+				triangles = append(triangles, NewTriangle(vertex(a, Vector{0, 0, -1}, -depth), vertex(c, Vector{0, 0, -1}, -depth), vertex(b, Vector{0, 0, -1}, -depth)))
+			}
+		}
+		if depth <= 0 {
+			continue
+		}
+
+		// Side walls, their normals pointing away from the filled side of
+		// every edge and smoothed across corners under 30 degrees
+		smooth := math.Cos(Radians(30))
+		for _, contour := range glyph {
+			n := len(contour)
+			normals := make([]Vector, n)
+			for i, a := range contour {
+				b := contour[(i+1)%n]
+				d := b.Sub(a)
+				normal := Vector{d.Y, -d.X, 0}.Normalize()
+				if contoursWinding(glyph, a.Add(b).MulScalar(0.5).Add(normal.MulScalar(1e-4))) != 0 {
+					normal = normal.Negate()
+				}
+				normals[i] = normal
+			}
+			for i, a := range contour {
+				b := contour[(i+1)%n]
+				normal := normals[i]
+				na, nb := normal, normal
+				if previous := normals[(i+n-1)%n]; previous.Dot(normal) > smooth {
+					na = previous.Add(normal).Normalize()
+				}
+				if next := normals[(i+1)%n]; next.Dot(normal) > smooth {
+					nb = next.Add(normal).Normalize()
+				}
+				fa, fb := vertex(a, na, 0), vertex(b, nb, 0)
+				ba, bb := vertex(a, na, -depth), vertex(b, nb, -depth)
+				// Wind the wall to face its normal
+				if d := b.Sub(a); d.Y*normal.X-d.X*normal.Y < 0 {
+					fa, fb, ba, bb = fb, fa, bb, ba
+				}
+				triangles = append(triangles, NewTriangle(fa, ba, bb), NewTriangle(fa, bb, fb))
+			}
+		}
+	}
+	return NewTriangleMesh(triangles)
+}
+
+// contoursWinding returns the winding number of closed contours around a
+// point
+func contoursWinding(contours [][]Vector, p Vector) int {
+	winding := 0
+	for _, contour := range contours {
+		for i, a := range contour {
+			b := contour[(i+1)%len(contour)]
+			if (a.Y <= p.Y) == (b.Y <= p.Y) {
+				continue
+			}
+			x := a.X + (p.Y-a.Y)/(b.Y-a.Y)*(b.X-a.X)
+			if x > p.X {
+				if b.Y > a.Y {
+					winding++
+				} else {
+					winding--
+				}
+			}
+		}
+	}
+	return winding
+}
+
+// fillContours triangulates the area of closed contours filled by the
+// nonzero rule into counterclockwise triangles, by splitting it into
+// horizontal slabs at every vertex and crossing, each filled with the
+// trapezoids between the edges crossing it
+func fillContours(contours [][]Vector) [][3]Vector {
+	type edge struct {
+		a, b Vector // a below b
+		dir  int
+	}
+	var edges []edge
+	var ys []float64
+	for _, contour := range contours {
+		for i, a := range contour {
+			b := contour[(i+1)%len(contour)]
+			ys = append(ys, a.Y)
+			switch {
+			case a.Y < b.Y:
+				edges = append(edges, edge{a, b, 1})
+			case a.Y > b.Y:
+				edges = append(edges, edge{b, a, -1})
+			}
+		}
+	}
+	// Slabs also split where edges cross
+	for i := range edges {
+		for j := i + 1; j < len(edges); j++ {
+			if y, ok := segmentCrossing(edges[i].a, edges[i].b, edges[j].a, edges[j].b); ok {
+				ys = append(ys, y)
+			}
+		}
+	}
+	sort.Float64s(ys)
+
+	xAt := func(e edge, y float64) float64 {
+		return e.a.X + (y-e.a.Y)/(e.b.Y-e.a.Y)*(e.b.X-e.a.X)
+	}
+	var triangles [][3]Vector
+	for i := 1; i < len(ys); i++ {
+		y0, y1 := ys[i-1], ys[i]
+		if y1-y0 < 1e-12 {
+			continue
+		}
+		mid := (y0 + y1) / 2
+		var crossing []edge
+		for _, e := range edges {
+			if e.a.Y <= y0 && e.b.Y >= y1 {
+				crossing = append(crossing, e)
+			}
+		}
+		sort.Slice(crossing, func(i, j int) bool {
+			return xAt(crossing[i], mid) < xAt(crossing[j], mid)
+		})
+		winding := 0
+		for j := 0; j+1 < len(crossing); j++ {
+			winding += crossing[j].dir
+			if winding == 0 {
+				continue
+			}
+			l, r := crossing[j], crossing[j+1]
+			bl, br := Vector{xAt(l, y0), y0, 0}, Vector{xAt(r, y0), y0, 0}
+			tl, tr := Vector{xAt(l, y1), y1, 0}, Vector{xAt(r, y1), y1, 0}
+			if br.X-bl.X > 1e-12 {
+				triangles = append(triangles, [3]Vector{bl, br, tr})
+			}
+			if tr.X-tl.X > 1e-12 {
+				triangles = append(triangles, [3]Vector{bl, tr, tl})
+			}
+		}
+	}
+	return triangles
+}
+
+// segmentCrossing returns the height at which two segments cross inside
+// both, excluding their end points
+func segmentCrossing(a0, a1, b0, b1 Vector) (float64, bool) {
+	da, db := a1.Sub(a0), b1.Sub(b0)
+	denominator := da.X*db.Y - da.Y*db.X
+	if math.Abs(denominator) < 1e-15 {
+		return 0, false
+	}
+	d := b0.Sub(a0)
+	t := (d.X*db.Y - d.Y*db.X) / denominator
+	u := (d.X*da.Y - d.Y*da.X) / denominator
+	if t <= 1e-9 || t >= 1-1e-9 || u <= 1e-9 || u >= 1-1e-9 {
+		return 0, false
+	}
+	return a0.Y + t*da.Y, true
+}
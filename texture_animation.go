@@ -0,0 +1,319 @@
+package fauxgl
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/ext/texturetransform"
+)
+
+// Texture slot paths as they appear in glTF material JSON pointers
+const (
+	TextureSlotBaseColor         = "pbrMetallicRoughness/baseColorTexture"
+	TextureSlotMetallicRoughness = "pbrMetallicRoughness/metallicRoughnessTexture"
+	TextureSlotNormal            = "normalTexture"
+	TextureSlotOcclusion         = "occlusionTexture"
+	TextureSlotEmissive          = "emissiveTexture"
+)
+
+// animationPointerExtension is the glTF extension used to animate arbitrary properties
+const animationPointerExtension = "KHR_animation_pointer"
+
+// textureTransformProperties maps KHR_texture_transform properties to animation properties
+var textureTransformProperties = map[string]AnimationProperty{
+	"offset":   TextureOffset,
+	"rotation": TextureRotation,
+	"scale":    TextureScale,
+}
+
+// NewKHRUVTransform creates a UV transform matching KHR_texture_transform
+// semantics: scale, then counter-clockwise rotation, then offset, about the UV origin
+func NewKHRUVTransform(offset [2]float64, rotation float64, scale [2]float64) *UVTransform {
+	transform := NewUVTransform()
+	transform.PivotU = 0
+	transform.PivotV = 0
+	transform.SetKHRTextureTransform(offset, rotation, scale)
+	return transform
+}
+
+// SetKHRTextureTransform sets the transform from KHR_texture_transform values
+func (transform *UVTransform) SetKHRTextureTransform(offset [2]float64, rotation float64, scale [2]float64) {
+	transform.OffsetU, transform.OffsetV = offset[0], offset[1]
+	transform.ScaleU, transform.ScaleV = scale[0], scale[1]
+	// KHR_texture_transform rotates UVs counter-clockwise in image space (V down)
+	transform.Rotation = -rotation
+}
+
+// KHRTextureTransform returns the transform as KHR_texture_transform values
+func (transform *UVTransform) KHRTextureTransform() (offset [2]float64, rotation float64, scale [2]float64) {
+	offset = [2]float64{transform.OffsetU, transform.OffsetV}
+	scale = [2]float64{transform.ScaleU, transform.ScaleV}
+	return offset, -transform.Rotation, scale
+}
+
+// TextureTransform returns the animatable UV transform of the texture,
+// creating a UV modifier with an identity KHR-style transform if needed
+func (t *AdvancedTexture) TextureTransform() *UVTransform {
+	if t.UVModifier == nil {
+		t.UVModifier = NewUVModifier()
+		t.UVModifier.SetGlobalTransform(NewKHRUVTransform([2]float64{0, 0}, 0, [2]float64{1, 1}))
+	}
+	t.UVModifier.EnableAnimation(true)
+	return t.UVModifier.GlobalTransform()
+}
+
+// applyUVValue applies an animated value to the channel's UV transform
+func (channel *AnimationChannel) applyUVValue(value interface{}) {
+	switch channel.Property {
+	case TextureOffset:
+		if v, ok := value.(Vector); ok {
+			channel.UVTarget.OffsetU, channel.UVTarget.OffsetV = v.X, v.Y
+		}
+	case TextureRotation:
+		if r, ok := value.(float64); ok {
+			channel.UVTarget.Rotation = -r
+		}
+	case TextureScale:
+		if v, ok := value.(Vector); ok {
+			channel.UVTarget.ScaleU, channel.UVTarget.ScaleV = v.X, v.Y
+		}
+	}
+}
+
+// TextureTransformPointer builds the KHR_animation_pointer path of a texture
+// transform property, e.g. "/materials/0/pbrMetallicRoughness/baseColorTexture/extensions/KHR_texture_transform/offset"
+func TextureTransformPointer(materialIndex int, slot string, property AnimationProperty) string {
+	return textureTransformPropertyPointer(fmt.Sprintf("/materials/%d/%s", materialIndex, slot), property)
+}
+
+// textureTransformPropertyPointer appends the path of a texture transform
+// property to the pointer of a material texture
+func textureTransformPropertyPointer(prefix string, property AnimationProperty) string {
+	name := ""
+	for key, value := range textureTransformProperties {
+		if value == property {
+			name = key
+		}
+	}
+	return prefix + "/extensions/" + texturetransform.ExtensionName + "/" + name
+}
+
+// ParseTextureTransformPointer parses a KHR_animation_pointer path targeting
+// a KHR_texture_transform property of a material texture
+func ParseTextureTransformPointer(pointer string) (int, string, AnimationProperty, error) {
+	parts := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	if len(parts) < 5 || parts[0] != "materials" {
+		return 0, "", 0, fmt.Errorf("not a material pointer: %s", pointer)
+	}
+
+	materialIndex, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, "", 0, fmt.Errorf("invalid material index in pointer %s: %w", pointer, err)
+	}
+
+	n := len(parts)
+	if parts[n-3] != "extensions" || parts[n-2] != texturetransform.ExtensionName {
+		return 0, "", 0, fmt.Errorf("not a texture transform pointer: %s", pointer)
+	}
+
+	property, ok := textureTransformProperties[parts[n-1]]
+	if !ok {
+		return 0, "", 0, fmt.Errorf("unsupported texture transform property: %s", parts[n-1])
+	}
+
+	return materialIndex, strings.Join(parts[2:n-3], "/"), property, nil
+}
+
+// materialTextureSlot returns the material field addressed by a texture slot path
+func materialTextureSlot(material *PBRMaterial, slot string) *Texture {
+	switch slot {
+	case TextureSlotBaseColor:
+		return &material.BaseColorTexture
+	case TextureSlotMetallicRoughness:
+		return &material.MetallicRoughnessTexture
+	case TextureSlotNormal:
+		return &material.NormalTexture
+	case TextureSlotOcclusion:
+		return &material.OcclusionTexture
+	case TextureSlotEmissive:
+		return &material.EmissiveTexture
+	}
+	return nil
+}
+
+// MaterialTextureTransform returns the animatable UV transform of a material
+// texture slot. Textures are shared between materials, so the slot receives
+// its own copy of the texture the first time it is animated.
+func MaterialTextureTransform(material *PBRMaterial, slot string) (*UVTransform, error) {
+	field := materialTextureSlot(material, slot)
+	if field == nil {
+		return nil, fmt.Errorf("unknown texture slot: %s", slot)
+	}
+
+	texture, ok := (*field).(*AdvancedTexture)
+	if !ok || texture == nil {
+		return nil, fmt.Errorf("texture slot %s has no animatable texture", slot)
+	}
+
+	if texture.UVModifier == nil {
+		clone := *texture
+		texture = &clone
+		*field = texture
+	}
+	return texture.TextureTransform(), nil
+}
+
+// BakeUVAnimation converts the scroll and rotation speeds of a UV transform
+// into keyframed channels covering duration seconds, so the motion can be
+// exported with KHR_animation_pointer. The speeds are cleared once baked.
+// pointerPrefix addresses the texture, e.g. "/materials/0/pbrMetallicRoughness/baseColorTexture".
+func BakeUVAnimation(name string, transform *UVTransform, pointerPrefix string, duration float64) *Animation {
+	animation := NewAnimation(name, duration)
+
+	if transform.ScrollSpeedU != 0 || transform.ScrollSpeedV != 0 {
+		start := Vector{transform.OffsetU, transform.OffsetV, 0}
+		end := start.Add(Vector{transform.ScrollSpeedU, transform.ScrollSpeedV, 0}.MulScalar(duration))
+		animation.AddChannel(AnimationChannel{
+			Property:      TextureOffset,
+			Keyframes:     []Keyframe{{Time: 0, Value: start}, {Time: duration, Value: end}},
+			Interpolation: Linear,
+			UVTarget:      transform,
+			Pointer:       textureTransformPropertyPointer(pointerPrefix, TextureOffset),
+		})
+	}
+
+	if transform.RotationSpeed != 0 {
+		// Keyframes hold KHR rotation values (the inverse of UVTransform.Rotation)
+		start := -transform.Rotation
+		end := start - transform.RotationSpeed*duration
+		animation.AddChannel(AnimationChannel{
+			Property:      TextureRotation,
+			Keyframes:     []Keyframe{{Time: 0, Value: start}, {Time: duration, Value: end}},
+			Interpolation: Linear,
+			UVTarget:      transform,
+			Pointer:       textureTransformPropertyPointer(pointerPrefix, TextureRotation),
+		})
+	}
+
+	transform.ScrollSpeedU = 0
+	transform.ScrollSpeedV = 0
+	transform.RotationSpeed = 0
+
+	return animation
+}
+
+// SetUVAnimationTime advances the UV modifiers of the material textures of
+// the scene to an absolute time, see UVModifier.SetAnimationTime, so the
+// scroll and rotation of textures are deterministic across rendered frames
+func (scene *Scene) SetUVAnimationTime(time float64) {
+	seen := make(map[*UVModifier]bool)
+	visit := func(material *PBRMaterial) {
+		if material == nil {
+			return
+		}
+		for _, slot := range []string{TextureSlotBaseColor, TextureSlotMetallicRoughness, TextureSlotNormal, TextureSlotOcclusion, TextureSlotEmissive} {
+			texture, ok := (*materialTextureSlot(material, slot)).(*AdvancedTexture)
+			if !ok || texture == nil || texture.UVModifier == nil || seen[texture.UVModifier] {
+				continue
+			}
+			seen[texture.UVModifier] = true
+			texture.UVModifier.SetAnimationTime(time)
+		}
+	}
+	for _, material := range scene.Materials {
+		visit(material)
+	}
+	scene.RootNode.VisitNodes(func(node *SceneNode) {
+		visit(node.Material)
+	})
+}
+
+// uvAnimationPeriod returns the time after which the scroll or rotation of
+// a UV transform repeats: one texture width of its slowest scroll or one
+// turn of its rotation, the longest if it does several. It is 0 for a
+// transform that doesn't move.
+func uvAnimationPeriod(transform *UVTransform) float64 {
+	period := 0.0
+	if transform.ScrollSpeedU != 0 {
+		period = math.Max(period, 1/math.Abs(transform.ScrollSpeedU))
+	}
+	if transform.ScrollSpeedV != 0 {
+		period = math.Max(period, 1/math.Abs(transform.ScrollSpeedV))
+	}
+	if transform.RotationSpeed != 0 {
+		period = math.Max(period, 2*math.Pi/math.Abs(transform.RotationSpeed))
+	}
+	return period
+}
+
+// textureTransformExtension decodes KHR_texture_transform from texture info extensions
+func textureTransformExtension(extensions gltf.Extensions) (*texturetransform.TextureTranform, bool) {
+	switch ext := extensions[texturetransform.ExtensionName].(type) {
+	case *texturetransform.TextureTranform:
+		return ext, true
+	case json.RawMessage:
+		transform := new(texturetransform.TextureTranform)
+		if err := json.Unmarshal(ext, transform); err == nil {
+			return transform, true
+		}
+	}
+	return nil, false
+}
+
+// animationPointer returns the KHR_animation_pointer path of a channel target
+func animationPointer(target gltf.AnimationChannelTarget) (string, bool) {
+	raw, ok := target.Extensions[animationPointerExtension]
+	if !ok {
+		return "", false
+	}
+
+	var data []byte
+	switch ext := raw.(type) {
+	case json.RawMessage:
+		data = ext
+	default:
+		var err error
+		if data, err = json.Marshal(ext); err != nil {
+			return "", false
+		}
+	}
+
+	var pointer struct {
+		Pointer string `json:"pointer"`
+	}
+	if err := json.Unmarshal(data, &pointer); err != nil || pointer.Pointer == "" {
+		return "", false
+	}
+	return pointer.Pointer, true
+}
+
+// gltfTextureExtensions returns the extensions of a glTF material texture slot
+func gltfTextureExtensions(material *gltf.Material, slot string) gltf.Extensions {
+	switch slot {
+	case TextureSlotBaseColor:
+		if material.PBRMetallicRoughness != nil && material.PBRMetallicRoughness.BaseColorTexture != nil {
+			return material.PBRMetallicRoughness.BaseColorTexture.Extensions
+		}
+	case TextureSlotMetallicRoughness:
+		if material.PBRMetallicRoughness != nil && material.PBRMetallicRoughness.MetallicRoughnessTexture != nil {
+			return material.PBRMetallicRoughness.MetallicRoughnessTexture.Extensions
+		}
+	case TextureSlotNormal:
+		if material.NormalTexture != nil {
+			return material.NormalTexture.Extensions
+		}
+	case TextureSlotOcclusion:
+		if material.OcclusionTexture != nil {
+			return material.OcclusionTexture.Extensions
+		}
+	case TextureSlotEmissive:
+		if material.EmissiveTexture != nil {
+			return material.EmissiveTexture.Extensions
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package fauxgl
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// BakeMode selects what a TextureBaker renders into a texture
+type BakeMode int
+
+const (
+	// BakeLighting bakes a lightmap: the light reaching the surface from
+	// the lights, shadowed, plus the ambient light, occluded. A light of
+	// intensity 1 facing the surface bakes white; multiply the lightmap
+	// over the base color to light the surface.
+	BakeLighting BakeMode = iota
+	// BakeAmbientOcclusion bakes the fraction of the hemisphere above the
+	// surface that is open within Distance, white where unoccluded
+	BakeAmbientOcclusion
+	// BakeShading bakes the full physically based shading of the material
+	// of the node, seen along the surface normal, for unlit materials
+	BakeShading
+)
+
+// TextureBaker renders the lighting of a scene on a node into a texture in
+// the UV space of its mesh, for exporting baked assets to real-time
+// engines. Shadows and occlusion are ray traced against the visible nodes
+// of the scene as stored: vertex modifiers and alpha are ignored. The UV
+// islands are dilated by Padding texels, see DilateImage, so the
+// background doesn't bleed into their edges. Texels of overlapping islands
+// are baked by one of them.
+type TextureBaker struct {
+	Mode          BakeMode
+	Width, Height int // Texture size
+	Padding       int // Texels the UV islands are dilated by
+	// Lights, when set, replace the lights of the scene
+	Lights []Light
+	// Shadows traces a ray from every texel to every light
+	Shadows bool
+	// Samples is the number of rays per texel the ambient occlusion is
+	// traced with, up to Distance (0 uses a quarter of the scene diagonal);
+	// 0 turns the occlusion off outside BakeAmbientOcclusion
+	Samples  int
+	Distance float64
+	// AmbientColor lights the surfaces when the lights have no AmbientLight,
+	// like PBRShader.AmbientColor
+	AmbientColor Color
+}
+
+// NewTextureBaker creates a baker of a 512 by 512 texture with shadows and
+// 32 occlusion rays per texel
+func NewTextureBaker(mode BakeMode) *TextureBaker {
+	return &TextureBaker{
+		Mode:         mode,
+		Width:        512,
+		Height:       512,
+		Padding:      4,
+		Shadows:      true,
+		Samples:      32,
+		AmbientColor: Color{0.1, 0.1, 0.1, 1},
+	}
+}
+
+// Bake renders the mesh of a node of a scene, placed by its world
+// transform, into a texture. Texels outside the UV islands and their
+// padding are transparent. It returns nil for nodes without a mesh.
+func (baker *TextureBaker) Bake(scene *Scene, node *SceneNode) *image.NRGBA {
+	if node.Mesh == nil {
+		return nil
+	}
+	scene.UpdateTransforms()
+	lights := baker.Lights
+	if lights == nil {
+		lights = scene.Lights
+	}
+	diagonal := 1.0
+	if bounds := scene.GetBounds(); bounds != EmptyBox {
+		diagonal = math.Max(bounds.Size().Length(), 1e-9)
+	}
+	distance := baker.Distance
+	if distance <= 0 {
+		distance = diagonal / 4
+	}
+	// Rays start this far off the surface, against hitting it
+	epsilon := diagonal * 1e-4
+
+	// The material of every triangle, when the mesh mixes them
+	var materials map[*Triangle]*PBRMaterial
+	if node.Mesh.hasMaterialIDs() && len(node.Materials) > 0 {
+		materials = make(map[*Triangle]*PBRMaterial, len(node.Mesh.Triangles))
+		for i, t := range node.Mesh.Triangles {
+			if id := node.Mesh.MaterialIDs[i]; id >= 0 && id < len(node.Materials) && node.Materials[id] != nil {
+				materials[t] = node.Materials[id]
+			}
+		}
+	}
+
+	model := node.WorldTransform
+	normalMatrix := model.Inverse().Transpose()
+	pbrLighting := &PBRLighting{}
+	return bakeTexels(node.Mesh, baker.Width, baker.Height, baker.Padding, func(t *Triangle, b VectorW, rnd *rand.Rand) Color {
+		position := model.MulPosition(InterpolateVectors(t.V1.Position, t.V2.Position, t.V3.Position, b))
+		normal := normalMatrix.MulDirection(InterpolateVectors(t.V1.Normal, t.V2.Normal, t.V3.Normal, b)).Normalize()
+		if normal == (Vector{}) {
+			normal = normalMatrix.MulDirection(t.Normal()).Normalize()
+		}
+		origin := position.Add(normal.MulScalar(epsilon))
+
+		occlusion := 1.0
+		if baker.Samples > 0 {
+			occlusion = baker.occlusion(scene, origin, normal, distance, rnd)
+		}
+		if baker.Mode == BakeAmbientOcclusion {
+			return Color{occlusion, occlusion, occlusion, 1}
+		}
+		shadowed := lights
+		if baker.Shadows {
+			shadowed = baker.shadowLights(scene, lights, origin)
+		}
+
+		if baker.Mode == BakeLighting {
+			c := bakedIrradiance(shadowed, position, normal, baker.AmbientColor, occlusion)
+			return c.Opaque()
+		}
+
+		material := node.Material
+		if m := materials[t]; m != nil {
+			material = m
+		}
+		if material == nil {
+			return missingColor
+		}
+		texture := InterpolateVectors(t.V1.Texture, t.V2.Texture, t.V3.Texture, b)
+		vertex := t.V1.Color.MulScalar(b.X).Add(t.V2.Color.MulScalar(b.Y)).Add(t.V3.Color.MulScalar(b.Z))
+		sampled := material.sample(texture.X, texture.Y, Vector{}, Vector{}, vertex)
+		sampled.Occlusion *= occlusion
+		tangent := t.V1.Tangent.MulScalar(b.X).Add(t.V2.Tangent.MulScalar(b.Y)).Add(t.V3.Tangent.MulScalar(b.Z))
+		worldTangent := model.MulDirection(tangent.Vector()).Normalize()
+		sampled.Tangent = worldTangent
+		shading := normal
+		if material.normalMapped() {
+			shading = TangentToWorld(normal, VectorW{worldTangent.X, worldTangent.Y, worldTangent.Z, tangent.W}, sampled.Normal)
+		}
+		c := pbrLighting.CalculatePBR(sampled, position, shading, normal, shadowed, baker.AmbientColor)
+		return c.Opaque()
+	})
+}
+
+// occlusion returns the fraction of cosine weighted rays from a surface
+// that leave it without hitting the scene within distance
+func (baker *TextureBaker) occlusion(scene *Scene, origin, normal Vector, distance float64, rnd *rand.Rand) float64 {
+	open := 0
+	for i := 0; i < baker.Samples; i++ {
+		direction := cosineSampleHemisphere(normal, rnd.Float64(), rnd.Float64())
+		if hit, ok := scene.RayIntersect(origin, direction); !ok || hit.Distance > distance {
+			open++
+		}
+	}
+	return float64(open) / float64(baker.Samples)
+}
+
+// shadowLights returns the lights with the intensity of those the scene
+// blocks from a position turned off
+func (baker *TextureBaker) shadowLights(scene *Scene, lights []Light, origin Vector) []Light {
+	shadowed := append([]Light(nil), lights...)
+	for i, light := range shadowed {
+		var direction Vector
+		reach := math.Inf(1)
+		switch light.Type {
+		case AmbientLight:
+			continue
+		case DirectionalLight:
+			direction = light.Direction.Negate().Normalize()
+		default:
+			offset := light.Position.Sub(origin)
+			direction, reach = offset.Normalize(), offset.Length()
+		}
+		if hit, ok := scene.RayIntersect(origin, direction); ok && hit.Distance < reach {
+			shadowed[i].Intensity = 0
+		}
+	}
+	return shadowed
+}
+
+// bakedIrradiance returns the light reaching a surface from lights, with
+// their attenuation and cones, and the occluded ambient light
+func bakedIrradiance(lights []Light, position, normal Vector, ambientColor Color, occlusion float64) Color {
+	var c Color
+	ambient := false
+	for _, light := range lights {
+		var toLight Vector
+		intensity := light.Intensity
+		switch light.Type {
+		case AmbientLight:
+			c = c.Add(light.Color.MulScalar(light.Intensity * occlusion))
+			ambient = true
+			continue
+		case DirectionalLight:
+			toLight = light.Direction.Negate().Normalize()
+		default:
+			offset := light.Position.Sub(position)
+			toLight = offset.Normalize()
+			if light.Range > 0 {
+				a := math.Max(0, 1-offset.Length()/light.Range)
+				intensity *= a * a
+			}
+			if light.Type == SpotLight {
+				inner, outer := math.Cos(light.InnerCone), math.Cos(light.OuterCone)
+				intensity *= Clamp((toLight.Dot(light.Direction.Negate())-outer)/math.Max(inner-outer, 1e-9), 0, 1)
+			}
+		}
+		c = c.Add(light.Color.MulScalar(intensity * math.Max(normal.Dot(toLight), 0)))
+	}
+	if !ambient {
+		c = c.Add(ambientColor.MulScalar(occlusion))
+	}
+	return c
+}
@@ -0,0 +1,271 @@
+package fauxgl
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TextureSlot identifies the material input a texture file maps to
+type TextureSlot int
+
+const (
+	// SlotBaseColor - albedo / base color map
+	SlotBaseColor TextureSlot = iota
+	// SlotNormal - tangent space normal map
+	SlotNormal
+	// SlotORM - packed occlusion (R), roughness (G), metallic (B)
+	SlotORM
+	// SlotRoughness - single channel roughness map
+	SlotRoughness
+	// SlotMetallic - single channel metallic map
+	SlotMetallic
+	// SlotOcclusion - single channel ambient occlusion map
+	SlotOcclusion
+	// SlotEmissive - emissive color map
+	SlotEmissive
+	// SlotHeight - height / displacement map
+	SlotHeight
+)
+
+// textureSlotSuffixes lists the file name suffixes recognized for each slot.
+// Longer suffixes come first so "base_color" wins over "color".
+var textureSlotSuffixes = []struct {
+	Slot     TextureSlot
+	Suffixes []string
+}{
+	{SlotBaseColor, []string{"base_color", "basecolor", "albedo", "diffuse", "color", "col"}},
+	{SlotNormal, []string{"normal_gl", "normalgl", "normal", "norm", "nrm"}},
+	{SlotORM, []string{"occlusionroughnessmetallic", "orm", "arm"}},
+	{SlotRoughness, []string{"roughness", "rough", "rgh"}},
+	{SlotMetallic, []string{"metallic", "metalness", "metal", "mtl"}},
+	{SlotOcclusion, []string{"ambient_occlusion", "ambientocclusion", "occlusion", "ao"}},
+	{SlotEmissive, []string{"emissive", "emission", "emit"}},
+	{SlotHeight, []string{"displacement", "height", "disp"}},
+}
+
+// textureSetExtensions lists the image formats LoadImage can decode
+var textureSetExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+}
+
+// TextureSet holds the file paths of a texture set, keyed by slot
+type TextureSet struct {
+	Name  string
+	Paths map[TextureSlot]string
+}
+
+// ClassifyTextureFile returns the slot a texture file belongs to based on
+// its naming convention (e.g. "wood_basecolor.png", "wood-Normal.jpg")
+func ClassifyTextureFile(path string) (TextureSlot, bool) {
+	base := strings.ToLower(filepath.Base(path))
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	for _, entry := range textureSlotSuffixes {
+		for _, suffix := range entry.Suffixes {
+			if base == suffix {
+				return entry.Slot, true
+			}
+			for _, sep := range []string{"_", "-", ".", " "} {
+				if strings.HasSuffix(base, sep+suffix) {
+					return entry.Slot, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// FindTextureSet scans a directory for texture files following common
+// naming conventions and groups them into a texture set
+func FindTextureSet(dir string) (*TextureSet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read texture directory: %w", err)
+	}
+
+	set := &TextureSet{
+		Name:  filepath.Base(dir),
+		Paths: make(map[TextureSlot]string),
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if !textureSetExtensions[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		slot, ok := ClassifyTextureFile(name)
+		if !ok {
+			continue
+		}
+		// Keep the first match per slot (ReadDir returns sorted entries)
+		if _, exists := set.Paths[slot]; !exists {
+			set.Paths[slot] = filepath.Join(dir, name)
+		}
+	}
+
+	if len(set.Paths) == 0 {
+		return nil, fmt.Errorf("no textures matching naming conventions found in %s", dir)
+	}
+
+	return set, nil
+}
+
+// BuildMaterial loads the textures of the set and builds a PBR material.
+// Separate roughness, metallic and occlusion maps are packed into a single
+// glTF-style ORM texture so they can be sampled by PBRMaterial.
+func (set *TextureSet) BuildMaterial() (*PBRMaterial, error) {
+	material := NewPBRMaterial()
+
+	load := func(slot TextureSlot, textureType TextureType) (*AdvancedTexture, error) {
+		path, ok := set.Paths[slot]
+		if !ok {
+			return nil, nil
+		}
+		texture, err := LoadAdvancedTexture(path, textureType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		return texture, nil
+	}
+
+	baseColor, err := load(SlotBaseColor, BaseColorTexture)
+	if err != nil {
+		return nil, err
+	}
+	if baseColor != nil {
+		material.BaseColorTexture = baseColor
+	}
+
+	normal, err := load(SlotNormal, NormalTexture)
+	if err != nil {
+		return nil, err
+	}
+	if normal != nil {
+		material.NormalTexture = normal
+	}
+
+	emissive, err := load(SlotEmissive, EmissiveTexture)
+	if err != nil {
+		return nil, err
+	}
+	if emissive != nil {
+		material.EmissiveTexture = emissive
+		material.EmissiveFactor = Color{1, 1, 1, 1}
+	}
+
+	height, err := load(SlotHeight, HeightTexture)
+	if err != nil {
+		return nil, err
+	}
+	if height != nil {
+		material.HeightTexture = height
+	}
+
+	orm, err := load(SlotORM, MetallicTexture)
+	if err != nil {
+		return nil, err
+	}
+	if orm != nil {
+		material.MetallicRoughnessTexture = orm
+		material.OcclusionTexture = orm
+		return material, nil
+	}
+
+	roughness, err := load(SlotRoughness, RoughnessTexture)
+	if err != nil {
+		return nil, err
+	}
+	metallic, err := load(SlotMetallic, MetallicTexture)
+	if err != nil {
+		return nil, err
+	}
+	occlusion, err := load(SlotOcclusion, OcclusionTexture)
+	if err != nil {
+		return nil, err
+	}
+
+	if metallic == nil {
+		// Texture sets without a metallic map are dielectrics
+		material.MetallicFactor = 0
+	}
+	if roughness != nil || metallic != nil {
+		packed := packORMImage(occlusion, roughness, metallic)
+		material.MetallicRoughnessTexture = NewAdvancedTexture(packed, MetallicTexture)
+	}
+	if occlusion != nil {
+		material.OcclusionTexture = occlusion
+	}
+
+	return material, nil
+}
+
+// packORMImage combines separate grayscale maps into one ORM image.
+// Missing occlusion defaults to 1 and missing roughness/metallic to the
+// neutral value expected by the material factors.
+func packORMImage(occlusion, roughness, metallic *AdvancedTexture) image.Image {
+	width, height := 1, 1
+	for _, t := range []*AdvancedTexture{roughness, metallic, occlusion} {
+		if t != nil && t.Width*t.Height > width*height {
+			width, height = t.Width, t.Height
+		}
+	}
+
+	channel := func(t *AdvancedTexture, u, v, fallback float64) uint8 {
+		if t == nil {
+			return uint8(fallback * 255)
+		}
+		return uint8(Clamp(t.BilinearSample(u, v).R, 0, 1) * 255)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		// Sample at texel centers; V is flipped by the sampler
+		v := 1 - (float64(y)+0.5)/float64(height)
+		for x := 0; x < width; x++ {
+			u := (float64(x) + 0.5) / float64(width)
+			img.SetNRGBA(x, y, color.NRGBA{
+				R: channel(occlusion, u, v, 1),
+				G: channel(roughness, u, v, 1),
+				B: channel(metallic, u, v, 0),
+				A: 255,
+			})
+		}
+	}
+	return img
+}
+
+// LoadTextureSetMaterial scans a directory for a texture set and builds a
+// PBR material from it
+func LoadTextureSetMaterial(dir string) (*PBRMaterial, error) {
+	set, err := FindTextureSet(dir)
+	if err != nil {
+		return nil, err
+	}
+	return set.BuildMaterial()
+}
+
+// ApplyTextureSet builds a material from the texture set in dir and assigns
+// it to the node and every descendant that has a mesh
+func ApplyTextureSet(node *SceneNode, dir string) (*PBRMaterial, error) {
+	material, err := LoadTextureSetMaterial(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	node.VisitNodes(func(n *SceneNode) {
+		if n.Mesh != nil {
+			n.Material = material
+		}
+	})
+
+	return material, nil
+}
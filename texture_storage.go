@@ -0,0 +1,273 @@
+package fauxgl
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+)
+
+// TexelFormat is the in-memory layout of a TexelImage
+type TexelFormat int
+
+const (
+	// TexelAuto - TexelGray8 for opaque gray images, TexelRGBA8 otherwise
+	TexelAuto TexelFormat = iota
+	// TexelRGBA8 - 4 bytes per texel, straight alpha
+	TexelRGBA8
+	// TexelGray8 - 1 byte per texel, opaque; for single channel maps
+	TexelGray8
+	// TexelBC1 - 4x4 blocks of two RGB565 colors and 2-bit indices, half a
+	// byte per texel, opaque. Lossy, like BC1/DXT1 GPU compression.
+	TexelBC1
+)
+
+// TexelImage is a compact, read-only image for texture sampling. Samplers
+// read its bytes directly instead of boxing every texel through
+// image.Image.At, and block compressed formats decode single texels from
+// their block, so no decoded copy is kept.
+type TexelImage struct {
+	Format TexelFormat
+	Pix    []uint8
+	Stride int // Bytes between rows of texels, or of blocks for TexelBC1
+	Rect   image.Rectangle
+}
+
+// NewTexelImage converts an image to a TexelImage of the given format
+func NewTexelImage(src image.Image, format TexelFormat) *TexelImage {
+	img := toNRGBA(src)
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if format == TexelAuto {
+		format = TexelRGBA8
+		if opaqueGray(img) {
+			format = TexelGray8
+		}
+	}
+	ti := &TexelImage{Format: format, Rect: image.Rect(0, 0, width, height)}
+	switch format {
+	case TexelGray8:
+		ti.Stride = width
+		ti.Pix = make([]uint8, width*height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				p := img.Pix[y*img.Stride+x*4:]
+				ti.Pix[y*width+x] = uint8((299*int(p[0]) + 587*int(p[1]) + 114*int(p[2]) + 500) / 1000)
+			}
+		}
+	case TexelBC1:
+		bw, bh := (width+3)/4, (height+3)/4
+		ti.Stride = bw * 8
+		ti.Pix = make([]uint8, bw*bh*8)
+		for by := 0; by < bh; by++ {
+			for bx := 0; bx < bw; bx++ {
+				encodeBC1Block(ti.Pix[by*ti.Stride+bx*8:], img, bx*4, by*4)
+			}
+		}
+	default:
+		ti.Format = TexelRGBA8
+		ti.Stride = width * 4
+		ti.Pix = make([]uint8, width*height*4)
+		for y := 0; y < height; y++ {
+			copy(ti.Pix[y*ti.Stride:(y+1)*ti.Stride], img.Pix[y*img.Stride:])
+		}
+	}
+	return ti
+}
+
+func (ti *TexelImage) Bounds() image.Rectangle {
+	return ti.Rect
+}
+
+func (ti *TexelImage) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (ti *TexelImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(ti.Rect)) {
+		return color.NRGBA{}
+	}
+	r, g, b, a := ti.texel(x, y)
+	return color.NRGBA{r, g, b, a}
+}
+
+// texel returns the straight alpha bytes of a texel
+func (ti *TexelImage) texel(x, y int) (r, g, b, a uint8) {
+	switch ti.Format {
+	case TexelGray8:
+		v := ti.Pix[y*ti.Stride+x]
+		return v, v, v, 255
+	case TexelBC1:
+		block := ti.Pix[(y/4)*ti.Stride+(x/4)*8:]
+		c0 := binary.LittleEndian.Uint16(block)
+		c1 := binary.LittleEndian.Uint16(block[2:])
+		index := binary.LittleEndian.Uint32(block[4:]) >> (2 * uint((y%4)*4+x%4)) & 3
+		r, g, b = bc1Color(c0, c1, index)
+		return r, g, b, 255
+	default:
+		p := ti.Pix[y*ti.Stride+x*4:]
+		return p[0], p[1], p[2], p[3]
+	}
+}
+
+// color returns a texel as a premultiplied color, like MakeColor
+func (ti *TexelImage) color(x, y int) Color {
+	r, g, b, a := ti.texel(x, y)
+	if a == 255 {
+		return Color{float64(r) / 255, float64(g) / 255, float64(b) / 255, 1}
+	}
+	fa := float64(a) / 255
+	return Color{float64(r) / 255 * fa, float64(g) / 255 * fa, float64(b) / 255 * fa, fa}
+}
+
+// bilinear is sampleImageBilinear without image.Image calls
+func (ti *TexelImage) bilinear(u, v float64) Color {
+	width, height := ti.Rect.Dx(), ti.Rect.Dy()
+	if ti.Format == TexelRGBA8 {
+		return sampleNRGBABilinear(&image.NRGBA{Pix: ti.Pix, Stride: ti.Stride, Rect: ti.Rect}, width, height, u, v)
+	}
+	x := u * float64(width-1)
+	y := v * float64(height-1)
+	x0 := ClampInt(int(x), 0, width-1)
+	y0 := ClampInt(int(y), 0, height-1)
+	x1 := ClampInt(x0+1, 0, width-1)
+	y1 := ClampInt(y0+1, 0, height-1)
+	fx := x - float64(int(x))
+	fy := y - float64(int(y))
+
+	if ti.Format == TexelGray8 {
+		row0, row1 := ti.Pix[y0*ti.Stride:], ti.Pix[y1*ti.Stride:]
+		top := float64(row0[x0]) + (float64(row0[x1])-float64(row0[x0]))*fx
+		bottom := float64(row1[x0]) + (float64(row1[x1])-float64(row1[x0]))*fx
+		g := (top + (bottom-top)*fy) / 255
+		return Color{g, g, g, 1}
+	}
+
+	top := ti.color(x0, y0).Lerp(ti.color(x1, y0), fx)
+	bottom := ti.color(x0, y1).Lerp(ti.color(x1, y1), fx)
+	return top.Lerp(bottom, fy)
+}
+
+// Size returns the number of bytes of texel data
+func (ti *TexelImage) Size() int {
+	return len(ti.Pix)
+}
+
+// Compact converts the texture image and its mip levels to TexelImages of
+// a format, which sample several times faster than generic images and, for
+// gray and block compressed formats, take a quarter or an eighth of the
+// memory of RGBA
+func (t *AdvancedTexture) Compact(format TexelFormat) {
+	if format == TexelAuto {
+		format = TexelRGBA8
+		if opaqueGray(toNRGBA(t.Image)) {
+			format = TexelGray8
+		}
+	}
+	levels := t.MipLevels
+	t.Image = NewTexelImage(t.Image, format)
+	if len(levels) == 0 {
+		return
+	}
+	t.MipLevels = make([]image.Image, len(levels))
+	t.MipLevels[0] = t.Image
+	for i := 1; i < len(levels); i++ {
+		t.MipLevels[i] = NewTexelImage(levels[i], format)
+	}
+}
+
+// opaqueGray reports whether every pixel of an image is opaque gray
+func opaqueGray(img *image.NRGBA) bool {
+	b := img.Rect
+	for y := 0; y < b.Dy(); y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+b.Dx()*4]
+		for i := 0; i < len(row); i += 4 {
+			if row[i+3] != 255 || row[i] != row[i+1] || row[i] != row[i+2] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// encodeBC1Block encodes the 4x4 block of an image at x0, y0 with the
+// colors of least and greatest luminance as endpoints
+func encodeBC1Block(dst []uint8, img *image.NRGBA, x0, y0 int) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	var texels [16][3]float64
+	lo, hi := 0, 0
+	for i := range texels {
+		x := minInt(x0+i%4, width-1)
+		y := minInt(y0+i/4, height-1)
+		p := img.Pix[y*img.Stride+x*4:]
+		texels[i] = [3]float64{float64(p[0]), float64(p[1]), float64(p[2])}
+		if luma(texels[i]) < luma(texels[lo]) {
+			lo = i
+		}
+		if luma(texels[i]) > luma(texels[hi]) {
+			hi = i
+		}
+	}
+	c0 := packRGB565(texels[hi])
+	c1 := packRGB565(texels[lo])
+	if c0 < c1 {
+		c0, c1 = c1, c0
+	}
+	var indices uint32
+	if c0 != c1 {
+		var palette [4][3]float64
+		for i := range palette {
+			r, g, b := bc1Color(c0, c1, uint32(i))
+			palette[i] = [3]float64{float64(r), float64(g), float64(b)}
+		}
+		for i, c := range texels {
+			best, bestDistance := 0, math.MaxFloat64
+			for j, p := range palette {
+				dr, dg, db := c[0]-p[0], c[1]-p[1], c[2]-p[2]
+				if d := dr*dr + dg*dg + db*db; d < bestDistance {
+					best, bestDistance = j, d
+				}
+			}
+			indices |= uint32(best) << (2 * uint(i))
+		}
+	}
+	binary.LittleEndian.PutUint16(dst, c0)
+	binary.LittleEndian.PutUint16(dst[2:], c1)
+	binary.LittleEndian.PutUint32(dst[4:], indices)
+}
+
+func luma(c [3]float64) float64 {
+	return 0.299*c[0] + 0.587*c[1] + 0.114*c[2]
+}
+
+// packRGB565 rounds a color to 16 bits
+func packRGB565(c [3]float64) uint16 {
+	r := uint16(math.Round(c[0] * 31 / 255))
+	g := uint16(math.Round(c[1] * 63 / 255))
+	b := uint16(math.Round(c[2] * 31 / 255))
+	return r<<11 | g<<5 | b
+}
+
+// bc1Color returns a palette color of a BC1 block in four color mode, or
+// three color mode when c0 <= c1 with index 3 black
+func bc1Color(c0, c1 uint16, index uint32) (r, g, b uint8) {
+	unpack := func(c uint16) (int, int, int) {
+		r, g, b := int(c>>11&31), int(c>>5&63), int(c&31)
+		return r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2
+	}
+	r0, g0, b0 := unpack(c0)
+	r1, g1, b1 := unpack(c1)
+	switch {
+	case index == 0:
+		return uint8(r0), uint8(g0), uint8(b0)
+	case index == 1:
+		return uint8(r1), uint8(g1), uint8(b1)
+	case c0 > c1 && index == 2:
+		return uint8((2*r0 + r1 + 1) / 3), uint8((2*g0 + g1 + 1) / 3), uint8((2*b0 + b1 + 1) / 3)
+	case c0 > c1:
+		return uint8((r0 + 2*r1 + 1) / 3), uint8((g0 + 2*g1 + 1) / 3), uint8((b0 + 2*b1 + 1) / 3)
+	case index == 2:
+		return uint8((r0 + r1) / 2), uint8((g0 + g1) / 2), uint8((b0 + b1) / 2)
+	default:
+		return 0, 0, 0
+	}
+}
@@ -0,0 +1,264 @@
+package fauxgl
+
+import (
+	"math"
+	"sort"
+)
+
+// ThumbnailOptions configures the candidate cameras and scoring of
+// ThumbnailCameras
+type ThumbnailOptions struct {
+	// Azimuths cameras are placed evenly around the vertical axis, starting
+	// from the front (+Z), at each of Elevations angles from MinElevation to
+	// MaxElevation above the horizon, in radians
+	Azimuths     int
+	Elevations   int
+	MinElevation float64
+	MaxElevation float64
+	FOV          float64 // Vertical field of view in radians
+	AspectRatio  float64 // Width over height of the thumbnail
+	Margin       float64 // Distance factor beyond a tight fit of the bounding sphere
+	Resolution   int     // Height in pixels of the ID buffer candidates are scored on
+	// Weights of the coverage, variety and composition scores, see
+	// ThumbnailCandidate
+	CoverageWeight    float64
+	VarietyWeight     float64
+	CompositionWeight float64
+}
+
+// NewThumbnailOptions returns the default thumbnail options: 12 azimuths at
+// 15, 30 and 45 degrees, with a 35 degree square view
+func NewThumbnailOptions() *ThumbnailOptions {
+	return &ThumbnailOptions{
+		Azimuths:          12,
+		Elevations:        3,
+		MinElevation:      Radians(15),
+		MaxElevation:      Radians(45),
+		FOV:               Radians(35),
+		AspectRatio:       1,
+		Margin:            1.1,
+		Resolution:        96,
+		CoverageWeight:    1,
+		VarietyWeight:     0.5,
+		CompositionWeight: 0.5,
+	}
+}
+
+// ThumbnailCandidate is a camera scored by ThumbnailCameras. The scores
+// range from 0 to 1:
+//
+//   - Coverage is the silhouette area, relative to the largest of all the
+//     candidates, so that views showing the broad side of a model win
+//   - Variety is the number of materials visible, weighted by the share of
+//     the silhouette each covers, relative to the materials in the scene
+//   - Composition is how close the center of mass of the silhouette falls
+//     to the center of the frame or one of the rule of thirds points
+type ThumbnailCandidate struct {
+	Camera      *Camera
+	Azimuth     float64 // Radians around the vertical axis from the front
+	Elevation   float64 // Radians above the horizon
+	Coverage    float64
+	Variety     float64
+	Composition float64
+	Score       float64 // Weighted sum of the scores
+
+	extent float64 // Largest distance of the silhouette from the frame center, 1 at its edges
+}
+
+// ChooseThumbnailCamera returns the best camera of ThumbnailCameras for an
+// unattended thumbnail of a scene, or nil when it has nothing to render.
+// The scene is assumed to be Y-up, see AutoOrient.
+func ChooseThumbnailCamera(scene *Scene, options *ThumbnailOptions) *Camera {
+	candidates := ThumbnailCameras(scene, options)
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[0].Camera
+}
+
+// ThumbnailCameras places candidate cameras around the bounds of a scene,
+// each framing its bounding sphere, renders the materials each sees into a
+// small ID buffer and scores them. The cameras are then moved in until the
+// silhouette they see fills the frame up to the margin. Candidates are
+// returned best first.
+func ThumbnailCameras(scene *Scene, options *ThumbnailOptions) []*ThumbnailCandidate {
+	if options == nil {
+		options = NewThumbnailOptions()
+	}
+	bounds := scene.GetBounds()
+	nodes := expandMaterialNodes(scene.RootNode.GetRenderableNodes())
+	if bounds == EmptyBox || len(nodes) == 0 {
+		return nil
+	}
+
+	// Material IDs, from 1
+	ids := make(map[*PBRMaterial]int)
+	for _, node := range nodes {
+		if _, ok := ids[node.Material]; !ok {
+			ids[node.Material] = len(ids) + 1
+		}
+	}
+
+	// Distance at which the bounding sphere fits the narrower field of view
+	aspect := options.AspectRatio
+	if aspect <= 0 {
+		aspect = 1
+	}
+	fov := options.FOV
+	if fov <= 0 || fov >= math.Pi {
+		fov = Radians(35)
+	}
+	halfFOV := fov / 2
+	if aspect < 1 {
+		halfFOV = math.Atan(math.Tan(halfFOV) * aspect)
+	}
+	center := bounds.Center()
+	radius := math.Max(bounds.Size().Length()/2, 1e-9)
+	margin := math.Max(options.Margin, 1)
+	distance := radius / math.Sin(halfFOV) * margin
+
+	height := maxInt(options.Resolution, 8)
+	width := maxInt(int(math.Round(float64(height)*aspect)), 1)
+	azimuths := maxInt(options.Azimuths, 1)
+	elevations := maxInt(options.Elevations, 1)
+
+	var candidates []*ThumbnailCandidate
+	maxCoverage := 0.0
+	for j := 0; j < elevations; j++ {
+		elevation := options.MinElevation
+		if elevations > 1 {
+			elevation += (options.MaxElevation - options.MinElevation) * float64(j) / float64(elevations-1)
+		}
+		for i := 0; i < azimuths; i++ {
+			azimuth := 2 * math.Pi * float64(i) / float64(azimuths)
+			direction := Vector{
+				math.Sin(azimuth) * math.Cos(elevation),
+				math.Sin(elevation),
+				math.Cos(azimuth) * math.Cos(elevation),
+			}
+			camera := NewPerspectiveCamera("Thumbnail", center.Add(direction.MulScalar(distance)), center, Vector{0, 1, 0}, fov, aspect, 0.01, 1000)
+			camera.FitDepthRange(bounds, 0.05)
+
+			candidate := &ThumbnailCandidate{Camera: camera, Azimuth: azimuth, Elevation: elevation}
+			candidate.score(renderThumbnailIDs(camera, nodes, ids, width, height), width, height, len(ids))
+			maxCoverage = math.Max(maxCoverage, candidate.Coverage)
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	for _, candidate := range candidates {
+		if maxCoverage > 0 {
+			candidate.Coverage /= maxCoverage
+		}
+		candidate.Score = options.CoverageWeight*candidate.Coverage +
+			options.VarietyWeight*candidate.Variety +
+			options.CompositionWeight*candidate.Composition
+		candidate.frame(bounds, radius, margin)
+	}
+	// Ties keep the order of the candidates, front first
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// frame moves the camera of the candidate towards its target until the
+// silhouette fills the frame up to the margin, staying outside the
+// bounding sphere
+func (candidate *ThumbnailCandidate) frame(bounds Box, radius, margin float64) {
+	if candidate.extent <= 0 {
+		return
+	}
+	camera := candidate.Camera
+	offset := camera.Position.Sub(camera.Target)
+	distance := offset.Length()
+	// Perspective shrinks the silhouette as the camera backs off, so this
+	// leaves a little more margin than asked for
+	closer := math.Max(distance*candidate.extent*margin, radius*1.05)
+	if closer < distance {
+		camera.Position = camera.Target.Add(offset.MulScalar(closer / distance))
+		camera.FitDepthRange(bounds, 0.05)
+	}
+}
+
+// renderThumbnailIDs renders the material IDs of the nodes seen by a
+// camera, 0 where none is visible
+func renderThumbnailIDs(camera *Camera, nodes []*SceneNode, ids map[*PBRMaterial]int, width, height int) []int {
+	dc := NewContext(width, height)
+	dc.SetDepthMode(camera.DepthMode)
+	dc.AlphaBlend = false
+	cameraMatrix := camera.GetProjectionMatrix().Mul(camera.GetViewMatrix())
+	for _, node := range nodes {
+		// IDs are stored in the red and green bytes, like RenderIDs
+		id := ids[node.Material]
+		c := Color{(float64(id&0xff) + 0.5) / 255, (float64(id>>8&0xff) + 0.5) / 255, 0, 1}
+		if node.Material.DoubleSided {
+			dc.Cull = CullNone
+		} else {
+			dc.Cull = CullBack
+		}
+		for _, transform := range node.InstanceTransforms() {
+			dc.Shader = NewSolidColorShader(cameraMatrix.Mul(transform), c)
+			dc.DrawMesh(node.Mesh)
+		}
+	}
+
+	result := make([]int, width*height)
+	for y := 0; y < height; y++ {
+		row := dc.ColorBuffer.Pix[dc.ColorBuffer.PixOffset(0, y):]
+		for x := 0; x < width; x++ {
+			p := row[x*4:]
+			if p[3] != 0 {
+				result[y*width+x] = int(p[0]) | int(p[1])<<8
+			}
+		}
+	}
+	return result
+}
+
+// score sets the coverage, before normalization, variety and composition
+// of the candidate from its ID buffer
+func (candidate *ThumbnailCandidate) score(ids []int, width, height, materials int) {
+	pixels := make(map[int]int)
+	covered := 0
+	var cx, cy float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			id := ids[y*width+x]
+			if id == 0 {
+				continue
+			}
+			pixels[id]++
+			covered++
+			ex := math.Abs((float64(x)+0.5)/float64(width)-0.5) * 2
+			ey := math.Abs((float64(y)+0.5)/float64(height)-0.5) * 2
+			candidate.extent = math.Max(candidate.extent, math.Max(ex, ey))
+			cx += (float64(x) + 0.5) / float64(width)
+			cy += (float64(y) + 0.5) / float64(height)
+		}
+	}
+	if covered == 0 {
+		return
+	}
+	candidate.Coverage = float64(covered) / float64(width*height)
+
+	// The perplexity of the material shares counts materials covering
+	// slivers of the silhouette as a fraction of one
+	entropy := 0.0
+	for _, n := range pixels {
+		p := float64(n) / float64(covered)
+		entropy -= p * math.Log(p)
+	}
+	candidate.Variety = math.Exp(entropy) / float64(materials)
+
+	cx /= float64(covered)
+	cy /= float64(covered)
+	nearest := math.Hypot(cx-0.5, cy-0.5)
+	for _, tx := range []float64{1.0 / 3, 2.0 / 3} {
+		for _, ty := range []float64{1.0 / 3, 2.0 / 3} {
+			nearest = math.Min(nearest, math.Hypot(cx-tx, cy-ty))
+		}
+	}
+	// A sixth of the frame away from every point scores 0
+	candidate.Composition = Clamp(1-nearest*6, 0, 1)
+}
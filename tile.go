@@ -0,0 +1,96 @@
+package fauxgl
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// RenderTile is a region of a frame rendered on its own, so one large frame
+// can be split across the workers of a render farm and stitched back with
+// TileStitcher. A tile renders exactly the pixels the whole frame would:
+// its context maps the frame's projection onto the tile, and jittered
+// renderers like ProgressiveRenderer use the same sample offsets in every
+// tile. Tiles hold only plain values and can be sent to workers as JSON.
+type RenderTile struct {
+	Width, Height int             // Size of the whole frame
+	Rect          image.Rectangle // Region of the frame the tile covers
+	// Padding is rendered around Rect and cropped when stitching, for
+	// passes reading neighbor pixels, like adaptive supersampling, contact
+	// shadows and blurs, to see the pixels of the neighboring tiles
+	Padding int
+}
+
+// SplitFrame splits a frame into tiles of at most tileSize pixels square,
+// in rows from the top left, each rendered with padding around it
+func SplitFrame(width, height, tileSize, padding int) []RenderTile {
+	if tileSize <= 0 {
+		tileSize = maxInt(width, height)
+	}
+	var tiles []RenderTile
+	for y := 0; y < height; y += tileSize {
+		for x := 0; x < width; x += tileSize {
+			rect := image.Rect(x, y, x+tileSize, y+tileSize).Intersect(image.Rect(0, 0, width, height))
+			tiles = append(tiles, RenderTile{width, height, rect, padding})
+		}
+	}
+	return tiles
+}
+
+// Bounds returns the region of the frame the tile renders: Rect grown by
+// the padding, within the frame
+func (t RenderTile) Bounds() image.Rectangle {
+	return t.Rect.Inset(-t.Padding).Intersect(image.Rect(0, 0, t.Width, t.Height))
+}
+
+// NewContext creates a context of the size of Bounds rendering that region
+// of the frame; draw into it as into a context of the whole frame
+func (t RenderTile) NewContext() *Context {
+	b := t.Bounds()
+	dc := NewContext(b.Dx(), b.Dy())
+	offset := Vector{float64(-b.Min.X), float64(-b.Min.Y), 0}
+	dc.screenMatrix = Translate(offset).Mul(Screen(t.Width, t.Height))
+	return dc
+}
+
+// TileStitcher assembles the images of the tiles of a frame
+type TileStitcher struct {
+	Image *image.NRGBA
+	tiles map[image.Rectangle]bool
+	area  int
+}
+
+// NewTileStitcher creates a stitcher for a frame, transparent until tiles
+// are added
+func NewTileStitcher(width, height int) *TileStitcher {
+	return &TileStitcher{
+		Image: image.NewNRGBA(image.Rect(0, 0, width, height)),
+		tiles: make(map[image.Rectangle]bool),
+	}
+}
+
+// Add copies the Rect region of a tile's image into the frame, cropping
+// the padding. The image must have the size of the tile's Bounds, like the
+// color buffer of its context.
+func (s *TileStitcher) Add(tile RenderTile, img image.Image) error {
+	b := tile.Bounds()
+	if img.Bounds().Dx() != b.Dx() || img.Bounds().Dy() != b.Dy() {
+		return fmt.Errorf("tile image is %dx%d, not %dx%d", img.Bounds().Dx(), img.Bounds().Dy(), b.Dx(), b.Dy())
+	}
+	if tile.Width != s.Image.Rect.Dx() || tile.Height != s.Image.Rect.Dy() {
+		return fmt.Errorf("tile of a %dx%d frame, not %dx%d", tile.Width, tile.Height, s.Image.Rect.Dx(), s.Image.Rect.Dy())
+	}
+	src := img.Bounds().Min.Add(tile.Rect.Min.Sub(b.Min))
+	draw.Draw(s.Image, tile.Rect, img, src, draw.Src)
+	if !s.tiles[tile.Rect] {
+		s.tiles[tile.Rect] = true
+		s.area += tile.Rect.Dx() * tile.Rect.Dy()
+	}
+	return nil
+}
+
+// Complete reports whether tiles covering the whole frame were added,
+// assuming the tiles don't overlap, as those of SplitFrame
+func (s *TileStitcher) Complete() bool {
+	return s.area >= s.Image.Rect.Dx()*s.Image.Rect.Dy()
+}
@@ -0,0 +1,178 @@
+package fauxgl
+
+import "math"
+
+// ToonStyle configures the cel shading of ToonShader
+type ToonStyle struct {
+	// Bands is the number of flat lighting levels from unlit to fully lit,
+	// at least 2, and Softness the fraction of a band over which the steps blend
+	Bands    int
+	Softness float64
+	// AmbientColor lights the unlit band
+	AmbientColor Color
+	// Specular is the size of the flat highlight from 0, none, to 1, and
+	// SpecularColor its color
+	Specular      float64
+	SpecularColor Color
+	// RimWidth lights the edges of the surfaces facing away from the camera
+	// in RimColor, from 0, none, to 1
+	RimWidth float64
+	RimColor Color
+}
+
+// NewToonStyle returns a three band style with a small white highlight
+func NewToonStyle() *ToonStyle {
+	return &ToonStyle{
+		Bands:         3,
+		Softness:      0.05,
+		AmbientColor:  Color{0.25, 0.25, 0.3, 1},
+		Specular:      0.1,
+		SpecularColor: White,
+		RimColor:      White,
+	}
+}
+
+// ToonShader is a non-photorealistic shader for cel shaded illustrations:
+// the diffuse lighting of every light is quantized into the flat bands of
+// Style, with a flat highlight and rim. The base color comes from the
+// material. Combine it with OutlineEffect for ink lines.
+type ToonShader struct {
+	Matrix         Matrix
+	Model          Matrix // Model to world space, where the lights are
+	Material       *PBRMaterial
+	Lights         []Light
+	CameraPosition Vector // World position of the camera
+	Style          *ToonStyle
+	// ReceiveShadows darkens lights by their Shadow maps, which are
+	// quantized with the rest of the lighting
+	ReceiveShadows bool
+}
+
+// NewToonShader creates a toon shader of a material lit by lights
+func NewToonShader(matrix Matrix, material *PBRMaterial, lights []Light, cameraPosition Vector, style *ToonStyle) *ToonShader {
+	if style == nil {
+		style = NewToonStyle()
+	}
+	return &ToonShader{
+		Matrix:         matrix,
+		Model:          Identity(),
+		Material:       material,
+		Lights:         lights,
+		CameraPosition: cameraPosition,
+		Style:          style,
+		ReceiveShadows: true,
+	}
+}
+
+// toonShader returns the toon shader of a node instance for SceneRenderer.Toon
+func (renderer *SceneRenderer) toonShader(node *SceneNode, matrix, model Matrix, lights []Light) *ToonShader {
+	shader := NewToonShader(matrix, node.Material, lights, renderer.cameraPosition, renderer.Toon)
+	shader.Model = model
+	shader.ReceiveShadows = node.ReceiveShadows
+	return shader
+}
+
+func (shader *ToonShader) Vertex(v Vertex) Vertex {
+	v.Output = shader.Matrix.MulPositionW(v.Position)
+	return worldVertex(v, shader.Model)
+}
+
+func (shader *ToonShader) Fragment(v Vertex) Color {
+	if shader.Material == nil {
+		return missingColor
+	}
+	material := shader.Material.sample(v.Texture.X, v.Texture.Y, v.TextureDx, v.TextureDy, v.Color)
+	base := material.BaseColor
+	if shader.Material.AlphaMode == AlphaMask && base.A < shader.Material.AlphaCutoff {
+		return Discard
+	}
+
+	style := shader.Style
+	position, normal := v.Position, v.Normal
+	view := shader.CameraPosition.Sub(position).Normalize()
+	if normal.Dot(view) < 0 {
+		normal = normal.Negate()
+	}
+
+	light := style.AmbientColor
+	var highlight float64
+	var highlightColor Color
+	for _, l := range shader.Lights {
+		var toLight Vector
+		intensity := l.Intensity
+		switch l.Type {
+		case AmbientLight:
+			light = light.Add(l.Color.MulScalar(l.Intensity))
+			continue
+		case DirectionalLight:
+			toLight = l.Direction.Negate().Normalize()
+		default:
+			offset := l.Position.Sub(position)
+			toLight = offset.Normalize()
+			if l.Range > 0 {
+				a := math.Max(0, 1-offset.Length()/l.Range)
+				intensity *= a * a
+			}
+			if l.Type == SpotLight {
+				inner, outer := math.Cos(l.InnerCone), math.Cos(l.OuterCone)
+				intensity *= Clamp((toLight.Dot(l.Direction.Negate())-outer)/math.Max(inner-outer, 1e-9), 0, 1)
+			}
+		}
+		diffuse := math.Max(normal.Dot(toLight), 0)
+		if shader.ReceiveShadows && l.Shadow != nil && diffuse > 0 {
+			diffuse *= l.Shadow.Visibility(position, normal)
+		}
+		level := style.band(diffuse)
+		light = light.Add(l.Color.MulScalar(intensity * level))
+
+		if style.Specular > 0 && level > 0 {
+			// A flat disk around the mirror direction
+			h := toLight.Add(view).Normalize()
+			threshold := math.Cos(style.Specular * math.Pi / 4)
+			if s := style.step(normal.Dot(h), threshold, (1-threshold)*2); s > highlight {
+				highlight, highlightColor = s, style.SpecularColor.MulScalar(math.Min(intensity, 1))
+			}
+		}
+	}
+
+	c := base.Mul(light)
+	c = c.Add(highlightColor.MulScalar(highlight))
+	if style.RimWidth > 0 {
+		rim := style.step(1-normal.Dot(view), 1-style.RimWidth, style.RimWidth)
+		c = c.Add(style.RimColor.MulScalar(rim))
+	}
+	c = c.Add(material.Emissive.MulScalar(material.EmissiveStrength))
+	c.A = base.A
+	return c
+}
+
+// band quantizes a lighting level from 0 to 1 into the bands of the style,
+// spread from unlit to fully lit
+func (style *ToonStyle) band(level float64) float64 {
+	bands := maxInt(style.Bands, 2)
+	x := Clamp(level, 0, 1) * float64(bands)
+	step := math.Floor(x)
+	if step >= float64(bands-1) {
+		return 1
+	}
+	// Blend into the next band over the last Softness of this one
+	if style.Softness > 0 {
+		t := Clamp((x-step-(1-style.Softness))/style.Softness, 0, 1)
+		step += t * t * (3 - 2*t)
+	}
+	return step / float64(bands-1)
+}
+
+// step returns 0 below a threshold and 1 above it, blending over the
+// softness of the style relative to a range
+func (style *ToonStyle) step(x, threshold, scale float64) float64 {
+	width := style.Softness * scale
+	if width <= 0 {
+		if x >= threshold {
+			return 1
+		}
+		return 0
+	}
+	t := Clamp((x-threshold)/width+0.5, 0, 1)
+	return t * t * (3 - 2*t)
+}
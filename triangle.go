@@ -79,6 +79,12 @@ func (t *Triangle) Transform(matrix Matrix) {
 	t.V1.Normal = matrix.MulDirection(t.V1.Normal)
 	t.V2.Normal = matrix.MulDirection(t.V2.Normal)
 	t.V3.Normal = matrix.MulDirection(t.V3.Normal)
+	if t.V1.Tangent != (VectorW{}) || t.V2.Tangent != (VectorW{}) || t.V3.Tangent != (VectorW{}) {
+		mirror := matrix.Determinant() < 0
+		t.V1.Tangent = transformTangent(matrix, t.V1.Tangent, mirror)
+		t.V2.Tangent = transformTangent(matrix, t.V2.Tangent, mirror)
+		t.V3.Tangent = transformTangent(matrix, t.V3.Tangent, mirror)
+	}
 }
 
 // ReverseWinding f
@@ -0,0 +1,148 @@
+package fauxgl
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// UDIMToken is the placeholder used in texture paths for the UDIM tile number
+const UDIMToken = "<UDIM>"
+
+// UDIMTileIndex returns the UDIM tile number (1001, 1002, ...) for a UV coordinate.
+// Tiles advance by one per unit in U (10 columns) and by ten per unit in V.
+// It reports false for coordinates outside the tile grid of 10 columns and
+// 100 rows (tiles 1001..1999), i.e. U outside [0, 10) or V outside [0, 100).
+func UDIMTileIndex(u, v float64) (int, bool) {
+	column := math.Floor(u)
+	row := math.Floor(v)
+	if column < 0 || column > 9 || row < 0 || row > 99 {
+		return 0, false
+	}
+	return 1001 + int(column) + int(row)*10, true
+}
+
+// UDIMTileOffset returns the integer UV offset of a UDIM tile number
+func UDIMTileOffset(tile int) (float64, float64) {
+	index := tile - 1001
+	return float64(index % 10), float64(index / 10)
+}
+
+// IsUDIMPath reports whether a texture path refers to a UDIM tile set
+func IsUDIMPath(path string) bool {
+	return strings.Contains(path, UDIMToken)
+}
+
+// NewUDIMTexture creates a texture that dispatches samples to UDIM tiles.
+// The 1001 tile (or the lowest tile present) provides the base image.
+func NewUDIMTexture(tiles map[int]*AdvancedTexture, textureType TextureType) (*AdvancedTexture, error) {
+	if len(tiles) == 0 {
+		return nil, fmt.Errorf("no UDIM tiles provided")
+	}
+
+	first := -1
+	for tile := range tiles {
+		if first < 0 || tile < first {
+			first = tile
+		}
+	}
+	if _, ok := tiles[1001]; ok {
+		first = 1001
+	}
+	base := tiles[first]
+
+	texture := &AdvancedTexture{
+		Image:     base.Image,
+		Width:     base.Width,
+		Height:    base.Height,
+		Type:      textureType,
+		WrapS:     WrapRepeat,
+		WrapT:     WrapRepeat,
+		MinFilter: base.MinFilter,
+		MagFilter: base.MagFilter,
+		MipLevels: base.MipLevels,
+		Transform: Identity(),
+		UDIMTiles: tiles,
+	}
+	return texture, nil
+}
+
+// LoadUDIMTexture loads all tiles matching a path pattern containing
+// UDIMToken, e.g. "textures/body_color.<UDIM>.png"
+func LoadUDIMTexture(pattern string, textureType TextureType) (*AdvancedTexture, error) {
+	return loadUDIMTiles(pattern, textureType, filepath.Glob, func(path string) (*AdvancedTexture, error) {
+		return LoadAdvancedTexture(path, textureType)
+	})
+}
+
+// LoadUDIMTextureFS loads all tiles matching a slash separated pattern
+// containing UDIMToken from a file system
+func LoadUDIMTextureFS(fsys fs.FS, pattern string, textureType TextureType) (*AdvancedTexture, error) {
+	glob := func(pattern string) ([]string, error) {
+		return fs.Glob(fsys, pattern)
+	}
+	return loadUDIMTiles(pattern, textureType, glob, func(name string) (*AdvancedTexture, error) {
+		return LoadAdvancedTextureFS(fsys, name, textureType)
+	})
+}
+
+// loadUDIMTiles expands the UDIM token of pattern with glob and loads every matching tile
+func loadUDIMTiles(pattern string, textureType TextureType, glob func(string) ([]string, error),
+	load func(string) (*AdvancedTexture, error)) (*AdvancedTexture, error) {
+	if !IsUDIMPath(pattern) {
+		return nil, fmt.Errorf("path %s does not contain %s", pattern, UDIMToken)
+	}
+
+	prefix := pattern[:strings.Index(pattern, UDIMToken)]
+	suffix := pattern[strings.Index(pattern, UDIMToken)+len(UDIMToken):]
+	matches, err := glob(prefix + "[0-9][0-9][0-9][0-9]" + suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	tiles := make(map[int]*AdvancedTexture)
+	for _, path := range matches {
+		number := strings.TrimSuffix(strings.TrimPrefix(path, prefix), suffix)
+		tile, err := strconv.Atoi(number)
+		if err != nil || tile < 1001 {
+			continue
+		}
+		texture, err := load(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load UDIM tile %d: %w", tile, err)
+		}
+		tiles[tile] = texture
+	}
+
+	if len(tiles) == 0 {
+		return nil, fmt.Errorf("no UDIM tiles found for %s", pattern)
+	}
+
+	return NewUDIMTexture(tiles, textureType)
+}
+
+// sampleUDIM applies the transform and samples the tile addressed by the
+// transformed UV coordinate, see sample. Coordinates falling on a missing
+// tile or outside the tile grid return transparent black.
+func (t *AdvancedTexture) sampleUDIM(u, v float64, dx, dy Vector, filter TextureFilter, grad bool) Color {
+	uv := t.Transform.MulPosition(Vector{u, v, 0})
+	u, v = uv.X, uv.Y
+	index, ok := UDIMTileIndex(u, v)
+	if !ok {
+		return Transparent
+	}
+	tile, ok := t.UDIMTiles[index]
+	if !ok {
+		return Transparent
+	}
+	if grad {
+		m := t.Transform
+		dx = Vector{m.X00*dx.X + m.X01*dx.Y, m.X10*dx.X + m.X11*dx.Y, 0}
+		dy = Vector{m.X00*dy.X + m.X01*dy.Y, m.X10*dy.X + m.X11*dy.Y, 0}
+		filter = tile.MagFilter
+	}
+	return tile.sample(u-math.Floor(u), v-math.Floor(v), dx, dy, filter, grad)
+}
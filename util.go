@@ -1,11 +1,15 @@
 package fauxgl
 
 import (
+	"bytes"
 	"image"
 	_ "image/jpeg"
 	"image/png"
+	"io/fs"
 	"math"
 	"os"
+
+	_ "golang.org/x/image/webp"
 )
 
 func Radians(degrees float64) float64 {
@@ -34,6 +38,24 @@ func LoadImage(path string) (image.Image, error) {
 	return im, err
 }
 
+// LoadImageFS loads an image from a file system, e.g. an embed.FS or an
+// in-memory file system on platforms without os file access
+func LoadImageFS(fsys fs.FS, name string) (image.Image, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	im, _, err := image.Decode(file)
+	return im, err
+}
+
+// DecodeImage decodes an encoded image (PNG, JPEG, WebP or KTX2) held in memory
+func DecodeImage(data []byte) (image.Image, error) {
+	im, _, err := image.Decode(bytes.NewReader(data))
+	return im, err
+}
+
 func SavePNG(path string, im image.Image) error {
 	file, err := os.Create(path)
 	if err != nil {
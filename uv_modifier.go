@@ -122,6 +122,11 @@ func (modifier *UVModifier) SetGlobalTransform(transform *UVTransform) {
 	modifier.globalTransform = transform
 }
 
+// GlobalTransform returns the global UV transformation
+func (modifier *UVModifier) GlobalTransform() *UVTransform {
+	return modifier.globalTransform
+}
+
 // EnableAnimation enables/disables UV animation
 func (modifier *UVModifier) EnableAnimation(enabled bool) {
 	modifier.animationEnabled = enabled
@@ -144,6 +149,22 @@ func (modifier *UVModifier) UpdateAnimation(deltaTime float64) {
 	}
 }
 
+// SetAnimationTime advances all animated transforms to an absolute time.
+// Unlike UpdateAnimation this is deterministic, so frame sequences can be
+// rendered out of order or re-rendered at any time.
+func (modifier *UVModifier) SetAnimationTime(time float64) {
+	if !modifier.animationEnabled {
+		return
+	}
+
+	modifier.updateTransformAnimation(modifier.globalTransform, time-modifier.globalTransform.AnimationTime)
+	for _, mapping := range modifier.mappings {
+		if mapping.Enabled && mapping.Transform != nil {
+			modifier.updateTransformAnimation(mapping.Transform, time-mapping.Transform.AnimationTime)
+		}
+	}
+}
+
 // updateTransformAnimation updates a single transform's animation
 func (modifier *UVModifier) updateTransformAnimation(transform *UVTransform, deltaTime float64) {
 	transform.AnimationTime += deltaTime
@@ -263,6 +284,16 @@ func (modifier *UVModifier) isInRegion(u, v float64, region *UVRegion) bool {
 	}
 }
 
+// Weight returns the influence of the region at UV coordinates, 0 outside
+// it, with the falloff of its mask type
+func (region *UVRegion) Weight(u, v float64) float64 {
+	var modifier UVModifier
+	if !modifier.isInRegion(u, v, region) {
+		return 0
+	}
+	return modifier.calculateRegionWeight(u, v, region)
+}
+
 // calculateRegionWeight calculates the influence weight for a region
 func (modifier *UVModifier) calculateRegionWeight(u, v float64, region *UVRegion) float64 {
 	switch region.MaskType {
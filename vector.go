@@ -24,7 +24,7 @@ func (a Vector) IsDegenerate() bool {
 
 func (a Vector) Length() float64 {
 	// 使用SIMD优化的长度计算
-	sv := NewSIMDVector4FromVector(a)
+	sv := NewSIMDDirectionFromVector(a)
 	return sv.Length()
 }
 
@@ -83,7 +83,7 @@ func (a Vector) Cross(b Vector) Vector {
 
 func (a Vector) Normalize() Vector {
 	// 使用SIMD优化的归一化
-	sv := NewSIMDVector4FromVector(a)
+	sv := NewSIMDDirectionFromVector(a)
 	return sv.Normalize().ToVector()
 }
 
@@ -5,7 +5,20 @@ type Vertex struct {
 	Normal   Vector
 	Texture  Vector
 	Color    Color
-	Output   VectorW
+	// Tangent of the surface along +u in XYZ and the handedness of the
+	// bitangent (+1 or -1) in W, as in glTF TANGENT attributes. A zero
+	// tangent disables normal mapping of the vertex.
+	Tangent VectorW
+	Output  VectorW
+	// Screen space derivatives of Texture per pixel step in x and y, set by
+	// the rasterizer for fragment shaders (see AdvancedTexture.SampleGrad)
+	TextureDx Vector
+	TextureDy Vector
+	// Skinning influences: indices into Skin.Joints and their weights, as
+	// loaded from JOINTS_0 and WEIGHTS_0. All zero weights leave the vertex
+	// in place.
+	Joints  [4]int
+	Weights [4]float64
 	// Vectors  []Vector
 	// Colors   []Color
 	// Floats   []float64
@@ -21,6 +34,10 @@ func InterpolateVertexes(v1, v2, v3 Vertex, b VectorW) Vertex {
 	v.Normal = InterpolateVectors(v1.Normal, v2.Normal, v3.Normal, b).Normalize()
 	v.Texture = InterpolateVectors(v1.Texture, v2.Texture, v3.Texture, b)
 	v.Color = InterpolateColors(v1.Color, v2.Color, v3.Color, b)
+	if v1.Tangent != (VectorW{}) {
+		t := InterpolateVectors(v1.Tangent.Vector(), v2.Tangent.Vector(), v3.Tangent.Vector(), b)
+		v.Tangent = VectorW{t.X, t.Y, t.Z, v1.Tangent.W}
+	}
 	v.Output = InterpolateVectorWs(v1.Output, v2.Output, v3.Output, b)
 	// if v1.Vectors != nil {
 	// 	v.Vectors = make([]Vector, len(v1.Vectors))
@@ -0,0 +1,81 @@
+package fauxgl
+
+import "math"
+
+// VertexModifier deforms a vertex in model space at time t, in seconds,
+// before the shader transforms it. Modifiers run concurrently for the
+// vertices of a mesh and must not modify shared state. Bounds used for
+// culling and shadow fitting are those of the undeformed mesh, so keep
+// displacements small.
+type VertexModifier func(v Vertex, t float64) Vertex
+
+// modifierShader applies a vertex modifier before the vertex stage of
+// another shader
+type modifierShader struct {
+	Shader
+	modifier VertexModifier
+	time     float64
+}
+
+func (shader *modifierShader) Vertex(v Vertex) Vertex {
+	return shader.Shader.Vertex(shader.modifier(v, shader.time))
+}
+
+// modify returns shader with the vertex modifier of the node at time t
+// applied, or shader itself when the node has none
+func (node *SceneNode) modify(shader Shader, t float64) Shader {
+	if node.Modifier == nil {
+		return shader
+	}
+	return &modifierShader{shader, node.Modifier, t}
+}
+
+// ChainModifiers returns a modifier applying modifiers in order
+func ChainModifiers(modifiers ...VertexModifier) VertexModifier {
+	return func(v Vertex, t float64) Vertex {
+		for _, modifier := range modifiers {
+			v = modifier(v, t)
+		}
+		return v
+	}
+}
+
+// WindModifier sways vertices along the horizontal direction of the wind,
+// as foliage does. Vertices at base height (along Y in model space) stay in
+// place and the sway grows with the square of the height above it, by
+// strength at one unit. The sway oscillates frequency times per second,
+// gusting, with a phase varying across the mesh so that it doesn't move
+// rigidly.
+func WindModifier(direction Vector, strength, frequency, base float64) VertexModifier {
+	direction = Vector{direction.X, 0, direction.Z}.Normalize()
+	return func(v Vertex, t float64) Vertex {
+		h := v.Position.Y - base
+		if h <= 0 {
+			return v
+		}
+		phase := (v.Position.X + v.Position.Z) * 0.5
+		w := 2 * math.Pi * frequency
+		sway := 0.6 + 0.4*math.Sin(w*t+phase) + 0.2*math.Sin(2.7*w*t+3.1*phase)
+		v.Position = v.Position.Add(direction.MulScalar(strength * sway * h * h))
+		return v
+	}
+}
+
+// WaveModifier displaces vertices along their normal by a sine wave
+// traveling along direction, with the given amplitude, wavelength and
+// speed, for flags, water surfaces and similar. Normals are tilted to
+// follow the slope of the wave.
+func WaveModifier(direction Vector, amplitude, wavelength, speed float64) VertexModifier {
+	direction = direction.Normalize()
+	k := 2 * math.Pi / wavelength
+	return func(v Vertex, t float64) Vertex {
+		x := k * (v.Position.Dot(direction) - speed*t)
+		normal := v.Normal.Normalize()
+		v.Position = v.Position.Add(normal.MulScalar(amplitude * math.Sin(x)))
+		slope := amplitude * k * math.Cos(x)
+		// Remove the tangential part of direction to tilt within the surface
+		tangent := direction.Sub(normal.MulScalar(direction.Dot(normal)))
+		v.Normal = normal.Sub(tangent.MulScalar(slope)).Normalize()
+		return v
+	}
+}
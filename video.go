@@ -0,0 +1,208 @@
+package fauxgl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+	"os"
+)
+
+// EncoderSink receives the frames of an animation in order and encodes
+// them into a video or image sequence, see AnimationExporter. Close
+// finishes the output; no frames may be written after it.
+type EncoderSink interface {
+	WriteFrame(img image.Image) error
+	Close() error
+}
+
+// ImageSequenceSink saves every frame as a numbered image file, the
+// output of the repository's examples before video encoding existed
+type ImageSequenceSink struct {
+	// Pattern formats the frame number into a path, e.g. "frame_%04d.png"
+	Pattern string
+	frame   int
+}
+
+// NewImageSequenceSink creates a sink saving PNG files named by a pattern
+func NewImageSequenceSink(pattern string) *ImageSequenceSink {
+	return &ImageSequenceSink{Pattern: pattern}
+}
+
+func (s *ImageSequenceSink) WriteFrame(img image.Image) error {
+	path := fmt.Sprintf(s.Pattern, s.frame)
+	s.frame++
+	return SavePNG(path, img)
+}
+
+func (s *ImageSequenceSink) Close() error {
+	return nil
+}
+
+// MJPEGSink encodes frames as Motion JPEG in an AVI file, playable by
+// common video players without any codec dependency. Frames must all have
+// the size given at creation; alpha is dropped.
+type MJPEGSink struct {
+	Quality int // JPEG quality of the frames
+
+	w             io.WriteSeeker
+	closer        io.Closer
+	width, height int
+	fps           float64
+	offset        int64    // Bytes written
+	index         [][2]int // Offset within movi and size of each frame
+	maxSize       int
+	err           error
+}
+
+// AVI header layout, see header
+const (
+	aviRIFFSize     = 4   // Offset of the RIFF size
+	aviTotalFrames  = 48  // Offset of the frame count of the main header
+	aviSuggested    = 60  // Offset of the buffer size of the main header
+	aviStreamLength = 140 // Offset of the frame count of the stream header
+	aviStreamBuffer = 144 // Offset of the buffer size of the stream header
+	aviMoviSize     = 216 // Offset of the movi list size
+	aviMovi         = 220 // Offset of the movi list type, base of idx1 offsets
+)
+
+// NewMJPEGSink creates a Motion JPEG AVI sink writing to w. The file is
+// completed by Close, which seeks back to fill in the frame counts.
+func NewMJPEGSink(w io.WriteSeeker, width, height int, fps float64) (*MJPEGSink, error) {
+	if width <= 0 || height <= 0 || fps <= 0 {
+		return nil, errors.New("invalid video size or frame rate")
+	}
+	s := &MJPEGSink{Quality: 90, w: w, width: width, height: height, fps: fps}
+	if err := s.write(s.header()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CreateMJPEGFile creates a Motion JPEG AVI file at path
+func CreateMJPEGFile(path string, width, height int, fps float64) (*MJPEGSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewMJPEGSink(file, width, height, fps)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.closer = file
+	return s, nil
+}
+
+// header returns the RIFF, main and stream headers and the start of the
+// movi list, with the counts left for Close
+func (s *MJPEGSink) header() []byte {
+	var b bytes.Buffer
+	le := func(values ...interface{}) {
+		for _, v := range values {
+			binary.Write(&b, binary.LittleEndian, v)
+		}
+	}
+	scale, rate := uint32(1000), uint32(math.Round(s.fps*1000))
+	w, h := uint32(s.width), uint32(s.height)
+	b.WriteString("RIFF")
+	le(uint32(0))
+	b.WriteString("AVI LIST")
+	le(uint32(192))
+	b.WriteString("hdrlavih")
+	le(uint32(56), uint32(math.Round(1e6/s.fps)), uint32(0), uint32(0), uint32(0x10), // AVIF_HASINDEX
+		uint32(0), uint32(0), uint32(1), uint32(0), w, h, [4]uint32{})
+	b.WriteString("LIST")
+	le(uint32(116))
+	b.WriteString("strlstrh")
+	le(uint32(56))
+	b.WriteString("vidsMJPG")
+	le(uint32(0), uint16(0), uint16(0), uint32(0), scale, rate, uint32(0), uint32(0), uint32(0),
+		int32(-1), uint32(0), [4]int16{0, 0, int16(s.width), int16(s.height)})
+	b.WriteString("strf")
+	le(uint32(40), uint32(40), int32(s.width), int32(s.height), uint16(1), uint16(24))
+	b.WriteString("MJPG")
+	le(w*h*3, int32(0), int32(0), uint32(0), uint32(0))
+	b.WriteString("LIST")
+	le(uint32(0))
+	b.WriteString("movi")
+	return b.Bytes()
+}
+
+func (s *MJPEGSink) write(data []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	n, err := s.w.Write(data)
+	s.offset += int64(n)
+	s.err = err
+	return err
+}
+
+func (s *MJPEGSink) WriteFrame(img image.Image) error {
+	if img.Bounds().Dx() != s.width || img.Bounds().Dy() != s.height {
+		return fmt.Errorf("frame is %dx%d, not %dx%d", img.Bounds().Dx(), img.Bounds().Dy(), s.width, s.height)
+	}
+	var frame bytes.Buffer
+	frame.WriteString("00dc")
+	frame.Write(make([]byte, 4))
+	if err := jpeg.Encode(&frame, img, &jpeg.Options{Quality: s.Quality}); err != nil {
+		return fmt.Errorf("failed to encode frame: %w", err)
+	}
+	size := frame.Len() - 8
+	binary.LittleEndian.PutUint32(frame.Bytes()[4:], uint32(size))
+	if size%2 == 1 {
+		frame.WriteByte(0) // chunks are word aligned
+	}
+	s.index = append(s.index, [2]int{int(s.offset - aviMovi), size})
+	if size > s.maxSize {
+		s.maxSize = size
+	}
+	return s.write(frame.Bytes())
+}
+
+// Close writes the index and the frame counts, and closes the file of a
+// sink created by CreateMJPEGFile
+func (s *MJPEGSink) Close() error {
+	moviEnd := s.offset
+	var idx bytes.Buffer
+	idx.WriteString("idx1")
+	binary.Write(&idx, binary.LittleEndian, uint32(16*len(s.index)))
+	for _, entry := range s.index {
+		idx.WriteString("00dc")
+		binary.Write(&idx, binary.LittleEndian, [3]uint32{0x10, uint32(entry[0]), uint32(entry[1])}) // AVIIF_KEYFRAME
+	}
+	s.write(idx.Bytes())
+
+	patch := func(offset int64, value uint32) {
+		if s.err != nil {
+			return
+		}
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], value)
+		if _, s.err = s.w.Seek(offset, io.SeekStart); s.err == nil {
+			_, s.err = s.w.Write(b[:])
+		}
+	}
+	patch(aviRIFFSize, uint32(s.offset-8))
+	patch(aviTotalFrames, uint32(len(s.index)))
+	patch(aviSuggested, uint32(s.maxSize+8))
+	patch(aviStreamLength, uint32(len(s.index)))
+	patch(aviStreamBuffer, uint32(s.maxSize+8))
+	patch(aviMoviSize, uint32(moviEnd-aviMovi))
+	if s.err == nil {
+		_, s.err = s.w.Seek(0, io.SeekEnd)
+	}
+	err := s.err
+	if s.closer != nil {
+		if closeErr := s.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	s.err = errors.New("video sink closed")
+	return err
+}
@@ -0,0 +1,91 @@
+package fauxgl
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+// CommandSink pipes frames as raw RGBA video into an external encoder
+// process, such as ffmpeg, which writes the video itself. It adds codecs
+// like VP8 without linking them: the encoder is an optional runtime
+// dependency, found on the PATH when the sink is created.
+type CommandSink struct {
+	cmd           *exec.Cmd
+	stdin         io.WriteCloser
+	width, height int
+	frame         *image.NRGBA
+}
+
+// NewCommandSink starts an encoder command reading raw RGBA frames of a
+// size from its standard input
+func NewCommandSink(width, height int, name string, args ...string) (*CommandSink, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("video encoder %s not available: %w", name, err)
+	}
+	cmd := exec.Command(path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start video encoder: %w", err)
+	}
+	return &CommandSink{
+		cmd:    cmd,
+		stdin:  stdin,
+		width:  width,
+		height: height,
+		frame:  image.NewNRGBA(image.Rect(0, 0, width, height)),
+	}, nil
+}
+
+// NewFFmpegSink encodes frames with ffmpeg into the file at path, in the
+// format its extension selects, with extra output arguments such as
+// codec options
+func NewFFmpegSink(path string, width, height int, fps float64, args ...string) (*CommandSink, error) {
+	input := []string{
+		"-loglevel", "error", "-y",
+		"-f", "rawvideo", "-pix_fmt", "rgba",
+		"-s", fmt.Sprintf("%dx%d", width, height),
+		"-r", strconv.FormatFloat(fps, 'f', -1, 64),
+		"-i", "-",
+	}
+	return NewCommandSink(width, height, "ffmpeg", append(append(input, args...), path)...)
+}
+
+// NewWebMSink encodes frames as VP8 into a WebM file with ffmpeg
+func NewWebMSink(path string, width, height int, fps float64) (*CommandSink, error) {
+	return NewFFmpegSink(path, width, height, fps, "-c:v", "libvpx", "-b:v", "4M", "-auto-alt-ref", "0")
+}
+
+func (s *CommandSink) WriteFrame(img image.Image) error {
+	if img.Bounds().Dx() != s.width || img.Bounds().Dy() != s.height {
+		return fmt.Errorf("frame is %dx%d, not %dx%d", img.Bounds().Dx(), img.Bounds().Dy(), s.width, s.height)
+	}
+	frame, ok := img.(*image.NRGBA)
+	if !ok || frame.Stride != 4*s.width {
+		draw.Draw(s.frame, s.frame.Rect, img, img.Bounds().Min, draw.Src)
+		frame = s.frame
+	}
+	_, err := s.stdin.Write(frame.Pix[:4*s.width*s.height])
+	return err
+}
+
+// Close ends the input of the encoder and waits for it to finish the file
+func (s *CommandSink) Close() error {
+	err := s.stdin.Close()
+	if waitErr := s.cmd.Wait(); waitErr != nil {
+		var exit *exec.ExitError
+		if errors.As(waitErr, &exit) {
+			return fmt.Errorf("video encoder failed: %w", waitErr)
+		}
+		return waitErr
+	}
+	return err
+}